@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -53,12 +54,50 @@ func main() {
 		break
 	}
 
-	// Initialize OpenAI for content generation
-	if err := services.InitOpenAI(); err != nil {
-		log.Printf("⚠ Warning: OpenAI initialization failed: %v", err)
+	// Versioned prompt templates (embedded, PROMPT_TEMPLATES_DIR and prompt_templates table)
+	if err := services.InitPromptTemplates(); err != nil {
+		log.Fatalf("Failed to load prompt templates: %v", err)
+	}
+
+	// Drop shared content generated with prompt versions no longer assigned
+	if purged, err := services.PurgeStaleContentCache(); err != nil {
+		log.Printf("⚠ Warning: content cache purge failed: %v", err)
+	} else if purged > 0 {
+		log.Printf("✓ Purged %d stale content cache entries", purged)
+	}
+
+	// Moderation of generated content and student inputs
+	if err := services.InitModeration(); err != nil {
+		log.Fatalf("Failed to initialize moderation: %v", err)
+	}
+
+	// Curriculum reference documents: embeddings provider and vector index
+	if err := services.InitCurriculumRAG(); err != nil {
+		log.Printf("⚠ Warning: curriculum search initialization failed: %v", err)
+		log.Println("Generation will run without curriculum references")
+	}
+
+	// Initialize LLM client for content generation
+	if err := services.InitLLMClient(); err != nil {
+		log.Printf("⚠ Warning: LLM client initialization failed: %v", err)
 		log.Println("Learning plan generation will not be available")
+	} else {
+		// Background workers for asynchronous learning plan generation
+		services.StartGenerationWorkers(context.Background())
 	}
 
+	// Settles daily recommendation rewards and updates the bandit
+	services.StartRecommendationRewardSweeper(context.Background())
+
+	// Refits knowledge tracing parameters and recomputes student mastery
+	services.StartKnowledgeTracingFitter(context.Background())
+
+	// Refreshes per-question normative response times for rapid-guess detection
+	services.StartResponseTimeNormRefresher(context.Background())
+
+	// Expires practice and diagnostic sessions left inactive or paused for too long
+	services.StartSessionExpirySweeper(context.Background())
+
 	// Initialize router
 	r := chi.NewRouter()
 
@@ -95,6 +134,7 @@ func main() {
 		r.Put("/me", handlers.UpdateMe)
 		r.Post("/change-password", handlers.ChangePassword)
 		r.Delete("/me", handlers.DeleteMe)
+		r.Get("/me/llm-budget", handlers.GetMyLLMBudget)
 	})
 
 	// Protected profile routes
@@ -126,6 +166,9 @@ func main() {
 
 		// Get OA progress - needs auth
 		r.With(authmiddleware.AuthMiddleware).Get("/{materia_id}/oa-progress", handlers.GetOAProgressByMateria)
+		r.With(authmiddleware.AuthMiddleware).Get("/{id}/curriculum-map", handlers.GetCurriculumMap) // Prerequisite graph with the student's mastery
+		r.With(authmiddleware.AuthMiddleware, authmiddleware.RequireRole("admin", "docente")).
+			Post("/{id}/prerequisites/import", handlers.ImportOAPrerequisites) // Bulk import prerequisites from CSV
 
 		// Protected write operations
 		r.Group(func(r chi.Router) {
@@ -157,12 +200,26 @@ func main() {
 		})
 	})
 
+	// OA prerequisite graph (reads for any user, writes for teachers and admins)
+	r.Route("/api/oa-prerequisites", func(r chi.Router) {
+		r.Use(authmiddleware.AuthMiddleware)
+		r.Get("/", handlers.ListOAPrerequisites) // Edges by materia/OA
+
+		r.Group(func(r chi.Router) {
+			r.Use(authmiddleware.RequireRole("admin", "docente"))
+			r.Post("/", handlers.CreateOAPrerequisite)      // Add edge (409 if it closes a cycle)
+			r.Put("/{id}", handlers.UpdateOAPrerequisite)   // Update edge
+			r.Delete("/{id}", handlers.DeleteOAPrerequisite) // Delete edge
+		})
+	})
+
 	// Progress tracking routes (all protected)
 	r.Route("/api/progress", func(r chi.Router) {
 		r.Use(authmiddleware.AuthMiddleware)
 		r.Post("/", handlers.RegisterProgress)                  // Register progress
 		r.Get("/{user_id}", handlers.GetStudentProgress)        // Get student progress
 		r.Get("/{user_id}/history", handlers.GetProgressHistory) // Get progress history
+		r.Get("/{user_id}/knowledge", handlers.GetStudentKnowledge) // Knowledge tracing mastery estimates
 	})
 
 	// Question Types catalog (public)
@@ -191,7 +248,41 @@ func main() {
 		r.Get("/{id}/next-question", handlers.GetNextQuestion) // Get next adaptive question
 		r.Post("/{id}/answer", handlers.SubmitAnswer)          // Submit answer
 		r.Post("/{id}/complete", handlers.CompleteDiagnostic)  // Complete diagnostic
+		r.Post("/{id}/pause", handlers.PauseDiagnostic)        // Pause (no questions or answers until resumed)
+		r.Post("/{id}/resume", handlers.ResumeDiagnostic)      // Resume with the pending question
+		r.Post("/{id}/abandon", handlers.AbandonDiagnostic)    // Close without results
 		r.Get("/{id}/results", handlers.GetDiagnosticResults)  // Get diagnostic results
+		r.Get("/{id}/report", handlers.GetDiagnosticReport)    // Report as PDF, printable HTML or JSON
+	})
+
+	// Diagnostic reports in bulk per classroom (teachers and admins)
+	r.Route("/api/diagnostic-reports", func(r chi.Router) {
+		r.Use(authmiddleware.AuthMiddleware)
+		r.Use(authmiddleware.RequireRole("admin", "docente"))
+		r.Get("/", handlers.ExportClassroomDiagnosticReports) // Zip with the latest report of each student of a curso
+	})
+
+	// Misconception catalog per materia and classroom frequency (all protected)
+	r.Route("/api/misconceptions", func(r chi.Router) {
+		r.Use(authmiddleware.AuthMiddleware)
+		r.Get("/", handlers.ListMisconceptions) // Catalog by materia
+
+		r.Group(func(r chi.Router) {
+			r.Use(authmiddleware.RequireRole("admin", "docente"))
+			r.Post("/", handlers.CreateMisconception)             // Add entry
+			r.Put("/{id}", handlers.UpdateMisconception)          // Update texts or deactivate
+			r.Get("/dashboard", handlers.GetMisconceptionDashboard) // Frequency among the students of a curso
+		})
+	})
+
+	// Placement diagnostic across every materia of the student's curso (all protected)
+	r.Route("/api/placement-diagnostics", func(r chi.Router) {
+		r.Use(authmiddleware.AuthMiddleware)
+		r.Post("/", handlers.StartPlacement)                            // Sample OAs within the budget and open one session per materia
+		r.Get("/{id}", handlers.GetPlacement)                           // Placement with sessions and remaining budget
+		r.Get("/{id}/next-question", handlers.GetPlacementNextQuestion) // Next question (answer it in its diagnostic session)
+		r.Post("/{id}/complete", handlers.CompletePlacement)             // Complete sessions, build report, seed progress and profile
+		r.Get("/{id}/report", handlers.GetPlacementReport)              // Consolidated placement report
 	})
 
 	// Practice System (all protected)
@@ -201,7 +292,11 @@ func main() {
 		r.Post("/", handlers.StartPractice)                           // Start practice session
 		r.Get("/{id}/next-question", handlers.GetPracticeNextQuestion) // Get next adaptive question
 		r.Post("/{id}/answer", handlers.SubmitPracticeAnswer)          // Submit answer
+		r.Get("/{id}/hint", handlers.GetPracticeHint)                  // Reveal the next hint of the pending question
 		r.Post("/{id}/complete", handlers.CompletePracticeSession)     // Complete practice session
+		r.Post("/{id}/pause", handlers.PausePracticeSession)           // Pause (no questions or answers until resumed)
+		r.Post("/{id}/resume", handlers.ResumePracticeSession)         // Resume with the pending question
+		r.Post("/{id}/abandon", handlers.AbandonPracticeSession)       // Close without completing
 	})
 
 	// Gamification System (all protected)
@@ -226,16 +321,38 @@ func main() {
 	r.Route("/api/learning-plans", func(r chi.Router) {
 		r.Use(authmiddleware.AuthMiddleware)
 
-		// Generate endpoint with extended timeout (120s) for full content generation
-		r.With(middleware.Timeout(120 * time.Second)).Post("/generate", handlers.GenerateLearningPlanHandler)
+		// Generation runs in background workers; /generate returns 202 with a job to follow
+		r.Post("/generate", handlers.GenerateLearningPlanHandler)
+		r.Get("/jobs/{job_id}", handlers.GetGenerationJobHandler)                  // Job status and component progress
+		r.Get("/jobs/{job_id}/events", handlers.StreamGenerationJobEventsHandler) // Job progress as Server-Sent Events
 
 		r.Get("/{id}", handlers.GetLearningPlanByIDHandler)                                  // Get plan by ID
 		r.Get("/by-oa/{oa_bloom_objective_id}", handlers.GetLearningPlanByOAHandler)        // Get plan by OA
 		r.Post("/{plan_id}/components/{component_id}/generate-content", handlers.GenerateComponentContentHandler) // Generate component content (legacy, for individual components)
+		r.Post("/{plan_id}/components/{component_id}/stream-content", handlers.StreamComponentContentHandler)     // Stream component content block by block (SSE)
+		r.Post("/{plan_id}/components/{component_id}/regenerate", handlers.RegenerateComponentHandler)           // Reset a component for regeneration with feedback
+
+		// Feedback, regeneration and versions
+		r.Post("/{id}/feedback", handlers.SubmitLearningPlanFeedbackHandler)                    // Thumbs up/down and comment on a component or the plan
+		r.Get("/{id}/feedback", handlers.ListLearningPlanFeedbackHandler)                       // Feedback given on the plan
+		r.Post("/{id}/regenerate", handlers.RegenerateLearningPlanHandler)                      // Regenerate the whole plan (202 with job)
+		r.Get("/{id}/versions", handlers.ListLearningPlanVersionsHandler)                       // Plan versions
+		r.Get("/{id}/versions/compare", handlers.CompareLearningPlanVersionsHandler)            // Compare two versions
+		r.Get("/{id}/versions/{version}", handlers.GetLearningPlanVersionHandler)               // Version snapshot
+		r.Post("/{id}/versions/{version}/rollback", handlers.RollbackLearningPlanHandler)       // Restore a previous version
+
+		// Export for LMSs and printing
+		r.Get("/{id}/export", handlers.ExportLearningPlanHandler) // SCORM 1.2 / xAPI / HTML / Markdown zip (?format=)
 
 		// Completion tracking
 		r.Post("/{id}/start", handlers.StartLearningPlanHandler)       // Mark plan as started
-		r.Post("/{id}/complete", handlers.CompleteLearningPlanHandler) // Mark plan as completed
+		r.Post("/{id}/complete", handlers.CompleteLearningPlanHandler) // Mark plan as completed (requires passing all checkpoints)
+		r.Post("/{id}/events", handlers.RecordLearningPlanEventsHandler) // Slide view events (opened, time, scroll, audio)
+		r.Get("/{id}/progress", handlers.GetLearningPlanProgressHandler) // Progress, resume point and per-slide activity
+
+		// Check-for-understanding checkpoints
+		r.Get("/{id}/checkpoints", handlers.ListLearningPlanCheckpointsHandler)             // Checkpoints with their questions
+		r.Post("/{id}/checkpoints/{checkpoint_id}/submit", handlers.SubmitCheckpointHandler) // Grade answers; failing inserts a remediation slide
 	})
 
 	// Text-to-Speech System (all protected)
@@ -247,7 +364,69 @@ func main() {
 	// Recommendations System (all protected)
 	r.Route("/api/recommendations", func(r chi.Router) {
 		r.Use(authmiddleware.AuthMiddleware)
-		r.Get("/daily", handlers.GetDailyRecommendation) // Get personalized daily recommendation
+		r.Get("/", handlers.ListRecommendations)                   // Ranked recommendations across subjects
+		r.Get("/daily", handlers.GetDailyRecommendation)           // Get personalized daily recommendation
+		r.Post("/{id}/click", handlers.RecordRecommendationClick) // Student opened a recommendation
+	})
+
+	// Study goals and their weekly schedule
+	r.Route("/api/study-goals", func(r chi.Router) {
+		r.Get("/feed/{token}.ics", handlers.GetStudyGoalFeed) // Public: iCalendar feed by secret token
+
+		r.Group(func(r chi.Router) {
+			r.Use(authmiddleware.AuthMiddleware)
+			r.Post("/", handlers.CreateStudyGoal)                            // Create goal and schedule its tasks
+			r.Get("/", handlers.ListStudyGoals)                              // Student goals with schedule and progress
+			r.Get("/{id}", handlers.GetStudyGoal)                            // Goal detail (syncs done tasks, replans if behind)
+			r.Put("/{id}", handlers.UpdateStudyGoal)                         // Replace goal and replan
+			r.Delete("/{id}", handlers.DeleteStudyGoal)                      // Delete goal
+			r.Post("/{id}/replan", handlers.ReplanStudyGoal)                 // Reschedule pending work from today
+			r.Patch("/{id}/tasks/{taskId}", handlers.UpdateStudyGoalTask)    // Mark a task done or skipped
+			r.Get("/{id}/calendar.ics", handlers.GetStudyGoalCalendar)       // Download schedule as iCalendar
+		})
+	})
+
+	// Engagement from response times (teachers and admins)
+	r.Route("/api/engagement", func(r chi.Router) {
+		r.Use(authmiddleware.AuthMiddleware)
+		r.Use(authmiddleware.RequireRole("admin", "docente"))
+		r.Get("/students", handlers.GetEngagementReport)                             // Per-student rapid guesses and effort
+		r.Get("/students/{user_id}/sessions", handlers.GetStudentEngagementSessions) // Engagement of each session
+	})
+
+	// Curriculum reference documents (teachers and admins)
+	r.Route("/api/curriculum-documents", func(r chi.Router) {
+		r.Use(authmiddleware.AuthMiddleware)
+		r.Use(authmiddleware.RequireRole("admin", "docente"))
+		r.Post("/", handlers.CreateCurriculumDocument)          // Upload a document (chunked and indexed)
+		r.Get("/", handlers.ListCurriculumDocuments)            // List documents by materia/OA
+		r.Get("/search", handlers.SearchCurriculumDocuments)    // Preview the passages a prompt would receive
+		r.Get("/{id}", handlers.GetCurriculumDocument)          // Document with content
+		r.Delete("/{id}", handlers.DeleteCurriculumDocument)    // Delete document and passages
+	})
+
+	// Admin routes (admin role only)
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Use(authmiddleware.AuthMiddleware)
+		r.Use(authmiddleware.RequireRole("admin"))
+		r.Get("/llm-usage", handlers.GetLLMUsageReport)                 // LLM usage and cost report
+		r.Put("/users/{user_id}/llm-budget", handlers.SetUserLLMBudget) // Set a user's daily LLM budget
+		r.Put("/users/{user_id}/role", handlers.SetUserRole)            // Grant or revoke docente and admin
+		r.Get("/learning-plans/abandonment", handlers.GetPlanAbandonmentReport) // Where students abandon learning plans
+		r.Get("/content-cache/stats", handlers.GetContentCacheStats)             // Shared content cache hit rate
+		r.Delete("/content-cache", handlers.InvalidateContentCache)               // Invalidate shared content cache
+		r.Get("/recommendations/metrics", handlers.GetRecommendationMetrics)      // Recommender impressions, clicks and CTR
+		r.Get("/recommendations/bandit", handlers.GetRecommendationBandit)        // Daily recommendation bandit posteriors
+		r.Get("/recommendations/replay", handlers.ReplayRecommendationPolicy)     // Offline evaluation of a bandit policy
+		r.Get("/knowledge-tracing/params", handlers.ListKnowledgeTracingParams)   // Fitted BKT parameters
+		r.Post("/knowledge-tracing/fit", handlers.FitKnowledgeTracing)            // Refit BKT and recompute mastery
+		r.Get("/moderation/flags", handlers.ListModerationFlags)                  // Flagged content pending review
+		r.Post("/moderation/flags/{id}/review", handlers.ReviewModerationFlag)    // Approve or reject flagged content
+		r.Get("/prompts", handlers.ListPromptTemplates)                            // Loaded prompt template versions and A/B weights
+		r.Post("/prompts/reload", handlers.ReloadPromptTemplates)                  // Reload templates from files and database
+		r.Put("/prompts/{nombre}/{version}", handlers.SavePromptTemplate)         // Create or edit a prompt template version
+		r.Get("/prompts/{nombre}/experiment", handlers.GetPromptExperimentReport) // Outcomes per prompt version
+		r.Post("/curriculum-documents/reindex", handlers.ReindexCurriculumDocuments) // Re-embed curriculum documents
 	})
 
 	// Static file server for avatars
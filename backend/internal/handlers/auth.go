@@ -15,7 +15,6 @@ type RegisterRequest struct {
 	Email    string `json:"email"`
 	Name     string `json:"name"`
 	Password string `json:"password"`
-	Role     string `json:"role,omitempty"`
 }
 
 // LoginRequest represents the login payload
@@ -32,7 +31,7 @@ type AuthResponse struct {
 
 // Register godoc
 // @Summary Register a new user
-// @Description Creates a new user account and returns a JWT token
+// @Description Creates a new student account (role "user") and returns a JWT token. Teacher and admin roles are granted by an admin.
 // @Tags Authentication
 // @Accept json
 // @Produce json
@@ -65,16 +64,11 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Set default role if not provided
-	if req.Role == "" {
-		req.Role = "user"
-	}
-
-	// Create new user
+	// Create new user. Self-registered accounts are always students: docente and admin are granted by an admin
 	user := models.User{
 		Email: req.Email,
 		Name:  req.Name,
-		Role:  req.Role,
+		Role:  "user",
 	}
 
 	// Hash password
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/platanus-hack-25/lumera_app/internal/services"
+)
+
+// GetContentCacheStats godoc
+// @Summary Shared content cache stats
+// @Description Hits, misses, hit rate, personalizations and bypasses of the shared component content cache per component type between two dates. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param from query string false "Start date YYYY-MM-DD (default: 30 days ago)"
+// @Param to query string false "End date YYYY-MM-DD, inclusive (default: today)"
+// @Success 200 {object} services.ContentCacheStats
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/admin/content-cache/stats [get]
+func GetContentCacheStats(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from := today.AddDate(0, 0, -30)
+	to := today
+
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", fromStr, now.Location())
+		if err != nil {
+			http.Error(w, `{"error":"invalid from date, expected YYYY-MM-DD"}`, http.StatusBadRequest)
+			return
+		}
+		from = parsed
+	}
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", toStr, now.Location())
+		if err != nil {
+			http.Error(w, `{"error":"invalid to date, expected YYYY-MM-DD"}`, http.StatusBadRequest)
+			return
+		}
+		to = parsed
+	}
+
+	// "to" is inclusive for callers
+	stats, err := services.GetContentCacheStats(from, to.AddDate(0, 0, 1))
+	if err != nil {
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// InvalidateContentCache godoc
+// @Summary Invalidate shared content cache
+// @Description Deletes cached component content, either all of it or only the entries of one OA-Bloom objective (e.g. after fixing its curriculum data). Admin only.
+// @Tags Admin
+// @Produce json
+// @Param oa_bloom_objective_id query int false "Only invalidate entries of this OA-Bloom objective"
+// @Success 200 {object} map[string]int64
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/admin/content-cache [delete]
+func InvalidateContentCache(w http.ResponseWriter, r *http.Request) {
+	var oaBloomObjectiveID uint64
+	if idStr := r.URL.Query().Get("oa_bloom_objective_id"); idStr != "" {
+		parsed, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			http.Error(w, `{"error":"invalid oa_bloom_objective_id"}`, http.StatusBadRequest)
+			return
+		}
+		oaBloomObjectiveID = parsed
+	}
+
+	deleted, err := services.InvalidateContentCache(uint(oaBloomObjectiveID))
+	if err != nil {
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("🗑 Content cache invalidated: %d entries (oa_bloom_objective_id=%d)", deleted, oaBloomObjectiveID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"eliminadas": deleted})
+}
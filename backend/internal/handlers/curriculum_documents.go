@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/platanus-hack-25/lumera_app/internal/middleware"
+	"github.com/platanus-hack-25/lumera_app/internal/services"
+)
+
+// maxCurriculumDocumentBytes limita el cuerpo de una subida (el contenido se valida además en caracteres)
+const maxCurriculumDocumentBytes = 4 << 20
+
+// CreateCurriculumDocument godoc
+// @Summary Upload a curriculum reference document
+// @Description Stores a Markdown, plain text or PDF-extracted text document for a materia (or one of its OAs), splits it into passages and indexes them. The closest passages are injected into plan, component and question generation prompts and cited in the generated content. Teachers and admins.
+// @Tags Curriculum
+// @Accept json
+// @Produce json
+// @Param request body services.CurriculumDocumentInput true "Document"
+// @Success 201 {object} models.CurriculumDocument
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/curriculum-documents [post]
+func CreateCurriculumDocument(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req services.CurriculumDocumentInput
+	r.Body = http.MaxBytesReader(w, r.Body, maxCurriculumDocumentBytes)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	document, err := services.CreateCurriculumDocument(r.Context(), userID, req)
+	if err != nil {
+		writeCurriculumError(w, "creating curriculum document", err)
+		return
+	}
+
+	document.Contenido = ""
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(document)
+}
+
+// ListCurriculumDocuments godoc
+// @Summary List curriculum reference documents
+// @Description Documents without their content, optionally filtered by materia and OA. Teachers and admins.
+// @Tags Curriculum
+// @Produce json
+// @Param materia_id query int false "Materia ID"
+// @Param oa_id query int false "OA ID"
+// @Success 200 {array} models.CurriculumDocument
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/curriculum-documents [get]
+func ListCurriculumDocuments(w http.ResponseWriter, r *http.Request) {
+	materiaID, oaID, ok := parseCurriculumFilter(w, r)
+	if !ok {
+		return
+	}
+
+	documents, err := services.ListCurriculumDocuments(materiaID, oaID)
+	if err != nil {
+		log.Printf("Error listing curriculum documents: %v", err)
+		http.Error(w, `{"error":"failed to list curriculum documents"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(documents)
+}
+
+// GetCurriculumDocument godoc
+// @Summary Get a curriculum reference document
+// @Description Document with its full content. Teachers and admins.
+// @Tags Curriculum
+// @Produce json
+// @Param id path int true "Document ID"
+// @Success 200 {object} models.CurriculumDocument
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/curriculum-documents/{id} [get]
+func GetCurriculumDocument(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid document id"}`, http.StatusBadRequest)
+		return
+	}
+
+	document, err := services.GetCurriculumDocument(uint(id))
+	if err != nil {
+		writeCurriculumError(w, "getting curriculum document", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(document)
+}
+
+// DeleteCurriculumDocument godoc
+// @Summary Delete a curriculum reference document
+// @Description Removes the document and its passages from the index. Citations already stored in generated content are kept. Teachers and admins.
+// @Tags Curriculum
+// @Param id path int true "Document ID"
+// @Success 204
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/curriculum-documents/{id} [delete]
+func DeleteCurriculumDocument(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid document id"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := services.DeleteCurriculumDocument(r.Context(), uint(id)); err != nil {
+		writeCurriculumError(w, "deleting curriculum document", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SearchCurriculumDocuments godoc
+// @Summary Preview curriculum passage retrieval
+// @Description Returns the passages closest to a query, as the generation prompts would receive them (without the minimum score filter). Teachers and admins.
+// @Tags Curriculum
+// @Produce json
+// @Param materia_id query int true "Materia ID"
+// @Param oa_id query int false "OA ID (also returns passages of documents for the whole materia)"
+// @Param q query string true "Query text"
+// @Param k query int false "Number of passages (default CURRICULUM_RAG_TOP_K, max 20)"
+// @Success 200 {array} services.CurriculumSearchResult
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/curriculum-documents/search [get]
+func SearchCurriculumDocuments(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	materiaID, oaID, ok := parseCurriculumFilter(w, r)
+	if !ok {
+		return
+	}
+	k, _ := strconv.Atoi(r.URL.Query().Get("k"))
+
+	results, err := services.SearchCurriculum(r.Context(), userID, materiaID, oaID, r.URL.Query().Get("q"), k)
+	if err != nil {
+		writeCurriculumError(w, "searching curriculum", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// ReindexCurriculumDocuments godoc
+// @Summary Re-embed curriculum documents
+// @Description Splits and embeds again the documents embedded with another provider (e.g. after changing EMBEDDINGS_PROVIDER), or every document with all=true. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param all query bool false "Reindex every document"
+// @Success 200 {object} services.CurriculumReindexResult
+// @Failure 403 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/admin/curriculum-documents/reindex [post]
+func ReindexCurriculumDocuments(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	result, err := services.ReindexCurriculumDocuments(r.Context(), adminID, r.URL.Query().Get("all") == "true")
+	if err != nil {
+		writeCurriculumError(w, "reindexing curriculum documents", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// parseCurriculumFilter lee materia_id y oa_id (opcionales) de la query
+func parseCurriculumFilter(w http.ResponseWriter, r *http.Request) (uint, *uint, bool) {
+	var materiaID uint
+	if value := r.URL.Query().Get("materia_id"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			http.Error(w, `{"error":"invalid materia_id"}`, http.StatusBadRequest)
+			return 0, nil, false
+		}
+		materiaID = uint(parsed)
+	}
+
+	var oaID *uint
+	if value := r.URL.Query().Get("oa_id"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			http.Error(w, `{"error":"invalid oa_id"}`, http.StatusBadRequest)
+			return 0, nil, false
+		}
+		id := uint(parsed)
+		oaID = &id
+	}
+	return materiaID, oaID, true
+}
+
+func writeCurriculumError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case errors.Is(err, services.ErrCurriculumDocumentNotFound):
+		http.Error(w, `{"error":"curriculum document not found"}`, http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidCurriculumDocument):
+		errorJSON, _ := json.Marshal(map[string]string{"error": err.Error()})
+		http.Error(w, string(errorJSON), http.StatusBadRequest)
+	case errors.Is(err, services.ErrCurriculumRAGNotInitialized):
+		http.Error(w, `{"error":"curriculum search not available"}`, http.StatusServiceUnavailable)
+	default:
+		log.Printf("Error %s: %v", action, err)
+		http.Error(w, `{"error":"curriculum request failed"}`, http.StatusInternalServerError)
+	}
+}
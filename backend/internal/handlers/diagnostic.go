@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"time"
 
@@ -25,7 +26,7 @@ type StartDiagnosticRequest struct {
 // @Tags Diagnostic
 // @Produce json
 // @Param materia_id query int false "Filter by materia ID"
-// @Param estado query string false "Filter by estado (en_progreso, completado)"
+// @Param estado query string false "Filter by estado (en_progreso, pausado, abandonado, expirado, completado)"
 // @Success 200 {array} models.DiagnosticSession
 // @Failure 500 {string} string "Server error"
 // @Security BearerAuth
@@ -89,7 +90,9 @@ func GetDiagnosticSessions(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param diagnostic body StartDiagnosticRequest true "Diagnostic session data"
 // @Success 201 {object} models.DiagnosticSession
+// @Success 200 {object} models.DiagnosticSession "Open session of the same materia, resumed (SESSION_CONCURRENCY_POLICY=reanudar)"
 // @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "Open session limit reached (SESSION_CONCURRENCY_POLICY=rechazar)"
 // @Security BearerAuth
 // @Router /api/diagnostic-sessions [post]
 func StartDiagnostic(w http.ResponseWriter, r *http.Request) {
@@ -105,6 +108,23 @@ func StartDiagnostic(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Concurrent-session policy: may resume the open diagnostic of this materia or abandon older ones
+	resumedID, err := services.ReserveDiagnosticSlot(userID, req.MateriaID)
+	if err != nil {
+		writeSessionStateError(w, userID, err)
+		return
+	}
+	if resumedID != nil {
+		var resumed models.DiagnosticSession
+		if err := db.DB.First(&resumed, *resumedID).Error; err != nil {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resumed)
+		return
+	}
+
 	// Count previous attempts
 	var count int64
 	db.DB.Model(&models.DiagnosticSession{}).
@@ -128,6 +148,7 @@ func StartDiagnostic(w http.ResponseWriter, r *http.Request) {
 		Estado:        "en_progreso",
 		Estrategia:    datatypes.JSON(strategyJSON),
 		StartedAt:     time.Now(),
+		UltimaActividadAt: time.Now(),
 	}
 
 	if err := db.DB.Create(&session).Error; err != nil {
@@ -182,6 +203,11 @@ func GetNextQuestion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := services.CheckSessionActive(session.Estado); err != nil {
+		writeSessionStateError(w, session.UserID, err)
+		return
+	}
+
 	// Parse current strategy
 	var strategy models.AdaptiveStrategy
 	if err := json.Unmarshal(session.Estrategia, &strategy); err != nil {
@@ -189,8 +215,8 @@ func GetNextQuestion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// If first question, initialize strategy
-	if session.PreguntasTotales == 0 {
+	// If first question, initialize strategy (placement sessions get their OAs when the placement starts)
+	if session.PreguntasTotales == 0 && session.PlacementDiagnosticID == nil {
 		// Get all OAs for this materia
 		var oaBloomObjectives []models.OABloomObjective
 		err := db.DB.Joins("JOIN objetivos_aprendizaje ON oa_bloom_objectives.oa_id = objetivos_aprendizaje.id").
@@ -242,6 +268,13 @@ func GetNextQuestion(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// A question served and not answered yet (closed tab, resume) is served again
+	var pending models.Question
+	if session.PreguntaPendienteID != nil && db.DB.First(&pending, *session.PreguntaPendienteID).Error == nil {
+		writeDiagnosticQuestion(w, session, strategy, pending, len(oasToEvaluate))
+		return
+	}
+
 	// Check if we have OAs left to evaluate
 	if len(strategy.OAsEvaluados) >= len(oasToEvaluate) {
 		http.Error(w, "No more questions available", http.StatusNotFound)
@@ -269,14 +302,47 @@ func GetNextQuestion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	question, err := pickDiagnosticQuestion(&session, nextOAID)
+	if err != nil {
+		http.Error(w, "No questions available for current criteria", http.StatusNotFound)
+		return
+	}
+
+	writeDiagnosticQuestion(w, session, strategy, *question, len(oasToEvaluate))
+}
+
+// writeDiagnosticQuestion writes a diagnostic question without validation_data
+func writeDiagnosticQuestion(w http.ResponseWriter, session models.DiagnosticSession, strategy models.AdaptiveStrategy, question models.Question, totalQuestions int) {
+	response := map[string]interface{}{
+		"id":                    question.ID,
+		"oa_bloom_objective_id": question.OABloomObjectiveID,
+		"tipo":                  question.Tipo,
+		"question_data":         question.QuestionData,
+		"question_number":       session.PreguntasTotales + 1,
+		"total_questions":       totalQuestions,
+		"current_bloom_level":   strategy.NivelBloomActual,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// pickDiagnosticQuestion picks a random question of the OA at the session's current Bloom level
+// (or the closest one) and marks the OA as evaluated in the session strategy
+func pickDiagnosticQuestion(session *models.DiagnosticSession, oaID uint) (*models.Question, error) {
+	var strategy models.AdaptiveStrategy
+	if err := json.Unmarshal(session.Estrategia, &strategy); err != nil {
+		return nil, err
+	}
+
 	// Find OABloomObjective for this OA at current Bloom level
 	var oaBloomObjective models.OABloomObjective
-	err := db.DB.Where("oa_id = ? AND bloom_level_id = ?", nextOAID, strategy.NivelBloomActual).
+	err := db.DB.Where("oa_id = ? AND bloom_level_id = ?", oaID, strategy.NivelBloomActual).
 		First(&oaBloomObjective).Error
 
 	if err != nil {
 		// Bloom level not found, try closest one
-		db.DB.Where("oa_id = ?", nextOAID).
+		db.DB.Where("oa_id = ?", oaID).
 			Order(fmt.Sprintf("ABS(bloom_level_id - %d)", strategy.NivelBloomActual)).
 			First(&oaBloomObjective)
 	}
@@ -288,32 +354,29 @@ func GetNextQuestion(w http.ResponseWriter, r *http.Request) {
 		oaBloomObjective.ID, true, "diagnostico", "all", []string{"open_ended", "concept_map"}).
 		Order("RANDOM()").
 		First(&question).Error
-
 	if err != nil {
-		http.Error(w, "No questions available for current criteria", http.StatusNotFound)
-		return
+		return nil, err
 	}
 
-	// Mark OA as evaluated
-	strategy.OAsEvaluados = append(strategy.OAsEvaluados, nextOAID)
-	strategyMap["oas_evaluados"] = strategy.OAsEvaluados
-	updatedStrategyJSON, _ := json.Marshal(strategyMap)
-	session.Estrategia = datatypes.JSON(updatedStrategyJSON)
-	db.DB.Save(&session)
+	// Kept as pending until answered, so a resumed session gets the same question
+	now := time.Now()
+	session.PreguntaPendienteID = &question.ID
+	session.UltimaActividadAt = now
+	markDiagnosticOAEvaluated(session, oaID)
+	return &question, nil
+}
 
-	// Prepare response without validation_data
-	response := map[string]interface{}{
-		"id":                  question.ID,
-		"oa_bloom_objective_id": question.OABloomObjectiveID,
-		"tipo":                question.Tipo,
-		"question_data":       question.QuestionData,
-		"question_number":     session.PreguntasTotales + 1,
-		"total_questions":     len(oasToEvaluate),
-		"current_bloom_level": strategy.NivelBloomActual,
-	}
+// markDiagnosticOAEvaluated adds the OA to oas_evaluados and saves the session
+func markDiagnosticOAEvaluated(session *models.DiagnosticSession, oaID uint) {
+	var strategyMap map[string]interface{}
+	json.Unmarshal(session.Estrategia, &strategyMap)
+	var strategy models.AdaptiveStrategy
+	json.Unmarshal(session.Estrategia, &strategy)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	strategyMap["oas_evaluados"] = append(strategy.OAsEvaluados, oaID)
+	updatedStrategyJSON, _ := json.Marshal(strategyMap)
+	session.Estrategia = datatypes.JSON(updatedStrategyJSON)
+	db.DB.Save(session)
 }
 
 // SubmitAnswerRequest represents the request to submit an answer
@@ -344,6 +407,11 @@ func SubmitAnswer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := services.CheckSessionActive(session.Estado); err != nil {
+		writeSessionStateError(w, session.UserID, err)
+		return
+	}
+
 	var req SubmitAnswerRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -364,6 +432,9 @@ func SubmitAnswer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Answers faster than the question's normative threshold are rapid guesses
+	rapidGuess := services.IsRapidGuess(question, req.TiempoSegundos)
+
 	// Save answer
 	answer := models.DiagnosticAnswer{
 		SessionID:          session.ID,
@@ -374,6 +445,7 @@ func SubmitAnswer(w http.ResponseWriter, r *http.Request) {
 		IsCorrect:          &isCorrect,
 		Score:              &score,
 		TiempoSegundos:     req.TiempoSegundos,
+		AdivinanzaRapida:   rapidGuess,
 	}
 
 	if err := db.DB.Create(&answer).Error; err != nil {
@@ -381,11 +453,35 @@ func SubmitAnswer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Update the mastery posterior of the question's objective (answers pending manual grading are not traced)
+	var trace *services.KnowledgeTrace
+	if err == nil {
+		trace, err = services.TraceAnswer(session.UserID, question.OABloomObjectiveID, isCorrect, services.AnswerWeight(rapidGuess))
+		if err != nil {
+			log.Printf("Error tracing diagnostic answer of user %d: %v", session.UserID, err)
+		}
+	}
+
+	// Misconceptions behind the chosen distractor feed the report (a rapid guess says nothing about what the student believes)
+	if !rapidGuess {
+		if _, err := services.ObserveMisconceptions(session.UserID, models.MisconceptionOrigenDiagnostico, session.ID, &question, req.UserAnswer, isCorrect); err != nil {
+			log.Printf("Error recording misconceptions of user %d: %v", session.UserID, err)
+		}
+	}
+
 	// Update session stats
 	session.PreguntasTotales++
 	if isCorrect {
 		session.PreguntasCorrectas++
 	}
+	if rapidGuess {
+		session.AdivinanzasRapidas++
+	}
+	session.Compromiso = services.SessionCompromiso(session.PreguntasTotales, session.AdivinanzasRapidas)
+	session.UltimaActividadAt = time.Now()
+	if session.PreguntaPendienteID != nil && *session.PreguntaPendienteID == req.QuestionID {
+		session.PreguntaPendienteID = nil
+	}
 
 	// Update adaptive strategy
 	var strategyMap map[string]interface{}
@@ -393,7 +489,10 @@ func SubmitAnswer(w http.ResponseWriter, r *http.Request) {
 
 	currentBloomLevel, _ := strategyMap["nivel_bloom_actual"].(float64)
 
-	if isCorrect {
+	if rapidGuess {
+		// A rapid guess says nothing about ability: keep the current level
+		strategyMap["nivel_bloom_actual"] = int(currentBloomLevel)
+	} else if isCorrect {
 		// Increase Bloom level (max 6)
 		newLevel := int(currentBloomLevel) + 1
 		if newLevel > 6 {
@@ -421,6 +520,13 @@ func SubmitAnswer(w http.ResponseWriter, r *http.Request) {
 		"answer_id":  answer.ID,
 		"new_bloom_level": strategyMap["nivel_bloom_actual"],
 	}
+	if trace != nil {
+		response["dominio"] = trace
+	}
+	response["adivinanza_rapida"] = rapidGuess
+	if engagement, err := services.DiagnosticEngagement(session); err == nil {
+		response["compromiso"] = engagement
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -428,7 +534,7 @@ func SubmitAnswer(w http.ResponseWriter, r *http.Request) {
 
 // CompleteDiagnostic godoc
 // @Summary Complete a diagnostic session
-// @Description Mark session as completed and generate results
+// @Description Mark session as completed and generate results. conceptos_erroneos lists the misconceptions revealed by the distractors the student chose ("Probablemente confundes X con Y."), most frequent first.
 // @Tags Diagnostic
 // @Produce json
 // @Param id path int true "Session ID"
@@ -445,13 +551,85 @@ func CompleteDiagnostic(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Paused sessions can be completed; abandoned, expired and completed ones cannot
+	if session.Estado != "pausado" {
+		if err := services.CheckSessionActive(session.Estado); err != nil {
+			writeSessionStateError(w, session.UserID, err)
+			return
+		}
+	}
+
+	answers, averageBloomLevel, err := completeDiagnosticSession(&session)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Correct rapid guesses count only a fraction toward the performance bonus
+	weightedCorrect := 0.0
+	for _, answer := range answers {
+		if answer.IsCorrect != nil && *answer.IsCorrect {
+			weightedCorrect += services.AnswerWeight(answer.AdivinanzaRapida)
+		}
+	}
+
+	// Gamification events (async)
+	go func() {
+		// Award XP for completing diagnostic
+		gamificationService.AddXP(session.UserID, 100, "diagnostic_completed")
+
+		// Bonus coins for good performance
+		if session.PreguntasTotales > 0 {
+			scorePercent := int(weightedCorrect*100) / session.PreguntasTotales
+			if scorePercent >= 80 {
+				gamificationService.AddCoins(session.UserID, 50, "diagnostic_excellent")
+			} else if scorePercent >= 60 {
+				gamificationService.AddCoins(session.UserID, 25, "diagnostic_good")
+			}
+
+			// Check for diagnostic achievement unlocks
+			unlockService.CheckAndUnlock(session.UserID, services.UnlockEvent{
+				Type: "diagnostic_achievement",
+				Key:  fmt.Sprintf("diagnostic_materia_%d_score_%d", session.MateriaID, scorePercent),
+				Data: map[string]interface{}{
+					"materia_id": session.MateriaID,
+					"score":      scorePercent,
+				},
+			})
+		}
+	}()
+
+	response := map[string]interface{}{
+		"message":              "Diagnostic completed successfully",
+		"session":              session,
+		"average_bloom_level":  averageBloomLevel,
+	}
+	if engagement, err := services.DiagnosticEngagement(session); err == nil {
+		response["compromiso"] = engagement
+	}
+	if misconceptions, err := services.SessionMisconceptions(models.MisconceptionOrigenDiagnostico, session.ID); err != nil {
+		log.Printf("Error loading misconceptions of diagnostic session %d: %v", session.ID, err)
+	} else if len(misconceptions) > 0 {
+		response["conceptos_erroneos"] = misconceptions
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// completeDiagnosticSession marks the session as completed, records progress of the answered objectives
+// and creates its diagnostic results. Returns the answers and the average Bloom level of correct answers.
+func completeDiagnosticSession(session *models.DiagnosticSession) ([]models.DiagnosticAnswer, int, error) {
 	now := time.Now()
 	session.Estado = "completado"
 	session.CompletedAt = &now
+	session.UltimaActividadAt = now
+	session.PausadoAt = nil
+	session.PreguntaPendienteID = nil
+	session.Compromiso = services.SessionCompromiso(session.PreguntasTotales, session.AdivinanzasRapidas)
 
-	if err := db.DB.Save(&session).Error; err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	if err := db.DB.Save(session).Error; err != nil {
+		return nil, 0, err
 	}
 
 	// Generate diagnostic_results based on answers
@@ -469,6 +647,23 @@ func CompleteDiagnostic(w http.ResponseWriter, r *http.Request) {
 		oaAnswers[oaID] = append(oaAnswers[oaID], answer)
 	}
 
+	// Record the session in the progress of every answered objective (estado comes from knowledge tracing)
+	objectiveTotals := make(map[uint][2]int)
+	for _, answer := range answers {
+		totals := objectiveTotals[answer.OABloomObjectiveID]
+		if answer.IsCorrect != nil && *answer.IsCorrect {
+			totals[0]++
+		}
+		totals[1]++
+		objectiveTotals[answer.OABloomObjectiveID] = totals
+	}
+	for objectiveID, totals := range objectiveTotals {
+		notas := fmt.Sprintf("Diagnóstico - %d/%d correctas", totals[0], totals[1])
+		if err := services.RecordTracedProgress(session.UserID, objectiveID, "diagnostico", totals[0], totals[1], notas); err != nil {
+			log.Printf("Error recording diagnostic progress of user %d: %v", session.UserID, err)
+		}
+	}
+
 	// Create diagnostic results for each OA
 	var bloomLevels []uint
 	for oaID, oaAnswerList := range oaAnswers {
@@ -531,38 +726,7 @@ func CompleteDiagnostic(w http.ResponseWriter, r *http.Request) {
 		averageBloomLevel = int(sum) / len(bloomLevels)
 	}
 
-	// Gamification events (async)
-	go func() {
-		// Award XP for completing diagnostic
-		gamificationService.AddXP(session.UserID, 100, "diagnostic_completed")
-
-		// Bonus coins for good performance
-		if session.PreguntasTotales > 0 {
-			scorePercent := (session.PreguntasCorrectas * 100) / session.PreguntasTotales
-			if scorePercent >= 80 {
-				gamificationService.AddCoins(session.UserID, 50, "diagnostic_excellent")
-			} else if scorePercent >= 60 {
-				gamificationService.AddCoins(session.UserID, 25, "diagnostic_good")
-			}
-
-			// Check for diagnostic achievement unlocks
-			unlockService.CheckAndUnlock(session.UserID, services.UnlockEvent{
-				Type: "diagnostic_achievement",
-				Key:  fmt.Sprintf("diagnostic_materia_%d_score_%d", session.MateriaID, scorePercent),
-				Data: map[string]interface{}{
-					"materia_id": session.MateriaID,
-					"score":      scorePercent,
-				},
-			})
-		}
-	}()
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message":              "Diagnostic completed successfully",
-		"session":              session,
-		"average_bloom_level":  averageBloomLevel,
-	})
+	return answers, averageBloomLevel, nil
 }
 
 // GetDiagnosticResults godoc
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	authmiddleware "github.com/platanus-hack-25/lumera_app/internal/middleware"
+	"github.com/platanus-hack-25/lumera_app/internal/services"
+)
+
+// GetDiagnosticReport godoc
+// @Summary Download a diagnostic report
+// @Description Report of a completed diagnostic session for students, parents and teachers: Bloom-level bar per OA, strengths (80% or more correct) and weaknesses (under 60%), comparison with the previous attempts (numero_intento) of the same materia and next-step recommendations with the OA-Bloom objective to practice. format=pdf (default) and format=html (printable) download a file; format=json returns the report data. Teachers and admins can read any session; students only their own.
+// @Tags Diagnostic
+// @Produce json
+// @Produce application/pdf
+// @Produce text/html
+// @Param id path int true "Session ID"
+// @Param format query string false "pdf, html or json" default(pdf)
+// @Success 200 {object} services.DiagnosticReport
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/diagnostic-sessions/{id}/report [get]
+func GetDiagnosticReport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authmiddleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	sessionID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid session id"}`, http.StatusBadRequest)
+		return
+	}
+
+	role, _ := authmiddleware.GetRoleFromContext(r.Context())
+	anyOwner := role == "admin" || role == "docente"
+
+	report, err := services.BuildDiagnosticReport(userID, anyOwner, uint(sessionID))
+	if err != nil {
+		writeDiagnosticReportError(w, userID, err)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == services.ReportFormatJSON {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	file, err := services.RenderDiagnosticReport(report, format)
+	if err != nil {
+		writeDiagnosticReportError(w, userID, err)
+		return
+	}
+	writeDiagnosticReportFile(w, file)
+}
+
+// ExportClassroomDiagnosticReports godoc
+// @Summary Download the diagnostic reports of a classroom
+// @Description Zip with the report of the latest completed diagnostic of every student of a curso (students are matched by the curso_actual of their profile), one file per student and materia. Teachers and admins only.
+// @Tags Diagnostic
+// @Produce application/zip
+// @Param curso_id query int true "Curso ID"
+// @Param materia_id query int false "Only diagnostics of this subject"
+// @Param format query string false "pdf or html" default(pdf)
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/diagnostic-reports [get]
+func ExportClassroomDiagnosticReports(w http.ResponseWriter, r *http.Request) {
+	userID, _ := authmiddleware.GetUserIDFromContext(r.Context())
+
+	cursoID, err := strconv.ParseUint(r.URL.Query().Get("curso_id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"curso_id is required"}`, http.StatusBadRequest)
+		return
+	}
+	var materiaID *uint
+	if value := r.URL.Query().Get("materia_id"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			http.Error(w, `{"error":"invalid materia_id"}`, http.StatusBadRequest)
+			return
+		}
+		id := uint(parsed)
+		materiaID = &id
+	}
+
+	file, err := services.ExportClassroomDiagnosticReports(uint(cursoID), materiaID, r.URL.Query().Get("format"))
+	if err != nil {
+		writeDiagnosticReportError(w, userID, err)
+		return
+	}
+	writeDiagnosticReportFile(w, file)
+}
+
+func writeDiagnosticReportFile(w http.ResponseWriter, file *services.DiagnosticReportFile) {
+	w.Header().Set("Content-Type", file.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, file.Filename))
+	w.Header().Set("Content-Length", strconv.Itoa(len(file.Data)))
+	w.Write(file.Data)
+}
+
+func writeDiagnosticReportError(w http.ResponseWriter, userID uint, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidExportFormat):
+		errorJSON, _ := json.Marshal(map[string]string{"error": err.Error()})
+		http.Error(w, string(errorJSON), http.StatusBadRequest)
+	case errors.Is(err, services.ErrDiagnosticReportNotFound):
+		http.Error(w, `{"error":"session not found"}`, http.StatusNotFound)
+	case errors.Is(err, services.ErrClassroomNotFound):
+		http.Error(w, `{"error":"curso not found"}`, http.StatusNotFound)
+	case errors.Is(err, services.ErrDiagnosticReportPending):
+		http.Error(w, `{"error":"diagnostic session is not completed yet"}`, http.StatusConflict)
+	default:
+		log.Printf("Error handling diagnostic report for user %d: %v", userID, err)
+		http.Error(w, `{"error":"failed to generate report"}`, http.StatusInternalServerError)
+	}
+}
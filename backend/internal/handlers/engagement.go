@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/platanus-hack-25/lumera_app/internal/services"
+)
+
+// GetEngagementReport godoc
+// @Summary Student engagement report
+// @Description Per-student engagement in completed practice and diagnostic sessions between two dates: answers, rapid guesses (answers faster than the question's normative time threshold), mean response time effort and sessions under the low-engagement threshold. Lowest engagement first. Teachers and admins only.
+// @Tags Engagement
+// @Produce json
+// @Param from query string false "Start date YYYY-MM-DD (default: 30 days ago)"
+// @Param to query string false "End date YYYY-MM-DD, inclusive (default: today)"
+// @Param materia_id query int false "Only sessions of this subject"
+// @Success 200 {array} services.EngagementStudentRow
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/engagement/students [get]
+func GetEngagementReport(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := parseEngagementRange(w, r)
+	if !ok {
+		return
+	}
+	var materiaID uint
+	if value := r.URL.Query().Get("materia_id"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			http.Error(w, `{"error":"invalid materia_id"}`, http.StatusBadRequest)
+			return
+		}
+		materiaID = uint(parsed)
+	}
+
+	// "to" is inclusive for callers
+	rows, err := services.GetEngagementReport(from, to.AddDate(0, 0, 1), materiaID)
+	if err != nil {
+		log.Printf("Error building engagement report: %v", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rows)
+}
+
+// GetStudentEngagementSessions godoc
+// @Summary Engagement of a student's sessions
+// @Description Completed practice and diagnostic sessions of a student between two dates with their rapid guesses and response time effort, newest first. Teachers and admins only.
+// @Tags Engagement
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Param from query string false "Start date YYYY-MM-DD (default: 30 days ago)"
+// @Param to query string false "End date YYYY-MM-DD, inclusive (default: today)"
+// @Success 200 {array} services.EngagementSessionRow
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/engagement/students/{user_id}/sessions [get]
+func GetStudentEngagementSessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseUint(chi.URLParam(r, "user_id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid user id"}`, http.StatusBadRequest)
+		return
+	}
+	from, to, ok := parseEngagementRange(w, r)
+	if !ok {
+		return
+	}
+
+	// "to" is inclusive for callers
+	rows, err := services.GetStudentEngagementSessions(uint(userID), from, to.AddDate(0, 0, 1))
+	if err != nil {
+		log.Printf("Error loading engagement sessions of user %d: %v", userID, err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rows)
+}
+
+// parseEngagementRange reads from/to (YYYY-MM-DD), defaulting to the last 30 days
+func parseEngagementRange(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from := today.AddDate(0, 0, -30)
+	to := today
+
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", fromStr, now.Location())
+		if err != nil {
+			http.Error(w, `{"error":"invalid from date, expected YYYY-MM-DD"}`, http.StatusBadRequest)
+			return from, to, false
+		}
+		from = parsed
+	}
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", toStr, now.Location())
+		if err != nil {
+			http.Error(w, `{"error":"invalid to date, expected YYYY-MM-DD"}`, http.StatusBadRequest)
+			return from, to, false
+		}
+		to = parsed
+	}
+	return from, to, true
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/platanus-hack-25/lumera_app/internal/middleware"
+	"github.com/platanus-hack-25/lumera_app/internal/services"
+	"github.com/platanus-hack-25/lumera_app/pkg/llm"
+)
+
+// GetPracticeHint godoc
+// @Summary Reveal the next hint of the pending question
+// @Description Hints go from most general to most specific. They come from validation_data.pistas or, for questions without authored hints, are generated once and cached. Each hint revealed lowers the score, XP and mastery evidence of a correct answer to the question by HINT_SCORE_PENALTY_PERCENT, down to HINT_MIN_SCORE_PERCENT (puntaje_maximo).
+// @Tags Practice
+// @Produce json
+// @Param id path int true "Session ID"
+// @Success 200 {object} services.PracticeHint
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/practice-sessions/{id}/hint [get]
+func GetPracticeHint(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	sessionID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid session id"}`, http.StatusBadRequest)
+		return
+	}
+
+	hint, err := services.NextPracticeHint(llm.WithUser(r.Context(), userID), userID, uint(sessionID))
+	if err != nil {
+		writeHintError(w, userID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hint)
+}
+
+func writeHintError(w http.ResponseWriter, userID uint, err error) {
+	switch {
+	case errors.Is(err, services.ErrNoPendingQuestion):
+		http.Error(w, `{"error":"no pending question; request the next question first"}`, http.StatusConflict)
+	case errors.Is(err, services.ErrNoMoreHints):
+		http.Error(w, `{"error":"no more hints for this question"}`, http.StatusNotFound)
+	case errors.Is(err, services.ErrHintsUnavailable):
+		http.Error(w, `{"error":"hints are not available for this question"}`, http.StatusServiceUnavailable)
+	case errors.Is(err, services.ErrLLMBudgetExceeded):
+		http.Error(w, `{"error":"daily AI budget exceeded"}`, http.StatusTooManyRequests)
+	case errors.Is(err, services.ErrSessionNotFound), errors.Is(err, services.ErrSessionPaused), errors.Is(err, services.ErrSessionClosed):
+		writeSessionStateError(w, userID, err)
+	default:
+		log.Printf("Error revealing hint for user %d: %v", userID, err)
+		http.Error(w, `{"error":"failed to get hint"}`, http.StatusInternalServerError)
+	}
+}
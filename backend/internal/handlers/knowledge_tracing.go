@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/platanus-hack-25/lumera_app/internal/services"
+)
+
+// GetStudentKnowledge godoc
+// @Summary Get student mastery estimates
+// @Description Knowledge tracing posterior P(mastery) of a student on every OA-Bloom objective with graded diagnostic or practice answers, and the progress estado it maps to
+// @Tags Progress
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Success 200 {array} services.KnowledgeTrace
+// @Failure 400 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/progress/{user_id}/knowledge [get]
+func GetStudentKnowledge(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseUint(chi.URLParam(r, "user_id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid user id"}`, http.StatusBadRequest)
+		return
+	}
+
+	traces, err := services.GetKnowledgeTraces(uint(userID))
+	if err != nil {
+		log.Printf("Error loading knowledge traces of user %d: %v", userID, err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(traces)
+}
+
+// ListKnowledgeTracingParams godoc
+// @Summary Knowledge tracing parameters
+// @Description Fitted BKT parameters (initial mastery, learn, guess and slip probabilities): the global row first, then one per OA-Bloom objective with enough answers. Admin only.
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} models.KnowledgeTracingParams
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/admin/knowledge-tracing/params [get]
+func ListKnowledgeTracingParams(w http.ResponseWriter, r *http.Request) {
+	params, err := services.ListKnowledgeTracingParams()
+	if err != nil {
+		log.Printf("Error listing knowledge tracing params: %v", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(params)
+}
+
+// FitKnowledgeTracing godoc
+// @Summary Fit knowledge tracing parameters
+// @Description Fits BKT parameters by maximum likelihood on every graded diagnostic and practice answer, then replays each student's history with them to recompute mastery and progress estado. Admin only.
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} services.KnowledgeTracingFitReport
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/admin/knowledge-tracing/fit [post]
+func FitKnowledgeTracing(w http.ResponseWriter, r *http.Request) {
+	report, err := services.FitKnowledgeTracing()
+	if err != nil {
+		log.Printf("Error fitting knowledge tracing: %v", err)
+		http.Error(w, `{"error":"failed to fit knowledge tracing"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -1,11 +1,12 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
-	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -13,16 +14,19 @@ import (
 	"github.com/platanus-hack-25/lumera_app/internal/middleware"
 	"github.com/platanus-hack-25/lumera_app/internal/models"
 	"github.com/platanus-hack-25/lumera_app/internal/services"
-	"gorm.io/datatypes"
+	"github.com/platanus-hack-25/lumera_app/pkg/llm"
 	"gorm.io/gorm"
 )
 
+var generationJobService = services.NewGenerationJobService()
+
 // GenerateLearningPlanRequest es el payload para generar un plan
 type GenerateLearningPlanRequest struct {
 	OABloomObjectiveID uint `json:"oa_bloom_objective_id"`
 }
 
-// GenerateLearningPlanHandler genera un nuevo plan de aprendizaje para el usuario
+// GenerateLearningPlanHandler encola la generación de un nuevo plan de aprendizaje para el usuario.
+// Si el plan ya existe lo devuelve (200; para cambiarlo está POST /{id}/regenerate); si no, responde 202 con el job que lo genera.
 // POST /api/learning-plans/generate
 func GenerateLearningPlanHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r.Context())
@@ -39,11 +43,11 @@ func GenerateLearningPlanHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Verificar si ya existe un plan para este usuario y OA
 	var existingPlan models.LearningPlan
-	err := db.DB.Preload("Components").
+	err := db.DB.Preload("Components").Preload("Checkpoints").
 		Where("user_id = ? AND oa_bloom_objective_id = ?", userID, req.OABloomObjectiveID).
 		First(&existingPlan).Error
 
-	if err == nil {
+	if err == nil && existingPlan.Estado != models.LearningPlanEstadoGenerando {
 		// Plan already exists, return it
 		log.Printf("✓ Learning plan already exists for user %d and OA %d", userID, req.OABloomObjectiveID)
 		w.Header().Set("Content-Type", "application/json")
@@ -51,165 +55,175 @@ func GenerateLearningPlanHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Obtener datos del OA para el contexto
+	// Verificar presupuesto diario de LLM antes de generar
+	if !checkLLMBudget(w, userID) {
+		return
+	}
+
+	// Verificar que el objetivo exista antes de encolar
 	var oaBloomObjective models.OABloomObjective
-	if err := db.DB.Preload("OA").Preload("OA.Materia").Preload("BloomLevel").
-		First(&oaBloomObjective, req.OABloomObjectiveID).Error; err != nil {
-		log.Printf("Error loading OA: %v", err)
+	if err := db.DB.Select("id").First(&oaBloomObjective, req.OABloomObjectiveID).Error; err != nil {
 		http.Error(w, `{"error":"objective not found"}`, http.StatusNotFound)
 		return
 	}
 
-	// Fetch student profile for personalization
-	var profile models.StudentProfile
-	var interesesPersonales []string
-	var profesionSoñada string
-	var formatoPreferido string
-	var tipoActividad []string
-	var canalPreferido string
-
-	if err := db.DB.Where("user_id = ?", userID).First(&profile).Error; err == nil {
-		// Parse profile_data JSONB field
-		var profileData models.ProfileDataStructure
-		if err := profile.ProfileData.Scan(&profileData); err == nil {
-			interesesPersonales = profileData.InteresesPersonales.Temas
-			profesionSoñada = profileData.InteresesPersonales.ProfesionSoñada
-			formatoPreferido = profileData.PreferenciasAprendizaje.FormatoPreferido
-			tipoActividad = profileData.PreferenciasAprendizaje.TipoActividad
-			canalPreferido = profileData.PreferenciasAprendizaje.CanalPreferido
-		}
+	job, created, err := generationJobService.EnqueueLearningPlan(userID, req.OABloomObjectiveID)
+	if err != nil {
+		log.Printf("Error enqueuing learning plan: %v", err)
+		http.Error(w, `{"error":"failed to enqueue learning plan"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if created {
+		log.Printf("📥 Learning plan job %d queued for user %d and OA %d", job.ID, userID, req.OABloomObjectiveID)
 	}
 
-	// Construir contexto para OpenAI
-	oaContext := services.OAContext{
-		MateriaNombre:      oaBloomObjective.OA.Materia.Nombre,
-		MateriaDescripcion: oaBloomObjective.OA.Materia.Descripcion,
-		CursoNombre:        oaBloomObjective.OA.Materia.Nombre, // TODO: Get actual curso
-		OATitulo:           oaBloomObjective.OA.Titulo,
-		OADescripcion:      oaBloomObjective.OA.Descripcion,
-		BloomLevelNombre:   oaBloomObjective.BloomLevel.Nombre,
-		BloomLevelNumero:   oaBloomObjective.BloomLevel.Nivel,
-		BloomDescripcion:   oaBloomObjective.BloomLevel.Descripcion,
-		ObjetivoEspecifico: oaBloomObjective.ObjetivoEspecifico,
-		IndicadoresLogro:   oaBloomObjective.IndicadoresLogro,
-		// Student personalization
-		InteresesPersonales: interesesPersonales,
-		ProfesionSoñada:     profesionSoñada,
-		FormatoPreferido:    formatoPreferido,
-		TipoActividad:       tipoActividad,
-		CanalPreferido:      canalPreferido,
-	}
-
-	// Generar estructura del plan con OpenAI
-	planStructure, err := services.GenerateLearningPlanStructure(oaContext)
+	writeGenerationJobAccepted(w, job)
+}
+
+// writeGenerationJobAccepted responde 202 con el job y las URLs para seguir su progreso
+func writeGenerationJobAccepted(w http.ResponseWriter, job *models.GenerationJob) {
+	statusURL := fmt.Sprintf("/api/learning-plans/jobs/%d", job.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", statusURL)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"job_id":           job.ID,
+		"estado":           job.Estado,
+		"learning_plan_id": job.LearningPlanID,
+		"status_url":       statusURL,
+		"events_url":       statusURL + "/events",
+	})
+}
+
+// GetGenerationJobHandler devuelve el estado de un job de generación y de sus componentes
+// GET /api/learning-plans/jobs/{job_id}
+func GetGenerationJobHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	jobID, err := strconv.ParseUint(chi.URLParam(r, "job_id"), 10, 32)
 	if err != nil {
-		log.Printf("Error generating plan structure: %v", err)
-		http.Error(w, `{"error":"failed to generate learning plan"}`, http.StatusInternalServerError)
+		http.Error(w, `{"error":"invalid job ID"}`, http.StatusBadRequest)
 		return
 	}
 
-	// Calcular tiempo total estimado
-	totalTime := 0
-	for _, comp := range planStructure.Componentes {
-		totalTime += comp.TiempoEstimadoMin
+	progress, err := generationJobService.GetJobProgress(uint(jobID), userID)
+	if err != nil {
+		http.Error(w, `{"error":"job not found"}`, http.StatusNotFound)
+		return
 	}
 
-	// Crear el plan en la base de datos
-	plan := models.LearningPlan{
-		UserID:             userID,
-		OABloomObjectiveID: req.OABloomObjectiveID,
-		Titulo:             planStructure.Titulo,
-		Descripcion:        planStructure.Descripcion,
-		TiempoEstimadoMin:  totalTime,
-		Estado:             models.LearningPlanEstadoGenerando,
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(progress)
+}
+
+// generationEventsMaxDuration cierra el stream antes del timeout global del router;
+// el cliente reconecta y recibe de nuevo el estado completo
+const generationEventsMaxDuration = 50 * time.Second
+
+// StreamGenerationJobEventsHandler emite el progreso de un job por Server-Sent Events.
+// Eventos: "job" (estado del job), "component" (cambio de estado de un componente) y "done".
+// GET /api/learning-plans/jobs/{job_id}/events
+func StreamGenerationJobEventsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
 	}
 
-	if err := db.DB.Create(&plan).Error; err != nil {
-		log.Printf("Error creating plan: %v", err)
-		http.Error(w, `{"error":"failed to create plan"}`, http.StatusInternalServerError)
+	jobID, err := strconv.ParseUint(chi.URLParam(r, "job_id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid job ID"}`, http.StatusBadRequest)
 		return
 	}
 
-	// Crear componentes y generar su contenido en paralelo
-	totalComponents := len(planStructure.Componentes)
-	components := make([]models.LearningPlanComponent, totalComponents)
-	var wg sync.WaitGroup
-	var mu sync.Mutex
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, `{"error":"streaming not supported"}`, http.StatusInternalServerError)
+		return
+	}
 
-	log.Printf("🚀 Starting parallel generation of %d components...", totalComponents)
+	// Suscribirse antes del primer snapshot para no perder cambios
+	updates, unsubscribe := services.SubscribeGenerationJob(uint(jobID))
+	defer unsubscribe()
 
-	for i, compStruct := range planStructure.Componentes {
-		wg.Add(1)
-		go func(index int, cs services.ComponentStructure) {
-			defer wg.Done()
+	progress, err := generationJobService.GetJobProgress(uint(jobID), userID)
+	if err != nil {
+		http.Error(w, `{"error":"job not found"}`, http.StatusNotFound)
+		return
+	}
 
-			// Crear componente en DB
-			component := models.LearningPlanComponent{
-				LearningPlanID:     plan.ID,
-				Orden:              index + 1,
-				TipoComponente:     cs.Tipo,
-				ObjetivoEspecifico: cs.ObjetivoEspecifico,
-				TiempoEstimadoMin:  cs.TiempoEstimadoMin,
-				Estado:             models.ComponentEstadoGenerando,
-			}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprint(w, "retry: 2000\n\n")
 
-			if err := db.DB.Create(&component).Error; err != nil {
-				log.Printf("❌ Error creating component %d: %v", index+1, err)
-				mu.Lock()
-				components[index] = component
-				mu.Unlock()
-				return
-			}
+	lastJobEstado := ""
+	lastComponents := make(map[uint]string)
 
-			// Generar contenido del componente (ahora en paralelo)
-			log.Printf("⏳ [%d/%d] Generating content (%s)...", index+1, totalComponents, cs.Tipo)
-
-			content, err := services.GenerateComponentContent(
-				component.TipoComponente,
-				oaContext,
-				component.ObjetivoEspecifico,
-			)
-
-			if err != nil {
-				log.Printf("❌ [%d/%d] Error generating content: %v", index+1, totalComponents, err)
-				component.Estado = models.ComponentEstadoError
-				component.ErrorMensaje = err.Error()
-				db.DB.Save(&component)
-				mu.Lock()
-				components[index] = component
-				mu.Unlock()
-				return
+	// send escribe solo lo que cambió desde el último snapshot; retorna true al terminar el job
+	send := func(progress *services.GenerationJobProgress) bool {
+		if progress.Job.Estado != lastJobEstado {
+			writeSSE(w, "job", progress.Job)
+			lastJobEstado = progress.Job.Estado
+		}
+		for _, c := range progress.Components {
+			if lastComponents[c.ComponentID] != c.Estado {
+				writeSSE(w, "component", c)
+				lastComponents[c.ComponentID] = c.Estado
 			}
+		}
+		if progress.Job.IsFinished() {
+			writeSSE(w, "done", map[string]interface{}{
+				"estado":           progress.Job.Estado,
+				"learning_plan_id": progress.Job.LearningPlanID,
+				"error_mensaje":    progress.Job.ErrorMensaje,
+				"generados":        progress.Generados,
+				"total":            progress.Total,
+			})
+		}
+		flusher.Flush()
+		return progress.Job.IsFinished()
+	}
 
-			// Guardar contenido generado
-			contentJSON, _ := json.Marshal(content)
-			component.ContenidoProps = datatypes.JSON(contentJSON)
-			component.Estado = models.ComponentEstadoGenerado
-			component.ErrorMensaje = ""
-			db.DB.Save(&component)
-
-			log.Printf("✅ [%d/%d] Content generated successfully", index+1, totalComponents)
-
-			mu.Lock()
-			components[index] = component
-			mu.Unlock()
-		}(i, compStruct)
+	if send(progress) {
+		return
 	}
 
-	// Esperar a que todas las goroutines terminen
-	wg.Wait()
-	log.Printf("✅ All components generated in parallel")
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	deadline := time.After(generationEventsMaxDuration)
 
-	// Actualizar estado del plan a generado (todo está listo)
-	plan.Estado = models.LearningPlanEstadoGenerado
-	plan.Components = components
-	plan.TotalSlides = len(components) // Set total slides for completion tracking
-	db.DB.Save(&plan)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-deadline:
+			return
+		case <-updates:
+		case <-ticker.C:
+		}
 
-	log.Printf("✅ Learning plan fully generated: %s (%d/%d components successful)", plan.Titulo, len(components), totalComponents)
+		progress, err := generationJobService.GetJobProgress(uint(jobID), userID)
+		if err != nil {
+			return
+		}
+		if send(progress) {
+			return
+		}
+	}
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(plan)
+// writeSSE escribe un evento Server-Sent Events con data en JSON
+func writeSSE(w http.ResponseWriter, event string, data interface{}) {
+	payload, _ := json.Marshal(data)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
 }
 
 // GetLearningPlanByIDHandler obtiene un plan por ID
@@ -229,7 +243,7 @@ func GetLearningPlanByIDHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var plan models.LearningPlan
-	if err := db.DB.Preload("Components").
+	if err := db.DB.Preload("Components").Preload("Checkpoints").
 		Where("id = ? AND user_id = ?", planID, userID).
 		First(&plan).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -261,7 +275,7 @@ func GetLearningPlanByOAHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var plan models.LearningPlan
-	if err := db.DB.Preload("Components").
+	if err := db.DB.Preload("Components").Preload("Checkpoints").
 		Where("user_id = ? AND oa_bloom_objective_id = ?", userID, oaID).
 		First(&plan).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -315,90 +329,75 @@ func GenerateComponentContentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Si ya está generado, devolverlo
-	if component.Estado == models.ComponentEstadoGenerado && component.ContenidoProps != nil {
+	// Si ya está generado o en revisión de moderación, devolverlo
+	if (component.Estado == models.ComponentEstadoGenerado && component.ContenidoProps != nil) ||
+		component.Estado == models.ComponentEstadoCuarentena {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(component)
 		return
 	}
 
-	// Marcar como generando
-	component.Estado = models.ComponentEstadoGenerando
-	db.DB.Save(&component)
-
-	// Obtener datos del OA para el contexto
-	var oaBloomObjective models.OABloomObjective
-	if err := db.DB.Preload("OA").Preload("OA.Materia").Preload("BloomLevel").
-		First(&oaBloomObjective, plan.OABloomObjectiveID).Error; err != nil {
-		component.Estado = models.ComponentEstadoError
-		component.ErrorMensaje = "Failed to load OA data"
-		db.DB.Save(&component)
-		http.Error(w, `{"error":"objective not found"}`, http.StatusInternalServerError)
+	// Verificar presupuesto diario de LLM antes de generar
+	if !checkLLMBudget(w, userID) {
 		return
 	}
 
-	// Fetch student profile for personalization
-	var profile models.StudentProfile
-	var interesesPersonales []string
-	var profesionSoñada string
-	var formatoPreferido string
-	var tipoActividad []string
-	var canalPreferido string
-
-	if err := db.DB.Where("user_id = ?", userID).First(&profile).Error; err == nil {
-		// Parse profile_data JSONB field
-		var profileData models.ProfileDataStructure
-		if err := profile.ProfileData.Scan(&profileData); err == nil {
-			interesesPersonales = profileData.InteresesPersonales.Temas
-			profesionSoñada = profileData.InteresesPersonales.ProfesionSoñada
-			formatoPreferido = profileData.PreferenciasAprendizaje.FormatoPreferido
-			tipoActividad = profileData.PreferenciasAprendizaje.TipoActividad
-			canalPreferido = profileData.PreferenciasAprendizaje.CanalPreferido
+	// Marcar como generando: si otra petición (o un job) ya lo reclamó, se devuelve el estado actual
+	claimed, err := services.ClaimComponentGeneration(component.ID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to start generation"}`, http.StatusInternalServerError)
+		return
+	}
+	if !claimed {
+		if err := db.DB.First(&component, component.ID).Error; err != nil {
+			http.Error(w, `{"error":"component not found"}`, http.StatusNotFound)
+			return
 		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(component)
+		return
 	}
+	component.Estado = models.ComponentEstadoGenerando
 
-	// Construir contexto para OpenAI
-	oaContext := services.OAContext{
-		MateriaNombre:      oaBloomObjective.OA.Materia.Nombre,
-		MateriaDescripcion: oaBloomObjective.OA.Materia.Descripcion,
-		CursoNombre:        oaBloomObjective.OA.Materia.Nombre,
-		OATitulo:           oaBloomObjective.OA.Titulo,
-		OADescripcion:      oaBloomObjective.OA.Descripcion,
-		BloomLevelNombre:   oaBloomObjective.BloomLevel.Nombre,
-		BloomLevelNumero:   oaBloomObjective.BloomLevel.Nivel,
-		BloomDescripcion:   oaBloomObjective.BloomLevel.Descripcion,
-		ObjetivoEspecifico: oaBloomObjective.ObjetivoEspecifico,
-		IndicadoresLogro:   oaBloomObjective.IndicadoresLogro,
-		// Student personalization
-		InteresesPersonales: interesesPersonales,
-		ProfesionSoñada:     profesionSoñada,
-		FormatoPreferido:    formatoPreferido,
-		TipoActividad:       tipoActividad,
-		CanalPreferido:      canalPreferido,
+	// Obtener datos del OA y del perfil para el contexto
+	oaContext, err := services.BuildOAContext(userID, plan.OABloomObjectiveID)
+	if err != nil {
+		services.MarkComponentError(&component, "Failed to load OA data")
+		http.Error(w, `{"error":"objective not found"}`, http.StatusInternalServerError)
+		return
 	}
 
+	// En una regeneración se agrega el feedback que la motivó
+	oaContext.FeedbackEstudiante = services.RegenerationFeedback(&plan, &component.ID)
+
 	// Generar contenido
-	content, err := services.GenerateComponentContent(
+	content, promptVersion, err := services.GenerateComponentContent(
+		llm.WithUser(r.Context(), userID),
 		component.TipoComponente,
-		oaContext,
+		*oaContext,
 		component.ObjetivoEspecifico,
 	)
 
+	// Contenido marcado por la moderación: el componente queda en cuarentena (estado "cuarentena")
+	if services.QuarantineComponent(&component, userID, err) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(component)
+		return
+	}
+
 	if err != nil {
 		log.Printf("Error generating component content: %v", err)
-		component.Estado = models.ComponentEstadoError
-		component.ErrorMensaje = err.Error()
-		db.DB.Save(&component)
+		services.MarkComponentError(&component, err.Error())
 		http.Error(w, `{"error":"failed to generate content"}`, http.StatusInternalServerError)
 		return
 	}
 
 	// Guardar contenido
-	contentJSON, _ := json.Marshal(content)
-	component.ContenidoProps = datatypes.JSON(contentJSON)
-	component.Estado = models.ComponentEstadoGenerado
-	component.ErrorMensaje = ""
-	db.DB.Save(&component)
+	if err := services.SaveGeneratedComponent(&component, content, promptVersion); err != nil {
+		log.Printf("Error saving component content: %v", err)
+		http.Error(w, `{"error":"failed to save content"}`, http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(component)
@@ -445,7 +444,8 @@ func StartLearningPlanHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(plan)
 }
 
-// CompleteLearningPlanHandler marks a learning plan as completed
+// CompleteLearningPlanHandler marks a learning plan as completed.
+// Responds 409 with the pending checkpoint IDs if any checkpoint has not been passed.
 // POST /api/learning-plans/{id}/complete
 func CompleteLearningPlanHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r.Context())
@@ -471,6 +471,22 @@ func CompleteLearningPlanHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Completion requires passing every checkpoint of the plan
+	pending, err := services.PendingCheckpointIDs(plan.ID)
+	if err != nil {
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if len(pending) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":                  "checkpoints pending",
+			"checkpoints_pendientes": pending,
+		})
+		return
+	}
+
 	// Mark as completed
 	now := time.Now()
 	plan.Completado = true
@@ -492,3 +508,205 @@ func CompleteLearningPlanHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(plan)
 }
+
+// contentStreamMessage es un mensaje del generador al handler de streaming
+type contentStreamMessage struct {
+	event string
+	data  interface{}
+}
+
+// StreamComponentContentHandler genera el contenido de un componente y emite por Server-Sent Events
+// el título y cada bloque apenas se terminan de parsear. Al final guarda el ContenidoProps validado.
+// Eventos: "titulo", "bloque", "reintento" (descartar lo recibido), "done" (componente final), "error"
+// y "cuarentena" (la moderación marcó el contenido: descartar lo recibido y mostrarlo como en revisión).
+// POST /api/learning-plans/{plan_id}/components/{component_id}/stream-content
+func StreamComponentContentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	planID, err := strconv.ParseUint(chi.URLParam(r, "plan_id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid plan ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	componentID, err := strconv.ParseUint(chi.URLParam(r, "component_id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid component ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, `{"error":"streaming not supported"}`, http.StatusInternalServerError)
+		return
+	}
+
+	// Verificar que el plan pertenece al usuario
+	var plan models.LearningPlan
+	if err := db.DB.Where("id = ? AND user_id = ?", planID, userID).First(&plan).Error; err != nil {
+		http.Error(w, `{"error":"plan not found"}`, http.StatusNotFound)
+		return
+	}
+
+	var component models.LearningPlanComponent
+	if err := db.DB.Where("id = ? AND learning_plan_id = ?", componentID, planID).
+		First(&component).Error; err != nil {
+		http.Error(w, `{"error":"component not found"}`, http.StatusNotFound)
+		return
+	}
+
+	// Solo se genera si no hay contenido ni otra generación en curso
+	generate := component.Estado == models.ComponentEstadoPendiente || component.Estado == models.ComponentEstadoError
+	if generate && !checkLLMBudget(w, userID) {
+		return
+	}
+
+	var oaContext *services.OAContext
+	if generate {
+		oaContext, err = services.BuildOAContext(userID, plan.OABloomObjectiveID)
+		if err != nil {
+			http.Error(w, `{"error":"objective not found"}`, http.StatusInternalServerError)
+			return
+		}
+		oaContext.FeedbackEstudiante = services.RegenerationFeedback(&plan, &component.ID)
+	}
+
+	if generate {
+		// Se reclama el componente de forma atómica: si dos pestañas o un job llegan a la vez,
+		// solo uno genera (y paga) el contenido y el resto espera el resultado guardado
+		claimed, err := services.ClaimComponentGeneration(component.ID)
+		if err != nil {
+			http.Error(w, `{"error":"failed to start generation"}`, http.StatusInternalServerError)
+			return
+		}
+		generate = claimed
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	flusher.Flush()
+
+	if !generate {
+		// Ya generado o generándose en otro proceso (p. ej. un job): esperar y enviar lo guardado
+		streamStoredComponentContent(w, flusher, r, component.ID)
+		return
+	}
+
+	component.Estado = models.ComponentEstadoGenerando
+
+	// La generación sigue aunque el cliente se desconecte, para no perder el contenido pagado
+	messages := make(chan contentStreamMessage, 64)
+	stopped := make(chan struct{})
+	defer close(stopped)
+
+	send := func(event string, data interface{}) {
+		select {
+		case messages <- contentStreamMessage{event: event, data: data}:
+		case <-stopped:
+		}
+	}
+
+	genCtx := llm.WithUser(context.WithoutCancel(r.Context()), userID)
+	go func() {
+		defer close(messages)
+
+		content, promptVersion, err := services.StreamComponentContent(genCtx, component.TipoComponente, *oaContext, component.ObjetivoEspecifico,
+			func(event services.ContentStreamEvent) {
+				send(event.Tipo, event)
+			})
+		if services.QuarantineComponent(&component, userID, err) {
+			send("cuarentena", component)
+			return
+		}
+		if err != nil {
+			log.Printf("Error streaming component content: %v", err)
+			services.MarkComponentError(&component, err.Error())
+			send("error", map[string]string{"error": "failed to generate content"})
+			return
+		}
+
+		if err := services.SaveGeneratedComponent(&component, content, promptVersion); err != nil {
+			log.Printf("Error saving streamed component content: %v", err)
+			send("error", map[string]string{"error": "failed to save content"})
+			return
+		}
+		send("done", component)
+	}()
+
+	deadline := time.After(generationEventsMaxDuration)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-deadline:
+			// El cliente reconecta y recibe el contenido guardado
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			writeSSE(w, msg.event, msg.data)
+			flusher.Flush()
+		}
+	}
+}
+
+// streamStoredComponentContent espera a que un componente termine de generarse y emite su contenido
+// guardado con los mismos eventos que el streaming ("titulo", "bloque" y "done", "error" o "cuarentena").
+// "done" trae el componente completo, así que los campos de texto no se repiten como "campo".
+func streamStoredComponentContent(w http.ResponseWriter, flusher http.Flusher, r *http.Request, componentID uint) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	deadline := time.After(generationEventsMaxDuration)
+
+	for {
+		var component models.LearningPlanComponent
+		if err := db.DB.First(&component, componentID).Error; err != nil {
+			writeSSE(w, "error", map[string]string{"error": "component not found"})
+			flusher.Flush()
+			return
+		}
+
+		switch component.Estado {
+		case models.ComponentEstadoGenerado:
+			var content map[string]interface{}
+			json.Unmarshal(component.ContenidoProps, &content)
+
+			titulo, _ := content["titulo"].(string)
+			writeSSE(w, services.ContentStreamEventTitulo, services.ContentStreamEvent{Tipo: services.ContentStreamEventTitulo, Titulo: titulo})
+			arrayField := services.StreamedArrayField(component.TipoComponente)
+			if items, ok := content[arrayField].([]interface{}); ok {
+				for i, item := range items {
+					if bloque, ok := item.(map[string]interface{}); ok {
+						writeSSE(w, services.ContentStreamEventBloque, services.ContentStreamEvent{Tipo: services.ContentStreamEventBloque, Campo: arrayField, Indice: i, Bloque: bloque})
+					}
+				}
+			}
+			writeSSE(w, "done", component)
+			flusher.Flush()
+			return
+		case models.ComponentEstadoError:
+			writeSSE(w, "error", map[string]string{"error": component.ErrorMensaje})
+			flusher.Flush()
+			return
+		case models.ComponentEstadoCuarentena:
+			writeSSE(w, "cuarentena", component)
+			flusher.Flush()
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-deadline:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/platanus-hack-25/lumera_app/internal/middleware"
+	"github.com/platanus-hack-25/lumera_app/internal/services"
+)
+
+// SubmitCheckpointRequest es el payload con las respuestas de un checkpoint
+type SubmitCheckpointRequest struct {
+	Respuestas []services.CheckpointAnswer `json:"respuestas"`
+}
+
+// writeCheckpointError traduce los errores del servicio de checkpoints a respuestas HTTP
+func writeCheckpointError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrPlanNotFound):
+		http.Error(w, `{"error":"plan not found"}`, http.StatusNotFound)
+	case errors.Is(err, services.ErrCheckpointNotFound):
+		http.Error(w, `{"error":"checkpoint not found"}`, http.StatusNotFound)
+	default:
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+	}
+}
+
+// ListLearningPlanCheckpointsHandler lista los checkpoints del plan con sus preguntas (sin respuestas)
+// GET /api/learning-plans/{id}/checkpoints
+func ListLearningPlanCheckpointsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	planID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid plan ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	checkpoints, err := services.ListPlanCheckpoints(userID, uint(planID))
+	if err != nil {
+		writeCheckpointError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(checkpoints)
+}
+
+// SubmitCheckpointHandler corrige las respuestas de un checkpoint. Si se reprueba se inserta un
+// slide de remediación después del componente del checkpoint (viene en "remediacion").
+// POST /api/learning-plans/{id}/checkpoints/{checkpoint_id}/submit
+func SubmitCheckpointHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	planID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid plan ID"}`, http.StatusBadRequest)
+		return
+	}
+	checkpointID, err := strconv.ParseUint(chi.URLParam(r, "checkpoint_id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid checkpoint ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req SubmitCheckpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	result, err := services.SubmitCheckpoint(userID, uint(planID), uint(checkpointID), req.Respuestas)
+	if err != nil {
+		writeCheckpointError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/platanus-hack-25/lumera_app/internal/middleware"
+	"github.com/platanus-hack-25/lumera_app/internal/services"
+)
+
+// ExportLearningPlanHandler descarga el plan como zip autocontenido: scorm (SCORM 1.2), xapi (Tin Can),
+// html (página imprimible) o markdown. Incluye los audios TTS ya cacheados.
+// Docentes y admins pueden exportar cualquier plan; los estudiantes solo los suyos.
+// GET /api/learning-plans/{id}/export?format=scorm|xapi|html|markdown
+func ExportLearningPlanHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	planID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid plan ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	role, _ := middleware.GetRoleFromContext(r.Context())
+	anyOwner := role == "admin" || role == "docente"
+
+	export, err := services.ExportLearningPlan(userID, anyOwner, uint(planID), r.URL.Query().Get("format"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidExportFormat):
+			errorJSON, _ := json.Marshal(map[string]string{"error": err.Error()})
+			http.Error(w, string(errorJSON), http.StatusBadRequest)
+		case errors.Is(err, services.ErrPlanNotFound):
+			http.Error(w, `{"error":"plan not found"}`, http.StatusNotFound)
+		case errors.Is(err, services.ErrPlanBusy):
+			http.Error(w, `{"error":"plan is being generated"}`, http.StatusConflict)
+		default:
+			log.Printf("Error exporting learning plan %d: %v", planID, err)
+			http.Error(w, `{"error":"failed to export plan"}`, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", export.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, export.Filename))
+	w.Header().Set("Content-Length", strconv.Itoa(len(export.Data)))
+	w.Write(export.Data)
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/platanus-hack-25/lumera_app/internal/middleware"
+	"github.com/platanus-hack-25/lumera_app/internal/services"
+)
+
+var planProgressService = services.NewPlanProgressService()
+
+// RecordLearningPlanEventsRequest es el lote de eventos de visualización de slides
+type RecordLearningPlanEventsRequest struct {
+	Eventos []services.ComponentEventInput `json:"eventos"`
+}
+
+// RecordLearningPlanEventsHandler registra eventos de visualización (abierto, tiempo, scroll_final, audio)
+// y retorna el progreso actualizado del plan
+// POST /api/learning-plans/{id}/events
+func RecordLearningPlanEventsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	planID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid plan ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req RecordLearningPlanEventsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	progress, err := planProgressService.RecordEvents(userID, uint(planID), req.Eventos)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrPlanNotFound):
+			http.Error(w, `{"error":"plan not found"}`, http.StatusNotFound)
+		case errors.Is(err, services.ErrInvalidComponentEvent):
+			errorJSON, _ := json.Marshal(map[string]string{"error": err.Error()})
+			http.Error(w, string(errorJSON), http.StatusBadRequest)
+		default:
+			http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(progress)
+}
+
+// GetLearningPlanProgressHandler retorna el progreso del plan, el componente donde retomar y la
+// actividad del estudiante en cada componente
+// GET /api/learning-plans/{id}/progress
+func GetLearningPlanProgressHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	planID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid plan ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	progress, err := planProgressService.GetProgress(userID, uint(planID))
+	if err != nil {
+		if errors.Is(err, services.ErrPlanNotFound) {
+			http.Error(w, `{"error":"plan not found"}`, http.StatusNotFound)
+		} else {
+			http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(progress)
+}
+
+// GetPlanAbandonmentReport godoc
+// @Summary Learning plan abandonment report
+// @Description Aggregates slide view events to show where students abandon learning plans. A plan counts as abandoned when it is not completed and has had no activity for inactive_hours; it was abandoned at its last opened component. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param from query string false "Start date YYYY-MM-DD (default: 30 days ago)"
+// @Param to query string false "End date YYYY-MM-DD, inclusive (default: today)"
+// @Param group_by query string false "tipo | orden | objetivo | remediacion (default: tipo)"
+// @Param oa_bloom_objective_id query int false "Only plans for this OA-Bloom objective"
+// @Param inactive_hours query int false "Hours without activity to consider a plan abandoned (default: 48)"
+// @Success 200 {object} services.AbandonmentReport
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/admin/learning-plans/abandonment [get]
+func GetPlanAbandonmentReport(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from := today.AddDate(0, 0, -30)
+	to := today
+
+	query := r.URL.Query()
+	if fromStr := query.Get("from"); fromStr != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", fromStr, now.Location())
+		if err != nil {
+			http.Error(w, `{"error":"invalid from date, expected YYYY-MM-DD"}`, http.StatusBadRequest)
+			return
+		}
+		from = parsed
+	}
+	if toStr := query.Get("to"); toStr != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", toStr, now.Location())
+		if err != nil {
+			http.Error(w, `{"error":"invalid to date, expected YYYY-MM-DD"}`, http.StatusBadRequest)
+			return
+		}
+		to = parsed
+	}
+
+	groupBy := query.Get("group_by")
+	if groupBy == "" {
+		groupBy = "tipo"
+	}
+
+	var oaBloomObjectiveID uint64
+	if idStr := query.Get("oa_bloom_objective_id"); idStr != "" {
+		parsed, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			http.Error(w, `{"error":"invalid oa_bloom_objective_id"}`, http.StatusBadRequest)
+			return
+		}
+		oaBloomObjectiveID = parsed
+	}
+
+	inactiveHours := 48
+	if hoursStr := query.Get("inactive_hours"); hoursStr != "" {
+		parsed, err := strconv.Atoi(hoursStr)
+		if err != nil {
+			http.Error(w, `{"error":"invalid inactive_hours"}`, http.StatusBadRequest)
+			return
+		}
+		inactiveHours = parsed
+	}
+
+	// "to" is inclusive for callers
+	report, err := planProgressService.GetAbandonmentReport(from, to.AddDate(0, 0, 1), groupBy, uint(oaBloomObjectiveID), inactiveHours)
+	if err != nil {
+		errorJSON, _ := json.Marshal(map[string]string{"error": err.Error()})
+		http.Error(w, string(errorJSON), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
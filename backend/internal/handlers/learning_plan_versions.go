@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/platanus-hack-25/lumera_app/internal/middleware"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"github.com/platanus-hack-25/lumera_app/internal/services"
+)
+
+// RegenerateRequest es el comentario opcional que se agrega al prompt de la regeneración
+type RegenerateRequest struct {
+	Comentario string `json:"comentario"`
+}
+
+// writePlanVersionError traduce los errores de feedback, regeneración y versiones a respuestas HTTP
+func writePlanVersionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrPlanNotFound):
+		http.Error(w, `{"error":"plan not found"}`, http.StatusNotFound)
+	case errors.Is(err, services.ErrComponentNotFound):
+		http.Error(w, `{"error":"component not found"}`, http.StatusNotFound)
+	case errors.Is(err, services.ErrPlanVersionNotFound):
+		http.Error(w, `{"error":"plan version not found"}`, http.StatusNotFound)
+	case errors.Is(err, services.ErrPlanBusy):
+		http.Error(w, `{"error":"plan is being generated"}`, http.StatusConflict)
+	case errors.Is(err, services.ErrInvalidFeedback), errors.Is(err, services.ErrInvalidPlanVersion):
+		errorJSON, _ := json.Marshal(map[string]string{"error": err.Error()})
+		http.Error(w, string(errorJSON), http.StatusBadRequest)
+	default:
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+	}
+}
+
+// decodeRegenerateRequest lee el body opcional de las regeneraciones
+func decodeRegenerateRequest(r *http.Request) (RegenerateRequest, error) {
+	var req RegenerateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		return req, err
+	}
+	return req, nil
+}
+
+// SubmitLearningPlanFeedbackHandler guarda un pulgar arriba/abajo y/o comentario sobre un componente
+// (component_id) o sobre el plan completo (sin component_id)
+// POST /api/learning-plans/{id}/feedback
+func SubmitLearningPlanFeedbackHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	planID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid plan ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req services.FeedbackInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	plan := uint(planID)
+	if !checkStudentInput(w, r, userID, models.ModerationEntidadFeedback, &plan, req.Comentario) {
+		return
+	}
+
+	feedback, err := services.SubmitFeedback(userID, plan, req)
+	if err != nil {
+		writePlanVersionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(feedback)
+}
+
+// ListLearningPlanFeedbackHandler lista el feedback del plan (del más reciente al más antiguo)
+// GET /api/learning-plans/{id}/feedback
+func ListLearningPlanFeedbackHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	planID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid plan ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	feedback, err := services.ListPlanFeedback(userID, uint(planID))
+	if err != nil {
+		writePlanVersionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(feedback)
+}
+
+// RegenerateComponentHandler guarda una nueva versión del plan y deja el componente pendiente; el contenido
+// se genera después con stream-content (o generate-content) usando el feedback del estudiante
+// POST /api/learning-plans/{plan_id}/components/{component_id}/regenerate
+func RegenerateComponentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	planID, err := strconv.ParseUint(chi.URLParam(r, "plan_id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid plan ID"}`, http.StatusBadRequest)
+		return
+	}
+	componentID, err := strconv.ParseUint(chi.URLParam(r, "component_id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid component ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	req, err := decodeRegenerateRequest(r)
+	if err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	// El comentario se agrega al prompt de la regeneración
+	plan := uint(planID)
+	if !checkStudentInput(w, r, userID, models.ModerationEntidadRegeneracion, &plan, req.Comentario) {
+		return
+	}
+
+	// Verificar presupuesto diario de LLM antes de descartar el contenido actual
+	if !checkLLMBudget(w, userID) {
+		return
+	}
+
+	component, err := services.RegenerateComponent(userID, plan, uint(componentID), req.Comentario)
+	if err != nil {
+		writePlanVersionError(w, err)
+		return
+	}
+
+	log.Printf("♻ Component %d of plan %d reset for regeneration by user %d", componentID, planID, userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(component)
+}
+
+// RegenerateLearningPlanHandler guarda una nueva versión y regenera el plan completo (estructura y contenido)
+// con el feedback pendiente. Responde 202 con el job, igual que la generación.
+// POST /api/learning-plans/{id}/regenerate
+func RegenerateLearningPlanHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	planID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid plan ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	req, err := decodeRegenerateRequest(r)
+	if err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	// El comentario se agrega al prompt de la regeneración
+	plan := uint(planID)
+	if !checkStudentInput(w, r, userID, models.ModerationEntidadRegeneracion, &plan, req.Comentario) {
+		return
+	}
+
+	if !checkLLMBudget(w, userID) {
+		return
+	}
+
+	regenerated, err := services.RegeneratePlan(userID, plan, req.Comentario)
+	if err != nil {
+		writePlanVersionError(w, err)
+		return
+	}
+
+	// Si el encolado falla, la recuperación de planes huérfanos lo vuelve a encolar
+	job, err := generationJobService.EnqueuePlanRegeneration(regenerated)
+	if err != nil {
+		log.Printf("Error enqueuing plan regeneration: %v", err)
+		http.Error(w, `{"error":"failed to enqueue learning plan"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("📥 Learning plan %d regeneration queued as job %d (version %d)", regenerated.ID, job.ID, regenerated.Version)
+	writeGenerationJobAccepted(w, job)
+}
+
+// ListLearningPlanVersionsHandler lista las versiones del plan, de la actual a la más antigua
+// GET /api/learning-plans/{id}/versions
+func ListLearningPlanVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	planID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid plan ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	versions, err := services.ListPlanVersions(userID, uint(planID))
+	if err != nil {
+		writePlanVersionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versions)
+}
+
+// GetLearningPlanVersionHandler retorna una versión del plan con sus componentes y checkpoints
+// GET /api/learning-plans/{id}/versions/{version}
+func GetLearningPlanVersionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	planID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid plan ID"}`, http.StatusBadRequest)
+		return
+	}
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid version"}`, http.StatusBadRequest)
+		return
+	}
+
+	detail, err := services.GetPlanVersion(userID, uint(planID), version)
+	if err != nil {
+		writePlanVersionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
+
+// CompareLearningPlanVersionsHandler compara dos versiones del plan componente a componente.
+// Sin "to" compara contra la versión actual.
+// GET /api/learning-plans/{id}/versions/compare?from=1&to=3
+func CompareLearningPlanVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	planID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid plan ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid from version"}`, http.StatusBadRequest)
+		return
+	}
+	to := 0
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		to, err = strconv.Atoi(toStr)
+		if err != nil {
+			http.Error(w, `{"error":"invalid to version"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	comparison, err := services.ComparePlanVersions(userID, uint(planID), from, to)
+	if err != nil {
+		writePlanVersionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comparison)
+}
+
+// RollbackLearningPlanHandler restaura una versión anterior del plan (la actual queda guardada como versión)
+// POST /api/learning-plans/{id}/versions/{version}/rollback
+func RollbackLearningPlanHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	planID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid plan ID"}`, http.StatusBadRequest)
+		return
+	}
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid version"}`, http.StatusBadRequest)
+		return
+	}
+
+	plan, err := services.RollbackPlan(userID, uint(planID), version)
+	if err != nil {
+		writePlanVersionError(w, err)
+		return
+	}
+
+	log.Printf("⏪ Learning plan %d rolled back to version %d by user %d (now version %d)", planID, version, userID, plan.Version)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plan)
+}
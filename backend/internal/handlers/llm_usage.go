@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	authmiddleware "github.com/platanus-hack-25/lumera_app/internal/middleware"
+	"github.com/platanus-hack-25/lumera_app/internal/services"
+)
+
+var llmUsageService = services.NewLLMUsageService()
+
+// checkLLMBudget writes a 429 and returns false when the user has no LLM budget left today
+func checkLLMBudget(w http.ResponseWriter, userID uint) bool {
+	err := llmUsageService.CheckBudget(userID)
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, services.ErrLLMBudgetExceeded) {
+		http.Error(w, `{"error":"daily AI budget exceeded"}`, http.StatusTooManyRequests)
+		return false
+	}
+	// Accounting problems must not block learning
+	log.Printf("⚠ Could not check LLM budget for user %d: %v", userID, err)
+	return true
+}
+
+// GetMyLLMBudget godoc
+// @Summary Get my LLM budget
+// @Description Returns the authenticated user's daily LLM budget and today's spend
+// @Tags LLM Usage
+// @Produce json
+// @Success 200 {object} services.LLMBudgetStatus
+// @Failure 401 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/users/me/llm-budget [get]
+func GetMyLLMBudget(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authmiddleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	status, err := llmUsageService.GetBudgetStatus(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to get budget"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// GetLLMUsageReport godoc
+// @Summary LLM usage report
+// @Description Aggregates LLM calls (tokens, retries, latency, estimated cost) between two dates. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param from query string false "Start date YYYY-MM-DD (default: 30 days ago)"
+// @Param to query string false "End date YYYY-MM-DD, inclusive (default: today)"
+// @Param group_by query string false "feature | model | user | day (default: feature)"
+// @Success 200 {object} services.LLMUsageReport
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/admin/llm-usage [get]
+func GetLLMUsageReport(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from := today.AddDate(0, 0, -30)
+	to := today
+
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", fromStr, now.Location())
+		if err != nil {
+			http.Error(w, `{"error":"invalid from date, expected YYYY-MM-DD"}`, http.StatusBadRequest)
+			return
+		}
+		from = parsed
+	}
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", toStr, now.Location())
+		if err != nil {
+			http.Error(w, `{"error":"invalid to date, expected YYYY-MM-DD"}`, http.StatusBadRequest)
+			return
+		}
+		to = parsed
+	}
+
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy == "" {
+		groupBy = "feature"
+	}
+
+	// "to" is inclusive for callers
+	report, err := llmUsageService.GetUsageReport(from, to.AddDate(0, 0, 1), groupBy)
+	if err != nil {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// SetLLMBudgetRequest is the payload to set a user's daily LLM budget
+type SetLLMBudgetRequest struct {
+	DailyBudgetUSD *float64 `json:"daily_budget_usd"` // null restores the default, 0 = unlimited
+}
+
+// SetUserLLMBudget godoc
+// @Summary Set a user's LLM budget
+// @Description Sets the daily LLM budget in USD for a user. null restores the default, 0 means unlimited. Admin only.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Param request body SetLLMBudgetRequest true "Budget"
+// @Success 200 {object} services.LLMBudgetStatus
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/admin/users/{user_id}/llm-budget [put]
+func SetUserLLMBudget(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseUint(chi.URLParam(r, "user_id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid user ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req SetLLMBudgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := llmUsageService.SetUserBudget(uint(userID), req.DailyBudgetUSD); err != nil {
+		status := http.StatusBadRequest
+		if err.Error() == "user not found" {
+			status = http.StatusNotFound
+		}
+		http.Error(w, `{"error":"`+err.Error()+`"}`, status)
+		return
+	}
+
+	budget, err := llmUsageService.GetBudgetStatus(uint(userID))
+	if err != nil {
+		http.Error(w, `{"error":"failed to get budget"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(budget)
+}
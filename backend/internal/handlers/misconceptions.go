@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/platanus-hack-25/lumera_app/internal/middleware"
+	"github.com/platanus-hack-25/lumera_app/internal/services"
+)
+
+// ListMisconceptions godoc
+// @Summary List the misconception catalog
+// @Description Misconceptions of a materia ("the student confuses concepto with confundido_con") that multiple_choice distractors reference by codigo. Only active entries unless incluir_inactivos=true.
+// @Tags Misconceptions
+// @Produce json
+// @Param materia_id query int false "Materia ID"
+// @Param incluir_inactivos query bool false "Include deactivated entries"
+// @Success 200 {array} models.Misconception
+// @Failure 400 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/misconceptions [get]
+func ListMisconceptions(w http.ResponseWriter, r *http.Request) {
+	materiaID, _, ok := parseCurriculumFilter(w, r)
+	if !ok {
+		return
+	}
+	soloActivos := r.URL.Query().Get("incluir_inactivos") != "true"
+
+	misconceptions, err := services.ListMisconceptions(materiaID, soloActivos)
+	if err != nil {
+		log.Printf("Error listing misconceptions: %v", err)
+		http.Error(w, `{"error":"failed to list misconceptions"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(misconceptions)
+}
+
+// CreateMisconception godoc
+// @Summary Add a misconception to the catalog
+// @Description codigo is unique per materia (stored in upper case, no spaces). Teachers and admins.
+// @Tags Misconceptions
+// @Accept json
+// @Produce json
+// @Param request body services.MisconceptionInput true "Misconception"
+// @Success 201 {object} models.Misconception
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/misconceptions [post]
+func CreateMisconception(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req services.MisconceptionInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	misconception, err := services.CreateMisconception(userID, req)
+	if err != nil {
+		writeMisconceptionError(w, "creating misconception", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(misconception)
+}
+
+// UpdateMisconception godoc
+// @Summary Update a misconception
+// @Description Replaces the texts and the activo flag. materia_id and codigo cannot change because tagged questions reference them: deactivate the entry (activo=false) and create a new one instead. Deactivated codes stop being accepted in new tags and stop producing feedback. Teachers and admins.
+// @Tags Misconceptions
+// @Accept json
+// @Produce json
+// @Param id path int true "Misconception ID"
+// @Param request body services.MisconceptionInput true "Misconception"
+// @Success 200 {object} models.Misconception
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/misconceptions/{id} [put]
+func UpdateMisconception(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid misconception id"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req services.MisconceptionInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	misconception, err := services.UpdateMisconception(uint(id), req)
+	if err != nil {
+		writeMisconceptionError(w, "updating misconception", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(misconception)
+}
+
+// GetMisconceptionDashboard godoc
+// @Summary Misconception frequency of a classroom
+// @Description Misconceptions revealed by the distractors chosen in practice and diagnostic sessions by the students of a curso (matched by the curso_actual of their profile) between two dates: observations, affected students and their share of the curso, the questions that revealed them and the affected students. Most widespread first. Teachers and admins only.
+// @Tags Misconceptions
+// @Produce json
+// @Param curso_id query int true "Curso ID"
+// @Param materia_id query int false "Only misconceptions of this subject"
+// @Param from query string false "Start date YYYY-MM-DD (default: 30 days ago)"
+// @Param to query string false "End date YYYY-MM-DD, inclusive (default: today)"
+// @Success 200 {object} services.MisconceptionDashboard
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/misconceptions/dashboard [get]
+func GetMisconceptionDashboard(w http.ResponseWriter, r *http.Request) {
+	cursoID, err := strconv.ParseUint(r.URL.Query().Get("curso_id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"curso_id is required"}`, http.StatusBadRequest)
+		return
+	}
+	var materiaID *uint
+	if value := r.URL.Query().Get("materia_id"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			http.Error(w, `{"error":"invalid materia_id"}`, http.StatusBadRequest)
+			return
+		}
+		id := uint(parsed)
+		materiaID = &id
+	}
+	from, to, ok := parseEngagementRange(w, r)
+	if !ok {
+		return
+	}
+
+	// "to" is inclusive for callers
+	dashboard, err := services.GetMisconceptionDashboard(uint(cursoID), materiaID, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		writeMisconceptionError(w, "building misconception dashboard", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dashboard)
+}
+
+func writeMisconceptionError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case errors.Is(err, services.ErrMisconceptionNotFound):
+		http.Error(w, `{"error":"misconception not found"}`, http.StatusNotFound)
+	case errors.Is(err, services.ErrMateriaNotFound):
+		http.Error(w, `{"error":"materia not found"}`, http.StatusNotFound)
+	case errors.Is(err, services.ErrClassroomNotFound):
+		http.Error(w, `{"error":"curso not found"}`, http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidMisconception):
+		errorJSON, _ := json.Marshal(map[string]string{"error": err.Error()})
+		http.Error(w, string(errorJSON), http.StatusBadRequest)
+	default:
+		log.Printf("Error %s: %v", action, err)
+		http.Error(w, `{"error":"misconception request failed"}`, http.StatusInternalServerError)
+	}
+}
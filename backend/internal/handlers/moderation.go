@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/platanus-hack-25/lumera_app/internal/middleware"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"github.com/platanus-hack-25/lumera_app/internal/services"
+)
+
+// checkStudentInput revisa un texto escrito por el estudiante; si la moderación lo marca responde 422
+// y retorna false. El texto queda registrado para revisión de un admin.
+func checkStudentInput(w http.ResponseWriter, r *http.Request, userID uint, entidadTipo string, entidadID *uint, text string) bool {
+	if err := services.ModerateStudentInput(r.Context(), userID, entidadTipo, entidadID, text); err != nil {
+		http.Error(w, `{"error":"input flagged by moderation"}`, http.StatusUnprocessableEntity)
+		return false
+	}
+	return true
+}
+
+// ListModerationFlags godoc
+// @Summary List moderation flags
+// @Description Generated content, questions and student inputs flagged by moderation, newest first. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param estado query string false "pendiente | aprobado | rechazado (default: pendiente, 'all' for every state)"
+// @Param origen query string false "contenido_generado | pregunta | entrada_estudiante"
+// @Param limit query int false "Max results (default: 50, max: 200)"
+// @Success 200 {array} models.ModerationFlag
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/admin/moderation/flags [get]
+func ListModerationFlags(w http.ResponseWriter, r *http.Request) {
+	estado := r.URL.Query().Get("estado")
+	switch estado {
+	case "":
+		estado = models.ModerationEstadoPendiente
+	case "all":
+		estado = ""
+	}
+
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 && parsed <= 200 {
+			limit = parsed
+		}
+	}
+
+	flags, err := services.ListModerationFlags(estado, r.URL.Query().Get("origen"), limit)
+	if err != nil {
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flags)
+}
+
+// ReviewModerationFlag godoc
+// @Summary Review a moderation flag
+// @Description Approves or rejects flagged content. Approving a quarantined component restores its content; rejecting it sends the component back to pending so it is generated again. Approving a question activates it in the bank. Admin only.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path int true "Flag ID"
+// @Param request body services.ModerationReviewInput true "Decision (aprobar | rechazar) and optional note"
+// @Success 200 {object} models.ModerationFlag
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/admin/moderation/flags/{id}/review [post]
+func ReviewModerationFlag(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	flagID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid flag ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req services.ModerationReviewInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	flag, err := services.ReviewModerationFlag(adminID, uint(flagID), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrModerationFlagNotFound):
+			http.Error(w, `{"error":"moderation flag not found"}`, http.StatusNotFound)
+		case errors.Is(err, services.ErrModerationFlagReviewed):
+			http.Error(w, `{"error":"moderation flag already reviewed"}`, http.StatusConflict)
+		case errors.Is(err, services.ErrInvalidModerationReview):
+			errorJSON, _ := json.Marshal(map[string]string{"error": err.Error()})
+			http.Error(w, string(errorJSON), http.StatusBadRequest)
+		default:
+			http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flag)
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/platanus-hack-25/lumera_app/internal/middleware"
+	"github.com/platanus-hack-25/lumera_app/internal/services"
+)
+
+// maxPrerequisiteCSVBytes limita el tamaño del CSV de importación
+const maxPrerequisiteCSVBytes = 2 << 20
+
+// ListOAPrerequisites godoc
+// @Summary List OA prerequisites
+// @Description Edges of the OA prerequisite graph touching a materia and/or an OA (as dependent or as prerequisite)
+// @Tags Educational
+// @Produce json
+// @Param materia_id query int false "Materia ID"
+// @Param oa_id query int false "OA ID"
+// @Success 200 {array} models.OAPrerequisite
+// @Failure 400 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/oa-prerequisites [get]
+func ListOAPrerequisites(w http.ResponseWriter, r *http.Request) {
+	materiaID, oaID, ok := parseCurriculumFilter(w, r)
+	if !ok {
+		return
+	}
+
+	prerequisites, err := services.ListOAPrerequisites(materiaID, oaID)
+	if err != nil {
+		log.Printf("Error listing OA prerequisites: %v", err)
+		http.Error(w, `{"error":"failed to list prerequisites"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prerequisites)
+}
+
+// CreateOAPrerequisite godoc
+// @Summary Add an OA prerequisite
+// @Description Adds the edge "oa_id builds on prerequisito_oa_id", optionally narrowed to Bloom objectives of either OA. Rejected with 409 when it would close a cycle. Teachers and admins.
+// @Tags Educational
+// @Accept json
+// @Produce json
+// @Param request body services.OAPrerequisiteInput true "Edge"
+// @Success 201 {object} models.OAPrerequisite
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/oa-prerequisites [post]
+func CreateOAPrerequisite(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req services.OAPrerequisiteInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	prerequisite, err := services.CreateOAPrerequisite(userID, req)
+	if err != nil {
+		writePrerequisiteError(w, "creating OA prerequisite", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(prerequisite)
+}
+
+// UpdateOAPrerequisite godoc
+// @Summary Update an OA prerequisite
+// @Description Replaces the edge endpoints, Bloom objectives and note. Teachers and admins.
+// @Tags Educational
+// @Accept json
+// @Produce json
+// @Param id path int true "Prerequisite ID"
+// @Param request body services.OAPrerequisiteInput true "Edge"
+// @Success 200 {object} models.OAPrerequisite
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/oa-prerequisites/{id} [put]
+func UpdateOAPrerequisite(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid prerequisite id"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req services.OAPrerequisiteInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	prerequisite, err := services.UpdateOAPrerequisite(uint(id), req)
+	if err != nil {
+		writePrerequisiteError(w, "updating OA prerequisite", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prerequisite)
+}
+
+// DeleteOAPrerequisite godoc
+// @Summary Delete an OA prerequisite
+// @Description Teachers and admins.
+// @Tags Educational
+// @Param id path int true "Prerequisite ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/oa-prerequisites/{id} [delete]
+func DeleteOAPrerequisite(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid prerequisite id"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := services.DeleteOAPrerequisite(uint(id)); err != nil {
+		writePrerequisiteError(w, "deleting OA prerequisite", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ImportOAPrerequisites godoc
+// @Summary Bulk import OA prerequisites from CSV
+// @Description CSV with header: oa, prerequisito (OA code within the materia or numeric OA ID), optional oa_bloom_nivel, prerequisito_bloom_nivel (1-6) and nota. Sent as the raw body (text/csv) or as the "file" field of a multipart form. All or nothing: with any invalid row or cycle nothing is saved and the row errors are returned with 400. Existing edges are skipped. Teachers and admins.
+// @Tags Educational
+// @Accept text/csv
+// @Produce json
+// @Param id path int true "Materia ID"
+// @Success 200 {object} services.OAPrerequisiteImportResult
+// @Failure 400 {object} services.OAPrerequisiteImportResult
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/materias/{id}/prerequisites/import [post]
+func ImportOAPrerequisites(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	materiaID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid materia id"}`, http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPrerequisiteCSVBytes)
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, `{"error":"missing file field"}`, http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	result, err := services.ImportOAPrerequisitesCSV(userID, uint(materiaID), body)
+	if err != nil {
+		writePrerequisiteError(w, "importing OA prerequisites", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(result.Errores) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetCurriculumMap godoc
+// @Summary Curriculum map of a materia
+// @Description Prerequisite graph of the materia's active OAs (plus prerequisites from other materias, marked externo), in topological order, annotated with the student's mastery per Bloom objective and whether each OA's prerequisites are met. Teachers and admins can pass user_id to see a student's map.
+// @Tags Educational
+// @Produce json
+// @Param id path int true "Materia ID"
+// @Param user_id query int false "Student ID (teachers and admins)"
+// @Success 200 {object} services.CurriculumMap
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/materias/{id}/curriculum-map [get]
+func GetCurriculumMap(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	materiaID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid materia id"}`, http.StatusBadRequest)
+		return
+	}
+
+	if value := r.URL.Query().Get("user_id"); value != "" {
+		role, _ := middleware.GetRoleFromContext(r.Context())
+		if role != "admin" && role != "docente" {
+			http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+		studentID, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			http.Error(w, `{"error":"invalid user_id"}`, http.StatusBadRequest)
+			return
+		}
+		userID = uint(studentID)
+	}
+
+	curriculumMap, err := services.GetCurriculumMap(userID, uint(materiaID))
+	if err != nil {
+		writePrerequisiteError(w, "building curriculum map", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(curriculumMap)
+}
+
+func writePrerequisiteError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case errors.Is(err, services.ErrOAPrerequisiteNotFound):
+		http.Error(w, `{"error":"prerequisite not found"}`, http.StatusNotFound)
+	case errors.Is(err, services.ErrMateriaNotFound):
+		http.Error(w, `{"error":"materia not found"}`, http.StatusNotFound)
+	case errors.Is(err, services.ErrOAPrerequisiteCycle):
+		errorJSON, _ := json.Marshal(map[string]string{"error": err.Error()})
+		http.Error(w, string(errorJSON), http.StatusConflict)
+	case errors.Is(err, services.ErrInvalidOAPrerequisite):
+		errorJSON, _ := json.Marshal(map[string]string{"error": err.Error()})
+		http.Error(w, string(errorJSON), http.StatusBadRequest)
+	default:
+		log.Printf("Error %s: %v", action, err)
+		http.Error(w, `{"error":"prerequisite request failed"}`, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	authmiddleware "github.com/platanus-hack-25/lumera_app/internal/middleware"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"github.com/platanus-hack-25/lumera_app/internal/services"
+)
+
+// StartPlacement godoc
+// @Summary Start a placement diagnostic
+// @Description Diagnostic across every materia of the student's curso. OAs are sampled stratified by materia and categoria within a question and time budget (defaults from PLACEMENT_MAX_QUESTIONS and PLACEMENT_MAX_MINUTES), and each materia gets its own diagnostic session.
+// @Tags Diagnostic
+// @Accept json
+// @Produce json
+// @Param body body services.PlacementInput false "Budget"
+// @Success 201 {object} services.PlacementDetail
+// @Failure 400 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/placement-diagnostics [post]
+func StartPlacement(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authmiddleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input services.PlacementInput
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	placement, err := services.StartPlacement(userID, input)
+	if err != nil {
+		writePlacementError(w, userID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(placement)
+}
+
+// GetPlacement godoc
+// @Summary Get a placement diagnostic
+// @Description Placement with its per-materia diagnostic sessions and the remaining question and time budget.
+// @Tags Diagnostic
+// @Produce json
+// @Param id path int true "Placement ID"
+// @Success 200 {object} services.PlacementDetail
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/placement-diagnostics/{id} [get]
+func GetPlacement(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authmiddleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	placementID, ok := parsePlacementID(w, r)
+	if !ok {
+		return
+	}
+
+	placement, err := services.GetPlacement(userID, placementID)
+	if err != nil {
+		writePlacementError(w, userID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(placement)
+}
+
+// GetPlacementNextQuestion godoc
+// @Summary Get next placement question
+// @Description Next question of the placement plan, interleaving materias. The answer is submitted to /api/diagnostic-sessions/{diagnostic_session_id}/answer, which adapts the Bloom level of that materia. Returns 404 once the plan or the budget is exhausted.
+// @Tags Diagnostic
+// @Produce json
+// @Param id path int true "Placement ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/placement-diagnostics/{id}/next-question [get]
+func GetPlacementNextQuestion(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authmiddleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	placementID, ok := parsePlacementID(w, r)
+	if !ok {
+		return
+	}
+
+	// A question served and not answered yet (closed tab, resume) is served again
+	placement, err := services.GetPlacement(userID, placementID)
+	if err != nil {
+		writePlacementError(w, userID, err)
+		return
+	}
+	if placement.Estado == "en_progreso" && !placement.Progreso.Agotado {
+		for _, session := range placement.Sessions {
+			var question models.Question
+			if session.PreguntaPendienteID == nil || db.DB.Preload("OABloomObjective").First(&question, *session.PreguntaPendienteID).Error != nil {
+				continue
+			}
+			item := services.PlacementItem{MateriaID: session.MateriaID, OAID: question.OABloomObjective.OAID, SessionID: session.ID}
+			var plan []services.PlacementItem
+			json.Unmarshal(placement.Plan, &plan)
+			for _, planned := range plan {
+				if planned.SessionID == session.ID && planned.OAID == item.OAID {
+					item.Categoria = planned.Categoria
+				}
+			}
+			writePlacementQuestion(w, session, item, question, placement.Progreso)
+			return
+		}
+	}
+
+	// The plan has at most 100 OAs, so this bounds the OAs skipped for lack of questions
+	for attempt := 0; attempt < 100; attempt++ {
+		item, progress, err := services.NextPlacementItem(userID, placementID)
+		if err != nil {
+			writePlacementError(w, userID, err)
+			return
+		}
+
+		var session models.DiagnosticSession
+		if err := db.DB.First(&session, item.SessionID).Error; err != nil {
+			writePlacementError(w, userID, err)
+			return
+		}
+
+		question, err := pickDiagnosticQuestion(&session, item.OAID)
+		if err != nil {
+			// No question at the current Bloom level: skip the OA and move on with the plan
+			markDiagnosticOAEvaluated(&session, item.OAID)
+			continue
+		}
+
+		writePlacementQuestion(w, session, *item, *question, *progress)
+		return
+	}
+	writePlacementError(w, userID, services.ErrPlacementExhausted)
+}
+
+// writePlacementQuestion writes a placement question without validation_data
+func writePlacementQuestion(w http.ResponseWriter, session models.DiagnosticSession, item services.PlacementItem, question models.Question, progress services.PlacementProgress) {
+	var strategy models.AdaptiveStrategy
+	json.Unmarshal(session.Estrategia, &strategy)
+
+	response := map[string]interface{}{
+		"id":                    question.ID,
+		"oa_bloom_objective_id": question.OABloomObjectiveID,
+		"tipo":                  question.Tipo,
+		"question_data":         question.QuestionData,
+		"diagnostic_session_id": session.ID,
+		"materia_id":            item.MateriaID,
+		"categoria":             item.Categoria,
+		"question_number":       progress.PreguntasRespondidas + 1,
+		"total_questions":       progress.PreguntasPlanificadas,
+		"current_bloom_level":   strategy.NivelBloomActual,
+		"minutos_restantes":     progress.MinutosRestantes,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// CompletePlacement godoc
+// @Summary Complete a placement diagnostic
+// @Description Completes the diagnostic session of every answered materia and builds the consolidated placement report. In the same step it seeds StudentOAProgress below the mastered Bloom levels and profile_data.conocimiento_previo (values set by a teacher are kept).
+// @Tags Diagnostic
+// @Produce json
+// @Param id path int true "Placement ID"
+// @Success 200 {object} services.PlacementReport
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/placement-diagnostics/{id}/complete [post]
+func CompletePlacement(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authmiddleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	placementID, ok := parsePlacementID(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := services.CompletablePlacement(userID, placementID)
+	if err != nil {
+		writePlacementError(w, userID, err)
+		return
+	}
+	for i := range sessions {
+		if sessions[i].PreguntasTotales == 0 || sessions[i].Estado == "completado" {
+			continue
+		}
+		if _, _, err := completeDiagnosticSession(&sessions[i]); err != nil {
+			writePlacementError(w, userID, err)
+			return
+		}
+	}
+
+	report, err := services.FinishPlacement(userID, placementID)
+	if err != nil {
+		writePlacementError(w, userID, err)
+		return
+	}
+
+	// Gamification events (async): one diagnostic reward for the whole placement
+	go gamificationService.AddXP(userID, 100, "diagnostic_completed")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// GetPlacementReport godoc
+// @Summary Get a placement report
+// @Description Consolidated report of a completed placement: level per materia and categoria, evaluated OAs, prior knowledge written to the profile and objectives seeded by inference.
+// @Tags Diagnostic
+// @Produce json
+// @Param id path int true "Placement ID"
+// @Success 200 {object} services.PlacementReport
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/placement-diagnostics/{id}/report [get]
+func GetPlacementReport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authmiddleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	placementID, ok := parsePlacementID(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := services.GetPlacementReport(userID, placementID)
+	if err != nil {
+		writePlacementError(w, userID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func parsePlacementID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	placementID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid placement id"}`, http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(placementID), true
+}
+
+func writePlacementError(w http.ResponseWriter, userID uint, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPlacement):
+		errorJSON, _ := json.Marshal(map[string]string{"error": err.Error()})
+		http.Error(w, string(errorJSON), http.StatusBadRequest)
+	case errors.Is(err, services.ErrPlacementNotFound):
+		http.Error(w, `{"error":"placement diagnostic not found"}`, http.StatusNotFound)
+	case errors.Is(err, services.ErrPlacementExhausted):
+		http.Error(w, `{"error":"no more questions available"}`, http.StatusNotFound)
+	case errors.Is(err, services.ErrPlacementCompleted):
+		http.Error(w, `{"error":"placement diagnostic already completed"}`, http.StatusConflict)
+	default:
+		log.Printf("Error handling placement diagnostic for user %d: %v", userID, err)
+		http.Error(w, `{"error":"failed to process placement diagnostic"}`, http.StatusInternalServerError)
+	}
+}
@@ -3,6 +3,8 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"log"
+	"math"
 	"net/http"
 	"time"
 
@@ -29,7 +31,9 @@ type StartPracticeRequest struct {
 // @Produce json
 // @Param practice body StartPracticeRequest true "Practice session data"
 // @Success 201 {object} models.PracticeSession
+// @Success 200 {object} models.PracticeSession "Open session of the same objective, resumed (SESSION_CONCURRENCY_POLICY=reanudar)"
 // @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "Open session limit reached (SESSION_CONCURRENCY_POLICY=rechazar)"
 // @Security BearerAuth
 // @Router /api/practice-sessions [post]
 func StartPractice(w http.ResponseWriter, r *http.Request) {
@@ -52,6 +56,23 @@ func StartPractice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Concurrent-session policy: may resume the open practice of this objective or abandon older ones
+	resumedID, err := services.ReservePracticeSlot(userID, oaBloomObjective.ID)
+	if err != nil {
+		writeSessionStateError(w, userID, err)
+		return
+	}
+	if resumedID != nil {
+		var resumed models.PracticeSession
+		if err := db.DB.First(&resumed, *resumedID).Error; err != nil {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resumed)
+		return
+	}
+
 	// Set default number of questions
 	numPreguntas := 10
 	if req.NumeroPreguntas != nil && *req.NumeroPreguntas > 0 {
@@ -78,6 +99,7 @@ func StartPractice(w http.ResponseWriter, r *http.Request) {
 		Estado:             "en_progreso",
 		Estrategia:         datatypes.JSON(strategyJSON),
 		StartedAt:          time.Now(),
+		UltimaActividadAt:  time.Now(),
 	}
 
 	if err := db.DB.Create(&session).Error; err != nil {
@@ -96,7 +118,7 @@ func StartPractice(w http.ResponseWriter, r *http.Request) {
 // @Tags Practice
 // @Produce json
 // @Param oa_id query int false "Filter by OA ID"
-// @Param estado query string false "Filter by estado (en_progreso, completado)"
+// @Param estado query string false "Filter by estado (en_progreso, pausado, abandonado, expirado, completado)"
 // @Success 200 {array} models.PracticeSession
 // @Failure 500 {string} string "Server error"
 // @Security BearerAuth
@@ -148,6 +170,11 @@ func GetPracticeNextQuestion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := services.CheckSessionActive(session.Estado); err != nil {
+		writeSessionStateError(w, session.UserID, err)
+		return
+	}
+
 	// Check if session is complete
 	if session.PreguntasRespondidas >= session.NumeroPreguntas {
 		http.Error(w, "Session already completed", http.StatusBadRequest)
@@ -166,6 +193,12 @@ func GetPracticeNextQuestion(w http.ResponseWriter, r *http.Request) {
 	var question models.Question
 	var err error
 
+	// A question served and not answered yet (closed tab, resume) is served again
+	if session.PreguntaPendienteID != nil && db.DB.First(&question, *session.PreguntaPendienteID).Error == nil {
+		writePracticeQuestion(w, session, strategy, question)
+		return
+	}
+
 	// Get OA ID from the OABloomObjective
 	var oaBloomObjective models.OABloomObjective
 	if err := db.DB.First(&oaBloomObjective, session.OABloomObjectiveID).Error; err != nil {
@@ -198,15 +231,26 @@ func GetPracticeNextQuestion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Prepare response without validation_data
+	db.DB.Model(&session).Updates(map[string]interface{}{
+		"pregunta_pendiente_id": question.ID,
+		"pistas_usadas":         0,
+		"ultima_actividad_at":   time.Now(),
+	})
+
+	writePracticeQuestion(w, session, strategy, question)
+}
+
+// writePracticeQuestion writes a practice question without validation_data
+func writePracticeQuestion(w http.ResponseWriter, session models.PracticeSession, strategy models.PracticeStrategy, question models.Question) {
 	response := map[string]interface{}{
-		"id":                   question.ID,
+		"id":                    question.ID,
 		"oa_bloom_objective_id": question.OABloomObjectiveID,
-		"tipo":                 question.Tipo,
-		"question_data":        question.QuestionData,
-		"question_number":      session.PreguntasRespondidas + 1,
-		"total_questions":      session.NumeroPreguntas,
-		"current_bloom_level":  strategy.NivelBloomActual,
+		"tipo":                  question.Tipo,
+		"question_data":         question.QuestionData,
+		"question_number":       session.PreguntasRespondidas + 1,
+		"total_questions":       session.NumeroPreguntas,
+		"current_bloom_level":   strategy.NivelBloomActual,
+		"pistas_usadas":         session.PistasUsadas,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -215,7 +259,7 @@ func GetPracticeNextQuestion(w http.ResponseWriter, r *http.Request) {
 
 // SubmitPracticeAnswer godoc
 // @Summary Submit an answer during practice
-// @Description Submit an answer and get validation result with adaptive difficulty adjustment
+// @Description Submit an answer and get validation result with adaptive difficulty adjustment. When a wrong multiple_choice answer picks a distractor tagged with misconceptions, conceptos_erroneos explains them ("Probablemente confundes X con Y.").
 // @Tags Practice
 // @Accept json
 // @Produce json
@@ -234,6 +278,11 @@ func SubmitPracticeAnswer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := services.CheckSessionActive(session.Estado); err != nil {
+		writeSessionStateError(w, session.UserID, err)
+		return
+	}
+
 	var req SubmitAnswerRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -254,15 +303,28 @@ func SubmitPracticeAnswer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Answers faster than the question's normative threshold are rapid guesses
+	rapidGuess := services.IsRapidGuess(question, req.TiempoSegundos)
+
+	// Hints revealed for the pending question lower the score (see services.HintWeight)
+	hintsUsed := 0
+	answeredPending := session.PreguntaPendienteID != nil && *session.PreguntaPendienteID == req.QuestionID
+	if answeredPending {
+		hintsUsed = session.PistasUsadas
+	}
+	score *= services.HintWeight(hintsUsed)
+
 	// Save answer
 	answer := models.PracticeAnswer{
-		SessionID:      session.ID,
-		QuestionID:     req.QuestionID,
-		BloomLevelID:   question.OABloomObjective.BloomLevelID,
-		UserAnswer:     req.UserAnswer,
-		IsCorrect:      &isCorrect,
-		Score:          &score,
-		TiempoSegundos: req.TiempoSegundos,
+		SessionID:        session.ID,
+		QuestionID:       req.QuestionID,
+		BloomLevelID:     question.OABloomObjective.BloomLevelID,
+		UserAnswer:       req.UserAnswer,
+		IsCorrect:        &isCorrect,
+		Score:            &score,
+		TiempoSegundos:   req.TiempoSegundos,
+		AdivinanzaRapida: rapidGuess,
+		PistasUsadas:     hintsUsed,
 	}
 
 	if err := db.DB.Create(&answer).Error; err != nil {
@@ -270,11 +332,36 @@ func SubmitPracticeAnswer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Update the mastery posterior of the question's objective (answers pending manual grading are not traced)
+	var trace *services.KnowledgeTrace
+	if err == nil {
+		weight := services.AnswerWeight(rapidGuess) * services.HintEvidenceWeight(hintsUsed, isCorrect)
+		trace, err = services.TraceAnswer(session.UserID, question.OABloomObjectiveID, isCorrect, weight)
+		if err != nil {
+			log.Printf("Error tracing practice answer of user %d: %v", session.UserID, err)
+		}
+	}
+
+	// Misconceptions behind the chosen distractor (a rapid guess says nothing about what the student believes)
+	var misconceptions []services.MisconceptionFeedback
+	if !rapidGuess {
+		observed, err := services.ObserveMisconceptions(session.UserID, models.MisconceptionOrigenPractica, session.ID, &question, req.UserAnswer, isCorrect)
+		if err != nil {
+			log.Printf("Error recording misconceptions of user %d: %v", session.UserID, err)
+		}
+		misconceptions = observed
+	}
+
 	// Update session stats
 	session.PreguntasRespondidas++
 	if isCorrect {
 		session.PreguntasCorrectas++
 	}
+	if rapidGuess {
+		session.AdivinanzasRapidas++
+	}
+	session.Compromiso = services.SessionCompromiso(session.PreguntasRespondidas, session.AdivinanzasRapidas)
+	session.UltimaActividadAt = time.Now()
 
 	// Update adaptive strategy
 	var strategy models.PracticeStrategy
@@ -282,7 +369,23 @@ func SubmitPracticeAnswer(w http.ResponseWriter, r *http.Request) {
 
 	questionBloomLevel := int(question.OABloomObjective.BloomLevelID)
 
-	if isCorrect {
+	if rapidGuess {
+		// A rapid guess says nothing about ability: keep the level, streaks and per-level counts
+		if isCorrect {
+			strategy.PatronRespuestas = append(strategy.PatronRespuestas, "C")
+		} else {
+			strategy.PatronRespuestas = append(strategy.PatronRespuestas, "I")
+		}
+	} else if isCorrect && hintsUsed > 0 {
+		// A correct answer after hints ends a failure streak but does not count toward a level up
+		strategy.FallosConsecutivos = 0
+		strategy.PatronRespuestas = append(strategy.PatronRespuestas, "C")
+
+		if strategy.AciertosConPistas == nil {
+			strategy.AciertosConPistas = make(map[int]int)
+		}
+		strategy.AciertosConPistas[questionBloomLevel]++
+	} else if isCorrect {
 		strategy.AciertosConsecutivos++
 		strategy.FallosConsecutivos = 0
 		strategy.PatronRespuestas = append(strategy.PatronRespuestas, "C")
@@ -319,7 +422,26 @@ func SubmitPracticeAnswer(w http.ResponseWriter, r *http.Request) {
 	// Save updated strategy
 	strategyJSON, _ := json.Marshal(strategy)
 	session.Estrategia = datatypes.JSON(strategyJSON)
-	db.DB.Save(&session)
+
+	// Only the fields changed here are written: a full Save would undo a hint revealed meanwhile
+	if err := db.DB.Model(&session).Updates(map[string]interface{}{
+		"preguntas_respondidas": session.PreguntasRespondidas,
+		"preguntas_correctas":   session.PreguntasCorrectas,
+		"adivinanzas_rapidas":   session.AdivinanzasRapidas,
+		"compromiso":            session.Compromiso,
+		"ultima_actividad_at":   session.UltimaActividadAt,
+		"estrategia":            session.Estrategia,
+	}).Error; err != nil {
+		log.Printf("Error updating practice session %d: %v", session.ID, err)
+	}
+	// The pending question and its hints are cleared only while it is still the one answered
+	if answeredPending {
+		if err := db.DB.Model(&models.PracticeSession{}).
+			Where("id = ? AND pregunta_pendiente_id = ?", session.ID, req.QuestionID).
+			Updates(map[string]interface{}{"pregunta_pendiente_id": nil, "pistas_usadas": 0}).Error; err != nil {
+			log.Printf("Error clearing pending question of practice session %d: %v", session.ID, err)
+		}
+	}
 
 	response := map[string]interface{}{
 		"is_correct":           isCorrect,
@@ -329,6 +451,19 @@ func SubmitPracticeAnswer(w http.ResponseWriter, r *http.Request) {
 		"total_preguntas":      session.NumeroPreguntas,
 		"is_complete":          session.PreguntasRespondidas >= session.NumeroPreguntas,
 	}
+	if trace != nil {
+		response["dominio"] = trace
+	}
+	if len(misconceptions) > 0 {
+		response["conceptos_erroneos"] = misconceptions
+	}
+	response["adivinanza_rapida"] = rapidGuess
+	if hintsUsed > 0 {
+		response["pistas_usadas"] = hintsUsed
+	}
+	if engagement, err := services.PracticeEngagement(session); err == nil {
+		response["compromiso"] = engagement
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -357,6 +492,13 @@ func CompletePracticeSession(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Session already completed", http.StatusBadRequest)
 		return
 	}
+	// Paused sessions can be completed; abandoned and expired ones cannot
+	if session.Estado != "pausado" {
+		if err := services.CheckSessionActive(session.Estado); err != nil {
+			writeSessionStateError(w, session.UserID, err)
+			return
+		}
+	}
 
 	// Parse strategy
 	var strategy models.PracticeStrategy
@@ -375,6 +517,16 @@ func CompletePracticeSession(w http.ResponseWriter, r *http.Request) {
 	session.Estado = "completado"
 	session.BloomLevelFinal = &finalBloomLevel
 	session.CompletedAt = &now
+	session.UltimaActividadAt = now
+	session.PausadoAt = nil
+	session.PreguntaPendienteID = nil
+	session.PistasUsadas = 0
+	session.Compromiso = services.SessionCompromiso(session.PreguntasRespondidas, session.AdivinanzasRapidas)
+
+	hintsUsed := 0
+	for _, answer := range session.Answers {
+		hintsUsed += answer.PistasUsadas
+	}
 
 	// Create result summary
 	resultado := map[string]interface{}{
@@ -385,8 +537,12 @@ func CompletePracticeSession(w http.ResponseWriter, r *http.Request) {
 		"preguntas_totales":   session.PreguntasRespondidas,
 		"preguntas_correctas": session.PreguntasCorrectas,
 		"aciertos_por_nivel":  strategy.AciertosPorNivel,
+		"aciertos_con_pistas": strategy.AciertosConPistas,
 		"fallos_por_nivel":    strategy.FallosPorNivel,
 		"patron_respuestas":   strategy.PatronRespuestas,
+		"adivinanzas_rapidas": session.AdivinanzasRapidas,
+		"compromiso":          session.Compromiso,
+		"pistas_usadas":       hintsUsed,
 	}
 	resultadoJSON, _ := json.Marshal(resultado)
 	session.Resultado = datatypes.JSON(resultadoJSON)
@@ -397,16 +553,27 @@ func CompletePracticeSession(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update user's progress for this OA-Bloom objective
-	accuracy := float64(session.PreguntasCorrectas) / float64(session.PreguntasRespondidas) * 100
-	if err := updateUserProgress(session.UserID, session.OABloomObjectiveID, finalBloomLevel, accuracy, session.PreguntasCorrectas, session.PreguntasRespondidas); err != nil {
+	if err := updateUserProgress(session.UserID, session.OABloomObjectiveID, finalBloomLevel, session.PreguntasCorrectas, session.PreguntasRespondidas); err != nil {
 		// Log error but don't fail the request
 		http.Error(w, "Failed to update progress: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Award XP and Coins for completing practice
-	xpEarned := session.PreguntasCorrectas * 5 // 5 XP per correct answer
-	coinsEarned := session.PreguntasCorrectas / 5 // 1 coin per 5 correct answers
+	// Award XP and Coins for completing practice; correct rapid guesses and answers after hints earn a
+	// fraction of the XP, and rapid guesses no coins
+	effortfulCorrect := 0
+	xp := 0.0
+	for _, answer := range session.Answers {
+		if answer.IsCorrect == nil || !*answer.IsCorrect {
+			continue
+		}
+		if !answer.AdivinanzaRapida {
+			effortfulCorrect++
+		}
+		xp += 5 * services.AnswerWeight(answer.AdivinanzaRapida) * services.HintWeight(answer.PistasUsadas) // 5 XP per correct answer
+	}
+	xpEarned := int(math.Round(xp))
+	coinsEarned := effortfulCorrect / 5 // 1 coin per 5 correct answers
 
 	gamificationService := services.NewGamificationService()
 
@@ -442,6 +609,9 @@ func CompletePracticeSession(w http.ResponseWriter, r *http.Request) {
 			"streak_result":  streakResult,
 		},
 	}
+	if engagement, err := services.PracticeEngagement(session); err == nil {
+		response["compromiso"] = engagement
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -499,84 +669,12 @@ func calculateFinalBloomLevel(
 	return finalLevel
 }
 
-// updateUserProgress updates or creates student progress for an OA-Bloom objective
-func updateUserProgress(userID uint, oaBloomObjectiveID uint, bloomLevel int, accuracy float64, correctas int, totales int) error {
-	// Determine state based on accuracy
-	var estado string
-	if accuracy >= 80 {
-		estado = "dominado"
-	} else if accuracy >= 60 {
-		estado = "logrado"
-	} else if totales > 0 {
-		estado = "en_proceso"
-	} else {
-		estado = "no_iniciado"
-	}
-
-	// Find or create progress record
-	var progress models.StudentOAProgress
-	result := db.DB.Where("user_id = ? AND oa_bloom_objective_id = ?", userID, oaBloomObjectiveID).First(&progress)
-
-	now := time.Now()
-	porcentajeLogro := int(accuracy)
-
-	if result.Error != nil {
-		// Create new progress record
-		progress = models.StudentOAProgress{
-			UserID:              userID,
-			OABloomObjectiveID:  oaBloomObjectiveID,
-			Estado:              estado,
-			PorcentajeLogro:     porcentajeLogro,
-			Intentos:            1,
-			UltimaActividadFecha: &now,
-		}
-		if err := db.DB.Create(&progress).Error; err != nil {
-			return err
-		}
-	} else {
-		// Update existing record - only if performance improved or state changed
-		shouldUpdate := false
-
-		// Update if accuracy improved
-		if porcentajeLogro > progress.PorcentajeLogro {
-			progress.PorcentajeLogro = porcentajeLogro
-			shouldUpdate = true
-		}
-
-		// Always update state if it changed
-		if estado != progress.Estado {
-			progress.Estado = estado
-			shouldUpdate = true
-		}
-
-		// Always increment attempts and update last activity
-		progress.Intentos++
-		progress.UltimaActividadFecha = &now
-		shouldUpdate = true
-
-		if shouldUpdate {
-			if err := db.DB.Save(&progress).Error; err != nil {
-				return err
-			}
-		}
-	}
-
-	// Create history record
-	puntajeObtenido := float64(correctas)
-	puntajeMaximo := float64(totales)
-
-	history := models.StudentOAHistory{
-		UserID:             userID,
-		OABloomObjectiveID: oaBloomObjectiveID,
-		Estado:             estado,
-		PorcentajeLogro:    &porcentajeLogro,
-		TipoEvento:         "practica",
-		PuntajeObtenido:    &puntajeObtenido,
-		PuntajeMaximo:      &puntajeMaximo,
-		Notas:              fmt.Sprintf("Bloom level %d - %d/%d correctas", bloomLevel, correctas, totales),
-	}
-
-	return db.DB.Create(&history).Error
+// updateUserProgress records a completed session in the student's progress for an OA-Bloom objective.
+// Estado and porcentaje_logro come from the knowledge tracing posterior updated on every answer,
+// so a single lucky or bad session no longer overwrites them.
+func updateUserProgress(userID uint, oaBloomObjectiveID uint, bloomLevel int, correctas int, totales int) error {
+	notas := fmt.Sprintf("Bloom level %d - %d/%d correctas", bloomLevel, correctas, totales)
+	return services.RecordTracedProgress(userID, oaBloomObjectiveID, "practica", correctas, totales, notas)
 }
 
 // Helper functions
@@ -9,6 +9,7 @@ import (
 	"github.com/platanus-hack-25/lumera_app/internal/db"
 	"github.com/platanus-hack-25/lumera_app/internal/middleware"
 	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"github.com/platanus-hack-25/lumera_app/pkg/moderation"
 	"gorm.io/datatypes"
 )
 
@@ -76,6 +77,11 @@ func CreateProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Intereses y profesión soñada llegan a los prompts de contenido
+	if !checkProfileData(w, r, req.UserID, req.ProfileData) {
+		return
+	}
+
 	// Check if profile already exists
 	var existing models.StudentProfile
 	if err := db.DB.Where("user_id = ?", req.UserID).First(&existing).Error; err == nil {
@@ -136,6 +142,10 @@ func UpdateProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !checkProfileData(w, r, uint(userID), req.ProfileData) {
+		return
+	}
+
 	// Find profile
 	var profile models.StudentProfile
 	if err := db.DB.Where("user_id = ?", userID).First(&profile).Error; err != nil {
@@ -201,3 +211,15 @@ func ExportProfiles(w http.ResponseWriter, r *http.Request) {
 		"exported_by": userID,
 	})
 }
+
+// checkProfileData revisa con la moderación los textos libres del perfil (responde 422 si se marcan)
+func checkProfileData(w http.ResponseWriter, r *http.Request, userID uint, profileData datatypes.JSON) bool {
+	if len(profileData) == 0 {
+		return true
+	}
+	var data interface{}
+	if err := json.Unmarshal(profileData, &data); err != nil {
+		return true
+	}
+	return checkStudentInput(w, r, userID, models.ModerationEntidadPerfil, nil, moderation.CollectText(data))
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/platanus-hack-25/lumera_app/internal/middleware"
+	"github.com/platanus-hack-25/lumera_app/internal/services"
+)
+
+// ListPromptTemplates godoc
+// @Summary List prompt templates
+// @Description Loaded prompt template versions with their source (embedded, directory or database) and A/B weight. Admin only.
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} services.PromptTemplateInfo
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/admin/prompts [get]
+func ListPromptTemplates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(services.ListPromptTemplates())
+}
+
+// ReloadPromptTemplates godoc
+// @Summary Reload prompt templates
+// @Description Reloads templates from PROMPT_TEMPLATES_DIR and the prompt_templates table without a redeploy. If a template fails to compile the previous ones stay active. Admin only.
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} services.PromptTemplateInfo
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/admin/prompts/reload [post]
+func ReloadPromptTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := services.ReloadPromptTemplates()
+	if err != nil {
+		log.Printf("Error reloading prompt templates: %v", err)
+		errorJSON, _ := json.Marshal(map[string]string{"error": err.Error()})
+		http.Error(w, string(errorJSON), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(templates)
+}
+
+// SavePromptTemplate godoc
+// @Summary Create or edit a prompt template version
+// @Description Stores a text/template version in the database (overriding the file with the same name and version) and reloads the templates. Without contenido only the A/B weight of an existing version changes; weight 0 stops assigning it. Admin only.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param nombre path string true "plan_structure, example_personalization or a component type"
+// @Param version path string true "Version ID (e.g. v2)"
+// @Param request body services.PromptTemplateInput true "Template content and weight"
+// @Success 200 {object} models.PromptTemplate
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/admin/prompts/{nombre}/{version} [put]
+func SavePromptTemplate(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req services.PromptTemplateInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	saved, err := services.SavePromptTemplate(adminID, chi.URLParam(r, "nombre"), chi.URLParam(r, "version"), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownPromptTemplate):
+			http.Error(w, `{"error":"unknown prompt template"}`, http.StatusNotFound)
+		case errors.Is(err, services.ErrInvalidPromptTemplate):
+			errorJSON, _ := json.Marshal(map[string]string{"error": err.Error()})
+			http.Error(w, string(errorJSON), http.StatusBadRequest)
+		default:
+			log.Printf("Error saving prompt template: %v", err)
+			http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(saved)
+}
+
+// GetPromptExperimentReport godoc
+// @Summary Prompt experiment outcomes
+// @Description Compares the versions of a prompt template for plans created between two dates: completion, checkpoint pass rate, ratings and accuracy in later practice sessions of the same objective. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param nombre path string true "plan_structure or a component type"
+// @Param from query string false "Start date YYYY-MM-DD (default: 30 days ago)"
+// @Param to query string false "End date YYYY-MM-DD, inclusive (default: today)"
+// @Success 200 {object} services.PromptExperimentReport
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/admin/prompts/{nombre}/experiment [get]
+func GetPromptExperimentReport(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from := today.AddDate(0, 0, -30)
+	to := today
+
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", fromStr, now.Location())
+		if err != nil {
+			http.Error(w, `{"error":"invalid from date, expected YYYY-MM-DD"}`, http.StatusBadRequest)
+			return
+		}
+		from = parsed
+	}
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", toStr, now.Location())
+		if err != nil {
+			http.Error(w, `{"error":"invalid to date, expected YYYY-MM-DD"}`, http.StatusBadRequest)
+			return
+		}
+		to = parsed
+	}
+
+	// "to" is inclusive for callers
+	report, err := services.GetPromptExperimentReport(chi.URLParam(r, "nombre"), from, to.AddDate(0, 0, 1))
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownPromptTemplate) {
+			http.Error(w, `{"error":"unknown prompt template"}`, http.StatusNotFound)
+			return
+		}
+		log.Printf("Error building prompt experiment report: %v", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -2,11 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/platanus-hack-25/lumera_app/internal/db"
 	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"github.com/platanus-hack-25/lumera_app/internal/services"
 	"gorm.io/datatypes"
 )
 
@@ -105,7 +108,7 @@ func GetQuestion(w http.ResponseWriter, r *http.Request) {
 
 // CreateQuestion godoc
 // @Summary Create a new question
-// @Description Create a new question in the bank (auth required)
+// @Description Create a new question in the bank (auth required). multiple_choice distractors can be tagged in validation_data.conceptos_erroneos ({"B": ["FRAC-SUMA"]}) with active codes of the materia's misconception catalog.
 // @Tags Questions
 // @Accept json
 // @Produce json
@@ -126,6 +129,9 @@ func CreateQuestion(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Validation error: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	if !validateQuestionMisconceptions(w, &question) {
+		return
+	}
 
 	if err := db.DB.Create(&question).Error; err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -169,16 +175,40 @@ func UpdateQuestion(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Validation error: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	if !validateQuestionMisconceptions(w, &question) {
+		return
+	}
 
 	if err := db.DB.Save(&question).Error; err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	// Generated hints were written for the old version of the question
+	if err := services.ClearGeneratedHints(question.ID); err != nil {
+		log.Printf("Error clearing generated hints of question %d: %v", question.ID, err)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(question)
 }
 
+// validateQuestionMisconceptions checks the distractor tags of validation_data.conceptos_erroneos
+// against the materia's active misconception catalog
+func validateQuestionMisconceptions(w http.ResponseWriter, question *models.Question) bool {
+	err := services.ValidateQuestionMisconceptions(question)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, services.ErrInvalidMisconception):
+		http.Error(w, "Validation error: "+err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Error validating misconception tags of question %d: %v", question.ID, err)
+		http.Error(w, `{"error":"failed to validate misconception tags"}`, http.StatusInternalServerError)
+	}
+	return false
+}
+
 // ValidateAnswerRequest represents the request to validate an answer
 type ValidateAnswerRequest struct {
 	UserAnswer datatypes.JSON `json:"user_answer"`
@@ -2,35 +2,24 @@ package handlers
 
 import (
 	"encoding/json"
-	"math/rand"
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
 	"time"
 
-	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/go-chi/chi/v5"
 	authmiddleware "github.com/platanus-hack-25/lumera_app/internal/middleware"
 	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"github.com/platanus-hack-25/lumera_app/internal/services"
 )
 
-// DailyRecommendation represents a personalized learning recommendation
-type DailyRecommendation struct {
-	OA                 models.ObjetivoAprendizaje `json:"oa"`
-	OABloomObjective   models.OABloomObjective    `json:"oa_bloom_objective"`
-	BloomLevel         models.BloomLevel          `json:"bloom_level"`
-	RecommendationType string                     `json:"recommendation_type"` // "next_level", "practice_more", "new_topic"
-	Reason             string                     `json:"reason"`
-	Priority           int                        `json:"priority"` // 1-5, higher = more important
-	EstimatedMinutes   int                        `json:"estimated_minutes"`
-	NumeroPreguntas    int                        `json:"numero_preguntas"`
-	XPReward           int                        `json:"xp_reward"`
-	TokenReward        int                        `json:"token_reward"`
-}
-
 // GetDailyRecommendation godoc
 // @Summary Get personalized daily learning recommendation
-// @Description Get a smart recommendation based on diagnostic results, practice history, and learning progress
+// @Description Top-ranked OA-Bloom objective across every subject of the student's course, scored on mastery gaps, due reviews, prerequisite readiness, recency and subject variety. The response id identifies the logged impression (see POST /api/recommendations/{id}/click).
 // @Tags Recommendations
 // @Produce json
-// @Success 200 {object} DailyRecommendation
+// @Success 200 {object} services.Recommendation
 // @Failure 401 {object} map[string]interface{}
 // @Failure 404 {object} map[string]interface{}
 // @Security BearerAuth
@@ -42,252 +31,237 @@ func GetDailyRecommendation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user's profile to know their course/subjects
-	var profile models.StudentProfile
-	if err := db.DB.Where("user_id = ?", userID).First(&profile).Error; err != nil {
-		http.Error(w, "Profile not found", http.StatusNotFound)
+	recommendation, err := services.RecommendDailyOA(userID)
+	if err != nil {
+		writeRecommendationError(w, userID, err)
 		return
 	}
 
-	// Get user's completed diagnostic sessions to know their Bloom levels
-	var diagnosticSessions []models.DiagnosticSession
-	db.DB.Where("user_id = ? AND estado = ?", userID, "completado").
-		Order("completed_at DESC").
-		Find(&diagnosticSessions)
-
-	// Build map of materia_id -> average_bloom_level
-	diagnosticLevels := make(map[uint]float64)
-	for _, session := range diagnosticSessions {
-		var estrategia map[string]interface{}
-		if err := json.Unmarshal(session.Estrategia, &estrategia); err == nil {
-			if avgBloom, ok := estrategia["average_bloom_level"].(float64); ok {
-				diagnosticLevels[session.MateriaID] = avgBloom
-			}
-		}
-	}
-
-	// Get practice sessions to know what they've already practiced
-	var practiceSessions []models.PracticeSession
-	db.DB.Where("user_id = ? AND estado = ?", userID, "completado").
-		Order("completed_at DESC").
-		Limit(50).
-		Find(&practiceSessions)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(recommendation)
+}
 
-	// Build map of oa_id -> practice_count
-	oaPracticeCounts := make(map[uint]int)
-	for _, session := range practiceSessions {
-		oaPracticeCounts[session.OAID]++
+// ListRecommendations godoc
+// @Summary Ranked learning recommendations
+// @Description Ranked list of OA-Bloom objectives across the student's subjects, each with reason codes and the signals that scored it. Every item is logged as an impression.
+// @Tags Recommendations
+// @Produce json
+// @Param limit query int false "Number of recommendations (default 5, max 20)"
+// @Success 200 {array} services.Recommendation
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/recommendations [get]
+func ListRecommendations(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authmiddleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
 	}
 
-	// Find recommendation based on diagnostic levels and practice history
-	recommendation := findBestRecommendation(diagnosticLevels, oaPracticeCounts, userID)
-
-	if recommendation == nil {
-		// Fallback: random OA if no recommendation found
-		recommendation = getFallbackRecommendation()
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			http.Error(w, `{"error":"invalid limit"}`, http.StatusBadRequest)
+			return
+		}
+		limit = parsed
 	}
 
-	if recommendation == nil {
-		http.Error(w, "No recommendations available", http.StatusNotFound)
+	recommendations, err := services.RecommendOAs(userID, limit, models.RecommendationOrigenList)
+	if err != nil {
+		writeRecommendationError(w, userID, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(recommendation)
+	json.NewEncoder(w).Encode(recommendations)
 }
 
-// findBestRecommendation finds the best OA to recommend based on user's learning state
-func findBestRecommendation(
-	diagnosticLevels map[uint]float64,
-	oaPracticeCounts map[uint]int,
-	userID uint,
-) *DailyRecommendation {
-	// Strategy:
-	// 1. If user has diagnostics, recommend next level for a subject they've diagnosed
-	// 2. Prefer OAs they haven't practiced much
-	// 3. Prioritize by categoria and orden
-
-	// Get available subjects (Lengua for now)
-	var materia models.Materia
-	if err := db.DB.Where("nombre = ?", "Lengua y Literatura").First(&materia).Error; err != nil {
-		return nil
-	}
-
-	// Get user's bloom level for this subject
-	userBloomLevel := 2.0 // Default to level 2 (Comprensión)
-	if level, ok := diagnosticLevels[materia.ID]; ok {
-		userBloomLevel = level
+// RecordRecommendationClick godoc
+// @Summary Record a click on a recommendation
+// @Description Marks the impression as opened by the student. Repeated clicks keep the first timestamp.
+// @Tags Recommendations
+// @Param id path int true "Impression ID"
+// @Success 204
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/recommendations/{id}/click [post]
+func RecordRecommendationClick(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authmiddleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
 	}
 
-	// Get OAs for this subject, ordered by categoria and orden
-	var oas []models.ObjetivoAprendizaje
-	db.DB.Where("materia_id = ? AND activo = ?", materia.ID, true).
-		Order("orden ASC").
-		Limit(50).
-		Find(&oas)
-
-	if len(oas) == 0 {
-		return nil
+	impressionID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid recommendation id"}`, http.StatusBadRequest)
+		return
 	}
 
-	// Score each OA and pick the best one
-	type scoredOA struct {
-		oa          models.ObjetivoAprendizaje
-		score       float64
-		practiceGap int // Days since last practice
+	if err := services.RecordRecommendationClick(userID, uint(impressionID)); err != nil {
+		writeRecommendationError(w, userID, err)
+		return
 	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	var scoredOAs []scoredOA
-
-	for _, oa := range oas {
-		practiceCount := oaPracticeCounts[oa.ID]
-
-		// Calculate score (higher = better)
-		score := 100.0
-
-		// Boost if not practiced much
-		if practiceCount == 0 {
-			score += 50.0
-		} else if practiceCount < 3 {
-			score += 30.0 - float64(practiceCount*10)
-		} else {
-			score -= float64(practiceCount * 5) // Penalize over-practiced OAs
-		}
-
-		// Boost if early in the curriculum (lower orden)
-		if oa.Orden != nil && *oa.Orden <= 5 {
-			score += 20.0
-		}
-
-		// Boost specific categories
-		switch oa.Categoria {
-		case "Lectura":
-			score += 15.0
-		case "Escritura":
-			score += 10.0
+// GetRecommendationMetrics godoc
+// @Summary Recommender impressions and clicks
+// @Description Impressions, clicks, click-through rate and mean bandit reward of recommendations per type, reason code, list position and origin between two dates. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param from query string false "Start date YYYY-MM-DD (default: 30 days ago)"
+// @Param to query string false "End date YYYY-MM-DD, inclusive (default: today)"
+// @Success 200 {object} services.RecommendationMetrics
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/admin/recommendations/metrics [get]
+func GetRecommendationMetrics(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from := today.AddDate(0, 0, -30)
+	to := today
+
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", fromStr, now.Location())
+		if err != nil {
+			http.Error(w, `{"error":"invalid from date, expected YYYY-MM-DD"}`, http.StatusBadRequest)
+			return
 		}
-
-		scoredOAs = append(scoredOAs, scoredOA{
-			oa:          oa,
-			score:       score,
-			practiceGap: practiceCount,
-		})
+		from = parsed
 	}
-
-	// Sort by score (highest first)
-	if len(scoredOAs) == 0 {
-		return nil
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", toStr, now.Location())
+		if err != nil {
+			http.Error(w, `{"error":"invalid to date, expected YYYY-MM-DD"}`, http.StatusBadRequest)
+			return
+		}
+		to = parsed
 	}
 
-	bestScored := scoredOAs[0]
-	for _, s := range scoredOAs {
-		if s.score > bestScored.score {
-			bestScored = s
-		}
+	// "to" is inclusive for callers
+	metrics, err := services.GetRecommendationMetrics(from, to.AddDate(0, 0, 1))
+	if err != nil {
+		log.Printf("Error building recommendation metrics: %v", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
 	}
 
-	selectedOA := bestScored.oa
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metrics)
+}
 
-	// Get the appropriate Bloom objective for user's level
-	targetBloomLevel := int(userBloomLevel)
-	if targetBloomLevel < 1 {
-		targetBloomLevel = 1
-	}
-	if targetBloomLevel > 6 {
-		targetBloomLevel = 6
+func writeRecommendationError(w http.ResponseWriter, userID uint, err error) {
+	switch {
+	case errors.Is(err, services.ErrStudentProfileNotFound):
+		http.Error(w, "Profile not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrRecommendationNotFound):
+		http.Error(w, "No recommendations available", http.StatusNotFound)
+	default:
+		log.Printf("Error recommending for user %d: %v", userID, err)
+		http.Error(w, `{"error":"failed to build recommendations"}`, http.StatusInternalServerError)
 	}
+}
 
-	var oaBloomObjective models.OABloomObjective
-	if err := db.DB.Where("oa_id = ? AND bloom_level_id = ?", selectedOA.ID, targetBloomLevel).
-		First(&oaBloomObjective).Error; err != nil {
-		// Fallback to level 2 if specific level not found
-		db.DB.Where("oa_id = ? AND bloom_level_id = ?", selectedOA.ID, 2).
-			First(&oaBloomObjective)
+// GetRecommendationBandit godoc
+// @Summary Daily recommendation bandit state
+// @Description Active bandit policy and reward settings, and the Beta posterior of each recommendation type per candidate context learned from settled rewards. Admin only.
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} services.BanditReport
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/admin/recommendations/bandit [get]
+func GetRecommendationBandit(w http.ResponseWriter, r *http.Request) {
+	report, err := services.GetBanditReport()
+	if err != nil {
+		log.Printf("Error loading recommendation bandit: %v", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
 	}
 
-	// Get Bloom level info
-	var bloomLevel models.BloomLevel
-	db.DB.First(&bloomLevel, oaBloomObjective.BloomLevelID)
-
-	// Determine recommendation type and reason
-	recType := "new_topic"
-	reason := "Este objetivo es perfecto para comenzar tu día"
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
 
-	if bestScored.practiceGap == 0 {
-		recType = "new_topic"
-		reason = "Nuevo objetivo recomendado para ti"
-	} else if bestScored.practiceGap < 3 {
-		recType = "practice_more"
-		reason = "Continúa practicando para dominar este objetivo"
+// ReplayRecommendationPolicy godoc
+// @Summary Offline evaluation of a bandit policy
+// @Description Replays the logged daily recommendations with settled rewards between two dates under a policy (off, greedy, epsilon_greedy, thompson or random). Returns the replay reward on matching decisions, an inverse propensity scoring estimate and the reward of the logging policy. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param policy query string false "Policy to evaluate (default: thompson)"
+// @Param epsilon query number false "Exploration rate for epsilon_greedy (default 0.1)"
+// @Param seed query int false "Random seed (default 1)"
+// @Param from query string false "Start date YYYY-MM-DD (default: 30 days ago)"
+// @Param to query string false "End date YYYY-MM-DD, inclusive (default: today)"
+// @Success 200 {object} services.BanditReplayReport
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/admin/recommendations/replay [get]
+func ReplayRecommendationPolicy(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from := today.AddDate(0, 0, -30)
+	to := today
+
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", fromStr, now.Location())
+		if err != nil {
+			http.Error(w, `{"error":"invalid from date, expected YYYY-MM-DD"}`, http.StatusBadRequest)
+			return
+		}
+		from = parsed
 	}
-
-	// Calculate rewards
-	numPreguntas := 10
-	xpReward := numPreguntas * 5         // 5 XP per question
-	tokenReward := numPreguntas / 5      // 1 token per 5 questions
-	estimatedMinutes := numPreguntas * 2 // 2 minutes per question
-
-	return &DailyRecommendation{
-		OA:                 selectedOA,
-		OABloomObjective:   oaBloomObjective,
-		BloomLevel:         bloomLevel,
-		RecommendationType: recType,
-		Reason:             reason,
-		Priority:           5 - bestScored.practiceGap, // Higher priority if less practice
-		EstimatedMinutes:   estimatedMinutes,
-		NumeroPreguntas:    numPreguntas,
-		XPReward:           xpReward,
-		TokenReward:        tokenReward,
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", toStr, now.Location())
+		if err != nil {
+			http.Error(w, `{"error":"invalid to date, expected YYYY-MM-DD"}`, http.StatusBadRequest)
+			return
+		}
+		to = parsed
 	}
-}
 
-// getFallbackRecommendation returns a default recommendation if no smart recommendation found
-func getFallbackRecommendation() *DailyRecommendation {
-	// Get a random active OA from Lengua
-	var materia models.Materia
-	if err := db.DB.Where("nombre = ?", "Lengua y Literatura").First(&materia).Error; err != nil {
-		return nil
+	policy := r.URL.Query().Get("policy")
+	if policy == "" {
+		policy = services.BanditPolicyThompson
 	}
-
-	var count int64
-	db.DB.Model(&models.ObjetivoAprendizaje{}).
-		Where("materia_id = ? AND activo = ?", materia.ID, true).
-		Count(&count)
-
-	if count == 0 {
-		return nil
+	epsilon := 0.1
+	if value := r.URL.Query().Get("epsilon"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			http.Error(w, `{"error":"invalid epsilon"}`, http.StatusBadRequest)
+			return
+		}
+		epsilon = parsed
 	}
-
-	// Get random offset
-	rand.Seed(time.Now().UnixNano())
-	offset := rand.Intn(int(count))
-
-	var oa models.ObjetivoAprendizaje
-	db.DB.Where("materia_id = ? AND activo = ?", materia.ID, true).
-		Offset(offset).
-		Limit(1).
-		First(&oa)
-
-	// Get bloom objective at level 2 (Comprensión)
-	var oaBloomObjective models.OABloomObjective
-	if err := db.DB.Where("oa_id = ? AND bloom_level_id = ?", oa.ID, 2).
-		First(&oaBloomObjective).Error; err != nil {
-		return nil
+	seed := int64(1)
+	if value := r.URL.Query().Get("seed"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			http.Error(w, `{"error":"invalid seed"}`, http.StatusBadRequest)
+			return
+		}
+		seed = parsed
 	}
 
-	var bloomLevel models.BloomLevel
-	db.DB.First(&bloomLevel, 2)
-
-	return &DailyRecommendation{
-		OA:                 oa,
-		OABloomObjective:   oaBloomObjective,
-		BloomLevel:         bloomLevel,
-		RecommendationType: "new_topic",
-		Reason:             "Recomendado para tu nivel actual",
-		Priority:           3,
-		EstimatedMinutes:   20,
-		NumeroPreguntas:    10,
-		XPReward:           50,
-		TokenReward:        2,
+	// "to" is inclusive for callers
+	report, err := services.ReplayBanditPolicy(policy, epsilon, from, to.AddDate(0, 0, 1), seed)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidBanditPolicy) {
+			errorJSON, _ := json.Marshal(map[string]string{"error": err.Error()})
+			http.Error(w, string(errorJSON), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Error replaying recommendation policy: %v", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
 }
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	authmiddleware "github.com/platanus-hack-25/lumera_app/internal/middleware"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"github.com/platanus-hack-25/lumera_app/internal/services"
+)
+
+// PausePracticeSession godoc
+// @Summary Pause a practice session
+// @Description Pauses an in-progress session. It accepts no questions or answers until resumed, and expires after SESSION_PAUSE_EXPIRY_HOURS without activity.
+// @Tags Practice
+// @Produce json
+// @Param id path int true "Session ID"
+// @Success 200 {object} models.PracticeSession
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/practice-sessions/{id}/pause [post]
+func PausePracticeSession(w http.ResponseWriter, r *http.Request) {
+	transitionSessionHandler(w, r, services.SessionKindPractice, services.PauseSession, false)
+}
+
+// ResumePracticeSession godoc
+// @Summary Resume a practice session
+// @Description Resumes a paused session and returns it with the question that was served and not answered yet, if any.
+// @Tags Practice
+// @Produce json
+// @Param id path int true "Session ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/practice-sessions/{id}/resume [post]
+func ResumePracticeSession(w http.ResponseWriter, r *http.Request) {
+	transitionSessionHandler(w, r, services.SessionKindPractice, services.ResumeSession, true)
+}
+
+// AbandonPracticeSession godoc
+// @Summary Abandon a practice session
+// @Description Closes an open session without completing it.
+// @Tags Practice
+// @Produce json
+// @Param id path int true "Session ID"
+// @Success 200 {object} models.PracticeSession
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/practice-sessions/{id}/abandon [post]
+func AbandonPracticeSession(w http.ResponseWriter, r *http.Request) {
+	transitionSessionHandler(w, r, services.SessionKindPractice, services.AbandonSession, false)
+}
+
+// PauseDiagnostic godoc
+// @Summary Pause a diagnostic session
+// @Description Pauses an in-progress session. It accepts no questions or answers until resumed, and expires after SESSION_PAUSE_EXPIRY_HOURS without activity. Sessions of a placement diagnostic cannot be paused.
+// @Tags Diagnostic
+// @Produce json
+// @Param id path int true "Session ID"
+// @Success 200 {object} models.DiagnosticSession
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/diagnostic-sessions/{id}/pause [post]
+func PauseDiagnostic(w http.ResponseWriter, r *http.Request) {
+	transitionSessionHandler(w, r, services.SessionKindDiagnostic, services.PauseSession, false)
+}
+
+// ResumeDiagnostic godoc
+// @Summary Resume a diagnostic session
+// @Description Resumes a paused session and returns it with the question that was served and not answered yet, if any.
+// @Tags Diagnostic
+// @Produce json
+// @Param id path int true "Session ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/diagnostic-sessions/{id}/resume [post]
+func ResumeDiagnostic(w http.ResponseWriter, r *http.Request) {
+	transitionSessionHandler(w, r, services.SessionKindDiagnostic, services.ResumeSession, true)
+}
+
+// AbandonDiagnostic godoc
+// @Summary Abandon a diagnostic session
+// @Description Closes an open session without completing it or generating results.
+// @Tags Diagnostic
+// @Produce json
+// @Param id path int true "Session ID"
+// @Success 200 {object} models.DiagnosticSession
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/diagnostic-sessions/{id}/abandon [post]
+func AbandonDiagnostic(w http.ResponseWriter, r *http.Request) {
+	transitionSessionHandler(w, r, services.SessionKindDiagnostic, services.AbandonSession, false)
+}
+
+// transitionSessionHandler applies a lifecycle transition and writes the updated session.
+// Resuming also returns the pending question so the client can show it again.
+func transitionSessionHandler(w http.ResponseWriter, r *http.Request, kind string, transition func(kind string, userID, sessionID uint) error, withPending bool) {
+	userID, ok := authmiddleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	sessionID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid session id"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := transition(kind, userID, uint(sessionID)); err != nil {
+		writeSessionStateError(w, userID, err)
+		return
+	}
+
+	var session interface{}
+	var pendingID *uint
+	if kind == services.SessionKindDiagnostic {
+		var diagnostic models.DiagnosticSession
+		err = db.DB.First(&diagnostic, sessionID).Error
+		session, pendingID = diagnostic, diagnostic.PreguntaPendienteID
+	} else {
+		var practice models.PracticeSession
+		err = db.DB.First(&practice, sessionID).Error
+		session, pendingID = practice, practice.PreguntaPendienteID
+	}
+	if err != nil {
+		writeSessionStateError(w, userID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !withPending {
+		json.NewEncoder(w).Encode(session)
+		return
+	}
+
+	// Pending question without validation_data
+	var pending map[string]interface{}
+	var question models.Question
+	if pendingID != nil && db.DB.First(&question, *pendingID).Error == nil {
+		pending = map[string]interface{}{
+			"id":                    question.ID,
+			"oa_bloom_objective_id": question.OABloomObjectiveID,
+			"tipo":                  question.Tipo,
+			"question_data":         question.QuestionData,
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"session":            session,
+		"pregunta_pendiente": pending,
+	})
+}
+
+func writeSessionStateError(w http.ResponseWriter, userID uint, err error) {
+	var limitErr *services.SessionLimitError
+	switch {
+	case errors.As(err, &limitErr):
+		errorJSON, _ := json.Marshal(map[string]interface{}{
+			"error":             "open session limit reached; resume, complete or abandon an open session",
+			"sesiones_abiertas": limitErr.Abiertas,
+		})
+		http.Error(w, string(errorJSON), http.StatusConflict)
+	case errors.Is(err, services.ErrSessionNotFound):
+		http.Error(w, `{"error":"session not found"}`, http.StatusNotFound)
+	case errors.Is(err, services.ErrSessionPaused):
+		http.Error(w, `{"error":"session is paused; resume it first"}`, http.StatusConflict)
+	case errors.Is(err, services.ErrSessionClosed):
+		http.Error(w, `{"error":"session is no longer open"}`, http.StatusConflict)
+	case errors.Is(err, services.ErrSessionManaged):
+		http.Error(w, `{"error":"session belongs to a placement diagnostic"}`, http.StatusConflict)
+	default:
+		log.Printf("Error handling session lifecycle for user %d: %v", userID, err)
+		http.Error(w, `{"error":"failed to process session"}`, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	authmiddleware "github.com/platanus-hack-25/lumera_app/internal/middleware"
+	"github.com/platanus-hack-25/lumera_app/internal/services"
+)
+
+// CreateStudyGoal godoc
+// @Summary Create a study goal
+// @Description Goal of mastering a set of OA-Bloom objectives before a deadline. From the student's progress and the minutes available each weekday it schedules learning plan, practice and review tasks day by day until the day before the deadline.
+// @Tags Study Goals
+// @Accept json
+// @Produce json
+// @Param body body services.StudyGoalInput true "Goal"
+// @Success 201 {object} services.StudyGoalDetail
+// @Failure 400 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/study-goals [post]
+func CreateStudyGoal(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authmiddleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input services.StudyGoalInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	goal, err := services.CreateStudyGoal(userID, input)
+	if err != nil {
+		writeStudyGoalError(w, userID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(goal)
+}
+
+// ListStudyGoals godoc
+// @Summary List the student's study goals
+// @Description Goals with their schedule and progress, newest first. Tasks already done are marked and overdue goals are replanned.
+// @Tags Study Goals
+// @Produce json
+// @Success 200 {array} services.StudyGoalDetail
+// @Security BearerAuth
+// @Router /api/study-goals [get]
+func ListStudyGoals(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authmiddleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	goals, err := services.ListStudyGoals(userID)
+	if err != nil {
+		writeStudyGoalError(w, userID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(goals)
+}
+
+// GetStudyGoal godoc
+// @Summary Get a study goal
+// @Description Goal with objective progress, scheduled tasks and a summary of pending and available minutes. Completed practice sessions and learning plans mark their tasks as done; if the student fell behind, pending tasks are replanned from today.
+// @Tags Study Goals
+// @Produce json
+// @Param id path int true "Goal ID"
+// @Success 200 {object} services.StudyGoalDetail
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/study-goals/{id} [get]
+func GetStudyGoal(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authmiddleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	goalID, ok := parseStudyGoalID(w, r)
+	if !ok {
+		return
+	}
+
+	goal, err := services.GetStudyGoal(userID, goalID)
+	if err != nil {
+		writeStudyGoalError(w, userID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(goal)
+}
+
+// UpdateStudyGoal godoc
+// @Summary Update a study goal
+// @Description Replaces title, deadline, weekday minutes and objectives, and replans the pending tasks.
+// @Tags Study Goals
+// @Accept json
+// @Produce json
+// @Param id path int true "Goal ID"
+// @Param body body services.StudyGoalInput true "Goal"
+// @Success 200 {object} services.StudyGoalDetail
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/study-goals/{id} [put]
+func UpdateStudyGoal(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authmiddleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	goalID, ok := parseStudyGoalID(w, r)
+	if !ok {
+		return
+	}
+
+	var input services.StudyGoalInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	goal, err := services.UpdateStudyGoal(userID, goalID, input)
+	if err != nil {
+		writeStudyGoalError(w, userID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(goal)
+}
+
+// DeleteStudyGoal godoc
+// @Summary Delete a study goal
+// @Tags Study Goals
+// @Param id path int true "Goal ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/study-goals/{id} [delete]
+func DeleteStudyGoal(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authmiddleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	goalID, ok := parseStudyGoalID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := services.DeleteStudyGoal(userID, goalID); err != nil {
+		writeStudyGoalError(w, userID, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReplanStudyGoal godoc
+// @Summary Replan a study goal
+// @Description Marks overdue pending tasks as skipped and schedules the remaining work from today according to current progress.
+// @Tags Study Goals
+// @Produce json
+// @Param id path int true "Goal ID"
+// @Success 200 {object} services.StudyGoalDetail
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/study-goals/{id}/replan [post]
+func ReplanStudyGoal(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authmiddleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	goalID, ok := parseStudyGoalID(w, r)
+	if !ok {
+		return
+	}
+
+	goal, err := services.ReplanStudyGoal(userID, goalID)
+	if err != nil {
+		writeStudyGoalError(w, userID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(goal)
+}
+
+// UpdateStudyGoalTask godoc
+// @Summary Update a study goal task
+// @Description Sets a task as pendiente, completada or omitida. Skipping a task replans the goal.
+// @Tags Study Goals
+// @Accept json
+// @Produce json
+// @Param id path int true "Goal ID"
+// @Param taskId path int true "Task ID"
+// @Param body body object true "{\"estado\": \"completada\"}"
+// @Success 200 {object} models.StudyGoalTask
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/study-goals/{id}/tasks/{taskId} [patch]
+func UpdateStudyGoalTask(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authmiddleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	goalID, ok := parseStudyGoalID(w, r)
+	if !ok {
+		return
+	}
+	taskID, err := strconv.ParseUint(chi.URLParam(r, "taskId"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid task id"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Estado string `json:"estado"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	task, err := services.UpdateStudyGoalTask(userID, goalID, uint(taskID), req.Estado)
+	if err != nil {
+		writeStudyGoalError(w, userID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(task)
+}
+
+// GetStudyGoalCalendar godoc
+// @Summary Download a study goal as iCalendar
+// @Description Scheduled tasks as all-day events plus the deadline, in iCalendar (.ics) format.
+// @Tags Study Goals
+// @Produce text/calendar
+// @Param id path int true "Goal ID"
+// @Success 200 {string} string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/study-goals/{id}/calendar.ics [get]
+func GetStudyGoalCalendar(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authmiddleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	goalID, ok := parseStudyGoalID(w, r)
+	if !ok {
+		return
+	}
+
+	calendar, err := services.StudyGoalCalendar(userID, goalID)
+	if err != nil {
+		writeStudyGoalError(w, userID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="meta-de-estudio.ics"`)
+	w.Write(calendar)
+}
+
+// GetStudyGoalFeed godoc
+// @Summary Subscribe to a study goal calendar
+// @Description Public iCalendar feed of a goal, authenticated by the secret token in the goal's ical_path, for calendar apps that cannot send a bearer token.
+// @Tags Study Goals
+// @Produce text/calendar
+// @Param token path string true "Calendar token"
+// @Success 200 {string} string
+// @Failure 404 {object} map[string]string
+// @Router /api/study-goals/feed/{token}.ics [get]
+func GetStudyGoalFeed(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimSuffix(chi.URLParam(r, "token"), ".ics")
+
+	calendar, err := services.StudyGoalCalendarByToken(token)
+	if err != nil {
+		writeStudyGoalError(w, 0, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Write(calendar)
+}
+
+func parseStudyGoalID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	goalID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid study goal id"}`, http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(goalID), true
+}
+
+func writeStudyGoalError(w http.ResponseWriter, userID uint, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidStudyGoal):
+		errorJSON, _ := json.Marshal(map[string]string{"error": err.Error()})
+		http.Error(w, string(errorJSON), http.StatusBadRequest)
+	case errors.Is(err, services.ErrStudyGoalNotFound):
+		http.Error(w, `{"error":"study goal not found"}`, http.StatusNotFound)
+	case errors.Is(err, services.ErrStudyGoalTaskNotFound):
+		http.Error(w, `{"error":"task not found"}`, http.StatusNotFound)
+	default:
+		log.Printf("Error handling study goal for user %d: %v", userID, err)
+		http.Error(w, `{"error":"failed to process study goal"}`, http.StatusInternalServerError)
+	}
+}
@@ -2,14 +2,13 @@ package handlers
 
 import (
 	"bytes"
-	"crypto/md5"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
-	"path/filepath"
+
+	"github.com/platanus-hack-25/lumera_app/internal/services"
 )
 
 // TTSRequest es el payload para generar audio
@@ -31,17 +30,15 @@ func GenerateTTSHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate hash for cache key
-	hash := md5.Sum([]byte(req.Text))
-	cacheKey := hex.EncodeToString(hash[:])
+	// Generate hash for cache key (shared with the learning plan exports)
+	cacheKey := services.TTSCacheKey(req.Text)
 
 	// Setup cache directory
-	cacheDir := "./static/tts-cache"
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+	if err := os.MkdirAll(services.TTSCacheDir, 0755); err != nil {
 		fmt.Printf("Warning: failed to create cache directory: %v\n", err)
 	}
 
-	cachePath := filepath.Join(cacheDir, cacheKey+".mp3")
+	cachePath := services.TTSCachePath(req.Text)
 
 	// Check if cached file exists
 	if _, err := os.Stat(cachePath); err == nil {
@@ -3,8 +3,10 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/platanus-hack-25/lumera_app/internal/db"
 	"github.com/platanus-hack-25/lumera_app/internal/middleware"
 	"github.com/platanus-hack-25/lumera_app/internal/models"
@@ -203,3 +205,63 @@ func DeleteMe(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "account deleted successfully"})
 }
+
+// SetUserRoleRequest represents the role change payload
+type SetUserRoleRequest struct {
+	Role string `json:"role"` // user, docente, admin
+}
+
+// SetUserRole godoc
+// @Summary Set a user's role
+// @Description Grants or revokes the docente and admin roles. Registration always creates "user" accounts, so this is the only way to obtain them. The user must log in again to get a token with the new role. Admins cannot change their own role. Admin only.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Param request body SetUserRoleRequest true "Role"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/admin/users/{user_id}/role [put]
+func SetUserRole(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	userID, err := strconv.ParseUint(chi.URLParam(r, "user_id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid user ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req SetUserRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	if req.Role != "user" && req.Role != "docente" && req.Role != "admin" {
+		http.Error(w, `{"error":"role must be user, docente or admin"}`, http.StatusBadRequest)
+		return
+	}
+	// An admin demoting themselves could leave the system without admins
+	if uint(userID) == adminID {
+		http.Error(w, `{"error":"cannot change your own role"}`, http.StatusForbidden)
+		return
+	}
+
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		http.Error(w, `{"error":"user not found"}`, http.StatusNotFound)
+		return
+	}
+	if err := db.DB.Model(&user).Update("role", req.Role).Error; err != nil {
+		http.Error(w, `{"error":"failed to update role"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// ComponentContentCache is generic (non-personalized) component content shared across students.
+// Entries are keyed by objective, Bloom level, personalization bucket and prompt version.
+type ComponentContentCache struct {
+	ID                  uint           `json:"id" gorm:"primaryKey"`
+	CacheKey            string         `json:"cache_key" gorm:"size:64;not null;uniqueIndex"`
+	OABloomObjectiveID  uint           `json:"oa_bloom_objective_id" gorm:"not null"`
+	TipoComponente      string         `json:"tipo_componente" gorm:"size:100;not null"`
+	BloomLevel          int            `json:"bloom_level" gorm:"not null"`
+	ObjetivoNormalizado string         `json:"objetivo_normalizado" gorm:"type:text;not null"`
+	Bucket              string         `json:"bucket" gorm:"size:50;not null"`
+	PromptVersion       string         `json:"prompt_version" gorm:"size:20;not null"`
+	ContenidoProps      datatypes.JSON `json:"contenido_props" gorm:"type:jsonb;not null"`
+	Hits                int            `json:"hits" gorm:"default:0;not null"`
+	LastHitAt           *time.Time     `json:"last_hit_at,omitempty"`
+	CreatedAt           time.Time      `json:"created_at"`
+}
+
+// TableName overrides the default table name
+func (ComponentContentCache) TableName() string {
+	return "component_content_cache"
+}
+
+// ContentCacheMetric holds the daily cache counters of a component type
+type ContentCacheMetric struct {
+	Fecha             time.Time `json:"fecha" gorm:"type:date;primaryKey"`
+	TipoComponente    string    `json:"tipo_componente" gorm:"size:100;primaryKey"`
+	Hits              int       `json:"hits" gorm:"default:0;not null"`
+	Misses            int       `json:"misses" gorm:"default:0;not null"`
+	Bypasses          int       `json:"bypasses" gorm:"default:0;not null"` // regenerations with feedback skip the cache
+	Personalizaciones int       `json:"personalizaciones" gorm:"default:0;not null"`
+}
+
+// TableName overrides the default table name
+func (ContentCacheMetric) TableName() string {
+	return "content_cache_metrics"
+}
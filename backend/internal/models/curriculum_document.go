@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// CurriculumDocument is a reference document (official curriculum, program, teacher guide)
+// uploaded for a materia or one of its OAs. Its passages ground plan and question generation.
+type CurriculumDocument struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	MateriaID      uint      `json:"materia_id" gorm:"not null"`
+	OAID           *uint     `json:"oa_id,omitempty"` // nil = applies to every OA of the materia
+	Titulo         string    `json:"titulo" gorm:"size:300;not null"`
+	Formato        string    `json:"formato" gorm:"size:20;not null"`
+	Fuente         string    `json:"fuente,omitempty" gorm:"size:500"` // URL or bibliographic reference
+	Contenido      string    `json:"contenido,omitempty" gorm:"type:text;not null"`
+	SubidoPor      *uint     `json:"subido_por,omitempty"`
+	Chunks         int       `json:"chunks" gorm:"default:0;not null"`
+	EmbeddingModel string    `json:"embedding_model,omitempty" gorm:"size:100"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// TableName overrides the default table name
+func (CurriculumDocument) TableName() string {
+	return "curriculum_documents"
+}
+
+// CurriculumChunk is an embedded fragment of a curriculum document
+type CurriculumChunk struct {
+	ID             uint            `json:"id" gorm:"primaryKey"`
+	DocumentID     uint            `json:"documento_id" gorm:"column:document_id;not null"`
+	MateriaID      uint            `json:"materia_id" gorm:"not null"`
+	OAID           *uint           `json:"oa_id,omitempty"`
+	Orden          int             `json:"orden" gorm:"not null"`
+	Texto          string          `json:"texto" gorm:"type:text;not null"`
+	Embedding      pq.Float32Array `json:"-" gorm:"type:real[];not null"`
+	EmbeddingModel string          `json:"embedding_model" gorm:"size:100;not null"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// TableName overrides the default table name
+func (CurriculumChunk) TableName() string {
+	return "curriculum_chunks"
+}
+
+// CurriculumCitation is a passage injected into a generation prompt, stored with the
+// generated content (learning_plans.fuentes, questions.fuentes, contenido_props.fuentes)
+type CurriculumCitation struct {
+	Indice      int     `json:"indice"` // [n] marker used in the prompt
+	DocumentoID uint    `json:"documento_id"`
+	Titulo      string  `json:"titulo"`
+	Fuente      string  `json:"fuente,omitempty"`
+	ChunkID     uint    `json:"chunk_id"`
+	Fragmento   string  `json:"fragmento"` // first characters of the passage
+	Score       float64 `json:"score"`
+}
+
+// Curriculum document formats
+const (
+	CurriculumFormatoMarkdown = "markdown"
+	CurriculumFormatoTexto    = "texto"
+	CurriculumFormatoPDF      = "pdf" // text extracted from a PDF
+)
@@ -12,12 +12,18 @@ type DiagnosticSession struct {
 	UserID              uint           `json:"user_id" gorm:"not null"`
 	MateriaID           uint           `json:"materia_id" gorm:"not null"`
 	NumeroIntento       int            `json:"numero_intento" gorm:"default:1"`
-	Estado              string         `json:"estado" gorm:"size:20;not null;default:en_progreso"`
+	Estado              string         `json:"estado" gorm:"size:20;not null;default:en_progreso"` // en_progreso, pausado, abandonado, expirado, completado
 	Estrategia          datatypes.JSON `json:"estrategia" gorm:"type:jsonb;not null"`
 	PreguntasTotales    int            `json:"preguntas_totales" gorm:"default:0"`
 	PreguntasCorrectas  int            `json:"preguntas_correctas" gorm:"default:0"`
+	AdivinanzasRapidas  int            `json:"adivinanzas_rapidas" gorm:"default:0"` // Answers flagged as rapid guesses
+	Compromiso          *float64       `json:"compromiso" gorm:"type:decimal(5,4)"`  // Response time effort (0-1): share of effortful answers
 	StartedAt           time.Time      `json:"started_at"`
 	CompletedAt         *time.Time     `json:"completed_at"`
+	UltimaActividadAt   time.Time      `json:"ultima_actividad_at"` // Inactive sessions expire
+	PausadoAt           *time.Time     `json:"pausado_at"`
+	PreguntaPendienteID *uint          `json:"pregunta_pendiente_id"` // Served and not yet answered
+	PlacementDiagnosticID *uint        `json:"placement_diagnostic_id,omitempty"` // Set when the session is one materia of a placement diagnostic
 
 	// Relationships
 	User     User     `json:"user,omitempty" gorm:"foreignKey:UserID"`
@@ -42,6 +48,7 @@ type DiagnosticAnswer struct {
 	IsCorrect            *bool          `json:"is_correct"`
 	Score                *float64       `json:"score" gorm:"type:decimal(5,2)"`
 	TiempoSegundos       *int           `json:"tiempo_segundos"`
+	AdivinanzaRapida     bool           `json:"adivinanza_rapida" gorm:"default:false"` // Faster than the question's rapid-guess threshold
 	CreatedAt            time.Time      `json:"created_at"`
 
 	// Relationships
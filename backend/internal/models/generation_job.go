@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// GenerationJob is a queued asynchronous generation task processed by background workers
+type GenerationJob struct {
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	Tipo           string         `json:"tipo" gorm:"size:50;not null;default:learning_plan"`
+	UserID         uint           `json:"user_id" gorm:"not null"`
+	LearningPlanID *uint          `json:"learning_plan_id,omitempty"`
+	Payload        datatypes.JSON `json:"payload" gorm:"type:jsonb;not null"`
+	Estado         string         `json:"estado" gorm:"size:20;not null;default:pendiente"`
+	Intentos       int            `json:"intentos" gorm:"default:0;not null"`
+	MaxIntentos    int            `json:"max_intentos" gorm:"default:3;not null"`
+	RunAfter       time.Time      `json:"run_after"`
+	LockedBy       string         `json:"-" gorm:"size:100"`
+	LockedAt       *time.Time     `json:"-"`
+	ErrorMensaje   string         `json:"error_mensaje,omitempty" gorm:"type:text"`
+	CompletedAt    *time.Time     `json:"completed_at,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// TableName overrides the default table name
+func (GenerationJob) TableName() string {
+	return "generation_jobs"
+}
+
+// IsFinished reports whether the job reached a terminal state
+func (j *GenerationJob) IsFinished() bool {
+	return j.Estado == GenerationJobEstadoCompletado || j.Estado == GenerationJobEstadoDeadLetter
+}
+
+// Constants for GenerationJob types
+const (
+	GenerationJobTipoLearningPlan = "learning_plan"
+)
+
+// Constants for GenerationJob states
+const (
+	GenerationJobEstadoPendiente  = "pendiente"
+	GenerationJobEstadoEnProceso  = "en_proceso"
+	GenerationJobEstadoCompletado = "completado"
+	GenerationJobEstadoDeadLetter = "dead_letter"
+)
+
+// LearningPlanJobPayload is the payload of a learning_plan generation job
+type LearningPlanJobPayload struct {
+	OABloomObjectiveID uint `json:"oa_bloom_objective_id"`
+}
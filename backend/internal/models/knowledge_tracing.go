@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// KnowledgeTracingParams are the Bayesian Knowledge Tracing parameters of an OA-Bloom objective.
+// The row with nil OABloomObjectiveID is fit on every answer and used for objectives with too few.
+type KnowledgeTracingParams struct {
+	ID                 uint      `json:"id" gorm:"primaryKey"`
+	OABloomObjectiveID *uint     `json:"oa_bloom_objective_id"`
+	PInicial           float64   `json:"p_inicial" gorm:"type:decimal(6,4);not null"`     // P(L0)
+	PAprendizaje       float64   `json:"p_aprendizaje" gorm:"type:decimal(6,4);not null"` // P(T)
+	PAdivinar          float64   `json:"p_adivinar" gorm:"type:decimal(6,4);not null"`    // P(G)
+	PDesliz            float64   `json:"p_desliz" gorm:"type:decimal(6,4);not null"`      // P(S)
+	Respuestas         int       `json:"respuestas" gorm:"not null;default:0"`
+	Estudiantes        int       `json:"estudiantes" gorm:"not null;default:0"`
+	LogVerosimilitud   *float64  `json:"log_verosimilitud" gorm:"type:decimal(14,4)"`
+	AjustadoAt         time.Time `json:"ajustado_at"`
+}
+
+// TableName overrides the default table name
+func (KnowledgeTracingParams) TableName() string {
+	return "knowledge_tracing_params"
+}
+
+// StudentKnowledgeState is the mastery posterior of a student on an OA-Bloom objective
+type StudentKnowledgeState struct {
+	ID                 uint       `json:"id" gorm:"primaryKey"`
+	UserID             uint       `json:"user_id" gorm:"not null;uniqueIndex:idx_user_knowledge_state"`
+	OABloomObjectiveID uint       `json:"oa_bloom_objective_id" gorm:"not null;uniqueIndex:idx_user_knowledge_state"`
+	PDominio           float64    `json:"p_dominio" gorm:"type:decimal(6,4);not null"`
+	Respuestas         int        `json:"respuestas" gorm:"not null;default:0"`
+	Correctas          int        `json:"correctas" gorm:"not null;default:0"`
+	UltimaRespuestaAt  *time.Time `json:"ultima_respuesta_at"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// TableName overrides the default table name
+func (StudentKnowledgeState) TableName() string {
+	return "student_knowledge_states"
+}
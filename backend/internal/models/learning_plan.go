@@ -16,6 +16,9 @@ type LearningPlan struct {
 	TiempoEstimadoMin    int        `json:"tiempo_estimado_minutos" gorm:"column:tiempo_estimado_minutos;default:0"`
 	Estado               string     `json:"estado" gorm:"size:50;not null;default:'generando'"`
 	ErrorMensaje         string     `json:"error_mensaje,omitempty" gorm:"type:text"`
+	Version              int        `json:"version" gorm:"default:1;not null"` // bumped by regenerations and rollbacks
+	PromptVersion        *string    `json:"prompt_version,omitempty" gorm:"size:20"` // plan_structure template version
+	Fuentes              datatypes.JSON `json:"fuentes,omitempty" gorm:"type:jsonb"`     // []CurriculumCitation used by the structure prompt
 	CreatedAt            time.Time  `json:"created_at"`
 	UpdatedAt            time.Time  `json:"updated_at"`
 
@@ -23,13 +26,16 @@ type LearningPlan struct {
 	Completado           bool       `json:"completado" gorm:"default:false;not null"`
 	FechaInicio          *time.Time `json:"fecha_inicio,omitempty"`
 	FechaCompletado      *time.Time `json:"fecha_completado,omitempty"`
-	ProgresoActual       int        `json:"progreso_actual" gorm:"default:0;not null"`
+	ProgresoActual       int        `json:"progreso_actual" gorm:"default:0;not null"` // distinct components opened
 	TotalSlides          int        `json:"total_slides" gorm:"default:0;not null"`
+	UltimoComponenteID   *uint      `json:"ultimo_componente_id,omitempty"`             // resume point
+	UltimaActividad      *time.Time `json:"ultima_actividad,omitempty"`
 
 	// Relationships
 	User             User             `json:"user,omitempty" gorm:"foreignKey:UserID"`
 	OABloomObjective OABloomObjective `json:"oa_bloom_objective,omitempty" gorm:"foreignKey:OABloomObjectiveID"`
 	Components       []LearningPlanComponent `json:"components,omitempty" gorm:"foreignKey:LearningPlanID;constraint:OnDelete:CASCADE;"`
+	Checkpoints      []LearningPlanCheckpoint `json:"checkpoints,omitempty" gorm:"foreignKey:LearningPlanID;constraint:OnDelete:CASCADE;"`
 }
 
 // TableName overrides the default table name
@@ -48,6 +54,8 @@ type LearningPlanComponent struct {
 	Estado              string         `json:"estado" gorm:"size:50;not null;default:'pendiente'"`
 	ContenidoProps      datatypes.JSON `json:"contenido_props,omitempty" gorm:"type:jsonb"`
 	ErrorMensaje        string         `json:"error_mensaje,omitempty" gorm:"type:text"`
+	EsRemediacion       bool           `json:"es_remediacion" gorm:"default:false;not null"` // inserted after a failed checkpoint
+	PromptVersion       *string        `json:"prompt_version,omitempty" gorm:"size:20"`     // template version that generated the content
 	CreatedAt           time.Time      `json:"created_at"`
 	UpdatedAt           time.Time      `json:"updated_at"`
 
@@ -73,17 +81,45 @@ const (
 	ComponentEstadoGenerando  = "generando"
 	ComponentEstadoGenerado   = "generado"
 	ComponentEstadoError      = "error"
+	ComponentEstadoCuarentena = "cuarentena" // flagged by moderation, hidden until an admin reviews it
 )
 
-// Available teaching component type - flexible blocks-based component
+// Available teaching component types
 const (
-	ComponentTipoExplainAndExplore = "ExplainAndExploreSlide"
+	ComponentTipoExplainAndExplore   = "ExplainAndExploreSlide"
+	ComponentTipoGuidedPracticeQuiz  = "GuidedPracticeQuiz"
+	ComponentTipoWorkedExample       = "WorkedExample"
+	ComponentTipoReadingPassage      = "ReadingPassage"
+	ComponentTipoFlashcardDeck       = "FlashcardDeck"
+	ComponentTipoReflectionPrompt    = "ReflectionPrompt"
 )
 
 // AvailableComponentTypes returns the list of available teaching component types
 func AvailableComponentTypes() []string {
 	return []string{
 		ComponentTipoExplainAndExplore,
+		ComponentTipoGuidedPracticeQuiz,
+		ComponentTipoWorkedExample,
+		ComponentTipoReadingPassage,
+		ComponentTipoFlashcardDeck,
+		ComponentTipoReflectionPrompt,
+	}
+}
+
+// ComponentTypesForBloomLevel returns the component types suited to a Bloom level (1-6).
+// ExplainAndExploreSlide is always available.
+func ComponentTypesForBloomLevel(nivel int) []string {
+	switch {
+	case nivel <= 1:
+		return []string{ComponentTipoExplainAndExplore, ComponentTipoFlashcardDeck, ComponentTipoReadingPassage, ComponentTipoGuidedPracticeQuiz}
+	case nivel == 2:
+		return []string{ComponentTipoExplainAndExplore, ComponentTipoReadingPassage, ComponentTipoFlashcardDeck, ComponentTipoWorkedExample, ComponentTipoGuidedPracticeQuiz}
+	case nivel == 3:
+		return []string{ComponentTipoExplainAndExplore, ComponentTipoWorkedExample, ComponentTipoGuidedPracticeQuiz, ComponentTipoReadingPassage}
+	case nivel == 4:
+		return []string{ComponentTipoExplainAndExplore, ComponentTipoWorkedExample, ComponentTipoReadingPassage, ComponentTipoGuidedPracticeQuiz, ComponentTipoReflectionPrompt}
+	default:
+		return []string{ComponentTipoExplainAndExplore, ComponentTipoReadingPassage, ComponentTipoWorkedExample, ComponentTipoReflectionPrompt}
 	}
 }
 
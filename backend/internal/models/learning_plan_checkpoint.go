@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/datatypes"
+)
+
+// LearningPlanCheckpoint is a check-for-understanding quiz placed after a component of a learning plan.
+// The student must pass every checkpoint before the plan can be completed.
+type LearningPlanCheckpoint struct {
+	ID                     uint          `json:"id" gorm:"primaryKey"`
+	LearningPlanID         uint          `json:"learning_plan_id" gorm:"not null"`
+	ComponentID            uint          `json:"component_id" gorm:"not null"` // the checkpoint is shown after this component
+	QuestionIDs            pq.Int64Array `json:"question_ids" gorm:"type:integer[];not null"`
+	PorcentajeAprobacion   int           `json:"porcentaje_aprobacion" gorm:"default:70;not null"`
+	Estado                 string        `json:"estado" gorm:"size:20;not null;default:pendiente"`
+	Intentos               int           `json:"intentos" gorm:"default:0;not null"`
+	MejorPorcentaje        *int          `json:"mejor_porcentaje,omitempty"`
+	RemediationComponentID *uint         `json:"remediation_component_id,omitempty"`
+	CreatedAt              time.Time     `json:"created_at"`
+	UpdatedAt              time.Time     `json:"updated_at"`
+}
+
+// TableName overrides the default table name
+func (LearningPlanCheckpoint) TableName() string {
+	return "learning_plan_checkpoints"
+}
+
+// Constants for LearningPlanCheckpoint states
+const (
+	CheckpointEstadoPendiente = "pendiente"
+	CheckpointEstadoAprobado  = "aprobado"
+	CheckpointEstadoReprobado = "reprobado"
+)
+
+// LearningPlanCheckpointAttempt stores one graded submission of a checkpoint
+type LearningPlanCheckpointAttempt struct {
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	CheckpointID uint           `json:"checkpoint_id" gorm:"not null"`
+	UserID       uint           `json:"user_id" gorm:"not null"`
+	Respuestas   datatypes.JSON `json:"respuestas" gorm:"type:jsonb;not null"` // graded answers, one per question
+	Correctas    int            `json:"correctas" gorm:"not null"`
+	Total        int            `json:"total" gorm:"not null"`
+	Porcentaje   int            `json:"porcentaje" gorm:"not null"`
+	Aprobado     bool           `json:"aprobado" gorm:"not null"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+// TableName overrides the default table name
+func (LearningPlanCheckpointAttempt) TableName() string {
+	return "learning_plan_checkpoint_attempts"
+}
+
+// CheckpointQuestionTypes are the question types used in checkpoints: auto-graded and answerable
+// without revealing the correct answer to the client
+func CheckpointQuestionTypes() []string {
+	return []string{"multiple_choice", "true_false"}
+}
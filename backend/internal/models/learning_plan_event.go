@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// LearningPlanComponentEvent is a view event of a learning plan component (slide)
+type LearningPlanComponentEvent struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	LearningPlanID uint      `json:"learning_plan_id" gorm:"not null"`
+	ComponentID    uint      `json:"component_id" gorm:"not null"`
+	UserID         uint      `json:"user_id" gorm:"not null"`
+	Tipo           string    `json:"tipo" gorm:"size:20;not null"`
+	TiempoSegundos int       `json:"tiempo_segundos" gorm:"default:0;not null"` // only for "tiempo" events
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// TableName overrides the default table name
+func (LearningPlanComponentEvent) TableName() string {
+	return "learning_plan_component_events"
+}
+
+// Constants for LearningPlanComponentEvent types
+const (
+	ComponentEventAbierto     = "abierto"      // the slide was opened
+	ComponentEventTiempo      = "tiempo"       // time spent on the slide before leaving it
+	ComponentEventScrollFinal = "scroll_final" // the student scrolled to the end of the slide
+	ComponentEventAudio       = "audio"        // text-to-speech audio was played
+)
+
+// IsValidComponentEventType checks if a component event type is valid
+func IsValidComponentEventType(tipo string) bool {
+	switch tipo {
+	case ComponentEventAbierto, ComponentEventTiempo, ComponentEventScrollFinal, ComponentEventAudio:
+		return true
+	}
+	return false
+}
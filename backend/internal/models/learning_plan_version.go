@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/datatypes"
+)
+
+// LearningPlanVersion is a snapshot of a previous version of a learning plan.
+// The current version lives in learning_plans / learning_plan_components.
+type LearningPlanVersion struct {
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	LearningPlanID uint           `json:"learning_plan_id" gorm:"not null"`
+	Version        int            `json:"version" gorm:"not null"`
+	Motivo         string         `json:"motivo" gorm:"size:30;not null"` // why this version was replaced
+	Nota           string         `json:"nota,omitempty" gorm:"type:text"`
+	Snapshot       datatypes.JSON `json:"snapshot,omitempty" gorm:"type:jsonb;not null"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+// TableName overrides the default table name
+func (LearningPlanVersion) TableName() string {
+	return "learning_plan_versions"
+}
+
+// Constants for LearningPlanVersion reasons
+const (
+	PlanVersionMotivoRegeneracionComponente = "regeneracion_componente"
+	PlanVersionMotivoRegeneracionPlan       = "regeneracion_plan"
+	PlanVersionMotivoRollback               = "rollback"
+)
+
+// LearningPlanSnapshot is the content of a plan version
+type LearningPlanSnapshot struct {
+	Titulo            string               `json:"titulo"`
+	Descripcion       string               `json:"descripcion"`
+	TiempoEstimadoMin int                  `json:"tiempo_estimado_minutos"`
+	PromptVersion     *string              `json:"prompt_version,omitempty"`
+	Fuentes           datatypes.JSON       `json:"fuentes,omitempty"`
+	Components        []ComponentSnapshot  `json:"components"`
+	Checkpoints       []CheckpointSnapshot `json:"checkpoints,omitempty"`
+}
+
+// ComponentSnapshot is a component as it was in a plan version
+type ComponentSnapshot struct {
+	ID                 uint           `json:"id"`
+	Orden              int            `json:"orden"`
+	TipoComponente     string         `json:"tipo_componente"`
+	ObjetivoEspecifico string         `json:"objetivo_especifico"`
+	TiempoEstimadoMin  int            `json:"tiempo_estimado_minutos"`
+	Estado             string         `json:"estado"`
+	ContenidoProps     datatypes.JSON `json:"contenido_props,omitempty"`
+	EsRemediacion      bool           `json:"es_remediacion"`
+	PromptVersion      *string        `json:"prompt_version,omitempty"`
+}
+
+// CheckpointSnapshot is a checkpoint as it was in a plan version; components are referenced by orden
+type CheckpointSnapshot struct {
+	ComponentOrden       int           `json:"component_orden"`
+	RemediationOrden     *int          `json:"remediation_orden,omitempty"`
+	QuestionIDs          pq.Int64Array `json:"question_ids"`
+	PorcentajeAprobacion int           `json:"porcentaje_aprobacion"`
+	Estado               string        `json:"estado"`
+	Intentos             int           `json:"intentos"`
+	MejorPorcentaje      *int          `json:"mejor_porcentaje,omitempty"`
+}
+
+// LearningPlanFeedback is a student's rating and/or comment on a plan component (or the whole plan)
+type LearningPlanFeedback struct {
+	ID                uint      `json:"id" gorm:"primaryKey"`
+	LearningPlanID    uint      `json:"learning_plan_id" gorm:"not null"`
+	ComponentID       *uint     `json:"component_id,omitempty"` // nil = feedback on the whole plan
+	TipoComponente    string    `json:"tipo_componente,omitempty" gorm:"size:100"`
+	UserID            uint      `json:"user_id" gorm:"not null"`
+	Version           int       `json:"version" gorm:"not null"` // plan version the feedback was given on
+	Valoracion        *string   `json:"valoracion,omitempty" gorm:"size:10"`
+	Comentario        string    `json:"comentario,omitempty" gorm:"type:text"`
+	AplicadaEnVersion *int      `json:"aplicada_en_version,omitempty"` // regeneration that used it
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// TableName overrides the default table name
+func (LearningPlanFeedback) TableName() string {
+	return "learning_plan_component_feedback"
+}
+
+// Constants for feedback ratings (thumbs up / down)
+const (
+	FeedbackValoracionPositiva = "positiva"
+	FeedbackValoracionNegativa = "negativa"
+)
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Origins of a misconception observation
+const (
+	MisconceptionOrigenPractica    = "practica"
+	MisconceptionOrigenDiagnostico = "diagnostico"
+)
+
+// Misconception is an entry of the per-materia misconception catalog: the student confuses
+// Concepto with ConfundidoCon. Multiple choice distractors reference it by Codigo in
+// validation_data.conceptos_erroneos.
+type Misconception struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	MateriaID     uint      `json:"materia_id" gorm:"not null"`
+	Codigo        string    `json:"codigo" gorm:"size:50;not null"`
+	Concepto      string    `json:"concepto" gorm:"size:150;not null"`
+	ConfundidoCon string    `json:"confundido_con" gorm:"size:150;not null"`
+	Descripcion   string    `json:"descripcion,omitempty" gorm:"type:text"`
+	Remediacion   string    `json:"remediacion,omitempty" gorm:"type:text"` // Short hint shown with the feedback
+	Activo        bool      `json:"activo" gorm:"default:true"`
+	CreadoPor     *uint     `json:"creado_por,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TableName overrides the default table name
+func (Misconception) TableName() string {
+	return "misconceptions"
+}
+
+// MisconceptionObservation records a misconception behind a wrong multiple choice answer
+type MisconceptionObservation struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	MisconceptionID uint      `json:"misconception_id" gorm:"not null"`
+	UserID          uint      `json:"user_id" gorm:"not null"`
+	QuestionID      uint      `json:"question_id" gorm:"not null"`
+	Opcion          string    `json:"opcion" gorm:"size:10;not null"`
+	Origen          string    `json:"origen" gorm:"size:20;not null"` // practica or diagnostico
+	SessionID       uint      `json:"session_id" gorm:"not null"`     // Practice or diagnostic session, depending on Origen
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// TableName overrides the default table name
+func (MisconceptionObservation) TableName() string {
+	return "misconception_observations"
+}
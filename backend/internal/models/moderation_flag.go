@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/datatypes"
+)
+
+// ModerationFlag is a text flagged by the moderation classifiers, kept for admin review.
+// Quarantined content is stored in Contenido and restored to the entity if an admin approves it.
+type ModerationFlag struct {
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	Origen       string         `json:"origen" gorm:"size:30;not null"`
+	EntidadTipo  string         `json:"entidad_tipo" gorm:"size:50;not null"`
+	EntidadID    *uint          `json:"entidad_id,omitempty"`
+	UserID       *uint          `json:"user_id,omitempty"` // student who wrote or would have received the text
+	Texto        string         `json:"texto" gorm:"type:text;not null"`
+	Contenido    datatypes.JSON `json:"contenido,omitempty" gorm:"type:jsonb"`
+	Categorias   pq.StringArray `json:"categorias" gorm:"type:text[]"`
+	Motivo       string         `json:"motivo" gorm:"type:text;not null"`
+	Clasificador string         `json:"clasificador" gorm:"size:50;not null"`
+	Estado       string         `json:"estado" gorm:"size:20;not null;default:pendiente"`
+	RevisadoPor  *uint          `json:"revisado_por,omitempty"`
+	RevisadoEn   *time.Time     `json:"revisado_en,omitempty"`
+	NotaRevision string         `json:"nota_revision,omitempty" gorm:"type:text"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+// TableName overrides the default table name
+func (ModerationFlag) TableName() string {
+	return "moderation_flags"
+}
+
+// Moderation flag sources
+const (
+	ModerationOrigenContenido = "contenido_generado"
+	ModerationOrigenPregunta  = "pregunta"
+	ModerationOrigenEntrada   = "entrada_estudiante"
+)
+
+// Moderation flag review states
+const (
+	ModerationEstadoPendiente = "pendiente"
+	ModerationEstadoAprobado  = "aprobado"
+	ModerationEstadoRechazado = "rechazado"
+)
+
+// Flagged entity types
+const (
+	ModerationEntidadComponente      = "learning_plan_component"
+	ModerationEntidadPregunta        = "question"
+	ModerationEntidadFeedback        = "plan_feedback"
+	ModerationEntidadRegeneracion    = "plan_regeneration"
+	ModerationEntidadPerfil          = "student_profile"
+	ModerationEntidadPersonalizacion = "content_personalization"
+)
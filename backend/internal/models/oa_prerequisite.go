@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// OAPrerequisite is an edge of the OA prerequisite graph: OAID builds on PrerequisitoOAID.
+// The Bloom objective IDs optionally narrow the edge to specific levels of either OA.
+type OAPrerequisite struct {
+	ID                             uint      `json:"id" gorm:"primaryKey"`
+	OAID                           uint      `json:"oa_id" gorm:"column:oa_id;not null"`
+	PrerequisitoOAID               uint      `json:"prerequisito_oa_id" gorm:"column:prerequisito_oa_id;not null"`
+	OABloomObjectiveID             *uint     `json:"oa_bloom_objective_id,omitempty" gorm:"column:oa_bloom_objective_id"`                           // nil = the whole OA
+	PrerequisitoOABloomObjectiveID *uint     `json:"prerequisito_oa_bloom_objective_id,omitempty" gorm:"column:prerequisito_oa_bloom_objective_id"` // nil = the prerequisite OA at any level
+	Nota                           string    `json:"nota,omitempty" gorm:"type:text"`
+	CreadoPor                      *uint     `json:"creado_por,omitempty"`
+	CreatedAt                      time.Time `json:"created_at"`
+	UpdatedAt                      time.Time `json:"updated_at"`
+}
+
+// TableName overrides the default table name
+func (OAPrerequisite) TableName() string {
+	return "oa_prerequisites"
+}
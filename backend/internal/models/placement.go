@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// PlacementDiagnostic is a placement evaluation across every materia of the student's curso.
+// Each materia is answered in its own DiagnosticSession linked by PlacementDiagnosticID.
+type PlacementDiagnostic struct {
+	ID                   uint           `json:"id" gorm:"primaryKey"`
+	UserID               uint           `json:"user_id" gorm:"not null;index"`
+	CursoID              *uint          `json:"curso_id"`
+	Estado               string         `json:"estado" gorm:"size:20;not null;default:'en_progreso'"` // en_progreso, completado
+	PresupuestoPreguntas int            `json:"presupuesto_preguntas" gorm:"not null"`
+	PresupuestoMinutos   int            `json:"presupuesto_minutos" gorm:"not null"`
+	Plan                 datatypes.JSON `json:"plan" gorm:"type:jsonb;not null"`     // [{materia_id, oa_id, categoria, session_id}]
+	Informe              datatypes.JSON `json:"informe,omitempty" gorm:"type:jsonb"` // Consolidated report once completed
+	StartedAt            time.Time      `json:"started_at"`
+	CompletedAt          *time.Time     `json:"completed_at"`
+
+	// Relationships
+	Sessions []DiagnosticSession `json:"sessions,omitempty" gorm:"foreignKey:PlacementDiagnosticID"`
+}
+
+// TableName overrides the default table name
+func (PlacementDiagnostic) TableName() string {
+	return "placement_diagnostics"
+}
@@ -17,11 +17,17 @@ type PracticeSession struct {
 	NumeroPreguntas     int            `json:"numero_preguntas" gorm:"default:10"`   // Number of questions (default 10)
 	PreguntasRespondidas int           `json:"preguntas_respondidas" gorm:"default:0"`
 	PreguntasCorrectas  int            `json:"preguntas_correctas" gorm:"default:0"`
-	Estado              string         `json:"estado" gorm:"type:varchar(20);default:'en_progreso';index"` // en_progreso, completado
+	Estado              string         `json:"estado" gorm:"type:varchar(20);default:'en_progreso';index"` // en_progreso, pausado, abandonado, expirado, completado
 	Estrategia          datatypes.JSON `json:"estrategia" gorm:"type:jsonb"`         // Adaptive strategy data
 	Resultado           datatypes.JSON `json:"resultado" gorm:"type:jsonb"`          // Final results and analysis
+	AdivinanzasRapidas  int            `json:"adivinanzas_rapidas" gorm:"default:0"` // Answers flagged as rapid guesses
+	Compromiso          *float64       `json:"compromiso" gorm:"type:decimal(5,4)"`  // Response time effort (0-1): share of effortful answers
 	StartedAt           time.Time      `json:"started_at"`
 	CompletedAt         *time.Time     `json:"completed_at"`
+	UltimaActividadAt   time.Time      `json:"ultima_actividad_at"`                  // Inactive sessions expire
+	PausadoAt           *time.Time     `json:"pausado_at"`
+	PreguntaPendienteID *uint          `json:"pregunta_pendiente_id"`                // Served and not yet answered
+	PistasUsadas        int            `json:"pistas_usadas" gorm:"default:0"`       // Hints revealed for the pending question
 	CreatedAt           time.Time      `json:"created_at"`
 	UpdatedAt           time.Time      `json:"updated_at"`
 
@@ -42,6 +48,8 @@ type PracticeAnswer struct {
 	IsCorrect          *bool          `json:"is_correct"`
 	Score              *float64       `json:"score"`              // Partial credit (0-1)
 	TiempoSegundos     *int           `json:"tiempo_segundos"`
+	AdivinanzaRapida   bool           `json:"adivinanza_rapida" gorm:"default:false"` // Faster than the question's rapid-guess threshold
+	PistasUsadas       int            `json:"pistas_usadas" gorm:"default:0"`         // Hints revealed before answering
 	CreatedAt          time.Time      `json:"created_at"`
 
 	// Relations
@@ -57,6 +65,7 @@ type PracticeStrategy struct {
 	AciertosPorNivel     map[int]int `json:"aciertos_por_nivel"`
 	FallosPorNivel       map[int]int `json:"fallos_por_nivel"`
 	PatronRespuestas     []string `json:"patron_respuestas"` // "C" = correct, "I" = incorrect
+	AciertosConPistas    map[int]int `json:"aciertos_con_pistas,omitempty"` // Correct answers after hints, by level (not in AciertosPorNivel)
 }
 
 func (PracticeSession) TableName() string {
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// PromptTemplate is a prompt template version stored in the database. It replaces the
+// embedded template with the same name and version, or adds a new version.
+type PromptTemplate struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	Nombre         string    `json:"nombre" gorm:"size:100;not null"`
+	Version        string    `json:"version" gorm:"size:20;not null"`
+	Contenido      string    `json:"contenido" gorm:"type:text;not null"`
+	Peso           int       `json:"peso" gorm:"default:0;not null"` // A/B weight, 0 = no new assignments
+	ActualizadoPor *uint     `json:"actualizado_por,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// TableName overrides the default table name
+func (PromptTemplate) TableName() string {
+	return "prompt_templates"
+}
+
+// Prompt template names besides the component types
+const (
+	PromptPlanStructure          = "plan_structure"
+	PromptExamplePersonalization = "example_personalization"
+	PromptQuestionHints          = "question_hints"
+)
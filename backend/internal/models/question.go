@@ -36,8 +36,11 @@ type Question struct {
 	ValidationData       datatypes.JSON `json:"validation_data" gorm:"type:jsonb;not null"`
 	DificultadRelativa   int            `json:"dificultad_relativa" gorm:"default:3"`
 	VecesUsada           int            `json:"veces_usada" gorm:"default:0"`
+	TiempoNormativoSegundos *float64    `json:"tiempo_normativo_segundos,omitempty" gorm:"type:decimal(8,2)"` // Median answer time
+	RespuestasCronometradas int         `json:"respuestas_cronometradas" gorm:"default:0"`
 	Activa               bool           `json:"activa" gorm:"default:true"`
 	Tags                 pq.StringArray `json:"tags" gorm:"type:text[]"`
+	Fuentes              datatypes.JSON `json:"fuentes,omitempty" gorm:"type:jsonb"` // []CurriculumCitation used by the generation prompt
 	CreatedAt            time.Time      `json:"created_at"`
 	UpdatedAt            time.Time      `json:"updated_at"`
 
@@ -53,6 +56,9 @@ func (Question) TableName() string {
 
 // Validate validates the question structure based on its type
 func (q *Question) Validate() error {
+	if err := q.validateHints(); err != nil {
+		return err
+	}
 	switch q.Tipo {
 	case "multiple_choice":
 		return q.validateMultipleChoice()
@@ -309,36 +315,64 @@ func (q *Question) ValidateAnswer(userAnswer datatypes.JSON) (bool, float64, err
 	}
 }
 
-// validateMultipleChoiceAnswer validates a multiple choice answer
-func (q *Question) validateMultipleChoiceAnswer(userAnswer datatypes.JSON) (bool, float64, error) {
-	var answer map[string]interface{}
-	if err := json.Unmarshal(userAnswer, &answer); err != nil {
-		return false, 0, err
+// validateHints checks the optional authored hints (validation_data.pistas): texts from most general to most specific
+func (q *Question) validateHints() error {
+	var validation map[string]json.RawMessage
+	if err := json.Unmarshal(q.ValidationData, &validation); err != nil {
+		return nil // reported by the type validators
+	}
+	raw, ok := validation["pistas"]
+	if !ok {
+		return nil
+	}
+	var hints []string
+	if err := json.Unmarshal(raw, &hints); err != nil {
+		return errors.New("pistas must be an array of strings")
 	}
+	for i, hint := range hints {
+		if strings.TrimSpace(hint) == "" {
+			return fmt.Errorf("pista %d is empty", i+1)
+		}
+	}
+	return nil
+}
 
-	var validation map[string]interface{}
-	if err := json.Unmarshal(q.ValidationData, &validation); err != nil {
-		return false, 0, err
+// SelectedOption returns the option letter of a multiple choice answer
+func (q *Question) SelectedOption(userAnswer datatypes.JSON) (string, error) {
+	var answer map[string]interface{}
+	if err := json.Unmarshal(userAnswer, &answer); err != nil {
+		return "", err
 	}
 
 	userChoice, ok := answer["selected"]
 	if !ok {
-		return false, 0, errors.New("answer must contain 'selected' field")
+		return "", errors.New("answer must contain 'selected' field")
 	}
 
-	correctAnswer := validation["respuesta_correcta"]
-
-	// Convert both to strings for comparison
-	userStr := fmt.Sprint(userChoice)
-	correctStr := fmt.Sprint(correctAnswer)
-
 	// If user sent a number (0,1,2,3), convert to letter (A,B,C,D)
 	if userFloat, ok := userChoice.(float64); ok {
 		letters := []string{"A", "B", "C", "D", "E", "F"}
 		if int(userFloat) >= 0 && int(userFloat) < len(letters) {
-			userStr = letters[int(userFloat)]
+			return letters[int(userFloat)], nil
 		}
 	}
+	return fmt.Sprint(userChoice), nil
+}
+
+// validateMultipleChoiceAnswer validates a multiple choice answer
+func (q *Question) validateMultipleChoiceAnswer(userAnswer datatypes.JSON) (bool, float64, error) {
+	userStr, err := q.SelectedOption(userAnswer)
+	if err != nil {
+		return false, 0, err
+	}
+
+	var validation map[string]interface{}
+	if err := json.Unmarshal(q.ValidationData, &validation); err != nil {
+		return false, 0, err
+	}
+
+	// Convert both to strings for comparison
+	correctStr := fmt.Sprint(validation["respuesta_correcta"])
 
 	isCorrect := userStr == correctStr
 
@@ -758,3 +792,19 @@ func (q *Question) validateCompareContrastAnswer(userAnswer datatypes.JSON) (boo
 
 	return isCorrect, score, nil
 }
+
+// QuestionHint is an LLM-generated hint of a question without authored hints (validation_data.pistas),
+// cached on first request
+type QuestionHint struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	QuestionID    uint      `json:"question_id" gorm:"not null"`
+	Orden         int       `json:"orden" gorm:"not null"` // 1 = most general
+	Texto         string    `json:"texto" gorm:"type:text;not null"`
+	PromptVersion *string   `json:"prompt_version,omitempty" gorm:"size:20"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// TableName overrides the default table name
+func (QuestionHint) TableName() string {
+	return "question_hints"
+}
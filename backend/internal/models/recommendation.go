@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// RecommendationImpression is a recommendation shown to a student. Impressions of one
+// recommender call share a Lote; ClickedAt is set when the student opens the recommendation.
+type RecommendationImpression struct {
+	ID                 uint           `json:"id" gorm:"primaryKey"`
+	UserID             uint           `json:"user_id" gorm:"not null"`
+	Lote               string         `json:"lote" gorm:"size:32;not null"`
+	Origen             string         `json:"origen" gorm:"size:20;not null"` // daily, list
+	Posicion           int            `json:"posicion" gorm:"not null"`       // 1 = top of the list
+	MateriaID          uint           `json:"materia_id" gorm:"not null"`
+	OAID               uint           `json:"oa_id" gorm:"column:oa_id;not null"`
+	OABloomObjectiveID uint           `json:"oa_bloom_objective_id" gorm:"not null"`
+	RecommendationType string         `json:"recommendation_type" gorm:"size:30;not null"`
+	Score              float64        `json:"score" gorm:"type:decimal(8,4);not null"`
+	Reasons            StringArray    `json:"reasons" gorm:"type:text[]"`
+	Signals            datatypes.JSON `json:"signals,omitempty" gorm:"type:jsonb"`
+	Contexto           string         `json:"contexto,omitempty" gorm:"size:60"`
+	ClickedAt          *time.Time     `json:"clicked_at,omitempty"`
+	CreatedAt          time.Time      `json:"created_at"`
+
+	// Bandit decision (daily recommendations only, one per user and Dia) and its reward, settled after the reward window
+	Dia                *time.Time     `json:"dia,omitempty" gorm:"type:date"`
+	Politica           *string        `json:"politica,omitempty" gorm:"size:30"`
+	Probabilidad       *float64       `json:"probabilidad,omitempty" gorm:"type:decimal(6,4)"`
+	Brazos             datatypes.JSON `json:"brazos,omitempty" gorm:"type:jsonb"`
+	PorcentajeInicial  int            `json:"porcentaje_inicial" gorm:"not null;default:0"`
+	PracticaCompletada *bool          `json:"practica_completada,omitempty"`
+	DeltaLogro         *int           `json:"delta_logro,omitempty"`
+	Recompensa         *float64       `json:"recompensa,omitempty" gorm:"type:decimal(5,4)"`
+	RecompensadaAt     *time.Time     `json:"recompensada_at,omitempty"`
+}
+
+// TableName overrides the default table name
+func (RecommendationImpression) TableName() string {
+	return "recommendation_impressions"
+}
+
+// Recommendation origins
+const (
+	RecommendationOrigenDaily = "daily"
+	RecommendationOrigenList  = "list"
+)
+
+// Recommendation types
+const (
+	RecommendationTypeNewTopic     = "new_topic"
+	RecommendationTypeNextLevel    = "next_level"
+	RecommendationTypePracticeMore = "practice_more"
+	RecommendationTypeReview       = "review"
+)
+
+// RecommendationBanditArm is the Beta(Alfa, Beta) posterior of the reward of recommending
+// one type (Brazo) to candidates of one context
+type RecommendationBanditArm struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	Brazo           string    `json:"brazo" gorm:"size:30;not null;uniqueIndex:idx_bandit_arm"`
+	Contexto        string    `json:"contexto" gorm:"size:60;not null;uniqueIndex:idx_bandit_arm"`
+	Alfa            float64   `json:"alfa" gorm:"type:decimal(12,4);not null;default:1"`
+	Beta            float64   `json:"beta" gorm:"type:decimal(12,4);not null;default:1"`
+	Jugadas         int       `json:"jugadas" gorm:"not null;default:0"`
+	RecompensaTotal float64   `json:"recompensa_total" gorm:"type:decimal(12,4);not null;default:0"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// TableName overrides the default table name
+func (RecommendationBanditArm) TableName() string {
+	return "recommendation_bandit_arms"
+}
@@ -32,6 +32,11 @@ type ProfileHistory struct {
 	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
 }
 
+// TableName overrides the default table name
+func (ProfileHistory) TableName() string {
+	return "profile_history"
+}
+
 // ProfileDataStructure defines the expected structure of profile_data JSON
 // This is for documentation and type safety in code (not enforced by GORM)
 type ProfileDataStructure struct {
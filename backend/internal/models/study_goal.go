@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// StudyGoal is a student's goal of mastering a set of OA-Bloom objectives before a deadline,
+// with the minutes they can study each weekday
+type StudyGoal struct {
+	ID              uint           `json:"id" gorm:"primaryKey"`
+	UserID          uint           `json:"user_id" gorm:"not null;index"`
+	Titulo          string         `json:"titulo" gorm:"size:255;not null"`
+	FechaLimite     time.Time      `json:"fecha_limite" gorm:"type:date;not null"`
+	MinutosPorDia   datatypes.JSON `json:"minutos_por_dia" gorm:"type:jsonb;not null"` // {"lunes": 30, ...}
+	Estado          string         `json:"estado" gorm:"size:20;not null;default:'activa'"`
+	ICalToken       string         `json:"-" gorm:"column:ical_token;size:64;not null;uniqueIndex"`
+	ReplanificadaAt *time.Time     `json:"replanificada_at,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+
+	// Relationships
+	Objetivos []StudyGoalObjective `json:"-" gorm:"foreignKey:StudyGoalID;constraint:OnDelete:CASCADE;"`
+	Tareas    []StudyGoalTask      `json:"-" gorm:"foreignKey:StudyGoalID;constraint:OnDelete:CASCADE;"`
+}
+
+// TableName overrides the default table name
+func (StudyGoal) TableName() string {
+	return "study_goals"
+}
+
+// StudyGoalObjective is a target OA-Bloom objective of a goal
+type StudyGoalObjective struct {
+	ID                 uint `json:"id" gorm:"primaryKey"`
+	StudyGoalID        uint `json:"study_goal_id" gorm:"not null"`
+	OABloomObjectiveID uint `json:"oa_bloom_objective_id" gorm:"not null"`
+}
+
+// TableName overrides the default table name
+func (StudyGoalObjective) TableName() string {
+	return "study_goal_objectives"
+}
+
+// StudyGoalTask is one scheduled activity of a goal. Fecha is nil when the task did not fit
+// before the deadline.
+type StudyGoalTask struct {
+	ID                 uint       `json:"id" gorm:"primaryKey"`
+	StudyGoalID        uint       `json:"study_goal_id" gorm:"not null;index"`
+	UserID             uint       `json:"user_id" gorm:"not null"`
+	OABloomObjectiveID uint       `json:"oa_bloom_objective_id" gorm:"not null"`
+	OAID               uint       `json:"oa_id" gorm:"column:oa_id;not null"`
+	LearningPlanID     *uint      `json:"learning_plan_id,omitempty"`
+	Fecha              *time.Time `json:"fecha" gorm:"type:date"`
+	Orden              int        `json:"orden" gorm:"not null;default:0"`
+	Tipo               string     `json:"tipo" gorm:"size:30;not null"` // practica, plan_aprendizaje, repaso
+	Titulo             string     `json:"titulo" gorm:"size:500;not null"`
+	Minutos            int        `json:"minutos" gorm:"not null"`
+	Estado             string     `json:"estado" gorm:"size:20;not null;default:'pendiente'"` // pendiente, completada, omitida
+	CompletedAt        *time.Time `json:"completed_at,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// TableName overrides the default table name
+func (StudyGoalTask) TableName() string {
+	return "study_goal_tasks"
+}
+
+// Study goal states
+const (
+	StudyGoalEstadoActiva     = "activa"
+	StudyGoalEstadoCompletada = "completada"
+	StudyGoalEstadoVencida    = "vencida"
+)
+
+// Study goal task types
+const (
+	StudyGoalTaskTipoPractica        = "practica"
+	StudyGoalTaskTipoPlanAprendizaje = "plan_aprendizaje"
+	StudyGoalTaskTipoRepaso          = "repaso"
+)
+
+// Study goal task states
+const (
+	StudyGoalTaskEstadoPendiente  = "pendiente"
+	StudyGoalTaskEstadoCompletada = "completada"
+	StudyGoalTaskEstadoOmitida    = "omitida"
+)
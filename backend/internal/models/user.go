@@ -7,13 +7,14 @@ import (
 )
 
 type User struct {
-	ID           uint      `json:"id" gorm:"primaryKey"`
-	Email        string    `json:"email" gorm:"uniqueIndex;not null"`
-	Name         string    `json:"name" gorm:"not null"`
-	PasswordHash string    `json:"-" gorm:"column:password_hash;not null"`
-	Role         string    `json:"role" gorm:"default:user;not null"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID                uint      `json:"id" gorm:"primaryKey"`
+	Email             string    `json:"email" gorm:"uniqueIndex;not null"`
+	Name              string    `json:"name" gorm:"not null"`
+	PasswordHash      string    `json:"-" gorm:"column:password_hash;not null"`
+	Role              string    `json:"role" gorm:"default:user;not null"`
+	LLMDailyBudgetUSD *float64  `json:"llm_daily_budget_usd,omitempty" gorm:"column:llm_daily_budget_usd"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
 }
 
 // SetPassword hashes the password and stores it
@@ -0,0 +1,438 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"github.com/platanus-hack-25/lumera_app/pkg/llm"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Columnas de content_cache_metrics
+const (
+	cacheMetricHit             = "hits"
+	cacheMetricMiss            = "misses"
+	cacheMetricBypass          = "bypasses"
+	cacheMetricPersonalization = "personalizaciones"
+)
+
+// ContentCacheTypeStats son los contadores del caché de un tipo de componente
+type ContentCacheTypeStats struct {
+	TipoComponente    string  `json:"tipo_componente"`
+	Hits              int     `json:"hits"`
+	Misses            int     `json:"misses"`
+	Bypasses          int     `json:"bypasses"`
+	Personalizaciones int     `json:"personalizaciones"`
+	HitRate           float64 `json:"hit_rate"` // hits / (hits + misses)
+}
+
+// ContentCacheStats es el reporte de uso del caché compartido de contenido
+type ContentCacheStats struct {
+	From           time.Time               `json:"from"`
+	To             time.Time               `json:"to"`
+	PromptVersions map[string][]string     `json:"prompt_versions"` // versiones de prompt vigentes por tipo de componente
+	Entradas       int64                   `json:"entradas"`
+	Totales        ContentCacheTypeStats   `json:"totales"`
+	PorTipo        []ContentCacheTypeStats `json:"por_tipo"`
+}
+
+// contentCacheEnabled indica si el caché compartido está activo (CONTENT_CACHE_ENABLED, por defecto true)
+func contentCacheEnabled() bool {
+	return getEnvString("CONTENT_CACHE_ENABLED", "true") != "false"
+}
+
+// useContentCache decide si una generación pasa por el caché. Las regeneraciones con feedback del
+// estudiante lo saltan: deben producir contenido nuevo y propio.
+func useContentCache(componentType string, oaContext OAContext) bool {
+	if !contentCacheEnabled() {
+		return false
+	}
+	if len(oaContext.FeedbackEstudiante) > 0 {
+		recordContentCacheMetric(componentType, cacheMetricBypass)
+		return false
+	}
+	return true
+}
+
+// normalizeCacheText pasa a minúsculas, quita tildes y puntuación y colapsa espacios
+func normalizeCacheText(text string) string {
+	replacer := strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n")
+	text = replacer.Replace(strings.ToLower(text))
+
+	var b strings.Builder
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// personalizationBucket agrupa los rasgos del perfil que cambian el contenido más allá de los ejemplos.
+// Intereses y profesión no entran: se aplican después sobre los ejemplos (personalizeExamples).
+func personalizationBucket(oaContext OAContext) string {
+	formato := normalizeCacheText(oaContext.FormatoPreferido)
+	if formato == "" {
+		return "general"
+	}
+	return formato
+}
+
+// genericContext es el contexto sin intereses ni profesión, con el que se genera el contenido compartible
+func genericContext(oaContext OAContext) OAContext {
+	oaContext.InteresesPersonales = nil
+	oaContext.ProfesionSoñada = ""
+	return oaContext
+}
+
+// contentCacheKey incluye la versión del prompt: cada brazo de un experimento tiene su propio contenido
+func contentCacheKey(oaContext OAContext, componentType, objetivo, bucket, promptVersion string) string {
+	raw := fmt.Sprintf("%d|%s|%d|%s|%s|%s", oaContext.OABloomObjectiveID, componentType, oaContext.BloomLevelNumero, objetivo, bucket,
+		promptVersion)
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// objectiveSimilarity es el índice de Jaccard entre las palabras (de más de 2 letras) de dos objetivos normalizados
+func objectiveSimilarity(a, b string) float64 {
+	words := func(s string) map[string]bool {
+		set := make(map[string]bool)
+		for _, w := range strings.Fields(s) {
+			if len(w) > 2 {
+				set[w] = true
+			}
+		}
+		return set
+	}
+	setA, setB := words(a), words(b)
+	if len(setA) == 0 || len(setB) == 0 {
+		return 0
+	}
+	intersection := 0
+	for w := range setA {
+		if setB[w] {
+			intersection++
+		}
+	}
+	return float64(intersection) / float64(len(setA)+len(setB)-intersection)
+}
+
+// lookupContentCache busca contenido genérico para el componente: primero por clave exacta y si no,
+// el objetivo más parecido del mismo OA-Bloom, tipo, bucket y versión de prompt
+// (CONTENT_CACHE_MIN_SIMILARITY, en porcentaje, por defecto 85).
+func lookupContentCache(componentType string, oaContext OAContext, componentObjective string) (*models.ComponentContentCache, bool) {
+	objetivo := normalizeCacheText(componentObjective)
+	bucket := personalizationBucket(oaContext)
+	promptVersion := AssignedPromptVersion(componentType, oaContext.UserID)
+
+	var entry models.ComponentContentCache
+	err := db.DB.Where("cache_key = ?", contentCacheKey(oaContext, componentType, objetivo, bucket, promptVersion)).First(&entry).Error
+	if err != nil {
+		var candidates []models.ComponentContentCache
+		if err := db.DB.Select("id", "objetivo_normalizado").
+			Where("oa_bloom_objective_id = ? AND tipo_componente = ? AND bloom_level = ? AND bucket = ? AND prompt_version = ?",
+				oaContext.OABloomObjectiveID, componentType, oaContext.BloomLevelNumero, bucket, promptVersion).
+			Find(&candidates).Error; err != nil {
+			return nil, false
+		}
+
+		minSimilarity := float64(getEnvInt("CONTENT_CACHE_MIN_SIMILARITY", 85)) / 100
+		var bestID uint
+		best := 0.0
+		for _, c := range candidates {
+			if similarity := objectiveSimilarity(objetivo, c.ObjetivoNormalizado); similarity >= minSimilarity && similarity > best {
+				best, bestID = similarity, c.ID
+			}
+		}
+		if bestID == 0 || db.DB.First(&entry, bestID).Error != nil {
+			return nil, false
+		}
+	}
+
+	now := time.Now()
+	db.DB.Model(&entry).Updates(map[string]interface{}{
+		"hits":        gorm.Expr("hits + 1"),
+		"last_hit_at": now,
+	})
+	return &entry, true
+}
+
+// storeContentCache guarda el contenido genérico recién generado con la versión del prompt que lo produjo;
+// si otra generación ya lo guardó se conserva esa
+func storeContentCache(componentType string, oaContext OAContext, componentObjective, promptVersion string, content map[string]interface{}) {
+	contentJSON, err := json.Marshal(content)
+	if err != nil {
+		return
+	}
+
+	objetivo := normalizeCacheText(componentObjective)
+	bucket := personalizationBucket(oaContext)
+	entry := models.ComponentContentCache{
+		CacheKey:            contentCacheKey(oaContext, componentType, objetivo, bucket, promptVersion),
+		OABloomObjectiveID:  oaContext.OABloomObjectiveID,
+		TipoComponente:      componentType,
+		BloomLevel:          oaContext.BloomLevelNumero,
+		ObjetivoNormalizado: objetivo,
+		Bucket:              bucket,
+		PromptVersion:       promptVersion,
+		ContenidoProps:      datatypes.JSON(contentJSON),
+	}
+	if err := db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry).Error; err != nil {
+		log.Printf("⚠ Failed to store content cache entry (%s): %v", componentType, err)
+	}
+}
+
+// recordContentCacheMetric suma 1 al contador del día para el tipo de componente
+func recordContentCacheMetric(componentType, column string) {
+	metric := models.ContentCacheMetric{Fecha: time.Now().Truncate(24 * time.Hour), TipoComponente: componentType}
+	err := db.DB.Model(&models.ContentCacheMetric{}).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "fecha"}, {Name: "tipo_componente"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{column: gorm.Expr("content_cache_metrics." + column + " + 1")}),
+	}).Create(map[string]interface{}{
+		"fecha":           metric.Fecha,
+		"tipo_componente": metric.TipoComponente,
+		column:            1,
+	}).Error
+	if err != nil {
+		log.Printf("⚠ Failed to record content cache metric: %v", err)
+	}
+}
+
+// cachedComponentContent retorna el contenido del caché (con los ejemplos personalizados) y la versión del prompt
+// que lo generó si hay una entrada para el componente, registrando el hit o el miss
+func cachedComponentContent(ctx context.Context, componentType string, oaContext OAContext, componentObjective string) (map[string]interface{}, string, bool) {
+	entry, ok := lookupContentCache(componentType, oaContext, componentObjective)
+	if !ok {
+		recordContentCacheMetric(componentType, cacheMetricMiss)
+		return nil, "", false
+	}
+
+	var content map[string]interface{}
+	if err := json.Unmarshal(entry.ContenidoProps, &content); err != nil {
+		recordContentCacheMetric(componentType, cacheMetricMiss)
+		return nil, "", false
+	}
+	recordContentCacheMetric(componentType, cacheMetricHit)
+	log.Printf("✓ Content cache hit for component type: %s (entry %d)", componentType, entry.ID)
+
+	return personalizeExamples(ctx, componentType, oaContext, content), entry.PromptVersion, true
+}
+
+// cacheGeneratedContent guarda el contenido genérico recién generado y lo retorna personalizado para el estudiante
+func cacheGeneratedContent(ctx context.Context, componentType string, oaContext OAContext, componentObjective, promptVersion string, content map[string]interface{}) map[string]interface{} {
+	storeContentCache(componentType, oaContext, componentObjective, promptVersion, content)
+	return personalizeExamples(ctx, componentType, oaContext, content)
+}
+
+// personalizedExample es un ejemplo reescrito con los intereses del estudiante
+type personalizedExample struct {
+	Titulo    string `json:"titulo"`
+	Contenido string `json:"contenido"`
+	Analisis  string `json:"analisis"`
+}
+
+// personalizeExamples reescribe los bloques "ejemplo" de un ExplainAndExploreSlide con los intereses y la
+// profesión soñada del estudiante, sin tocar el resto del contenido compartido. Los demás tipos se usan tal cual.
+// Si la llamada falla se retorna el contenido genérico.
+func personalizeExamples(ctx context.Context, componentType string, oaContext OAContext, content map[string]interface{}) map[string]interface{} {
+	if componentType != models.ComponentTipoExplainAndExplore || llmClient == nil {
+		return content
+	}
+	if len(oaContext.InteresesPersonales) == 0 && oaContext.ProfesionSoñada == "" {
+		return content
+	}
+
+	bloques, _ := content["bloques"].([]interface{})
+	var indices []int
+	var ejemplos []personalizedExample
+	for i, item := range bloques {
+		bloque, ok := item.(map[string]interface{})
+		if !ok || bloque["tipo"] != "ejemplo" {
+			continue
+		}
+		titulo, _ := bloque["titulo"].(string)
+		contenido, _ := bloque["contenido"].(string)
+		analisis, _ := bloque["analisis"].(string)
+		indices = append(indices, i)
+		ejemplos = append(ejemplos, personalizedExample{Titulo: titulo, Contenido: contenido, Analisis: analisis})
+	}
+	if len(ejemplos) == 0 {
+		return content
+	}
+
+	ejemplosJSON, _ := json.Marshal(map[string]interface{}{"ejemplos": ejemplos})
+	prompt, err := buildExamplePersonalizationPrompt(oaContext, string(ejemplosJSON))
+	if err != nil {
+		log.Printf("⚠ Example personalization failed, using cached content: %v", err)
+		return content
+	}
+
+	timeout := getEnvInt("OPENAI_TIMEOUT_SECONDS", 45)
+	callCtx, cancel := context.WithTimeout(llm.WithFeature(ctx, llm.FeatureContentPersonalization), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	resp, err := llmClient.Chat(callCtx, llm.ChatRequest{
+		Model: getEnvString("OPENAI_MODEL", "gpt-4o-mini"),
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: "Eres un experto en diseño de contenido educativo. Adaptas ejemplos a los intereses de cada estudiante sin cambiar lo que enseñan."},
+			{Role: llm.RoleUser, Content: prompt},
+		},
+		Temperature: 0.7,
+		MaxTokens:   1500,
+	})
+	if err != nil {
+		log.Printf("⚠ Example personalization failed, using cached content: %v", err)
+		return content
+	}
+
+	var result struct {
+		Ejemplos []personalizedExample `json:"ejemplos"`
+	}
+	if err := json.Unmarshal([]byte(cleanMarkdownJSON(resp.Content)), &result); err != nil || len(result.Ejemplos) != len(ejemplos) {
+		log.Printf("⚠ Invalid example personalization response, using cached content")
+		return content
+	}
+
+	// Copia para no modificar el contenido compartido
+	var personalized map[string]interface{}
+	contentJSON, _ := json.Marshal(content)
+	json.Unmarshal(contentJSON, &personalized)
+	personalizedBloques := personalized["bloques"].([]interface{})
+	for n, i := range indices {
+		ejemplo := result.Ejemplos[n]
+		if strings.TrimSpace(ejemplo.Contenido) == "" {
+			continue
+		}
+		bloque := personalizedBloques[i].(map[string]interface{})
+		bloque["contenido"] = ejemplo.Contenido
+		if ejemplo.Titulo != "" {
+			bloque["titulo"] = ejemplo.Titulo
+		}
+		if ejemplo.Analisis != "" {
+			bloque["analisis"] = ejemplo.Analisis
+		}
+	}
+
+	var flagged *FlaggedContentError
+	if err := moderateGeneratedContent(ctx, personalized); errors.As(err, &flagged) {
+		log.Printf("⚠ Personalized examples flagged by moderation, using cached content: %s", flagged.Result.Reason)
+		recordPersonalizationFlag(ctx, flagged)
+		return content
+	} else if err != nil {
+		log.Printf("⚠ Personalized examples not moderated, using cached content: %v", err)
+		return content
+	}
+
+	recordContentCacheMetric(componentType, cacheMetricPersonalization)
+	return personalized
+}
+
+// emitCachedContent emite el contenido del caché con los mismos eventos que el streaming
+func emitCachedContent(componentType string, content map[string]interface{}, onEvent func(ContentStreamEvent)) {
+	if titulo, ok := content["titulo"].(string); ok {
+		onEvent(ContentStreamEvent{Tipo: ContentStreamEventTitulo, Titulo: titulo})
+	}
+	campos := make([]string, 0, len(content))
+	for campo := range content {
+		campos = append(campos, campo)
+	}
+	sort.Strings(campos)
+	for _, campo := range campos {
+		if valor, ok := content[campo].(string); ok && campo != "titulo" {
+			onEvent(ContentStreamEvent{Tipo: ContentStreamEventCampo, Campo: campo, Valor: valor})
+		}
+	}
+	arrayField := streamedArrayFields[componentType]
+	if items, ok := content[arrayField].([]interface{}); ok {
+		for i, item := range items {
+			if bloque, ok := item.(map[string]interface{}); ok {
+				onEvent(ContentStreamEvent{Tipo: ContentStreamEventBloque, Campo: arrayField, Indice: i, Bloque: bloque})
+			}
+		}
+	}
+}
+
+// PurgeStaleContentCache borra las entradas generadas con versiones de prompts que ya no se asignan
+// (retiradas del experimento o reemplazadas por una versión nueva)
+func PurgeStaleContentCache() (int64, error) {
+	var active [][]interface{}
+	for tipo, versions := range activeComponentPromptVersions() {
+		for _, version := range versions {
+			active = append(active, []interface{}{tipo, version})
+		}
+	}
+	result := db.DB.Where("(tipo_componente, prompt_version) NOT IN ?", active).Delete(&models.ComponentContentCache{})
+	return result.RowsAffected, result.Error
+}
+
+// activeComponentPromptVersions retorna las versiones con peso de cada tipo de componente
+func activeComponentPromptVersions() map[string][]string {
+	registry := currentPrompts()
+	versions := make(map[string][]string)
+	for _, tipo := range models.AvailableComponentTypes() {
+		versions[tipo] = registry.activeVersions(tipo)
+	}
+	return versions
+}
+
+// InvalidateContentCache borra las entradas del caché; con oaBloomObjectiveID > 0 solo las de ese objetivo
+func InvalidateContentCache(oaBloomObjectiveID uint) (int64, error) {
+	query := db.DB.Where("1 = 1")
+	if oaBloomObjectiveID > 0 {
+		query = db.DB.Where("oa_bloom_objective_id = ?", oaBloomObjectiveID)
+	}
+	result := query.Delete(&models.ComponentContentCache{})
+	return result.RowsAffected, result.Error
+}
+
+// GetContentCacheStats suma los contadores diarios del caché en [from, to)
+func GetContentCacheStats(from, to time.Time) (*ContentCacheStats, error) {
+	stats := &ContentCacheStats{From: from, To: to, PromptVersions: activeComponentPromptVersions(), PorTipo: []ContentCacheTypeStats{}}
+
+	if err := db.DB.Model(&models.ComponentContentCache{}).Count(&stats.Entradas).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.DB.Model(&models.ContentCacheMetric{}).
+		Select("tipo_componente, SUM(hits) AS hits, SUM(misses) AS misses, SUM(bypasses) AS bypasses, SUM(personalizaciones) AS personalizaciones").
+		Where("fecha >= ? AND fecha < ?", from, to).
+		Group("tipo_componente").
+		Order("tipo_componente").
+		Scan(&stats.PorTipo).Error; err != nil {
+		return nil, err
+	}
+
+	stats.Totales.TipoComponente = "total"
+	for i := range stats.PorTipo {
+		row := &stats.PorTipo[i]
+		row.HitRate = hitRate(row.Hits, row.Misses)
+		stats.Totales.Hits += row.Hits
+		stats.Totales.Misses += row.Misses
+		stats.Totales.Bypasses += row.Bypasses
+		stats.Totales.Personalizaciones += row.Personalizaciones
+	}
+	stats.Totales.HitRate = hitRate(stats.Totales.Hits, stats.Totales.Misses)
+	return stats, nil
+}
+
+func hitRate(hits, misses int) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
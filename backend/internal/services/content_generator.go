@@ -10,23 +10,33 @@ import (
 	"strings"
 	"time"
 
+	"github.com/platanus-hack-25/lumera_app/internal/db"
 	"github.com/platanus-hack-25/lumera_app/internal/models"
-	openai "github.com/sashabaranov/go-openai"
+	"github.com/platanus-hack-25/lumera_app/pkg/llm"
 )
 
-var openaiClient *openai.Client
+var llmClient llm.LLMClient
 
-// InitOpenAI inicializa el cliente de OpenAI
-func InitOpenAI() error {
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		return fmt.Errorf("OPENAI_API_KEY no está configurada")
+// errLLMNotInitialized se retorna cuando se intenta generar contenido sin cliente LLM
+var errLLMNotInitialized = fmt.Errorf("LLM client not initialized")
+
+// InitLLMClient inicializa el cliente LLM según LLM_MODE (openai, record, replay, auto).
+// Cada llamada queda registrada en llm_calls.
+func InitLLMClient() error {
+	client, err := llm.NewFromEnv()
+	if err != nil {
+		return err
 	}
-	openaiClient = openai.NewClient(apiKey)
-	log.Println("✓ OpenAI client initialized for content generation")
+	llmClient = llm.NewMeteredClient(client, llm.NewGormRecorder(db.DB))
+	log.Printf("✓ LLM client initialized for content generation (mode: %s)", getEnvString("LLM_MODE", "openai"))
 	return nil
 }
 
+// SetLLMClient reemplaza el cliente LLM (p. ej. con un llm.FakeClient o llm.CassetteClient en tests)
+func SetLLMClient(client llm.LLMClient) {
+	llmClient = client
+}
+
 // cleanMarkdownJSON removes markdown code block markers from JSON response
 func cleanMarkdownJSON(content string) string {
 	content = strings.TrimSpace(content)
@@ -67,6 +77,8 @@ type LearningPlanStructure struct {
 	Titulo      string                    `json:"titulo"`
 	Descripcion string                    `json:"descripcion"`
 	Componentes []ComponentStructure       `json:"componentes"`
+	PromptVersion string                   `json:"-"` // versión de plan_structure usada
+	Fuentes     []models.CurriculumCitation `json:"-"` // pasajes curriculares incluidos en el prompt
 }
 
 // ComponentStructure representa la estructura de un componente en el plan
@@ -74,10 +86,15 @@ type ComponentStructure struct {
 	Tipo                string `json:"tipo"`
 	ObjetivoEspecifico  string `json:"objetivo_especifico"`
 	TiempoEstimadoMin   int    `json:"tiempo_estimado_minutos"`
+	Checkpoint          bool   `json:"checkpoint"` // verificar comprensión con preguntas del banco después de este componente
 }
 
 // OAContext contiene el contexto educativo del OA para los prompts
 type OAContext struct {
+	UserID              uint // define la versión de los prompts (experimentos A/B)
+	OABloomObjectiveID  uint
+	MateriaID           uint
+	OAID                uint
 	MateriaNombre       string
 	MateriaDescripcion  string
 	CursoNombre         string
@@ -95,30 +112,52 @@ type OAContext struct {
 	FormatoPreferido     string
 	TipoActividad        []string
 	CanalPreferido       string
+
+	// Preguntas del banco disponibles para GuidedPracticeQuiz
+	PreguntasPractica    int
+
+	// Comentarios del estudiante que motivaron una regeneración
+	FeedbackEstudiante   []string
+
+	// Pasajes de los documentos curriculares más cercanos al objetivo (ver withCurriculumReferences)
+	Referencias          []CurriculumPassage
+
+	// Catálogo activo de conceptos erróneos de la materia, para etiquetar los distractores
+	ConceptosErroneos    []models.Misconception
 }
 
-// GenerateLearningPlanStructure genera la estructura del plan de aprendizaje
-func GenerateLearningPlanStructure(oaContext OAContext) (*LearningPlanStructure, error) {
+// GenerateLearningPlanStructure genera la estructura del plan de aprendizaje.
+// ctx debe traer el usuario (llm.WithUser) para contabilizar el costo.
+func GenerateLearningPlanStructure(ctx context.Context, oaContext OAContext) (*LearningPlanStructure, error) {
+	if llmClient == nil {
+		return nil, errLLMNotInitialized
+	}
+
 	model := getEnvString("OPENAI_MODEL", "gpt-4o-mini")
 	timeout := getEnvInt("OPENAI_TIMEOUT_SECONDS", 45)
 	maxRetries := getEnvInt("OPENAI_MAX_RETRIES", 3)
 
-	prompt := buildPlanStructurePrompt(oaContext)
+	oaContext, fuentes := withCurriculumReferences(ctx, oaContext, oaContext.ObjetivoEspecifico)
+	prompt, promptVersion, err := buildPlanStructurePrompt(oaContext)
+	if err != nil {
+		return nil, err
+	}
+	ctx = llm.WithFeature(ctx, llm.FeatureLearningPlanStructure)
 
 	var lastError error
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+		callCtx, cancel := context.WithTimeout(llm.WithAttempt(ctx, attempt), time.Duration(timeout)*time.Second)
 		defer cancel()
 
-		resp, err := openaiClient.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		resp, err := llmClient.Chat(callCtx, llm.ChatRequest{
 			Model: model,
-			Messages: []openai.ChatCompletionMessage{
+			Messages: []llm.Message{
 				{
-					Role:    openai.ChatMessageRoleSystem,
+					Role:    llm.RoleSystem,
 					Content: "Eres un experto en diseño instruccional para el currículo chileno de enseñanza media. Tu tarea es crear planes de aprendizaje personalizados y efectivos.",
 				},
 				{
-					Role:    openai.ChatMessageRoleUser,
+					Role:    llm.RoleUser,
 					Content: prompt,
 				},
 			},
@@ -137,13 +176,7 @@ func GenerateLearningPlanStructure(oaContext OAContext) (*LearningPlanStructure,
 			return nil, fmt.Errorf("failed after %d attempts: %w", maxRetries, err)
 		}
 
-		if len(resp.Choices) == 0 {
-			lastError = fmt.Errorf("no response from OpenAI")
-			continue
-		}
-
-		content := resp.Choices[0].Message.Content
-		content = cleanMarkdownJSON(content)
+		content := cleanMarkdownJSON(resp.Content)
 
 		var result LearningPlanStructure
 		err = json.Unmarshal([]byte(content), &result)
@@ -179,58 +212,62 @@ func GenerateLearningPlanStructure(oaContext OAContext) (*LearningPlanStructure,
 			return nil, lastError
 		}
 
-		// Validar que todos los componentes tengan tipo válido
+		// Los tipos que no corresponden al nivel de Bloom (o sin preguntas en el banco) se enseñan como ExplainAndExploreSlide
+		allowed := allowedComponentTypes(oaContext)
 		for i, comp := range result.Componentes {
-			if !models.IsValidComponentType(comp.Tipo) {
-				lastError = fmt.Errorf("invalid component type at index %d: %s", i, comp.Tipo)
-				if attempt < maxRetries {
-					log.Printf("⚠ Invalid component type (attempt %d/%d): %s. Retrying...", attempt, maxRetries, comp.Tipo)
-					time.Sleep(2 * time.Second)
-					continue
-				}
-				return nil, lastError
+			if !containsString(allowed, comp.Tipo) {
+				log.Printf("⚠ Component type %s not allowed for Bloom level %d, using %s", comp.Tipo, oaContext.BloomLevelNumero, models.ComponentTipoExplainAndExplore)
+				result.Componentes[i].Tipo = models.ComponentTipoExplainAndExplore
 			}
 		}
 
-		log.Printf("✓ Generated learning plan structure: %s (%d components)", result.Titulo, len(result.Componentes))
+		result.PromptVersion = promptVersion
+		result.Fuentes = fuentes
+		log.Printf("✓ Generated learning plan structure: %s (%d components, prompt %s)", result.Titulo, len(result.Componentes), promptVersion)
 		return &result, nil
 	}
 
 	return nil, lastError
 }
 
-// GenerateComponentContent genera el contenido (props) de un componente específico
-func GenerateComponentContent(componentType string, oaContext OAContext, componentObjective string) (map[string]interface{}, error) {
+// GenerateComponentContent genera el contenido (props) de un componente específico y retorna la versión del
+// prompt que lo produjo (la de la entrada del caché si hubo hit). ctx debe traer el usuario (llm.WithUser)
+// para contabilizar el costo.
+func GenerateComponentContent(ctx context.Context, componentType string, oaContext OAContext, componentObjective string) (map[string]interface{}, string, error) {
 	if !models.IsValidComponentType(componentType) {
-		return nil, fmt.Errorf("invalid component type: %s", componentType)
+		return nil, "", fmt.Errorf("invalid component type: %s", componentType)
+	}
+	if llmClient == nil {
+		return nil, "", errLLMNotInitialized
 	}
 
 	model := getEnvString("OPENAI_MODEL", "gpt-4o-mini")
 	timeout := getEnvInt("OPENAI_TIMEOUT_SECONDS", 60) // Más tiempo para contenido detallado
 	maxRetries := getEnvInt("OPENAI_MAX_RETRIES", 3)
 
-	prompt := buildComponentPrompt(componentType, oaContext, componentObjective)
+	// Con el caché compartido se genera sin intereses ni profesión y los ejemplos se personalizan después
+	useCache := useContentCache(componentType, oaContext)
+	promptContext := oaContext
+	if useCache {
+		if content, promptVersion, ok := cachedComponentContent(ctx, componentType, oaContext, componentObjective); ok {
+			return content, promptVersion, nil
+		}
+		promptContext = genericContext(oaContext)
+	}
+
+	promptContext, fuentes := withCurriculumReferences(ctx, promptContext, componentObjective)
+	prompt, promptVersion, err := buildComponentPrompt(componentType, promptContext, componentObjective)
+	if err != nil {
+		return nil, "", err
+	}
+	ctx = llm.WithFeature(ctx, llm.FeatureComponentContent)
 
 	var lastError error
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+		callCtx, cancel := context.WithTimeout(llm.WithAttempt(ctx, attempt), time.Duration(timeout)*time.Second)
 		defer cancel()
 
-		resp, err := openaiClient.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-			Model: model,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: "Eres un experto en diseño de contenido educativo para el currículo chileno de enseñanza media. Creas contenido pedagógico claro, preciso y adaptado al nivel del estudiante.",
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
-				},
-			},
-			Temperature: 0.7,
-			MaxTokens:   3000,
-		})
+		resp, err := llmClient.Chat(callCtx, componentChatRequest(model, prompt))
 
 		if err != nil {
 			lastError = err
@@ -240,16 +277,10 @@ func GenerateComponentContent(componentType string, oaContext OAContext, compone
 				time.Sleep(waitTime)
 				continue
 			}
-			return nil, fmt.Errorf("failed after %d attempts: %w", maxRetries, err)
-		}
-
-		if len(resp.Choices) == 0 {
-			lastError = fmt.Errorf("no response from OpenAI")
-			continue
+			return nil, "", fmt.Errorf("failed after %d attempts: %w", maxRetries, err)
 		}
 
-		content := resp.Choices[0].Message.Content
-		content = cleanMarkdownJSON(content)
+		content := cleanMarkdownJSON(resp.Content)
 
 		var result map[string]interface{}
 		err = json.Unmarshal([]byte(content), &result)
@@ -261,10 +292,19 @@ func GenerateComponentContent(componentType string, oaContext OAContext, compone
 				time.Sleep(waitTime)
 				continue
 			}
-			return nil, lastError
+			return nil, "", lastError
 		}
 
 		// Validación básica según el tipo de componente
+		if err := finalizeComponentContent(componentType, oaContext, result); err != nil {
+			lastError = err
+			if attempt < maxRetries {
+				log.Printf("⚠ Invalid content structure (attempt %d/%d): %v. Retrying...", attempt, maxRetries, err)
+				time.Sleep(2 * time.Second)
+				continue
+			}
+			return nil, "", lastError
+		}
 		if err := validateComponentContent(componentType, result); err != nil {
 			lastError = err
 			if attempt < maxRetries {
@@ -272,28 +312,283 @@ func GenerateComponentContent(componentType string, oaContext OAContext, compone
 				time.Sleep(2 * time.Second)
 				continue
 			}
-			return nil, lastError
+			return nil, "", lastError
 		}
 
+		// Lo marcado por la moderación no se reintenta: queda en cuarentena para revisión
+		if err := moderateGeneratedContent(ctx, result); err != nil {
+			return nil, "", err
+		}
+		attachCurriculumCitations(result, fuentes)
+
 		log.Printf("✓ Generated content for component type: %s", componentType)
-		return result, nil
+		if useCache {
+			return cacheGeneratedContent(ctx, componentType, oaContext, componentObjective, promptVersion, result), promptVersion, nil
+		}
+		return result, promptVersion, nil
 	}
 
-	return nil, lastError
+	return nil, "", lastError
+}
+
+// componentChatRequest arma el request de contenido de un componente (compartido con el streaming)
+func componentChatRequest(model, prompt string) llm.ChatRequest {
+	return llm.ChatRequest{
+		Model: model,
+		Messages: []llm.Message{
+			{
+				Role:    llm.RoleSystem,
+				Content: "Eres un experto en diseño de contenido educativo para el currículo chileno de enseñanza media. Creas contenido pedagógico claro, preciso y adaptado al nivel del estudiante.",
+			},
+			{
+				Role:    llm.RoleUser,
+				Content: prompt,
+			},
+		},
+		Temperature: 0.7,
+		MaxTokens:   3000,
+	}
+}
+
+// validBlockTypes son los tipos de bloque permitidos en ExplainAndExploreSlide
+var validBlockTypes = map[string]bool{
+	"texto":       true,
+	"ejemplo":     true,
+	"definicion":  true,
+	"nota":        true,
+	"ejercicio":   true,
+	"resumen":     true,
+	"comparacion": true,
+}
+
+// finalizeComponentContent completa el contenido generado con datos de la base antes de validarlo
+func finalizeComponentContent(componentType string, oaContext OAContext, content map[string]interface{}) error {
+	switch componentType {
+	case models.ComponentTipoGuidedPracticeQuiz:
+		return attachGuidedPracticeItems(oaContext.OABloomObjectiveID, content)
+	case models.ComponentTipoReadingPassage:
+		tagGeneratedMisconceptions(oaContext.ConceptosErroneos, content)
+	}
+	return nil
 }
 
-// validateComponentContent valida que el contenido de ExplainAndExploreSlide tenga los campos necesarios
+// validateComponentContent valida que el contenido tenga los campos necesarios según el tipo de componente
 func validateComponentContent(componentType string, content map[string]interface{}) error {
-	// Validar que el componente sea ExplainAndExploreSlide
-	if componentType != models.ComponentTipoExplainAndExplore {
+	if _, ok := content["titulo"].(string); !ok {
+		return fmt.Errorf("missing required field: titulo")
+	}
+
+	switch componentType {
+	case models.ComponentTipoExplainAndExplore:
+		return validateExplainAndExploreContent(content)
+	case models.ComponentTipoGuidedPracticeQuiz:
+		return validateGuidedPracticeQuizContent(content)
+	case models.ComponentTipoWorkedExample:
+		return validateWorkedExampleContent(content)
+	case models.ComponentTipoReadingPassage:
+		return validateReadingPassageContent(content)
+	case models.ComponentTipoFlashcardDeck:
+		return validateFlashcardDeckContent(content)
+	case models.ComponentTipoReflectionPrompt:
+		return validateReflectionPromptContent(content)
+	default:
 		return fmt.Errorf("unknown component type: %s", componentType)
 	}
+}
 
-	// Campos requeridos para ExplainAndExploreSlide
-	if _, ok := content["titulo"]; !ok {
-		return fmt.Errorf("missing required field: titulo")
+// objectArray retorna content[field] como arreglo de objetos con al menos min elementos
+func objectArray(content map[string]interface{}, field string, min int) ([]map[string]interface{}, error) {
+	raw, ok := content[field]
+	if !ok {
+		return nil, fmt.Errorf("missing required field: %s", field)
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an array", field)
+	}
+	if len(list) < min {
+		return nil, fmt.Errorf("%s must have at least %d items", field, min)
+	}
+
+	objects := make([]map[string]interface{}, len(list))
+	for i, item := range list {
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s at index %d is not an object", field, i)
+		}
+		objects[i] = object
+	}
+	return objects, nil
+}
+
+// requireStrings verifica que el objeto tenga los campos de texto no vacíos indicados
+func requireStrings(object map[string]interface{}, where string, fields ...string) error {
+	for _, field := range fields {
+		value, ok := object[field].(string)
+		if !ok || strings.TrimSpace(value) == "" {
+			return fmt.Errorf("%s missing '%s' field", where, field)
+		}
+	}
+	return nil
+}
+
+// validateMultipleChoice verifica opciones y que respuesta_correcta sea una de ellas
+func validateMultipleChoice(object map[string]interface{}, where string) error {
+	opciones, ok := object["opciones"].(map[string]interface{})
+	if !ok || len(opciones) < 2 {
+		return fmt.Errorf("%s must have at least 2 'opciones'", where)
+	}
+	respuesta, _ := object["respuesta_correcta"].(string)
+	if _, ok := opciones[respuesta]; !ok {
+		return fmt.Errorf("%s has invalid respuesta_correcta: %q", where, respuesta)
+	}
+	return nil
+}
+
+// validateGuidedPracticeQuizContent valida un GuidedPracticeQuiz ya completado con las preguntas del banco
+func validateGuidedPracticeQuizContent(content map[string]interface{}) error {
+	preguntas, err := objectArray(content, "preguntas", guidedPracticeMinItems)
+	if err != nil {
+		return err
+	}
+	for i, pregunta := range preguntas {
+		where := fmt.Sprintf("pregunta at index %d", i)
+		if _, ok := pregunta["question_id"]; !ok {
+			return fmt.Errorf("%s missing 'question_id' field", where)
+		}
+		if err := requireStrings(pregunta, where, "pregunta", "pista", "estrategia"); err != nil {
+			return err
+		}
+		if err := validateMultipleChoice(pregunta, where); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateWorkedExampleContent valida los ejemplos resueltos y que el desvanecimiento sea progresivo
+func validateWorkedExampleContent(content map[string]interface{}) error {
+	ejemplos, err := objectArray(content, "ejemplos", 2)
+	if err != nil {
+		return err
+	}
+
+	previousStudentSteps := 0
+	for i, ejemplo := range ejemplos {
+		where := fmt.Sprintf("ejemplo at index %d", i)
+		if err := requireStrings(ejemplo, where, "enunciado", "respuesta_final"); err != nil {
+			return err
+		}
+		pasos, err := objectArray(ejemplo, "pasos", 2)
+		if err != nil {
+			return fmt.Errorf("%s: %w", where, err)
+		}
+
+		studentSteps := 0
+		for j, paso := range pasos {
+			pasoWhere := fmt.Sprintf("%s, paso %d", where, j)
+			if err := requireStrings(paso, pasoWhere, "descripcion", "resultado"); err != nil {
+				return err
+			}
+			switch paso["completado_por"] {
+			case "modelo":
+			case "estudiante":
+				studentSteps++
+				if err := requireStrings(paso, pasoWhere, "pista"); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("%s has invalid completado_por: %v", pasoWhere, paso["completado_por"])
+			}
+		}
+
+		if i == 0 && studentSteps > 0 {
+			return fmt.Errorf("first ejemplo must be fully worked by the model")
+		}
+		if studentSteps < previousStudentSteps {
+			return fmt.Errorf("%s removes student steps; fading must be progressive", where)
+		}
+		previousStudentSteps = studentSteps
+	}
+
+	if previousStudentSteps == 0 {
+		return fmt.Errorf("no ejemplo has steps for the student to complete")
+	}
+	return nil
+}
+
+// validateReadingPassageContent valida el texto y sus preguntas de comprensión
+func validateReadingPassageContent(content map[string]interface{}) error {
+	if err := requireStrings(content, "content", "texto"); err != nil {
+		return err
+	}
+	preguntas, err := objectArray(content, "preguntas", 2)
+	if err != nil {
+		return err
+	}
+
+	validNiveles := map[string]bool{"literal": true, "inferencial": true, "critica": true}
+	for i, pregunta := range preguntas {
+		where := fmt.Sprintf("pregunta at index %d", i)
+		if err := requireStrings(pregunta, where, "pregunta", "explicacion"); err != nil {
+			return err
+		}
+		if nivel, _ := pregunta["nivel"].(string); !validNiveles[nivel] {
+			return fmt.Errorf("%s has invalid nivel: %s", where, nivel)
+		}
+		if err := validateMultipleChoice(pregunta, where); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateFlashcardDeckContent valida las tarjetas del mazo
+func validateFlashcardDeckContent(content map[string]interface{}) error {
+	tarjetas, err := objectArray(content, "tarjetas", 4)
+	if err != nil {
+		return err
+	}
+	for i, tarjeta := range tarjetas {
+		if err := requireStrings(tarjeta, fmt.Sprintf("tarjeta at index %d", i), "frente", "reverso"); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
+// validateReflectionPromptContent valida el contexto y las preguntas abiertas
+func validateReflectionPromptContent(content map[string]interface{}) error {
+	if err := requireStrings(content, "content", "contexto"); err != nil {
+		return err
+	}
+	for _, field := range []string{"preguntas", "criterios"} {
+		list, ok := content[field].([]interface{})
+		if !ok || len(list) == 0 {
+			return fmt.Errorf("%s must be a non-empty array", field)
+		}
+		for i, item := range list {
+			if text, ok := item.(string); !ok || strings.TrimSpace(text) == "" {
+				return fmt.Errorf("%s at index %d must be a non-empty string", field, i)
+			}
+		}
+	}
+	return nil
+}
+
+// containsString indica si value está en list
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// validateExplainAndExploreContent valida que el contenido de ExplainAndExploreSlide tenga los campos necesarios
+func validateExplainAndExploreContent(content map[string]interface{}) error {
+	// Campos requeridos para ExplainAndExploreSlide
 	if _, ok := content["bloques"]; !ok {
 		return fmt.Errorf("missing required field: bloques")
 	}
@@ -308,17 +603,6 @@ func validateComponentContent(componentType string, content map[string]interface
 		return fmt.Errorf("bloques array cannot be empty")
 	}
 
-	// Validar tipos de bloques permitidos
-	validBlockTypes := map[string]bool{
-		"texto":       true,
-		"ejemplo":     true,
-		"definicion":  true,
-		"nota":        true,
-		"ejercicio":   true,
-		"resumen":     true,
-		"comparacion": true,
-	}
-
 	for i, bloque := range bloques {
 		bloqueMap, ok := bloque.(map[string]interface{})
 		if !ok {
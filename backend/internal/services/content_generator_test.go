@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"github.com/platanus-hack-25/lumera_app/pkg/llm"
+)
+
+// useCassettes replays testdata/cassettes as the LLM client. LLM_MODE=record (with OPENAI_API_KEY) re-records them.
+func useCassettes(t *testing.T) {
+	t.Helper()
+	if mode := getEnvString("LLM_MODE", ""); mode != string(llm.CassetteRecord) {
+		t.Setenv("LLM_MODE", string(llm.CassetteReplay))
+	}
+	t.Setenv("LLM_CASSETTE_DIR", "testdata/cassettes")
+	t.Setenv("OPENAI_MODEL", "gpt-4o-mini")
+	t.Setenv("OPENAI_MAX_RETRIES", "1")
+	t.Setenv("CURRICULUM_RAG_ENABLED", "false")
+	t.Setenv("CONTENT_CACHE_ENABLED", "false")
+
+	client, err := llm.NewFromEnv()
+	if err != nil {
+		t.Fatalf("cassette client: %v", err)
+	}
+	previous := llmClient
+	SetLLMClient(client)
+	t.Cleanup(func() { llmClient = previous })
+}
+
+func cassetteOAContext() OAContext {
+	return OAContext{
+		UserID:             1,
+		OABloomObjectiveID: 1,
+		MateriaNombre:      "Matemática",
+		MateriaDescripcion: "Números y álgebra",
+		CursoNombre:        "1° Medio",
+		OATitulo:           "Potencias",
+		OADescripcion:      "Mostrar que comprenden las potencias de base racional y exponente entero",
+		BloomLevelNombre:   "Comprender",
+		BloomLevelNumero:   2,
+		BloomDescripcion:   "Explicar ideas o conceptos",
+		ObjetivoEspecifico: "Explicar las propiedades de las potencias de igual base",
+		IndicadoresLogro:   []string{"Explican la multiplicación de potencias de igual base"},
+	}
+}
+
+func TestGenerateLearningPlanStructureReplay(t *testing.T) {
+	useCassettes(t)
+
+	structure, err := GenerateLearningPlanStructure(context.Background(), cassetteOAContext())
+	if err != nil {
+		t.Fatalf("GenerateLearningPlanStructure: %v", err)
+	}
+	if structure.Titulo != "Propiedades de las potencias de igual base" {
+		t.Errorf("titulo = %q", structure.Titulo)
+	}
+	if structure.PromptVersion != "v1" {
+		t.Errorf("prompt version = %q, want v1", structure.PromptVersion)
+	}
+
+	want := []string{
+		models.ComponentTipoExplainAndExplore,
+		models.ComponentTipoFlashcardDeck,
+		models.ComponentTipoReadingPassage,
+		// ReflectionPrompt is not a Bloom 2 type and falls back to ExplainAndExploreSlide
+		models.ComponentTipoExplainAndExplore,
+	}
+	if len(structure.Componentes) != len(want) {
+		t.Fatalf("got %d components, want %d", len(structure.Componentes), len(want))
+	}
+	for i, tipo := range want {
+		if structure.Componentes[i].Tipo != tipo {
+			t.Errorf("component %d tipo = %s, want %s", i, structure.Componentes[i].Tipo, tipo)
+		}
+	}
+	if !structure.Componentes[1].Checkpoint {
+		t.Error("component 1 should be a checkpoint")
+	}
+}
+
+func TestGenerateComponentContentReplay(t *testing.T) {
+	useCassettes(t)
+
+	content, promptVersion, err := GenerateComponentContent(context.Background(), models.ComponentTipoFlashcardDeck, cassetteOAContext(),
+		"Recordar las propiedades de las potencias de igual base")
+	if err != nil {
+		t.Fatalf("GenerateComponentContent: %v", err)
+	}
+	if promptVersion != "v1" {
+		t.Errorf("prompt version = %q, want v1", promptVersion)
+	}
+	tarjetas, ok := content["tarjetas"].([]interface{})
+	if !ok || len(tarjetas) != 4 {
+		t.Fatalf("tarjetas = %v", content["tarjetas"])
+	}
+	first := tarjetas[0].(map[string]interface{})
+	if first["frente"] != "¿Qué pasa con los exponentes al multiplicar potencias de igual base?" {
+		t.Errorf("frente = %q", first["frente"])
+	}
+}
+
+func TestGenerateLearningPlanStructureReplayMiss(t *testing.T) {
+	useCassettes(t)
+	if getEnvString("LLM_MODE", "") == string(llm.CassetteRecord) {
+		t.Skip("recording")
+	}
+
+	oaContext := cassetteOAContext()
+	oaContext.ObjetivoEspecifico = "Un objetivo sin grabación"
+	if _, err := GenerateLearningPlanStructure(context.Background(), oaContext); !errors.Is(err, llm.ErrCassetteMiss) {
+		t.Fatalf("err = %v, want a cassette miss", err)
+	}
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"github.com/platanus-hack-25/lumera_app/pkg/llm"
+)
+
+// Tipos de evento emitidos mientras se genera un componente en streaming
+const (
+	ContentStreamEventTitulo    = "titulo"
+	ContentStreamEventCampo     = "campo"
+	ContentStreamEventBloque    = "bloque"
+	ContentStreamEventReintento = "reintento"
+)
+
+// streamedArrayFields indica qué arreglo del contenido se emite elemento por elemento según el tipo.
+// GuidedPracticeQuiz no se emite por partes: sus preguntas se completan con el banco al final.
+var streamedArrayFields = map[string]string{
+	models.ComponentTipoExplainAndExplore: "bloques",
+	models.ComponentTipoWorkedExample:     "ejemplos",
+	models.ComponentTipoReadingPassage:    "preguntas",
+	models.ComponentTipoFlashcardDeck:     "tarjetas",
+}
+
+// StreamedArrayField retorna el arreglo del contenido que se emite por partes para un tipo de componente
+func StreamedArrayField(componentType string) string {
+	return streamedArrayFields[componentType]
+}
+
+// ContentStreamEvent es una pieza del contenido que ya se pudo parsear
+type ContentStreamEvent struct {
+	Tipo    string                 `json:"tipo"`
+	Titulo  string                 `json:"titulo,omitempty"`
+	Campo   string                 `json:"campo,omitempty"` // campo de texto (evento "campo") o arreglo (evento "bloque")
+	Valor   string                 `json:"valor,omitempty"`
+	Indice  int                    `json:"indice"`
+	Bloque  map[string]interface{} `json:"bloque,omitempty"`
+	Intento int                    `json:"intento,omitempty"`
+	Motivo  string                 `json:"motivo,omitempty"`
+}
+
+// contentStreamParser es un parser JSON incremental para el contenido de un componente.
+// Recibe el texto a medida que llega y avisa cuando termina cada campo de texto del nivel raíz
+// y cada elemento de arrayField (p. ej. "bloques"), sin esperar a que el objeto completo sea JSON válido.
+type contentStreamParser struct {
+	buf      []byte
+	started  bool // ya se vio el '{' raíz (se ignora texto previo como ```json)
+	depth    int
+	inString bool
+	escape   bool
+
+	strStart   int    // offset de la comilla inicial del string actual
+	lastString string // último string completo en el nivel raíz
+	key        string // clave actual del objeto raíz
+	afterColon bool   // el próximo valor del nivel raíz pertenece a key
+
+	arrayField string
+	inBloques  bool
+	blockStart int
+	blocks     int
+
+	onCampo  func(campo, valor string)
+	onBloque func(indice int, bloque map[string]interface{})
+}
+
+// Write procesa un nuevo fragmento del stream
+func (p *contentStreamParser) Write(delta string) {
+	offset := len(p.buf)
+	p.buf = append(p.buf, delta...)
+
+	for i := offset; i < len(p.buf); i++ {
+		c := p.buf[i]
+
+		if !p.started {
+			if c == '{' {
+				p.started = true
+				p.depth = 1
+			}
+			continue
+		}
+
+		if p.inString {
+			switch {
+			case p.escape:
+				p.escape = false
+			case c == '\\':
+				p.escape = true
+			case c == '"':
+				p.inString = false
+				p.endString(i)
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			p.inString = true
+			p.strStart = i
+		case ':':
+			if p.depth == 1 {
+				p.key = p.lastString
+				p.afterColon = true
+			}
+		case ',':
+			if p.depth == 1 {
+				p.afterColon = false
+			}
+		case '{', '[':
+			p.depth++
+			if c == '[' && p.depth == 2 && p.afterColon && p.arrayField != "" && p.key == p.arrayField {
+				p.inBloques = true
+			}
+			if c == '{' && p.inBloques && p.depth == 3 {
+				p.blockStart = i
+			}
+		case '}', ']':
+			if c == '}' && p.inBloques && p.depth == 3 {
+				p.endBlock(i)
+			}
+			p.depth--
+			if c == ']' && p.depth == 1 {
+				p.inBloques = false
+			}
+		}
+	}
+}
+
+func (p *contentStreamParser) endString(end int) {
+	if p.depth != 1 {
+		return
+	}
+
+	var value string
+	if err := json.Unmarshal(p.buf[p.strStart:end+1], &value); err != nil {
+		return
+	}
+
+	if p.afterColon {
+		if p.onCampo != nil {
+			p.onCampo(p.key, value)
+		}
+		p.afterColon = false
+		return
+	}
+	p.lastString = value
+}
+
+func (p *contentStreamParser) endBlock(end int) {
+	var bloque map[string]interface{}
+	if err := json.Unmarshal(p.buf[p.blockStart:end+1], &bloque); err != nil {
+		return
+	}
+
+	indice := p.blocks
+	p.blocks++
+	if p.onBloque != nil {
+		p.onBloque(indice, bloque)
+	}
+}
+
+// StreamComponentContent genera el contenido de un componente usando la API de streaming.
+// onEvent recibe el título y cada bloque apenas se terminan de parsear; si un intento falla
+// se emite un evento "reintento" y el cliente debe descartar lo recibido hasta ese momento.
+// Retorna el contenido completo ya validado y la versión del prompt que lo produjo (la del caché si hubo hit).
+func StreamComponentContent(ctx context.Context, componentType string, oaContext OAContext, componentObjective string, onEvent func(ContentStreamEvent)) (map[string]interface{}, string, error) {
+	if !models.IsValidComponentType(componentType) {
+		return nil, "", fmt.Errorf("invalid component type: %s", componentType)
+	}
+	if llmClient == nil {
+		return nil, "", errLLMNotInitialized
+	}
+
+	model := getEnvString("OPENAI_MODEL", "gpt-4o-mini")
+	timeout := getEnvInt("OPENAI_TIMEOUT_SECONDS", 60)
+	maxRetries := getEnvInt("OPENAI_MAX_RETRIES", 3)
+
+	// Con el caché compartido se genera sin intereses ni profesión y los ejemplos se personalizan después
+	useCache := useContentCache(componentType, oaContext)
+	promptContext := oaContext
+	if useCache {
+		if content, promptVersion, ok := cachedComponentContent(ctx, componentType, oaContext, componentObjective); ok {
+			emitCachedContent(componentType, content, onEvent)
+			return content, promptVersion, nil
+		}
+		promptContext = genericContext(oaContext)
+	}
+	onEvent = moderatedStreamEvents(ctx, onEvent)
+
+	promptContext, fuentes := withCurriculumReferences(ctx, promptContext, componentObjective)
+	prompt, promptVersion, err := buildComponentPrompt(componentType, promptContext, componentObjective)
+	if err != nil {
+		return nil, "", err
+	}
+	ctx = llm.WithFeature(ctx, llm.FeatureComponentContent)
+
+	var lastError error
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		if attempt > 1 {
+			onEvent(ContentStreamEvent{Tipo: ContentStreamEventReintento, Intento: attempt, Motivo: lastError.Error()})
+		}
+
+		parser := &contentStreamParser{
+			arrayField: streamedArrayFields[componentType],
+			onCampo: func(campo, valor string) {
+				if campo == "titulo" {
+					onEvent(ContentStreamEvent{Tipo: ContentStreamEventTitulo, Titulo: valor})
+					return
+				}
+				onEvent(ContentStreamEvent{Tipo: ContentStreamEventCampo, Campo: campo, Valor: valor})
+			},
+			onBloque: func(indice int, bloque map[string]interface{}) {
+				// Los bloques con tipo desconocido no se muestran; la validación final decide si se reintenta
+				if componentType == models.ComponentTipoExplainAndExplore {
+					if tipo, _ := bloque["tipo"].(string); !validBlockTypes[tipo] {
+						return
+					}
+				}
+				onEvent(ContentStreamEvent{Tipo: ContentStreamEventBloque, Campo: streamedArrayFields[componentType], Indice: indice, Bloque: bloque})
+			},
+		}
+
+		callCtx, cancel := context.WithTimeout(llm.WithAttempt(ctx, attempt), time.Duration(timeout)*time.Second)
+		resp, err := llm.ChatStream(callCtx, llmClient, componentChatRequest(model, prompt), func(delta string) error {
+			parser.Write(delta)
+			return nil
+		})
+		cancel()
+
+		if err != nil {
+			lastError = err
+			if attempt < maxRetries && ctx.Err() == nil {
+				waitTime := time.Duration(attempt*2) * time.Second
+				log.Printf("⚠ OpenAI stream error (attempt %d/%d): %v. Retrying in %v...", attempt, maxRetries, err, waitTime)
+				time.Sleep(waitTime)
+				continue
+			}
+			return nil, "", fmt.Errorf("failed after %d attempts: %w", attempt, err)
+		}
+
+		content := cleanMarkdownJSON(strings.TrimSpace(resp.Content))
+
+		var result map[string]interface{}
+		if err := json.Unmarshal([]byte(content), &result); err != nil {
+			lastError = fmt.Errorf("failed to parse OpenAI response as JSON: %w\nContent: %s", err, content)
+			if attempt < maxRetries {
+				log.Printf("⚠ JSON parse error (attempt %d/%d): %v. Retrying...", attempt, maxRetries, err)
+				continue
+			}
+			return nil, "", lastError
+		}
+
+		if err := finalizeComponentContent(componentType, oaContext, result); err != nil {
+			lastError = err
+			if attempt < maxRetries {
+				log.Printf("⚠ Invalid content structure (attempt %d/%d): %v. Retrying...", attempt, maxRetries, err)
+				continue
+			}
+			return nil, "", lastError
+		}
+		if err := validateComponentContent(componentType, result); err != nil {
+			lastError = err
+			if attempt < maxRetries {
+				log.Printf("⚠ Invalid content structure (attempt %d/%d): %v. Retrying...", attempt, maxRetries, err)
+				continue
+			}
+			return nil, "", lastError
+		}
+
+		// Lo marcado por la moderación no se reintenta: queda en cuarentena para revisión
+		if err := moderateGeneratedContent(ctx, result); err != nil {
+			return nil, "", err
+		}
+		attachCurriculumCitations(result, fuentes)
+
+		log.Printf("✓ Streamed content for component type: %s (%d blocks)", componentType, parser.blocks)
+		if useCache {
+			// Los ejemplos personalizados llegan en el evento final con el contenido completo
+			return cacheGeneratedContent(ctx, componentType, oaContext, componentObjective, promptVersion, result), promptVersion, nil
+		}
+		return result, promptVersion, nil
+	}
+
+	return nil, "", lastError
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+type parsedStream struct {
+	campos  map[string]string
+	bloques []map[string]interface{}
+}
+
+// parseInChunks alimenta el parser con fragmentos de size bytes, como llegan del stream
+func parseInChunks(input, arrayField string, size int) parsedStream {
+	result := parsedStream{campos: map[string]string{}}
+	parser := &contentStreamParser{
+		arrayField: arrayField,
+		onCampo:    func(campo, valor string) { result.campos[campo] = valor },
+		onBloque: func(indice int, bloque map[string]interface{}) {
+			if indice != len(result.bloques) {
+				panic("bloques fuera de orden")
+			}
+			result.bloques = append(result.bloques, bloque)
+		},
+	}
+	for start := 0; start < len(input); start += size {
+		end := start + size
+		if end > len(input) {
+			end = len(input)
+		}
+		parser.Write(input[start:end])
+	}
+	return result
+}
+
+func TestContentStreamParser(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		campos  map[string]string
+		bloques []map[string]interface{}
+	}{
+		{
+			name:   "escapes",
+			input:  `{"titulo":"Comillas \"dobles\", barra \\ y salto\nde línea ñ","bloques":[{"texto":"a \"b\" }]"}]}`,
+			campos: map[string]string{"titulo": "Comillas \"dobles\", barra \\ y salto\nde línea ñ"},
+			bloques: []map[string]interface{}{
+				{"texto": `a "b" }]`},
+			},
+		},
+		{
+			name:   "multi-byte runes",
+			input:  `{"titulo":"Potencias ¿aⁿ·aᵐ? 😀","bloques":[{"texto":"niño"},{"texto":"canción"}]}`,
+			campos: map[string]string{"titulo": "Potencias ¿aⁿ·aᵐ? 😀"},
+			bloques: []map[string]interface{}{
+				{"texto": "niño"},
+				{"texto": "canción"},
+			},
+		},
+		{
+			name:   "markdown fence and nested values",
+			input:  "```json\n{\"titulo\":\"T\",\"meta\":{\"bloques\":[{\"x\":1}]},\"bloques\":[{\"tipo\":\"texto\",\"items\":[{\"a\":1}]}],\"resumen\":\"R\"}\n```",
+			campos: map[string]string{"titulo": "T", "resumen": "R"},
+			bloques: []map[string]interface{}{
+				{"tipo": "texto", "items": []interface{}{map[string]interface{}{"a": float64(1)}}},
+			},
+		},
+		{
+			name:   "string values inside arrays are not fields",
+			input:  `{"etiquetas":["a","b"],"titulo":"T","bloques":[]}`,
+			campos: map[string]string{"titulo": "T"},
+		},
+	}
+
+	for _, tt := range tests {
+		// 1 byte parte escapes, claves y runas multi-byte en todos los puntos posibles
+		for _, size := range []int{1, 2, 3, 7, len(tt.input)} {
+			got := parseInChunks(tt.input, "bloques", size)
+			if !reflect.DeepEqual(got.campos, tt.campos) {
+				t.Errorf("%s (chunks of %d): campos = %v, want %v", tt.name, size, got.campos, tt.campos)
+			}
+			if len(got.bloques) != len(tt.bloques) || (len(tt.bloques) > 0 && !reflect.DeepEqual(got.bloques, tt.bloques)) {
+				t.Errorf("%s (chunks of %d): bloques = %v, want %v", tt.name, size, got.bloques, tt.bloques)
+			}
+		}
+	}
+}
+
+func TestContentStreamParserEmitsBeforeTheObjectCloses(t *testing.T) {
+	var titulo string
+	var bloques int
+	parser := &contentStreamParser{
+		arrayField: "tarjetas",
+		onCampo:    func(campo, valor string) { titulo = valor },
+		onBloque:   func(int, map[string]interface{}) { bloques++ },
+	}
+
+	parser.Write(`{"titu`)
+	parser.Write(`lo":"Poten`)
+	if titulo != "" {
+		t.Fatalf("titulo emitted before its string closed: %q", titulo)
+	}
+	parser.Write(`cias","tarjetas":[{"frente":"¿a`)
+	if titulo != "Potencias" {
+		t.Fatalf("titulo = %q after its string closed", titulo)
+	}
+	if bloques != 0 {
+		t.Fatal("incomplete tarjeta emitted")
+	}
+	parser.Write(`ⁿ?","reverso":"b"},{"frente":`)
+	if bloques != 1 {
+		t.Fatalf("got %d tarjetas after the first closed", bloques)
+	}
+}
+
+func TestContentStreamParserSplitRunes(t *testing.T) {
+	input := `{"titulo":"` + strings.Repeat("ñ😀", 8) + `"}`
+	// Los cortes caen dentro de runas: el valor debe llegar entero e intacto
+	got := parseInChunks(input, "", 1)
+	if valor := got.campos["titulo"]; valor != strings.Repeat("ñ😀", 8) || !utf8.ValidString(valor) {
+		t.Errorf("titulo = %q", valor)
+	}
+}
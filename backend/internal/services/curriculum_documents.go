@@ -0,0 +1,540 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"github.com/platanus-hack-25/lumera_app/pkg/embeddings"
+	"github.com/platanus-hack-25/lumera_app/pkg/llm"
+	"github.com/platanus-hack-25/lumera_app/pkg/vectorstore"
+	"gorm.io/gorm"
+)
+
+// ErrCurriculumDocumentNotFound indica que el documento curricular no existe
+var ErrCurriculumDocumentNotFound = errors.New("curriculum document not found")
+
+// ErrInvalidCurriculumDocument indica un documento incompleto, demasiado largo o con materia/OA inválidos
+var ErrInvalidCurriculumDocument = errors.New("invalid curriculum document")
+
+// ErrCurriculumRAGNotInitialized indica que no hay proveedor de embeddings o índice configurado
+var ErrCurriculumRAGNotInitialized = errors.New("curriculum search not initialized")
+
+var (
+	curriculumMu      sync.RWMutex
+	embeddingProvider embeddings.Provider
+	curriculumIndex   vectorstore.Index
+)
+
+// CurriculumDocumentInput es un documento subido por un docente o admin
+type CurriculumDocumentInput struct {
+	MateriaID uint   `json:"materia_id"`
+	OAID      *uint  `json:"oa_id,omitempty"` // vacío = aplica a todos los OA de la materia
+	Titulo    string `json:"titulo"`
+	Formato   string `json:"formato"` // markdown | texto | pdf (texto extraído del PDF)
+	Fuente    string `json:"fuente,omitempty"`
+	Contenido string `json:"contenido"`
+}
+
+// CurriculumReindexResult resume una reindexación
+type CurriculumReindexResult struct {
+	Documentos     int    `json:"documentos"`
+	Chunks         int    `json:"chunks"`
+	EmbeddingModel string `json:"embedding_model"`
+	Indice         string `json:"indice"`
+}
+
+// InitCurriculumRAG configura el proveedor de embeddings (EMBEDDINGS_PROVIDER) y el índice de vectores
+// (CURRICULUM_INDEX: auto, pgvector o memory). auto usa pgvector si la extensión está instalada;
+// el índice en memoria se carga con los chunks guardados.
+func InitCurriculumRAG() error {
+	provider, err := embeddings.NewFromEnv()
+	if err != nil {
+		return err
+	}
+	if openaiProvider, ok := provider.(*embeddings.OpenAIProvider); ok {
+		openaiProvider.WithRecorder(llm.NewGormRecorder(db.DB))
+	}
+
+	var index vectorstore.Index
+	switch mode := getEnvString("CURRICULUM_INDEX", "auto"); mode {
+	case "pgvector":
+		if !vectorstore.PgvectorAvailable(db.DB) {
+			return errors.New("CURRICULUM_INDEX=pgvector but the vector extension is not installed")
+		}
+		index = vectorstore.NewPgvectorIndex(db.DB, models.CurriculumChunk{}.TableName())
+	case "memory":
+		index = vectorstore.NewMemoryIndex()
+	case "auto":
+		if vectorstore.PgvectorAvailable(db.DB) {
+			index = vectorstore.NewPgvectorIndex(db.DB, models.CurriculumChunk{}.TableName())
+		} else {
+			index = vectorstore.NewMemoryIndex()
+		}
+	default:
+		return fmt.Errorf("unknown CURRICULUM_INDEX %q", mode)
+	}
+
+	if memory, ok := index.(*vectorstore.MemoryIndex); ok {
+		if err := loadCurriculumChunks(context.Background(), memory, provider.Name()); err != nil {
+			return err
+		}
+	}
+
+	SetCurriculumRAG(provider, index)
+	log.Printf("✓ Curriculum search initialized (embeddings %s, index %s)", provider.Name(), index.Name())
+	return nil
+}
+
+// SetCurriculumRAG reemplaza el proveedor de embeddings y el índice (útil para tests)
+func SetCurriculumRAG(provider embeddings.Provider, index vectorstore.Index) {
+	curriculumMu.Lock()
+	defer curriculumMu.Unlock()
+	embeddingProvider = provider
+	curriculumIndex = index
+}
+
+func currentCurriculumRAG() (embeddings.Provider, vectorstore.Index, error) {
+	curriculumMu.RLock()
+	defer curriculumMu.RUnlock()
+	if embeddingProvider == nil || curriculumIndex == nil {
+		return nil, nil, ErrCurriculumRAGNotInitialized
+	}
+	return embeddingProvider, curriculumIndex, nil
+}
+
+// loadCurriculumChunks carga en el índice los chunks embebidos con el modelo actual
+func loadCurriculumChunks(ctx context.Context, index vectorstore.Index, model string) error {
+	var chunks []models.CurriculumChunk
+	if err := db.DB.Where("embedding_model = ?", model).Find(&chunks).Error; err != nil {
+		return fmt.Errorf("failed to load curriculum chunks: %w", err)
+	}
+	return index.Add(ctx, toVectorChunks(chunks))
+}
+
+func toVectorChunks(chunks []models.CurriculumChunk) []vectorstore.Chunk {
+	result := make([]vectorstore.Chunk, len(chunks))
+	for i, chunk := range chunks {
+		result[i] = vectorstore.Chunk{
+			ID:         chunk.ID,
+			DocumentID: chunk.DocumentID,
+			MateriaID:  chunk.MateriaID,
+			OAID:       chunk.OAID,
+			Model:      chunk.EmbeddingModel,
+			Vector:     chunk.Embedding,
+		}
+	}
+	return result
+}
+
+// CreateCurriculumDocument valida el documento, lo divide en fragmentos, los embebe y los indexa.
+// El caché de contenido de los objetivos afectados se invalida para que las próximas generaciones
+// usen las referencias.
+func CreateCurriculumDocument(ctx context.Context, userID uint, input CurriculumDocumentInput) (*models.CurriculumDocument, error) {
+	provider, index, err := currentCurriculumRAG()
+	if err != nil {
+		return nil, err
+	}
+	if err := validateCurriculumDocument(&input); err != nil {
+		return nil, err
+	}
+
+	texts := chunkCurriculumText(input.Formato, input.Contenido)
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("%w: contenido sin texto", ErrInvalidCurriculumDocument)
+	}
+	vectors, err := provider.Embed(llm.WithFeature(llm.WithUser(ctx, userID), llm.FeatureCurriculumEmbedding), texts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed curriculum document: %w", err)
+	}
+
+	document := models.CurriculumDocument{
+		MateriaID:      input.MateriaID,
+		OAID:           input.OAID,
+		Titulo:         input.Titulo,
+		Formato:        input.Formato,
+		Fuente:         input.Fuente,
+		Contenido:      input.Contenido,
+		SubidoPor:      &userID,
+		Chunks:         len(texts),
+		EmbeddingModel: provider.Name(),
+	}
+	var chunks []models.CurriculumChunk
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&document).Error; err != nil {
+			return err
+		}
+		chunks = newCurriculumChunks(&document, texts, vectors)
+		return tx.Create(&chunks).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := index.Add(ctx, toVectorChunks(chunks)); err != nil {
+		log.Printf("⚠ Failed to index curriculum document %d: %v", document.ID, err)
+	}
+	invalidateCurriculumContentCache(document.MateriaID, document.OAID)
+
+	log.Printf("✓ Curriculum document %d indexed (%d chunks, %s)", document.ID, len(chunks), provider.Name())
+	return &document, nil
+}
+
+func newCurriculumChunks(document *models.CurriculumDocument, texts []string, vectors [][]float32) []models.CurriculumChunk {
+	chunks := make([]models.CurriculumChunk, len(texts))
+	for i, text := range texts {
+		chunks[i] = models.CurriculumChunk{
+			DocumentID:     document.ID,
+			MateriaID:      document.MateriaID,
+			OAID:           document.OAID,
+			Orden:          i + 1,
+			Texto:          text,
+			Embedding:      vectors[i],
+			EmbeddingModel: document.EmbeddingModel,
+		}
+	}
+	return chunks
+}
+
+func validateCurriculumDocument(input *CurriculumDocumentInput) error {
+	input.Titulo = strings.TrimSpace(input.Titulo)
+	input.Fuente = strings.TrimSpace(input.Fuente)
+	if input.Formato == "" {
+		input.Formato = models.CurriculumFormatoTexto
+	}
+
+	if input.MateriaID == 0 || input.Titulo == "" || strings.TrimSpace(input.Contenido) == "" {
+		return fmt.Errorf("%w: materia_id, titulo y contenido son obligatorios", ErrInvalidCurriculumDocument)
+	}
+	if utf8.RuneCountInString(input.Titulo) > 300 || utf8.RuneCountInString(input.Fuente) > 500 {
+		return fmt.Errorf("%w: titulo o fuente demasiado largos", ErrInvalidCurriculumDocument)
+	}
+	switch input.Formato {
+	case models.CurriculumFormatoMarkdown, models.CurriculumFormatoTexto, models.CurriculumFormatoPDF:
+	default:
+		return fmt.Errorf("%w: formato debe ser markdown, texto o pdf", ErrInvalidCurriculumDocument)
+	}
+	if maxChars := getEnvInt("CURRICULUM_MAX_DOCUMENT_CHARS", 500000); utf8.RuneCountInString(input.Contenido) > maxChars {
+		return fmt.Errorf("%w: el contenido supera %d caracteres", ErrInvalidCurriculumDocument, maxChars)
+	}
+
+	var materia models.Materia
+	if err := db.DB.First(&materia, input.MateriaID).Error; err != nil {
+		return fmt.Errorf("%w: materia %d no existe", ErrInvalidCurriculumDocument, input.MateriaID)
+	}
+	if input.OAID != nil {
+		var oa models.ObjetivoAprendizaje
+		if err := db.DB.First(&oa, *input.OAID).Error; err != nil || oa.MateriaID != input.MateriaID {
+			return fmt.Errorf("%w: el OA %d no pertenece a la materia %d", ErrInvalidCurriculumDocument, *input.OAID, input.MateriaID)
+		}
+	}
+	return nil
+}
+
+// ListCurriculumDocuments lista los documentos (sin el contenido), opcionalmente de una materia u OA
+func ListCurriculumDocuments(materiaID uint, oaID *uint) ([]models.CurriculumDocument, error) {
+	query := db.DB.Omit("contenido").Order("materia_id, oa_id NULLS FIRST, id")
+	if materiaID > 0 {
+		query = query.Where("materia_id = ?", materiaID)
+	}
+	if oaID != nil {
+		query = query.Where("oa_id = ?", *oaID)
+	}
+
+	documents := []models.CurriculumDocument{}
+	if err := query.Find(&documents).Error; err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// GetCurriculumDocument retorna un documento con su contenido
+func GetCurriculumDocument(id uint) (*models.CurriculumDocument, error) {
+	var document models.CurriculumDocument
+	if err := db.DB.First(&document, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCurriculumDocumentNotFound
+		}
+		return nil, err
+	}
+	return &document, nil
+}
+
+// DeleteCurriculumDocument borra el documento y sus fragmentos. Las citas ya guardadas en planes
+// y preguntas se conservan (son una copia del fragmento).
+func DeleteCurriculumDocument(ctx context.Context, id uint) error {
+	document, err := GetCurriculumDocument(id)
+	if err != nil {
+		return err
+	}
+	if err := db.DB.Delete(&models.CurriculumDocument{}, id).Error; err != nil {
+		return err
+	}
+
+	if _, index, err := currentCurriculumRAG(); err == nil {
+		if err := index.RemoveDocument(ctx, id); err != nil {
+			log.Printf("⚠ Failed to remove curriculum document %d from index: %v", id, err)
+		}
+	}
+	invalidateCurriculumContentCache(document.MateriaID, document.OAID)
+	return nil
+}
+
+// ReindexCurriculumDocuments vuelve a fragmentar y embeber los documentos con el proveedor actual.
+// Sin all solo se procesan los embebidos con otro modelo (p. ej. después de cambiar EMBEDDINGS_PROVIDER).
+func ReindexCurriculumDocuments(ctx context.Context, userID uint, all bool) (*CurriculumReindexResult, error) {
+	provider, index, err := currentCurriculumRAG()
+	if err != nil {
+		return nil, err
+	}
+
+	query := db.DB.Order("id")
+	if !all {
+		query = query.Where("embedding_model IS NULL OR embedding_model <> ?", provider.Name())
+	}
+	var documents []models.CurriculumDocument
+	if err := query.Find(&documents).Error; err != nil {
+		return nil, err
+	}
+
+	result := &CurriculumReindexResult{EmbeddingModel: provider.Name(), Indice: index.Name()}
+	embedCtx := llm.WithFeature(llm.WithUser(ctx, userID), llm.FeatureCurriculumEmbedding)
+	for i := range documents {
+		document := &documents[i]
+		texts := chunkCurriculumText(document.Formato, document.Contenido)
+		vectors, err := provider.Embed(embedCtx, texts)
+		if err != nil {
+			return result, fmt.Errorf("failed to embed curriculum document %d: %w", document.ID, err)
+		}
+
+		document.Chunks = len(texts)
+		document.EmbeddingModel = provider.Name()
+		var chunks []models.CurriculumChunk
+		err = db.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("document_id = ?", document.ID).Delete(&models.CurriculumChunk{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(document).Updates(map[string]interface{}{
+				"chunks":          document.Chunks,
+				"embedding_model": document.EmbeddingModel,
+			}).Error; err != nil {
+				return err
+			}
+			chunks = newCurriculumChunks(document, texts, vectors)
+			if len(chunks) == 0 {
+				return nil
+			}
+			return tx.Create(&chunks).Error
+		})
+		if err != nil {
+			return result, err
+		}
+
+		index.RemoveDocument(ctx, document.ID)
+		if err := index.Add(ctx, toVectorChunks(chunks)); err != nil {
+			log.Printf("⚠ Failed to index curriculum document %d: %v", document.ID, err)
+		}
+		invalidateCurriculumContentCache(document.MateriaID, document.OAID)
+
+		result.Documentos++
+		result.Chunks += len(chunks)
+	}
+
+	log.Printf("✓ Reindexed %d curriculum documents (%d chunks, %s)", result.Documentos, result.Chunks, provider.Name())
+	return result, nil
+}
+
+// invalidateCurriculumContentCache borra el contenido compartido de los objetivos de la materia (o del OA),
+// generado sin las referencias actuales
+func invalidateCurriculumContentCache(materiaID uint, oaID *uint) {
+	objectives := db.DB.Model(&models.OABloomObjective{}).Select("oa_bloom_objectives.id").
+		Joins("JOIN objetivos_aprendizaje oa ON oa.id = oa_bloom_objectives.oa_id").
+		Where("oa.materia_id = ?", materiaID)
+	if oaID != nil {
+		objectives = objectives.Where("oa.id = ?", *oaID)
+	}
+
+	result := db.DB.Where("oa_bloom_objective_id IN (?)", objectives).Delete(&models.ComponentContentCache{})
+	if result.Error != nil {
+		log.Printf("⚠ Failed to invalidate content cache for materia %d: %v", materiaID, result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("✓ Invalidated %d content cache entries after curriculum change (materia %d)", result.RowsAffected, materiaID)
+	}
+}
+
+// Normalización y fragmentación de documentos
+
+var (
+	markdownHeading    = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	markdownImage      = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	markdownLink       = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	markdownListMarker = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+`)
+	markdownQuote      = regexp.MustCompile(`^\s*>\s?`)
+	markdownHTMLTag    = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	markdownEmphasis   = strings.NewReplacer("**", "", "__", "", "~~", "", "`", "")
+	pdfPageNumber      = regexp.MustCompile(`^(?i:p[áa]g(?:ina)?\.?\s*)?\d{1,4}(?:\s*(?:/|de)\s*\d{1,4})?$`)
+	pdfHyphenation     = regexp.MustCompile(`(\p{L})-\n(\p{Ll})`)
+	sentenceEnd        = regexp.MustCompile(`[.!?…]["»”)]*\s+`)
+)
+
+// curriculumParagraph es un párrafo del documento con el título de su sección
+type curriculumParagraph struct {
+	Heading string
+	Text    string
+}
+
+// chunkCurriculumText divide un documento en fragmentos de hasta CURRICULUM_CHUNK_CHARS caracteres
+// (por defecto 1000) que no cruzan secciones; cada fragmento lleva el título de su sección
+func chunkCurriculumText(formato, contenido string) []string {
+	maxChars := getEnvInt("CURRICULUM_CHUNK_CHARS", 1000)
+	if maxChars < 200 {
+		maxChars = 200
+	}
+
+	var chunks []string
+	var current strings.Builder
+	heading := ""
+	flush := func() {
+		if current.Len() == 0 {
+			return
+		}
+		text := current.String()
+		if heading != "" {
+			text = heading + "\n" + text
+		}
+		chunks = append(chunks, text)
+		current.Reset()
+	}
+
+	for _, paragraph := range curriculumParagraphs(formato, contenido) {
+		if paragraph.Heading != heading {
+			flush()
+			heading = paragraph.Heading
+		}
+		for _, piece := range splitLongParagraph(paragraph.Text, maxChars) {
+			if current.Len() > 0 && current.Len()+2+len(piece) > maxChars {
+				flush()
+			}
+			if current.Len() > 0 {
+				current.WriteString("\n\n")
+			}
+			current.WriteString(piece)
+		}
+	}
+	flush()
+	return chunks
+}
+
+// curriculumParagraphs normaliza el texto según el formato y lo separa en párrafos:
+// markdown pierde el marcado (los títulos pasan a ser el encabezado de su sección) y el
+// texto extraído de un PDF recupera los párrafos partidos por líneas, guiones y números de página
+func curriculumParagraphs(formato, contenido string) []curriculumParagraph {
+	text := strings.ReplaceAll(contenido, "\r\n", "\n")
+	if formato == models.CurriculumFormatoPDF {
+		text = strings.ReplaceAll(text, "\f", "\n\n")
+		text = pdfHyphenation.ReplaceAllString(text, "$1$2")
+	}
+
+	var paragraphs []curriculumParagraph
+	var lines []string
+	heading := ""
+	flush := func() {
+		separator := "\n"
+		if formato == models.CurriculumFormatoPDF {
+			separator = " " // los saltos de línea de un PDF son del diseño de la página, no del texto
+		}
+		if text := strings.TrimSpace(strings.Join(lines, separator)); text != "" {
+			paragraphs = append(paragraphs, curriculumParagraph{Heading: heading, Text: text})
+		}
+		lines = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		switch formato {
+		case models.CurriculumFormatoMarkdown:
+			if strings.HasPrefix(line, "```") {
+				continue
+			}
+			if match := markdownHeading.FindStringSubmatch(line); match != nil {
+				flush()
+				heading = cleanMarkdownInline(match[2])
+				continue
+			}
+			if markdownListMarker.MatchString(line) {
+				line = "- " + markdownListMarker.ReplaceAllString(line, "")
+			}
+			line = cleanMarkdownInline(markdownQuote.ReplaceAllString(line, ""))
+		case models.CurriculumFormatoPDF:
+			if pdfPageNumber.MatchString(line) {
+				continue
+			}
+		}
+
+		if line == "" {
+			flush()
+			continue
+		}
+		lines = append(lines, line)
+	}
+	flush()
+	return paragraphs
+}
+
+func cleanMarkdownInline(text string) string {
+	text = markdownImage.ReplaceAllString(text, "")
+	text = markdownLink.ReplaceAllString(text, "$1")
+	text = markdownHTMLTag.ReplaceAllString(text, "")
+	return strings.TrimSpace(markdownEmphasis.Replace(text))
+}
+
+// splitLongParagraph parte un párrafo más largo que maxChars por oraciones y, si una oración
+// sigue siendo demasiado larga, por palabras
+func splitLongParagraph(text string, maxChars int) []string {
+	if len(text) <= maxChars {
+		return []string{text}
+	}
+
+	var sentences []string
+	start := 0
+	for _, loc := range sentenceEnd.FindAllStringIndex(text, -1) {
+		sentences = append(sentences, strings.TrimSpace(text[start:loc[1]]))
+		start = loc[1]
+	}
+	if rest := strings.TrimSpace(text[start:]); rest != "" {
+		sentences = append(sentences, rest)
+	}
+
+	var pieces []string
+	var current strings.Builder
+	add := func(part string) {
+		if current.Len() > 0 && current.Len()+1+len(part) > maxChars {
+			pieces = append(pieces, current.String())
+			current.Reset()
+		}
+		if current.Len() > 0 {
+			current.WriteByte(' ')
+		}
+		current.WriteString(part)
+	}
+	for _, sentence := range sentences {
+		if len(sentence) <= maxChars {
+			add(sentence)
+			continue
+		}
+		for _, word := range strings.Fields(sentence) {
+			add(word)
+		}
+	}
+	if current.Len() > 0 {
+		pieces = append(pieces, current.String())
+	}
+	return pieces
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"github.com/platanus-hack-25/lumera_app/pkg/llm"
+	"github.com/platanus-hack-25/lumera_app/pkg/vectorstore"
+)
+
+// maxCitationFragment es el largo (en caracteres) del fragmento guardado en cada cita
+const maxCitationFragment = 300
+
+// CurriculumPassage es un pasaje curricular incluido en un prompt (ver la plantilla "referencias")
+type CurriculumPassage struct {
+	Indice int
+	Titulo string
+	Texto  string
+}
+
+// CurriculumSearchResult es un fragmento encontrado por la búsqueda de vista previa
+type CurriculumSearchResult struct {
+	models.CurriculumCitation
+	Texto string `json:"texto"`
+}
+
+// curriculumRAGEnabled permite desactivar las referencias en las generaciones sin borrar los documentos
+// (CURRICULUM_RAG_ENABLED, por defecto true)
+func curriculumRAGEnabled() bool {
+	return getEnvString("CURRICULUM_RAG_ENABLED", "true") != "false"
+}
+
+// curriculumMinScore es la similitud mínima de un pasaje para incluirlo (CURRICULUM_RAG_MIN_SCORE, por defecto 0.2)
+func curriculumMinScore() float64 {
+	if score, err := strconv.ParseFloat(getEnvString("CURRICULUM_RAG_MIN_SCORE", ""), 64); err == nil {
+		return score
+	}
+	return 0.2
+}
+
+// withCurriculumReferences agrega al contexto los pasajes de los documentos de la materia (del OA o de
+// toda la materia) más cercanos al objetivo, y retorna las citas para guardarlas con el contenido generado.
+// Sin documentos, o si la búsqueda falla, el contexto queda igual: las referencias son opcionales.
+func withCurriculumReferences(ctx context.Context, oaContext OAContext, objetivo string) (OAContext, []models.CurriculumCitation) {
+	oaContext.Referencias = nil
+	if !curriculumRAGEnabled() || oaContext.MateriaID == 0 {
+		return oaContext, nil
+	}
+
+	oaID := oaContext.OAID
+	query := strings.Join([]string{oaContext.OATitulo, oaContext.OADescripcion, objetivo}, ". ")
+	results, err := searchCurriculumChunks(ctx, oaContext.MateriaID, &oaID, query, getEnvInt("CURRICULUM_RAG_TOP_K", 4))
+	if err != nil {
+		if err != ErrCurriculumRAGNotInitialized {
+			log.Printf("⚠ Curriculum search failed (materia %d, OA %d): %v", oaContext.MateriaID, oaID, err)
+		}
+		return oaContext, nil
+	}
+
+	minScore := curriculumMinScore()
+	citations := []models.CurriculumCitation{}
+	for _, result := range results {
+		if result.Score < minScore {
+			continue
+		}
+		result.Indice = len(citations) + 1
+		citations = append(citations, result.CurriculumCitation)
+		oaContext.Referencias = append(oaContext.Referencias, CurriculumPassage{
+			Indice: result.Indice,
+			Titulo: result.Titulo,
+			Texto:  result.Texto,
+		})
+	}
+	if len(citations) == 0 {
+		return oaContext, nil
+	}
+	return oaContext, citations
+}
+
+// attachCurriculumCitations guarda las citas en el contenido de un componente (contenido_props.fuentes)
+func attachCurriculumCitations(content map[string]interface{}, citations []models.CurriculumCitation) {
+	if len(citations) > 0 {
+		content["fuentes"] = citations
+	}
+}
+
+// SearchCurriculum busca los fragmentos más cercanos a un texto; permite a los docentes revisar
+// qué pasajes recibirían los prompts de un OA
+func SearchCurriculum(ctx context.Context, userID, materiaID uint, oaID *uint, query string, k int) ([]CurriculumSearchResult, error) {
+	if materiaID == 0 || strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("%w: materia_id y q son obligatorios", ErrInvalidCurriculumDocument)
+	}
+	if k <= 0 || k > 20 {
+		k = getEnvInt("CURRICULUM_RAG_TOP_K", 4)
+	}
+	return searchCurriculumChunks(llm.WithUser(ctx, userID), materiaID, oaID, query, k)
+}
+
+// searchCurriculumChunks embebe la consulta y retorna los k fragmentos más similares con su documento
+func searchCurriculumChunks(ctx context.Context, materiaID uint, oaID *uint, query string, k int) ([]CurriculumSearchResult, error) {
+	provider, index, err := currentCurriculumRAG()
+	if err != nil {
+		return nil, err
+	}
+
+	// Sin fragmentos de la materia no se gasta una llamada de embeddings
+	var available int64
+	count := db.DB.Model(&models.CurriculumChunk{}).Where("materia_id = ? AND embedding_model = ?", materiaID, provider.Name())
+	if oaID != nil {
+		count = count.Where("(oa_id = ? OR oa_id IS NULL)", *oaID)
+	}
+	if err := count.Count(&available).Error; err != nil {
+		return nil, err
+	}
+	if available == 0 {
+		return []CurriculumSearchResult{}, nil
+	}
+
+	vectors, err := provider.Embed(llm.WithFeature(ctx, llm.FeatureCurriculumEmbedding), []string{query})
+	if err != nil {
+		return nil, err
+	}
+	matches, err := index.Search(ctx, vectors[0], vectorstore.Filter{
+		MateriaID: materiaID,
+		OAID:      oaID,
+		Model:     provider.Name(),
+	}, k)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return []CurriculumSearchResult{}, nil
+	}
+
+	chunkIDs := make([]uint, len(matches))
+	for i, match := range matches {
+		chunkIDs[i] = match.ChunkID
+	}
+	var rows []struct {
+		ID         uint
+		DocumentID uint
+		Texto      string
+		Titulo     string
+		Fuente     string
+	}
+	err = db.DB.Table("curriculum_chunks c").
+		Select("c.id, c.document_id, c.texto, d.titulo, d.fuente").
+		Joins("JOIN curriculum_documents d ON d.id = c.document_id").
+		Where("c.id IN ?", chunkIDs).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]int, len(rows))
+	for i, row := range rows {
+		byID[row.ID] = i
+	}
+
+	results := []CurriculumSearchResult{}
+	for _, match := range matches {
+		i, ok := byID[match.ChunkID]
+		if !ok {
+			continue // borrado después de indexarse
+		}
+		row := rows[i]
+		results = append(results, CurriculumSearchResult{
+			CurriculumCitation: models.CurriculumCitation{
+				Indice:      len(results) + 1,
+				DocumentoID: row.DocumentID,
+				Titulo:      row.Titulo,
+				Fuente:      row.Fuente,
+				ChunkID:     row.ID,
+				Fragmento:   citationFragment(row.Texto),
+				Score:       match.Score,
+			},
+			Texto: row.Texto,
+		})
+	}
+	return results, nil
+}
+
+func citationFragment(text string) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	if len(runes) <= maxCitationFragment {
+		return string(runes)
+	}
+	return string(runes[:maxCitationFragment]) + "…"
+}
+
+// marshalCitations serializa las citas para una columna fuentes (nil sin citas)
+func marshalCitations(citations []models.CurriculumCitation) []byte {
+	if len(citations) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(citations)
+	if err != nil {
+		return nil
+	}
+	return data
+}
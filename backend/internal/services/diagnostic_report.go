@@ -0,0 +1,500 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"sort"
+	"time"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+)
+
+var (
+	ErrDiagnosticReportNotFound = errors.New("diagnostic session not found")
+	ErrDiagnosticReportPending  = errors.New("diagnostic session not completed")
+	ErrClassroomNotFound        = errors.New("curso not found")
+)
+
+// Formatos de un informe de diagnóstico (GET /api/diagnostic-sessions/{id}/report?format=)
+const (
+	ReportFormatPDF  = "pdf"
+	ReportFormatHTML = "html"
+	ReportFormatJSON = "json" // el informe como JSON, para que el cliente lo dibuje
+)
+
+// Umbrales de porcentaje de aciertos por OA, los mismos de la recomendación de cada resultado
+const (
+	diagnosticReportStrength = 80
+	diagnosticReportWeakness = 60
+	diagnosticReportMaxSteps = 3
+)
+
+var diagnosticReportTemplate = htmltemplate.Must(htmltemplate.New("diagnostic_report.html.tmpl").
+	Funcs(htmltemplate.FuncMap{"fecha": reportDate, "pct": reportPercent, "cambio": reportLevelChange}).
+	ParseFS(exportTemplateFiles, "export_templates/diagnostic_report.html.tmpl"))
+
+// DiagnosticReport es el informe de una sesión de diagnóstico completada
+type DiagnosticReport struct {
+	SessionID          uint                      `json:"session_id"`
+	NumeroIntento      int                       `json:"numero_intento"`
+	UserID             uint                      `json:"user_id"`
+	Estudiante         string                    `json:"estudiante"`
+	MateriaID          uint                      `json:"materia_id"`
+	Materia            string                    `json:"materia"`
+	Fecha              time.Time                 `json:"fecha"`
+	PreguntasTotales   int                       `json:"preguntas_totales"`
+	PreguntasCorrectas int                       `json:"preguntas_correctas"`
+	Porcentaje         int                       `json:"porcentaje"`
+	NivelPromedio      float64                   `json:"nivel_promedio"`
+	Compromiso         *float64                  `json:"compromiso"`
+	AdivinanzasRapidas int                       `json:"adivinanzas_rapidas"`
+	Niveles            []DiagnosticReportLevel   `json:"niveles"`
+	OAs                []DiagnosticReportOA      `json:"oas"`
+	Fortalezas         []DiagnosticReportOA      `json:"fortalezas"`
+	Debilidades        []DiagnosticReportOA      `json:"debilidades"`
+	ConceptosErroneos  []SessionMisconception    `json:"conceptos_erroneos"` // según los distractores elegidos
+	Intentos           []DiagnosticReportAttempt `json:"intentos"`
+	ProximosPasos      []DiagnosticReportStep    `json:"proximos_pasos"`
+	GeneradoEl         time.Time                 `json:"generado_el"`
+}
+
+// DiagnosticReportLevel es un nivel de Bloom con su color para las barras
+type DiagnosticReportLevel struct {
+	Nivel  int    `json:"nivel"`
+	Nombre string `json:"nombre"`
+	Color  string `json:"color"`
+}
+
+// DiagnosticReportOA es el resultado de un OA, comparado con el intento anterior
+type DiagnosticReportOA struct {
+	OAID               uint   `json:"oa_id"`
+	Codigo             string `json:"codigo"`
+	Titulo             string `json:"titulo"`
+	Categoria          string `json:"categoria"`
+	NivelBloom         int    `json:"nivel_bloom"`
+	NivelBloomNombre   string `json:"nivel_bloom_nombre"`
+	Respondidas        int    `json:"respondidas"`
+	Correctas          int    `json:"correctas"`
+	Porcentaje         int    `json:"porcentaje"`
+	NivelAnterior      *int   `json:"nivel_anterior"`      // nil si el intento anterior no evaluó el OA
+	PorcentajeAnterior *int   `json:"porcentaje_anterior"` // nil si el intento anterior no evaluó el OA
+}
+
+// DiagnosticReportAttempt resume un intento de diagnóstico de la misma materia
+type DiagnosticReportAttempt struct {
+	SessionID     uint      `json:"session_id"`
+	NumeroIntento int       `json:"numero_intento"`
+	Fecha         time.Time `json:"fecha"`
+	Porcentaje    int       `json:"porcentaje"`
+	NivelPromedio float64   `json:"nivel_promedio"`
+	OAsEvaluados  int       `json:"oas_evaluados"`
+	Actual        bool      `json:"actual"`
+}
+
+// DiagnosticReportStep es una recomendación de práctica sobre un objetivo OA-Bloom
+type DiagnosticReportStep struct {
+	OAID               uint   `json:"oa_id"`
+	Codigo             string `json:"codigo"`
+	NivelBloom         int    `json:"nivel_bloom"`
+	NivelBloomNombre   string `json:"nivel_bloom_nombre"`
+	OABloomObjectiveID *uint  `json:"oa_bloom_objective_id"`
+	Texto              string `json:"texto"`
+}
+
+// DiagnosticReportFile es un informe renderizado (o un zip de informes)
+type DiagnosticReportFile struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// BuildDiagnosticReport arma el informe de una sesión completada. Docentes y admins (anyOwner) ven
+// cualquier sesión; los estudiantes solo las suyas.
+func BuildDiagnosticReport(userID uint, anyOwner bool, sessionID uint) (*DiagnosticReport, error) {
+	var session models.DiagnosticSession
+	query := db.DB.Preload("User").Preload("Materia")
+	if !anyOwner {
+		query = query.Where("user_id = ?", userID)
+	}
+	result := query.Where("id = ?", sessionID).Limit(1).Find(&session)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrDiagnosticReportNotFound
+	}
+	if session.Estado != "completado" {
+		return nil, ErrDiagnosticReportPending
+	}
+	return buildDiagnosticReport(&session)
+}
+
+func buildDiagnosticReport(session *models.DiagnosticSession) (*DiagnosticReport, error) {
+	var levels []models.BloomLevel
+	if err := db.DB.Order("nivel").Find(&levels).Error; err != nil {
+		return nil, err
+	}
+	report := &DiagnosticReport{
+		SessionID:          session.ID,
+		NumeroIntento:      session.NumeroIntento,
+		UserID:             session.UserID,
+		Estudiante:         session.User.Name,
+		MateriaID:          session.MateriaID,
+		Materia:            session.Materia.Nombre,
+		Fecha:              session.StartedAt,
+		PreguntasTotales:   session.PreguntasTotales,
+		PreguntasCorrectas: session.PreguntasCorrectas,
+		Compromiso:         session.Compromiso,
+		AdivinanzasRapidas: session.AdivinanzasRapidas,
+		GeneradoEl:         time.Now(),
+	}
+	if session.CompletedAt != nil {
+		report.Fecha = *session.CompletedAt
+	}
+	if session.PreguntasTotales > 0 {
+		report.Porcentaje = session.PreguntasCorrectas * 100 / session.PreguntasTotales
+	}
+	levelNames := make(map[int]string, len(levels))
+	for _, level := range levels {
+		report.Niveles = append(report.Niveles, DiagnosticReportLevel{Nivel: level.Nivel, Nombre: level.Nombre, Color: level.Color})
+		levelNames[level.Nivel] = level.Nombre
+	}
+
+	// Intentos completados de la misma materia, del primero al más reciente
+	var attempts []models.DiagnosticSession
+	err := db.DB.Where("user_id = ? AND materia_id = ? AND estado = ?", session.UserID, session.MateriaID, "completado").
+		Order("numero_intento, completed_at, id").
+		Find(&attempts).Error
+	if err != nil {
+		return nil, err
+	}
+	attemptIDs := make([]uint, len(attempts))
+	for i, attempt := range attempts {
+		attemptIDs[i] = attempt.ID
+	}
+	var results []models.DiagnosticResult
+	if err := db.DB.Preload("OA").Where("session_id IN ?", attemptIDs).Order("oa_id").Find(&results).Error; err != nil {
+		return nil, err
+	}
+	resultsBySession := make(map[uint][]models.DiagnosticResult)
+	for _, result := range results {
+		resultsBySession[result.SessionID] = append(resultsBySession[result.SessionID], result)
+	}
+
+	var previous map[uint]models.DiagnosticResult
+	for i, attempt := range attempts {
+		levelsSum := 0
+		for _, result := range resultsBySession[attempt.ID] {
+			levelsSum += result.NivelBloomDominado
+		}
+		summary := DiagnosticReportAttempt{
+			SessionID:     attempt.ID,
+			NumeroIntento: attempt.NumeroIntento,
+			Fecha:         attempt.StartedAt,
+			OAsEvaluados:  len(resultsBySession[attempt.ID]),
+			Actual:        attempt.ID == session.ID,
+		}
+		if attempt.CompletedAt != nil {
+			summary.Fecha = *attempt.CompletedAt
+		}
+		if attempt.PreguntasTotales > 0 {
+			summary.Porcentaje = attempt.PreguntasCorrectas * 100 / attempt.PreguntasTotales
+		}
+		if summary.OAsEvaluados > 0 {
+			summary.NivelPromedio = roundPlacement(float64(levelsSum) / float64(summary.OAsEvaluados))
+		}
+		report.Intentos = append(report.Intentos, summary)
+
+		if summary.Actual {
+			report.NivelPromedio = summary.NivelPromedio
+			if i > 0 {
+				previous = make(map[uint]models.DiagnosticResult)
+				for _, result := range resultsBySession[attempts[i-1].ID] {
+					previous[result.OAID] = result
+				}
+			}
+			// Los intentos posteriores no son parte de la comparación
+			break
+		}
+	}
+
+	for _, result := range resultsBySession[session.ID] {
+		oa := DiagnosticReportOA{
+			OAID:             result.OAID,
+			Codigo:           result.OA.Codigo,
+			Titulo:           result.OA.Titulo,
+			Categoria:        result.OA.Categoria,
+			NivelBloom:       result.NivelBloomDominado,
+			NivelBloomNombre: result.NivelBloomNombre,
+			Respondidas:      result.PreguntasRespondidas,
+			Correctas:        result.PreguntasCorrectas,
+			Porcentaje:       result.PorcentajeAciertos,
+		}
+		if name, ok := levelNames[oa.NivelBloom]; ok {
+			oa.NivelBloomNombre = name
+		}
+		if before, ok := previous[result.OAID]; ok {
+			nivel, porcentaje := before.NivelBloomDominado, before.PorcentajeAciertos
+			oa.NivelAnterior, oa.PorcentajeAnterior = &nivel, &porcentaje
+		}
+		report.OAs = append(report.OAs, oa)
+		switch {
+		case oa.Porcentaje >= diagnosticReportStrength:
+			report.Fortalezas = append(report.Fortalezas, oa)
+		case oa.Porcentaje < diagnosticReportWeakness:
+			report.Debilidades = append(report.Debilidades, oa)
+		}
+	}
+	sort.SliceStable(report.Fortalezas, func(i, j int) bool {
+		if report.Fortalezas[i].NivelBloom != report.Fortalezas[j].NivelBloom {
+			return report.Fortalezas[i].NivelBloom > report.Fortalezas[j].NivelBloom
+		}
+		return report.Fortalezas[i].Porcentaje > report.Fortalezas[j].Porcentaje
+	})
+	sort.SliceStable(report.Debilidades, func(i, j int) bool {
+		return report.Debilidades[i].Porcentaje < report.Debilidades[j].Porcentaje
+	})
+
+	report.ConceptosErroneos, err = SessionMisconceptions(models.MisconceptionOrigenDiagnostico, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	steps, err := diagnosticReportSteps(report.OAs, levelNames)
+	if err != nil {
+		return nil, err
+	}
+	report.ProximosPasos = steps
+	return report, nil
+}
+
+// diagnosticReportSteps recomienda practicar primero los OAs con menos aciertos: los débiles se
+// refuerzan en su nivel dominado y el resto avanza al nivel siguiente. Los OAs dominados en el
+// último nivel no necesitan un paso.
+func diagnosticReportSteps(oas []DiagnosticReportOA, levelNames map[int]string) ([]DiagnosticReportStep, error) {
+	maxLevel := 0
+	for nivel := range levelNames {
+		if nivel > maxLevel {
+			maxLevel = nivel
+		}
+	}
+
+	candidates := append([]DiagnosticReportOA(nil), oas...)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Porcentaje != candidates[j].Porcentaje {
+			return candidates[i].Porcentaje < candidates[j].Porcentaje
+		}
+		return candidates[i].NivelBloom < candidates[j].NivelBloom
+	})
+
+	var steps []DiagnosticReportStep
+	for _, oa := range candidates {
+		if len(steps) == diagnosticReportMaxSteps {
+			break
+		}
+		weak := oa.Porcentaje < diagnosticReportWeakness
+		nivel := oa.NivelBloom + 1
+		if weak {
+			nivel = oa.NivelBloom
+		}
+		if nivel < 1 {
+			nivel = 1
+		}
+		if !weak && oa.NivelBloom >= maxLevel {
+			continue
+		}
+
+		step := DiagnosticReportStep{
+			OAID:             oa.OAID,
+			Codigo:           oa.Codigo,
+			NivelBloom:       nivel,
+			NivelBloomNombre: levelNames[nivel],
+		}
+		var objective models.OABloomObjective
+		result := db.DB.Joins("JOIN bloom_levels bl ON bl.id = oa_bloom_objectives.bloom_level_id").
+			Where("oa_bloom_objectives.oa_id = ? AND bl.nivel = ?", oa.OAID, nivel).
+			Limit(1).Find(&objective)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected > 0 {
+			step.OABloomObjectiveID = &objective.ID
+		}
+
+		if weak {
+			step.Texto = fmt.Sprintf("Reforzar %s en el nivel %s", oa.Codigo, step.NivelBloomNombre)
+		} else {
+			step.Texto = fmt.Sprintf("Avanzar en %s al nivel %s", oa.Codigo, step.NivelBloomNombre)
+		}
+		if objective.ObjetivoEspecifico != "" {
+			step.Texto += ": " + objective.ObjetivoEspecifico
+		} else if oa.Titulo != "" {
+			step.Texto += ": " + oa.Titulo
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// RenderDiagnosticReport renderiza el informe como PDF o como HTML imprimible
+func RenderDiagnosticReport(report *DiagnosticReport, format string) (*DiagnosticReportFile, error) {
+	if format == "" {
+		format = ReportFormatPDF
+	}
+	name := fmt.Sprintf("diagnostico-%d-%s-%s-intento-%d", report.SessionID, exportSlug(report.Estudiante), exportSlug(report.Materia), report.NumeroIntento)
+
+	switch format {
+	case ReportFormatPDF:
+		data, err := renderDiagnosticReportPDF(report)
+		if err != nil {
+			return nil, err
+		}
+		return &DiagnosticReportFile{Filename: name + ".pdf", ContentType: "application/pdf", Data: data}, nil
+	case ReportFormatHTML:
+		var buf bytes.Buffer
+		if err := diagnosticReportTemplate.Execute(&buf, report); err != nil {
+			return nil, err
+		}
+		return &DiagnosticReportFile{Filename: name + ".html", ContentType: "text/html; charset=utf-8", Data: buf.Bytes()}, nil
+	}
+	return nil, fmt.Errorf("%w: %q (opciones: %s, %s, %s)", ErrInvalidExportFormat, format, ReportFormatPDF, ReportFormatHTML, ReportFormatJSON)
+}
+
+// ExportClassroomDiagnosticReports genera un zip con el informe del último diagnóstico completado de
+// cada estudiante del curso (según el curso_actual de su perfil), opcionalmente de una sola materia.
+func ExportClassroomDiagnosticReports(cursoID uint, materiaID *uint, format string) (*DiagnosticReportFile, error) {
+	if format == "" {
+		format = ReportFormatPDF
+	}
+	if format != ReportFormatPDF && format != ReportFormatHTML {
+		return nil, fmt.Errorf("%w: %q (opciones: %s, %s)", ErrInvalidExportFormat, format, ReportFormatPDF, ReportFormatHTML)
+	}
+
+	curso, userIDs, err := classroomStudents(cursoID)
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []models.DiagnosticSession
+	if len(userIDs) > 0 {
+		query := db.DB.Preload("User").Preload("Materia").
+			Where("user_id IN ? AND estado = ?", userIDs, "completado")
+		if materiaID != nil {
+			query = query.Where("materia_id = ?", *materiaID)
+		}
+		err := query.Order("user_id, materia_id, numero_intento DESC, completed_at DESC, id DESC").Find(&sessions).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	seen := make(map[[2]uint]bool)
+	for i := range sessions {
+		key := [2]uint{sessions[i].UserID, sessions[i].MateriaID}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		report, err := buildDiagnosticReport(&sessions[i])
+		if err != nil {
+			return nil, err
+		}
+		file, err := RenderDiagnosticReport(report, format)
+		if err != nil {
+			return nil, err
+		}
+		entry, err := archive.Create(file.Filename)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := entry.Write(file.Data); err != nil {
+			return nil, err
+		}
+	}
+	if len(seen) == 0 {
+		// Un zip vacío no dice nada: se deja una nota para el docente
+		entry, err := archive.Create("LEEME.txt")
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(entry, "Ningún estudiante de %s tiene diagnósticos completados.\n", curso.Nombre)
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+
+	return &DiagnosticReportFile{
+		Filename:    fmt.Sprintf("diagnosticos-%s-%s.zip", exportSlug(curso.Nombre), format),
+		ContentType: "application/zip",
+		Data:        buf.Bytes(),
+	}, nil
+}
+
+// classroomStudents retorna el curso activo y los estudiantes cuyo perfil lo tiene como curso_actual
+func classroomStudents(cursoID uint) (*models.Curso, []uint, error) {
+	var cursos []models.Curso
+	if err := db.DB.Where("activo = ?", true).Order("id").Find(&cursos).Error; err != nil {
+		return nil, nil, err
+	}
+	var curso *models.Curso
+	for i := range cursos {
+		if cursos[i].ID == cursoID {
+			curso = &cursos[i]
+		}
+	}
+	if curso == nil {
+		return nil, nil, ErrClassroomNotFound
+	}
+
+	var profiles []models.StudentProfile
+	if err := db.DB.Where("curso_actual <> ''").Find(&profiles).Error; err != nil {
+		return nil, nil, err
+	}
+	var userIDs []uint
+	for _, profile := range profiles {
+		if match := matchCurso(cursos, profile.CursoActual); match != nil && match.ID == cursoID {
+			userIDs = append(userIDs, profile.UserID)
+		}
+	}
+	return curso, userIDs, nil
+}
+
+func reportDate(value time.Time) string {
+	return value.Format("02/01/2006")
+}
+
+// reportPercent formatea un valor entre 0 y 1 como porcentaje entero
+func reportPercent(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprintf("%.0f%%", *value*100)
+}
+
+// reportLevelChange describe el cambio de nivel respecto del intento anterior
+func reportLevelChange(oa DiagnosticReportOA, levels []DiagnosticReportLevel) string {
+	if oa.NivelAnterior == nil {
+		return ""
+	}
+	name := fmt.Sprintf("nivel %d", *oa.NivelAnterior)
+	for _, level := range levels {
+		if level.Nivel == *oa.NivelAnterior {
+			name = level.Nombre
+		}
+	}
+	diff := oa.NivelBloom - *oa.NivelAnterior
+	switch {
+	case diff > 0:
+		return fmt.Sprintf("antes %s (+%d)", name, diff)
+	case diff < 0:
+		return fmt.Sprintf("antes %s (%d)", name, diff)
+	}
+	return fmt.Sprintf("antes %s (sin cambio)", name)
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/platanus-hack-25/lumera_app/pkg/pdf"
+)
+
+// Diseño del PDF del informe de diagnóstico (puntos, A4)
+const (
+	reportMargin      = 50.0
+	reportWidth       = pdf.PageWidth - 2*reportMargin
+	reportBottom      = pdf.PageHeight - 60
+	reportBarWidth    = 180.0
+	reportBarHeight   = 10.0
+	reportLabelWidth  = reportWidth - reportBarWidth - 130
+	reportSectionSize = 13.0
+	reportBodySize    = 10.0
+	reportSmallSize   = 8.5
+)
+
+var (
+	reportInk     = pdf.Hex("#1f2937")
+	reportMuted   = pdf.Hex("#6b7280")
+	reportRule    = pdf.Hex("#e5e7eb")
+	reportEmpty   = pdf.Hex("#f3f4f6")
+	reportGood    = pdf.Hex("#10b981")
+	reportWarning = pdf.Hex("#f59e0b")
+	reportAccent  = pdf.Hex("#3b82f6")
+)
+
+// reportPage lleva la posición vertical y agrega páginas cuando el contenido no cabe
+type reportPage struct {
+	doc    *pdf.Document
+	report *DiagnosticReport
+	y      float64
+}
+
+func (p *reportPage) newPage() {
+	p.doc.AddPage()
+	p.y = reportMargin
+	footer := fmt.Sprintf("%s · %s · intento %d · página %d", p.report.Estudiante, p.report.Materia, p.report.NumeroIntento, p.doc.PageCount())
+	p.doc.Text(reportMargin, pdf.PageHeight-30, reportSmallSize, false, reportMuted, footer)
+}
+
+// ensure pasa a una página nueva si faltan height puntos
+func (p *reportPage) ensure(height float64) {
+	if p.y+height > reportBottom {
+		p.newPage()
+	}
+}
+
+func (p *reportPage) section(title string) {
+	p.ensure(50)
+	p.y += 18
+	p.doc.Text(reportMargin, p.y, reportSectionSize, true, reportInk, title)
+	p.y += 6
+	p.doc.Line(reportMargin, p.y, reportMargin+reportWidth, p.y, 1, reportRule)
+	p.y += 16
+}
+
+// paragraph escribe texto con saltos de línea; indent deja espacio para una viñeta
+func (p *reportPage) paragraph(text string, size float64, bold bool, color pdf.Color, indent float64) {
+	for _, line := range pdf.Wrap(text, size, bold, reportWidth-indent) {
+		p.ensure(size + 4)
+		p.doc.Text(reportMargin+indent, p.y, size, bold, color, line)
+		p.y += size + 4
+	}
+}
+
+func (p *reportPage) bullet(text string, color pdf.Color) {
+	p.ensure(reportBodySize + 4)
+	p.doc.Rect(reportMargin+2, p.y-6, 5, 5, color)
+	p.paragraph(text, reportBodySize, false, reportInk, 14)
+	p.y += 2
+}
+
+// levelColor es el color del nivel de Bloom, o el de acento si el nivel no tiene color
+func (p *reportPage) levelColor(nivel int) pdf.Color {
+	for _, level := range p.report.Niveles {
+		if level.Nivel == nivel && level.Color != "" {
+			return pdf.Hex(level.Color)
+		}
+	}
+	return reportAccent
+}
+
+// bloomBar dibuja un segmento por nivel, pintados hasta el nivel dominado
+func (p *reportPage) bloomBar(x, y float64, nivel int) {
+	count := len(p.report.Niveles)
+	if count == 0 {
+		count = 6
+	}
+	segment := reportBarWidth / float64(count)
+	for i := 1; i <= count; i++ {
+		color := reportEmpty
+		if i <= nivel {
+			color = p.levelColor(i)
+		}
+		p.doc.Rect(x+float64(i-1)*segment, y, segment-2, reportBarHeight, color)
+	}
+}
+
+func renderDiagnosticReportPDF(report *DiagnosticReport) ([]byte, error) {
+	doc := pdf.New()
+	doc.Title = fmt.Sprintf("Informe de diagnóstico: %s", report.Materia)
+	doc.Author = "Lumera"
+	p := &reportPage{doc: doc, report: report}
+	p.newPage()
+
+	// Encabezado y resumen
+	p.doc.Text(reportMargin, p.y+10, 20, true, reportInk, "Informe de diagnóstico")
+	p.y += 32
+	p.paragraph(fmt.Sprintf("%s · %s · Intento %d · %s", report.Estudiante, report.Materia, report.NumeroIntento, reportDate(report.Fecha)),
+		reportBodySize+1, false, reportMuted, 0)
+	p.y += 6
+	summary := fmt.Sprintf("Aciertos: %d de %d (%d%%)   ·   Nivel Bloom promedio: %.1f", report.PreguntasCorrectas, report.PreguntasTotales, report.Porcentaje, report.NivelPromedio)
+	if report.Compromiso != nil {
+		summary += "   ·   Compromiso: " + reportPercent(report.Compromiso)
+	}
+	p.paragraph(summary, reportBodySize, true, reportInk, 0)
+	if report.AdivinanzasRapidas > 0 {
+		p.paragraph(fmt.Sprintf("%d respuestas fueron demasiado rápidas para considerarse un esfuerzo real; los resultados pueden subestimar el nivel.", report.AdivinanzasRapidas),
+			reportSmallSize, false, reportWarning, 0)
+	}
+
+	// Barras de nivel de Bloom por OA
+	p.section("Nivel de Bloom por objetivo")
+	legendX := reportMargin
+	for _, level := range report.Niveles {
+		label := fmt.Sprintf("%d %s", level.Nivel, level.Nombre)
+		width := pdf.TextWidth(label, reportSmallSize, false) + 22
+		if legendX+width > reportMargin+reportWidth {
+			legendX = reportMargin
+			p.y += 14
+		}
+		p.doc.Rect(legendX, p.y-7, 8, 8, p.levelColor(level.Nivel))
+		p.doc.Text(legendX+11, p.y, reportSmallSize, false, reportMuted, label)
+		legendX += width
+	}
+	p.y += 18
+	if len(report.OAs) == 0 {
+		p.paragraph("La sesión no tiene resultados por objetivo.", reportBodySize, false, reportMuted, 0)
+	}
+	for _, oa := range report.OAs {
+		title := pdf.Wrap(oa.Codigo+" — "+oa.Titulo, reportBodySize-1, false, reportLabelWidth)
+		height := float64(len(title))*(reportBodySize+3) + 8
+		if height < 30 {
+			height = 30
+		}
+		p.ensure(height)
+		top := p.y
+		for i, line := range title {
+			p.doc.Text(reportMargin, top+float64(i)*(reportBodySize+3), reportBodySize-1, i == 0, reportInk, line)
+		}
+		barX := reportMargin + reportLabelWidth + 10
+		p.bloomBar(barX, top-8, oa.NivelBloom)
+		infoX := barX + reportBarWidth + 8
+		p.doc.Text(infoX, top, reportBodySize-1, true, reportInk, fmt.Sprintf("%s · %d%%", oa.NivelBloomNombre, oa.Porcentaje))
+		if change := reportLevelChange(oa, report.Niveles); change != "" {
+			p.doc.Text(infoX, top+12, reportSmallSize, false, reportMuted, change)
+		}
+		p.y = top + height
+	}
+
+	// Fortalezas y debilidades
+	p.section("Fortalezas")
+	if len(report.Fortalezas) == 0 {
+		p.paragraph(fmt.Sprintf("Aún no hay objetivos con %d%% o más de aciertos.", diagnosticReportStrength), reportBodySize, false, reportMuted, 0)
+	}
+	for _, oa := range report.Fortalezas {
+		p.bullet(fmt.Sprintf("%s — %s (%s, %d%%)", oa.Codigo, oa.Titulo, oa.NivelBloomNombre, oa.Porcentaje), reportGood)
+	}
+	p.section("Por reforzar")
+	if len(report.Debilidades) == 0 {
+		p.paragraph(fmt.Sprintf("Ningún objetivo quedó bajo %d%% de aciertos.", diagnosticReportWeakness), reportBodySize, false, reportMuted, 0)
+	}
+	for _, oa := range report.Debilidades {
+		p.bullet(fmt.Sprintf("%s — %s (%s, %d%%)", oa.Codigo, oa.Titulo, oa.NivelBloomNombre, oa.Porcentaje), reportWarning)
+	}
+
+	// Conceptos erróneos revelados por los distractores
+	if len(report.ConceptosErroneos) > 0 {
+		p.section("Posibles confusiones")
+		for _, misconception := range report.ConceptosErroneos {
+			text := misconception.Mensaje
+			if misconception.Veces > 1 {
+				text += fmt.Sprintf(" (%d respuestas)", misconception.Veces)
+			}
+			p.bullet(text, reportWarning)
+			if misconception.Remediacion != "" {
+				p.paragraph(misconception.Remediacion, reportSmallSize, false, reportMuted, 14)
+				p.y += 2
+			}
+		}
+	}
+
+	// Comparación con intentos anteriores
+	p.section("Intentos de diagnóstico")
+	if len(report.Intentos) <= 1 {
+		p.paragraph("Es el primer diagnóstico de esta materia.", reportBodySize, false, reportMuted, 0)
+	} else {
+		columns := []struct {
+			title string
+			x     float64
+		}{{"Intento", 0}, {"Fecha", 60}, {"Aciertos", 150}, {"", 210}, {"Nivel promedio", 350}, {"OAs", 450}}
+		for _, column := range columns {
+			p.doc.Text(reportMargin+column.x, p.y, reportSmallSize, true, reportMuted, column.title)
+		}
+		p.y += 16
+		for _, attempt := range report.Intentos {
+			p.ensure(18)
+			bold := attempt.Actual
+			p.doc.Text(reportMargin, p.y, reportBodySize-1, bold, reportInk, fmt.Sprintf("%d", attempt.NumeroIntento))
+			p.doc.Text(reportMargin+60, p.y, reportBodySize-1, bold, reportInk, reportDate(attempt.Fecha))
+			p.doc.Text(reportMargin+150, p.y, reportBodySize-1, bold, reportInk, fmt.Sprintf("%d%%", attempt.Porcentaje))
+			p.doc.Rect(reportMargin+210, p.y-8, 120, 8, reportEmpty)
+			p.doc.Rect(reportMargin+210, p.y-8, 120*float64(attempt.Porcentaje)/100, 8, reportAccent)
+			p.doc.Text(reportMargin+350, p.y, reportBodySize-1, bold, reportInk, fmt.Sprintf("%.1f", attempt.NivelPromedio))
+			p.doc.Text(reportMargin+450, p.y, reportBodySize-1, bold, reportInk, fmt.Sprintf("%d", attempt.OAsEvaluados))
+			p.y += 16
+		}
+	}
+
+	// Próximos pasos
+	p.section("Próximos pasos")
+	if len(report.ProximosPasos) == 0 {
+		p.paragraph("Todos los objetivos evaluados están dominados en el nivel más alto.", reportBodySize, false, reportMuted, 0)
+	}
+	for i, step := range report.ProximosPasos {
+		p.ensure(reportBodySize + 4)
+		p.doc.Text(reportMargin, p.y, reportBodySize, true, reportAccent, fmt.Sprintf("%d.", i+1))
+		p.paragraph(step.Texto, reportBodySize, false, reportInk, 14)
+		p.y += 4
+	}
+
+	p.y += 10
+	p.paragraph("Generado el "+reportDate(report.GeneradoEl), reportSmallSize, false, reportMuted, 0)
+	return doc.Bytes()
+}
@@ -0,0 +1,554 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"github.com/platanus-hack-25/lumera_app/pkg/llm"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// GenerationJobService handles the DB-backed queue of asynchronous generation jobs
+type GenerationJobService struct{}
+
+// NewGenerationJobService creates a new generation job service instance
+func NewGenerationJobService() *GenerationJobService {
+	return &GenerationJobService{}
+}
+
+// jobWake wakes an idle worker when a job is enqueued
+var jobWake = make(chan struct{}, 1)
+
+// jobSubscribers are notified whenever a job or its components change (used by SSE)
+var (
+	jobSubscribersMu sync.Mutex
+	jobSubscribers   = make(map[uint]map[chan struct{}]struct{})
+)
+
+// SubscribeGenerationJob returns a channel signalled on every progress change of the job
+// and a function to cancel the subscription
+func SubscribeGenerationJob(jobID uint) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	jobSubscribersMu.Lock()
+	if jobSubscribers[jobID] == nil {
+		jobSubscribers[jobID] = make(map[chan struct{}]struct{})
+	}
+	jobSubscribers[jobID][ch] = struct{}{}
+	jobSubscribersMu.Unlock()
+
+	return ch, func() {
+		jobSubscribersMu.Lock()
+		delete(jobSubscribers[jobID], ch)
+		if len(jobSubscribers[jobID]) == 0 {
+			delete(jobSubscribers, jobID)
+		}
+		jobSubscribersMu.Unlock()
+	}
+}
+
+func notifyGenerationJob(jobID uint) {
+	jobSubscribersMu.Lock()
+	defer jobSubscribersMu.Unlock()
+	for ch := range jobSubscribers[jobID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// EnqueueLearningPlan queues the generation of a learning plan. If an active job already
+// exists for the same user and objective it is returned instead (created = false).
+func (s *GenerationJobService) EnqueueLearningPlan(userID, oaBloomObjectiveID uint) (*models.GenerationJob, bool, error) {
+	if existing, err := activeLearningPlanJob(userID, oaBloomObjectiveID); err == nil {
+		return existing, false, nil
+	}
+
+	// Un plan que quedó a medias se retoma en vez de crear otro
+	var planID *uint
+	var plan models.LearningPlan
+	if err := db.DB.Select("id").Where("user_id = ? AND oa_bloom_objective_id = ? AND estado = ?",
+		userID, oaBloomObjectiveID, models.LearningPlanEstadoGenerando).First(&plan).Error; err == nil {
+		planID = &plan.ID
+	}
+
+	job, err := s.enqueue(userID, planID, models.LearningPlanJobPayload{OABloomObjectiveID: oaBloomObjectiveID})
+	if err != nil {
+		// A concurrent request enqueued the same plan first (idx_generation_jobs_active_plan)
+		if isUniqueViolation(err) {
+			if existing, findErr := activeLearningPlanJob(userID, oaBloomObjectiveID); findErr == nil {
+				return existing, false, nil
+			}
+		}
+		return nil, false, err
+	}
+	return job, true, nil
+}
+
+// activeLearningPlanJob returns the pending or in-progress job for the user and objective
+func activeLearningPlanJob(userID, oaBloomObjectiveID uint) (*models.GenerationJob, error) {
+	var job models.GenerationJob
+	err := db.DB.Where("user_id = ? AND tipo = ? AND payload->>'oa_bloom_objective_id' = ? AND estado IN ?",
+		userID, models.GenerationJobTipoLearningPlan, fmt.Sprint(oaBloomObjectiveID),
+		[]string{models.GenerationJobEstadoPendiente, models.GenerationJobEstadoEnProceso}).
+		First(&job).Error
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// isUniqueViolation reports whether err is a PostgreSQL unique_violation (SQLSTATE 23505)
+func isUniqueViolation(err error) bool {
+	var pgErr interface{ SQLState() string }
+	return errors.As(err, &pgErr) && pgErr.SQLState() == "23505"
+}
+
+// EnqueuePlanRegeneration queues the generation of an existing plan that RegeneratePlan emptied
+func (s *GenerationJobService) EnqueuePlanRegeneration(plan *models.LearningPlan) (*models.GenerationJob, error) {
+	return s.enqueue(plan.UserID, &plan.ID, models.LearningPlanJobPayload{OABloomObjectiveID: plan.OABloomObjectiveID})
+}
+
+func (s *GenerationJobService) enqueue(userID uint, planID *uint, payload models.LearningPlanJobPayload) (*models.GenerationJob, error) {
+	payloadJSON, _ := json.Marshal(payload)
+	job := models.GenerationJob{
+		Tipo:           models.GenerationJobTipoLearningPlan,
+		UserID:         userID,
+		LearningPlanID: planID,
+		Payload:        datatypes.JSON(payloadJSON),
+		Estado:         models.GenerationJobEstadoPendiente,
+		MaxIntentos:    getEnvInt("GENERATION_JOB_MAX_ATTEMPTS", 3),
+		RunAfter:       time.Now(),
+	}
+	if err := db.DB.Create(&job).Error; err != nil {
+		return nil, err
+	}
+
+	select {
+	case jobWake <- struct{}{}:
+	default:
+	}
+	return &job, nil
+}
+
+// GetJob returns a job owned by the user
+func (s *GenerationJobService) GetJob(jobID, userID uint) (*models.GenerationJob, error) {
+	var job models.GenerationJob
+	if err := db.DB.Where("id = ? AND user_id = ?", jobID, userID).First(&job).Error; err != nil {
+		return nil, errors.New("job not found")
+	}
+	return &job, nil
+}
+
+// ComponentProgress is the generation state of a single component
+type ComponentProgress struct {
+	ComponentID    uint   `json:"component_id"`
+	Orden          int    `json:"orden"`
+	TipoComponente string `json:"tipo_componente"`
+	Estado         string `json:"estado"`
+	ErrorMensaje   string `json:"error_mensaje,omitempty"`
+}
+
+// GenerationJobProgress is a snapshot of a job and its plan components
+type GenerationJobProgress struct {
+	Job        models.GenerationJob `json:"job"`
+	Components []ComponentProgress  `json:"components"`
+	Total      int                  `json:"total"`
+	Generados  int                  `json:"generados"`
+}
+
+// GetJobProgress returns the current progress of a job owned by the user
+func (s *GenerationJobService) GetJobProgress(jobID, userID uint) (*GenerationJobProgress, error) {
+	job, err := s.GetJob(jobID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := &GenerationJobProgress{Job: *job, Components: []ComponentProgress{}}
+	if job.LearningPlanID == nil {
+		return progress, nil
+	}
+
+	var components []models.LearningPlanComponent
+	if err := db.DB.Select("id", "orden", "tipo_componente", "estado", "error_mensaje").
+		Where("learning_plan_id = ?", *job.LearningPlanID).
+		Order("orden ASC").Find(&components).Error; err != nil {
+		return nil, err
+	}
+
+	for _, c := range components {
+		progress.Components = append(progress.Components, ComponentProgress{
+			ComponentID:    c.ID,
+			Orden:          c.Orden,
+			TipoComponente: c.TipoComponente,
+			Estado:         c.Estado,
+			ErrorMensaje:   c.ErrorMensaje,
+		})
+		if c.Estado == models.ComponentEstadoGenerado {
+			progress.Generados++
+		}
+	}
+	progress.Total = len(components)
+
+	return progress, nil
+}
+
+// StartGenerationWorkers recovers stale work and starts the background workers.
+// Workers stop when ctx is cancelled.
+func StartGenerationWorkers(ctx context.Context) {
+	s := NewGenerationJobService()
+	workers := getEnvInt("GENERATION_WORKERS", 2)
+	staleAfter := time.Duration(getEnvInt("GENERATION_JOB_STALE_MINUTES", 10)) * time.Minute
+
+	if err := s.RecoverStale(staleAfter, true); err != nil {
+		log.Printf("⚠ Failed to recover stale generation jobs: %v", err)
+	}
+
+	hostname, _ := os.Hostname()
+	for i := 1; i <= workers; i++ {
+		go s.runWorker(ctx, fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), i))
+	}
+
+	// Janitor: releases jobs whose worker stopped sending heartbeats
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.RecoverStale(staleAfter, false); err != nil {
+					log.Printf("⚠ Failed to recover stale generation jobs: %v", err)
+				}
+			}
+		}
+	}()
+
+	log.Printf("✓ Started %d generation workers", workers)
+}
+
+// RecoverStale releases jobs locked by workers that stopped sending heartbeats. When
+// orphanedPlans is set (startup), plans stuck in 'generando' without an active job are
+// re-queued so their pending components get generated.
+func (s *GenerationJobService) RecoverStale(staleAfter time.Duration, orphanedPlans bool) error {
+	cutoff := time.Now().Add(-staleAfter)
+
+	// Stale jobs that still have attempts left go back to the queue...
+	released := db.DB.Model(&models.GenerationJob{}).
+		Where("estado = ? AND locked_at < ? AND intentos < max_intentos", models.GenerationJobEstadoEnProceso, cutoff).
+		Updates(map[string]interface{}{
+			"estado":     models.GenerationJobEstadoPendiente,
+			"locked_by":  nil,
+			"locked_at":  nil,
+			"run_after":  time.Now(),
+			"updated_at": time.Now(),
+		})
+	if released.Error != nil {
+		return released.Error
+	}
+
+	// ...the rest are dead-lettered
+	var exhausted []models.GenerationJob
+	if err := db.DB.Where("estado = ? AND locked_at < ? AND intentos >= max_intentos", models.GenerationJobEstadoEnProceso, cutoff).
+		Find(&exhausted).Error; err != nil {
+		return err
+	}
+	for i := range exhausted {
+		s.deadLetter(&exhausted[i], exhausted[i].LockedBy, errors.New("worker stopped responding"))
+	}
+
+	if released.RowsAffected > 0 || len(exhausted) > 0 {
+		log.Printf("♻ Recovered %d stale generation jobs (%d dead-lettered)", released.RowsAffected, len(exhausted))
+	}
+
+	if !orphanedPlans {
+		return nil
+	}
+
+	var plans []models.LearningPlan
+	err := db.DB.Where("estado = ?", models.LearningPlanEstadoGenerando).
+		Where("NOT EXISTS (SELECT 1 FROM generation_jobs j WHERE j.learning_plan_id = learning_plans.id AND j.estado IN ?)",
+			[]string{models.GenerationJobEstadoPendiente, models.GenerationJobEstadoEnProceso}).
+		Find(&plans).Error
+	if err != nil {
+		return err
+	}
+
+	for _, plan := range plans {
+		db.DB.Model(&models.LearningPlanComponent{}).
+			Where("learning_plan_id = ? AND estado = ?", plan.ID, models.ComponentEstadoGenerando).
+			Update("estado", models.ComponentEstadoPendiente)
+
+		planID := plan.ID
+		if _, err := s.enqueue(plan.UserID, &planID, models.LearningPlanJobPayload{OABloomObjectiveID: plan.OABloomObjectiveID}); err != nil {
+			log.Printf("⚠ Failed to re-queue stale plan %d: %v", plan.ID, err)
+			continue
+		}
+		log.Printf("♻ Re-queued stale learning plan %d (%s)", plan.ID, plan.Titulo)
+	}
+
+	return nil
+}
+
+func (s *GenerationJobService) runWorker(ctx context.Context, workerID string) {
+	pollInterval := time.Duration(getEnvInt("GENERATION_POLL_SECONDS", 2)) * time.Second
+
+	for {
+		job, err := s.claimNext(workerID)
+		if err != nil {
+			log.Printf("⚠ Worker %s failed to claim job: %v", workerID, err)
+		}
+
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-jobWake:
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+
+		s.process(ctx, workerID, job)
+	}
+}
+
+// claimNext locks the oldest runnable job using SKIP LOCKED so several workers
+// (or instances) never take the same job
+func (s *GenerationJobService) claimNext(workerID string) (*models.GenerationJob, error) {
+	var job models.GenerationJob
+	err := db.DB.Raw(`
+		UPDATE generation_jobs
+		SET estado = ?, locked_by = ?, locked_at = NOW(), intentos = intentos + 1, updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM generation_jobs
+			WHERE estado = ? AND run_after <= NOW()
+			ORDER BY run_after, id
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING *
+	`, models.GenerationJobEstadoEnProceso, workerID, models.GenerationJobEstadoPendiente).Scan(&job).Error
+	if err != nil {
+		return nil, err
+	}
+	if job.ID == 0 {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+func (s *GenerationJobService) process(ctx context.Context, workerID string, job *models.GenerationJob) {
+	log.Printf("🛠 Worker %s processing job %d (%s, attempt %d/%d)", workerID, job.ID, job.Tipo, job.Intentos, job.MaxIntentos)
+	notifyGenerationJob(job.ID)
+
+	stopKeepAlive := s.keepAlive(job.ID, workerID)
+	var err error
+	switch job.Tipo {
+	case models.GenerationJobTipoLearningPlan:
+		err = s.runLearningPlanJob(ctx, job)
+	default:
+		err = fmt.Errorf("unknown job type: %s", job.Tipo)
+	}
+	stopKeepAlive()
+
+	if err != nil {
+		s.fail(job, workerID, err)
+		return
+	}
+
+	now := time.Now()
+	if !s.finish(job, workerID, map[string]interface{}{
+		"estado":        models.GenerationJobEstadoCompletado,
+		"completed_at":  now,
+		"error_mensaje": "",
+	}) {
+		return
+	}
+	log.Printf("✅ Job %d completed", job.ID)
+}
+
+// finish writes the outcome of a job and releases its lock, but only while workerID still holds it:
+// if the janitor released the job and another worker claimed it, that worker's state is left alone
+func (s *GenerationJobService) finish(job *models.GenerationJob, workerID string, updates map[string]interface{}) bool {
+	updates["locked_by"] = nil
+	updates["locked_at"] = nil
+	updates["updated_at"] = time.Now()
+	result := db.DB.Model(&models.GenerationJob{}).
+		Where("id = ? AND locked_by = ? AND estado = ?", job.ID, workerID, models.GenerationJobEstadoEnProceso).
+		Updates(updates)
+	if result.Error != nil {
+		log.Printf("⚠ Failed to update job %d: %v", job.ID, result.Error)
+		return false
+	}
+	if result.RowsAffected == 0 {
+		log.Printf("⚠ Job %d is no longer held by worker %s; discarding its outcome", job.ID, workerID)
+		return false
+	}
+	notifyGenerationJob(job.ID)
+	return true
+}
+
+// heartbeat refreshes the job lock and notifies subscribers after each step of progress
+func (s *GenerationJobService) heartbeat(job *models.GenerationJob) {
+	refreshJobLock(job.ID, job.LockedBy)
+	notifyGenerationJob(job.ID)
+}
+
+// keepAlive refreshes the job lock every GENERATION_JOB_HEARTBEAT_SECONDS while the job runs, so
+// a slow LLM call is not mistaken for a dead worker by the janitor. The returned function stops it.
+func (s *GenerationJobService) keepAlive(jobID uint, workerID string) func() {
+	interval := time.Duration(clampInt(getEnvInt("GENERATION_JOB_HEARTBEAT_SECONDS", 30), 1, 300)) * time.Second
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := refreshJobLock(jobID, workerID); err != nil {
+					log.Printf("⚠ Failed to refresh lock of job %d: %v", jobID, err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// refreshJobLock only touches the job while this worker still holds it, so a job the janitor
+// already released is not claimed back
+func refreshJobLock(jobID uint, workerID string) error {
+	return db.DB.Model(&models.GenerationJob{}).
+		Where("id = ? AND locked_by = ? AND estado = ?", jobID, workerID, models.GenerationJobEstadoEnProceso).
+		Update("locked_at", time.Now()).Error
+}
+
+func (s *GenerationJobService) runLearningPlanJob(ctx context.Context, job *models.GenerationJob) error {
+	var payload models.LearningPlanJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+
+	oaContext, err := BuildOAContext(job.UserID, payload.OABloomObjectiveID)
+	if err != nil {
+		return err
+	}
+
+	llmCtx := llm.WithUser(ctx, job.UserID)
+
+	var plan *models.LearningPlan
+	if job.LearningPlanID != nil {
+		plan = &models.LearningPlan{}
+		if err := db.DB.Preload("Components", func(tx *gorm.DB) *gorm.DB {
+			return tx.Order("orden ASC")
+		}).First(plan, *job.LearningPlanID).Error; err != nil {
+			return fmt.Errorf("learning plan not found: %w", err)
+		}
+
+		// Un plan sin componentes se está regenerando completo: nueva estructura con el feedback del estudiante
+		if len(plan.Components) == 0 {
+			structureContext := *oaContext
+			structureContext.FeedbackEstudiante = RegenerationFeedback(plan, nil)
+			structure, err := GenerateLearningPlanStructure(llmCtx, structureContext)
+			if err != nil {
+				return err
+			}
+			if err := ReplacePlanStructure(plan, structure); err != nil {
+				return fmt.Errorf("failed to replace plan structure: %w", err)
+			}
+			s.heartbeat(job)
+		}
+	} else {
+		structure, err := GenerateLearningPlanStructure(llmCtx, *oaContext)
+		if err != nil {
+			return err
+		}
+
+		plan, err = CreatePlanFromStructure(job.UserID, payload.OABloomObjectiveID, structure, func(tx *gorm.DB, plan *models.LearningPlan) error {
+			job.LearningPlanID = &plan.ID
+			return tx.Model(job).Update("learning_plan_id", plan.ID).Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create plan: %w", err)
+		}
+		s.heartbeat(job)
+	}
+
+	generated := GeneratePendingComponents(llmCtx, plan, *oaContext, func() { s.heartbeat(job) })
+	if len(plan.Components) > 0 && generated == 0 {
+		return errors.New("all components failed to generate")
+	}
+
+	// Los componentes con error se pueden regenerar después de forma individual
+	plan.Estado = models.LearningPlanEstadoGenerado
+	plan.ErrorMensaje = ""
+	plan.TotalSlides = len(plan.Components)
+	db.DB.Model(plan).Updates(map[string]interface{}{
+		"estado":        plan.Estado,
+		"error_mensaje": "",
+		"total_slides":  plan.TotalSlides,
+	})
+
+	log.Printf("✅ Learning plan fully generated: %s (%d/%d components successful)", plan.Titulo, generated, len(plan.Components))
+	return nil
+}
+
+// fail schedules a retry with exponential backoff or dead-letters the job
+func (s *GenerationJobService) fail(job *models.GenerationJob, workerID string, cause error) {
+	if job.Intentos >= job.MaxIntentos {
+		s.deadLetter(job, workerID, cause)
+		return
+	}
+
+	backoff := time.Duration(getEnvInt("GENERATION_RETRY_BASE_SECONDS", 30)) * time.Second << (job.Intentos - 1)
+	if !s.finish(job, workerID, map[string]interface{}{
+		"estado":        models.GenerationJobEstadoPendiente,
+		"error_mensaje": cause.Error(),
+		"run_after":     time.Now().Add(backoff),
+	}) {
+		return
+	}
+
+	log.Printf("⚠ Job %d failed (attempt %d/%d): %v. Retrying in %v", job.ID, job.Intentos, job.MaxIntentos, cause, backoff)
+}
+
+// deadLetter gives up on a job held by workerID (the janitor passes the lock holder it found stale)
+func (s *GenerationJobService) deadLetter(job *models.GenerationJob, workerID string, cause error) {
+	if !s.finish(job, workerID, map[string]interface{}{
+		"estado":        models.GenerationJobEstadoDeadLetter,
+		"error_mensaje": cause.Error(),
+		"completed_at":  time.Now(),
+	}) {
+		return
+	}
+
+	if job.LearningPlanID != nil {
+		db.DB.Model(&models.LearningPlan{}).Where("id = ?", *job.LearningPlanID).Updates(map[string]interface{}{
+			"estado":        models.LearningPlanEstadoError,
+			"error_mensaje": cause.Error(),
+		})
+	}
+	notifyGenerationJob(job.ID)
+
+	log.Printf("❌ Job %d moved to dead letter after %d attempts: %v", job.ID, job.Intentos, cause)
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db/dbtest"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func createGenerationJob(t *testing.T, gdb *gorm.DB, userID uint, runAfter time.Time) models.GenerationJob {
+	t.Helper()
+	job := models.GenerationJob{
+		Tipo:        models.GenerationJobTipoLearningPlan,
+		UserID:      userID,
+		Payload:     []byte(`{"oa_bloom_objective_id":1}`),
+		Estado:      models.GenerationJobEstadoPendiente,
+		MaxIntentos: 2,
+		RunAfter:    runAfter,
+	}
+	if err := gdb.Create(&job).Error; err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	return job
+}
+
+func reloadGenerationJob(t *testing.T, gdb *gorm.DB, id uint) models.GenerationJob {
+	t.Helper()
+	var job models.GenerationJob
+	if err := gdb.First(&job, id).Error; err != nil {
+		t.Fatalf("load job: %v", err)
+	}
+	return job
+}
+
+func TestGenerationJobClaimSkipsLockedAndFutureJobs(t *testing.T) {
+	gdb := dbtest.Open(t)
+	user := dbtest.CreateUser(t, gdb)
+	s := NewGenerationJobService()
+
+	first := createGenerationJob(t, gdb, user.ID, time.Now().Add(-2*time.Minute))
+	second := createGenerationJob(t, gdb, user.ID, time.Now().Add(-time.Minute))
+	createGenerationJob(t, gdb, user.ID, time.Now().Add(time.Hour))
+
+	// Otro worker tiene tomada la fila del primer job: SKIP LOCKED pasa al siguiente
+	tx := gdb.Begin()
+	defer tx.Rollback()
+	var locked models.GenerationJob
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, first.ID).Error; err != nil {
+		t.Fatalf("lock job: %v", err)
+	}
+
+	job, err := s.claimNext("worker-a")
+	if err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if job == nil || job.ID != second.ID {
+		t.Fatalf("claimed %+v, want job %d", job, second.ID)
+	}
+	if job.Estado != models.GenerationJobEstadoEnProceso || job.LockedBy != "worker-a" || job.Intentos != 1 || job.LockedAt == nil {
+		t.Errorf("claimed job = %+v", job)
+	}
+	tx.Rollback()
+
+	job, err = s.claimNext("worker-b")
+	if err != nil || job == nil || job.ID != first.ID {
+		t.Fatalf("claimed %+v (%v), want job %d", job, err, first.ID)
+	}
+
+	// Solo queda el job programado para el futuro
+	if job, err := s.claimNext("worker-c"); err != nil || job != nil {
+		t.Fatalf("claimed %+v (%v), want nothing", job, err)
+	}
+}
+
+func TestGenerationJobRetryThenDeadLetter(t *testing.T) {
+	gdb := dbtest.Open(t)
+	user := dbtest.CreateUser(t, gdb)
+	s := NewGenerationJobService()
+	t.Setenv("GENERATION_RETRY_BASE_SECONDS", "60")
+
+	created := createGenerationJob(t, gdb, user.ID, time.Now().Add(-time.Minute))
+
+	job, err := s.claimNext("worker-a")
+	if err != nil || job == nil {
+		t.Fatalf("claim: %+v %v", job, err)
+	}
+	s.fail(job, "worker-a", errors.New("llm down"))
+
+	retried := reloadGenerationJob(t, gdb, created.ID)
+	if retried.Estado != models.GenerationJobEstadoPendiente || retried.LockedAt != nil || retried.ErrorMensaje != "llm down" {
+		t.Fatalf("after first failure: %+v", retried)
+	}
+	if !retried.RunAfter.After(time.Now().Add(50 * time.Second)) {
+		t.Errorf("run_after = %v, want about a minute of backoff", retried.RunAfter)
+	}
+	if job, _ := s.claimNext("worker-a"); job != nil {
+		t.Fatal("job claimed before its backoff elapsed")
+	}
+
+	gdb.Model(&retried).Update("run_after", time.Now().Add(-time.Second))
+	job, err = s.claimNext("worker-a")
+	if err != nil || job == nil || job.Intentos != 2 {
+		t.Fatalf("second claim: %+v %v", job, err)
+	}
+	s.fail(job, "worker-a", errors.New("llm still down"))
+
+	dead := reloadGenerationJob(t, gdb, created.ID)
+	if dead.Estado != models.GenerationJobEstadoDeadLetter || dead.CompletedAt == nil || dead.ErrorMensaje != "llm still down" {
+		t.Fatalf("after last attempt: %+v", dead)
+	}
+}
+
+func TestGenerationJobRecoverStale(t *testing.T) {
+	gdb := dbtest.Open(t)
+	user := dbtest.CreateUser(t, gdb)
+	s := NewGenerationJobService()
+
+	stale := func(intentos int) uint {
+		job := createGenerationJob(t, gdb, user.ID, time.Now())
+		gdb.Model(&job).Updates(map[string]interface{}{
+			"estado":    models.GenerationJobEstadoEnProceso,
+			"locked_by": "dead-worker",
+			"locked_at": time.Now().Add(-time.Hour),
+			"intentos":  intentos,
+		})
+		return job.ID
+	}
+	released := stale(1)
+	exhausted := stale(2)
+
+	if err := s.RecoverStale(10*time.Minute, false); err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	if job := reloadGenerationJob(t, gdb, released); job.Estado != models.GenerationJobEstadoPendiente || job.LockedBy != "" {
+		t.Errorf("job with attempts left = %+v", job)
+	}
+	if job := reloadGenerationJob(t, gdb, exhausted); job.Estado != models.GenerationJobEstadoDeadLetter {
+		t.Errorf("exhausted job = %+v", job)
+	}
+}
+
+func TestGenerationJobKeepAliveSurvivesSlowCalls(t *testing.T) {
+	gdb := dbtest.Open(t)
+	user := dbtest.CreateUser(t, gdb)
+	s := NewGenerationJobService()
+	t.Setenv("GENERATION_JOB_HEARTBEAT_SECONDS", "1")
+
+	created := createGenerationJob(t, gdb, user.ID, time.Now().Add(-time.Minute))
+	job, err := s.claimNext("worker-a")
+	if err != nil || job == nil {
+		t.Fatalf("claim: %+v %v", job, err)
+	}
+	// La última señal de vida es antigua, como tras una llamada larga al LLM
+	gdb.Model(&models.GenerationJob{}).Where("id = ?", job.ID).Update("locked_at", time.Now().Add(-time.Hour))
+
+	stop := s.keepAlive(job.ID, "worker-a")
+	time.Sleep(1500 * time.Millisecond)
+	stop()
+
+	if err := s.RecoverStale(10*time.Minute, false); err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	if reloaded := reloadGenerationJob(t, gdb, created.ID); reloaded.Estado != models.GenerationJobEstadoEnProceso || reloaded.LockedBy != "worker-a" {
+		t.Errorf("job released while its worker was alive: %+v", reloaded)
+	}
+
+	// Un worker que perdió el job no lo vuelve a tomar
+	gdb.Model(&models.GenerationJob{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+		"estado": models.GenerationJobEstadoPendiente, "locked_by": nil, "locked_at": nil,
+	})
+	if err := refreshJobLock(job.ID, "worker-a"); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if reloaded := reloadGenerationJob(t, gdb, created.ID); reloaded.LockedAt != nil {
+		t.Errorf("released job refreshed: %+v", reloaded)
+	}
+}
+
+func TestGenerationJobStaleWorkerCannotOverwrite(t *testing.T) {
+	gdb := dbtest.Open(t)
+	user := dbtest.CreateUser(t, gdb)
+	s := NewGenerationJobService()
+
+	created := createGenerationJob(t, gdb, user.ID, time.Now().Add(-time.Minute))
+	stale, err := s.claimNext("worker-a")
+	if err != nil || stale == nil {
+		t.Fatalf("claim: %+v %v", stale, err)
+	}
+
+	// El janitor lo libera y otro worker lo toma
+	gdb.Model(&models.GenerationJob{}).Where("id = ?", created.ID).Update("locked_at", time.Now().Add(-time.Hour))
+	if err := s.RecoverStale(10*time.Minute, false); err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	current, err := s.claimNext("worker-b")
+	if err != nil || current == nil || current.ID != created.ID {
+		t.Fatalf("reclaim: %+v %v", current, err)
+	}
+
+	s.fail(stale, "worker-a", errors.New("late failure"))
+	if s.finish(stale, "worker-a", map[string]interface{}{"estado": models.GenerationJobEstadoCompletado}) {
+		t.Error("stale worker finished a job it no longer holds")
+	}
+
+	job := reloadGenerationJob(t, gdb, created.ID)
+	if job.Estado != models.GenerationJobEstadoEnProceso || job.LockedBy != "worker-b" || job.Intentos != 2 || job.ErrorMensaje != "" {
+		t.Errorf("stale worker overwrote the job: %+v", job)
+	}
+}
+
+func TestEnqueueLearningPlanConcurrent(t *testing.T) {
+	gdb := dbtest.Open(t)
+	user := dbtest.CreateUser(t, gdb)
+	s := NewGenerationJobService()
+
+	const requests = 8
+	var wg sync.WaitGroup
+	jobs := make([]*models.GenerationJob, requests)
+	errs := make([]error, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			jobs[i], _, errs[i] = s.EnqueueLearningPlan(user.ID, 1)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range jobs {
+		if errs[i] != nil {
+			t.Fatalf("request %d: %v", i, errs[i])
+		}
+		if jobs[i].ID != jobs[0].ID {
+			t.Errorf("request %d got job %d, want %d", i, jobs[i].ID, jobs[0].ID)
+		}
+	}
+	var count int64
+	gdb.Model(&models.GenerationJob{}).Where("user_id = ?", user.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("%d jobs enqueued, want 1", count)
+	}
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"gorm.io/gorm"
+)
+
+// guidedPracticeMaxItems es la cantidad máxima de preguntas del banco en un GuidedPracticeQuiz
+const guidedPracticeMaxItems = 5
+
+// guidedPracticeMinItems es el mínimo de preguntas para ofrecer GuidedPracticeQuiz en la estructura
+const guidedPracticeMinItems = 2
+
+// guidedPracticeItem es una pregunta real del banco usada en un GuidedPracticeQuiz
+type guidedPracticeItem struct {
+	QuestionID        uint              `json:"question_id"`
+	Pregunta          string            `json:"pregunta"`
+	Opciones          map[string]string `json:"opciones"`
+	RespuestaCorrecta string            `json:"respuesta_correcta"`
+	Explicacion       string            `json:"explicacion,omitempty"`
+	Dificultad        int               `json:"dificultad"`
+}
+
+// Preguntas del banco que un GuidedPracticeQuiz puede mostrar junto con su respuesta
+const guidedPracticeTipo = "multiple_choice"
+
+var guidedPracticeTiposUso = []string{"practica", "all"}
+
+// guidedPracticeScope filtra las preguntas activas de selección múltiple aptas para práctica
+func guidedPracticeScope(oaBloomObjectiveID uint) *gorm.DB {
+	return db.DB.Model(&models.Question{}).
+		Where("oa_bloom_objective_id = ? AND activa = true AND tipo = ? AND tipo_uso IN ?",
+			oaBloomObjectiveID, guidedPracticeTipo, guidedPracticeTiposUso)
+}
+
+// countGuidedPracticeItems cuenta las preguntas del banco disponibles para práctica guiada
+func countGuidedPracticeItems(oaBloomObjectiveID uint) int {
+	var count int64
+	guidedPracticeScope(oaBloomObjectiveID).Count(&count)
+	return int(count)
+}
+
+// loadGuidedPracticeItems carga las preguntas del banco para un GuidedPracticeQuiz, de menor a
+// mayor dificultad. El orden es determinista para que el prompt (y su cassette) sea estable.
+func loadGuidedPracticeItems(oaBloomObjectiveID uint) ([]guidedPracticeItem, error) {
+	var questions []models.Question
+	err := guidedPracticeScope(oaBloomObjectiveID).
+		Order("veces_usada ASC, id ASC").
+		Limit(guidedPracticeMaxItems).
+		Find(&questions).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(questions) < guidedPracticeMinItems {
+		return nil, fmt.Errorf("not enough practice questions for objective %d", oaBloomObjectiveID)
+	}
+
+	items := make([]guidedPracticeItem, 0, len(questions))
+	for _, q := range questions {
+		item, err := toGuidedPracticeItem(q)
+		if err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+	if len(items) < guidedPracticeMinItems {
+		return nil, fmt.Errorf("not enough valid practice questions for objective %d", oaBloomObjectiveID)
+	}
+
+	// Presentar de menor a mayor dificultad
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Dificultad < items[j].Dificultad
+	})
+
+	return items, nil
+}
+
+func toGuidedPracticeItem(q models.Question) (guidedPracticeItem, error) {
+	var data struct {
+		Pregunta    string            `json:"pregunta"`
+		Opciones    map[string]string `json:"opciones"`
+		Explicacion string            `json:"explicacion"`
+	}
+	if err := json.Unmarshal(q.QuestionData, &data); err != nil {
+		return guidedPracticeItem{}, err
+	}
+
+	var validation struct {
+		RespuestaCorrecta string `json:"respuesta_correcta"`
+	}
+	if err := json.Unmarshal(q.ValidationData, &validation); err != nil {
+		return guidedPracticeItem{}, err
+	}
+
+	if data.Pregunta == "" || len(data.Opciones) < 2 || validation.RespuestaCorrecta == "" {
+		return guidedPracticeItem{}, fmt.Errorf("question %d is incomplete", q.ID)
+	}
+
+	return guidedPracticeItem{
+		QuestionID:        q.ID,
+		Pregunta:          data.Pregunta,
+		Opciones:          data.Opciones,
+		RespuestaCorrecta: validation.RespuestaCorrecta,
+		Explicacion:       data.Explicacion,
+		Dificultad:        q.DificultadRelativa,
+	}, nil
+}
+
+// attachGuidedPracticeItems reemplaza las referencias question_id que devolvió el modelo por las
+// preguntas reales del banco, conservando la pista y la estrategia generadas
+func attachGuidedPracticeItems(oaBloomObjectiveID uint, content map[string]interface{}) error {
+	preguntas, ok := content["preguntas"].([]interface{})
+	if !ok || len(preguntas) == 0 {
+		return fmt.Errorf("missing required field: preguntas")
+	}
+
+	items, err := loadGuidedPracticeItems(oaBloomObjectiveID)
+	if err != nil {
+		return err
+	}
+	byID := make(map[uint]guidedPracticeItem, len(items))
+	for _, item := range items {
+		byID[item.QuestionID] = item
+	}
+
+	seen := make(map[uint]bool)
+	for i, p := range preguntas {
+		pregunta, ok := p.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("pregunta at index %d is not an object", i)
+		}
+
+		id, ok := pregunta["question_id"].(float64)
+		if !ok {
+			return fmt.Errorf("pregunta at index %d missing 'question_id'", i)
+		}
+		item, ok := byID[uint(id)]
+		if !ok {
+			return fmt.Errorf("pregunta at index %d references unknown question %d", i, uint(id))
+		}
+		if seen[item.QuestionID] {
+			return fmt.Errorf("question %d is repeated", item.QuestionID)
+		}
+		seen[item.QuestionID] = true
+
+		pregunta["question_id"] = item.QuestionID
+		pregunta["pregunta"] = item.Pregunta
+		pregunta["opciones"] = item.Opciones
+		pregunta["respuesta_correcta"] = item.RespuestaCorrecta
+		if item.Explicacion != "" {
+			pregunta["explicacion"] = item.Explicacion
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"github.com/platanus-hack-25/lumera_app/pkg/llm"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrNoPendingQuestion: la sesión no tiene una pregunta servida sin responder
+	ErrNoPendingQuestion = errors.New("no pending question")
+	// ErrNoMoreHints: ya se revelaron todas las pistas de la pregunta
+	ErrNoMoreHints = errors.New("no more hints")
+	// ErrHintsUnavailable: la pregunta no tiene pistas de autor y no se pudieron generar
+	ErrHintsUnavailable = errors.New("hints unavailable")
+)
+
+// Fuentes de las pistas
+const (
+	HintFuenteAutor    = "autor"
+	HintFuenteGenerada = "generada"
+)
+
+// PracticeHint es la siguiente pista de la pregunta pendiente de una sesión de práctica
+type PracticeHint struct {
+	QuestionID    uint     `json:"question_id"`
+	Orden         int      `json:"orden"` // 1 = la más general
+	Pista         string   `json:"pista"`
+	Pistas        []string `json:"pistas"` // todas las reveladas hasta ahora, en orden
+	PistasUsadas  int      `json:"pistas_usadas"`
+	PistasTotales int      `json:"pistas_totales"`
+	Fuente        string   `json:"fuente"`         // autor, generada
+	PuntajeMaximo float64  `json:"puntaje_maximo"` // fracción del puntaje que aún vale la respuesta
+}
+
+type hintConfig struct {
+	PenalizacionPercent int // puntaje descontado por cada pista revelada
+	MinimoPercent       int // puntaje mínimo de una respuesta correcta con pistas
+	Generadas           int // pistas a generar para preguntas sin pistas de autor
+}
+
+func loadHintConfig() hintConfig {
+	return hintConfig{
+		PenalizacionPercent: clampInt(getEnvInt("HINT_SCORE_PENALTY_PERCENT", 25), 0, 100),
+		MinimoPercent:       clampInt(getEnvInt("HINT_MIN_SCORE_PERCENT", 25), 0, 100),
+		Generadas:           clampInt(getEnvInt("HINT_GENERATED_COUNT", 3), 1, 5),
+	}
+}
+
+// HintWeight es la fracción del puntaje y la XP que conserva una respuesta tras revelar pistasUsadas pistas:
+// HINT_SCORE_PENALTY_PERCENT (25%) menos por pista, sin bajar de HINT_MIN_SCORE_PERCENT (25%)
+func HintWeight(pistasUsadas int) float64 {
+	if pistasUsadas <= 0 {
+		return 1
+	}
+	config := loadHintConfig()
+	percent := 100 - pistasUsadas*config.PenalizacionPercent
+	if percent < config.MinimoPercent {
+		percent = config.MinimoPercent
+	}
+	return float64(percent) / 100
+}
+
+// HintEvidenceWeight es el peso de una respuesta como evidencia de dominio: un acierto con pistas
+// demuestra menos que uno sin ayuda, pero un error con pistas es evidencia completa
+func HintEvidenceWeight(pistasUsadas int, correct bool) float64 {
+	if !correct {
+		return 1
+	}
+	return HintWeight(pistasUsadas)
+}
+
+// NextPracticeHint revela la siguiente pista de la pregunta pendiente de la sesión. Las pistas vienen de
+// validation_data.pistas o, si la pregunta no tiene, se generan con el LLM la primera vez y quedan en caché.
+func NextPracticeHint(ctx context.Context, userID, sessionID uint) (*PracticeHint, error) {
+	var session models.PracticeSession
+	if err := db.DB.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+	if err := CheckSessionActive(session.Estado); err != nil {
+		return nil, err
+	}
+	if session.PreguntaPendienteID == nil {
+		return nil, ErrNoPendingQuestion
+	}
+
+	var question models.Question
+	if err := db.DB.First(&question, *session.PreguntaPendienteID).Error; err != nil {
+		return nil, err
+	}
+
+	// Las pistas se obtienen fuera de la transacción: generarlas puede tardar
+	pistas, fuente, err := questionHints(ctx, userID, &question)
+	if err != nil {
+		return nil, err
+	}
+
+	var usadas int
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var locked models.PracticeSession
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, session.ID).Error; err != nil {
+			return err
+		}
+		if err := CheckSessionActive(locked.Estado); err != nil {
+			return err
+		}
+		// La pregunta pudo responderse o cambiar mientras se generaban las pistas
+		if locked.PreguntaPendienteID == nil || *locked.PreguntaPendienteID != question.ID {
+			return ErrNoPendingQuestion
+		}
+		if locked.PistasUsadas >= len(pistas) {
+			return ErrNoMoreHints
+		}
+		usadas = locked.PistasUsadas + 1
+		return tx.Model(&locked).Updates(map[string]interface{}{
+			"pistas_usadas":       usadas,
+			"ultima_actividad_at": time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &PracticeHint{
+		QuestionID:    question.ID,
+		Orden:         usadas,
+		Pista:         pistas[usadas-1],
+		Pistas:        pistas[:usadas],
+		PistasUsadas:  usadas,
+		PistasTotales: len(pistas),
+		Fuente:        fuente,
+		PuntajeMaximo: HintWeight(usadas),
+	}, nil
+}
+
+// ClearGeneratedHints borra las pistas generadas en caché de una pregunta (al editarla dejan de calzar)
+func ClearGeneratedHints(questionID uint) error {
+	return db.DB.Where("question_id = ?", questionID).Delete(&models.QuestionHint{}).Error
+}
+
+// questionHints devuelve las pistas de la pregunta, de la más general a la más específica, y su fuente
+func questionHints(ctx context.Context, userID uint, question *models.Question) ([]string, string, error) {
+	if pistas := authoredHints(question); len(pistas) > 0 {
+		return pistas, HintFuenteAutor, nil
+	}
+
+	pistas, err := cachedHints(question.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(pistas) > 0 {
+		return pistas, HintFuenteGenerada, nil
+	}
+
+	if err := generateHints(ctx, userID, question); err != nil {
+		return nil, "", err
+	}
+	// Se relee la caché: si otra petición generó las pistas a la vez, gana la primera
+	pistas, err = cachedHints(question.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(pistas) == 0 {
+		return nil, "", ErrHintsUnavailable
+	}
+	return pistas, HintFuenteGenerada, nil
+}
+
+// authoredHints lee validation_data.pistas
+func authoredHints(question *models.Question) []string {
+	var validation struct {
+		Pistas []string `json:"pistas"`
+	}
+	if err := json.Unmarshal(question.ValidationData, &validation); err != nil {
+		return nil
+	}
+	return validation.Pistas
+}
+
+func cachedHints(questionID uint) ([]string, error) {
+	var hints []models.QuestionHint
+	if err := db.DB.Where("question_id = ?", questionID).Order("orden").Find(&hints).Error; err != nil {
+		return nil, err
+	}
+	pistas := make([]string, 0, len(hints))
+	for _, hint := range hints {
+		pistas = append(pistas, hint.Texto)
+	}
+	return pistas, nil
+}
+
+// generateHints escribe las pistas de una pregunta sin pistas de autor con el LLM y las guarda en caché
+func generateHints(ctx context.Context, userID uint, question *models.Question) error {
+	if llmClient == nil {
+		return ErrHintsUnavailable
+	}
+	if err := NewLLMUsageService().CheckBudget(userID); err != nil {
+		if errors.Is(err, ErrLLMBudgetExceeded) {
+			return err
+		}
+		// Los problemas de contabilidad no bloquean el aprendizaje
+		log.Printf("⚠ Could not check LLM budget for user %d: %v", userID, err)
+	}
+
+	oaContext, err := BuildOAContext(userID, question.OABloomObjectiveID)
+	if err != nil {
+		return err
+	}
+
+	// La respuesta va sin pistas ni conceptos erróneos: solo orienta al LLM
+	var validation map[string]interface{}
+	json.Unmarshal(question.ValidationData, &validation)
+	delete(validation, "pistas")
+	delete(validation, misconceptionTagsKey)
+	preguntaJSON, _ := json.Marshal(map[string]interface{}{
+		"tipo":      question.Tipo,
+		"pregunta":  question.QuestionData,
+		"respuesta": validation,
+	})
+
+	config := loadHintConfig()
+	prompt, version, err := renderPrompt(models.PromptQuestionHints, userID, PromptData{
+		OAContext:      *oaContext,
+		Pregunta:       string(preguntaJSON),
+		CantidadPistas: config.Generadas,
+	})
+	if err != nil {
+		return err
+	}
+
+	timeout := getEnvInt("OPENAI_TIMEOUT_SECONDS", 45)
+	callCtx, cancel := context.WithTimeout(llm.WithFeature(ctx, llm.FeatureQuestionHints), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	resp, err := llmClient.Chat(callCtx, llm.ChatRequest{
+		Model: getEnvString("OPENAI_MODEL", "gpt-4o-mini"),
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: "Eres un tutor paciente. Guías al estudiante hacia la respuesta sin revelarla."},
+			{Role: llm.RoleUser, Content: prompt},
+		},
+		Temperature: 0.4,
+		MaxTokens:   600,
+	})
+	if err != nil {
+		log.Printf("⚠ Hint generation failed for question %d: %v", question.ID, err)
+		return ErrHintsUnavailable
+	}
+
+	var result struct {
+		Pistas []string `json:"pistas"`
+	}
+	if err := json.Unmarshal([]byte(cleanMarkdownJSON(resp.Content)), &result); err != nil {
+		log.Printf("⚠ Invalid hint response for question %d: %v", question.ID, err)
+		return ErrHintsUnavailable
+	}
+	var pistas []string
+	for _, pista := range result.Pistas {
+		if pista = strings.TrimSpace(pista); pista != "" && len(pistas) < config.Generadas {
+			pistas = append(pistas, pista)
+		}
+	}
+	if len(pistas) == 0 {
+		log.Printf("⚠ Empty hint response for question %d", question.ID)
+		return ErrHintsUnavailable
+	}
+	flagged, err := classifyText(ctx, strings.Join(pistas, "\n"))
+	if err != nil {
+		log.Printf("⚠ Generated hints for question %d not moderated: %v", question.ID, err)
+		return ErrHintsUnavailable
+	}
+	if flagged != nil {
+		log.Printf("⚠ Generated hints for question %d flagged by moderation: %s", question.ID, flagged.Reason)
+		return ErrHintsUnavailable
+	}
+
+	hints := make([]models.QuestionHint, 0, len(pistas))
+	for i, pista := range pistas {
+		hints = append(hints, models.QuestionHint{
+			QuestionID:    question.ID,
+			Orden:         i + 1,
+			Texto:         pista,
+			PromptVersion: &version,
+		})
+	}
+	return db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&hints).Error
+}
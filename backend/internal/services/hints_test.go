@@ -0,0 +1,44 @@
+package services
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHintWeight(t *testing.T) {
+	tests := []struct {
+		name    string
+		penalty string
+		minimum string
+		pistas  int
+		want    float64
+	}{
+		{"no hints", "", "", 0, 1},
+		{"negative count", "", "", -1, 1},
+		{"one hint", "", "", 1, 0.75},
+		{"two hints", "", "", 2, 0.5},
+		{"floor", "", "", 4, 0.25},
+		{"custom penalty", "10", "", 3, 0.7},
+		{"custom floor", "40", "50", 2, 0.5},
+		{"no penalty", "0", "", 3, 1},
+		{"penalty clamped to 100", "250", "0", 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HINT_SCORE_PENALTY_PERCENT", tt.penalty)
+			t.Setenv("HINT_MIN_SCORE_PERCENT", tt.minimum)
+
+			if got := HintWeight(tt.pistas); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("HintWeight(%d) = %v, want %v", tt.pistas, got, tt.want)
+			}
+			// A correct answer after hints is weaker evidence of mastery, like its score
+			if got := HintEvidenceWeight(tt.pistas, true); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("HintEvidenceWeight(%d, true) = %v, want %v", tt.pistas, got, tt.want)
+			}
+			// A wrong answer after hints is full evidence of not knowing
+			if got := HintEvidenceWeight(tt.pistas, false); got != 1 {
+				t.Errorf("HintEvidenceWeight(%d, false) = %v, want 1", tt.pistas, got)
+			}
+		})
+	}
+}
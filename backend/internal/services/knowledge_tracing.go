@@ -0,0 +1,649 @@
+package services
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// bktParams son los parámetros de Bayesian Knowledge Tracing de un objetivo
+type bktParams struct {
+	inicial     float64 // P(L0): ya lo sabía antes de la primera respuesta
+	aprendizaje float64 // P(T): lo aprende después de cada respuesta
+	adivinar    float64 // P(G): responde bien sin saberlo
+	desliz      float64 // P(S): responde mal sabiéndolo
+}
+
+// defaultBKTParams se usa mientras no hay parámetros ajustados
+var defaultBKTParams = bktParams{inicial: 0.3, aprendizaje: 0.1, adivinar: 0.2, desliz: 0.1}
+
+// Valores candidatos de cada parámetro en el ajuste. Adivinar y desliz se acotan bajo 0.5 para que el modelo
+// no degenere (un "dominio" que predice respuestas malas).
+var (
+	bktInicialGrid     = []float64{0.05, 0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9}
+	bktAprendizajeGrid = []float64{0.01, 0.03, 0.05, 0.08, 0.1, 0.15, 0.2, 0.3, 0.4}
+	bktAdivinarGrid    = []float64{0.05, 0.1, 0.15, 0.2, 0.25, 0.3, 0.35}
+	bktDeslizGrid      = []float64{0.02, 0.05, 0.08, 0.1, 0.15, 0.2, 0.3}
+)
+
+// bktObservation es una respuesta calificada con su peso (menor para las adivinanzas rápidas)
+type bktObservation struct {
+	correct bool
+	peso    float64
+}
+
+// KnowledgeTrace es el dominio estimado de un estudiante en un objetivo
+type KnowledgeTrace struct {
+	OABloomObjectiveID uint    `json:"oa_bloom_objective_id"`
+	PDominio           float64 `json:"p_dominio"`
+	Estado             string  `json:"estado"`
+	PorcentajeLogro    int     `json:"porcentaje_logro"`
+	Respuestas         int     `json:"respuestas"`
+	Correctas          int     `json:"correctas"`
+}
+
+// KnowledgeTracingFitReport resume un ajuste de parámetros
+type KnowledgeTracingFitReport struct {
+	Global              models.KnowledgeTracingParams   `json:"global"`
+	Objetivos           []models.KnowledgeTracingParams `json:"objetivos"`
+	Respuestas          int                             `json:"respuestas"`
+	Secuencias          int                             `json:"secuencias"` // pares estudiante-objetivo
+	MinRespuestas       int                             `json:"min_respuestas"`
+	EstadosActualizados int                             `json:"estados_actualizados"`
+	AjustadoAt          time.Time                       `json:"ajustado_at"`
+}
+
+// knowledgeTracingConfig son los umbrales de estado y la configuración del ajuste
+type knowledgeTracingConfig struct {
+	LogradoPercent      int
+	DominadoPercent     int
+	MinRespuestas       int
+	IntervaloHoras      int
+	LoteObjetivos       int // objetivos cuyas respuestas se cargan y ajustan a la vez
+	MaxRespuestasGlobal int // respuestas usadas para ajustar los parámetros globales
+}
+
+func loadKnowledgeTracingConfig() knowledgeTracingConfig {
+	return knowledgeTracingConfig{
+		LogradoPercent:      clampInt(getEnvInt("KNOWLEDGE_TRACING_LOGRADO_PERCENT", 80), 1, 100),
+		DominadoPercent:     clampInt(getEnvInt("KNOWLEDGE_TRACING_DOMINADO_PERCENT", 95), 1, 100),
+		MinRespuestas:       getEnvInt("KNOWLEDGE_TRACING_MIN_ANSWERS", 100),
+		IntervaloHoras:      getEnvInt("KNOWLEDGE_TRACING_FIT_HOURS", 24),
+		LoteObjetivos:       clampInt(getEnvInt("KNOWLEDGE_TRACING_FIT_BATCH", 50), 1, 1000),
+		MaxRespuestasGlobal: clampInt(getEnvInt("KNOWLEDGE_TRACING_GLOBAL_MAX_ANSWERS", 200000), 1000, 5000000),
+	}
+}
+
+// update aplica una respuesta al dominio: posterior bayesiano de la observación y luego la transición de
+// aprendizaje
+func (p bktParams) update(dominio float64, correct bool) float64 {
+	var posterior float64
+	if correct {
+		known := dominio * (1 - p.desliz)
+		posterior = known / (known + (1-dominio)*p.adivinar)
+	} else {
+		known := dominio * p.desliz
+		posterior = known / (known + (1-dominio)*(1-p.adivinar))
+	}
+	return clampProbability(posterior + (1-posterior)*p.aprendizaje)
+}
+
+// observe aplica una respuesta con peso: se avanza esa fracción del cambio que haría una respuesta completa
+func (p bktParams) observe(dominio float64, observation bktObservation) float64 {
+	if observation.peso >= 1 {
+		return p.update(dominio, observation.correct)
+	}
+	return dominio + observation.peso*(p.update(dominio, observation.correct)-dominio)
+}
+
+// predict es la probabilidad de responder bien con el dominio dado
+func (p bktParams) predict(dominio float64) float64 {
+	return dominio*(1-p.desliz) + (1-dominio)*p.adivinar
+}
+
+// logLikelihood es la log-verosimilitud ponderada de las secuencias de respuestas bajo los parámetros
+func (p bktParams) logLikelihood(sequences [][]bktObservation) float64 {
+	total := 0.0
+	for _, sequence := range sequences {
+		dominio := p.inicial
+		for _, observation := range sequence {
+			predicted := clampProbability(p.predict(dominio))
+			if observation.correct {
+				total += observation.peso * math.Log(predicted)
+			} else {
+				total += observation.peso * math.Log(1-predicted)
+			}
+			dominio = p.observe(dominio, observation)
+		}
+	}
+	return total
+}
+
+// fitBKTParams maximiza la log-verosimilitud por descenso coordenado sobre la grilla de cada parámetro, partiendo
+// de start. Cada ronda cuesta ~40 pasadas sobre las respuestas en vez de las miles de la grilla completa.
+func fitBKTParams(sequences [][]bktObservation, start bktParams) (bktParams, float64) {
+	best := start
+	bestLL := best.logLikelihood(sequences)
+
+	fields := []struct {
+		grid []float64
+		set  func(*bktParams, float64)
+	}{
+		{bktInicialGrid, func(p *bktParams, v float64) { p.inicial = v }},
+		{bktAprendizajeGrid, func(p *bktParams, v float64) { p.aprendizaje = v }},
+		{bktAdivinarGrid, func(p *bktParams, v float64) { p.adivinar = v }},
+		{bktDeslizGrid, func(p *bktParams, v float64) { p.desliz = v }},
+	}
+	for round := 0; round < 10; round++ {
+		improved := false
+		for _, field := range fields {
+			for _, value := range field.grid {
+				candidate := best
+				field.set(&candidate, value)
+				if candidate == best {
+					continue
+				}
+				if ll := candidate.logLikelihood(sequences); ll > bestLL+1e-9 {
+					best, bestLL = candidate, ll
+					improved = true
+				}
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+	return best, bestLL
+}
+
+// TraceAnswer actualiza el dominio del estudiante en el objetivo con una respuesta de diagnóstico o práctica,
+// y con él el estado y porcentaje de logro de StudentOAProgress. peso (0-1) es la fracción de la evidencia que
+// cuenta la respuesta (ver AnswerWeight y HintEvidenceWeight).
+func TraceAnswer(userID, objectiveID uint, correct bool, peso float64) (*KnowledgeTrace, error) {
+	params, err := loadBKTParams(objectiveID)
+	if err != nil {
+		return nil, err
+	}
+	config := loadKnowledgeTracingConfig()
+	now := time.Now()
+
+	var trace *KnowledgeTrace
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		seed := models.StudentKnowledgeState{UserID: userID, OABloomObjectiveID: objectiveID, PDominio: params.inicial}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&seed).Error; err != nil {
+			return err
+		}
+		var state models.StudentKnowledgeState
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND oa_bloom_objective_id = ?", userID, objectiveID).
+			First(&state).Error; err != nil {
+			return err
+		}
+
+		state.PDominio = params.observe(state.PDominio, bktObservation{correct: correct, peso: peso})
+		state.Respuestas++
+		if correct {
+			state.Correctas++
+		}
+		state.UltimaRespuestaAt = &now
+		if err := tx.Save(&state).Error; err != nil {
+			return err
+		}
+
+		trace = knowledgeTraceFromState(state, config)
+		return upsertTracedProgress(tx, userID, trace, now, true)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return trace, nil
+}
+
+// RecordTracedProgress cierra una sesión de diagnóstico o práctica en el progreso del estudiante: suma un intento
+// y registra el historial con el estado y porcentaje del dominio estimado (no del acierto de la sesión)
+func RecordTracedProgress(userID, objectiveID uint, tipoEvento string, correctas, totales int, notas string) error {
+	config := loadKnowledgeTracingConfig()
+	now := time.Now()
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		var states []models.StudentKnowledgeState
+		if err := tx.Where("user_id = ? AND oa_bloom_objective_id = ?", userID, objectiveID).Limit(1).Find(&states).Error; err != nil {
+			return err
+		}
+		if len(states) > 0 {
+			if err := upsertTracedProgress(tx, userID, knowledgeTraceFromState(states[0], config), now, true); err != nil {
+				return err
+			}
+		} else {
+			// Sin respuestas trazadas en este objetivo (la sesión respondió otros niveles): se conserva el progreso
+			progress := models.StudentOAProgress{UserID: userID, OABloomObjectiveID: objectiveID, Estado: "en_proceso"}
+			if err := tx.Omit("User", "OABloomObjective").Clauses(clause.OnConflict{DoNothing: true}).Create(&progress).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&models.StudentOAProgress{}).
+			Where("user_id = ? AND oa_bloom_objective_id = ?", userID, objectiveID).
+			Updates(map[string]interface{}{
+				"intentos":               gorm.Expr("COALESCE(intentos, 0) + 1"),
+				"ultima_actividad_fecha": now,
+			}).Error; err != nil {
+			return err
+		}
+		var progress models.StudentOAProgress
+		if err := tx.Where("user_id = ? AND oa_bloom_objective_id = ?", userID, objectiveID).First(&progress).Error; err != nil {
+			return err
+		}
+
+		porcentaje := progress.PorcentajeLogro
+		puntajeObtenido := float64(correctas)
+		puntajeMaximo := float64(totales)
+		history := models.StudentOAHistory{
+			UserID:             userID,
+			OABloomObjectiveID: objectiveID,
+			Estado:             progress.Estado,
+			PorcentajeLogro:    &porcentaje,
+			TipoEvento:         tipoEvento,
+			PuntajeObtenido:    &puntajeObtenido,
+			PuntajeMaximo:      &puntajeMaximo,
+			Notas:              notas,
+		}
+		return tx.Create(&history).Error
+	})
+}
+
+// GetKnowledgeTraces retorna el dominio estimado del estudiante en los objetivos con respuestas
+func GetKnowledgeTraces(userID uint) ([]KnowledgeTrace, error) {
+	var states []models.StudentKnowledgeState
+	if err := db.DB.Where("user_id = ?", userID).Order("oa_bloom_objective_id").Find(&states).Error; err != nil {
+		return nil, err
+	}
+	config := loadKnowledgeTracingConfig()
+	traces := make([]KnowledgeTrace, len(states))
+	for i, state := range states {
+		traces[i] = *knowledgeTraceFromState(state, config)
+	}
+	return traces, nil
+}
+
+// ListKnowledgeTracingParams retorna los parámetros ajustados (el global primero)
+func ListKnowledgeTracingParams() ([]models.KnowledgeTracingParams, error) {
+	var params []models.KnowledgeTracingParams
+	err := db.DB.Order("oa_bloom_objective_id NULLS FIRST").Find(&params).Error
+	return params, err
+}
+
+// FitKnowledgeTracing ajusta los parámetros con las respuestas de diagnóstico y práctica: uno global y uno por
+// objetivo con al menos KNOWLEDGE_TRACING_MIN_ANSWERS respuestas (100). Los objetivos se procesan en lotes de
+// KNOWLEDGE_TRACING_FIT_BATCH (50) para no cargar todas las respuestas a la vez: se ajustan sus parámetros y se
+// recalcula el dominio de cada estudiante repitiendo su historial, y se sincroniza StudentOAProgress. El global
+// se ajusta con las respuestas de los primeros lotes hasta KNOWLEDGE_TRACING_GLOBAL_MAX_ANSWERS (200000).
+func FitKnowledgeTracing() (*KnowledgeTracingFitReport, error) {
+	config := loadKnowledgeTracingConfig()
+	objectives, total, err := answeredObjectives()
+	if err != nil {
+		return nil, err
+	}
+
+	// Postgres guarda microsegundos: así ajustado_at se puede comparar con now al limpiar
+	now := time.Now().Truncate(time.Microsecond)
+	report := &KnowledgeTracingFitReport{Respuestas: total, MinRespuestas: config.MinRespuestas, AjustadoAt: now}
+
+	var batches [][]uint
+	for start := 0; start < len(objectives); start += config.LoteObjetivos {
+		batches = append(batches, objectives[start:min(start+config.LoteObjetivos, len(objectives))])
+	}
+
+	var sample [][]bktObservation
+	sampled := 0
+	for _, batch := range batches {
+		if sampled >= config.MaxRespuestasGlobal {
+			break
+		}
+		histories, err := loadAnswerHistories(batch)
+		if err != nil {
+			return nil, err
+		}
+		for _, history := range histories {
+			sample = append(sample, history.respuestas)
+			sampled += len(history.respuestas)
+		}
+	}
+
+	global, globalLL := defaultBKTParams, 0.0
+	if sampled > 0 {
+		global, globalLL = fitBKTParams(sample, defaultBKTParams)
+	}
+	report.Global = knowledgeTracingParamsRow(nil, global, sampled, len(sample), globalLL, now)
+	sample = nil
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("oa_bloom_objective_id IS NULL").Delete(&models.KnowledgeTracingParams{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&report.Global).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, batch := range batches {
+		if err := fitKnowledgeTracingBatch(batch, global, config, report); err != nil {
+			return nil, err
+		}
+	}
+
+	// Objetivos que ya no tienen respuestas suficientes vuelven a usar el global
+	if err := db.DB.Where("oa_bloom_objective_id IS NOT NULL AND ajustado_at < ?", now).
+		Delete(&models.KnowledgeTracingParams{}).Error; err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// fitKnowledgeTracingBatch ajusta los parámetros de un lote de objetivos y recalcula el dominio de sus estudiantes
+func fitKnowledgeTracingBatch(objectives []uint, global bktParams, config knowledgeTracingConfig, report *KnowledgeTracingFitReport) error {
+	histories, err := loadAnswerHistories(objectives)
+	if err != nil {
+		return err
+	}
+
+	byObjective := map[uint][][]bktObservation{}
+	for key, history := range histories {
+		byObjective[key.objectiveID] = append(byObjective[key.objectiveID], history.respuestas)
+	}
+	report.Secuencias += len(histories)
+
+	fitted := map[uint]bktParams{}
+	var rows []models.KnowledgeTracingParams
+	for _, objectiveID := range objectives {
+		sequences := byObjective[objectiveID]
+		answers := 0
+		for _, sequence := range sequences {
+			answers += len(sequence)
+		}
+		if answers < config.MinRespuestas {
+			continue
+		}
+		params, ll := fitBKTParams(sequences, global)
+		fitted[objectiveID] = params
+		id := objectiveID
+		rows = append(rows, knowledgeTracingParamsRow(&id, params, answers, len(sequences), ll, report.AjustadoAt))
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("oa_bloom_objective_id IN ?", objectives).Delete(&models.KnowledgeTracingParams{}).Error; err != nil {
+			return err
+		}
+		if len(rows) > 0 {
+			return tx.Create(&rows).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	report.Objetivos = append(report.Objetivos, rows...)
+
+	for key, history := range histories {
+		params, ok := fitted[key.objectiveID]
+		if !ok {
+			params = global
+		}
+		ultima := history.ultima
+		state := models.StudentKnowledgeState{UserID: key.userID, OABloomObjectiveID: key.objectiveID, PDominio: params.inicial, UltimaRespuestaAt: &ultima}
+		for _, observation := range history.respuestas {
+			state.PDominio = params.observe(state.PDominio, observation)
+			state.Respuestas++
+			if observation.correct {
+				state.Correctas++
+			}
+		}
+		replaced, err := replaceKnowledgeState(state, config)
+		if err != nil {
+			return err
+		}
+		if replaced {
+			report.EstadosActualizados++
+		}
+	}
+	return nil
+}
+
+// StartKnowledgeTracingFitter reajusta periódicamente los parámetros (KNOWLEDGE_TRACING_FIT_HOURS, 24; 0 lo
+// desactiva) hasta que ctx se cancela
+func StartKnowledgeTracingFitter(ctx context.Context) {
+	interval := time.Duration(loadKnowledgeTracingConfig().IntervaloHoras) * time.Hour
+	if interval <= 0 {
+		log.Println("Knowledge tracing fitter disabled")
+		return
+	}
+
+	fit := func() {
+		report, err := FitKnowledgeTracing()
+		if err != nil {
+			log.Printf("⚠ Failed to fit knowledge tracing: %v", err)
+			return
+		}
+		log.Printf("✓ Knowledge tracing fit on %d answers (%d objectives with own parameters)", report.Respuestas, len(report.Objetivos))
+	}
+
+	go func() {
+		fit()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				fit()
+			}
+		}
+	}()
+}
+
+type answerHistoryKey struct {
+	userID      uint
+	objectiveID uint
+}
+
+// answerHistory son las respuestas de un estudiante en un objetivo y la fecha de la última
+type answerHistory struct {
+	respuestas []bktObservation
+	ultima     time.Time
+}
+
+// gradedAnswersSQL son las respuestas calificadas de diagnóstico y práctica. En práctica el objetivo es el de la
+// pregunta, que puede ser otro nivel Bloom del OA.
+const gradedAnswersSQL = `
+	SELECT ps.user_id, q.oa_bloom_objective_id, pa.is_correct, pa.adivinanza_rapida, pa.pistas_usadas, pa.created_at, pa.id
+	FROM practice_answers pa
+	JOIN practice_sessions ps ON ps.id = pa.session_id
+	JOIN questions q ON q.id = pa.question_id
+	WHERE pa.is_correct IS NOT NULL
+	UNION ALL
+	SELECT ds.user_id, da.oa_bloom_objective_id, da.is_correct, da.adivinanza_rapida, 0, da.created_at, da.id
+	FROM diagnostic_answers da
+	JOIN diagnostic_sessions ds ON ds.id = da.session_id
+	WHERE da.is_correct IS NOT NULL`
+
+// answeredObjectives retorna los objetivos con respuestas calificadas, en orden, y el total de respuestas
+func answeredObjectives() ([]uint, int, error) {
+	var counts []struct {
+		OABloomObjectiveID uint
+		Respuestas         int
+	}
+	err := db.DB.Raw(`SELECT oa_bloom_objective_id, COUNT(*) AS respuestas FROM (` + gradedAnswersSQL + `) respuestas
+		GROUP BY oa_bloom_objective_id ORDER BY oa_bloom_objective_id`).Scan(&counts).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	objectives := make([]uint, len(counts))
+	total := 0
+	for i, count := range counts {
+		objectives[i] = count.OABloomObjectiveID
+		total += count.Respuestas
+	}
+	return objectives, total, nil
+}
+
+// loadAnswerHistories retorna las respuestas calificadas en los objetivos por estudiante y objetivo, en orden
+// cronológico
+func loadAnswerHistories(objectives []uint) (map[answerHistoryKey]*answerHistory, error) {
+	rows, err := db.DB.Raw(`SELECT user_id, oa_bloom_objective_id, is_correct, adivinanza_rapida, pistas_usadas, created_at
+		FROM (`+gradedAnswersSQL+`) respuestas
+		WHERE oa_bloom_objective_id IN ?
+		ORDER BY created_at, id`, objectives).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	histories := map[answerHistoryKey]*answerHistory{}
+	for rows.Next() {
+		var key answerHistoryKey
+		var correct, rapid bool
+		var pistas int
+		var at time.Time
+		if err := rows.Scan(&key.userID, &key.objectiveID, &correct, &rapid, &pistas, &at); err != nil {
+			return nil, err
+		}
+		history, ok := histories[key]
+		if !ok {
+			history = &answerHistory{}
+			histories[key] = history
+		}
+		history.respuestas = append(history.respuestas, bktObservation{correct: correct, peso: AnswerWeight(rapid) * HintEvidenceWeight(pistas, correct)})
+		history.ultima = at
+	}
+	return histories, rows.Err()
+}
+
+// loadBKTParams retorna los parámetros del objetivo, los globales si el objetivo no tiene suficientes respuestas,
+// o los de partida si nunca se ha ajustado
+func loadBKTParams(objectiveID uint) (bktParams, error) {
+	var rows []models.KnowledgeTracingParams
+	err := db.DB.Where("oa_bloom_objective_id = ? OR oa_bloom_objective_id IS NULL", objectiveID).
+		Order("oa_bloom_objective_id NULLS LAST").
+		Limit(1).
+		Find(&rows).Error
+	if err != nil {
+		return bktParams{}, err
+	}
+	if len(rows) == 0 {
+		return defaultBKTParams, nil
+	}
+	row := rows[0]
+	return bktParams{inicial: row.PInicial, aprendizaje: row.PAprendizaje, adivinar: row.PAdivinar, desliz: row.PDesliz}, nil
+}
+
+// replaceKnowledgeState guarda el dominio recalculado y sincroniza el progreso sin tocar su última actividad.
+// Toma el mismo bloqueo de fila que TraceAnswer: si el estado ya cuenta más respuestas que el historial (llegaron
+// durante el ajuste) se deja como está y el próximo ajuste lo recalcula. Retorna si lo reemplazó.
+func replaceKnowledgeState(state models.StudentKnowledgeState, config knowledgeTracingConfig) (bool, error) {
+	replaced := false
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		created := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&state)
+		if created.Error != nil {
+			return created.Error
+		}
+		if created.RowsAffected == 0 {
+			var current models.StudentKnowledgeState
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("user_id = ? AND oa_bloom_objective_id = ?", state.UserID, state.OABloomObjectiveID).
+				First(&current).Error; err != nil {
+				return err
+			}
+			if current.Respuestas > state.Respuestas {
+				return nil
+			}
+			if err := tx.Model(&current).Updates(map[string]interface{}{
+				"p_dominio":           state.PDominio,
+				"respuestas":          state.Respuestas,
+				"correctas":           state.Correctas,
+				"ultima_respuesta_at": state.UltimaRespuestaAt,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		replaced = true
+		return upsertTracedProgress(tx, state.UserID, knowledgeTraceFromState(state, config), *state.UltimaRespuestaAt, false)
+	})
+	return replaced, err
+}
+
+// upsertTracedProgress escribe el estado y porcentaje del dominio en StudentOAProgress. at es la última actividad
+// del registro nuevo; en uno existente solo se actualiza si touch.
+func upsertTracedProgress(tx *gorm.DB, userID uint, trace *KnowledgeTrace, at time.Time, touch bool) error {
+	progress := models.StudentOAProgress{
+		UserID:               userID,
+		OABloomObjectiveID:   trace.OABloomObjectiveID,
+		Estado:               trace.Estado,
+		PorcentajeLogro:      trace.PorcentajeLogro,
+		UltimaActividadFecha: &at,
+	}
+	columns := []string{"estado", "porcentaje_logro", "updated_at"}
+	if touch {
+		columns = append(columns, "ultima_actividad_fecha")
+	}
+	return tx.Omit("User", "OABloomObjective").Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "oa_bloom_objective_id"}},
+		DoUpdates: clause.AssignmentColumns(columns),
+	}).Create(&progress).Error
+}
+
+func knowledgeTraceFromState(state models.StudentKnowledgeState, config knowledgeTracingConfig) *KnowledgeTrace {
+	trace := &KnowledgeTrace{
+		OABloomObjectiveID: state.OABloomObjectiveID,
+		PDominio:           math.Round(state.PDominio*10000) / 10000,
+		Respuestas:         state.Respuestas,
+		Correctas:          state.Correctas,
+	}
+	trace.Estado, trace.PorcentajeLogro = knowledgeEstado(state.PDominio, state.Respuestas, config)
+	return trace
+}
+
+// knowledgeEstado traduce el dominio a estado de progreso: dominado desde KNOWLEDGE_TRACING_DOMINADO_PERCENT (95),
+// logrado desde KNOWLEDGE_TRACING_LOGRADO_PERCENT (80) y si no en_proceso (no_iniciado sin respuestas)
+func knowledgeEstado(dominio float64, respuestas int, config knowledgeTracingConfig) (string, int) {
+	porcentaje := clampInt(int(math.Round(dominio*100)), 0, 100)
+	switch {
+	case respuestas == 0:
+		return "no_iniciado", porcentaje
+	case porcentaje >= config.DominadoPercent:
+		return "dominado", porcentaje
+	case porcentaje >= config.LogradoPercent:
+		return "logrado", porcentaje
+	default:
+		return "en_proceso", porcentaje
+	}
+}
+
+func knowledgeTracingParamsRow(objectiveID *uint, params bktParams, answers, students int, ll float64, now time.Time) models.KnowledgeTracingParams {
+	row := models.KnowledgeTracingParams{
+		OABloomObjectiveID: objectiveID,
+		PInicial:           params.inicial,
+		PAprendizaje:       params.aprendizaje,
+		PAdivinar:          params.adivinar,
+		PDesliz:            params.desliz,
+		Respuestas:         answers,
+		Estudiantes:        students,
+		AjustadoAt:         now,
+	}
+	if answers > 0 {
+		rounded := math.Round(ll*10000) / 10000
+		row.LogVerosimilitud = &rounded
+	}
+	return row
+}
+
+func clampProbability(p float64) float64 {
+	return math.Min(math.Max(p, 0.0001), 0.9999)
+}
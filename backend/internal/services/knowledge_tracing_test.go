@@ -0,0 +1,144 @@
+package services
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestBKTUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		dominio float64
+		correct bool
+		want    float64
+	}{
+		// Posterior L(1-S) / (L(1-S) + (1-L)G) = 0.27 / 0.41, luego + (1 - posterior) T
+		{"correct", 0.3, true, 0.6927},
+		// Posterior LS / (LS + (1-L)(1-G)) = 0.03 / 0.59
+		{"wrong", 0.3, false, 0.1458},
+		{"correct when mastered", 0.95, true, 0.9896},
+		{"wrong when mastered", 0.95, false, 0.7333},
+		{"lower bound", 0.0001, false, 0.1},
+		{"upper bound", 0.9999, true, 0.9999},
+	}
+	for _, tt := range tests {
+		got := defaultBKTParams.update(tt.dominio, tt.correct)
+		if math.Abs(got-tt.want) > 1e-4 {
+			t.Errorf("%s: update(%v, %v) = %.4f, want %.4f", tt.name, tt.dominio, tt.correct, got, tt.want)
+		}
+	}
+}
+
+// Con P(G) y P(S) acotados por la grilla un acierto nunca baja el dominio ni un error lo sube más que el aprendizaje
+func TestBKTUpdateWithinGridBounds(t *testing.T) {
+	for _, adivinar := range bktAdivinarGrid {
+		for _, desliz := range bktDeslizGrid {
+			params := bktParams{inicial: 0.3, aprendizaje: 0, adivinar: adivinar, desliz: desliz}
+			for _, dominio := range []float64{0.0001, 0.05, 0.3, 0.5, 0.8, 0.9999} {
+				correct := params.update(dominio, true)
+				wrong := params.update(dominio, false)
+				if correct < dominio-1e-9 || wrong > dominio+1e-9 {
+					t.Errorf("G=%v S=%v L=%v: correct %v, wrong %v", adivinar, desliz, dominio, correct, wrong)
+				}
+				if correct < 0.0001 || correct > 0.9999 || wrong < 0.0001 || wrong > 0.9999 {
+					t.Errorf("G=%v S=%v L=%v: outside the clamp", adivinar, desliz, dominio)
+				}
+			}
+		}
+	}
+}
+
+func TestBKTObserveWeight(t *testing.T) {
+	full := defaultBKTParams.update(0.3, true)
+	tests := []struct {
+		peso float64
+		want float64
+	}{
+		{1, full},
+		{0, 0.3},
+		{0.5, 0.3 + (full-0.3)/2},
+	}
+	for _, tt := range tests {
+		if got := defaultBKTParams.observe(0.3, bktObservation{correct: true, peso: tt.peso}); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("observe with peso %v = %v, want %v", tt.peso, got, tt.want)
+		}
+	}
+}
+
+// simulateBKT genera respuestas de estudiantes que siguen el modelo con params
+func simulateBKT(params bktParams, students, answers int, seed int64) [][]bktObservation {
+	rng := rand.New(rand.NewSource(seed))
+	sequences := make([][]bktObservation, students)
+	for i := range sequences {
+		knows := rng.Float64() < params.inicial
+		for j := 0; j < answers; j++ {
+			correct := rng.Float64() < params.adivinar
+			if knows {
+				correct = rng.Float64() >= params.desliz
+			}
+			sequences[i] = append(sequences[i], bktObservation{correct: correct, peso: 1})
+			if !knows && rng.Float64() < params.aprendizaje {
+				knows = true
+			}
+		}
+	}
+	return sequences
+}
+
+func TestFitBKTParamsRecoversKnownParameters(t *testing.T) {
+	tests := []bktParams{
+		{inicial: 0.2, aprendizaje: 0.15, adivinar: 0.2, desliz: 0.1},
+		{inicial: 0.6, aprendizaje: 0.05, adivinar: 0.1, desliz: 0.05},
+	}
+	for _, truth := range tests {
+		sequences := simulateBKT(truth, 3000, 12, 42)
+		fitted, ll := fitBKTParams(sequences, defaultBKTParams)
+
+		for _, field := range []struct {
+			name           string
+			got, want, tol float64
+		}{
+			{"inicial", fitted.inicial, truth.inicial, 0.1},
+			{"aprendizaje", fitted.aprendizaje, truth.aprendizaje, 0.05},
+			{"adivinar", fitted.adivinar, truth.adivinar, 0.05},
+			{"desliz", fitted.desliz, truth.desliz, 0.03},
+		} {
+			if math.Abs(field.got-field.want) > field.tol+1e-9 {
+				t.Errorf("truth %+v: fitted %s = %v, want %v ± %v", truth, field.name, field.got, field.want, field.tol)
+			}
+		}
+		if trueLL := truth.logLikelihood(sequences); ll < trueLL-1e-6 {
+			t.Errorf("truth %+v: fitted log-likelihood %v below the true parameters' %v", truth, ll, trueLL)
+		}
+	}
+}
+
+func TestFitBKTParamsWithoutEvidenceKeepsStart(t *testing.T) {
+	if fitted, _ := fitBKTParams(nil, defaultBKTParams); fitted != defaultBKTParams {
+		t.Errorf("fitted %+v from no answers", fitted)
+	}
+}
+
+func TestKnowledgeEstado(t *testing.T) {
+	config := knowledgeTracingConfig{LogradoPercent: 80, DominadoPercent: 95}
+	tests := []struct {
+		dominio    float64
+		respuestas int
+		estado     string
+		porcentaje int
+	}{
+		{0.3, 0, "no_iniciado", 30},
+		{0.3, 4, "en_proceso", 30},
+		{0.795, 4, "logrado", 80},
+		{0.944, 4, "logrado", 94},
+		{0.949, 4, "dominado", 95},
+		{0.9999, 4, "dominado", 100},
+	}
+	for _, tt := range tests {
+		estado, porcentaje := knowledgeEstado(tt.dominio, tt.respuestas, config)
+		if estado != tt.estado || porcentaje != tt.porcentaje {
+			t.Errorf("knowledgeEstado(%v, %d) = %s %d, want %s %d", tt.dominio, tt.respuestas, estado, porcentaje, tt.estado, tt.porcentaje)
+		}
+	}
+}
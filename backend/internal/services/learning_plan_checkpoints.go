@@ -0,0 +1,452 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// checkpointQuestions es la cantidad de preguntas de cada checkpoint
+const checkpointQuestions = 3
+
+// checkpointMinQuestions es el mínimo de preguntas del banco para crear checkpoints en un plan
+const checkpointMinQuestions = 2
+
+// ErrCheckpointNotFound indica que el checkpoint no existe o no pertenece al plan del usuario
+var ErrCheckpointNotFound = errors.New("checkpoint not found")
+
+// ErrPlanNotFound indica que el plan no existe o no pertenece al usuario
+var ErrPlanNotFound = errors.New("plan not found")
+
+// CheckpointQuestion es una pregunta del checkpoint tal como la ve el estudiante (sin la respuesta)
+type CheckpointQuestion struct {
+	ID           uint                   `json:"id"`
+	Tipo         string                 `json:"tipo"`
+	QuestionData map[string]interface{} `json:"question_data"`
+}
+
+// CheckpointView es un checkpoint con sus preguntas
+type CheckpointView struct {
+	models.LearningPlanCheckpoint
+	Preguntas []CheckpointQuestion `json:"preguntas"`
+}
+
+// CheckpointAnswer es la respuesta del estudiante a una pregunta (mismo formato que ValidateAnswer)
+type CheckpointAnswer struct {
+	QuestionID uint           `json:"question_id"`
+	Answer     datatypes.JSON `json:"answer"`
+}
+
+// CheckpointItemResult es el resultado de una pregunta del checkpoint
+type CheckpointItemResult struct {
+	QuestionID uint    `json:"question_id"`
+	Correcta   bool    `json:"correcta"`
+	Puntaje    float64 `json:"puntaje"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// CheckpointResult es el resultado de enviar un checkpoint
+type CheckpointResult struct {
+	Checkpoint   models.LearningPlanCheckpoint `json:"checkpoint"`
+	Correctas    int                           `json:"correctas"`
+	Total        int                           `json:"total"`
+	Porcentaje   int                           `json:"porcentaje"`
+	Aprobado     bool                          `json:"aprobado"`
+	Resultados   []CheckpointItemResult        `json:"resultados"`
+	Remediacion  *models.LearningPlanComponent `json:"remediacion,omitempty"`
+	PlanAprobado bool                          `json:"plan_aprobado"` // todos los checkpoints del plan están aprobados
+}
+
+// checkpointCandidates retorna las preguntas del banco (evaluables automáticamente) para los checkpoints de un OA-Bloom.
+// Se excluyen las que un GuidedPracticeQuiz del mismo plan puede mostrar con su respuesta: el contenido se genera
+// después de crear los checkpoints (y puede venir del caché compartido), así que los conjuntos deben ser disjuntos.
+func checkpointCandidates(tx *gorm.DB, oaBloomObjectiveID uint) ([]int64, error) {
+	var ids []int64
+	err := tx.Model(&models.Question{}).
+		Where("oa_bloom_objective_id = ? AND activa = true AND tipo IN ? AND tipo_uso IN ?",
+			oaBloomObjectiveID, models.CheckpointQuestionTypes(), []string{"evaluacion", "all"}).
+		Where("NOT (tipo = ? AND tipo_uso IN ?)", guidedPracticeTipo, guidedPracticeTiposUso).
+		Order("veces_usada ASC, id ASC").
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// createPlanCheckpoints crea los checkpoints de un plan recién creado: después de los componentes que la
+// estructura marcó y siempre al final. Si el banco no tiene suficientes preguntas el plan queda sin checkpoints.
+func createPlanCheckpoints(tx *gorm.DB, plan *models.LearningPlan, structure *LearningPlanStructure) error {
+	if len(plan.Components) == 0 {
+		return nil
+	}
+
+	candidates, err := checkpointCandidates(tx, plan.OABloomObjectiveID)
+	if err != nil {
+		return err
+	}
+	if len(candidates) < checkpointMinQuestions {
+		log.Printf("ℹ Plan %d without checkpoints: only %d gradable questions for objective %d", plan.ID, len(candidates), plan.OABloomObjectiveID)
+		return nil
+	}
+
+	last := len(plan.Components) - 1
+	next := 0
+	for i, component := range plan.Components {
+		if i != last && (i >= len(structure.Componentes) || !structure.Componentes[i].Checkpoint) {
+			continue
+		}
+
+		// Repartir las preguntas entre los checkpoints; si no alcanzan se reutilizan desde el inicio
+		size := checkpointQuestions
+		if size > len(candidates) {
+			size = len(candidates)
+		}
+		questionIDs := make(pq.Int64Array, 0, size)
+		for len(questionIDs) < size {
+			questionIDs = append(questionIDs, candidates[next%len(candidates)])
+			next++
+		}
+
+		checkpoint := models.LearningPlanCheckpoint{
+			LearningPlanID: plan.ID,
+			ComponentID:    component.ID,
+			QuestionIDs:    questionIDs,
+			Estado:         models.CheckpointEstadoPendiente,
+		}
+		if err := tx.Create(&checkpoint).Error; err != nil {
+			return err
+		}
+		plan.Checkpoints = append(plan.Checkpoints, checkpoint)
+	}
+
+	return nil
+}
+
+// loadUserCheckpoint carga un checkpoint verificando que el plan pertenezca al usuario
+func loadUserCheckpoint(tx *gorm.DB, userID, planID, checkpointID uint) (*models.LearningPlan, *models.LearningPlanCheckpoint, error) {
+	var plan models.LearningPlan
+	if err := tx.Where("id = ? AND user_id = ?", planID, userID).First(&plan).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrPlanNotFound
+		}
+		return nil, nil, err
+	}
+
+	var checkpoint models.LearningPlanCheckpoint
+	if err := tx.Where("id = ? AND learning_plan_id = ?", checkpointID, planID).First(&checkpoint).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrCheckpointNotFound
+		}
+		return nil, nil, err
+	}
+
+	return &plan, &checkpoint, nil
+}
+
+// loadCheckpointQuestions carga las preguntas de un checkpoint en el orden guardado
+func loadCheckpointQuestions(tx *gorm.DB, checkpoint *models.LearningPlanCheckpoint) ([]models.Question, error) {
+	var questions []models.Question
+	if err := tx.Where("id IN ?", []int64(checkpoint.QuestionIDs)).Find(&questions).Error; err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]models.Question, len(questions))
+	for _, q := range questions {
+		byID[q.ID] = q
+	}
+	ordered := make([]models.Question, 0, len(checkpoint.QuestionIDs))
+	for _, id := range checkpoint.QuestionIDs {
+		if q, ok := byID[uint(id)]; ok {
+			ordered = append(ordered, q)
+		}
+	}
+	return ordered, nil
+}
+
+// toCheckpointQuestion oculta la explicación para que no revele la respuesta
+func toCheckpointQuestion(q models.Question) CheckpointQuestion {
+	var data map[string]interface{}
+	json.Unmarshal(q.QuestionData, &data)
+	delete(data, "explicacion")
+	return CheckpointQuestion{ID: q.ID, Tipo: q.Tipo, QuestionData: data}
+}
+
+// ListPlanCheckpoints retorna los checkpoints de un plan con sus preguntas, en el orden del plan
+func ListPlanCheckpoints(userID, planID uint) ([]CheckpointView, error) {
+	var plan models.LearningPlan
+	if err := db.DB.Where("id = ? AND user_id = ?", planID, userID).First(&plan).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPlanNotFound
+		}
+		return nil, err
+	}
+
+	var checkpoints []models.LearningPlanCheckpoint
+	if err := db.DB.Table("learning_plan_checkpoints").
+		Select("learning_plan_checkpoints.*").
+		Joins("JOIN learning_plan_components c ON c.id = learning_plan_checkpoints.component_id").
+		Where("learning_plan_checkpoints.learning_plan_id = ?", planID).
+		Order("c.orden ASC").
+		Find(&checkpoints).Error; err != nil {
+		return nil, err
+	}
+
+	views := make([]CheckpointView, 0, len(checkpoints))
+	for i := range checkpoints {
+		questions, err := loadCheckpointQuestions(db.DB, &checkpoints[i])
+		if err != nil {
+			return nil, err
+		}
+		view := CheckpointView{LearningPlanCheckpoint: checkpoints[i], Preguntas: make([]CheckpointQuestion, 0, len(questions))}
+		for _, q := range questions {
+			view.Preguntas = append(view.Preguntas, toCheckpointQuestion(q))
+		}
+		views = append(views, view)
+	}
+
+	return views, nil
+}
+
+// PendingCheckpointIDs retorna los checkpoints del plan que aún no se aprueban
+func PendingCheckpointIDs(planID uint) ([]uint, error) {
+	var ids []uint
+	err := db.DB.Model(&models.LearningPlanCheckpoint{}).
+		Where("learning_plan_id = ? AND estado <> ?", planID, models.CheckpointEstadoAprobado).
+		Order("id ASC").
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// SubmitCheckpoint corrige las respuestas con Question.ValidateAnswer, guarda el intento y un evento en
+// StudentOAHistory. Si el checkpoint se reprueba por primera vez inserta un slide de remediación
+// (ExplainAndExploreSlide pendiente) justo después del componente del checkpoint.
+func SubmitCheckpoint(userID, planID, checkpointID uint, answers []CheckpointAnswer) (*CheckpointResult, error) {
+	result := &CheckpointResult{}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		plan, checkpoint, err := loadUserCheckpoint(tx, userID, planID, checkpointID)
+		if err != nil {
+			return err
+		}
+
+		questions, err := loadCheckpointQuestions(tx, checkpoint)
+		if err != nil {
+			return err
+		}
+		if len(questions) == 0 {
+			return fmt.Errorf("checkpoint %d has no active questions", checkpoint.ID)
+		}
+
+		answerByID := make(map[uint]datatypes.JSON, len(answers))
+		for _, a := range answers {
+			answerByID[a.QuestionID] = a.Answer
+		}
+
+		// Corregir cada pregunta; una respuesta faltante o mal formada cuenta como incorrecta
+		var missed []models.Question
+		result.Resultados = make([]CheckpointItemResult, 0, len(questions))
+		for _, q := range questions {
+			item := CheckpointItemResult{QuestionID: q.ID}
+			if answer, ok := answerByID[q.ID]; !ok || len(answer) == 0 {
+				item.Error = "missing answer"
+			} else if correct, score, err := q.ValidateAnswer(answer); err != nil {
+				item.Error = err.Error()
+			} else {
+				item.Correcta = correct
+				item.Puntaje = score
+			}
+
+			if item.Correcta {
+				result.Correctas++
+			} else {
+				missed = append(missed, q)
+			}
+			result.Resultados = append(result.Resultados, item)
+		}
+
+		result.Total = len(questions)
+		result.Porcentaje = result.Correctas * 100 / result.Total
+		result.Aprobado = result.Porcentaje >= checkpoint.PorcentajeAprobacion
+
+		respuestasJSON, _ := json.Marshal(result.Resultados)
+		attempt := models.LearningPlanCheckpointAttempt{
+			CheckpointID: checkpoint.ID,
+			UserID:       userID,
+			Respuestas:   datatypes.JSON(respuestasJSON),
+			Correctas:    result.Correctas,
+			Total:        result.Total,
+			Porcentaje:   result.Porcentaje,
+			Aprobado:     result.Aprobado,
+		}
+		if err := tx.Create(&attempt).Error; err != nil {
+			return err
+		}
+
+		// Un checkpoint aprobado sigue aprobado aunque se vuelva a intentar
+		checkpoint.Intentos++
+		if checkpoint.MejorPorcentaje == nil || result.Porcentaje > *checkpoint.MejorPorcentaje {
+			porcentaje := result.Porcentaje
+			checkpoint.MejorPorcentaje = &porcentaje
+		}
+		if result.Aprobado {
+			checkpoint.Estado = models.CheckpointEstadoAprobado
+		} else if checkpoint.Estado != models.CheckpointEstadoAprobado {
+			checkpoint.Estado = models.CheckpointEstadoReprobado
+		}
+
+		if !result.Aprobado && checkpoint.Estado != models.CheckpointEstadoAprobado {
+			remediation, err := ensureRemediationComponent(tx, plan, checkpoint, missed)
+			if err != nil {
+				return err
+			}
+			result.Remediacion = remediation
+		}
+
+		if err := tx.Save(checkpoint).Error; err != nil {
+			return err
+		}
+
+		if err := recordCheckpointHistory(tx, userID, plan, checkpoint.ID, result); err != nil {
+			return err
+		}
+
+		result.Checkpoint = *checkpoint
+
+		var pending int64
+		if err := tx.Model(&models.LearningPlanCheckpoint{}).
+			Where("learning_plan_id = ? AND estado <> ?", plan.ID, models.CheckpointEstadoAprobado).
+			Count(&pending).Error; err != nil {
+			return err
+		}
+		result.PlanAprobado = pending == 0
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Checkpoint %d of plan %d: %d/%d (aprobado=%v)", checkpointID, planID, result.Correctas, result.Total, result.Aprobado)
+	return result, nil
+}
+
+// ensureRemediationComponent inserta (una sola vez por checkpoint) el slide de remediación después
+// del componente del checkpoint. Si ya existe, lo retorna.
+func ensureRemediationComponent(tx *gorm.DB, plan *models.LearningPlan, checkpoint *models.LearningPlanCheckpoint, missed []models.Question) (*models.LearningPlanComponent, error) {
+	if checkpoint.RemediationComponentID != nil {
+		var existing models.LearningPlanComponent
+		if err := tx.First(&existing, *checkpoint.RemediationComponentID).Error; err == nil {
+			return &existing, nil
+		}
+	}
+
+	var anchor models.LearningPlanComponent
+	if err := tx.First(&anchor, checkpoint.ComponentID).Error; err != nil {
+		return nil, err
+	}
+
+	// Correr los componentes siguientes en dos pasos para no chocar con UNIQUE (learning_plan_id, orden)
+	if err := tx.Model(&models.LearningPlanComponent{}).
+		Where("learning_plan_id = ? AND orden > ?", plan.ID, anchor.Orden).
+		Update("orden", gorm.Expr("-(orden + 1)")).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&models.LearningPlanComponent{}).
+		Where("learning_plan_id = ? AND orden < 0", plan.ID).
+		Update("orden", gorm.Expr("-orden")).Error; err != nil {
+		return nil, err
+	}
+
+	remediation := models.LearningPlanComponent{
+		LearningPlanID:     plan.ID,
+		Orden:              anchor.Orden + 1,
+		TipoComponente:     models.ComponentTipoExplainAndExplore,
+		ObjetivoEspecifico: remediationObjective(anchor.ObjetivoEspecifico, missed),
+		TiempoEstimadoMin:  10,
+		Estado:             models.ComponentEstadoPendiente,
+		EsRemediacion:      true,
+	}
+	if err := tx.Create(&remediation).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.Model(plan).Updates(map[string]interface{}{
+		"total_slides":            gorm.Expr("total_slides + 1"),
+		"tiempo_estimado_minutos": gorm.Expr("tiempo_estimado_minutos + ?", remediation.TiempoEstimadoMin),
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	checkpoint.RemediationComponentID = &remediation.ID
+	log.Printf("Inserted remediation component %d after component %d (plan %d)", remediation.ID, anchor.ID, plan.ID)
+	return &remediation, nil
+}
+
+// remediationObjective arma el objetivo del slide de remediación a partir de las preguntas falladas
+func remediationObjective(anchorObjective string, missed []models.Question) string {
+	var enunciados []string
+	for _, q := range missed {
+		if enunciado := questionStatement(q); enunciado != "" {
+			enunciados = append(enunciados, fmt.Sprintf("\"%s\"", enunciado))
+		}
+	}
+
+	objective := fmt.Sprintf("Reforzar: %s. Explica de nuevo con otros ejemplos y aborda los errores típicos.", strings.TrimSuffix(anchorObjective, "."))
+	if len(enunciados) > 0 {
+		objective += fmt.Sprintf(" El estudiante respondió mal estas preguntas (no reveles sus respuestas): %s.", strings.Join(enunciados, "; "))
+	}
+	return objective
+}
+
+// questionStatement extrae el enunciado de una pregunta según su tipo
+func questionStatement(q models.Question) string {
+	var data map[string]interface{}
+	if err := json.Unmarshal(q.QuestionData, &data); err != nil {
+		return ""
+	}
+	for _, key := range []string{"pregunta", "afirmacion", "statement", "text", "instruccion", "instruction"} {
+		if value, ok := data[key].(string); ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// recordCheckpointHistory registra el intento como evento "checkpoint" en StudentOAHistory. Las preguntas del
+// checkpoint se repiten en cada intento, así que no son evidencia para el dominio estimado: el evento guarda el
+// acierto como puntaje y el estado y porcentaje de logro vigentes del objetivo (sin progreso, solo el estado).
+func recordCheckpointHistory(tx *gorm.DB, userID uint, plan *models.LearningPlan, checkpointID uint, result *CheckpointResult) error {
+	var progress []models.StudentOAProgress
+	if err := tx.Where("user_id = ? AND oa_bloom_objective_id = ?", userID, plan.OABloomObjectiveID).
+		Limit(1).Find(&progress).Error; err != nil {
+		return err
+	}
+	estado := "en_proceso"
+	var porcentaje *int
+	if len(progress) > 0 {
+		estado = progress[0].Estado
+		porcentaje = &progress[0].PorcentajeLogro
+	}
+
+	puntajeObtenido := float64(result.Correctas)
+	puntajeMaximo := float64(result.Total)
+
+	history := models.StudentOAHistory{
+		UserID:             userID,
+		OABloomObjectiveID: plan.OABloomObjectiveID,
+		Estado:             estado,
+		PorcentajeLogro:    porcentaje,
+		TipoEvento:         "checkpoint",
+		PuntajeObtenido:    &puntajeObtenido,
+		PuntajeMaximo:      &puntajeMaximo,
+		Notas:              fmt.Sprintf("Checkpoint %d del plan %d - %d/%d correctas", checkpointID, plan.ID, result.Correctas, result.Total),
+	}
+
+	return tx.Create(&history).Error
+}
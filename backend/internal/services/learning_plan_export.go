@@ -0,0 +1,480 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"embed"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"gorm.io/gorm"
+)
+
+// Formatos de exportación de un plan (GET /api/learning-plans/{id}/export?format=)
+const (
+	ExportFormatSCORM    = "scorm"    // SCORM 1.2: imsmanifest.xml + SCO único que reporta lesson_status
+	ExportFormatXAPI     = "xapi"     // xAPI (Tin Can): tincan.xml + statements al LRS de los parámetros de lanzamiento
+	ExportFormatHTML     = "html"     // index.html imprimible + audios
+	ExportFormatMarkdown = "markdown" // plan.md + audios
+)
+
+// ErrInvalidExportFormat se retorna para un format desconocido
+var ErrInvalidExportFormat = errors.New("invalid export format")
+
+//go:embed export_templates/*.tmpl
+var exportTemplateFiles embed.FS
+
+var (
+	exportHTMLTemplate = htmltemplate.Must(htmltemplate.New("plan.html.tmpl").
+				ParseFS(exportTemplateFiles, "export_templates/plan.html.tmpl"))
+	exportTextTemplates = template.Must(template.New("").
+				Funcs(template.FuncMap{"xml": xmlEscape, "quote": markdownBlockquote, "cell": markdownCell}).
+				ParseFS(exportTemplateFiles, "export_templates/plan.md.tmpl", "export_templates/imsmanifest.xml.tmpl", "export_templates/tincan.xml.tmpl"))
+)
+
+// ExportFormats son los formatos aceptados por ExportLearningPlan
+func ExportFormats() []string {
+	return []string{ExportFormatSCORM, ExportFormatXAPI, ExportFormatHTML, ExportFormatMarkdown}
+}
+
+// PlanExport es el zip generado para un plan
+type PlanExport struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// exportDocument es el plan listo para las plantillas de export_templates
+type exportDocument struct {
+	PlanID      uint
+	Titulo      string
+	Descripcion string
+	Objetivo    string
+	Minutos     int
+	Version     int
+	Fuentes     []models.CurriculumCitation
+	Secciones   []exportSection
+	Seguimiento string // scorm, xapi o vacío (sin seguimiento)
+	ActividadID string // IRI de la actividad xAPI
+	Archivos    []string
+	GeneradoEl  time.Time
+}
+
+// exportSection es un componente del plan
+type exportSection struct {
+	Numero   int
+	Titulo   string
+	Tipo     string
+	Objetivo string
+	Minutos  int
+	Bloques  []exportBlock
+	Fuentes  []models.CurriculumCitation
+}
+
+// exportBlock es un bloque neutro que las plantillas Markdown y HTML renderizan cada una a su manera
+type exportBlock struct {
+	Tipo        string // parrafo, subtitulo, nota, lista, numerada, tabla, pregunta, audio
+	Titulo      string
+	Texto       string
+	Estilo      string // nota: info, warning, tip, ejercicio, pista
+	Items       []string
+	Encabezados []string
+	Filas       [][]string
+	Respuesta   string // pregunta: respuesta y explicación (oculta en HTML hasta hacer clic)
+	Audio       string // ruta del mp3 dentro del zip
+}
+
+// exportNoteLabels son los títulos de las notas según su estilo
+var exportNoteLabels = map[string]string{
+	"info":    "Nota",
+	"warning": "Atención",
+	"tip":     "Consejo",
+}
+
+// exportComponentLabels son los nombres de los tipos de componente para docentes y estudiantes
+var exportComponentLabels = map[string]string{
+	"ExplainAndExploreSlide": "Explicación",
+	"GuidedPracticeQuiz":     "Práctica guiada",
+	"WorkedExample":          "Ejemplo resuelto",
+	"ReadingPassage":         "Lectura",
+	"FlashcardDeck":          "Tarjetas de repaso",
+	"ReflectionPrompt":       "Reflexión",
+}
+
+// planExporter arma el documento y recuerda los audios cacheados que hay que incluir
+type planExporter struct {
+	audios map[string]string // ruta en el zip -> mp3 en el caché de TTS
+}
+
+// ExportLearningPlan exporta un plan generado a un zip autocontenido en el formato pedido.
+// El dueño del plan siempre puede exportarlo; anyOwner (docentes y admins) permite exportar planes de otros.
+// Los componentes de remediación, en cuarentena o sin contenido quedan fuera.
+func ExportLearningPlan(userID uint, anyOwner bool, planID uint, format string) (*PlanExport, error) {
+	if !containsString(ExportFormats(), format) {
+		return nil, fmt.Errorf("%w: usa %s", ErrInvalidExportFormat, strings.Join(ExportFormats(), ", "))
+	}
+
+	var plan models.LearningPlan
+	query := db.DB.Preload("OABloomObjective").
+		Preload("Components", func(tx *gorm.DB) *gorm.DB { return tx.Order("orden ASC") })
+	if !anyOwner {
+		query = query.Where("user_id = ?", userID)
+	}
+	if err := query.First(&plan, planID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPlanNotFound
+		}
+		return nil, err
+	}
+	if err := ensurePlanIdle(db.DB, &plan); err != nil {
+		return nil, err
+	}
+
+	exporter := &planExporter{audios: map[string]string{}}
+	doc := exporter.document(&plan)
+	switch format {
+	case ExportFormatSCORM:
+		doc.Seguimiento = ExportFormatSCORM
+	case ExportFormatXAPI:
+		doc.Seguimiento = ExportFormatXAPI
+		doc.ActividadID = fmt.Sprintf("%s/learning-plans/%d", strings.TrimRight(getEnvString("XAPI_ACTIVITY_BASE_URL", "https://lumera.app/xapi"), "/"), plan.ID)
+	}
+
+	data, err := exporter.zip(doc, format)
+	if err != nil {
+		return nil, err
+	}
+	return &PlanExport{
+		Filename:    fmt.Sprintf("plan-%d-%s-%s.zip", plan.ID, exportSlug(plan.Titulo), format),
+		ContentType: "application/zip",
+		Data:        data,
+	}, nil
+}
+
+func (e *planExporter) document(plan *models.LearningPlan) *exportDocument {
+	doc := &exportDocument{
+		PlanID:      plan.ID,
+		Titulo:      plan.Titulo,
+		Descripcion: plan.Descripcion,
+		Objetivo:    plan.OABloomObjective.ObjetivoEspecifico,
+		Minutos:     plan.TiempoEstimadoMin,
+		Version:     plan.Version,
+		Fuentes:     unmarshalCitations(plan.Fuentes),
+		GeneradoEl:  time.Now(),
+	}
+
+	for _, component := range plan.Components {
+		if component.EsRemediacion || component.Estado != models.ComponentEstadoGenerado || len(component.ContenidoProps) == 0 {
+			continue
+		}
+		var content map[string]interface{}
+		if err := json.Unmarshal(component.ContenidoProps, &content); err != nil {
+			continue
+		}
+
+		section := exportSection{
+			Numero:   len(doc.Secciones) + 1,
+			Titulo:   stringField(content, "titulo"),
+			Tipo:     exportComponentLabels[component.TipoComponente],
+			Objetivo: component.ObjetivoEspecifico,
+			Minutos:  component.TiempoEstimadoMin,
+			Bloques:  e.componentBlocks(component.TipoComponente, content),
+		}
+		if section.Tipo == "" {
+			section.Tipo = component.TipoComponente
+		}
+		if section.Titulo == "" {
+			section.Titulo = component.ObjetivoEspecifico
+		}
+		if citations, err := json.Marshal(content["fuentes"]); err == nil {
+			section.Fuentes = unmarshalCitations(citations)
+		}
+		doc.Secciones = append(doc.Secciones, section)
+	}
+	return doc
+}
+
+// componentBlocks traduce el contenido_props de cada tipo de componente (ver docs/LEARNING_PLANS_API.md)
+func (e *planExporter) componentBlocks(componentType string, content map[string]interface{}) []exportBlock {
+	var blocks []exportBlock
+	add := func(block exportBlock) {
+		empty := block.Texto == "" && block.Respuesta == "" && block.Audio == "" && len(block.Items) == 0 && len(block.Filas) == 0
+		if empty && (block.Tipo != "subtitulo" || block.Titulo == "") {
+			return
+		}
+		if block.Tipo == "nota" && block.Titulo == "" {
+			block.Titulo = exportNoteLabels[block.Estilo]
+			if block.Titulo == "" {
+				block.Titulo = "Nota"
+			}
+		}
+		blocks = append(blocks, block)
+	}
+	add(exportBlock{Tipo: "parrafo", Texto: stringField(content, "introduccion")})
+
+	switch componentType {
+	case "ExplainAndExploreSlide":
+		for _, bloque := range objectList(content, "bloques") {
+			switch stringField(bloque, "tipo") {
+			case "texto":
+				texto, _ := bloque["contenido"].(string) // sin recortar: es la clave del caché de TTS
+				add(exportBlock{Tipo: "audio", Audio: e.audio(texto)})
+				add(exportBlock{Tipo: "parrafo", Texto: strings.TrimSpace(texto)})
+			case "ejemplo":
+				add(exportBlock{Tipo: "subtitulo", Titulo: stringField(bloque, "titulo")})
+				add(exportBlock{Tipo: "parrafo", Texto: stringField(bloque, "contenido")})
+				add(exportBlock{Tipo: "nota", Estilo: "info", Texto: stringField(bloque, "analisis")})
+			case "definicion":
+				add(exportBlock{Tipo: "parrafo", Titulo: stringField(bloque, "termino"), Texto: stringField(bloque, "texto")})
+			case "nota":
+				add(exportBlock{Tipo: "nota", Estilo: stringField(bloque, "estilo"), Texto: stringField(bloque, "texto")})
+			case "ejercicio":
+				add(exportBlock{Tipo: "nota", Estilo: "ejercicio", Titulo: "Ejercicio", Texto: stringField(bloque, "instruccion")})
+				add(exportBlock{Tipo: "parrafo", Titulo: "Ejemplo", Texto: stringField(bloque, "ejemplo")})
+			case "resumen":
+				add(exportBlock{Tipo: "lista", Titulo: "Resumen", Items: stringList(bloque, "puntos")})
+			case "comparacion":
+				table := exportBlock{Tipo: "tabla", Encabezados: []string{"Aspecto", "Opción 1", "Opción 2"}}
+				for _, item := range objectList(bloque, "items") {
+					table.Filas = append(table.Filas, []string{stringField(item, "aspecto"), stringField(item, "opcion1"), stringField(item, "opcion2")})
+				}
+				add(table)
+			}
+		}
+
+	case "GuidedPracticeQuiz":
+		for i, pregunta := range objectList(content, "preguntas") {
+			add(questionBlock(i+1, pregunta))
+			add(exportBlock{Tipo: "nota", Estilo: "pista", Titulo: "Pista", Texto: stringField(pregunta, "pista")})
+			add(exportBlock{Tipo: "nota", Estilo: "tip", Titulo: "Estrategia", Texto: stringField(pregunta, "estrategia")})
+		}
+
+	case "WorkedExample":
+		for i, ejemplo := range objectList(content, "ejemplos") {
+			add(exportBlock{Tipo: "subtitulo", Titulo: fmt.Sprintf("Ejemplo %d", i+1)})
+			add(exportBlock{Tipo: "parrafo", Texto: stringField(ejemplo, "enunciado")})
+			pasos := exportBlock{Tipo: "numerada"}
+			for _, paso := range objectList(ejemplo, "pasos") {
+				item := stringField(paso, "descripcion")
+				if stringField(paso, "completado_por") == "estudiante" {
+					item += " → completa este paso"
+					if pista := stringField(paso, "pista"); pista != "" {
+						item += " (pista: " + pista + ")"
+					}
+				} else if resultado := stringField(paso, "resultado"); resultado != "" {
+					item += " → " + resultado
+				}
+				pasos.Items = append(pasos.Items, item)
+			}
+			add(pasos)
+			add(exportBlock{Tipo: "nota", Estilo: "tip", Titulo: "Respuesta final", Texto: stringField(ejemplo, "respuesta_final")})
+		}
+
+	case "ReadingPassage":
+		texto, _ := content["texto"].(string)
+		add(exportBlock{Tipo: "audio", Audio: e.audio(texto)})
+		add(exportBlock{Tipo: "parrafo", Texto: strings.TrimSpace(texto)})
+		if fuente := stringField(content, "fuente"); fuente != "" {
+			add(exportBlock{Tipo: "nota", Estilo: "info", Texto: "Fuente: " + fuente})
+		}
+		for i, pregunta := range objectList(content, "preguntas") {
+			block := questionBlock(i+1, pregunta)
+			if nivel := stringField(pregunta, "nivel"); nivel != "" {
+				block.Titulo += " (" + nivel + ")"
+			}
+			add(block)
+		}
+
+	case "FlashcardDeck":
+		table := exportBlock{Tipo: "tabla", Encabezados: []string{"Frente", "Reverso"}}
+		for _, tarjeta := range objectList(content, "tarjetas") {
+			reverso := stringField(tarjeta, "reverso")
+			if ejemplo := stringField(tarjeta, "ejemplo"); ejemplo != "" {
+				reverso += " (ej.: " + ejemplo + ")"
+			}
+			table.Filas = append(table.Filas, []string{stringField(tarjeta, "frente"), reverso})
+		}
+		add(table)
+
+	case "ReflectionPrompt":
+		add(exportBlock{Tipo: "parrafo", Texto: stringField(content, "contexto")})
+		add(exportBlock{Tipo: "numerada", Titulo: "Preguntas", Items: stringList(content, "preguntas")})
+		add(exportBlock{Tipo: "lista", Titulo: "Criterios de autoevaluación", Items: stringList(content, "criterios")})
+
+	default:
+		add(exportBlock{Tipo: "parrafo", Texto: stringField(content, "contenido")})
+	}
+	return blocks
+}
+
+// questionBlock arma una pregunta de selección múltiple con sus opciones ordenadas por letra
+func questionBlock(number int, pregunta map[string]interface{}) exportBlock {
+	block := exportBlock{
+		Tipo:   "pregunta",
+		Titulo: fmt.Sprintf("Pregunta %d", number),
+		Texto:  stringField(pregunta, "pregunta"),
+	}
+	if opciones, ok := pregunta["opciones"].(map[string]interface{}); ok {
+		letters := make([]string, 0, len(opciones))
+		for letter := range opciones {
+			letters = append(letters, letter)
+		}
+		sort.Strings(letters)
+		for _, letter := range letters {
+			block.Items = append(block.Items, fmt.Sprintf("%s) %v", letter, opciones[letter]))
+		}
+	}
+	block.Respuesta = strings.TrimSpace(stringField(pregunta, "respuesta_correcta") + ". " + stringField(pregunta, "explicacion"))
+	block.Respuesta = strings.Trim(block.Respuesta, ". ")
+	return block
+}
+
+// audio retorna la ruta en el zip del audio TTS del texto si ya está en el caché (no genera audios nuevos)
+func (e *planExporter) audio(text string) string {
+	if strings.TrimSpace(text) == "" {
+		return ""
+	}
+	path := TTSCachePath(text)
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	name := "audio/" + TTSCacheKey(text) + ".mp3"
+	e.audios[name] = path
+	return name
+}
+
+// zip escribe el paquete: el documento en el formato pedido, los audios y los manifiestos SCORM/xAPI
+func (e *planExporter) zip(doc *exportDocument, format string) ([]byte, error) {
+	files := map[string][]byte{}
+	var out bytes.Buffer
+
+	if format == ExportFormatMarkdown {
+		if err := exportTextTemplates.ExecuteTemplate(&out, "plan.md.tmpl", doc); err != nil {
+			return nil, err
+		}
+		files["plan.md"] = append([]byte(nil), out.Bytes()...)
+	} else {
+		if err := exportHTMLTemplate.Execute(&out, doc); err != nil {
+			return nil, err
+		}
+		files["index.html"] = append([]byte(nil), out.Bytes()...)
+	}
+
+	for name, path := range e.audios {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		files[name] = data
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	doc.Archivos = names
+
+	manifest := map[string]string{ExportFormatSCORM: "imsmanifest.xml", ExportFormatXAPI: "tincan.xml"}[format]
+	if manifest != "" {
+		out.Reset()
+		if err := exportTextTemplates.ExecuteTemplate(&out, manifest+".tmpl", doc); err != nil {
+			return nil, err
+		}
+		files[manifest] = append([]byte(nil), out.Bytes()...)
+		names = append([]string{manifest}, names...)
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, name := range names {
+		method := zip.Deflate
+		if strings.HasSuffix(name, ".mp3") {
+			method = zip.Store // ya comprimido
+		}
+		writer, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: doc.GeneradoEl})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := writer.Write(files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func unmarshalCitations(data []byte) []models.CurriculumCitation {
+	var citations []models.CurriculumCitation
+	if len(data) == 0 || json.Unmarshal(data, &citations) != nil {
+		return nil
+	}
+	return citations
+}
+
+func stringField(object map[string]interface{}, field string) string {
+	value, _ := object[field].(string)
+	return strings.TrimSpace(value)
+}
+
+func stringList(object map[string]interface{}, field string) []string {
+	values, _ := object[field].([]interface{})
+	list := make([]string, 0, len(values))
+	for _, value := range values {
+		if text, ok := value.(string); ok && strings.TrimSpace(text) != "" {
+			list = append(list, strings.TrimSpace(text))
+		}
+	}
+	return list
+}
+
+func objectList(object map[string]interface{}, field string) []map[string]interface{} {
+	values, _ := object[field].([]interface{})
+	list := make([]map[string]interface{}, 0, len(values))
+	for _, value := range values {
+		if item, ok := value.(map[string]interface{}); ok {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// exportSlug arma la parte legible del nombre del archivo a partir del título del plan
+func exportSlug(title string) string {
+	slug := strings.ReplaceAll(normalizeCacheText(title), " ", "-")
+	if runes := []rune(slug); len(runes) > 60 {
+		slug = strings.Trim(string(runes[:60]), "-")
+	}
+	if slug == "" {
+		return "plan"
+	}
+	return slug
+}
+
+// markdownBlockquote convierte un texto (quizás de varias líneas) en una cita de Markdown
+func markdownBlockquote(text string) string {
+	return "> " + strings.ReplaceAll(strings.TrimSpace(text), "\n", "\n> ")
+}
+
+// markdownCell deja un texto en una sola línea y escapa los | para usarlo en una tabla de Markdown
+func markdownCell(text string) string {
+	return strings.ReplaceAll(strings.Join(strings.Fields(text), " "), "|", "\\|")
+}
+
+func xmlEscape(text string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// BuildOAContext carga el OA-Bloom objective y el perfil del estudiante para armar el contexto de los prompts
+func BuildOAContext(userID, oaBloomObjectiveID uint) (*OAContext, error) {
+	var oaBloomObjective models.OABloomObjective
+	if err := db.DB.Preload("OA").Preload("OA.Materia").Preload("BloomLevel").
+		First(&oaBloomObjective, oaBloomObjectiveID).Error; err != nil {
+		return nil, fmt.Errorf("objective not found: %w", err)
+	}
+
+	oaContext := &OAContext{
+		UserID:             userID,
+		OABloomObjectiveID: oaBloomObjectiveID,
+		MateriaID:          oaBloomObjective.OA.MateriaID,
+		OAID:               oaBloomObjective.OAID,
+		MateriaNombre:      oaBloomObjective.OA.Materia.Nombre,
+		MateriaDescripcion: oaBloomObjective.OA.Materia.Descripcion,
+		CursoNombre:        oaBloomObjective.OA.Materia.Nombre, // TODO: Get actual curso
+		OATitulo:           oaBloomObjective.OA.Titulo,
+		OADescripcion:      oaBloomObjective.OA.Descripcion,
+		BloomLevelNombre:   oaBloomObjective.BloomLevel.Nombre,
+		BloomLevelNumero:   oaBloomObjective.BloomLevel.Nivel,
+		BloomDescripcion:   oaBloomObjective.BloomLevel.Descripcion,
+		ObjetivoEspecifico: oaBloomObjective.ObjetivoEspecifico,
+		IndicadoresLogro:   oaBloomObjective.IndicadoresLogro,
+	}
+
+	// Personalización según el perfil del estudiante (opcional)
+	var profile models.StudentProfile
+	if err := db.DB.Where("user_id = ?", userID).First(&profile).Error; err == nil {
+		var profileData models.ProfileDataStructure
+		if err := profile.ProfileData.Scan(&profileData); err == nil {
+			oaContext.InteresesPersonales = profileData.InteresesPersonales.Temas
+			oaContext.ProfesionSoñada = profileData.InteresesPersonales.ProfesionSoñada
+			oaContext.FormatoPreferido = profileData.PreferenciasAprendizaje.FormatoPreferido
+			oaContext.TipoActividad = profileData.PreferenciasAprendizaje.TipoActividad
+			oaContext.CanalPreferido = profileData.PreferenciasAprendizaje.CanalPreferido
+		}
+	}
+
+	oaContext.PreguntasPractica = countGuidedPracticeItems(oaBloomObjectiveID)
+
+	// Catálogo de conceptos erróneos para etiquetar los distractores (opcional)
+	if misconceptions, err := ListMisconceptions(oaContext.MateriaID, true); err == nil {
+		oaContext.ConceptosErroneos = misconceptions
+	}
+
+	return oaContext, nil
+}
+
+// CreatePlanFromStructure guarda el plan, sus componentes (pendientes) y sus checkpoints en una transacción.
+// before permite enlazar el plan a otra entidad (p. ej. el job) dentro de la misma transacción.
+func CreatePlanFromStructure(userID, oaBloomObjectiveID uint, structure *LearningPlanStructure, before func(tx *gorm.DB, plan *models.LearningPlan) error) (*models.LearningPlan, error) {
+	plan := models.LearningPlan{
+		UserID:             userID,
+		OABloomObjectiveID: oaBloomObjectiveID,
+		Estado:             models.LearningPlanEstadoGenerando,
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		applyStructureFields(&plan, structure)
+		if err := tx.Create(&plan).Error; err != nil {
+			return err
+		}
+		if err := createStructureComponents(tx, &plan, structure); err != nil {
+			return err
+		}
+		if before != nil {
+			return before(tx, &plan)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &plan, nil
+}
+
+// ReplacePlanStructure carga una nueva estructura en un plan existente sin componentes
+// (regeneración completa): actualiza título, descripción y tiempos y crea componentes y checkpoints.
+func ReplacePlanStructure(plan *models.LearningPlan, structure *LearningPlanStructure) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		applyStructureFields(plan, structure)
+		if err := tx.Model(plan).Updates(map[string]interface{}{
+			"titulo":                  plan.Titulo,
+			"descripcion":             plan.Descripcion,
+			"tiempo_estimado_minutos": plan.TiempoEstimadoMin,
+			"total_slides":            plan.TotalSlides,
+			"prompt_version":          plan.PromptVersion,
+			"fuentes":                 plan.Fuentes,
+		}).Error; err != nil {
+			return err
+		}
+		return createStructureComponents(tx, plan, structure)
+	})
+}
+
+func applyStructureFields(plan *models.LearningPlan, structure *LearningPlanStructure) {
+	totalTime := 0
+	for _, comp := range structure.Componentes {
+		totalTime += comp.TiempoEstimadoMin
+	}
+
+	plan.Titulo = structure.Titulo
+	plan.Descripcion = structure.Descripcion
+	plan.TiempoEstimadoMin = totalTime
+	plan.TotalSlides = len(structure.Componentes)
+	if structure.PromptVersion != "" {
+		plan.PromptVersion = &structure.PromptVersion
+	}
+	plan.Fuentes = marshalCitations(structure.Fuentes)
+}
+
+// createStructureComponents crea los componentes pendientes de la estructura y sus checkpoints
+func createStructureComponents(tx *gorm.DB, plan *models.LearningPlan, structure *LearningPlanStructure) error {
+	plan.Components = nil
+	for i, cs := range structure.Componentes {
+		component := models.LearningPlanComponent{
+			LearningPlanID:     plan.ID,
+			Orden:              i + 1,
+			TipoComponente:     cs.Tipo,
+			ObjetivoEspecifico: cs.ObjetivoEspecifico,
+			TiempoEstimadoMin:  cs.TiempoEstimadoMin,
+			Estado:             models.ComponentEstadoPendiente,
+		}
+		if err := tx.Create(&component).Error; err != nil {
+			return err
+		}
+		plan.Components = append(plan.Components, component)
+	}
+	return createPlanCheckpoints(tx, plan, structure)
+}
+
+// GeneratePendingComponents genera en paralelo el contenido de los componentes que aún no
+// están generados. onProgress se llama después de cada cambio de estado de un componente.
+// Retorna cuántos componentes quedaron generados.
+func GeneratePendingComponents(ctx context.Context, plan *models.LearningPlan, oaContext OAContext, onProgress func()) int {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	generated := 0
+	total := len(plan.Components)
+
+	log.Printf("🚀 Starting parallel generation of %d components...", total)
+
+	for i := range plan.Components {
+		if plan.Components[i].Estado == models.ComponentEstadoGenerado {
+			generated++
+			continue
+		}
+		// Los componentes en cuarentena esperan la revisión de un admin
+		if plan.Components[i].Estado == models.ComponentEstadoCuarentena {
+			continue
+		}
+
+		wg.Add(1)
+		go func(component *models.LearningPlanComponent) {
+			defer wg.Done()
+
+			component.Estado = models.ComponentEstadoGenerando
+			db.DB.Model(component).Update("estado", component.Estado)
+			onProgress()
+
+			log.Printf("⏳ [%d/%d] Generating content (%s)...", component.Orden, total, component.TipoComponente)
+
+			// En una regeneración se agrega el feedback que la motivó
+			componentContext := oaContext
+			componentContext.FeedbackEstudiante = RegenerationFeedback(plan, &component.ID)
+
+			content, promptVersion, err := GenerateComponentContent(ctx, component.TipoComponente, componentContext, component.ObjetivoEspecifico)
+			if QuarantineComponent(component, plan.UserID, err) {
+				onProgress()
+				return
+			}
+			if err != nil {
+				log.Printf("❌ [%d/%d] Error generating content: %v", component.Orden, total, err)
+				if err := MarkComponentError(component, err.Error()); err != nil {
+					log.Printf("⚠ Failed to save error of component %d: %v", component.ID, err)
+				}
+				onProgress()
+				return
+			}
+
+			if err := SaveGeneratedComponent(component, content, promptVersion); err != nil {
+				log.Printf("❌ [%d/%d] Failed to save content: %v", component.Orden, total, err)
+				onProgress()
+				return
+			}
+
+			log.Printf("✅ [%d/%d] Content generated successfully", component.Orden, total)
+
+			mu.Lock()
+			generated++
+			mu.Unlock()
+			onProgress()
+		}(&plan.Components[i])
+	}
+
+	wg.Wait()
+	return generated
+}
+
+// ClaimComponentGeneration pasa un componente pendiente o con error a "generando" de forma atómica.
+// Retorna false si otra petición ya lo reclamó o ya tiene contenido: solo quien lo reclama llama (y paga) al LLM.
+func ClaimComponentGeneration(componentID uint) (bool, error) {
+	result := db.DB.Model(&models.LearningPlanComponent{}).
+		Where("id = ? AND estado IN ?", componentID, []string{models.ComponentEstadoPendiente, models.ComponentEstadoError}).
+		Update("estado", models.ComponentEstadoGenerando)
+	return result.RowsAffected == 1, result.Error
+}
+
+// SaveGeneratedComponent guarda el contenido generado. Solo escribe las columnas del resultado para no pisar
+// cambios hechos mientras se generaba.
+func SaveGeneratedComponent(component *models.LearningPlanComponent, content map[string]interface{}, promptVersion string) error {
+	contentJSON, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	component.ContenidoProps = datatypes.JSON(contentJSON)
+	component.PromptVersion = &promptVersion
+	component.Estado = models.ComponentEstadoGenerado
+	component.ErrorMensaje = ""
+	return db.DB.Model(component).Updates(map[string]interface{}{
+		"contenido_props": component.ContenidoProps,
+		"prompt_version":  promptVersion,
+		"estado":          component.Estado,
+		"error_mensaje":   "",
+	}).Error
+}
+
+// MarkComponentError deja el componente en error para que se pueda volver a generar
+func MarkComponentError(component *models.LearningPlanComponent, mensaje string) error {
+	component.Estado = models.ComponentEstadoError
+	component.ErrorMensaje = mensaje
+	return db.DB.Model(component).Updates(map[string]interface{}{
+		"estado":        component.Estado,
+		"error_mensaje": mensaje,
+	}).Error
+}
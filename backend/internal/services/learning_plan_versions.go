@@ -0,0 +1,691 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// feedbackMaxLength es el largo máximo de un comentario del estudiante
+const feedbackMaxLength = 2000
+
+// ErrComponentNotFound indica que el componente no existe o no pertenece al plan
+var ErrComponentNotFound = errors.New("component not found")
+
+// ErrPlanBusy indica que el plan (o el componente) se está generando y no se puede modificar
+var ErrPlanBusy = errors.New("plan is being generated")
+
+// ErrPlanVersionNotFound indica que la versión pedida no existe
+var ErrPlanVersionNotFound = errors.New("plan version not found")
+
+// ErrInvalidPlanVersion indica que no se puede restaurar la versión pedida (p. ej. es la actual)
+var ErrInvalidPlanVersion = errors.New("invalid plan version")
+
+// ErrInvalidFeedback indica un feedback sin valoración ni comentario, o con valores no válidos
+var ErrInvalidFeedback = errors.New("invalid feedback")
+
+// FeedbackInput es la valoración (pulgar arriba/abajo) y/o comentario del estudiante.
+// Sin ComponentID el feedback es sobre el plan completo.
+type FeedbackInput struct {
+	ComponentID *uint  `json:"component_id,omitempty"`
+	Valoracion  string `json:"valoracion,omitempty"` // positiva | negativa
+	Comentario  string `json:"comentario,omitempty"`
+}
+
+// PlanVersionSummary describe una versión del plan en el listado
+type PlanVersionSummary struct {
+	Version     int       `json:"version"`
+	Actual      bool      `json:"actual"`
+	Motivo      string    `json:"motivo,omitempty"` // por qué fue reemplazada (vacío en la actual)
+	Nota        string    `json:"nota,omitempty"`
+	Titulo      string    `json:"titulo"`
+	Componentes int       `json:"componentes"`
+	CreatedAt   time.Time `json:"created_at"` // cuándo fue reemplazada; en la actual, la última actualización del plan
+}
+
+// PlanVersionDetail es una versión del plan con su contenido completo
+type PlanVersionDetail struct {
+	PlanVersionSummary
+	Snapshot models.LearningPlanSnapshot `json:"snapshot"`
+}
+
+// ComponentVersionDiff es la diferencia de un componente (identificado por su orden) entre dos versiones
+type ComponentVersionDiff struct {
+	Orden   int                       `json:"orden"`
+	Cambio  string                    `json:"cambio"` // igual | modificado | agregado | eliminado
+	Campos  []string                  `json:"campos,omitempty"`
+	Antes   *models.ComponentSnapshot `json:"antes,omitempty"`
+	Despues *models.ComponentSnapshot `json:"despues,omitempty"`
+}
+
+// PlanVersionComparison compara dos versiones de un plan
+type PlanVersionComparison struct {
+	From        int                    `json:"from"`
+	To          int                    `json:"to"`
+	CamposPlan  []string               `json:"campos_plan,omitempty"` // campos del plan que cambiaron
+	Componentes []ComponentVersionDiff `json:"componentes"`
+}
+
+// loadOwnedPlan carga un plan del usuario
+func loadOwnedPlan(tx *gorm.DB, userID, planID uint) (*models.LearningPlan, error) {
+	var plan models.LearningPlan
+	if err := tx.Where("id = ? AND user_id = ?", planID, userID).First(&plan).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPlanNotFound
+		}
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// ensurePlanIdle retorna ErrPlanBusy si el plan o alguno de sus componentes se está generando
+func ensurePlanIdle(tx *gorm.DB, plan *models.LearningPlan) error {
+	if plan.Estado == models.LearningPlanEstadoGenerando {
+		return ErrPlanBusy
+	}
+	var generating int64
+	if err := tx.Model(&models.LearningPlanComponent{}).
+		Where("learning_plan_id = ? AND estado = ?", plan.ID, models.ComponentEstadoGenerando).
+		Count(&generating).Error; err != nil {
+		return err
+	}
+	if generating > 0 {
+		return ErrPlanBusy
+	}
+	return nil
+}
+
+// buildPlanSnapshot arma el contenido actual del plan: sus campos, componentes y checkpoints
+func buildPlanSnapshot(tx *gorm.DB, plan *models.LearningPlan) (*models.LearningPlanSnapshot, error) {
+	var components []models.LearningPlanComponent
+	if err := tx.Where("learning_plan_id = ?", plan.ID).Order("orden ASC").Find(&components).Error; err != nil {
+		return nil, err
+	}
+	var checkpoints []models.LearningPlanCheckpoint
+	if err := tx.Where("learning_plan_id = ?", plan.ID).Find(&checkpoints).Error; err != nil {
+		return nil, err
+	}
+
+	snapshot := &models.LearningPlanSnapshot{
+		Titulo:            plan.Titulo,
+		Descripcion:       plan.Descripcion,
+		TiempoEstimadoMin: plan.TiempoEstimadoMin,
+		PromptVersion:     plan.PromptVersion,
+		Fuentes:           plan.Fuentes,
+	}
+	ordenByID := make(map[uint]int, len(components))
+	for _, c := range components {
+		ordenByID[c.ID] = c.Orden
+		snapshot.Components = append(snapshot.Components, models.ComponentSnapshot{
+			ID:                 c.ID,
+			Orden:              c.Orden,
+			TipoComponente:     c.TipoComponente,
+			ObjetivoEspecifico: c.ObjetivoEspecifico,
+			TiempoEstimadoMin:  c.TiempoEstimadoMin,
+			Estado:             c.Estado,
+			ContenidoProps:     c.ContenidoProps,
+			EsRemediacion:      c.EsRemediacion,
+			PromptVersion:      c.PromptVersion,
+		})
+	}
+	for _, cp := range checkpoints {
+		cs := models.CheckpointSnapshot{
+			ComponentOrden:       ordenByID[cp.ComponentID],
+			QuestionIDs:          cp.QuestionIDs,
+			PorcentajeAprobacion: cp.PorcentajeAprobacion,
+			Estado:               cp.Estado,
+			Intentos:             cp.Intentos,
+			MejorPorcentaje:      cp.MejorPorcentaje,
+		}
+		if cp.RemediationComponentID != nil {
+			if orden, ok := ordenByID[*cp.RemediationComponentID]; ok {
+				cs.RemediationOrden = &orden
+			}
+		}
+		snapshot.Checkpoints = append(snapshot.Checkpoints, cs)
+	}
+	return snapshot, nil
+}
+
+// archivePlanVersion guarda la versión actual del plan como snapshot y avanza plan.Version
+func archivePlanVersion(tx *gorm.DB, plan *models.LearningPlan, motivo, nota string) error {
+	snapshot, err := buildPlanSnapshot(tx, plan)
+	if err != nil {
+		return err
+	}
+	snapshotJSON, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	version := models.LearningPlanVersion{
+		LearningPlanID: plan.ID,
+		Version:        plan.Version,
+		Motivo:         motivo,
+		Nota:           nota,
+		Snapshot:       datatypes.JSON(snapshotJSON),
+	}
+	if err := tx.Create(&version).Error; err != nil {
+		return err
+	}
+
+	plan.Version++
+	return tx.Model(plan).Update("version", plan.Version).Error
+}
+
+// SubmitFeedback guarda la valoración y/o comentario del estudiante sobre un componente o el plan completo.
+// Se usa como contexto la próxima vez que se regenere ese componente o el plan.
+func SubmitFeedback(userID, planID uint, input FeedbackInput) (*models.LearningPlanFeedback, error) {
+	input.Comentario = strings.TrimSpace(input.Comentario)
+	if input.Valoracion == "" && input.Comentario == "" {
+		return nil, fmt.Errorf("%w: valoracion or comentario is required", ErrInvalidFeedback)
+	}
+	if input.Valoracion != "" && input.Valoracion != models.FeedbackValoracionPositiva && input.Valoracion != models.FeedbackValoracionNegativa {
+		return nil, fmt.Errorf("%w: valoracion must be %q or %q", ErrInvalidFeedback, models.FeedbackValoracionPositiva, models.FeedbackValoracionNegativa)
+	}
+	if len(input.Comentario) > feedbackMaxLength {
+		return nil, fmt.Errorf("%w: comentario longer than %d characters", ErrInvalidFeedback, feedbackMaxLength)
+	}
+
+	plan, err := loadOwnedPlan(db.DB, userID, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	feedback := models.LearningPlanFeedback{
+		LearningPlanID: plan.ID,
+		ComponentID:    input.ComponentID,
+		UserID:         userID,
+		Version:        plan.Version,
+		Comentario:     input.Comentario,
+	}
+	if input.Valoracion != "" {
+		feedback.Valoracion = &input.Valoracion
+	}
+	if input.ComponentID != nil {
+		var component models.LearningPlanComponent
+		if err := db.DB.Select("id", "tipo_componente").
+			Where("id = ? AND learning_plan_id = ?", *input.ComponentID, plan.ID).
+			First(&component).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrComponentNotFound
+			}
+			return nil, err
+		}
+		feedback.TipoComponente = component.TipoComponente
+	}
+
+	if err := db.DB.Create(&feedback).Error; err != nil {
+		return nil, err
+	}
+	return &feedback, nil
+}
+
+// ListPlanFeedback retorna el feedback del plan, del más reciente al más antiguo
+func ListPlanFeedback(userID, planID uint) ([]models.LearningPlanFeedback, error) {
+	plan, err := loadOwnedPlan(db.DB, userID, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	feedback := []models.LearningPlanFeedback{}
+	err = db.DB.Where("learning_plan_id = ?", plan.ID).Order("created_at DESC, id DESC").Find(&feedback).Error
+	return feedback, err
+}
+
+// RegenerationFeedback retorna, como notas para el prompt, el feedback que motivó la versión actual del plan.
+// Con componentID se limita al de ese componente y al del plan completo; sin él (estructura) incluye todo.
+func RegenerationFeedback(plan *models.LearningPlan, componentID *uint) []string {
+	if plan.Version <= 1 {
+		return nil
+	}
+
+	query := db.DB.Where("learning_plan_id = ? AND aplicada_en_version = ?", plan.ID, plan.Version)
+	if componentID != nil {
+		query = query.Where("component_id = ? OR component_id IS NULL", *componentID)
+	}
+	var feedback []models.LearningPlanFeedback
+	if err := query.Order("created_at ASC").Find(&feedback).Error; err != nil {
+		return nil
+	}
+
+	notes := make([]string, 0, len(feedback))
+	for _, f := range feedback {
+		subject := "Sobre el plan completo"
+		if f.ComponentID != nil {
+			subject = fmt.Sprintf("Sobre el componente %s", f.TipoComponente)
+		}
+		note := subject
+		if f.Valoracion != nil {
+			if *f.Valoracion == models.FeedbackValoracionPositiva {
+				note += " (le gustó)"
+			} else {
+				note += " (no le gustó)"
+			}
+		}
+		if f.Comentario != "" {
+			note += ": " + f.Comentario
+		}
+		notes = append(notes, note)
+	}
+	return notes
+}
+
+// RegenerateComponent guarda la versión actual del plan y deja el componente pendiente para que se vuelva a
+// generar (p. ej. con stream-content) usando el feedback que el estudiante dio sobre él.
+// El componente mantiene su ID, así que sus checkpoints y eventos se conservan.
+func RegenerateComponent(userID, planID, componentID uint, comentario string) (*models.LearningPlanComponent, error) {
+	comentario = strings.TrimSpace(comentario)
+	if len(comentario) > feedbackMaxLength {
+		return nil, fmt.Errorf("%w: comentario longer than %d characters", ErrInvalidFeedback, feedbackMaxLength)
+	}
+
+	var component models.LearningPlanComponent
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		plan, err := loadOwnedPlan(tx, userID, planID)
+		if err != nil {
+			return err
+		}
+		if err := ensurePlanIdle(tx, plan); err != nil {
+			return err
+		}
+		if err := tx.Where("id = ? AND learning_plan_id = ?", componentID, plan.ID).First(&component).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrComponentNotFound
+			}
+			return err
+		}
+
+		if comentario != "" {
+			negativa := models.FeedbackValoracionNegativa
+			if err := tx.Create(&models.LearningPlanFeedback{
+				LearningPlanID: plan.ID,
+				ComponentID:    &component.ID,
+				TipoComponente: component.TipoComponente,
+				UserID:         userID,
+				Version:        plan.Version,
+				Valoracion:     &negativa,
+				Comentario:     comentario,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		nota := fmt.Sprintf("componente %d (%s) regenerado", component.Orden, component.TipoComponente)
+		if err := archivePlanVersion(tx, plan, models.PlanVersionMotivoRegeneracionComponente, nota); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.LearningPlanFeedback{}).
+			Where("learning_plan_id = ? AND component_id = ? AND aplicada_en_version IS NULL", plan.ID, component.ID).
+			Update("aplicada_en_version", plan.Version).Error; err != nil {
+			return err
+		}
+
+		component.Estado = models.ComponentEstadoPendiente
+		component.ContenidoProps = nil
+		component.ErrorMensaje = ""
+		return tx.Model(&component).Updates(map[string]interface{}{
+			"estado":          component.Estado,
+			"contenido_props": nil,
+			"error_mensaje":   "",
+			"prompt_version":  nil,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &component, nil
+}
+
+// RegeneratePlan guarda la versión actual y vacía el plan (componentes, checkpoints y progreso) para que un
+// job genere una nueva estructura con todo el feedback pendiente. El llamador debe encolar el job.
+func RegeneratePlan(userID, planID uint, comentario string) (*models.LearningPlan, error) {
+	comentario = strings.TrimSpace(comentario)
+	if len(comentario) > feedbackMaxLength {
+		return nil, fmt.Errorf("%w: comentario longer than %d characters", ErrInvalidFeedback, feedbackMaxLength)
+	}
+
+	var plan *models.LearningPlan
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		plan, err = loadOwnedPlan(tx, userID, planID)
+		if err != nil {
+			return err
+		}
+		if err := ensurePlanIdle(tx, plan); err != nil {
+			return err
+		}
+
+		if comentario != "" {
+			if err := tx.Create(&models.LearningPlanFeedback{
+				LearningPlanID: plan.ID,
+				UserID:         userID,
+				Version:        plan.Version,
+				Comentario:     comentario,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := archivePlanVersion(tx, plan, models.PlanVersionMotivoRegeneracionPlan, ""); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.LearningPlanFeedback{}).
+			Where("learning_plan_id = ? AND aplicada_en_version IS NULL", plan.ID).
+			Update("aplicada_en_version", plan.Version).Error; err != nil {
+			return err
+		}
+
+		// Los checkpoints y los eventos de los componentes se borran en cascada
+		if err := tx.Where("learning_plan_id = ?", plan.ID).Delete(&models.LearningPlanComponent{}).Error; err != nil {
+			return err
+		}
+
+		plan.Estado = models.LearningPlanEstadoGenerando
+		plan.ErrorMensaje = ""
+		plan.TotalSlides = 0
+		plan.ProgresoActual = 0
+		plan.UltimoComponenteID = nil
+		plan.Completado = false
+		plan.FechaCompletado = nil
+		return tx.Model(plan).Updates(map[string]interface{}{
+			"estado":               plan.Estado,
+			"error_mensaje":        "",
+			"total_slides":         0,
+			"progreso_actual":      0,
+			"ultimo_componente_id": nil,
+			"completado":           false,
+			"fecha_completado":     nil,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// ListPlanVersions lista las versiones del plan, de la actual a la más antigua
+func ListPlanVersions(userID, planID uint) ([]PlanVersionSummary, error) {
+	plan, err := loadOwnedPlan(db.DB, userID, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	var components int64
+	if err := db.DB.Model(&models.LearningPlanComponent{}).Where("learning_plan_id = ?", plan.ID).Count(&components).Error; err != nil {
+		return nil, err
+	}
+	summaries := []PlanVersionSummary{{
+		Version:     plan.Version,
+		Actual:      true,
+		Titulo:      plan.Titulo,
+		Componentes: int(components),
+		CreatedAt:   plan.UpdatedAt,
+	}}
+
+	var versions []models.LearningPlanVersion
+	if err := db.DB.Where("learning_plan_id = ?", plan.ID).Order("version DESC").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		var snapshot models.LearningPlanSnapshot
+		json.Unmarshal(v.Snapshot, &snapshot)
+		summaries = append(summaries, versionSummary(v, &snapshot))
+	}
+	return summaries, nil
+}
+
+func versionSummary(v models.LearningPlanVersion, snapshot *models.LearningPlanSnapshot) PlanVersionSummary {
+	return PlanVersionSummary{
+		Version:     v.Version,
+		Motivo:      v.Motivo,
+		Nota:        v.Nota,
+		Titulo:      snapshot.Titulo,
+		Componentes: len(snapshot.Components),
+		CreatedAt:   v.CreatedAt,
+	}
+}
+
+// GetPlanVersion retorna una versión del plan con su contenido; la versión actual se arma desde las tablas
+func GetPlanVersion(userID, planID uint, version int) (*PlanVersionDetail, error) {
+	plan, err := loadOwnedPlan(db.DB, userID, planID)
+	if err != nil {
+		return nil, err
+	}
+	return loadPlanVersion(db.DB, plan, version)
+}
+
+func loadPlanVersion(tx *gorm.DB, plan *models.LearningPlan, version int) (*PlanVersionDetail, error) {
+	if version == plan.Version {
+		snapshot, err := buildPlanSnapshot(tx, plan)
+		if err != nil {
+			return nil, err
+		}
+		return &PlanVersionDetail{
+			PlanVersionSummary: PlanVersionSummary{
+				Version:     plan.Version,
+				Actual:      true,
+				Titulo:      plan.Titulo,
+				Componentes: len(snapshot.Components),
+				CreatedAt:   plan.UpdatedAt,
+			},
+			Snapshot: *snapshot,
+		}, nil
+	}
+
+	var stored models.LearningPlanVersion
+	if err := tx.Where("learning_plan_id = ? AND version = ?", plan.ID, version).First(&stored).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPlanVersionNotFound
+		}
+		return nil, err
+	}
+	var snapshot models.LearningPlanSnapshot
+	if err := json.Unmarshal(stored.Snapshot, &snapshot); err != nil {
+		return nil, fmt.Errorf("invalid snapshot of version %d: %w", version, err)
+	}
+	return &PlanVersionDetail{PlanVersionSummary: versionSummary(stored, &snapshot), Snapshot: snapshot}, nil
+}
+
+// ComparePlanVersions compara dos versiones del plan componente a componente (por orden).
+// to = 0 compara contra la versión actual.
+func ComparePlanVersions(userID, planID uint, from, to int) (*PlanVersionComparison, error) {
+	plan, err := loadOwnedPlan(db.DB, userID, planID)
+	if err != nil {
+		return nil, err
+	}
+	if to == 0 {
+		to = plan.Version
+	}
+
+	before, err := loadPlanVersion(db.DB, plan, from)
+	if err != nil {
+		return nil, err
+	}
+	after, err := loadPlanVersion(db.DB, plan, to)
+	if err != nil {
+		return nil, err
+	}
+
+	comparison := &PlanVersionComparison{From: from, To: to, Componentes: []ComponentVersionDiff{}}
+	if before.Snapshot.Titulo != after.Snapshot.Titulo {
+		comparison.CamposPlan = append(comparison.CamposPlan, "titulo")
+	}
+	if before.Snapshot.Descripcion != after.Snapshot.Descripcion {
+		comparison.CamposPlan = append(comparison.CamposPlan, "descripcion")
+	}
+	if before.Snapshot.TiempoEstimadoMin != after.Snapshot.TiempoEstimadoMin {
+		comparison.CamposPlan = append(comparison.CamposPlan, "tiempo_estimado_minutos")
+	}
+
+	byOrden := make(map[int]*models.ComponentSnapshot)
+	for i := range before.Snapshot.Components {
+		byOrden[before.Snapshot.Components[i].Orden] = &before.Snapshot.Components[i]
+	}
+	seen := make(map[int]bool)
+	for i := range after.Snapshot.Components {
+		current := &after.Snapshot.Components[i]
+		seen[current.Orden] = true
+		previous, ok := byOrden[current.Orden]
+		if !ok {
+			comparison.Componentes = append(comparison.Componentes, ComponentVersionDiff{Orden: current.Orden, Cambio: "agregado", Despues: current})
+			continue
+		}
+		campos := changedComponentFields(previous, current)
+		cambio := "igual"
+		if len(campos) > 0 {
+			cambio = "modificado"
+		}
+		comparison.Componentes = append(comparison.Componentes, ComponentVersionDiff{
+			Orden: current.Orden, Cambio: cambio, Campos: campos, Antes: previous, Despues: current,
+		})
+	}
+	for i := range before.Snapshot.Components {
+		previous := &before.Snapshot.Components[i]
+		if !seen[previous.Orden] {
+			comparison.Componentes = append(comparison.Componentes, ComponentVersionDiff{Orden: previous.Orden, Cambio: "eliminado", Antes: previous})
+		}
+	}
+	return comparison, nil
+}
+
+func changedComponentFields(a, b *models.ComponentSnapshot) []string {
+	var campos []string
+	if a.TipoComponente != b.TipoComponente {
+		campos = append(campos, "tipo_componente")
+	}
+	if a.ObjetivoEspecifico != b.ObjetivoEspecifico {
+		campos = append(campos, "objetivo_especifico")
+	}
+	if a.TiempoEstimadoMin != b.TiempoEstimadoMin {
+		campos = append(campos, "tiempo_estimado_minutos")
+	}
+	if a.EsRemediacion != b.EsRemediacion {
+		campos = append(campos, "es_remediacion")
+	}
+	// El contenido se compara ya decodificado para no depender del formato del JSON guardado
+	var contentA, contentB interface{}
+	json.Unmarshal(a.ContenidoProps, &contentA)
+	json.Unmarshal(b.ContenidoProps, &contentB)
+	if !reflect.DeepEqual(contentA, contentB) {
+		campos = append(campos, "contenido_props")
+	}
+	return campos
+}
+
+// RollbackPlan restaura una versión anterior del plan. La versión actual se guarda antes, así que el
+// rollback también se puede deshacer. Los componentes se recrean (con nuevos IDs) junto con sus
+// checkpoints en el estado que tenían; los eventos de visualización de los componentes reemplazados se pierden.
+func RollbackPlan(userID, planID uint, version int) (*models.LearningPlan, error) {
+	var plan *models.LearningPlan
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		plan, err = loadOwnedPlan(tx, userID, planID)
+		if err != nil {
+			return err
+		}
+		if version == plan.Version {
+			return fmt.Errorf("%w: version %d is the current version", ErrInvalidPlanVersion, version)
+		}
+		if err := ensurePlanIdle(tx, plan); err != nil {
+			return err
+		}
+
+		target, err := loadPlanVersion(tx, plan, version)
+		if err != nil {
+			return err
+		}
+		snapshot := target.Snapshot
+
+		nota := fmt.Sprintf("restaurada la versión %d", version)
+		if err := archivePlanVersion(tx, plan, models.PlanVersionMotivoRollback, nota); err != nil {
+			return err
+		}
+		if err := tx.Where("learning_plan_id = ?", plan.ID).Delete(&models.LearningPlanComponent{}).Error; err != nil {
+			return err
+		}
+
+		idByOrden := make(map[int]uint, len(snapshot.Components))
+		for _, cs := range snapshot.Components {
+			component := models.LearningPlanComponent{
+				LearningPlanID:     plan.ID,
+				Orden:              cs.Orden,
+				TipoComponente:     cs.TipoComponente,
+				ObjetivoEspecifico: cs.ObjetivoEspecifico,
+				TiempoEstimadoMin:  cs.TiempoEstimadoMin,
+				Estado:             models.ComponentEstadoPendiente,
+				EsRemediacion:      cs.EsRemediacion,
+			}
+			// Solo se restaura el contenido completo; lo que estaba a medias se vuelve a generar
+			if cs.Estado == models.ComponentEstadoGenerado && len(cs.ContenidoProps) > 0 {
+				component.Estado = models.ComponentEstadoGenerado
+				component.ContenidoProps = cs.ContenidoProps
+				component.PromptVersion = cs.PromptVersion
+			}
+			if err := tx.Create(&component).Error; err != nil {
+				return err
+			}
+			idByOrden[cs.Orden] = component.ID
+		}
+
+		for _, cs := range snapshot.Checkpoints {
+			componentID, ok := idByOrden[cs.ComponentOrden]
+			if !ok {
+				continue
+			}
+			checkpoint := models.LearningPlanCheckpoint{
+				LearningPlanID:       plan.ID,
+				ComponentID:          componentID,
+				QuestionIDs:          cs.QuestionIDs,
+				PorcentajeAprobacion: cs.PorcentajeAprobacion,
+				Estado:               cs.Estado,
+				Intentos:             cs.Intentos,
+				MejorPorcentaje:      cs.MejorPorcentaje,
+			}
+			if cs.RemediationOrden != nil {
+				if remediationID, ok := idByOrden[*cs.RemediationOrden]; ok {
+					checkpoint.RemediationComponentID = &remediationID
+				}
+			}
+			if err := tx.Create(&checkpoint).Error; err != nil {
+				return err
+			}
+		}
+
+		updates := map[string]interface{}{
+			"titulo":                  snapshot.Titulo,
+			"descripcion":             snapshot.Descripcion,
+			"tiempo_estimado_minutos": snapshot.TiempoEstimadoMin,
+			"total_slides":            len(snapshot.Components),
+			"prompt_version":          snapshot.PromptVersion,
+			"fuentes":                 snapshot.Fuentes,
+			"estado":                  models.LearningPlanEstadoGenerado,
+			"error_mensaje":           "",
+			"ultimo_componente_id":    nil,
+		}
+		// Un plan completado sigue completado; si no, el progreso parte de nuevo con los componentes restaurados
+		if !plan.Completado {
+			updates["progreso_actual"] = 0
+		}
+		if err := tx.Model(plan).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.Preload("Components", func(tx *gorm.DB) *gorm.DB {
+			return tx.Order("orden ASC")
+		}).Preload("Checkpoints").First(plan, plan.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
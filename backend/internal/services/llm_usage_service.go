@@ -0,0 +1,190 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"github.com/platanus-hack-25/lumera_app/pkg/llm"
+)
+
+// ErrLLMBudgetExceeded is returned when a user has spent their daily LLM budget
+var ErrLLMBudgetExceeded = errors.New("daily LLM budget exceeded")
+
+// defaultLLMDailyBudgetUSD applies when neither the user nor LLM_DAILY_BUDGET_USD set a budget
+const defaultLLMDailyBudgetUSD = 1.0
+
+// LLMUsageService handles LLM cost accounting, budgets and reports
+type LLMUsageService struct{}
+
+// NewLLMUsageService creates a new LLM usage service instance
+func NewLLMUsageService() *LLMUsageService {
+	return &LLMUsageService{}
+}
+
+// LLMBudgetStatus represents a user's LLM spend for the current day
+type LLMBudgetStatus struct {
+	UserID       uint    `json:"user_id"`
+	BudgetUSD    float64 `json:"budget_usd"` // 0 = unlimited
+	SpentUSD     float64 `json:"spent_usd"`
+	RemainingUSD float64 `json:"remaining_usd"`
+	Unlimited    bool    `json:"unlimited"`
+}
+
+// GetDailyBudget returns the user's daily budget in USD (0 = unlimited)
+func (s *LLMUsageService) GetDailyBudget(userID uint) (float64, error) {
+	var user models.User
+	if err := db.DB.Select("id", "llm_daily_budget_usd").First(&user, userID).Error; err != nil {
+		return 0, errors.New("user not found")
+	}
+	if user.LLMDailyBudgetUSD != nil {
+		return *user.LLMDailyBudgetUSD, nil
+	}
+
+	if value := os.Getenv("LLM_DAILY_BUDGET_USD"); value != "" {
+		if budget, err := strconv.ParseFloat(value, 64); err == nil {
+			return budget, nil
+		}
+	}
+	return defaultLLMDailyBudgetUSD, nil
+}
+
+// GetDailySpend returns the estimated cost of the user's LLM calls since midnight
+func (s *LLMUsageService) GetDailySpend(userID uint) (float64, error) {
+	now := time.Now()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var spent float64
+	err := db.DB.Model(&llm.CallRecord{}).
+		Select("COALESCE(SUM(cost_usd), 0)").
+		Where("user_id = ? AND created_at >= ?", userID, startOfDay).
+		Scan(&spent).Error
+	return spent, err
+}
+
+// GetBudgetStatus returns the user's budget, spend and remaining amount for today
+func (s *LLMUsageService) GetBudgetStatus(userID uint) (*LLMBudgetStatus, error) {
+	budget, err := s.GetDailyBudget(userID)
+	if err != nil {
+		return nil, err
+	}
+	spent, err := s.GetDailySpend(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &LLMBudgetStatus{
+		UserID:    userID,
+		BudgetUSD: budget,
+		SpentUSD:  spent,
+		Unlimited: budget <= 0,
+	}
+	if !status.Unlimited {
+		status.RemainingUSD = budget - spent
+		if status.RemainingUSD < 0 {
+			status.RemainingUSD = 0
+		}
+	}
+	return status, nil
+}
+
+// CheckBudget returns ErrLLMBudgetExceeded when the user has no budget left today
+func (s *LLMUsageService) CheckBudget(userID uint) error {
+	status, err := s.GetBudgetStatus(userID)
+	if err != nil {
+		return err
+	}
+	if !status.Unlimited && status.SpentUSD >= status.BudgetUSD {
+		return ErrLLMBudgetExceeded
+	}
+	return nil
+}
+
+// SetUserBudget sets the user's daily budget; nil restores the default
+func (s *LLMUsageService) SetUserBudget(userID uint, budget *float64) error {
+	if budget != nil && *budget < 0 {
+		return errors.New("budget must be >= 0")
+	}
+	result := db.DB.Model(&models.User{}).Where("id = ?", userID).Update("llm_daily_budget_usd", budget)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
+// LLMUsageRow is one aggregated row of the usage report
+type LLMUsageRow struct {
+	Group            string  `json:"group"`
+	Calls            int     `json:"calls"`
+	FailedCalls      int     `json:"failed_calls"`
+	Retries          int     `json:"retries"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+	AvgLatencyMs     float64 `json:"avg_latency_ms"`
+}
+
+// LLMUsageReport is the aggregated usage between two dates
+type LLMUsageReport struct {
+	From    time.Time     `json:"from"`
+	To      time.Time     `json:"to"`
+	GroupBy string        `json:"group_by"`
+	Rows    []LLMUsageRow `json:"rows"`
+	Total   LLMUsageRow   `json:"total"`
+}
+
+// llmUsageGroupColumns maps the allowed group_by values to SQL expressions
+var llmUsageGroupColumns = map[string]string{
+	"feature": "feature",
+	"model":   "model",
+	"user":    "COALESCE(CAST(user_id AS TEXT), 'system')",
+	"day":     "TO_CHAR(created_at, 'YYYY-MM-DD')",
+}
+
+// GetUsageReport aggregates llm_calls in [from, to) grouped by feature, model, user or day
+func (s *LLMUsageService) GetUsageReport(from, to time.Time, groupBy string) (*LLMUsageReport, error) {
+	groupExpr, ok := llmUsageGroupColumns[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("invalid group_by: %s", groupBy)
+	}
+
+	aggregates := `
+		COUNT(*) AS calls,
+		COUNT(*) FILTER (WHERE NOT success) AS failed_calls,
+		COALESCE(SUM(retry_count), 0) AS retries,
+		COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens,
+		COALESCE(SUM(completion_tokens), 0) AS completion_tokens,
+		COALESCE(SUM(total_tokens), 0) AS total_tokens,
+		COALESCE(SUM(cost_usd), 0) AS cost_usd,
+		COALESCE(AVG(latency_ms), 0) AS avg_latency_ms`
+
+	report := &LLMUsageReport{From: from, To: to, GroupBy: groupBy, Rows: []LLMUsageRow{}}
+
+	err := db.DB.Model(&llm.CallRecord{}).
+		Select(groupExpr+" AS \"group\","+aggregates).
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group(groupExpr).
+		Order("cost_usd DESC").
+		Scan(&report.Rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.DB.Model(&llm.CallRecord{}).
+		Select("'total' AS \"group\","+aggregates).
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Scan(&report.Total).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
@@ -0,0 +1,613 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrMisconceptionNotFound se retorna cuando el concepto erróneo no existe
+	ErrMisconceptionNotFound = errors.New("misconception not found")
+	// ErrInvalidMisconception se retorna por entradas del catálogo o etiquetas de distractores inválidas
+	ErrInvalidMisconception = errors.New("invalid misconception")
+)
+
+// misconceptionTagsKey es la clave de validation_data con las etiquetas por opción ({"B": ["FRAC-SUMA"]}).
+// Va en validation_data y no en question_data para no revelar al estudiante qué opciones son distractores.
+const misconceptionTagsKey = "conceptos_erroneos"
+
+// MisconceptionInput es el cuerpo para crear o editar una entrada del catálogo
+type MisconceptionInput struct {
+	MateriaID     uint   `json:"materia_id"`
+	Codigo        string `json:"codigo"`
+	Concepto      string `json:"concepto"`
+	ConfundidoCon string `json:"confundido_con"`
+	Descripcion   string `json:"descripcion,omitempty"`
+	Remediacion   string `json:"remediacion,omitempty"`
+	Activo        *bool  `json:"activo,omitempty"` // nil = activo al crear, sin cambios al editar
+}
+
+// MisconceptionFeedback es la retroalimentación de un concepto erróneo detrás de una respuesta
+type MisconceptionFeedback struct {
+	MisconceptionID uint   `json:"misconception_id"`
+	Codigo          string `json:"codigo"`
+	Concepto        string `json:"concepto"`
+	ConfundidoCon   string `json:"confundido_con"`
+	Remediacion     string `json:"remediacion,omitempty"`
+	Mensaje         string `json:"mensaje"` // "Probablemente confundes X con Y."
+}
+
+// SessionMisconception es un concepto erróneo observado en una sesión y cuántas veces apareció
+type SessionMisconception struct {
+	MisconceptionFeedback
+	Veces int `json:"veces"`
+}
+
+// MisconceptionDashboard es la frecuencia de conceptos erróneos de los estudiantes de un curso
+type MisconceptionDashboard struct {
+	CursoID           uint                        `json:"curso_id"`
+	Curso             string                      `json:"curso"`
+	MateriaID         *uint                       `json:"materia_id,omitempty"`
+	Desde             time.Time                   `json:"desde"`
+	Hasta             time.Time                   `json:"hasta"`
+	Estudiantes       int                         `json:"estudiantes"` // estudiantes del curso
+	Observaciones     int                         `json:"observaciones"`
+	ConceptosErroneos []MisconceptionDashboardRow `json:"conceptos_erroneos"`
+}
+
+// MisconceptionDashboardRow resume un concepto erróneo en el curso
+type MisconceptionDashboardRow struct {
+	MisconceptionID      uint                            `json:"misconception_id"`
+	MateriaID            uint                            `json:"materia_id"`
+	Materia              string                          `json:"materia"`
+	Codigo               string                          `json:"codigo"`
+	Concepto             string                          `json:"concepto"`
+	ConfundidoCon        string                          `json:"confundido_con"`
+	Observaciones        int                             `json:"observaciones"`
+	Estudiantes          int                             `json:"estudiantes"`
+	PorcentajeCurso      int                             `json:"porcentaje_curso"` // estudiantes afectados sobre el total del curso
+	Preguntas            []uint                          `json:"preguntas"`        // preguntas cuyos distractores lo revelaron
+	EstudiantesAfectados []MisconceptionDashboardStudent `json:"estudiantes_afectados"`
+}
+
+// MisconceptionDashboardStudent es un estudiante con el concepto erróneo
+type MisconceptionDashboardStudent struct {
+	UserID    uint      `json:"user_id"`
+	Nombre    string    `json:"nombre"`
+	Veces     int       `json:"veces"`
+	UltimaVez time.Time `json:"ultima_vez"`
+}
+
+// ListMisconceptions lista el catálogo de una materia (0 = todas), opcionalmente solo las entradas activas
+func ListMisconceptions(materiaID uint, soloActivos bool) ([]models.Misconception, error) {
+	query := db.DB.Model(&models.Misconception{}).Order("materia_id, codigo")
+	if materiaID != 0 {
+		query = query.Where("materia_id = ?", materiaID)
+	}
+	if soloActivos {
+		query = query.Where("activo = ?", true)
+	}
+	misconceptions := []models.Misconception{}
+	if err := query.Find(&misconceptions).Error; err != nil {
+		return nil, err
+	}
+	return misconceptions, nil
+}
+
+// CreateMisconception agrega una entrada al catálogo de la materia; el código es único por materia
+func CreateMisconception(userID uint, input MisconceptionInput) (*models.Misconception, error) {
+	input.Codigo = normalizeMisconceptionCode(input.Codigo)
+	if err := validateMisconceptionInput(input); err != nil {
+		return nil, err
+	}
+	if err := db.DB.First(&models.Materia{}, input.MateriaID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMateriaNotFound
+		}
+		return nil, err
+	}
+	var count int64
+	err := db.DB.Model(&models.Misconception{}).
+		Where("materia_id = ? AND codigo = ?", input.MateriaID, input.Codigo).
+		Count(&count).Error
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("%w: el código %s ya existe en la materia", ErrInvalidMisconception, input.Codigo)
+	}
+
+	misconception := models.Misconception{
+		MateriaID: input.MateriaID,
+		Codigo:    input.Codigo,
+		Activo:    true,
+		CreadoPor: &userID,
+	}
+	applyMisconceptionInput(&misconception, input)
+	if err := db.DB.Create(&misconception).Error; err != nil {
+		return nil, err
+	}
+	return &misconception, nil
+}
+
+// UpdateMisconception edita los textos y el estado de una entrada. La materia y el código no cambian
+// porque las preguntas ya etiquetadas los referencian: para reemplazarlos se desactiva y se crea otra.
+func UpdateMisconception(id uint, input MisconceptionInput) (*models.Misconception, error) {
+	var misconception models.Misconception
+	if err := db.DB.First(&misconception, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMisconceptionNotFound
+		}
+		return nil, err
+	}
+
+	input.Codigo = normalizeMisconceptionCode(input.Codigo)
+	if input.MateriaID == 0 {
+		input.MateriaID = misconception.MateriaID
+	}
+	if input.Codigo == "" {
+		input.Codigo = misconception.Codigo
+	}
+	if input.MateriaID != misconception.MateriaID || input.Codigo != misconception.Codigo {
+		return nil, fmt.Errorf("%w: la materia y el código no se pueden cambiar; desactiva la entrada y crea una nueva", ErrInvalidMisconception)
+	}
+	if err := validateMisconceptionInput(input); err != nil {
+		return nil, err
+	}
+
+	applyMisconceptionInput(&misconception, input)
+	if err := db.DB.Save(&misconception).Error; err != nil {
+		return nil, err
+	}
+	return &misconception, nil
+}
+
+func normalizeMisconceptionCode(codigo string) string {
+	return strings.ToUpper(strings.TrimSpace(codigo))
+}
+
+func validateMisconceptionInput(input MisconceptionInput) error {
+	switch {
+	case input.MateriaID == 0:
+		return fmt.Errorf("%w: materia_id es obligatorio", ErrInvalidMisconception)
+	case input.Codigo == "":
+		return fmt.Errorf("%w: codigo es obligatorio", ErrInvalidMisconception)
+	case len(input.Codigo) > 50 || strings.ContainsAny(input.Codigo, " \t\n"):
+		return fmt.Errorf("%w: el código debe tener hasta 50 caracteres y no llevar espacios", ErrInvalidMisconception)
+	case strings.TrimSpace(input.Concepto) == "" || strings.TrimSpace(input.ConfundidoCon) == "":
+		return fmt.Errorf("%w: concepto y confundido_con son obligatorios", ErrInvalidMisconception)
+	}
+	return nil
+}
+
+func applyMisconceptionInput(misconception *models.Misconception, input MisconceptionInput) {
+	misconception.Concepto = strings.TrimSpace(input.Concepto)
+	misconception.ConfundidoCon = strings.TrimSpace(input.ConfundidoCon)
+	misconception.Descripcion = strings.TrimSpace(input.Descripcion)
+	misconception.Remediacion = strings.TrimSpace(input.Remediacion)
+	if input.Activo != nil {
+		misconception.Activo = *input.Activo
+	}
+}
+
+// questionMisconceptionTags lee las etiquetas de los distractores de validation_data (nil si no tiene)
+func questionMisconceptionTags(question *models.Question) (map[string][]string, error) {
+	var validation map[string]json.RawMessage
+	if err := json.Unmarshal(question.ValidationData, &validation); err != nil {
+		return nil, err
+	}
+	raw, ok := validation[misconceptionTagsKey]
+	if !ok || string(raw) == "null" {
+		return nil, nil
+	}
+	var tags map[string][]string
+	if err := json.Unmarshal(raw, &tags); err != nil {
+		return nil, fmt.Errorf("%w: %s debe ser un objeto de opción a lista de códigos", ErrInvalidMisconception, misconceptionTagsKey)
+	}
+	return tags, nil
+}
+
+// questionMateriaID resuelve la materia de la pregunta por su objetivo OA-Bloom
+func questionMateriaID(question *models.Question) (uint, error) {
+	var materiaIDs []uint
+	err := db.DB.Table("oa_bloom_objectives").
+		Joins("JOIN objetivos_aprendizaje ON objetivos_aprendizaje.id = oa_bloom_objectives.oa_id").
+		Where("oa_bloom_objectives.id = ?", question.OABloomObjectiveID).
+		Pluck("objetivos_aprendizaje.materia_id", &materiaIDs).Error
+	if err != nil {
+		return 0, err
+	}
+	if len(materiaIDs) == 0 {
+		return 0, fmt.Errorf("%w: el objetivo OA-Bloom %d no existe", ErrInvalidMisconception, question.OABloomObjectiveID)
+	}
+	return materiaIDs[0], nil
+}
+
+// ValidateQuestionMisconceptions revisa validation_data.conceptos_erroneos: solo preguntas multiple_choice,
+// cada letra debe ser una opción existente distinta de la correcta y cada código una entrada activa del
+// catálogo de la materia de la pregunta.
+func ValidateQuestionMisconceptions(question *models.Question) error {
+	tags, err := questionMisconceptionTags(question)
+	if err != nil || len(tags) == 0 {
+		return err
+	}
+	if question.Tipo != "multiple_choice" {
+		return fmt.Errorf("%w: solo las preguntas multiple_choice llevan %s", ErrInvalidMisconception, misconceptionTagsKey)
+	}
+
+	var data struct {
+		Opciones map[string]interface{} `json:"opciones"`
+	}
+	if err := json.Unmarshal(question.QuestionData, &data); err != nil {
+		return err
+	}
+	var validation struct {
+		RespuestaCorrecta interface{} `json:"respuesta_correcta"`
+	}
+	if err := json.Unmarshal(question.ValidationData, &validation); err != nil {
+		return err
+	}
+	correct := fmt.Sprint(validation.RespuestaCorrecta)
+
+	codes := make(map[string]bool)
+	for letter, letterCodes := range tags {
+		if _, ok := data.Opciones[letter]; !ok {
+			return fmt.Errorf("%w: la opción %s no existe", ErrInvalidMisconception, letter)
+		}
+		if letter == correct {
+			return fmt.Errorf("%w: la opción correcta %s no puede revelar un concepto erróneo", ErrInvalidMisconception, letter)
+		}
+		if len(letterCodes) == 0 {
+			return fmt.Errorf("%w: la opción %s no tiene códigos", ErrInvalidMisconception, letter)
+		}
+		for _, code := range letterCodes {
+			codes[code] = true
+		}
+	}
+
+	materiaID, err := questionMateriaID(question)
+	if err != nil {
+		return err
+	}
+	known, err := activeMisconceptionsByCode(materiaID)
+	if err != nil {
+		return err
+	}
+	var unknown []string
+	for code := range codes {
+		if _, ok := known[code]; !ok {
+			unknown = append(unknown, code)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("%w: códigos que no están activos en el catálogo de la materia: %s", ErrInvalidMisconception, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// activeMisconceptionsByCode indexa por código el catálogo activo de una materia
+func activeMisconceptionsByCode(materiaID uint) (map[string]models.Misconception, error) {
+	misconceptions, err := ListMisconceptions(materiaID, true)
+	if err != nil {
+		return nil, err
+	}
+	byCode := make(map[string]models.Misconception, len(misconceptions))
+	for _, misconception := range misconceptions {
+		byCode[misconception.Codigo] = misconception
+	}
+	return byCode, nil
+}
+
+// ObserveMisconceptions registra los conceptos erróneos del distractor elegido en una respuesta incorrecta
+// de opción múltiple y retorna la retroalimentación para el estudiante. Los códigos que ya no están activos
+// en el catálogo se ignoran.
+func ObserveMisconceptions(userID uint, origen string, sessionID uint, question *models.Question, userAnswer datatypes.JSON, isCorrect bool) ([]MisconceptionFeedback, error) {
+	if isCorrect || question.Tipo != "multiple_choice" {
+		return nil, nil
+	}
+	tags, err := questionMisconceptionTags(question)
+	if err != nil || len(tags) == 0 {
+		return nil, err
+	}
+	option, err := question.SelectedOption(userAnswer)
+	if err != nil {
+		return nil, err
+	}
+	codes := tags[option]
+	if len(codes) == 0 {
+		return nil, nil
+	}
+
+	materiaID, err := questionMateriaID(question)
+	if err != nil {
+		return nil, err
+	}
+	var misconceptions []models.Misconception
+	err = db.DB.Where("materia_id = ? AND codigo IN ? AND activo = ?", materiaID, codes, true).
+		Order("codigo").
+		Find(&misconceptions).Error
+	if err != nil || len(misconceptions) == 0 {
+		return nil, err
+	}
+
+	observations := make([]models.MisconceptionObservation, len(misconceptions))
+	feedback := make([]MisconceptionFeedback, len(misconceptions))
+	for i, misconception := range misconceptions {
+		observations[i] = models.MisconceptionObservation{
+			MisconceptionID: misconception.ID,
+			UserID:          userID,
+			QuestionID:      question.ID,
+			Opcion:          option,
+			Origen:          origen,
+			SessionID:       sessionID,
+		}
+		feedback[i] = misconceptionFeedback(misconception)
+	}
+	if err := db.DB.Create(&observations).Error; err != nil {
+		return nil, err
+	}
+	return feedback, nil
+}
+
+func misconceptionFeedback(misconception models.Misconception) MisconceptionFeedback {
+	return MisconceptionFeedback{
+		MisconceptionID: misconception.ID,
+		Codigo:          misconception.Codigo,
+		Concepto:        misconception.Concepto,
+		ConfundidoCon:   misconception.ConfundidoCon,
+		Remediacion:     misconception.Remediacion,
+		Mensaje:         fmt.Sprintf("Probablemente confundes %s con %s.", misconception.Concepto, misconception.ConfundidoCon),
+	}
+}
+
+// SessionMisconceptions resume los conceptos erróneos observados en una sesión de práctica o diagnóstico,
+// del más frecuente al menos frecuente
+func SessionMisconceptions(origen string, sessionID uint) ([]SessionMisconception, error) {
+	var counts []struct {
+		MisconceptionID uint
+		Veces           int
+	}
+	err := db.DB.Model(&models.MisconceptionObservation{}).
+		Select("misconception_id, COUNT(*) AS veces").
+		Where("origen = ? AND session_id = ?", origen, sessionID).
+		Group("misconception_id").
+		Scan(&counts).Error
+	if err != nil || len(counts) == 0 {
+		return nil, err
+	}
+
+	ids := make([]uint, len(counts))
+	for i, count := range counts {
+		ids[i] = count.MisconceptionID
+	}
+	var misconceptions []models.Misconception
+	if err := db.DB.Where("id IN ?", ids).Find(&misconceptions).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.Misconception, len(misconceptions))
+	for _, misconception := range misconceptions {
+		byID[misconception.ID] = misconception
+	}
+
+	summary := make([]SessionMisconception, 0, len(counts))
+	for _, count := range counts {
+		if misconception, ok := byID[count.MisconceptionID]; ok {
+			summary = append(summary, SessionMisconception{MisconceptionFeedback: misconceptionFeedback(misconception), Veces: count.Veces})
+		}
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		if summary[i].Veces != summary[j].Veces {
+			return summary[i].Veces > summary[j].Veces
+		}
+		return summary[i].Codigo < summary[j].Codigo
+	})
+	return summary, nil
+}
+
+// GetMisconceptionDashboard cuenta los conceptos erróneos observados en [from, to) entre los estudiantes
+// del curso, opcionalmente de una materia. Los conceptos que afectan a más estudiantes van primero.
+func GetMisconceptionDashboard(cursoID uint, materiaID *uint, from, to time.Time) (*MisconceptionDashboard, error) {
+	curso, userIDs, err := classroomStudents(cursoID)
+	if err != nil {
+		return nil, err
+	}
+	dashboard := &MisconceptionDashboard{
+		CursoID:           curso.ID,
+		Curso:             curso.Nombre,
+		MateriaID:         materiaID,
+		Desde:             from,
+		Hasta:             to,
+		Estudiantes:       len(userIDs),
+		ConceptosErroneos: []MisconceptionDashboardRow{},
+	}
+	if len(userIDs) == 0 {
+		return dashboard, nil
+	}
+
+	query := db.DB.Where("user_id IN ? AND created_at >= ? AND created_at < ?", userIDs, from, to)
+	if materiaID != nil {
+		query = query.Where("misconception_id IN (?)",
+			db.DB.Model(&models.Misconception{}).Select("id").Where("materia_id = ?", *materiaID))
+	}
+	var observations []models.MisconceptionObservation
+	if err := query.Order("created_at").Find(&observations).Error; err != nil {
+		return nil, err
+	}
+	if len(observations) == 0 {
+		return dashboard, nil
+	}
+
+	misconceptionIDs := make(map[uint]bool)
+	studentIDs := make(map[uint]bool)
+	for _, observation := range observations {
+		misconceptionIDs[observation.MisconceptionID] = true
+		studentIDs[observation.UserID] = true
+	}
+	var misconceptions []models.Misconception
+	if err := db.DB.Where("id IN ?", sortedIDs(misconceptionIDs)).Find(&misconceptions).Error; err != nil {
+		return nil, err
+	}
+	materiaIDs := make(map[uint]bool)
+	for _, misconception := range misconceptions {
+		materiaIDs[misconception.MateriaID] = true
+	}
+	var materias []models.Materia
+	if err := db.DB.Where("id IN ?", sortedIDs(materiaIDs)).Find(&materias).Error; err != nil {
+		return nil, err
+	}
+	materiaNames := make(map[uint]string, len(materias))
+	for _, materia := range materias {
+		materiaNames[materia.ID] = materia.Nombre
+	}
+	var users []models.User
+	if err := db.DB.Where("id IN ?", sortedIDs(studentIDs)).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	userNames := make(map[uint]string, len(users))
+	for _, user := range users {
+		userNames[user.ID] = user.Name
+	}
+
+	rows := make(map[uint]*MisconceptionDashboardRow, len(misconceptions))
+	for _, misconception := range misconceptions {
+		rows[misconception.ID] = &MisconceptionDashboardRow{
+			MisconceptionID: misconception.ID,
+			MateriaID:       misconception.MateriaID,
+			Materia:         materiaNames[misconception.MateriaID],
+			Codigo:          misconception.Codigo,
+			Concepto:        misconception.Concepto,
+			ConfundidoCon:   misconception.ConfundidoCon,
+		}
+	}
+	students := make(map[uint]map[uint]*MisconceptionDashboardStudent)
+	questions := make(map[uint]map[uint]bool)
+	for _, observation := range observations {
+		row, ok := rows[observation.MisconceptionID]
+		if !ok {
+			continue
+		}
+		row.Observaciones++
+		dashboard.Observaciones++
+		if students[row.MisconceptionID] == nil {
+			students[row.MisconceptionID] = make(map[uint]*MisconceptionDashboardStudent)
+			questions[row.MisconceptionID] = make(map[uint]bool)
+		}
+		questions[row.MisconceptionID][observation.QuestionID] = true
+		student, ok := students[row.MisconceptionID][observation.UserID]
+		if !ok {
+			student = &MisconceptionDashboardStudent{UserID: observation.UserID, Nombre: userNames[observation.UserID]}
+			students[row.MisconceptionID][observation.UserID] = student
+		}
+		student.Veces++
+		student.UltimaVez = observation.CreatedAt // las observaciones vienen en orden cronológico
+	}
+
+	for id, row := range rows {
+		for _, student := range students[id] {
+			row.EstudiantesAfectados = append(row.EstudiantesAfectados, *student)
+		}
+		sort.Slice(row.EstudiantesAfectados, func(i, j int) bool {
+			a, b := row.EstudiantesAfectados[i], row.EstudiantesAfectados[j]
+			if a.Veces != b.Veces {
+				return a.Veces > b.Veces
+			}
+			return a.Nombre < b.Nombre
+		})
+		row.Estudiantes = len(row.EstudiantesAfectados)
+		row.PorcentajeCurso = row.Estudiantes * 100 / len(userIDs)
+		row.Preguntas = sortedIDs(questions[id])
+		dashboard.ConceptosErroneos = append(dashboard.ConceptosErroneos, *row)
+	}
+	sort.Slice(dashboard.ConceptosErroneos, func(i, j int) bool {
+		a, b := dashboard.ConceptosErroneos[i], dashboard.ConceptosErroneos[j]
+		if a.Estudiantes != b.Estudiantes {
+			return a.Estudiantes > b.Estudiantes
+		}
+		if a.Observaciones != b.Observaciones {
+			return a.Observaciones > b.Observaciones
+		}
+		return a.Codigo < b.Codigo
+	})
+	return dashboard, nil
+}
+
+// sortedIDs retorna los IDs de un conjunto en orden ascendente
+func sortedIDs(set map[uint]bool) []uint {
+	ids := make([]uint, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// tagGeneratedMisconceptions depura las etiquetas conceptos_erroneos que el modelo puso en los distractores de
+// las preguntas generadas: solo quedan opciones incorrectas existentes y códigos del catálogo de la materia.
+// Cada distractor etiquetado recibe en retroalimentacion el mensaje que se muestra al elegirlo.
+func tagGeneratedMisconceptions(catalog []models.Misconception, content map[string]interface{}) {
+	byCode := make(map[string]models.Misconception, len(catalog))
+	for _, misconception := range catalog {
+		byCode[misconception.Codigo] = misconception
+	}
+
+	preguntas, _ := content["preguntas"].([]interface{})
+	for _, item := range preguntas {
+		pregunta, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		raw, _ := pregunta[misconceptionTagsKey].(map[string]interface{})
+		delete(pregunta, misconceptionTagsKey)
+		opciones, _ := pregunta["opciones"].(map[string]interface{})
+		respuesta, _ := pregunta["respuesta_correcta"].(string)
+
+		tags := make(map[string][]string)
+		feedback := make(map[string]string)
+		for letter, value := range raw {
+			if _, ok := opciones[letter]; !ok || letter == respuesta {
+				continue
+			}
+			// El modelo a veces entrega un solo código como texto en vez de una lista
+			var codes []string
+			switch typed := value.(type) {
+			case string:
+				codes = []string{typed}
+			case []interface{}:
+				for _, code := range typed {
+					if text, ok := code.(string); ok {
+						codes = append(codes, text)
+					}
+				}
+			}
+
+			seen := make(map[string]bool)
+			var messages []string
+			for _, code := range codes {
+				misconception, ok := byCode[normalizeMisconceptionCode(code)]
+				if !ok || seen[misconception.Codigo] {
+					continue
+				}
+				seen[misconception.Codigo] = true
+				tags[letter] = append(tags[letter], misconception.Codigo)
+				messages = append(messages, misconceptionFeedback(misconception).Mensaje)
+			}
+			if len(messages) > 0 {
+				feedback[letter] = strings.Join(messages, " ")
+			}
+		}
+		if len(tags) > 0 {
+			pregunta[misconceptionTagsKey] = tags
+			pregunta["retroalimentacion"] = feedback
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"github.com/platanus-hack-25/lumera_app/pkg/llm"
+	"github.com/platanus-hack-25/lumera_app/pkg/moderation"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// moderator revisa el contenido generado y los textos de los estudiantes (nil = moderación desactivada)
+var moderator moderation.Classifier
+
+// ErrInputFlagged indica que el texto del estudiante fue bloqueado por la moderación
+var ErrInputFlagged = errors.New("input flagged by moderation")
+
+// ErrContentFlagged indica que el contenido generado fue marcado por la moderación
+var ErrContentFlagged = errors.New("generated content flagged by moderation")
+
+// ErrModerationUnavailable indica que el clasificador no respondió. El contenido generado no se muestra sin revisar.
+var ErrModerationUnavailable = errors.New("moderation unavailable")
+
+// ErrModerationFlagNotFound indica que el registro de moderación no existe
+var ErrModerationFlagNotFound = errors.New("moderation flag not found")
+
+// ErrModerationFlagReviewed indica que el registro ya fue revisado
+var ErrModerationFlagReviewed = errors.New("moderation flag already reviewed")
+
+// ErrInvalidModerationReview indica una decisión de revisión inválida
+var ErrInvalidModerationReview = errors.New("invalid moderation review")
+
+// maxModerationTextLength es el largo máximo del texto que se guarda en moderation_flags
+const maxModerationTextLength = 4000
+
+// Decisiones de revisión
+const (
+	ModerationDecisionAprobar  = "aprobar"
+	ModerationDecisionRechazar = "rechazar"
+)
+
+// FlaggedContentError lleva el veredicto y el contenido generado que quedó en cuarentena
+type FlaggedContentError struct {
+	Result  *moderation.Result
+	Content map[string]interface{}
+}
+
+func (e *FlaggedContentError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrContentFlagged, e.Result.Reason)
+}
+
+func (e *FlaggedContentError) Unwrap() error {
+	return ErrContentFlagged
+}
+
+// ModerationReviewInput es la decisión de un admin sobre un registro de moderación
+type ModerationReviewInput struct {
+	Decision string `json:"decision"` // aprobar | rechazar
+	Nota     string `json:"nota,omitempty"`
+}
+
+// InitModeration configura el clasificador según MODERATION_MODE (auto, local, openai u off)
+func InitModeration() error {
+	classifier, err := moderation.NewFromEnv()
+	if err != nil {
+		return err
+	}
+	moderator = classifier
+	if classifier == nil {
+		log.Println("⚠ Moderation disabled (MODERATION_MODE=off)")
+		return nil
+	}
+	log.Printf("✓ Moderation initialized (%s)", classifier.Name())
+	return nil
+}
+
+// SetModerator reemplaza el clasificador (p. ej. con un moderation.BlocklistClassifier en tests)
+func SetModerator(classifier moderation.Classifier) {
+	moderator = classifier
+}
+
+// classifyText retorna el veredicto si el texto fue marcado, o ErrModerationUnavailable si el clasificador
+// falla (la cadena auto solo falla si tampoco responde el blocklist local; MODERATION_MODE=openai no lo tiene)
+func classifyText(ctx context.Context, text string) (*moderation.Result, error) {
+	if moderator == nil || strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	result, err := moderator.Classify(ctx, text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrModerationUnavailable, err)
+	}
+	if !result.Flagged {
+		return nil, nil
+	}
+	return result, nil
+}
+
+// moderateGeneratedContent revisa todo el texto de un contenido generado. Si la moderación no responde
+// retorna ErrModerationUnavailable: el componente queda en error y se puede volver a generar.
+func moderateGeneratedContent(ctx context.Context, content map[string]interface{}) error {
+	result, err := classifyText(ctx, moderation.CollectText(content))
+	if err != nil {
+		return err
+	}
+	if result != nil {
+		return &FlaggedContentError{Result: result, Content: content}
+	}
+	return nil
+}
+
+// moderatedStreamEvents envuelve onEvent para no emitir piezas marcadas por la moderación.
+// Desde la primera pieza marcada (o que no se pudo revisar) no se emite nada más; la revisión del contenido
+// completo decide la cuarentena.
+func moderatedStreamEvents(ctx context.Context, onEvent func(ContentStreamEvent)) func(ContentStreamEvent) {
+	blocked := false
+	return func(event ContentStreamEvent) {
+		if event.Tipo == ContentStreamEventReintento {
+			blocked = false
+			onEvent(event)
+			return
+		}
+		if blocked {
+			return
+		}
+		text := event.Titulo + "\n" + event.Valor
+		if event.Bloque != nil {
+			text = moderation.CollectText(event.Bloque)
+		}
+		if result, err := classifyText(ctx, text); err != nil || result != nil {
+			blocked = true
+			return
+		}
+		onEvent(event)
+	}
+}
+
+// truncateModerationText corta el texto guardado sin partir un carácter
+func truncateModerationText(text string) string {
+	if len(text) <= maxModerationTextLength {
+		return text
+	}
+	cut := maxModerationTextLength
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "…"
+}
+
+// newModerationFlag arma el registro de un texto marcado
+func newModerationFlag(origen, entidadTipo string, entidadID, userID *uint, text string, result *moderation.Result) models.ModerationFlag {
+	return models.ModerationFlag{
+		Origen:       origen,
+		EntidadTipo:  entidadTipo,
+		EntidadID:    entidadID,
+		UserID:       userID,
+		Texto:        truncateModerationText(text),
+		Categorias:   result.Categories,
+		Motivo:       result.Reason,
+		Clasificador: result.Classifier,
+		Estado:       models.ModerationEstadoPendiente,
+	}
+}
+
+// ModerateStudentInput revisa un texto escrito por el estudiante antes de guardarlo o enviarlo al modelo.
+// Si se marca queda registrado para revisión y se retorna ErrInputFlagged. Si la moderación no responde el
+// texto pasa: no llega a otros estudiantes y lo que el modelo genere con él sí se revisa.
+func ModerateStudentInput(ctx context.Context, userID uint, entidadTipo string, entidadID *uint, text string) error {
+	result, err := classifyText(ctx, text)
+	if err != nil {
+		log.Printf("⚠ Moderation failed, allowing student input: %v", err)
+		return nil
+	}
+	if result == nil {
+		return nil
+	}
+
+	flag := newModerationFlag(models.ModerationOrigenEntrada, entidadTipo, entidadID, &userID, text, result)
+	if err := db.DB.Create(&flag).Error; err != nil {
+		log.Printf("⚠ Failed to record moderation flag: %v", err)
+	}
+	log.Printf("🚫 Student input blocked by moderation (user %d, %s): %s", userID, entidadTipo, result.Reason)
+	return fmt.Errorf("%w: %s", ErrInputFlagged, strings.Join(result.Categories, ", "))
+}
+
+// QuarantineComponent deja el componente en cuarentena si err es contenido marcado por la moderación:
+// el contenido se guarda en moderation_flags y el componente queda sin contenido hasta la revisión.
+// Retorna false si err es otro error.
+func QuarantineComponent(component *models.LearningPlanComponent, userID uint, err error) bool {
+	var flagged *FlaggedContentError
+	if !errors.As(err, &flagged) {
+		return false
+	}
+
+	contentJSON, _ := json.Marshal(flagged.Content)
+	flag := newModerationFlag(models.ModerationOrigenContenido, models.ModerationEntidadComponente, &component.ID, &userID,
+		moderation.CollectText(flagged.Content), flagged.Result)
+	flag.Contenido = datatypes.JSON(contentJSON)
+
+	component.Estado = models.ComponentEstadoCuarentena
+	component.ContenidoProps = nil
+	component.ErrorMensaje = "Contenido en revisión"
+
+	txErr := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&flag).Error; err != nil {
+			return err
+		}
+		return tx.Model(component).Updates(map[string]interface{}{
+			"estado":          component.Estado,
+			"contenido_props": nil,
+			"error_mensaje":   component.ErrorMensaje,
+		}).Error
+	})
+	if txErr != nil {
+		log.Printf("⚠ Failed to quarantine component %d: %v", component.ID, txErr)
+	}
+	log.Printf("🚫 Component %d quarantined by moderation: %s", component.ID, flagged.Result.Reason)
+	return true
+}
+
+// recordPersonalizationFlag registra ejemplos personalizados descartados por la moderación
+// (el estudiante recibe el contenido genérico, que ya estaba revisado)
+func recordPersonalizationFlag(ctx context.Context, flagged *FlaggedContentError) {
+	contentJSON, _ := json.Marshal(flagged.Content)
+	flag := newModerationFlag(models.ModerationOrigenContenido, models.ModerationEntidadPersonalizacion, nil,
+		llm.TagsFromContext(ctx).UserID, moderation.CollectText(flagged.Content), flagged.Result)
+	flag.Contenido = datatypes.JSON(contentJSON)
+	if err := db.DB.Create(&flag).Error; err != nil {
+		log.Printf("⚠ Failed to record moderation flag: %v", err)
+	}
+}
+
+// ListModerationFlags lista los registros de moderación, del más reciente al más antiguo.
+// estado y origen son filtros opcionales.
+func ListModerationFlags(estado, origen string, limit int) ([]models.ModerationFlag, error) {
+	query := db.DB.Order("created_at DESC").Limit(limit)
+	if estado != "" {
+		query = query.Where("estado = ?", estado)
+	}
+	if origen != "" {
+		query = query.Where("origen = ?", origen)
+	}
+
+	flags := []models.ModerationFlag{}
+	if err := query.Find(&flags).Error; err != nil {
+		return nil, err
+	}
+	return flags, nil
+}
+
+// ReviewModerationFlag aplica la decisión de un admin:
+//   - componente aprobado: se restaura el contenido y el componente queda generado
+//   - componente rechazado: el componente vuelve a pendiente para generarse de nuevo
+//   - pregunta aprobada: se activa en el banco (rechazada sigue inactiva)
+//   - entradas de estudiantes y personalizaciones: solo se registra la decisión
+func ReviewModerationFlag(adminID, flagID uint, input ModerationReviewInput) (*models.ModerationFlag, error) {
+	if input.Decision != ModerationDecisionAprobar && input.Decision != ModerationDecisionRechazar {
+		return nil, fmt.Errorf("%w: decision must be aprobar or rechazar", ErrInvalidModerationReview)
+	}
+
+	var flag models.ModerationFlag
+	if err := db.DB.First(&flag, flagID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrModerationFlagNotFound
+		}
+		return nil, err
+	}
+	if flag.Estado != models.ModerationEstadoPendiente {
+		return nil, ErrModerationFlagReviewed
+	}
+
+	approved := input.Decision == ModerationDecisionAprobar
+	now := time.Now()
+	flag.RevisadoPor = &adminID
+	flag.RevisadoEn = &now
+	flag.NotaRevision = strings.TrimSpace(input.Nota)
+	flag.Estado = models.ModerationEstadoRechazado
+	if approved {
+		flag.Estado = models.ModerationEstadoAprobado
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if flag.EntidadID != nil {
+			switch flag.EntidadTipo {
+			case models.ModerationEntidadComponente:
+				updates := map[string]interface{}{"estado": models.ComponentEstadoPendiente, "error_mensaje": ""}
+				if approved {
+					updates = map[string]interface{}{
+						"estado":          models.ComponentEstadoGenerado,
+						"contenido_props": flag.Contenido,
+						"error_mensaje":   "",
+					}
+				}
+				// Solo si sigue en cuarentena (pudo regenerarse o borrarse con el plan)
+				if err := tx.Model(&models.LearningPlanComponent{}).
+					Where("id = ? AND estado = ?", *flag.EntidadID, models.ComponentEstadoCuarentena).
+					Updates(updates).Error; err != nil {
+					return err
+				}
+			case models.ModerationEntidadPregunta:
+				if approved {
+					if err := tx.Model(&models.Question{}).Where("id = ?", *flag.EntidadID).
+						Update("activa", true).Error; err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return tx.Save(&flag).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("🛡 Moderation flag %d %s by admin %d (%s)", flag.ID, flag.Estado, adminID, flag.EntidadTipo)
+	return &flag, nil
+}
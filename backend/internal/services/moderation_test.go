@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/platanus-hack-25/lumera_app/pkg/moderation"
+)
+
+// failingClassifier simula un clasificador que no responde (p. ej. la API de OpenAI caída)
+type failingClassifier struct{}
+
+func (failingClassifier) Name() string { return "openai" }
+
+func (failingClassifier) Classify(context.Context, string) (*moderation.Result, error) {
+	return nil, errors.New("timeout")
+}
+
+func useModerator(t *testing.T, classifier moderation.Classifier) {
+	t.Helper()
+	previous := moderator
+	SetModerator(classifier)
+	t.Cleanup(func() { moderator = previous })
+}
+
+func TestModerateGeneratedContentFailsClosed(t *testing.T) {
+	useModerator(t, failingClassifier{})
+	content := map[string]interface{}{"titulo": "Potencias", "contenido": "Texto generado"}
+
+	err := moderateGeneratedContent(context.Background(), content)
+	if !errors.Is(err, ErrModerationUnavailable) {
+		t.Fatalf("err = %v, want ErrModerationUnavailable", err)
+	}
+	var flagged *FlaggedContentError
+	if errors.As(err, &flagged) {
+		t.Error("unavailable moderation reported as flagged content")
+	}
+
+	var emitted []ContentStreamEvent
+	onEvent := moderatedStreamEvents(context.Background(), func(event ContentStreamEvent) { emitted = append(emitted, event) })
+	onEvent(ContentStreamEvent{Tipo: ContentStreamEventTitulo, Titulo: "Potencias"})
+	if len(emitted) != 0 {
+		t.Errorf("emitted %d unmoderated events", len(emitted))
+	}
+}
+
+func TestModerateGeneratedContentBlocklist(t *testing.T) {
+	useModerator(t, moderation.NewBlocklistClassifier(moderation.DefaultRules()))
+
+	if err := moderateGeneratedContent(context.Background(), map[string]interface{}{"titulo": "Potencias de base natural"}); err != nil {
+		t.Errorf("clean content: %v", err)
+	}
+}
@@ -0,0 +1,719 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrOAPrerequisiteNotFound se retorna cuando el prerequisito no existe
+	ErrOAPrerequisiteNotFound = errors.New("oa prerequisite not found")
+	// ErrInvalidOAPrerequisite se retorna por OAs inexistentes, objetivos Bloom de otro OA o aristas duplicadas
+	ErrInvalidOAPrerequisite = errors.New("invalid oa prerequisite")
+	// ErrOAPrerequisiteCycle se retorna cuando la arista cerraría un ciclo en el grafo
+	ErrOAPrerequisiteCycle = errors.New("oa prerequisite would create a cycle")
+	// ErrMateriaNotFound se retorna cuando la materia no existe
+	ErrMateriaNotFound = errors.New("materia not found")
+)
+
+// OAPrerequisiteInput es el cuerpo para crear o editar una arista: oa_id se apoya en prerequisito_oa_id
+type OAPrerequisiteInput struct {
+	OAID                           uint   `json:"oa_id"`
+	PrerequisitoOAID               uint   `json:"prerequisito_oa_id"`
+	OABloomObjectiveID             *uint  `json:"oa_bloom_objective_id,omitempty"`
+	PrerequisitoOABloomObjectiveID *uint  `json:"prerequisito_oa_bloom_objective_id,omitempty"`
+	Nota                           string `json:"nota,omitempty"`
+}
+
+// OAPrerequisiteImportResult resume una importación CSV; con errores no se guarda ninguna fila
+type OAPrerequisiteImportResult struct {
+	Creados    int                         `json:"creados"`
+	Existentes int                         `json:"existentes"` // aristas que ya estaban (se omiten)
+	Errores    []OAPrerequisiteImportError `json:"errores,omitempty"`
+}
+
+// OAPrerequisiteImportError es el error de una fila del CSV (fila 1 = encabezado)
+type OAPrerequisiteImportError struct {
+	Fila  int    `json:"fila"`
+	Error string `json:"error"`
+}
+
+// CurriculumMap es el grafo de prerequisitos de una materia con el dominio del estudiante
+type CurriculumMap struct {
+	MateriaID     uint                `json:"materia_id"`
+	Materia       string              `json:"materia"`
+	UserID        uint                `json:"user_id"`
+	Nodos         []CurriculumMapNode `json:"nodos"`
+	Aristas       []CurriculumMapEdge `json:"aristas"`
+	OrdenSugerido []uint              `json:"orden_sugerido"` // OAs en orden topológico (desempate por orden y código)
+}
+
+// CurriculumMapNode es un OA del mapa
+type CurriculumMapNode struct {
+	OAID                    uint                 `json:"oa_id"`
+	MateriaID               uint                 `json:"materia_id"`
+	Codigo                  string               `json:"codigo"`
+	Titulo                  string               `json:"titulo"`
+	Categoria               string               `json:"categoria"`
+	Orden                   *int                 `json:"orden,omitempty"`
+	Externo                 bool                 `json:"externo"` // prerequisito de otra materia
+	Nivel                   int                  `json:"nivel"`   // largo del camino más largo desde un OA sin prerequisitos
+	Estado                  string               `json:"estado"`  // mejor estado del estudiante entre los niveles Bloom
+	PorcentajeLogro         int                  `json:"porcentaje_logro"`
+	BloomLevel              int                  `json:"bloom_level"` // nivel Bloom del mejor porcentaje
+	Dominado                bool                 `json:"dominado"`    // estado logrado o dominado
+	Bloom                   []CurriculumMapBloom `json:"bloom"`
+	PrerequisitosCumplidos  bool                 `json:"prerequisitos_cumplidos"`
+	PrerequisitosPendientes []uint               `json:"prerequisitos_pendientes"` // OAs de las aristas no cumplidas
+	ListoParaEstudiar       bool                 `json:"listo_para_estudiar"`      // prerequisitos cumplidos y aún no dominado
+}
+
+// CurriculumMapBloom es el progreso del estudiante en un objetivo Bloom del OA
+type CurriculumMapBloom struct {
+	OABloomObjectiveID uint   `json:"oa_bloom_objective_id"`
+	BloomLevel         int    `json:"bloom_level"`
+	Estado             string `json:"estado"`
+	PorcentajeLogro    int    `json:"porcentaje_logro"`
+}
+
+// CurriculumMapEdge es una arista del mapa: desde el prerequisito hacia el OA que lo necesita
+type CurriculumMapEdge struct {
+	ID                             uint  `json:"id"`
+	Desde                          uint  `json:"desde"`
+	Hacia                          uint  `json:"hacia"`
+	OABloomObjectiveID             *uint `json:"oa_bloom_objective_id,omitempty"`
+	PrerequisitoOABloomObjectiveID *uint `json:"prerequisito_oa_bloom_objective_id,omitempty"`
+	Cumplida                       bool  `json:"cumplida"`
+}
+
+// ListOAPrerequisites lista las aristas que tocan OAs de la materia (y/o de un OA, como requisito o prerequisito)
+func ListOAPrerequisites(materiaID uint, oaID *uint) ([]models.OAPrerequisite, error) {
+	query := db.DB.Model(&models.OAPrerequisite{}).Order("oa_id, prerequisito_oa_id, id")
+	if materiaID != 0 {
+		oas := db.DB.Model(&models.ObjetivoAprendizaje{}).Select("id").Where("materia_id = ?", materiaID)
+		query = query.Where("(oa_id IN (?) OR prerequisito_oa_id IN (?))", oas, oas)
+	}
+	if oaID != nil {
+		query = query.Where("(oa_id = ? OR prerequisito_oa_id = ?)", *oaID, *oaID)
+	}
+	prerequisites := []models.OAPrerequisite{}
+	if err := query.Find(&prerequisites).Error; err != nil {
+		return nil, err
+	}
+	return prerequisites, nil
+}
+
+// CreateOAPrerequisite agrega una arista al grafo si no cierra un ciclo
+func CreateOAPrerequisite(userID uint, input OAPrerequisiteInput) (*models.OAPrerequisite, error) {
+	prerequisite := models.OAPrerequisite{CreadoPor: &userID}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockOAPrerequisites(tx); err != nil {
+			return err
+		}
+		if err := validateOAPrerequisite(tx, 0, input); err != nil {
+			return err
+		}
+		applyOAPrerequisiteInput(&prerequisite, input)
+		return tx.Create(&prerequisite).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &prerequisite, nil
+}
+
+// UpdateOAPrerequisite reemplaza los extremos y la nota de una arista, revalidando el ciclo sin ella
+func UpdateOAPrerequisite(id uint, input OAPrerequisiteInput) (*models.OAPrerequisite, error) {
+	var prerequisite models.OAPrerequisite
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockOAPrerequisites(tx); err != nil {
+			return err
+		}
+		if err := tx.First(&prerequisite, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOAPrerequisiteNotFound
+			}
+			return err
+		}
+		if err := validateOAPrerequisite(tx, id, input); err != nil {
+			return err
+		}
+		applyOAPrerequisiteInput(&prerequisite, input)
+		return tx.Save(&prerequisite).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &prerequisite, nil
+}
+
+// DeleteOAPrerequisite borra una arista
+func DeleteOAPrerequisite(id uint) error {
+	result := db.DB.Delete(&models.OAPrerequisite{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOAPrerequisiteNotFound
+	}
+	return nil
+}
+
+// ImportOAPrerequisitesCSV importa aristas desde un CSV con encabezado. Columnas:
+// oa y prerequisito (código del OA en la materia o ID numérico, que permite OAs de otras materias),
+// y opcionalmente oa_bloom_nivel y prerequisito_bloom_nivel (1-6) y nota.
+// Todo o nada: si alguna fila es inválida o cierra un ciclo no se guarda ninguna.
+func ImportOAPrerequisitesCSV(userID, materiaID uint, r io.Reader) (*OAPrerequisiteImportResult, error) {
+	if err := db.DB.First(&models.Materia{}, materiaID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMateriaNotFound
+		}
+		return nil, err
+	}
+
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: CSV inválido: %v", ErrInvalidOAPrerequisite, err)
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("%w: el CSV necesita encabezado y al menos una fila", ErrInvalidOAPrerequisite)
+	}
+
+	columns := map[string]int{}
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"oa", "prerequisito"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: falta la columna %q", ErrInvalidOAPrerequisite, required)
+		}
+	}
+	cell := func(row []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	result := &OAPrerequisiteImportResult{}
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockOAPrerequisites(tx); err != nil {
+			return err
+		}
+		edges, err := loadOAPrerequisites(tx, 0)
+		if err != nil {
+			return err
+		}
+		requires := prerequisiteAdjacency(edges)
+		codes := map[uint]string{}
+
+		var created []models.OAPrerequisite
+		for i, row := range rows[1:] {
+			line := i + 2
+			if strings.TrimSpace(strings.Join(row, "")) == "" {
+				continue
+			}
+			fail := func(err error) {
+				result.Errores = append(result.Errores, OAPrerequisiteImportError{Fila: line, Error: strings.TrimPrefix(err.Error(), ErrInvalidOAPrerequisite.Error()+": ")})
+			}
+
+			input := OAPrerequisiteInput{Nota: cell(row, "nota")}
+			if input.OAID, err = resolveImportOA(tx, materiaID, cell(row, "oa")); err != nil {
+				fail(err)
+				continue
+			}
+			if input.PrerequisitoOAID, err = resolveImportOA(tx, materiaID, cell(row, "prerequisito")); err != nil {
+				fail(err)
+				continue
+			}
+			if input.OABloomObjectiveID, err = resolveImportBloom(tx, input.OAID, cell(row, "oa_bloom_nivel")); err != nil {
+				fail(err)
+				continue
+			}
+			if input.PrerequisitoOABloomObjectiveID, err = resolveImportBloom(tx, input.PrerequisitoOAID, cell(row, "prerequisito_bloom_nivel")); err != nil {
+				fail(err)
+				continue
+			}
+			if input.OAID == input.PrerequisitoOAID {
+				fail(fmt.Errorf("un OA no puede ser prerequisito de sí mismo"))
+				continue
+			}
+
+			candidate := models.OAPrerequisite{}
+			applyOAPrerequisiteInput(&candidate, input)
+			if containsOAPrerequisite(edges, candidate) || containsOAPrerequisite(created, candidate) {
+				result.Existentes++
+				continue
+			}
+			if cycle := findPrerequisiteCycle(requires, input.OAID, input.PrerequisitoOAID); cycle != nil {
+				fail(fmt.Errorf("cerraría el ciclo %s", describeCycle(tx, codes, cycle)))
+				continue
+			}
+
+			candidate.CreadoPor = &userID
+			created = append(created, candidate)
+			requires[input.OAID] = append(requires[input.OAID], input.PrerequisitoOAID)
+		}
+
+		if len(result.Errores) > 0 || len(created) == 0 {
+			return nil
+		}
+		if err := tx.Create(&created).Error; err != nil {
+			return err
+		}
+		result.Creados = len(created)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetCurriculumMap arma el grafo de los OAs activos de la materia (más los prerequisitos de otras
+// materias) con el progreso del estudiante de StudentOAProgress
+func GetCurriculumMap(userID, materiaID uint) (*CurriculumMap, error) {
+	var materia models.Materia
+	if err := db.DB.First(&materia, materiaID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMateriaNotFound
+		}
+		return nil, err
+	}
+
+	var oas []models.ObjetivoAprendizaje
+	if err := db.DB.Where("materia_id = ? AND activo = ?", materiaID, true).Find(&oas).Error; err != nil {
+		return nil, err
+	}
+	inMateria := make(map[uint]bool, len(oas))
+	oaIDs := make([]uint, len(oas))
+	for i, oa := range oas {
+		inMateria[oa.ID] = true
+		oaIDs[i] = oa.ID
+	}
+
+	// Aristas hacia los OAs de la materia; sus prerequisitos de otras materias entran como nodos externos
+	var edges []models.OAPrerequisite
+	if len(oaIDs) > 0 {
+		if err := db.DB.Where("oa_id IN ?", oaIDs).Order("id").Find(&edges).Error; err != nil {
+			return nil, err
+		}
+	}
+	var external []uint
+	for _, edge := range edges {
+		if !inMateria[edge.PrerequisitoOAID] && !containsUint(external, edge.PrerequisitoOAID) {
+			external = append(external, edge.PrerequisitoOAID)
+		}
+	}
+	if len(external) > 0 {
+		var externalOAs []models.ObjetivoAprendizaje
+		if err := db.DB.Where("id IN ?", external).Find(&externalOAs).Error; err != nil {
+			return nil, err
+		}
+		oas = append(oas, externalOAs...)
+	}
+
+	allIDs := make([]uint, len(oas))
+	for i, oa := range oas {
+		allIDs[i] = oa.ID
+	}
+	mastery, err := loadOAMastery(userID, allIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[uint]*CurriculumMapNode, len(oas))
+	for _, oa := range oas {
+		node := &CurriculumMapNode{
+			OAID:                    oa.ID,
+			MateriaID:               oa.MateriaID,
+			Codigo:                  oa.Codigo,
+			Titulo:                  oa.Titulo,
+			Categoria:               oa.Categoria,
+			Orden:                   oa.Orden,
+			Externo:                 !inMateria[oa.ID],
+			Estado:                  "no_iniciado",
+			Bloom:                   mastery[oa.ID],
+			PrerequisitosPendientes: []uint{},
+		}
+		if node.Bloom == nil {
+			node.Bloom = []CurriculumMapBloom{}
+		}
+		for _, bloom := range node.Bloom {
+			if bloom.PorcentajeLogro > node.PorcentajeLogro || (node.BloomLevel == 0 && bloom.Estado != "no_iniciado") {
+				node.PorcentajeLogro = bloom.PorcentajeLogro
+				node.BloomLevel = bloom.BloomLevel
+				node.Estado = bloom.Estado
+			}
+			if masteredEstado(bloom.Estado) {
+				node.Dominado = true
+			}
+		}
+		nodes[oa.ID] = node
+	}
+
+	curriculumMap := &CurriculumMap{
+		MateriaID: materia.ID,
+		Materia:   materia.Nombre,
+		UserID:    userID,
+		Nodos:     []CurriculumMapNode{},
+		Aristas:   []CurriculumMapEdge{},
+	}
+	for _, edge := range edges {
+		prerequisite, ok := nodes[edge.PrerequisitoOAID]
+		if !ok {
+			continue // OA inactivo
+		}
+		satisfied := prerequisite.Dominado
+		if edge.PrerequisitoOABloomObjectiveID != nil {
+			satisfied = false
+			for _, bloom := range prerequisite.Bloom {
+				if bloom.OABloomObjectiveID == *edge.PrerequisitoOABloomObjectiveID {
+					satisfied = masteredEstado(bloom.Estado)
+				}
+			}
+		}
+		if !satisfied && !containsUint(nodes[edge.OAID].PrerequisitosPendientes, edge.PrerequisitoOAID) {
+			nodes[edge.OAID].PrerequisitosPendientes = append(nodes[edge.OAID].PrerequisitosPendientes, edge.PrerequisitoOAID)
+		}
+		curriculumMap.Aristas = append(curriculumMap.Aristas, CurriculumMapEdge{
+			ID:                             edge.ID,
+			Desde:                          edge.PrerequisitoOAID,
+			Hacia:                          edge.OAID,
+			OABloomObjectiveID:             edge.OABloomObjectiveID,
+			PrerequisitoOABloomObjectiveID: edge.PrerequisitoOABloomObjectiveID,
+			Cumplida:                       satisfied,
+		})
+	}
+
+	curriculumMap.OrdenSugerido = topologicalOAOrder(nodes, curriculumMap.Aristas)
+	for _, oaID := range curriculumMap.OrdenSugerido {
+		node := nodes[oaID]
+		node.PrerequisitosCumplidos = len(node.PrerequisitosPendientes) == 0
+		node.ListoParaEstudiar = node.PrerequisitosCumplidos && !node.Dominado && !node.Externo
+		curriculumMap.Nodos = append(curriculumMap.Nodos, *node)
+	}
+	return curriculumMap, nil
+}
+
+// loadOAMastery retorna el progreso del estudiante en cada objetivo Bloom de los OAs (no_iniciado sin registro)
+func loadOAMastery(userID uint, oaIDs []uint) (map[uint][]CurriculumMapBloom, error) {
+	mastery := map[uint][]CurriculumMapBloom{}
+	if len(oaIDs) == 0 {
+		return mastery, nil
+	}
+	var rows []struct {
+		OAID               uint `gorm:"column:oa_id"`
+		OABloomObjectiveID uint
+		BloomLevel         int
+		Estado             *string
+		PorcentajeLogro    *int
+	}
+	err := db.DB.Table("oa_bloom_objectives obo").
+		Select("obo.oa_id, obo.id AS oa_bloom_objective_id, bl.nivel AS bloom_level, p.estado, p.porcentaje_logro").
+		Joins("JOIN bloom_levels bl ON bl.id = obo.bloom_level_id").
+		Joins("LEFT JOIN student_oa_progress p ON p.oa_bloom_objective_id = obo.id AND p.user_id = ?", userID).
+		Where("obo.oa_id IN ?", oaIDs).
+		Order("obo.oa_id, bl.nivel").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		bloom := CurriculumMapBloom{OABloomObjectiveID: row.OABloomObjectiveID, BloomLevel: row.BloomLevel, Estado: "no_iniciado"}
+		if row.Estado != nil {
+			bloom.Estado = *row.Estado
+		}
+		if row.PorcentajeLogro != nil {
+			bloom.PorcentajeLogro = *row.PorcentajeLogro
+		}
+		mastery[row.OAID] = append(mastery[row.OAID], bloom)
+	}
+	return mastery, nil
+}
+
+// topologicalOAOrder ordena los nodos (Kahn) eligiendo primero el menor orden/código entre los disponibles,
+// y asigna a cada nodo su nivel. Si la base tuviera un ciclo (editada a mano), esos nodos van al final.
+func topologicalOAOrder(nodes map[uint]*CurriculumMapNode, edges []CurriculumMapEdge) []uint {
+	pending := map[uint]int{}
+	dependents := map[uint][]uint{}
+	seen := map[[2]uint]bool{}
+	for _, edge := range edges {
+		key := [2]uint{edge.Desde, edge.Hacia}
+		if seen[key] {
+			continue // aristas a distintos niveles Bloom del mismo par
+		}
+		seen[key] = true
+		pending[edge.Hacia]++
+		dependents[edge.Desde] = append(dependents[edge.Desde], edge.Hacia)
+	}
+
+	remaining := make([]uint, 0, len(nodes))
+	for id := range nodes {
+		remaining = append(remaining, id)
+	}
+	sort.Slice(remaining, func(i, j int) bool { return oaNodeLess(nodes[remaining[i]], nodes[remaining[j]]) })
+
+	order := make([]uint, 0, len(nodes))
+	for len(remaining) > 0 {
+		next := -1
+		for i, id := range remaining {
+			if pending[id] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			return append(order, remaining...)
+		}
+		id := remaining[next]
+		remaining = append(remaining[:next], remaining[next+1:]...)
+		order = append(order, id)
+		for _, dependent := range dependents[id] {
+			pending[dependent]--
+			if nodes[id].Nivel+1 > nodes[dependent].Nivel {
+				nodes[dependent].Nivel = nodes[id].Nivel + 1
+			}
+		}
+	}
+	return order
+}
+
+func oaNodeLess(a, b *CurriculumMapNode) bool {
+	if a.Externo != b.Externo {
+		return a.Externo
+	}
+	orderA, orderB := 1<<30, 1<<30
+	if a.Orden != nil {
+		orderA = *a.Orden
+	}
+	if b.Orden != nil {
+		orderB = *b.Orden
+	}
+	if orderA != orderB {
+		return orderA < orderB
+	}
+	if a.Codigo != b.Codigo {
+		return a.Codigo < b.Codigo
+	}
+	return a.OAID < b.OAID
+}
+
+// validateOAPrerequisite revisa que los OAs existan, que los objetivos Bloom sean de su OA,
+// que la arista no exista y que no cierre un ciclo (ignorando la arista excludeID al editar)
+func validateOAPrerequisite(tx *gorm.DB, excludeID uint, input OAPrerequisiteInput) error {
+	if input.OAID == 0 || input.PrerequisitoOAID == 0 {
+		return fmt.Errorf("%w: oa_id y prerequisito_oa_id son obligatorios", ErrInvalidOAPrerequisite)
+	}
+	if input.OAID == input.PrerequisitoOAID {
+		return fmt.Errorf("%w: un OA no puede ser prerequisito de sí mismo", ErrInvalidOAPrerequisite)
+	}
+
+	var found int64
+	if err := tx.Model(&models.ObjetivoAprendizaje{}).Where("id IN ?", []uint{input.OAID, input.PrerequisitoOAID}).Count(&found).Error; err != nil {
+		return err
+	}
+	if found != 2 {
+		return fmt.Errorf("%w: OA inexistente", ErrInvalidOAPrerequisite)
+	}
+	for _, bloom := range []struct {
+		objectiveID *uint
+		oaID        uint
+	}{{input.OABloomObjectiveID, input.OAID}, {input.PrerequisitoOABloomObjectiveID, input.PrerequisitoOAID}} {
+		if bloom.objectiveID == nil {
+			continue
+		}
+		var objective models.OABloomObjective
+		if err := tx.Select("id", "oa_id").First(&objective, *bloom.objectiveID).Error; err != nil || objective.OAID != bloom.oaID {
+			return fmt.Errorf("%w: el objetivo Bloom %d no pertenece al OA %d", ErrInvalidOAPrerequisite, *bloom.objectiveID, bloom.oaID)
+		}
+	}
+
+	edges, err := loadOAPrerequisites(tx, excludeID)
+	if err != nil {
+		return err
+	}
+	candidate := models.OAPrerequisite{}
+	applyOAPrerequisiteInput(&candidate, input)
+	if containsOAPrerequisite(edges, candidate) {
+		return fmt.Errorf("%w: el prerequisito ya existe", ErrInvalidOAPrerequisite)
+	}
+	if cycle := findPrerequisiteCycle(prerequisiteAdjacency(edges), input.OAID, input.PrerequisitoOAID); cycle != nil {
+		return fmt.Errorf("%w: %s", ErrOAPrerequisiteCycle, describeCycle(tx, map[uint]string{}, cycle))
+	}
+	return nil
+}
+
+// lockOAPrerequisites serializa las escrituras del grafo: dos aristas validadas en paralelo podrían cerrar un ciclo
+func lockOAPrerequisites(tx *gorm.DB) error {
+	return tx.Exec("LOCK TABLE oa_prerequisites IN SHARE ROW EXCLUSIVE MODE").Error
+}
+
+func loadOAPrerequisites(tx *gorm.DB, excludeID uint) ([]models.OAPrerequisite, error) {
+	var edges []models.OAPrerequisite
+	query := tx.Model(&models.OAPrerequisite{})
+	if excludeID != 0 {
+		query = query.Where("id <> ?", excludeID)
+	}
+	return edges, query.Find(&edges).Error
+}
+
+func applyOAPrerequisiteInput(prerequisite *models.OAPrerequisite, input OAPrerequisiteInput) {
+	prerequisite.OAID = input.OAID
+	prerequisite.PrerequisitoOAID = input.PrerequisitoOAID
+	prerequisite.OABloomObjectiveID = input.OABloomObjectiveID
+	prerequisite.PrerequisitoOABloomObjectiveID = input.PrerequisitoOABloomObjectiveID
+	prerequisite.Nota = strings.TrimSpace(input.Nota)
+}
+
+// prerequisiteAdjacency agrupa las aristas a nivel de OA: oa_id -> prerequisitos directos
+func prerequisiteAdjacency(edges []models.OAPrerequisite) map[uint][]uint {
+	requires := map[uint][]uint{}
+	for _, edge := range edges {
+		requires[edge.OAID] = append(requires[edge.OAID], edge.PrerequisitoOAID)
+	}
+	return requires
+}
+
+// findPrerequisiteCycle retorna el ciclo que cerraría la arista "oaID necesita prerequisitoID"
+// (oaID, prerequisitoID, ..., oaID), o nil si prerequisitoID no depende ya de oaID
+func findPrerequisiteCycle(requires map[uint][]uint, oaID, prerequisitoID uint) []uint {
+	parent := map[uint]uint{prerequisitoID: prerequisitoID}
+	queue := []uint{prerequisitoID}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == oaID {
+			path := []uint{oaID}
+			for node := oaID; node != prerequisitoID; node = parent[node] {
+				path = append(path, parent[node])
+			}
+			// path va de oaID hacia atrás hasta prerequisitoID; el ciclo es oaID -> prerequisitoID -> ... -> oaID
+			cycle := []uint{oaID}
+			for i := len(path) - 1; i >= 0; i-- {
+				cycle = append(cycle, path[i])
+			}
+			return cycle
+		}
+		for _, next := range requires[current] {
+			if _, visited := parent[next]; !visited {
+				parent[next] = current
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+// describeCycle muestra el ciclo con los códigos de los OAs ("OA-3 → OA-5 → OA-3")
+func describeCycle(tx *gorm.DB, codes map[uint]string, cycle []uint) string {
+	var missing []uint
+	for _, id := range cycle {
+		if _, ok := codes[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		var oas []models.ObjetivoAprendizaje
+		tx.Select("id", "codigo").Where("id IN ?", missing).Find(&oas)
+		for _, oa := range oas {
+			codes[oa.ID] = oa.Codigo
+		}
+	}
+	labels := make([]string, len(cycle))
+	for i, id := range cycle {
+		labels[i] = codes[id]
+		if labels[i] == "" {
+			labels[i] = fmt.Sprintf("#%d", id)
+		}
+	}
+	return strings.Join(labels, " → ")
+}
+
+func containsOAPrerequisite(edges []models.OAPrerequisite, candidate models.OAPrerequisite) bool {
+	for _, edge := range edges {
+		if edge.OAID == candidate.OAID && edge.PrerequisitoOAID == candidate.PrerequisitoOAID &&
+			sameOptionalID(edge.OABloomObjectiveID, candidate.OABloomObjectiveID) &&
+			sameOptionalID(edge.PrerequisitoOABloomObjectiveID, candidate.PrerequisitoOABloomObjectiveID) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameOptionalID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// resolveImportOA acepta el código del OA en la materia o su ID numérico
+func resolveImportOA(tx *gorm.DB, materiaID uint, value string) (uint, error) {
+	if value == "" {
+		return 0, fmt.Errorf("OA vacío")
+	}
+	var oa models.ObjetivoAprendizaje
+	query := tx.Select("id")
+	if id, err := strconv.ParseUint(value, 10, 32); err == nil {
+		query = query.Where("id = ?", id)
+	} else {
+		query = query.Where("materia_id = ? AND codigo = ?", materiaID, value)
+	}
+	if err := query.First(&oa).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("OA %q no encontrado", value)
+		}
+		return 0, err
+	}
+	return oa.ID, nil
+}
+
+// resolveImportBloom busca el objetivo del OA para un nivel Bloom (1-6); vacío = todo el OA
+func resolveImportBloom(tx *gorm.DB, oaID uint, value string) (*uint, error) {
+	if value == "" {
+		return nil, nil
+	}
+	level, err := strconv.Atoi(value)
+	if err != nil || level < 1 || level > 6 {
+		return nil, fmt.Errorf("nivel Bloom %q inválido (1-6)", value)
+	}
+	var objective models.OABloomObjective
+	err = tx.Select("oa_bloom_objectives.id").
+		Joins("JOIN bloom_levels ON bloom_levels.id = oa_bloom_objectives.bloom_level_id").
+		Where("oa_bloom_objectives.oa_id = ? AND bloom_levels.nivel = ?", oaID, level).
+		First(&objective).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("el OA %d no tiene objetivo de nivel Bloom %d", oaID, level)
+		}
+		return nil, err
+	}
+	return &objective.ID, nil
+}
+
+// masteredEstado indica si un estado de StudentOAProgress cuenta como dominio del objetivo
+func masteredEstado(estado string) bool {
+	return estado == "logrado" || estado == "dominado"
+}
+
+func containsUint(list []uint, value uint) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindPrerequisiteCycle(t *testing.T) {
+	tests := []struct {
+		name           string
+		requires       map[uint][]uint // oa -> prerrequisitos que ya tiene
+		oaID           uint
+		prerequisitoID uint
+		want           []uint
+	}{
+		{"self-loop", nil, 1, 1, []uint{1, 1}},
+		{"empty graph", nil, 1, 2, nil},
+		{"two-cycle", map[uint][]uint{2: {1}}, 1, 2, []uint{1, 2, 1}},
+		{
+			name:           "long cycle",
+			requires:       map[uint][]uint{2: {3}, 3: {4}, 4: {5}, 5: {1}},
+			oaID:           1,
+			prerequisitoID: 2,
+			want:           []uint{1, 2, 3, 4, 5, 1},
+		},
+		{
+			name:           "shortest of two cycles",
+			requires:       map[uint][]uint{2: {3, 4}, 3: {5}, 5: {1}, 4: {1}},
+			oaID:           1,
+			prerequisitoID: 2,
+			want:           []uint{1, 2, 4, 1},
+		},
+		{
+			// 4 necesita 2 y 3, que necesitan 1: dos caminos al mismo OA no son un ciclo
+			name:           "diamond",
+			requires:       map[uint][]uint{4: {2, 3}, 2: {1}, 3: {1}},
+			oaID:           5,
+			prerequisitoID: 4,
+			want:           nil,
+		},
+		{
+			name:           "diamond closed from the bottom",
+			requires:       map[uint][]uint{4: {2, 3}, 2: {1}, 3: {1}},
+			oaID:           1,
+			prerequisitoID: 4,
+			want:           []uint{1, 4, 2, 1},
+		},
+		{
+			name:           "edge in the existing direction",
+			requires:       map[uint][]uint{2: {1}},
+			oaID:           2,
+			prerequisitoID: 1,
+			want:           nil,
+		},
+		{
+			name:           "cycle elsewhere in the graph",
+			requires:       map[uint][]uint{7: {8}, 8: {7}, 2: {7}},
+			oaID:           1,
+			prerequisitoID: 2,
+			want:           nil,
+		},
+	}
+	for _, tt := range tests {
+		if got := findPrerequisiteCycle(tt.requires, tt.oaID, tt.prerequisitoID); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: findPrerequisiteCycle(%d needs %d) = %v, want %v", tt.name, tt.oaID, tt.prerequisitoID, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,885 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPlacementNotFound  = errors.New("placement diagnostic not found")
+	ErrPlacementCompleted = errors.New("placement diagnostic already completed")
+	ErrPlacementExhausted = errors.New("placement diagnostic has no questions left")
+	ErrInvalidPlacement   = errors.New("invalid placement diagnostic")
+)
+
+const (
+	maxPlacementQuestions = 100
+	maxPlacementMinutes   = 180
+	// placementBloomInicial es el nivel Bloom con que parte cada materia, igual que el diagnóstico por materia
+	placementBloomInicial = 3
+)
+
+// PlacementInput inicia un diagnóstico de ubicación; los presupuestos en 0 toman los valores por defecto
+type PlacementInput struct {
+	PresupuestoPreguntas int `json:"presupuesto_preguntas"`
+	PresupuestoMinutos   int `json:"presupuesto_minutos"`
+}
+
+// PlacementItem es un OA del plan de ubicación y la sesión de diagnóstico de su materia
+type PlacementItem struct {
+	MateriaID uint   `json:"materia_id"`
+	OAID      uint   `json:"oa_id"`
+	Categoria string `json:"categoria"`
+	SessionID uint   `json:"session_id"`
+}
+
+// PlacementDetail es el diagnóstico de ubicación con sus sesiones por materia y el avance del presupuesto
+type PlacementDetail struct {
+	models.PlacementDiagnostic
+	Progreso PlacementProgress `json:"progreso"`
+}
+
+// PlacementProgress es el consumo del presupuesto de preguntas y tiempo
+type PlacementProgress struct {
+	PreguntasPlanificadas int  `json:"preguntas_planificadas"`
+	PreguntasRespondidas  int  `json:"preguntas_respondidas"`
+	PreguntasRestantes    int  `json:"preguntas_restantes"`
+	MinutosTranscurridos  int  `json:"minutos_transcurridos"`
+	MinutosRestantes      int  `json:"minutos_restantes"`
+	Agotado               bool `json:"agotado"` // sin preguntas ni tiempo: corresponde completarlo
+}
+
+// PlacementReport es el informe consolidado del diagnóstico de ubicación
+type PlacementReport struct {
+	PlacementID            uint                                `json:"placement_id"`
+	CursoID                *uint                               `json:"curso_id"`
+	PreguntasRespondidas   int                                 `json:"preguntas_respondidas"`
+	PreguntasCorrectas     int                                 `json:"preguntas_correctas"`
+	Compromiso             *float64                            `json:"compromiso"`
+	NivelBloomPromedio     float64                             `json:"nivel_bloom_promedio"`
+	Materias               []PlacementMateriaReport            `json:"materias"`
+	ConocimientoPrevio     map[string]models.NivelConocimiento `json:"conocimiento_previo"`
+	ConocimientoConservado []string                            `json:"conocimiento_conservado,omitempty"` // claves fijadas por un docente, que no se sobrescriben
+	ObjetivosInferidos     int                                 `json:"objetivos_inferidos"`               // progresos sembrados bajo el nivel Bloom dominado
+	GeneradoAt             time.Time                           `json:"generado_at"`
+}
+
+// PlacementMateriaReport es el resultado de una materia del curso
+type PlacementMateriaReport struct {
+	MateriaID            uint                       `json:"materia_id"`
+	Nombre               string                     `json:"nombre"`
+	SessionID            uint                       `json:"session_id"`
+	OAsPlanificados      int                        `json:"oas_planificados"`
+	OAsEvaluados         int                        `json:"oas_evaluados"`
+	PreguntasRespondidas int                        `json:"preguntas_respondidas"`
+	PreguntasCorrectas   int                        `json:"preguntas_correctas"`
+	NivelBloomPromedio   float64                    `json:"nivel_bloom_promedio"`
+	Nivel                int                        `json:"nivel"` // escala de NivelConocimiento: 0 sin nociones .. 4 experto
+	Categorias           []PlacementCategoriaReport `json:"categorias"`
+	OAs                  []PlacementOAReport        `json:"oas"`
+}
+
+// PlacementCategoriaReport agrega los OAs evaluados de una categoría de la materia
+type PlacementCategoriaReport struct {
+	Categoria          string  `json:"categoria"`
+	OAsEvaluados       int     `json:"oas_evaluados"`
+	PorcentajeAciertos int     `json:"porcentaje_aciertos"`
+	NivelBloomPromedio float64 `json:"nivel_bloom_promedio"`
+	Nivel              int     `json:"nivel"`
+}
+
+// PlacementOAReport es el resultado de un OA evaluado
+type PlacementOAReport struct {
+	OAID               uint   `json:"oa_id"`
+	Codigo             string `json:"codigo"`
+	Titulo             string `json:"titulo"`
+	Categoria          string `json:"categoria"`
+	NivelBloomDominado int    `json:"nivel_bloom_dominado"`
+	NivelBloomNombre   string `json:"nivel_bloom_nombre"`
+	PorcentajeAciertos int    `json:"porcentaje_aciertos"`
+	Recomendacion      string `json:"recomendacion"`
+}
+
+type placementConfig struct {
+	Preguntas           int
+	Minutos             int
+	SegundosPorPregunta int
+}
+
+func loadPlacementConfig() placementConfig {
+	return placementConfig{
+		Preguntas:           clampInt(getEnvInt("PLACEMENT_MAX_QUESTIONS", 30), 1, maxPlacementQuestions),
+		Minutos:             clampInt(getEnvInt("PLACEMENT_MAX_MINUTES", 45), 1, maxPlacementMinutes),
+		SegundosPorPregunta: clampInt(getEnvInt("PLACEMENT_SECONDS_PER_QUESTION", 60), 10, 600),
+	}
+}
+
+// placementCandidate es un OA con preguntas de diagnóstico autocorregibles
+type placementCandidate struct {
+	MateriaID uint
+	OAID      uint
+	Categoria string
+}
+
+// StartPlacement crea un diagnóstico de ubicación sobre todas las materias del curso del estudiante:
+// muestrea OAs estratificados por materia y categoría dentro del presupuesto y abre una sesión de
+// diagnóstico por materia con sus OAs a evaluar
+func StartPlacement(userID uint, input PlacementInput) (*PlacementDetail, error) {
+	config := loadPlacementConfig()
+	preguntas, minutos := config.Preguntas, config.Minutos
+	if input.PresupuestoPreguntas != 0 {
+		if input.PresupuestoPreguntas < 1 || input.PresupuestoPreguntas > maxPlacementQuestions {
+			return nil, fmt.Errorf("%w: presupuesto_preguntas debe estar entre 1 y %d", ErrInvalidPlacement, maxPlacementQuestions)
+		}
+		preguntas = input.PresupuestoPreguntas
+	}
+	if input.PresupuestoMinutos != 0 {
+		if input.PresupuestoMinutos < 1 || input.PresupuestoMinutos > maxPlacementMinutes {
+			return nil, fmt.Errorf("%w: presupuesto_minutos debe estar entre 1 y %d", ErrInvalidPlacement, maxPlacementMinutes)
+		}
+		minutos = input.PresupuestoMinutos
+	}
+
+	var profiles []models.StudentProfile
+	if err := db.DB.Where("user_id = ?", userID).Limit(1).Find(&profiles).Error; err != nil {
+		return nil, err
+	}
+	cursoActual := ""
+	if len(profiles) > 0 {
+		cursoActual = profiles[0].CursoActual
+	}
+	var cursos []models.Curso
+	if err := db.DB.Where("activo = ?", true).Order("id").Find(&cursos).Error; err != nil {
+		return nil, err
+	}
+	var cursoID *uint
+	if curso := matchCurso(cursos, cursoActual); curso != nil {
+		cursoID = &curso.ID
+	}
+
+	materias, err := recommendationMaterias(cursoActual)
+	if err != nil {
+		return nil, err
+	}
+	var materiaIDs []uint
+	for _, materia := range materias {
+		materiaIDs = append(materiaIDs, materia.ID)
+	}
+	candidates, err := loadPlacementCandidates(materiaIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	count := placementQuestionCount(preguntas, minutos, config.SegundosPorPregunta)
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	items := samplePlacementItems(candidates, count, rng)
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%w: no hay preguntas de diagnóstico para las materias del curso", ErrInvalidPlacement)
+	}
+
+	placement := models.PlacementDiagnostic{
+		UserID:               userID,
+		CursoID:              cursoID,
+		Estado:               "en_progreso",
+		PresupuestoPreguntas: preguntas,
+		PresupuestoMinutos:   minutos,
+		Plan:                 datatypes.JSON("[]"),
+		StartedAt:            time.Now(),
+	}
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Sessions").Create(&placement).Error; err != nil {
+			return err
+		}
+
+		// Una sesión por materia, con sus OAs en el orden del plan
+		sessionIDs := map[uint]uint{}
+		for _, materiaID := range placementMateriaOrder(items) {
+			var oas []uint
+			for _, item := range items {
+				if item.MateriaID == materiaID {
+					oas = append(oas, item.OAID)
+				}
+			}
+			var attempts int64
+			if err := tx.Model(&models.DiagnosticSession{}).
+				Where("user_id = ? AND materia_id = ?", userID, materiaID).
+				Count(&attempts).Error; err != nil {
+				return err
+			}
+			strategyJSON, _ := json.Marshal(map[string]interface{}{
+				"nivel_bloom_actual":    placementBloomInicial,
+				"oas_evaluados":         []uint{},
+				"oas_a_evaluar":         oas,
+				"aciertos_consecutivos": 0,
+				"fallos_consecutivos":   0,
+				"patron_respuestas":     []string{},
+			})
+			session := models.DiagnosticSession{
+				UserID:                userID,
+				MateriaID:             materiaID,
+				NumeroIntento:         int(attempts) + 1,
+				Estado:                "en_progreso",
+				Estrategia:            datatypes.JSON(strategyJSON),
+				StartedAt:             placement.StartedAt,
+				UltimaActividadAt:     placement.StartedAt,
+				PlacementDiagnosticID: &placement.ID,
+			}
+			if err := tx.Omit("User", "Materia", "Answers", "Results").Create(&session).Error; err != nil {
+				return err
+			}
+			sessionIDs[materiaID] = session.ID
+		}
+
+		for i := range items {
+			items[i].SessionID = sessionIDs[items[i].MateriaID]
+		}
+		planJSON, err := json.Marshal(items)
+		if err != nil {
+			return err
+		}
+		placement.Plan = datatypes.JSON(planJSON)
+		return tx.Model(&placement).Update("plan", placement.Plan).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetPlacement(userID, placement.ID)
+}
+
+// GetPlacement retorna el diagnóstico de ubicación con sus sesiones y el presupuesto restante
+func GetPlacement(userID, placementID uint) (*PlacementDetail, error) {
+	placement, err := loadPlacement(userID, placementID)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := placementPlan(placement)
+	if err != nil {
+		return nil, err
+	}
+	return &PlacementDetail{
+		PlacementDiagnostic: *placement,
+		Progreso:            placementProgress(placement, plan, time.Now()),
+	}, nil
+}
+
+// NextPlacementItem retorna el siguiente OA del plan aún no preguntado, si queda presupuesto
+func NextPlacementItem(userID, placementID uint) (*PlacementItem, *PlacementProgress, error) {
+	placement, err := loadPlacement(userID, placementID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if placement.Estado == "completado" {
+		return nil, nil, ErrPlacementCompleted
+	}
+	plan, err := placementPlan(placement)
+	if err != nil {
+		return nil, nil, err
+	}
+	progress := placementProgress(placement, plan, time.Now())
+	if progress.Agotado {
+		return nil, &progress, ErrPlacementExhausted
+	}
+
+	evaluated := placementEvaluatedOAs(placement.Sessions)
+	for i := range plan {
+		if !containsUint(evaluated[plan[i].SessionID], plan[i].OAID) {
+			return &plan[i], &progress, nil
+		}
+	}
+	return nil, &progress, ErrPlacementExhausted
+}
+
+// CompletablePlacement verifica que el diagnóstico sea del estudiante y siga en progreso, y retorna
+// sus sesiones por materia para cerrarlas
+func CompletablePlacement(userID, placementID uint) ([]models.DiagnosticSession, error) {
+	placement, err := loadPlacement(userID, placementID)
+	if err != nil {
+		return nil, err
+	}
+	if placement.Estado == "completado" {
+		return nil, ErrPlacementCompleted
+	}
+	return placement.Sessions, nil
+}
+
+// FinishPlacement consolida el informe de las sesiones ya completadas y, en un solo paso, siembra el
+// progreso de los niveles Bloom inferiores a los dominados y el conocimiento previo del perfil.
+// Las sesiones de materias que el presupuesto no alcanzó a preguntar se descartan.
+func FinishPlacement(userID, placementID uint) (*PlacementReport, error) {
+	placement, err := loadPlacement(userID, placementID)
+	if err != nil {
+		return nil, err
+	}
+	if placement.Estado == "completado" {
+		return nil, ErrPlacementCompleted
+	}
+	plan, err := placementPlan(placement)
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []models.DiagnosticSession
+	var emptySessionIDs []uint
+	for _, session := range placement.Sessions {
+		if session.PreguntasTotales == 0 {
+			emptySessionIDs = append(emptySessionIDs, session.ID)
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+
+	report, err := buildPlacementReport(placement, plan, sessions)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if len(emptySessionIDs) > 0 {
+			if err := tx.Where("id IN ?", emptySessionIDs).Delete(&models.DiagnosticSession{}).Error; err != nil {
+				return err
+			}
+		}
+
+		inferred, err := seedPlacementProgress(tx, userID, report, now)
+		if err != nil {
+			return err
+		}
+		report.ObjetivosInferidos = inferred
+
+		kept, err := seedConocimientoPrevio(tx, userID, report.ConocimientoPrevio, now)
+		if err != nil {
+			return err
+		}
+		report.ConocimientoConservado = kept
+
+		informe, err := json.Marshal(report)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.PlacementDiagnostic{}).Where("id = ?", placement.ID).Updates(map[string]interface{}{
+			"estado":       "completado",
+			"completed_at": now,
+			"informe":      datatypes.JSON(informe),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// GetPlacementReport retorna el informe guardado de un diagnóstico completado
+func GetPlacementReport(userID, placementID uint) (*PlacementReport, error) {
+	placement, err := loadPlacement(userID, placementID)
+	if err != nil {
+		return nil, err
+	}
+	if placement.Estado != "completado" || len(placement.Informe) == 0 {
+		return nil, fmt.Errorf("%w: el diagnóstico aún no se completa", ErrInvalidPlacement)
+	}
+	var report PlacementReport
+	if err := json.Unmarshal(placement.Informe, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func loadPlacement(userID, placementID uint) (*models.PlacementDiagnostic, error) {
+	var placement models.PlacementDiagnostic
+	err := db.DB.Preload("Sessions", func(tx *gorm.DB) *gorm.DB { return tx.Order("id") }).
+		Where("id = ? AND user_id = ?", placementID, userID).
+		First(&placement).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPlacementNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &placement, nil
+}
+
+func placementPlan(placement *models.PlacementDiagnostic) ([]PlacementItem, error) {
+	var plan []PlacementItem
+	if err := json.Unmarshal(placement.Plan, &plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// loadPlacementCandidates retorna los OAs activos de las materias que tienen alguna pregunta de
+// diagnóstico que no requiere corrección manual
+func loadPlacementCandidates(materiaIDs []uint) ([]placementCandidate, error) {
+	var candidates []placementCandidate
+	if len(materiaIDs) == 0 {
+		return candidates, nil
+	}
+	err := db.DB.Table("objetivos_aprendizaje oa").
+		Select("oa.materia_id, oa.id AS oa_id, oa.categoria").
+		Where("oa.materia_id IN ? AND oa.activo = ?", materiaIDs, true).
+		Where(`EXISTS (
+			SELECT 1 FROM oa_bloom_objectives obo
+			JOIN questions q ON q.oa_bloom_objective_id = obo.id
+			WHERE obo.oa_id = oa.id AND q.activa = ? AND q.tipo_uso IN ? AND q.tipo NOT IN ?
+		)`, true, []string{"diagnostico", "all"}, []string{"open_ended", "concept_map"}).
+		Order("oa.materia_id, oa.id").
+		Scan(&candidates).Error
+	return candidates, err
+}
+
+// placementQuestionCount es el largo del plan: el presupuesto de preguntas, acotado por las que caben
+// en el presupuesto de minutos
+func placementQuestionCount(preguntas, minutos, segundosPorPregunta int) int {
+	byTime := minutos * 60 / segundosPorPregunta
+	if byTime < 1 {
+		byTime = 1
+	}
+	if byTime < preguntas {
+		return byTime
+	}
+	return preguntas
+}
+
+// samplePlacementItems muestrea count OAs estratificados: reparte las preguntas por turnos entre las
+// materias y, dentro de cada materia, por turnos entre sus categorías, con un OA al azar de cada
+// estrato. El orden resultante intercala materias, así que un presupuesto cortado antes de tiempo
+// igual cubre todo el curso.
+func samplePlacementItems(candidates []placementCandidate, count int, rng *rand.Rand) []PlacementItem {
+	type stratum struct {
+		categoria string
+		oas       []uint
+	}
+	strata := map[uint][]*stratum{}
+	var materiaOrder []uint
+	for _, candidate := range candidates {
+		categoria := strings.TrimSpace(candidate.Categoria)
+		if categoria == "" {
+			categoria = "General"
+		}
+		if _, ok := strata[candidate.MateriaID]; !ok {
+			materiaOrder = append(materiaOrder, candidate.MateriaID)
+		}
+		var target *stratum
+		for _, s := range strata[candidate.MateriaID] {
+			if s.categoria == categoria {
+				target = s
+				break
+			}
+		}
+		if target == nil {
+			target = &stratum{categoria: categoria}
+			strata[candidate.MateriaID] = append(strata[candidate.MateriaID], target)
+		}
+		target.oas = append(target.oas, candidate.OAID)
+	}
+	for _, materiaID := range materiaOrder {
+		materiaStrata := strata[materiaID]
+		rng.Shuffle(len(materiaStrata), func(i, j int) { materiaStrata[i], materiaStrata[j] = materiaStrata[j], materiaStrata[i] })
+		for _, s := range materiaStrata {
+			rng.Shuffle(len(s.oas), func(i, j int) { s.oas[i], s.oas[j] = s.oas[j], s.oas[i] })
+		}
+	}
+
+	items := []PlacementItem{}
+	nextStratum := map[uint]int{}
+	for len(items) < count {
+		added := false
+		for _, materiaID := range materiaOrder {
+			if len(items) >= count {
+				break
+			}
+			materiaStrata := strata[materiaID]
+			for tries := 0; tries < len(materiaStrata); tries++ {
+				s := materiaStrata[nextStratum[materiaID]%len(materiaStrata)]
+				nextStratum[materiaID]++
+				if len(s.oas) == 0 {
+					continue
+				}
+				items = append(items, PlacementItem{MateriaID: materiaID, OAID: s.oas[0], Categoria: s.categoria})
+				s.oas = s.oas[1:]
+				added = true
+				break
+			}
+		}
+		if !added {
+			break
+		}
+	}
+	return items
+}
+
+func placementMateriaOrder(items []PlacementItem) []uint {
+	var order []uint
+	for _, item := range items {
+		if !containsUint(order, item.MateriaID) {
+			order = append(order, item.MateriaID)
+		}
+	}
+	return order
+}
+
+// placementEvaluatedOAs retorna los OAs ya preguntados por sesión
+func placementEvaluatedOAs(sessions []models.DiagnosticSession) map[uint][]uint {
+	evaluated := map[uint][]uint{}
+	for _, session := range sessions {
+		var strategy models.AdaptiveStrategy
+		if err := json.Unmarshal(session.Estrategia, &strategy); err == nil {
+			evaluated[session.ID] = strategy.OAsEvaluados
+		}
+	}
+	return evaluated
+}
+
+func placementProgress(placement *models.PlacementDiagnostic, plan []PlacementItem, now time.Time) PlacementProgress {
+	progress := PlacementProgress{PreguntasPlanificadas: len(plan)}
+	if progress.PreguntasPlanificadas > placement.PresupuestoPreguntas {
+		progress.PreguntasPlanificadas = placement.PresupuestoPreguntas
+	}
+
+	served := 0
+	for _, session := range placement.Sessions {
+		progress.PreguntasRespondidas += session.PreguntasTotales
+	}
+	for _, oas := range placementEvaluatedOAs(placement.Sessions) {
+		served += len(oas)
+	}
+
+	end := now
+	if placement.CompletedAt != nil {
+		end = *placement.CompletedAt
+	}
+	progress.MinutosTranscurridos = int(end.Sub(placement.StartedAt).Minutes())
+	progress.MinutosRestantes = placement.PresupuestoMinutos - progress.MinutosTranscurridos
+	if progress.MinutosRestantes < 0 {
+		progress.MinutosRestantes = 0
+	}
+
+	progress.PreguntasRestantes = placement.PresupuestoPreguntas - progress.PreguntasRespondidas
+	if pending := len(plan) - served; pending < progress.PreguntasRestantes {
+		progress.PreguntasRestantes = pending
+	}
+	if progress.PreguntasRestantes < 0 || placement.Estado == "completado" {
+		progress.PreguntasRestantes = 0
+	}
+	progress.Agotado = progress.PreguntasRestantes == 0 || progress.MinutosRestantes == 0
+	return progress
+}
+
+// buildPlacementReport agrega los resultados de diagnóstico de cada sesión por materia y categoría
+func buildPlacementReport(placement *models.PlacementDiagnostic, plan []PlacementItem, sessions []models.DiagnosticSession) (*PlacementReport, error) {
+	report := &PlacementReport{
+		PlacementID:        placement.ID,
+		CursoID:            placement.CursoID,
+		Materias:           []PlacementMateriaReport{},
+		ConocimientoPrevio: map[string]models.NivelConocimiento{},
+		GeneradoAt:         time.Now(),
+	}
+
+	var sessionIDs, materiaIDs []uint
+	for _, session := range sessions {
+		sessionIDs = append(sessionIDs, session.ID)
+		materiaIDs = append(materiaIDs, session.MateriaID)
+	}
+	var results []models.DiagnosticResult
+	if len(sessionIDs) > 0 {
+		if err := db.DB.Preload("OA").Where("session_id IN ?", sessionIDs).Order("id").Find(&results).Error; err != nil {
+			return nil, err
+		}
+	}
+	materiaNames := map[uint]string{}
+	if len(materiaIDs) > 0 {
+		var materias []models.Materia
+		if err := db.DB.Where("id IN ?", materiaIDs).Find(&materias).Error; err != nil {
+			return nil, err
+		}
+		for _, materia := range materias {
+			materiaNames[materia.ID] = materia.Nombre
+		}
+	}
+
+	rapidGuesses := 0
+	var bloomTotal float64
+	conocimiento := map[string][]float64{}
+	for _, session := range sessions {
+		report.PreguntasRespondidas += session.PreguntasTotales
+		report.PreguntasCorrectas += session.PreguntasCorrectas
+		rapidGuesses += session.AdivinanzasRapidas
+
+		materia := PlacementMateriaReport{
+			MateriaID:            session.MateriaID,
+			Nombre:               materiaNames[session.MateriaID],
+			SessionID:            session.ID,
+			PreguntasRespondidas: session.PreguntasTotales,
+			PreguntasCorrectas:   session.PreguntasCorrectas,
+			Categorias:           []PlacementCategoriaReport{},
+			OAs:                  []PlacementOAReport{},
+		}
+		for _, item := range plan {
+			if item.SessionID == session.ID {
+				materia.OAsPlanificados++
+			}
+		}
+
+		var categoriaOrder []string
+		categoriaBloom := map[string][]float64{}
+		categoriaAnswers := map[string][2]int{}
+		var materiaBloom float64
+		for _, result := range results {
+			if result.SessionID != session.ID {
+				continue
+			}
+			categoria := strings.TrimSpace(result.OA.Categoria)
+			if categoria == "" {
+				categoria = "General"
+			}
+			materia.OAs = append(materia.OAs, PlacementOAReport{
+				OAID:               result.OAID,
+				Codigo:             result.OA.Codigo,
+				Titulo:             result.OA.Titulo,
+				Categoria:          categoria,
+				NivelBloomDominado: result.NivelBloomDominado,
+				NivelBloomNombre:   result.NivelBloomNombre,
+				PorcentajeAciertos: result.PorcentajeAciertos,
+				Recomendacion:      result.Recomendacion,
+			})
+			if _, ok := categoriaBloom[categoria]; !ok {
+				categoriaOrder = append(categoriaOrder, categoria)
+			}
+			level := float64(result.NivelBloomDominado)
+			categoriaBloom[categoria] = append(categoriaBloom[categoria], level)
+			answers := categoriaAnswers[categoria]
+			answers[0] += result.PreguntasCorrectas
+			answers[1] += result.PreguntasRespondidas
+			categoriaAnswers[categoria] = answers
+			materiaBloom += level
+			bloomTotal += level
+
+			if key := conocimientoPrevioKey(materia.Nombre, categoria); key != "" {
+				conocimiento[key] = append(conocimiento[key], level)
+			}
+		}
+
+		for _, categoria := range categoriaOrder {
+			levels := categoriaBloom[categoria]
+			average := averageFloat(levels)
+			answers := categoriaAnswers[categoria]
+			porcentaje := 0
+			if answers[1] > 0 {
+				porcentaje = answers[0] * 100 / answers[1]
+			}
+			materia.Categorias = append(materia.Categorias, PlacementCategoriaReport{
+				Categoria:          categoria,
+				OAsEvaluados:       len(levels),
+				PorcentajeAciertos: porcentaje,
+				NivelBloomPromedio: roundPlacement(average),
+				Nivel:              placementNivel(average),
+			})
+		}
+
+		materia.OAsEvaluados = len(materia.OAs)
+		if materia.OAsEvaluados > 0 {
+			average := materiaBloom / float64(materia.OAsEvaluados)
+			materia.NivelBloomPromedio = roundPlacement(average)
+			materia.Nivel = placementNivel(average)
+		}
+		report.Materias = append(report.Materias, materia)
+	}
+
+	evaluated := 0
+	for _, materia := range report.Materias {
+		evaluated += materia.OAsEvaluados
+	}
+	if evaluated > 0 {
+		report.NivelBloomPromedio = roundPlacement(bloomTotal / float64(evaluated))
+	}
+	report.Compromiso = SessionCompromiso(report.PreguntasRespondidas, rapidGuesses)
+
+	for key, levels := range conocimiento {
+		report.ConocimientoPrevio[key] = models.NivelConocimiento{
+			Nivel:  placementNivel(averageFloat(levels)),
+			Fuente: "diagnostico_inicial",
+		}
+	}
+	return report, nil
+}
+
+// seedPlacementProgress marca como logrados los niveles Bloom inferiores al dominado en cada OA
+// evaluado, solo donde el estudiante aún no tiene progreso. El nivel dominado ya lo registra el
+// trigger de diagnostic_results y las respuestas futuras reemplazan lo inferido vía knowledge tracing.
+func seedPlacementProgress(tx *gorm.DB, userID uint, report *PlacementReport, now time.Time) (int, error) {
+	dominated := map[uint]int{}
+	var oaIDs []uint
+	for _, materia := range report.Materias {
+		for _, oa := range materia.OAs {
+			if oa.NivelBloomDominado > 1 {
+				dominated[oa.OAID] = oa.NivelBloomDominado
+				oaIDs = append(oaIDs, oa.OAID)
+			}
+		}
+	}
+	if len(oaIDs) == 0 {
+		return 0, nil
+	}
+
+	var objectives []models.OABloomObjective
+	if err := tx.Where("oa_id IN ?", oaIDs).Order("oa_id, bloom_level_id").Find(&objectives).Error; err != nil {
+		return 0, err
+	}
+
+	porcentaje := loadKnowledgeTracingConfig().LogradoPercent
+	inferred := 0
+	for _, objective := range objectives {
+		level := dominated[objective.OAID]
+		if int(objective.BloomLevelID) >= level {
+			continue
+		}
+		notas := fmt.Sprintf("Inferido del diagnóstico de ubicación: domina el nivel %d de Bloom del OA", level)
+		progress := models.StudentOAProgress{
+			UserID:               userID,
+			OABloomObjectiveID:   objective.ID,
+			Estado:               "logrado",
+			PorcentajeLogro:      porcentaje,
+			UltimaActividadFecha: &now,
+			Notas:                notas,
+		}
+		result := tx.Omit("User", "OABloomObjective").Clauses(clause.OnConflict{DoNothing: true}).Create(&progress)
+		if result.Error != nil {
+			return 0, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		history := models.StudentOAHistory{
+			UserID:             userID,
+			OABloomObjectiveID: objective.ID,
+			Estado:             "logrado",
+			PorcentajeLogro:    &porcentaje,
+			TipoEvento:         "diagnostico",
+			Notas:              notas,
+		}
+		if err := tx.Create(&history).Error; err != nil {
+			return 0, err
+		}
+		inferred++
+	}
+	return inferred, nil
+}
+
+// seedConocimientoPrevio escribe los niveles en profile_data.conocimiento_previo (creando el perfil si
+// no existe) y guarda una foto en profile_history. Retorna las claves que fijó un docente y se conservan.
+func seedConocimientoPrevio(tx *gorm.DB, userID uint, niveles map[string]models.NivelConocimiento, now time.Time) ([]string, error) {
+	if len(niveles) == 0 {
+		return nil, nil
+	}
+
+	var profile models.StudentProfile
+	err := tx.Where("user_id = ?", userID).First(&profile).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		profile = models.StudentProfile{UserID: userID, ProfileData: datatypes.JSON("{}")}
+	} else if err != nil {
+		return nil, err
+	}
+
+	data := map[string]interface{}{}
+	if len(profile.ProfileData) > 0 {
+		if err := json.Unmarshal(profile.ProfileData, &data); err != nil {
+			return nil, err
+		}
+	}
+	previo, _ := data["conocimiento_previo"].(map[string]interface{})
+	if previo == nil {
+		previo = map[string]interface{}{}
+	}
+
+	var kept []string
+	for _, key := range []string{"lectura", "escritura", "matematicas"} {
+		nivel, ok := niveles[key]
+		if !ok {
+			continue
+		}
+		if existing, ok := previo[key].(map[string]interface{}); ok && existing["fuente"] == "docente" {
+			kept = append(kept, key)
+			continue
+		}
+		previo[key] = nivel
+	}
+	data["conocimiento_previo"] = previo
+	data["ultima_actualizacion"] = now.Format(time.RFC3339)
+
+	profileData, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	profile.ProfileData = datatypes.JSON(profileData)
+	if err := tx.Omit("User").Save(&profile).Error; err != nil {
+		return nil, err
+	}
+
+	history := models.ProfileHistory{
+		UserID:   userID,
+		Snapshot: profile.ProfileData,
+		Evento:   "diagnostico_inicial",
+	}
+	if err := tx.Omit("User").Create(&history).Error; err != nil {
+		return nil, err
+	}
+	return kept, nil
+}
+
+// conocimientoPrevioKey asocia una materia y categoría a las claves de ProfileDataStructure.ConocimientoPrevio
+func conocimientoPrevioKey(materia, categoria string) string {
+	switch normalizeCacheText(categoria) {
+	case "lectura":
+		return "lectura"
+	case "escritura":
+		return "escritura"
+	}
+	if strings.Contains(normalizeCacheText(materia), "matematica") {
+		return "matematicas"
+	}
+	return ""
+}
+
+// placementNivel lleva un nivel Bloom promedio (0-6) a la escala de NivelConocimiento (0-4)
+func placementNivel(bloom float64) int {
+	switch {
+	case bloom < 0.5:
+		return 0
+	case bloom < 2.5:
+		return 1
+	case bloom < 3.5:
+		return 2
+	case bloom < 5.5:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func averageFloat(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func roundPlacement(v float64) float64 {
+	return math.Round(v*100) / 100
+}
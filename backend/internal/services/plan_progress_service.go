@@ -0,0 +1,316 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"gorm.io/gorm"
+)
+
+// maxEventSeconds limita el tiempo reportado por un evento "tiempo" (pestañas olvidadas abiertas)
+const maxEventSeconds = 3600
+
+// ErrInvalidComponentEvent indica un evento con tipo desconocido o de un componente ajeno al plan
+var ErrInvalidComponentEvent = errors.New("invalid component event")
+
+// PlanProgressService registra los eventos de visualización de los slides y calcula el progreso
+type PlanProgressService struct{}
+
+// NewPlanProgressService crea una nueva instancia del servicio
+func NewPlanProgressService() *PlanProgressService {
+	return &PlanProgressService{}
+}
+
+// ComponentEventInput es un evento reportado por el cliente
+type ComponentEventInput struct {
+	ComponentID    uint   `json:"component_id"`
+	Tipo           string `json:"tipo"`
+	TiempoSegundos int    `json:"tiempo_segundos,omitempty"`
+}
+
+// ComponentProgressSummary resume la actividad del estudiante en un componente
+type ComponentProgressSummary struct {
+	ComponentID    uint   `json:"component_id"`
+	Orden          int    `json:"orden"`
+	TipoComponente string `json:"tipo_componente"`
+	Abierto        bool   `json:"abierto"`
+	Aperturas      int    `json:"aperturas"`
+	TiempoSegundos int    `json:"tiempo_segundos"`
+	ScrollFinal    bool   `json:"scroll_final"`
+	Audio          bool   `json:"audio"`
+}
+
+// PlanProgress es el progreso del estudiante en un plan
+type PlanProgress struct {
+	LearningPlanID     uint                       `json:"learning_plan_id"`
+	ProgresoActual     int                        `json:"progreso_actual"`
+	TotalSlides        int                        `json:"total_slides"`
+	UltimoComponenteID *uint                      `json:"ultimo_componente_id,omitempty"`
+	UltimaActividad    *time.Time                 `json:"ultima_actividad,omitempty"`
+	Completado         bool                       `json:"completado"`
+	Componentes        []ComponentProgressSummary `json:"componentes,omitempty"`
+}
+
+func (s *PlanProgressService) loadUserPlan(tx *gorm.DB, userID, planID uint) (*models.LearningPlan, error) {
+	var plan models.LearningPlan
+	if err := tx.Where("id = ? AND user_id = ?", planID, userID).First(&plan).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPlanNotFound
+		}
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// RecordEvents guarda un lote de eventos y actualiza ProgresoActual (componentes distintos abiertos),
+// el último componente abierto (para retomar) y la última actividad del plan
+func (s *PlanProgressService) RecordEvents(userID, planID uint, events []ComponentEventInput) (*PlanProgress, error) {
+	if len(events) == 0 {
+		return nil, fmt.Errorf("%w: no events", ErrInvalidComponentEvent)
+	}
+
+	var plan *models.LearningPlan
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		plan, err = s.loadUserPlan(tx, userID, planID)
+		if err != nil {
+			return err
+		}
+
+		var componentIDs []uint
+		if err := tx.Model(&models.LearningPlanComponent{}).
+			Where("learning_plan_id = ?", plan.ID).
+			Pluck("id", &componentIDs).Error; err != nil {
+			return err
+		}
+		inPlan := make(map[uint]bool, len(componentIDs))
+		for _, id := range componentIDs {
+			inPlan[id] = true
+		}
+
+		rows := make([]models.LearningPlanComponentEvent, 0, len(events))
+		var lastOpened *uint
+		for i, e := range events {
+			if !models.IsValidComponentEventType(e.Tipo) {
+				return fmt.Errorf("%w: unknown type %q at index %d", ErrInvalidComponentEvent, e.Tipo, i)
+			}
+			if !inPlan[e.ComponentID] {
+				return fmt.Errorf("%w: component %d does not belong to plan %d", ErrInvalidComponentEvent, e.ComponentID, plan.ID)
+			}
+
+			seconds := 0
+			if e.Tipo == models.ComponentEventTiempo {
+				seconds = e.TiempoSegundos
+				if seconds < 0 {
+					seconds = 0
+				}
+				if seconds > maxEventSeconds {
+					seconds = maxEventSeconds
+				}
+			}
+			if e.Tipo == models.ComponentEventAbierto {
+				componentID := e.ComponentID
+				lastOpened = &componentID
+			}
+
+			rows = append(rows, models.LearningPlanComponentEvent{
+				LearningPlanID: plan.ID,
+				ComponentID:    e.ComponentID,
+				UserID:         userID,
+				Tipo:           e.Tipo,
+				TiempoSegundos: seconds,
+			})
+		}
+		if err := tx.Create(&rows).Error; err != nil {
+			return err
+		}
+
+		var opened int64
+		if err := tx.Model(&models.LearningPlanComponentEvent{}).
+			Where("learning_plan_id = ? AND tipo = ?", plan.ID, models.ComponentEventAbierto).
+			Distinct("component_id").
+			Count(&opened).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		plan.UltimaActividad = &now
+		if lastOpened != nil {
+			plan.UltimoComponenteID = lastOpened
+		}
+		if plan.FechaInicio == nil {
+			plan.FechaInicio = &now
+		}
+		// Un plan completado conserva ProgresoActual = TotalSlides
+		if !plan.Completado {
+			plan.ProgresoActual = int(opened)
+		}
+
+		return tx.Model(plan).Updates(map[string]interface{}{
+			"progreso_actual":      plan.ProgresoActual,
+			"ultimo_componente_id": plan.UltimoComponenteID,
+			"ultima_actividad":     plan.UltimaActividad,
+			"fecha_inicio":         plan.FechaInicio,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &PlanProgress{
+		LearningPlanID:     plan.ID,
+		ProgresoActual:     plan.ProgresoActual,
+		TotalSlides:        plan.TotalSlides,
+		UltimoComponenteID: plan.UltimoComponenteID,
+		UltimaActividad:    plan.UltimaActividad,
+		Completado:         plan.Completado,
+	}, nil
+}
+
+// GetProgress retorna el progreso del plan con el resumen de actividad de cada componente
+func (s *PlanProgressService) GetProgress(userID, planID uint) (*PlanProgress, error) {
+	plan, err := s.loadUserPlan(db.DB, userID, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	var componentes []ComponentProgressSummary
+	err = db.DB.Raw(`
+		SELECT c.id AS component_id, c.orden, c.tipo_componente,
+			COUNT(e.id) FILTER (WHERE e.tipo = 'abierto') > 0 AS abierto,
+			COUNT(e.id) FILTER (WHERE e.tipo = 'abierto') AS aperturas,
+			COALESCE(SUM(e.tiempo_segundos) FILTER (WHERE e.tipo = 'tiempo'), 0) AS tiempo_segundos,
+			COUNT(e.id) FILTER (WHERE e.tipo = 'scroll_final') > 0 AS scroll_final,
+			COUNT(e.id) FILTER (WHERE e.tipo = 'audio') > 0 AS audio
+		FROM learning_plan_components c
+		LEFT JOIN learning_plan_component_events e ON e.component_id = c.id
+		WHERE c.learning_plan_id = ?
+		GROUP BY c.id, c.orden, c.tipo_componente
+		ORDER BY c.orden ASC`, plan.ID).Scan(&componentes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &PlanProgress{
+		LearningPlanID:     plan.ID,
+		ProgresoActual:     plan.ProgresoActual,
+		TotalSlides:        plan.TotalSlides,
+		UltimoComponenteID: plan.UltimoComponenteID,
+		UltimaActividad:    plan.UltimaActividad,
+		Completado:         plan.Completado,
+		Componentes:        componentes,
+	}, nil
+}
+
+// AbandonmentRow agrega vistas y abandonos de un grupo de componentes
+type AbandonmentRow struct {
+	Group             string  `json:"group"`
+	Vistas            int     `json:"vistas"`    // pares plan-componente abiertos
+	Abandonos         int     `json:"abandonos"` // planes sin completar cuya última actividad fue este componente
+	TasaAbandono      float64 `json:"tasa_abandono"`
+	TiempoPromedioSeg float64 `json:"tiempo_promedio_seg"`
+	PctScrollFinal    float64 `json:"pct_scroll_final"`
+	PctAudio          float64 `json:"pct_audio"`
+}
+
+// AbandonmentReport es el reporte de abandono de componentes entre dos fechas
+type AbandonmentReport struct {
+	From              time.Time        `json:"from"`
+	To                time.Time        `json:"to"`
+	GroupBy           string           `json:"group_by"`
+	InactiveHours     int              `json:"inactive_hours"`
+	PlanesIniciados   int              `json:"planes_iniciados"`
+	PlanesCompletados int              `json:"planes_completados"`
+	PlanesAbandonados int              `json:"planes_abandonados"`
+	Rows              []AbandonmentRow `json:"rows"`
+}
+
+// abandonmentGroupColumns mapea los group_by permitidos a expresiones SQL
+var abandonmentGroupColumns = map[string]string{
+	"tipo":        "c.tipo_componente",
+	"orden":       "LPAD(CAST(c.orden AS TEXT), 3, '0')",
+	"objetivo":    "CAST(p.oa_bloom_objective_id AS TEXT)",
+	"remediacion": "CASE WHEN c.es_remediacion THEN 'remediacion' ELSE 'regular' END",
+}
+
+// GetAbandonmentReport agrega los eventos en [from, to). Un plan se considera abandonado si no está
+// completado y no tiene actividad hace más de inactiveHours; su último componente abierto es donde se abandonó.
+// oaBloomObjectiveID = 0 incluye todos los objetivos.
+func (s *PlanProgressService) GetAbandonmentReport(from, to time.Time, groupBy string, oaBloomObjectiveID uint, inactiveHours int) (*AbandonmentReport, error) {
+	groupExpr, ok := abandonmentGroupColumns[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("invalid group_by: %s", groupBy)
+	}
+	if inactiveHours <= 0 {
+		return nil, errors.New("inactive_hours must be > 0")
+	}
+	inactiveSince := time.Now().Add(-time.Duration(inactiveHours) * time.Hour)
+
+	objectiveFilter := ""
+	args := []interface{}{from, to}
+	if oaBloomObjectiveID != 0 {
+		objectiveFilter = "AND p.oa_bloom_objective_id = ?"
+		args = append(args, oaBloomObjectiveID)
+	}
+
+	report := &AbandonmentReport{From: from, To: to, GroupBy: groupBy, InactiveHours: inactiveHours}
+
+	views := fmt.Sprintf(`
+		WITH views AS (
+			SELECT e.learning_plan_id, e.component_id,
+				BOOL_OR(e.tipo = 'scroll_final') AS scroll_final,
+				BOOL_OR(e.tipo = 'audio') AS audio,
+				COALESCE(SUM(e.tiempo_segundos) FILTER (WHERE e.tipo = 'tiempo'), 0) AS tiempo
+			FROM learning_plan_component_events e
+			JOIN learning_plans p ON p.id = e.learning_plan_id
+			WHERE e.created_at >= ? AND e.created_at < ? %s
+			GROUP BY e.learning_plan_id, e.component_id
+		)`, objectiveFilter)
+
+	rowsQuery := views + fmt.Sprintf(`
+		SELECT %s AS "group",
+			COUNT(*) AS vistas,
+			COUNT(*) FILTER (WHERE NOT p.completado AND p.ultimo_componente_id = v.component_id AND p.ultima_actividad < ?) AS abandonos,
+			COALESCE(AVG(v.tiempo), 0) AS tiempo_promedio_seg,
+			COALESCE(AVG(CASE WHEN v.scroll_final THEN 100.0 ELSE 0 END), 0) AS pct_scroll_final,
+			COALESCE(AVG(CASE WHEN v.audio THEN 100.0 ELSE 0 END), 0) AS pct_audio
+		FROM views v
+		JOIN learning_plan_components c ON c.id = v.component_id
+		JOIN learning_plans p ON p.id = v.learning_plan_id
+		GROUP BY 1
+		ORDER BY 1`, groupExpr)
+
+	if err := db.DB.Raw(rowsQuery, append(args, inactiveSince)...).Scan(&report.Rows).Error; err != nil {
+		return nil, err
+	}
+	for i := range report.Rows {
+		if report.Rows[i].Vistas > 0 {
+			report.Rows[i].TasaAbandono = float64(report.Rows[i].Abandonos) / float64(report.Rows[i].Vistas)
+		}
+	}
+
+	totalsQuery := views + `
+		SELECT
+			COUNT(DISTINCT p.id) AS planes_iniciados,
+			COUNT(DISTINCT p.id) FILTER (WHERE p.completado) AS planes_completados,
+			COUNT(DISTINCT p.id) FILTER (WHERE NOT p.completado AND p.ultima_actividad < ?) AS planes_abandonados
+		FROM views v
+		JOIN learning_plans p ON p.id = v.learning_plan_id`
+
+	var totals struct {
+		PlanesIniciados   int
+		PlanesCompletados int
+		PlanesAbandonados int
+	}
+	if err := db.DB.Raw(totalsQuery, append(args, inactiveSince)...).Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+	report.PlanesIniciados = totals.PlanesIniciados
+	report.PlanesCompletados = totals.PlanesCompletados
+	report.PlanesAbandonados = totals.PlanesAbandonados
+
+	return report, nil
+}
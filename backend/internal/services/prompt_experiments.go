@@ -0,0 +1,143 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+)
+
+// PromptExperimentRow agrega los resultados de los planes (o componentes) generados con una versión de plantilla
+type PromptExperimentRow struct {
+	Version               string  `json:"version"`
+	Planes                int     `json:"planes"`
+	Componentes           int     `json:"componentes"` // solo en plantillas de componentes
+	PlanesCompletados     int     `json:"planes_completados"`
+	TasaCompletacion      float64 `json:"tasa_completacion"`
+	IntentosCheckpoint    int     `json:"intentos_checkpoint"`
+	AprobadosCheckpoint   int     `json:"aprobados_checkpoint"`
+	TasaAprobacion        float64 `json:"tasa_aprobacion_checkpoint"`
+	ValoracionesPositivas int     `json:"valoraciones_positivas"`
+	ValoracionesNegativas int     `json:"valoraciones_negativas"`
+	SesionesPractica      int     `json:"sesiones_practica"` // prácticas completadas del mismo OA-Bloom después de crear el plan
+	PreguntasRespondidas  int     `json:"preguntas_respondidas"`
+	PreguntasCorrectas    int     `json:"preguntas_correctas"`
+	PrecisionPractica     float64 `json:"precision_practica"`
+}
+
+// PromptExperimentReport compara las versiones de una plantilla para los planes creados entre dos fechas
+type PromptExperimentReport struct {
+	Nombre    string                `json:"nombre"`
+	From      time.Time             `json:"from"`
+	To        time.Time             `json:"to"`
+	Versiones []PromptTemplateInfo  `json:"versiones"` // pesos vigentes
+	Rows      []PromptExperimentRow `json:"rows"`
+}
+
+// GetPromptExperimentReport agrega por versión los planes creados en [from, to): completación, checkpoints,
+// valoraciones y precisión en las prácticas posteriores del mismo objetivo. Para plan_structure la unidad es
+// el plan; para una plantilla de componente, los componentes de ese tipo (y las valoraciones sobre ellos).
+func GetPromptExperimentReport(nombre string, from, to time.Time) (*PromptExperimentReport, error) {
+	if !isPromptTemplateName(nombre) || nombre == models.PromptExamplePersonalization || nombre == models.PromptQuestionHints {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPromptTemplate, nombre)
+	}
+
+	units := `
+		SELECT p.id AS plan_id, p.user_id, p.oa_bloom_objective_id, p.created_at, p.completado,
+			p.prompt_version AS version, NULL::INTEGER AS component_id
+		FROM learning_plans p
+		WHERE p.prompt_version IS NOT NULL AND p.created_at >= ? AND p.created_at < ?`
+	args := []interface{}{from, to}
+	if nombre != models.PromptPlanStructure {
+		units = `
+		SELECT p.id AS plan_id, p.user_id, p.oa_bloom_objective_id, p.created_at, p.completado,
+			c.prompt_version AS version, c.id AS component_id
+		FROM learning_plans p
+		JOIN learning_plan_components c ON c.learning_plan_id = p.id
+		WHERE c.tipo_componente = ? AND c.prompt_version IS NOT NULL AND p.created_at >= ? AND p.created_at < ?`
+		args = []interface{}{nombre, from, to}
+	}
+
+	query := fmt.Sprintf(`
+		WITH units AS (%s),
+		plans AS (
+			SELECT DISTINCT version, plan_id, user_id, oa_bloom_objective_id, created_at
+			FROM units
+		),
+		checkpoints AS (
+			SELECT pl.version, COUNT(a.id) AS intentos, COUNT(a.id) FILTER (WHERE a.aprobado) AS aprobados
+			FROM plans pl
+			JOIN learning_plan_checkpoints cp ON cp.learning_plan_id = pl.plan_id
+			JOIN learning_plan_checkpoint_attempts a ON a.checkpoint_id = cp.id
+			GROUP BY pl.version
+		),
+		feedback AS (
+			SELECT u.version,
+				COUNT(*) FILTER (WHERE f.valoracion = ?) AS positivas,
+				COUNT(*) FILTER (WHERE f.valoracion = ?) AS negativas
+			FROM units u
+			JOIN learning_plan_component_feedback f ON f.learning_plan_id = u.plan_id
+				AND (u.component_id IS NULL OR f.component_id = u.component_id)
+			GROUP BY u.version
+		),
+		practice AS (
+			SELECT version, COUNT(*) AS sesiones,
+				COALESCE(SUM(preguntas_respondidas), 0) AS respondidas,
+				COALESCE(SUM(preguntas_correctas), 0) AS correctas
+			FROM (
+				SELECT DISTINCT pl.version, s.id, s.preguntas_respondidas, s.preguntas_correctas
+				FROM plans pl
+				JOIN practice_sessions s ON s.user_id = pl.user_id
+					AND s.oa_bloom_objective_id = pl.oa_bloom_objective_id
+					AND s.created_at > pl.created_at
+					AND s.estado = 'completado'
+			) sessions
+			GROUP BY version
+		)
+		SELECT v.version, v.planes, v.componentes, v.planes_completados,
+			COALESCE(ck.intentos, 0) AS intentos_checkpoint,
+			COALESCE(ck.aprobados, 0) AS aprobados_checkpoint,
+			COALESCE(fb.positivas, 0) AS valoraciones_positivas,
+			COALESCE(fb.negativas, 0) AS valoraciones_negativas,
+			COALESCE(pr.sesiones, 0) AS sesiones_practica,
+			COALESCE(pr.respondidas, 0) AS preguntas_respondidas,
+			COALESCE(pr.correctas, 0) AS preguntas_correctas
+		FROM (
+			SELECT version,
+				COUNT(DISTINCT plan_id) AS planes,
+				COUNT(DISTINCT component_id) AS componentes,
+				COUNT(DISTINCT plan_id) FILTER (WHERE completado) AS planes_completados
+			FROM units
+			GROUP BY version
+		) v
+		LEFT JOIN checkpoints ck ON ck.version = v.version
+		LEFT JOIN feedback fb ON fb.version = v.version
+		LEFT JOIN practice pr ON pr.version = v.version
+		ORDER BY v.version`, units)
+	args = append(args, models.FeedbackValoracionPositiva, models.FeedbackValoracionNegativa)
+
+	report := &PromptExperimentReport{Nombre: nombre, From: from, To: to, Rows: []PromptExperimentRow{}}
+	for _, info := range ListPromptTemplates() {
+		if info.Nombre == nombre {
+			report.Versiones = append(report.Versiones, info)
+		}
+	}
+
+	if err := db.DB.Raw(query, args...).Scan(&report.Rows).Error; err != nil {
+		return nil, err
+	}
+	for i := range report.Rows {
+		row := &report.Rows[i]
+		if row.Planes > 0 {
+			row.TasaCompletacion = float64(row.PlanesCompletados) / float64(row.Planes)
+		}
+		if row.IntentosCheckpoint > 0 {
+			row.TasaAprobacion = float64(row.AprobadosCheckpoint) / float64(row.IntentosCheckpoint)
+		}
+		if row.PreguntasRespondidas > 0 {
+			row.PrecisionPractica = float64(row.PreguntasCorrectas) / float64(row.PreguntasRespondidas)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,468 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"github.com/platanus-hack-25/lumera_app/prompts"
+	"gorm.io/gorm/clause"
+)
+
+// ErrUnknownPromptTemplate indica un nombre o versión de plantilla que no existe
+var ErrUnknownPromptTemplate = errors.New("unknown prompt template")
+
+// ErrInvalidPromptTemplate indica una plantilla que no compila o falla al renderizar
+var ErrInvalidPromptTemplate = errors.New("invalid prompt template")
+
+// Origen de cada versión de plantilla (de menor a mayor prioridad)
+const (
+	PromptFuenteEmbebida   = "embebida"
+	PromptFuenteDirectorio = "directorio"
+	PromptFuenteDB         = "db"
+)
+
+// promptVersionPattern limita los IDs de versión a lo que cabe en prompt_version
+var promptVersionPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,19}$`)
+
+// PromptComponentType es un tipo de componente permitido, tal como se lista en plan_structure
+type PromptComponentType struct {
+	Tipo        string
+	Descripcion string
+}
+
+// PromptData son los datos disponibles en las plantillas: todos los campos de OAContext
+// más los propios de cada prompt
+type PromptData struct {
+	OAContext
+	Objetivo        string                // objetivo específico del componente
+	TiposComponente []PromptComponentType // plan_structure: tipos permitidos para el nivel de Bloom
+	PreguntasBanco  string                // GuidedPracticeQuiz: preguntas del banco en JSON
+	Ejemplos        string                // example_personalization: ejemplos a reescribir en JSON
+	Pregunta        string                // question_hints: pregunta del banco con su respuesta en JSON
+	CantidadPistas  int                   // question_hints: pistas a escribir
+}
+
+// PromptTemplateInfo describe una versión cargada y su parte del experimento
+type PromptTemplateInfo struct {
+	Nombre     string  `json:"nombre"`
+	Version    string  `json:"version"`
+	Fuente     string  `json:"fuente"`
+	Peso       int     `json:"peso"`
+	Porcentaje float64 `json:"porcentaje"` // porcentaje de los estudiantes asignados a esta versión
+}
+
+// PromptTemplateInput es el cuerpo para crear o editar una versión desde el admin.
+// Sin contenido solo se cambia el peso de una versión existente.
+type PromptTemplateInput struct {
+	Contenido string `json:"contenido,omitempty"`
+	Peso      int    `json:"peso"`
+}
+
+type promptVersion struct {
+	info PromptTemplateInfo
+	tmpl *template.Template
+}
+
+// promptRegistry guarda las versiones de cada plantilla, ordenadas de la más antigua a la más nueva
+type promptRegistry struct {
+	partials string
+	versions map[string][]*promptVersion
+}
+
+var (
+	promptsMu       sync.RWMutex
+	promptTemplates *promptRegistry
+)
+
+// promptTemplateNames son las plantillas que el backend necesita
+func promptTemplateNames() []string {
+	return append([]string{models.PromptPlanStructure, models.PromptExamplePersonalization, models.PromptQuestionHints}, models.AvailableComponentTypes()...)
+}
+
+func isPromptTemplateName(nombre string) bool {
+	return containsString(promptTemplateNames(), nombre)
+}
+
+// comparePromptVersions ordena "v2" antes que "v10"
+func comparePromptVersions(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+type promptSource struct {
+	fuente string
+	fsys   fs.FS
+}
+
+type promptFile struct {
+	contenido string
+	fuente    string
+}
+
+// InitPromptTemplates carga las plantillas embebidas, las de PROMPT_TEMPLATES_DIR y las de la tabla prompt_templates
+func InitPromptTemplates() error {
+	registry, err := loadPromptRegistry()
+	if err != nil {
+		return err
+	}
+	setPromptRegistry(registry)
+	log.Printf("✓ Prompt templates loaded (%d versions)", len(registry.list()))
+	return nil
+}
+
+// ReloadPromptTemplates vuelve a cargar las plantillas. Si alguna no compila se mantienen las anteriores.
+// Después borra del caché compartido el contenido de versiones que ya no se asignan.
+func ReloadPromptTemplates() ([]PromptTemplateInfo, error) {
+	registry, err := loadPromptRegistry()
+	if err != nil {
+		return nil, err
+	}
+	setPromptRegistry(registry)
+	PurgeStaleContentCache()
+	return registry.list(), nil
+}
+
+// ListPromptTemplates lista las versiones cargadas con su peso en el experimento
+func ListPromptTemplates() []PromptTemplateInfo {
+	return currentPrompts().list()
+}
+
+// AssignedPromptVersion retorna la versión de la plantilla que le corresponde al usuario.
+// La asignación es determinista: el mismo usuario recibe la misma versión mientras no cambien los pesos.
+func AssignedPromptVersion(nombre string, userID uint) string {
+	if version := currentPrompts().assign(nombre, userID); version != nil {
+		return version.info.Version
+	}
+	return ""
+}
+
+// SavePromptTemplate crea o edita una versión en la tabla prompt_templates y recarga las plantillas
+func SavePromptTemplate(adminID uint, nombre, version string, input PromptTemplateInput) (*models.PromptTemplate, error) {
+	if !isPromptTemplateName(nombre) {
+		return nil, ErrUnknownPromptTemplate
+	}
+	if !promptVersionPattern.MatchString(version) {
+		return nil, fmt.Errorf("%w: version must match %s", ErrInvalidPromptTemplate, promptVersionPattern)
+	}
+	if input.Peso < 0 {
+		return nil, fmt.Errorf("%w: peso must be >= 0", ErrInvalidPromptTemplate)
+	}
+
+	registry := currentPrompts()
+	if input.Contenido == "" {
+		if registry.find(nombre, version) == nil {
+			return nil, fmt.Errorf("%w: contenido is required for a new version", ErrInvalidPromptTemplate)
+		}
+	} else if _, err := compilePromptTemplate(registry.partials, nombre, version, input.Contenido); err != nil {
+		return nil, err
+	}
+
+	row := models.PromptTemplate{
+		Nombre:         nombre,
+		Version:        version,
+		Contenido:      input.Contenido,
+		Peso:           input.Peso,
+		ActualizadoPor: &adminID,
+	}
+	if err := db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "nombre"}, {Name: "version"}},
+		DoUpdates: clause.AssignmentColumns([]string{"contenido", "peso", "actualizado_por", "updated_at"}),
+	}).Create(&row).Error; err != nil {
+		return nil, err
+	}
+
+	if _, err := ReloadPromptTemplates(); err != nil {
+		return nil, err
+	}
+	log.Printf("📝 Prompt template %s/%s saved by admin %d (peso %d)", nombre, version, adminID, input.Peso)
+	return &row, nil
+}
+
+// renderPrompt renderiza la versión asignada al usuario y retorna el prompt y la versión usada
+func renderPrompt(nombre string, userID uint, data PromptData) (string, string, error) {
+	version := currentPrompts().assign(nombre, userID)
+	if version == nil {
+		return "", "", fmt.Errorf("%w: %s", ErrUnknownPromptTemplate, nombre)
+	}
+
+	var buf bytes.Buffer
+	if err := version.tmpl.Execute(&buf, data); err != nil {
+		return "", "", fmt.Errorf("failed to render prompt %s/%s: %w", nombre, version.info.Version, err)
+	}
+	return buf.String(), version.info.Version, nil
+}
+
+func setPromptRegistry(registry *promptRegistry) {
+	promptsMu.Lock()
+	promptTemplates = registry
+	promptsMu.Unlock()
+}
+
+// currentPrompts retorna las plantillas cargadas; si nadie llamó a InitPromptTemplates (p. ej. en tests)
+// carga las embebidas
+func currentPrompts() *promptRegistry {
+	promptsMu.RLock()
+	registry := promptTemplates
+	promptsMu.RUnlock()
+	if registry != nil {
+		return registry
+	}
+
+	registry, err := loadPromptRegistry()
+	if err != nil {
+		log.Fatalf("Failed to load prompt templates: %v", err)
+	}
+	setPromptRegistry(registry)
+	return registry
+}
+
+// loadPromptRegistry lee y compila todas las plantillas. Prioridad: tabla prompt_templates,
+// PROMPT_TEMPLATES_DIR y por último las embebidas en el binario.
+func loadPromptRegistry() (*promptRegistry, error) {
+	sources := []promptSource{{fuente: PromptFuenteEmbebida, fsys: prompts.FS}}
+	if dir := os.Getenv("PROMPT_TEMPLATES_DIR"); dir != "" {
+		sources = append(sources, promptSource{fuente: PromptFuenteDirectorio, fsys: os.DirFS(dir)})
+	}
+
+	partials := ""
+	files := make(map[string]map[string]promptFile)
+	weights := make(map[string]map[string]int)
+	for _, source := range sources {
+		content, err := fs.ReadFile(source.fsys, "partials.tmpl")
+		if err == nil {
+			partials += string(content) + "\n"
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+
+		content, err = fs.ReadFile(source.fsys, "experiments.json")
+		if err == nil {
+			var experiments map[string]map[string]int
+			if err := json.Unmarshal(content, &experiments); err != nil {
+				return nil, fmt.Errorf("invalid experiments.json (%s): %w", source.fuente, err)
+			}
+			for nombre, arms := range experiments {
+				weights[nombre] = arms
+			}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+
+		matches, err := fs.Glob(source.fsys, "*/*.tmpl")
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			nombre := path.Dir(match)
+			if !isPromptTemplateName(nombre) {
+				return nil, fmt.Errorf("%w: %s (%s)", ErrUnknownPromptTemplate, match, source.fuente)
+			}
+			content, err := fs.ReadFile(source.fsys, match)
+			if err != nil {
+				return nil, err
+			}
+			if files[nombre] == nil {
+				files[nombre] = make(map[string]promptFile)
+			}
+			files[nombre][strings.TrimSuffix(path.Base(match), ".tmpl")] = promptFile{contenido: string(content), fuente: source.fuente}
+		}
+	}
+
+	// Sin pesos configurados se usa siempre la versión más nueva
+	for nombre, versions := range files {
+		if _, ok := weights[nombre]; ok {
+			continue
+		}
+		latest := ""
+		for version := range versions {
+			if latest == "" || comparePromptVersions(latest, version) {
+				latest = version
+			}
+		}
+		weights[nombre] = map[string]int{latest: 100}
+	}
+
+	// Las filas de prompt_templates reemplazan el contenido y el peso de su versión
+	if db.DB != nil {
+		var rows []models.PromptTemplate
+		if err := db.DB.Find(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to load prompt_templates: %w", err)
+		}
+		for _, row := range rows {
+			if files[row.Nombre] == nil {
+				files[row.Nombre] = make(map[string]promptFile)
+			}
+			if row.Contenido != "" {
+				files[row.Nombre][row.Version] = promptFile{contenido: row.Contenido, fuente: PromptFuenteDB}
+			}
+			if weights[row.Nombre] == nil {
+				weights[row.Nombre] = make(map[string]int)
+			}
+			weights[row.Nombre][row.Version] = row.Peso
+		}
+	}
+
+	registry := &promptRegistry{partials: partials, versions: make(map[string][]*promptVersion)}
+	for _, nombre := range promptTemplateNames() {
+		if len(files[nombre]) == 0 {
+			return nil, fmt.Errorf("%w: no versions of %s", ErrUnknownPromptTemplate, nombre)
+		}
+		for version, file := range files[nombre] {
+			tmpl, err := compilePromptTemplate(partials, nombre, version, file.contenido)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file.fuente, err)
+			}
+			registry.versions[nombre] = append(registry.versions[nombre], &promptVersion{
+				info: PromptTemplateInfo{Nombre: nombre, Version: version, Fuente: file.fuente, Peso: weights[nombre][version]},
+				tmpl: tmpl,
+			})
+		}
+
+		versions := registry.versions[nombre]
+		sort.Slice(versions, func(i, j int) bool {
+			return comparePromptVersions(versions[i].info.Version, versions[j].info.Version)
+		})
+		total := 0
+		for _, v := range versions {
+			total += v.info.Peso
+		}
+		for _, v := range versions {
+			if total > 0 {
+				v.info.Porcentaje = float64(v.info.Peso) * 100 / float64(total)
+			}
+		}
+		if total == 0 {
+			versions[len(versions)-1].info.Porcentaje = 100
+		}
+	}
+
+	return registry, nil
+}
+
+// compilePromptTemplate compila una plantilla junto a los bloques compartidos y la prueba con datos de ejemplo,
+// para detectar campos inexistentes antes de usarla. El salto de línea final del archivo se ignora.
+func compilePromptTemplate(partials, nombre, version, contenido string) (*template.Template, error) {
+	tmpl, err := template.New("partials.tmpl").Parse(partials)
+	if err != nil {
+		return nil, fmt.Errorf("%w: partials: %v", ErrInvalidPromptTemplate, err)
+	}
+	tmpl, err = tmpl.New(nombre + "/" + version).Parse(strings.TrimSuffix(contenido, "\n"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPromptTemplate, err)
+	}
+	if err := tmpl.Execute(io.Discard, samplePromptData()); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPromptTemplate, err)
+	}
+	return tmpl, nil
+}
+
+// samplePromptData llena todos los campos para que la prueba recorra todas las ramas de la plantilla
+func samplePromptData() PromptData {
+	return PromptData{
+		OAContext: OAContext{
+			UserID:              1,
+			OABloomObjectiveID:  1,
+			MateriaNombre:       "Matemática",
+			MateriaDescripcion:  "Números y álgebra",
+			CursoNombre:         "1° Medio",
+			OATitulo:            "Potencias",
+			OADescripcion:       "Mostrar que comprenden las potencias de base racional",
+			BloomLevelNombre:    "Aplicar",
+			BloomLevelNumero:    3,
+			BloomDescripcion:    "Usar procedimientos en situaciones nuevas",
+			ObjetivoEspecifico:  "Resolver problemas con potencias",
+			IndicadoresLogro:    []string{"Calculan potencias"},
+			InteresesPersonales: []string{"fútbol"},
+			ProfesionSoñada:     "ingeniería",
+			FormatoPreferido:    "visual",
+			TipoActividad:       []string{"juegos"},
+			CanalPreferido:      "video",
+			PreguntasPractica:   10,
+			FeedbackEstudiante:  []string{"muy largo"},
+			Referencias:         []CurriculumPassage{{Indice: 1, Titulo: "Programa de Estudio 1° Medio", Texto: "Las potencias de base racional..."}},
+			ConceptosErroneos:   []models.Misconception{{Codigo: "POT-PRODUCTO", Concepto: "una potencia", ConfundidoCon: "multiplicar la base por el exponente", Descripcion: "Calcula 2^3 como 6."}},
+		},
+		Objetivo:        "Calcular potencias de base racional",
+		TiposComponente: []PromptComponentType{{Tipo: models.ComponentTipoExplainAndExplore, Descripcion: "Bloques de contenido"}},
+		PreguntasBanco:  "[]",
+		Ejemplos:        "[]",
+		Pregunta:        "{}",
+		CantidadPistas:  3,
+	}
+}
+
+func (r *promptRegistry) find(nombre, version string) *promptVersion {
+	for _, v := range r.versions[nombre] {
+		if v.info.Version == version {
+			return v
+		}
+	}
+	return nil
+}
+
+// assign elige la versión según un hash de nombre y usuario sobre la suma de pesos.
+// Si ninguna versión tiene peso se usa la más nueva.
+func (r *promptRegistry) assign(nombre string, userID uint) *promptVersion {
+	versions := r.versions[nombre]
+	if len(versions) == 0 {
+		return nil
+	}
+
+	total := 0
+	for _, v := range versions {
+		total += v.info.Peso
+	}
+	if total == 0 {
+		return versions[len(versions)-1]
+	}
+
+	hash := fnv.New32a()
+	fmt.Fprintf(hash, "%s:%d", nombre, userID)
+	bucket := int(hash.Sum32() % uint32(total))
+	for _, v := range versions {
+		if bucket < v.info.Peso {
+			return v
+		}
+		bucket -= v.info.Peso
+	}
+	return versions[len(versions)-1]
+}
+
+// activeVersions retorna las versiones de cada plantilla que pueden asignarse a un estudiante
+func (r *promptRegistry) activeVersions(nombre string) []string {
+	versions := r.versions[nombre]
+	var active []string
+	for _, v := range versions {
+		if v.info.Porcentaje > 0 {
+			active = append(active, v.info.Version)
+		}
+	}
+	return active
+}
+
+func (r *promptRegistry) list() []PromptTemplateInfo {
+	var infos []PromptTemplateInfo
+	for _, nombre := range promptTemplateNames() {
+		for _, v := range r.versions[nombre] {
+			infos = append(infos, v.info)
+		}
+	}
+	return infos
+}
@@ -0,0 +1,560 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"gorm.io/gorm"
+)
+
+// Políticas del bandit sobre el tipo de la recomendación diaria
+const (
+	BanditPolicyOff           = "off"            // sin bandit: el mejor puntaje del ranker
+	BanditPolicyGreedy        = "greedy"         // el tipo de mayor recompensa media
+	BanditPolicyEpsilonGreedy = "epsilon_greedy" // greedy, y con probabilidad epsilon un tipo al azar
+	BanditPolicyThompson      = "thompson"       // muestra de la posterior Beta de cada tipo
+	BanditPolicyRandom        = "random"         // tipo al azar (línea base del evaluador)
+)
+
+// thompsonProbabilitySamples son las simulaciones con que se estima la probabilidad de una elección de Thompson
+const thompsonProbabilitySamples = 200
+
+var ErrInvalidBanditPolicy = errors.New("invalid bandit policy")
+
+// BanditPolicies retorna las políticas válidas
+func BanditPolicies() []string {
+	return []string{BanditPolicyOff, BanditPolicyGreedy, BanditPolicyEpsilonGreedy, BanditPolicyThompson, BanditPolicyRandom}
+}
+
+// banditArmOption es un brazo disponible al decidir: el mejor candidato de un tipo, con su contexto
+// y su puntaje en el ranker. Se guarda en la impresión para reproducir la decisión offline.
+type banditArmOption struct {
+	Tipo     string  `json:"tipo"`
+	Contexto string  `json:"contexto"`
+	Score    float64 `json:"score"`
+}
+
+// banditDecision es el brazo elegido para la recomendación diaria y la probabilidad que tenía
+type banditDecision struct {
+	politica     string
+	probabilidad float64
+	brazos       []banditArmOption
+}
+
+// banditPosterior es Beta(alfa, beta) de la recompensa de un brazo en un contexto
+type banditPosterior struct {
+	alfa float64
+	beta float64
+}
+
+func (p banditPosterior) mean() float64 {
+	return p.alfa / (p.alfa + p.beta)
+}
+
+// banditState son las posteriores por brazo y contexto; las que faltan valen Beta(1, 1)
+type banditState map[string]banditPosterior
+
+func banditKey(tipo, contexto string) string {
+	return tipo + "|" + contexto
+}
+
+func (s banditState) posterior(option banditArmOption) banditPosterior {
+	if p, ok := s[banditKey(option.Tipo, option.Contexto)]; ok {
+		return p
+	}
+	return banditPosterior{alfa: 1, beta: 1}
+}
+
+func (s banditState) update(option banditArmOption, reward float64) {
+	p := s.posterior(option)
+	p.alfa += reward
+	p.beta += 1 - reward
+	s[banditKey(option.Tipo, option.Contexto)] = p
+}
+
+// BanditConfig es la política activa del recomendador diario
+type BanditConfig struct {
+	Politica             string  `json:"politica"`
+	Epsilon              float64 `json:"epsilon"`
+	VentanaHoras         int     `json:"ventana_horas"`         // horas tras la impresión en que cuenta la práctica
+	EscalaDeltaLogro     int     `json:"escala_delta_logro"`    // puntos de porcentaje de logro que valen la recompensa completa
+	IntervaloLiquidacion int     `json:"intervalo_liquidacion"` // minutos entre liquidaciones de recompensas
+}
+
+// BanditReport es la configuración y el estado aprendido del bandit
+type BanditReport struct {
+	Config BanditConfig      `json:"config"`
+	Brazos []BanditArmReport `json:"brazos"`
+}
+
+// BanditArmReport es la posterior de un tipo en un contexto
+type BanditArmReport struct {
+	models.RecommendationBanditArm
+	Media float64 `json:"media"`
+}
+
+// BanditReplayReport es la evaluación offline de una política sobre las decisiones registradas
+type BanditReplayReport struct {
+	Politica             string                  `json:"politica"`
+	Epsilon              float64                 `json:"epsilon"`
+	From                 time.Time               `json:"from"`
+	To                   time.Time               `json:"to"`
+	Eventos              int                     `json:"eventos"`
+	Coincidencias        int                     `json:"coincidencias"`         // eventos en que la política eligió el brazo registrado
+	RecompensaReplay     *float64                `json:"recompensa_replay"`     // recompensa media en las coincidencias
+	EstimacionIPS        *float64                `json:"estimacion_ips"`        // recompensa esperada por inverse propensity scoring
+	RecompensaRegistrada *float64                `json:"recompensa_registrada"` // recompensa media de la política que registró los datos
+	PorBrazo             []BanditReplayArmReport `json:"por_brazo"`
+}
+
+// BanditReplayArmReport cuenta por tipo las elecciones de la política evaluada y las registradas
+type BanditReplayArmReport struct {
+	Tipo            string   `json:"tipo"`
+	Elegidos        int      `json:"elegidos"`
+	Registrados     int      `json:"registrados"`
+	Coincidencias   int      `json:"coincidencias"`
+	RecompensaMedia *float64 `json:"recompensa_media"` // recompensa registrada media del tipo
+}
+
+// loadBanditConfig lee RECOMMENDER_BANDIT_POLICY (thompson), RECOMMENDER_BANDIT_EPSILON_PERCENT (10),
+// RECOMMENDER_REWARD_WINDOW_HOURS (48), RECOMMENDER_REWARD_DELTA_SCALE (20) y RECOMMENDER_REWARD_SWEEP_MINUTES (15)
+func loadBanditConfig() BanditConfig {
+	config := BanditConfig{
+		Politica:             getEnvString("RECOMMENDER_BANDIT_POLICY", BanditPolicyThompson),
+		Epsilon:              float64(getEnvInt("RECOMMENDER_BANDIT_EPSILON_PERCENT", 10)) / 100,
+		VentanaHoras:         getEnvInt("RECOMMENDER_REWARD_WINDOW_HOURS", 48),
+		EscalaDeltaLogro:     getEnvInt("RECOMMENDER_REWARD_DELTA_SCALE", 20),
+		IntervaloLiquidacion: getEnvInt("RECOMMENDER_REWARD_SWEEP_MINUTES", 15),
+	}
+	if !containsString(BanditPolicies(), config.Politica) {
+		log.Printf("⚠ Unknown RECOMMENDER_BANDIT_POLICY %q, using %s", config.Politica, BanditPolicyThompson)
+		config.Politica = BanditPolicyThompson
+	}
+	config.Epsilon = math.Max(0, math.Min(1, config.Epsilon))
+	if config.EscalaDeltaLogro <= 0 {
+		config.EscalaDeltaLogro = 20
+	}
+	return config
+}
+
+// banditContext discretiza las señales del candidato que más cambian la recompensa esperada de un tipo
+func banditContext(c *recommendationCandidate) string {
+	gap := "low"
+	if c.signals.MasteryGap >= 2.0/3 {
+		gap = "high"
+	} else if c.signals.MasteryGap >= 1.0/3 {
+		gap = "mid"
+	}
+	recency := "recent"
+	if c.signals.Recency >= 1 {
+		recency = "stale"
+	}
+	return "gap:" + gap + ",recency:" + recency
+}
+
+// chooseDailyCandidate deja que el bandit elija el tipo de la recomendación diaria entre el mejor candidato
+// de cada tipo (con la variedad de la primera posición)
+func chooseDailyCandidate(candidates []*recommendationCandidate, lastMateriaID uint) ([]*recommendationCandidate, *banditDecision, error) {
+	if len(candidates) == 0 {
+		return nil, nil, nil
+	}
+
+	applyDailyVariety(candidates, lastMateriaID)
+	bestByType := map[string]*recommendationCandidate{}
+	for _, candidate := range candidates {
+		best, ok := bestByType[candidate.recType]
+		if !ok || candidate.score > best.score || (candidate.score == best.score && candidate.oa.ID < best.oa.ID) {
+			bestByType[candidate.recType] = candidate
+		}
+	}
+
+	options := make([]banditArmOption, 0, len(bestByType))
+	for tipo, candidate := range bestByType {
+		options = append(options, banditArmOption{Tipo: tipo, Contexto: banditContext(candidate), Score: math.Round(candidate.score*10000) / 10000})
+	}
+	sort.Slice(options, func(i, j int) bool { return options[i].Tipo < options[j].Tipo })
+
+	config := loadBanditConfig()
+	state, err := loadBanditState()
+	if err != nil {
+		return nil, nil, err
+	}
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	chosen, probability := banditChoose(config.Politica, config.Epsilon, state, options, rng)
+
+	decision := &banditDecision{politica: config.Politica, probabilidad: probability, brazos: options}
+	return []*recommendationCandidate{bestByType[options[chosen].Tipo]}, decision, nil
+}
+
+// applyDailyVariety puntúa los candidatos con la variedad de la primera posición
+func applyDailyVariety(candidates []*recommendationCandidate, lastMateriaID uint) {
+	for _, candidate := range candidates {
+		candidate.signals.Variety = 0.5
+		if candidate.oa.MateriaID != lastMateriaID {
+			candidate.signals.Variety = 1
+		}
+		candidate.score = candidate.base + weightVariety*candidate.signals.Variety
+	}
+}
+
+// banditChoose elige un brazo con la política y retorna su índice y la probabilidad que tenía de ser elegido
+func banditChoose(policy string, epsilon float64, state banditState, options []banditArmOption, rng *rand.Rand) (int, float64) {
+	if policy == BanditPolicyThompson {
+		chosen := thompsonSample(state, options, rng)
+		// La estimación nunca baja de una simulación: el brazo sí salió elegido
+		return chosen, math.Max(banditProbabilities(policy, epsilon, state, options, rng)[chosen], 1.0/thompsonProbabilitySamples)
+	}
+	probabilities := banditProbabilities(policy, epsilon, state, options, rng)
+	chosen := sampleIndex(probabilities, rng)
+	return chosen, probabilities[chosen]
+}
+
+// banditProbabilities es la probabilidad de la política de elegir cada brazo (Thompson se estima simulando)
+func banditProbabilities(policy string, epsilon float64, state banditState, options []banditArmOption, rng *rand.Rand) []float64 {
+	n := len(options)
+	probabilities := make([]float64, n)
+	switch policy {
+	case BanditPolicyRandom:
+		for i := range probabilities {
+			probabilities[i] = 1 / float64(n)
+		}
+	case BanditPolicyThompson:
+		for s := 0; s < thompsonProbabilitySamples; s++ {
+			probabilities[thompsonSample(state, options, rng)] += 1.0 / thompsonProbabilitySamples
+		}
+	case BanditPolicyGreedy, BanditPolicyEpsilonGreedy:
+		if policy == BanditPolicyGreedy {
+			epsilon = 0
+		}
+		greedy := 0
+		for i, option := range options {
+			mean, bestMean := state.posterior(option).mean(), state.posterior(options[greedy]).mean()
+			if mean > bestMean || (mean == bestMean && option.Score > options[greedy].Score) {
+				greedy = i
+			}
+		}
+		for i := range probabilities {
+			probabilities[i] = epsilon / float64(n)
+		}
+		probabilities[greedy] += 1 - epsilon
+	default: // off
+		best := 0
+		for i, option := range options {
+			if option.Score > options[best].Score {
+				best = i
+			}
+		}
+		probabilities[best] = 1
+	}
+	return probabilities
+}
+
+func thompsonSample(state banditState, options []banditArmOption, rng *rand.Rand) int {
+	chosen, best := 0, -1.0
+	for i, option := range options {
+		p := state.posterior(option)
+		if sample := sampleBeta(p.alfa, p.beta, rng); sample > best {
+			chosen, best = i, sample
+		}
+	}
+	return chosen
+}
+
+func sampleIndex(probabilities []float64, rng *rand.Rand) int {
+	r := rng.Float64()
+	for i, p := range probabilities {
+		if r < p {
+			return i
+		}
+		r -= p
+	}
+	// Redondeo: el último brazo con probabilidad
+	for i := len(probabilities) - 1; i >= 0; i-- {
+		if probabilities[i] > 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+// sampleBeta muestrea Beta(a, b) como X/(X+Y) con X ~ Gamma(a), Y ~ Gamma(b)
+func sampleBeta(a, b float64, rng *rand.Rand) float64 {
+	x := sampleGamma(a, rng)
+	y := sampleGamma(b, rng)
+	if x+y == 0 {
+		return 0.5
+	}
+	return x / (x + y)
+}
+
+// sampleGamma muestrea Gamma(shape, 1) con el método de Marsaglia y Tsang
+func sampleGamma(shape float64, rng *rand.Rand) float64 {
+	if shape < 1 {
+		// Gamma(a) = Gamma(a+1) * U^(1/a)
+		return sampleGamma(shape+1, rng) * math.Pow(rng.Float64(), 1/shape)
+	}
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rng.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rng.Float64()
+		if u < 1-0.0331*x*x*x*x || math.Log(u) < 0.5*x*x+d*(1-v+math.Log(v)) {
+			return d * v
+		}
+	}
+}
+
+func loadBanditState() (banditState, error) {
+	var arms []models.RecommendationBanditArm
+	if err := db.DB.Find(&arms).Error; err != nil {
+		return nil, err
+	}
+	state := make(banditState, len(arms))
+	for _, arm := range arms {
+		state[banditKey(arm.Brazo, arm.Contexto)] = banditPosterior{alfa: arm.Alfa, beta: arm.Beta}
+	}
+	return state, nil
+}
+
+// banditReward combina completar una práctica del OA (mitad) y el avance de logro del objetivo (mitad, completa
+// al subir escala puntos)
+func banditReward(completed bool, delta, scale int) float64 {
+	reward := 0.0
+	if completed {
+		reward += 0.5
+	}
+	if delta > 0 {
+		reward += 0.5 * math.Min(1, float64(delta)/float64(scale))
+	}
+	return reward
+}
+
+// SettleRecommendationRewards calcula la recompensa de las recomendaciones diarias cuya ventana ya cerró y
+// actualiza la posterior de su brazo. Retorna cuántas liquidó.
+func SettleRecommendationRewards() (int, error) {
+	config := loadBanditConfig()
+	window := time.Duration(config.VentanaHoras) * time.Hour
+
+	var impressions []models.RecommendationImpression
+	if err := db.DB.Where("politica IS NOT NULL AND dia IS NOT NULL AND recompensada_at IS NULL AND created_at < ?", time.Now().Add(-window)).
+		Order("id").
+		Limit(500).
+		Find(&impressions).Error; err != nil {
+		return 0, err
+	}
+
+	settled := 0
+	for _, impression := range impressions {
+		windowEnd := impression.CreatedAt.Add(window)
+
+		var completedCount int64
+		if err := db.DB.Model(&models.PracticeSession{}).
+			Where("user_id = ? AND oa_id = ? AND estado = ? AND completed_at >= ? AND completed_at < ?",
+				impression.UserID, impression.OAID, "completado", impression.CreatedAt, windowEnd).
+			Count(&completedCount).Error; err != nil {
+			return settled, err
+		}
+
+		// Último porcentaje registrado del objetivo dentro de la ventana
+		delta := 0
+		var history models.StudentOAHistory
+		err := db.DB.Where("user_id = ? AND oa_bloom_objective_id = ? AND porcentaje_logro IS NOT NULL AND created_at >= ? AND created_at < ?",
+			impression.UserID, impression.OABloomObjectiveID, impression.CreatedAt, windowEnd).
+			Order("created_at DESC").
+			First(&history).Error
+		if err == nil {
+			delta = *history.PorcentajeLogro - impression.PorcentajeInicial
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return settled, err
+		}
+
+		completed := completedCount > 0
+		reward := banditReward(completed, delta, config.EscalaDeltaLogro)
+		err = db.DB.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&models.RecommendationImpression{}).
+				Where("id = ? AND recompensada_at IS NULL", impression.ID).
+				Updates(map[string]interface{}{
+					"practica_completada": completed,
+					"delta_logro":         delta,
+					"recompensa":          reward,
+					"recompensada_at":     time.Now(),
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return nil // otra instancia la liquidó
+			}
+			return tx.Exec(`INSERT INTO recommendation_bandit_arms (brazo, contexto, alfa, beta, jugadas, recompensa_total, updated_at)
+				VALUES (?, ?, 1 + ?, 1 + ?, 1, ?, NOW())
+				ON CONFLICT (brazo, contexto) DO UPDATE SET
+					alfa = recommendation_bandit_arms.alfa + EXCLUDED.recompensa_total,
+					beta = recommendation_bandit_arms.beta + 1 - EXCLUDED.recompensa_total,
+					jugadas = recommendation_bandit_arms.jugadas + 1,
+					recompensa_total = recommendation_bandit_arms.recompensa_total + EXCLUDED.recompensa_total,
+					updated_at = NOW()`,
+				impression.RecommendationType, impression.Contexto, reward, 1-reward, reward).Error
+		})
+		if err != nil {
+			return settled, err
+		}
+		settled++
+	}
+	return settled, nil
+}
+
+// StartRecommendationRewardSweeper liquida periódicamente las recompensas del bandit hasta que ctx se cancela
+func StartRecommendationRewardSweeper(ctx context.Context) {
+	interval := time.Duration(loadBanditConfig().IntervaloLiquidacion) * time.Minute
+	if interval <= 0 {
+		log.Println("Recommendation reward sweeper disabled")
+		return
+	}
+
+	sweep := func() {
+		settled, err := SettleRecommendationRewards()
+		if err != nil {
+			log.Printf("⚠ Failed to settle recommendation rewards: %v", err)
+		}
+		if settled > 0 {
+			log.Printf("✓ Settled %d recommendation rewards", settled)
+		}
+	}
+
+	go func() {
+		sweep()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				sweep()
+			}
+		}
+	}()
+}
+
+// GetBanditReport retorna la política activa y las posteriores aprendidas
+func GetBanditReport() (*BanditReport, error) {
+	var arms []models.RecommendationBanditArm
+	if err := db.DB.Order("contexto, brazo").Find(&arms).Error; err != nil {
+		return nil, err
+	}
+	report := &BanditReport{Config: loadBanditConfig(), Brazos: make([]BanditArmReport, len(arms))}
+	for i, arm := range arms {
+		report.Brazos[i] = BanditArmReport{
+			RecommendationBanditArm: arm,
+			Media:                   banditPosterior{alfa: arm.Alfa, beta: arm.Beta}.mean(),
+		}
+	}
+	return report, nil
+}
+
+// ReplayBanditPolicy evalúa offline una política sobre las recomendaciones diarias ya liquidadas entre from y to.
+// Replay: se simula la política con su propio estado (que aprende solo de los eventos en que coincide con el brazo
+// registrado) y se promedia la recompensa de las coincidencias. IPS: recompensa registrada ponderada por la
+// probabilidad de la política sobre la probabilidad registrada. seed hace reproducibles las políticas aleatorias.
+func ReplayBanditPolicy(policy string, epsilon float64, from, to time.Time, seed int64) (*BanditReplayReport, error) {
+	if !containsString(BanditPolicies(), policy) {
+		return nil, fmt.Errorf("%w: %q (valid: %v)", ErrInvalidBanditPolicy, policy, BanditPolicies())
+	}
+	if epsilon < 0 || epsilon > 1 {
+		return nil, fmt.Errorf("%w: epsilon must be between 0 and 1", ErrInvalidBanditPolicy)
+	}
+
+	var impressions []models.RecommendationImpression
+	if err := db.DB.Where("politica IS NOT NULL AND recompensada_at IS NOT NULL AND brazos IS NOT NULL AND created_at >= ? AND created_at < ?", from, to).
+		Order("created_at, id").
+		Find(&impressions).Error; err != nil {
+		return nil, err
+	}
+
+	report := &BanditReplayReport{Politica: policy, Epsilon: epsilon, From: from, To: to, PorBrazo: []BanditReplayArmReport{}}
+	rng := rand.New(rand.NewSource(seed))
+	state := banditState{}
+	arms := map[string]*BanditReplayArmReport{}
+	armRewards := map[string]float64{}
+	arm := func(tipo string) *BanditReplayArmReport {
+		if _, ok := arms[tipo]; !ok {
+			arms[tipo] = &BanditReplayArmReport{Tipo: tipo}
+		}
+		return arms[tipo]
+	}
+
+	var replayReward, ipsSum, loggedSum float64
+	ipsEvents := 0
+	for _, impression := range impressions {
+		var options []banditArmOption
+		if err := json.Unmarshal(impression.Brazos, &options); err != nil || len(options) == 0 {
+			continue
+		}
+		logged := -1
+		for i, option := range options {
+			if option.Tipo == impression.RecommendationType {
+				logged = i
+			}
+		}
+		if logged < 0 || impression.Recompensa == nil {
+			continue
+		}
+		reward := *impression.Recompensa
+
+		report.Eventos++
+		loggedSum += reward
+		arm(impression.RecommendationType).Registrados++
+		armRewards[impression.RecommendationType] += reward
+
+		probabilities := banditProbabilities(policy, epsilon, state, options, rng)
+		if impression.Probabilidad != nil && *impression.Probabilidad > 0 {
+			ipsSum += reward * probabilities[logged] / *impression.Probabilidad
+			ipsEvents++
+		}
+
+		chosen := sampleIndex(probabilities, rng)
+		arm(options[chosen].Tipo).Elegidos++
+		if chosen == logged {
+			report.Coincidencias++
+			arm(options[chosen].Tipo).Coincidencias++
+			replayReward += reward
+			state.update(options[logged], reward)
+		}
+	}
+
+	if report.Coincidencias > 0 {
+		value := replayReward / float64(report.Coincidencias)
+		report.RecompensaReplay = &value
+	}
+	if ipsEvents > 0 {
+		value := ipsSum / float64(ipsEvents)
+		report.EstimacionIPS = &value
+	}
+	if report.Eventos > 0 {
+		value := loggedSum / float64(report.Eventos)
+		report.RecompensaRegistrada = &value
+	}
+	for tipo, row := range arms {
+		if row.Registrados > 0 {
+			value := armRewards[tipo] / float64(row.Registrados)
+			row.RecompensaMedia = &value
+		}
+		report.PorBrazo = append(report.PorBrazo, *row)
+	}
+	sort.Slice(report.PorBrazo, func(i, j int) bool { return report.PorBrazo[i].Tipo < report.PorBrazo[j].Tipo })
+	return report, nil
+}
@@ -0,0 +1,951 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrStudentProfileNotFound = errors.New("student profile not found")
+	ErrRecommendationNotFound = errors.New("recommendation not found")
+)
+
+// Pesos de las señales del recomendador (suman 1 junto con variety)
+const (
+	weightMasteryGap = 0.25
+	weightReviewDue  = 0.30
+	weightReadiness  = 0.20
+	weightRecency    = 0.10
+	weightContinuity = 0.10
+	weightLevelFit   = 0.05
+	weightOrder      = 0.05
+	weightVariety    = 0.10
+)
+
+// Códigos de razón de una recomendación
+const (
+	ReasonReviewDue            = "review_due"
+	ReasonInProgress           = "in_progress"
+	ReasonMasteryGap           = "mastery_gap"
+	ReasonNextLevel            = "next_level"
+	ReasonNewTopic             = "new_topic"
+	ReasonPrerequisitesReady   = "prerequisites_ready"
+	ReasonDiagnosticLevel      = "diagnostic_level"
+	ReasonNotPracticedRecently = "not_practiced_recently"
+	ReasonSubjectVariety       = "subject_variety"
+	ReasonCurriculumOrder      = "curriculum_order"
+)
+
+const (
+	defaultRecommendationLimit = 5
+	maxRecommendationLimit     = 20
+	recommendationQuestions    = 10
+)
+
+// Recommendation es un objetivo OA-Bloom recomendado al estudiante. ID es la impresión registrada,
+// que el cliente devuelve a POST /api/recommendations/{id}/click al abrirla.
+type Recommendation struct {
+	ID                 uint                       `json:"id"`
+	OA                 models.ObjetivoAprendizaje `json:"oa"`
+	OABloomObjective   models.OABloomObjective    `json:"oa_bloom_objective"`
+	BloomLevel         models.BloomLevel          `json:"bloom_level"`
+	Materia            string                     `json:"materia"`
+	RecommendationType string                     `json:"recommendation_type"` // new_topic, next_level, practice_more, review
+	Reason             string                     `json:"reason"`              // explicación principal para mostrar
+	Reasons            []RecommendationReason     `json:"reasons"`
+	Score              float64                    `json:"score"`
+	Signals            RecommendationSignals      `json:"signals"`
+	Priority           int                        `json:"priority"` // 1-5, mayor = más importante
+	EstimatedMinutes   int                        `json:"estimated_minutes"`
+	NumeroPreguntas    int                        `json:"numero_preguntas"`
+	XPReward           int                        `json:"xp_reward"`
+	TokenReward        int                        `json:"token_reward"`
+}
+
+// RecommendationReason explica por qué se recomendó un objetivo
+type RecommendationReason struct {
+	Code    string `json:"code"`
+	Detalle string `json:"detalle"`
+}
+
+// RecommendationSignals son las señales normalizadas (0-1) con que se puntuó el candidato
+type RecommendationSignals struct {
+	MasteryGap float64 `json:"mastery_gap"` // 1 - porcentaje de logro del objetivo
+	ReviewDue  float64 `json:"review_due"`  // cuán vencido está el repaso de un objetivo dominado
+	Readiness  float64 `json:"readiness"`   // fracción de prerequisitos cumplidos
+	Recency    float64 `json:"recency"`     // tiempo desde la última práctica del OA (1 = una semana o nunca)
+	Continuity float64 `json:"continuity"`  // el objetivo está en proceso
+	LevelFit   float64 `json:"level_fit"`   // cercanía al nivel Bloom del diagnóstico
+	Order      float64 `json:"order"`       // posición en el currículum de la materia (1 = primero)
+	Variety    float64 `json:"variety"`     // materia distinta a las ya recomendadas y a la última practicada
+}
+
+// RecommendationMetrics resume impresiones y clics del recomendador entre dos fechas
+type RecommendationMetrics struct {
+	From        time.Time                  `json:"from"`
+	To          time.Time                  `json:"to"`
+	Totales     RecommendationMetricsRow   `json:"totales"`
+	PorTipo     []RecommendationMetricsRow `json:"por_tipo"`
+	PorRazon    []RecommendationMetricsRow `json:"por_razon"`
+	PorPosicion []RecommendationMetricsRow `json:"por_posicion"`
+	PorOrigen   []RecommendationMetricsRow `json:"por_origen"`
+}
+
+// RecommendationMetricsRow son las impresiones, clics y CTR de un grupo
+type RecommendationMetricsRow struct {
+	Clave       string   `json:"clave"`
+	Impresiones int64    `json:"impresiones"`
+	Clics       int64    `json:"clics"`
+	CTR         float64  `json:"ctr"`
+	Recompensas int64    `json:"recompensas"`      // impresiones diarias con recompensa ya liquidada
+	Recompensa  *float64 `json:"recompensa_media"` // recompensa media del bandit en esas impresiones
+}
+
+// recommendationCandidate es un objetivo candidato de un OA con sus señales
+type recommendationCandidate struct {
+	oa          models.ObjetivoAprendizaje
+	objective   models.OABloomObjective
+	materia     string
+	recType     string
+	signals     RecommendationSignals
+	base        float64
+	score       float64
+	practiced   bool
+	reviewDays  int
+	porcentaje  int // porcentaje de logro del objetivo al recomendarlo
+	hasPrereqs  bool
+	hasDiagnose bool
+}
+
+// recommendationProgress es el progreso del estudiante en un objetivo Bloom
+type recommendationProgress struct {
+	estado          string
+	porcentajeLogro int
+	ultimaActividad *time.Time
+}
+
+// RecommendDailyOA retorna la recomendación del día. El bandit decide una vez por estudiante y día: las
+// consultas siguientes muestran esa misma impresión, que es la única del día que recibe recompensa.
+func RecommendDailyOA(userID uint) (*Recommendation, error) {
+	if recommendation, err := todaysDailyRecommendation(userID); err != nil || recommendation != nil {
+		return recommendation, err
+	}
+
+	recommendations, err := RecommendOAs(userID, 1, models.RecommendationOrigenDaily)
+	if err != nil {
+		return nil, err
+	}
+	if len(recommendations) == 0 {
+		return nil, ErrRecommendationNotFound
+	}
+	// Otra petición registró la decisión del día al mismo tiempo: se muestra esa
+	if recommendations[0].ID == 0 {
+		if recommendation, err := todaysDailyRecommendation(userID); err == nil && recommendation != nil {
+			return recommendation, nil
+		}
+	}
+	return &recommendations[0], nil
+}
+
+// todaysDailyRecommendation rearma la recomendación de la decisión del día con las señales actuales.
+// Retorna nil si aún no hay decisión o si su objetivo ya no es candidato (p. ej. el OA se desactivó).
+func todaysDailyRecommendation(userID uint) (*Recommendation, error) {
+	var impression models.RecommendationImpression
+	err := db.DB.Where("user_id = ? AND dia = ?", userID, recommendationDay(time.Now())).First(&impression).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, lastMateriaID, err := recommendationCandidatesFor(userID)
+	if err != nil {
+		return nil, err
+	}
+	applyDailyVariety(candidates, lastMateriaID)
+	for _, candidate := range candidates {
+		if candidate.objective.ID == impression.OABloomObjectiveID {
+			recommendation := candidate.toRecommendation()
+			recommendation.ID = impression.ID
+			return &recommendation, nil
+		}
+	}
+	log.Printf("Daily recommendation %d of user %d is no longer a candidate", impression.ID, userID)
+	return nil, nil
+}
+
+// recommendationDay es el día (del servidor) de la decisión diaria, como fecha sin hora
+func recommendationDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// RecommendOAs puntúa los objetivos OA-Bloom de todas las materias del curso del estudiante y retorna
+// los mejores limit, reordenados para variar de materia. Cada recomendación queda registrada como impresión.
+func RecommendOAs(userID uint, limit int, origen string) ([]Recommendation, error) {
+	if limit <= 0 {
+		limit = defaultRecommendationLimit
+	}
+	if limit > maxRecommendationLimit {
+		limit = maxRecommendationLimit
+	}
+
+	candidates, lastMateriaID, err := recommendationCandidatesFor(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// La recomendación diaria la elige el bandit por tipo; la lista sigue el orden del ranker
+	var ranked []*recommendationCandidate
+	var decision *banditDecision
+	if origen == models.RecommendationOrigenDaily {
+		ranked, decision, err = chooseDailyCandidate(candidates, lastMateriaID)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		ranked = rankRecommendationCandidates(candidates, lastMateriaID, limit)
+	}
+
+	recommendations := make([]Recommendation, 0, len(ranked))
+	for _, candidate := range ranked {
+		recommendations = append(recommendations, candidate.toRecommendation())
+	}
+	logRecommendationImpressions(userID, origen, ranked, recommendations, decision)
+	return recommendations, nil
+}
+
+// recommendationCandidatesFor arma los candidatos de las materias del curso actual del estudiante
+func recommendationCandidatesFor(userID uint) ([]*recommendationCandidate, uint, error) {
+	var profile models.StudentProfile
+	if err := db.DB.Where("user_id = ?", userID).First(&profile).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, 0, ErrStudentProfileNotFound
+		}
+		return nil, 0, err
+	}
+	return buildRecommendationCandidates(userID, profile.CursoActual)
+}
+
+// buildRecommendationCandidates arma un candidato por OA activo de las materias del curso, con las señales
+// sin variedad (depende del orden final). Retorna también la materia de la última práctica completada.
+func buildRecommendationCandidates(userID uint, cursoActual string) ([]*recommendationCandidate, uint, error) {
+	materias, err := recommendationMaterias(cursoActual)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(materias) == 0 {
+		return nil, 0, nil
+	}
+	materiaNames := make(map[uint]string, len(materias))
+	materiaIDs := make([]uint, len(materias))
+	for i, materia := range materias {
+		materiaNames[materia.ID] = materia.Nombre
+		materiaIDs[i] = materia.ID
+	}
+
+	var oas []models.ObjetivoAprendizaje
+	if err := db.DB.Where("materia_id IN ? AND activo = ?", materiaIDs, true).
+		Order("materia_id, orden ASC NULLS LAST, codigo").
+		Find(&oas).Error; err != nil {
+		return nil, 0, err
+	}
+	if len(oas) == 0 {
+		return nil, 0, nil
+	}
+	oaIDs := make([]uint, len(oas))
+	for i, oa := range oas {
+		oaIDs[i] = oa.ID
+	}
+
+	var objectives []models.OABloomObjective
+	if err := db.DB.Preload("BloomLevel").Where("oa_id IN ?", oaIDs).Find(&objectives).Error; err != nil {
+		return nil, 0, err
+	}
+	objectivesByOA := map[uint][]models.OABloomObjective{}
+	objectiveIDs := make([]uint, len(objectives))
+	for i, objective := range objectives {
+		objectivesByOA[objective.OAID] = append(objectivesByOA[objective.OAID], objective)
+		objectiveIDs[i] = objective.ID
+	}
+	for oaID := range objectivesByOA {
+		list := objectivesByOA[oaID]
+		sort.Slice(list, func(i, j int) bool { return list[i].BloomLevel.Nivel < list[j].BloomLevel.Nivel })
+	}
+
+	progress, err := loadRecommendationProgress(userID, objectiveIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+	lastPractice, lastMateriaID, err := loadLastPractices(userID, oaIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+	readiness, err := loadPrerequisiteReadiness(userID, oaIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+	diagnosticLevels := loadDiagnosticLevels(userID)
+
+	// Posición de cada OA dentro de su materia (ya vienen ordenados por orden y código)
+	orderSignal := map[uint]float64{}
+	for _, materiaID := range materiaIDs {
+		var inMateria []uint
+		for _, oa := range oas {
+			if oa.MateriaID == materiaID {
+				inMateria = append(inMateria, oa.ID)
+			}
+		}
+		for i, oaID := range inMateria {
+			orderSignal[oaID] = 1
+			if len(inMateria) > 1 {
+				orderSignal[oaID] = 1 - float64(i)/float64(len(inMateria)-1)
+			}
+		}
+	}
+
+	logradoDays := getEnvInt("RECOMMENDER_REVIEW_DAYS_LOGRADO", 7)
+	dominadoDays := getEnvInt("RECOMMENDER_REVIEW_DAYS_DOMINADO", 21)
+	now := time.Now()
+
+	var candidates []*recommendationCandidate
+	for _, oa := range oas {
+		list := objectivesByOA[oa.ID]
+		if len(list) == 0 {
+			continue
+		}
+
+		diagnosticLevel, hasDiagnostic := diagnosticLevels[oa.MateriaID]
+		targetLevel := 2
+		if hasDiagnostic {
+			targetLevel = int(math.Round(diagnosticLevel))
+		}
+		targetLevel = clampInt(targetLevel, 1, 6)
+
+		recency := 1.0
+		practiced := false
+		if last, ok := lastPractice[oa.ID]; ok {
+			practiced = true
+			recency = math.Min(1, now.Sub(last).Hours()/24/7)
+			if now.Sub(last) < 24*time.Hour {
+				recency = 0
+			}
+		}
+
+		base := recommendationCandidate{
+			oa:          oa,
+			materia:     materiaNames[oa.MateriaID],
+			practiced:   practiced,
+			hasDiagnose: hasDiagnostic,
+		}
+		base.signals.Recency = recency
+		base.signals.Order = orderSignal[oa.ID]
+
+		// Un candidato por OA: el siguiente objetivo o el repaso, el que puntúe más
+		var best *recommendationCandidate
+		for _, candidate := range []*recommendationCandidate{
+			frontierCandidate(base, list, progress, targetLevel),
+			reviewCandidate(base, list, progress, now, logradoDays, dominadoDays),
+		} {
+			if candidate == nil {
+				continue
+			}
+			if candidate.recType != models.RecommendationTypeReview {
+				candidate.signals.Readiness, candidate.hasPrereqs = readiness.forObjective(oa.ID, candidate.objective.ID)
+			}
+			candidate.signals.LevelFit = 1 - math.Abs(float64(candidate.objective.BloomLevel.Nivel-targetLevel))/5
+			candidate.base = candidate.weightedScore()
+			if best == nil || candidate.base > best.base {
+				best = candidate
+			}
+		}
+		if best == nil {
+			continue // todo dominado y sin repasos pendientes
+		}
+		candidates = append(candidates, best)
+	}
+
+	// Los OAs con prerequisitos pendientes solo se recomiendan si no queda nada más
+	var ready []*recommendationCandidate
+	for _, candidate := range candidates {
+		if candidate.signals.Readiness >= 1 {
+			ready = append(ready, candidate)
+		}
+	}
+	if len(ready) > 0 {
+		candidates = ready
+	}
+	return candidates, lastMateriaID, nil
+}
+
+// frontierCandidate elige el siguiente objetivo por estudiar del OA: el nivel en proceso más bajo sobre lo ya
+// dominado, el siguiente nivel si no hay nada en proceso o, si el OA no se ha tocado, el nivel del diagnóstico
+func frontierCandidate(base recommendationCandidate, objectives []models.OABloomObjective, progress map[uint]recommendationProgress, targetLevel int) *recommendationCandidate {
+	highestMastered := 0
+	touched := false
+	for _, objective := range objectives {
+		p, ok := progress[objective.ID]
+		if !ok {
+			continue
+		}
+		if p.estado != "no_iniciado" {
+			touched = true
+		}
+		if masteredEstado(p.estado) && objective.BloomLevel.Nivel > highestMastered {
+			highestMastered = objective.BloomLevel.Nivel
+		}
+	}
+
+	candidate := base
+	if !touched {
+		// El objetivo del nivel del diagnóstico o, si el OA no lo tiene, el más cercano
+		chosen := objectives[0]
+		for _, objective := range objectives {
+			if math.Abs(float64(objective.BloomLevel.Nivel-targetLevel)) < math.Abs(float64(chosen.BloomLevel.Nivel-targetLevel)) {
+				chosen = objective
+			}
+		}
+		candidate.objective = chosen
+		candidate.recType = models.RecommendationTypeNewTopic
+		candidate.signals.MasteryGap = 1
+		return &candidate
+	}
+
+	for _, objective := range objectives {
+		if objective.BloomLevel.Nivel <= highestMastered {
+			continue
+		}
+		if p := progress[objective.ID]; p.estado == "en_proceso" {
+			candidate.objective = objective
+			candidate.recType = models.RecommendationTypePracticeMore
+			candidate.porcentaje = p.porcentajeLogro
+			candidate.signals.MasteryGap = 1 - float64(p.porcentajeLogro)/100
+			candidate.signals.Continuity = 1
+			return &candidate
+		}
+	}
+	for _, objective := range objectives {
+		if objective.BloomLevel.Nivel <= highestMastered {
+			continue
+		}
+		candidate.objective = objective
+		candidate.recType = models.RecommendationTypeNextLevel
+		if highestMastered == 0 {
+			candidate.recType = models.RecommendationTypePracticeMore
+		}
+		candidate.porcentaje = progress[objective.ID].porcentajeLogro
+		candidate.signals.MasteryGap = 1 - float64(candidate.porcentaje)/100
+		return &candidate
+	}
+	return nil
+}
+
+// reviewCandidate elige el objetivo dominado de mayor nivel cuyo repaso está vencido: logrado cada
+// RECOMMENDER_REVIEW_DAYS_LOGRADO días (7) y dominado cada RECOMMENDER_REVIEW_DAYS_DOMINADO (21)
+func reviewCandidate(base recommendationCandidate, objectives []models.OABloomObjective, progress map[uint]recommendationProgress, now time.Time, logradoDays, dominadoDays int) *recommendationCandidate {
+	var best *recommendationCandidate
+	for _, objective := range objectives {
+		p, ok := progress[objective.ID]
+		if !ok || !masteredEstado(p.estado) || p.ultimaActividad == nil {
+			continue
+		}
+		interval := logradoDays
+		if p.estado == "dominado" {
+			interval = dominadoDays
+		}
+		if interval <= 0 {
+			continue
+		}
+		days := now.Sub(*p.ultimaActividad).Hours() / 24
+		if days < float64(interval) {
+			continue
+		}
+		candidate := base
+		candidate.objective = objective
+		candidate.recType = models.RecommendationTypeReview
+		candidate.reviewDays = int(days)
+		candidate.porcentaje = p.porcentajeLogro
+		candidate.signals.MasteryGap = 1 - float64(p.porcentajeLogro)/100
+		// Recién vencido vale 0.5 y llega a 1 con el doble del intervalo
+		candidate.signals.ReviewDue = math.Min(1, days/float64(2*interval))
+		candidate.signals.Readiness = 1
+		if best == nil || objective.BloomLevel.Nivel > best.objective.BloomLevel.Nivel {
+			best = &candidate
+		}
+	}
+	return best
+}
+
+// weightedScore combina las señales salvo la variedad, que se suma al reordenar
+func (c *recommendationCandidate) weightedScore() float64 {
+	s := c.signals
+	return weightMasteryGap*s.MasteryGap +
+		weightReviewDue*s.ReviewDue +
+		weightReadiness*s.Readiness +
+		weightRecency*s.Recency +
+		weightContinuity*s.Continuity +
+		weightLevelFit*s.LevelFit +
+		weightOrder*s.Order
+}
+
+// rankRecommendationCandidates ordena por puntaje y elige de a uno sumando la variedad: media señal por
+// una materia aún no elegida y media por no ser la materia de la última práctica
+func rankRecommendationCandidates(candidates []*recommendationCandidate, lastMateriaID uint, limit int) []*recommendationCandidate {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].base != candidates[j].base {
+			return candidates[i].base > candidates[j].base
+		}
+		return candidates[i].oa.ID < candidates[j].oa.ID
+	})
+
+	chosenMaterias := map[uint]bool{}
+	used := make([]bool, len(candidates))
+	var ranked []*recommendationCandidate
+	for len(ranked) < limit && len(ranked) < len(candidates) {
+		bestIndex := -1
+		bestScore := 0.0
+		bestVariety := 0.0
+		for i, candidate := range candidates {
+			if used[i] {
+				continue
+			}
+			variety := 0.0
+			if !chosenMaterias[candidate.oa.MateriaID] {
+				variety += 0.5
+			}
+			if candidate.oa.MateriaID != lastMateriaID {
+				variety += 0.5
+			}
+			score := candidate.base + weightVariety*variety
+			if bestIndex < 0 || score > bestScore {
+				bestIndex, bestScore, bestVariety = i, score, variety
+			}
+		}
+		used[bestIndex] = true
+		candidate := candidates[bestIndex]
+		candidate.signals.Variety = bestVariety
+		candidate.score = bestScore
+		chosenMaterias[candidate.oa.MateriaID] = true
+		ranked = append(ranked, candidate)
+	}
+	return ranked
+}
+
+// reasons explica la recomendación; la primera es la principal
+func (c *recommendationCandidate) reasons() []RecommendationReason {
+	bloom := strings.ToLower(c.objective.BloomLevel.Nombre)
+	var reasons []RecommendationReason
+	switch c.recType {
+	case models.RecommendationTypeReview:
+		reasons = append(reasons, RecommendationReason{ReasonReviewDue, fmt.Sprintf("Repasa este objetivo: lo dominaste y no lo practicas hace %d días", c.reviewDays)})
+	case models.RecommendationTypePracticeMore:
+		reasons = append(reasons, RecommendationReason{ReasonInProgress, "Continúa practicando para dominar este objetivo"})
+	case models.RecommendationTypeNextLevel:
+		reasons = append(reasons, RecommendationReason{ReasonNextLevel, fmt.Sprintf("Ya dominas el nivel anterior; sube al nivel %s", bloom)})
+	default:
+		reasons = append(reasons, RecommendationReason{ReasonNewTopic, "Nuevo objetivo recomendado para ti"})
+	}
+
+	if c.recType != models.RecommendationTypeNewTopic && c.recType != models.RecommendationTypeReview && c.signals.MasteryGap >= 0.5 {
+		reasons = append(reasons, RecommendationReason{ReasonMasteryGap, fmt.Sprintf("Llevas %d%% de logro en este nivel", int(math.Round((1-c.signals.MasteryGap)*100)))})
+	}
+	if c.hasPrereqs && c.signals.Readiness >= 1 {
+		reasons = append(reasons, RecommendationReason{ReasonPrerequisitesReady, "Ya dominas los objetivos previos que necesita"})
+	}
+	if c.recType == models.RecommendationTypeNewTopic && c.hasDiagnose {
+		reasons = append(reasons, RecommendationReason{ReasonDiagnosticLevel, fmt.Sprintf("Está en el nivel %s, según tu diagnóstico", bloom)})
+	}
+	if c.practiced && c.signals.Recency >= 1 {
+		reasons = append(reasons, RecommendationReason{ReasonNotPracticedRecently, "No lo practicas hace más de una semana"})
+	}
+	if c.signals.Variety >= 1 {
+		reasons = append(reasons, RecommendationReason{ReasonSubjectVariety, fmt.Sprintf("Cambia de materia con %s", c.materia)})
+	}
+	if c.recType == models.RecommendationTypeNewTopic && c.signals.Order >= 0.8 {
+		reasons = append(reasons, RecommendationReason{ReasonCurriculumOrder, "Es de los primeros objetivos de la materia"})
+	}
+	return reasons
+}
+
+func (c *recommendationCandidate) toRecommendation() Recommendation {
+	reasons := c.reasons()
+	numPreguntas := recommendationQuestions
+	return Recommendation{
+		OA:                 c.oa,
+		OABloomObjective:   c.objective,
+		BloomLevel:         c.objective.BloomLevel,
+		Materia:            c.materia,
+		RecommendationType: c.recType,
+		Reason:             reasons[0].Detalle,
+		Reasons:            reasons,
+		Score:              math.Round(c.score*10000) / 10000,
+		Signals:            c.signals,
+		Priority:           clampInt(int(math.Ceil(c.score*5)), 1, 5),
+		EstimatedMinutes:   practiceMinutes(numPreguntas),
+		NumeroPreguntas:    numPreguntas,
+		XPReward:           numPreguntas * 5, // 5 XP por pregunta
+		TokenReward:        numPreguntas / 5, // 1 token cada 5 preguntas
+	}
+}
+
+// practiceMinutes estima la duración de una práctica: 2 minutos por pregunta
+func practiceMinutes(numPreguntas int) int {
+	return numPreguntas * 2
+}
+
+// recommendationMaterias son las materias activas del curso del estudiante; si CursoActual no calza
+// con ningún curso, todas las materias activas
+func recommendationMaterias(cursoActual string) ([]models.Materia, error) {
+	var cursos []models.Curso
+	if err := db.DB.Where("activo = ?", true).Order("id").Find(&cursos).Error; err != nil {
+		return nil, err
+	}
+
+	var materias []models.Materia
+	if curso := matchCurso(cursos, cursoActual); curso != nil {
+		err := db.DB.Joins("JOIN curso_materias cm ON cm.materia_id = materias.id").
+			Where("cm.curso_id = ? AND materias.activo = ?", curso.ID, true).
+			Order("materias.id").
+			Find(&materias).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(materias) > 0 {
+			return materias, nil
+		}
+	}
+	err := db.DB.Where("activo = ?", true).Order("id").Find(&materias).Error
+	return materias, err
+}
+
+// matchCurso busca el curso por nombre exacto y si no por número u ordinal ("1ro Medio", "primero"),
+// igual que findCursoByName del frontend
+func matchCurso(cursos []models.Curso, cursoActual string) *models.Curso {
+	normalized := strings.ToLower(strings.TrimSpace(cursoActual))
+	if normalized == "" {
+		return nil
+	}
+	for i := range cursos {
+		if strings.ToLower(cursos[i].Nombre) == normalized {
+			return &cursos[i]
+		}
+	}
+
+	ordinals := []struct{ digit, word, codigo string }{
+		{"1", "primero", "1M"},
+		{"2", "segundo", "2M"},
+		{"3", "tercero", "3M"},
+		{"4", "cuarto", "4M"},
+	}
+	for _, ordinal := range ordinals {
+		if !strings.Contains(normalized, ordinal.digit) && !strings.Contains(normalized, ordinal.word) {
+			continue
+		}
+		for i := range cursos {
+			if cursos[i].Codigo == ordinal.codigo || strings.Contains(strings.ToLower(cursos[i].Nombre), ordinal.word) {
+				return &cursos[i]
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// loadRecommendationProgress retorna el progreso del estudiante por objetivo Bloom
+func loadRecommendationProgress(userID uint, objectiveIDs []uint) (map[uint]recommendationProgress, error) {
+	progress := map[uint]recommendationProgress{}
+	if len(objectiveIDs) == 0 {
+		return progress, nil
+	}
+	var rows []models.StudentOAProgress
+	if err := db.DB.Where("user_id = ? AND oa_bloom_objective_id IN ?", userID, objectiveIDs).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		progress[row.OABloomObjectiveID] = recommendationProgress{
+			estado:          row.Estado,
+			porcentajeLogro: row.PorcentajeLogro,
+			ultimaActividad: row.UltimaActividadFecha,
+		}
+	}
+	return progress, nil
+}
+
+// loadLastPractices retorna la última práctica completada de cada OA y la materia de la más reciente
+func loadLastPractices(userID uint, oaIDs []uint) (map[uint]time.Time, uint, error) {
+	var rows []struct {
+		OAID        uint `gorm:"column:oa_id"`
+		CompletedAt time.Time
+	}
+	err := db.DB.Model(&models.PracticeSession{}).
+		Select("oa_id, MAX(completed_at) AS completed_at").
+		Where("user_id = ? AND estado = ? AND completed_at IS NOT NULL AND oa_id IN ?", userID, "completado", oaIDs).
+		Group("oa_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	lastPractice := make(map[uint]time.Time, len(rows))
+	var lastOAID uint
+	var lastAt time.Time
+	for _, row := range rows {
+		lastPractice[row.OAID] = row.CompletedAt
+		if row.CompletedAt.After(lastAt) {
+			lastOAID, lastAt = row.OAID, row.CompletedAt
+		}
+	}
+
+	var lastMateriaID uint
+	if lastOAID != 0 {
+		var oa models.ObjetivoAprendizaje
+		if err := db.DB.Select("id, materia_id").First(&oa, lastOAID).Error; err != nil {
+			return nil, 0, err
+		}
+		lastMateriaID = oa.MateriaID
+	}
+	return lastPractice, lastMateriaID, nil
+}
+
+// loadDiagnosticLevels retorna el nivel Bloom promedio del último diagnóstico completado de cada materia
+func loadDiagnosticLevels(userID uint) map[uint]float64 {
+	var sessions []models.DiagnosticSession
+	db.DB.Where("user_id = ? AND estado = ?", userID, "completado").
+		Order("completed_at ASC").
+		Find(&sessions)
+
+	levels := make(map[uint]float64)
+	for _, session := range sessions {
+		var estrategia map[string]interface{}
+		if err := json.Unmarshal(session.Estrategia, &estrategia); err == nil {
+			if avgBloom, ok := estrategia["average_bloom_level"].(float64); ok {
+				levels[session.MateriaID] = avgBloom
+			}
+		}
+	}
+	return levels
+}
+
+// prerequisiteReadiness son las aristas de prerequisitos de los OAs candidatos y si cada una se cumple
+type prerequisiteReadiness struct {
+	edges     map[uint][]models.OAPrerequisite
+	satisfied map[uint]bool // por ID de arista
+}
+
+// loadPrerequisiteReadiness evalúa las aristas hacia los OAs con el dominio del estudiante, con el mismo
+// criterio que el mapa curricular
+func loadPrerequisiteReadiness(userID uint, oaIDs []uint) (*prerequisiteReadiness, error) {
+	readiness := &prerequisiteReadiness{edges: map[uint][]models.OAPrerequisite{}, satisfied: map[uint]bool{}}
+	var edges []models.OAPrerequisite
+	if err := db.DB.Where("oa_id IN ?", oaIDs).Find(&edges).Error; err != nil {
+		return nil, err
+	}
+	if len(edges) == 0 {
+		return readiness, nil
+	}
+
+	var prerequisiteIDs []uint
+	for _, edge := range edges {
+		if !containsUint(prerequisiteIDs, edge.PrerequisitoOAID) {
+			prerequisiteIDs = append(prerequisiteIDs, edge.PrerequisitoOAID)
+		}
+	}
+	mastery, err := loadOAMastery(userID, prerequisiteIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, edge := range edges {
+		satisfied := false
+		for _, bloom := range mastery[edge.PrerequisitoOAID] {
+			if edge.PrerequisitoOABloomObjectiveID != nil && bloom.OABloomObjectiveID != *edge.PrerequisitoOABloomObjectiveID {
+				continue
+			}
+			if masteredEstado(bloom.Estado) {
+				satisfied = true
+			}
+		}
+		readiness.edges[edge.OAID] = append(readiness.edges[edge.OAID], edge)
+		readiness.satisfied[edge.ID] = satisfied
+	}
+	return readiness, nil
+}
+
+// forObjective retorna la fracción de prerequisitos cumplidos por un objetivo del OA (1 sin prerequisitos)
+// y si tiene alguno. Las aristas acotadas a otro objetivo Bloom del OA no cuentan.
+func (p *prerequisiteReadiness) forObjective(oaID, objectiveID uint) (float64, bool) {
+	total, met := 0, 0
+	for _, edge := range p.edges[oaID] {
+		if edge.OABloomObjectiveID != nil && *edge.OABloomObjectiveID != objectiveID {
+			continue
+		}
+		total++
+		if p.satisfied[edge.ID] {
+			met++
+		}
+	}
+	if total == 0 {
+		return 1, false
+	}
+	return float64(met) / float64(total), true
+}
+
+// logRecommendationImpressions guarda las recomendaciones mostradas (con la decisión del bandit, si la hubo)
+// y asigna su ID. Si falla, las recomendaciones igual se entregan (sin ID, no se podrán registrar sus clics).
+func logRecommendationImpressions(userID uint, origen string, ranked []*recommendationCandidate, recommendations []Recommendation, decision *banditDecision) {
+	if len(recommendations) == 0 {
+		return
+	}
+	lote, err := newRecommendationBatchID()
+	if err != nil {
+		log.Printf("Error generating recommendation batch id: %v", err)
+		return
+	}
+
+	impressions := make([]models.RecommendationImpression, len(recommendations))
+	for i, recommendation := range recommendations {
+		codes := make(models.StringArray, len(recommendation.Reasons))
+		for j, reason := range recommendation.Reasons {
+			codes[j] = reason.Code
+		}
+		signals, _ := json.Marshal(recommendation.Signals)
+		impressions[i] = models.RecommendationImpression{
+			UserID:             userID,
+			Lote:               lote,
+			Origen:             origen,
+			Posicion:           i + 1,
+			MateriaID:          recommendation.OA.MateriaID,
+			OAID:               recommendation.OA.ID,
+			OABloomObjectiveID: recommendation.OABloomObjective.ID,
+			RecommendationType: recommendation.RecommendationType,
+			Score:              recommendation.Score,
+			Reasons:            codes,
+			Signals:            signals,
+			Contexto:           banditContext(ranked[i]),
+			PorcentajeInicial:  ranked[i].porcentaje,
+		}
+		if decision != nil {
+			dia := recommendationDay(time.Now())
+			impressions[i].Dia = &dia
+			brazos, _ := json.Marshal(decision.brazos)
+			probabilidad := math.Round(decision.probabilidad*10000) / 10000
+			impressions[i].Politica = &decision.politica
+			impressions[i].Probabilidad = &probabilidad
+			impressions[i].Brazos = brazos
+		}
+	}
+	if err := db.DB.Create(&impressions).Error; err != nil {
+		log.Printf("Error logging recommendation impressions for user %d: %v", userID, err)
+		return
+	}
+	for i := range recommendations {
+		recommendations[i].ID = impressions[i].ID
+	}
+}
+
+func newRecommendationBatchID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// RecordRecommendationClick marca la impresión como abierta. Repetir el clic no cambia la fecha.
+func RecordRecommendationClick(userID, impressionID uint) error {
+	var impression models.RecommendationImpression
+	if err := db.DB.Where("id = ? AND user_id = ?", impressionID, userID).First(&impression).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRecommendationNotFound
+		}
+		return err
+	}
+	if impression.ClickedAt != nil {
+		return nil
+	}
+	return db.DB.Model(&models.RecommendationImpression{}).
+		Where("id = ? AND clicked_at IS NULL", impression.ID).
+		Update("clicked_at", time.Now()).Error
+}
+
+// GetRecommendationMetrics retorna impresiones, clics, CTR y recompensa media por tipo, código de razón,
+// posición y origen
+func GetRecommendationMetrics(from, to time.Time) (*RecommendationMetrics, error) {
+	metrics := &RecommendationMetrics{From: from, To: to}
+	base := func() *gorm.DB {
+		return db.DB.Model(&models.RecommendationImpression{}).Where("created_at >= ? AND created_at < ?", from, to)
+	}
+	counts := "COUNT(*) AS impresiones, COUNT(clicked_at) AS clics, COUNT(recompensa) AS recompensas, AVG(recompensa) AS recompensa"
+
+	var totals []RecommendationMetricsRow
+	if err := base().Select("'total' AS clave, " + counts).Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+	if len(totals) > 0 {
+		metrics.Totales = totals[0]
+	}
+	metrics.Totales.Clave = "total"
+
+	groups := []struct {
+		target *[]RecommendationMetricsRow
+		join   string
+		expr   string
+		order  string
+	}{
+		{&metrics.PorTipo, "", "recommendation_type", "clave"},
+		{&metrics.PorRazon, "CROSS JOIN LATERAL UNNEST(reasons) AS razon(codigo)", "razon.codigo", "clave"},
+		{&metrics.PorPosicion, "", "posicion::text", "MIN(posicion)"},
+		{&metrics.PorOrigen, "", "origen", "clave"},
+	}
+	for _, group := range groups {
+		*group.target = []RecommendationMetricsRow{}
+		query := base()
+		if group.join != "" {
+			query = query.Joins(group.join)
+		}
+		if err := query.
+			Select(group.expr + " AS clave, " + counts).
+			Group("clave").
+			Order(group.order).
+			Scan(group.target).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	metrics.Totales.CTR = clickThroughRate(metrics.Totales.Clics, metrics.Totales.Impresiones)
+	for _, rows := range [][]RecommendationMetricsRow{metrics.PorTipo, metrics.PorRazon, metrics.PorPosicion, metrics.PorOrigen} {
+		for i := range rows {
+			rows[i].CTR = clickThroughRate(rows[i].Clics, rows[i].Impresiones)
+		}
+	}
+	return metrics, nil
+}
+
+func clickThroughRate(clicks, impressions int64) float64 {
+	if impressions == 0 {
+		return 0
+	}
+	return float64(clicks) / float64(impressions)
+}
+
+func clampInt(value, low, high int) int {
+	if value < low {
+		return low
+	}
+	if value > high {
+		return high
+	}
+	return value
+}
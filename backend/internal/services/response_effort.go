@@ -0,0 +1,288 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+)
+
+// SessionEngagement es el compromiso de una sesión según el tiempo de respuesta
+type SessionEngagement struct {
+	Respuestas         int     `json:"respuestas"`
+	AdivinanzasRapidas int     `json:"adivinanzas_rapidas"`
+	Compromiso         float64 `json:"compromiso"`      // fracción de respuestas con esfuerzo (0-1)
+	BajoCompromiso     bool    `json:"bajo_compromiso"` // bajo ENGAGEMENT_LOW_PERCENT
+	SugerirPausa       bool    `json:"sugerir_pausa"`
+	Mensaje            string  `json:"mensaje,omitempty"`
+}
+
+// EngagementStudentRow resume el compromiso de un estudiante en un período
+type EngagementStudentRow struct {
+	UserID                 uint       `json:"user_id"`
+	Nombre                 string     `json:"nombre"`
+	Sesiones               int        `json:"sesiones"`
+	Respuestas             int        `json:"respuestas"`
+	AdivinanzasRapidas     int        `json:"adivinanzas_rapidas"`
+	CompromisoMedio        *float64   `json:"compromiso_medio"`
+	SesionesBajoCompromiso int        `json:"sesiones_bajo_compromiso"`
+	UltimaSesion           *time.Time `json:"ultima_sesion"`
+}
+
+// EngagementSessionRow es una sesión completada con su compromiso
+type EngagementSessionRow struct {
+	Tipo               string     `json:"tipo"` // practica, diagnostico
+	SessionID          uint       `json:"session_id"`
+	MateriaID          uint       `json:"materia_id"`
+	OAID               *uint      `json:"oa_id,omitempty"`
+	Respuestas         int        `json:"respuestas"`
+	AdivinanzasRapidas int        `json:"adivinanzas_rapidas"`
+	Compromiso         *float64   `json:"compromiso"`
+	BajoCompromiso     bool       `json:"bajo_compromiso"`
+	CompletedAt        *time.Time `json:"completed_at"`
+}
+
+// effortConfig son los umbrales de adivinanza rápida y de compromiso
+type effortConfig struct {
+	NormPercent       int // umbral = este % del tiempo normativo de la pregunta
+	MaxSegundos       int // tope del umbral
+	DefaultSegundos   int // umbral de preguntas con pocas respuestas cronometradas
+	MinMuestras       int
+	PesoPercent       int // peso de una adivinanza rápida en el dominio y en la XP
+	VentanaPausa      int
+	AdivinanzasPausa  int
+	BajoPercent       int
+	IntervaloNormasHr int
+}
+
+func loadEffortConfig() effortConfig {
+	return effortConfig{
+		NormPercent:       clampInt(getEnvInt("RAPID_GUESS_NORM_PERCENT", 10), 1, 100),
+		MaxSegundos:       getEnvInt("RAPID_GUESS_MAX_SECONDS", 10),
+		DefaultSegundos:   getEnvInt("RAPID_GUESS_DEFAULT_SECONDS", 3),
+		MinMuestras:       getEnvInt("RAPID_GUESS_MIN_SAMPLES", 20),
+		PesoPercent:       clampInt(getEnvInt("RAPID_GUESS_WEIGHT_PERCENT", 25), 0, 100),
+		VentanaPausa:      getEnvInt("ENGAGEMENT_BREAK_WINDOW", 5),
+		AdivinanzasPausa:  getEnvInt("ENGAGEMENT_BREAK_RAPID_GUESSES", 3),
+		BajoPercent:       clampInt(getEnvInt("ENGAGEMENT_LOW_PERCENT", 70), 0, 100),
+		IntervaloNormasHr: getEnvInt("RESPONSE_TIME_NORMS_HOURS", 6),
+	}
+}
+
+// RapidGuessThreshold es el tiempo bajo el cual una respuesta a la pregunta se considera adivinanza rápida:
+// RAPID_GUESS_NORM_PERCENT (10%) de su tiempo normativo, con tope RAPID_GUESS_MAX_SECONDS (10) y al menos 1
+// segundo; RAPID_GUESS_DEFAULT_SECONDS (3) si tiene menos de RAPID_GUESS_MIN_SAMPLES (20) respuestas cronometradas
+func RapidGuessThreshold(question models.Question) float64 {
+	return rapidGuessThreshold(question, loadEffortConfig())
+}
+
+func rapidGuessThreshold(question models.Question, config effortConfig) float64 {
+	if question.TiempoNormativoSegundos == nil || question.RespuestasCronometradas < config.MinMuestras {
+		return float64(config.DefaultSegundos)
+	}
+	threshold := *question.TiempoNormativoSegundos * float64(config.NormPercent) / 100
+	return math.Min(math.Max(threshold, 1), float64(config.MaxSegundos))
+}
+
+// IsRapidGuess indica si la respuesta fue más rápida que el umbral de la pregunta. Sin tiempo no hay evidencia.
+func IsRapidGuess(question models.Question, tiempoSegundos *int) bool {
+	if tiempoSegundos == nil || *tiempoSegundos < 0 {
+		return false
+	}
+	return float64(*tiempoSegundos) < RapidGuessThreshold(question)
+}
+
+// AnswerWeight es la fracción que cuenta una respuesta en el dominio y la XP: 1, o RAPID_GUESS_WEIGHT_PERCENT (25%)
+// si fue adivinanza rápida
+func AnswerWeight(rapidGuess bool) float64 {
+	if !rapidGuess {
+		return 1
+	}
+	return float64(loadEffortConfig().PesoPercent) / 100
+}
+
+// SessionCompromiso es la fracción de respuestas con esfuerzo (response time effort); nil sin respuestas
+func SessionCompromiso(respuestas, adivinanzas int) *float64 {
+	if respuestas <= 0 {
+		return nil
+	}
+	compromiso := math.Round(float64(respuestas-adivinanzas)/float64(respuestas)*10000) / 10000
+	return &compromiso
+}
+
+// PracticeEngagement evalúa el compromiso de una sesión de práctica y si conviene sugerir una pausa
+func PracticeEngagement(session models.PracticeSession) (*SessionEngagement, error) {
+	recent, err := loadRecentRapidGuesses("practice_answers", session.ID)
+	if err != nil {
+		return nil, err
+	}
+	engagement := evaluateEngagement(session.PreguntasRespondidas, session.AdivinanzasRapidas, recent, loadEffortConfig())
+	return &engagement, nil
+}
+
+// DiagnosticEngagement evalúa el compromiso de una sesión de diagnóstico y si conviene sugerir una pausa
+func DiagnosticEngagement(session models.DiagnosticSession) (*SessionEngagement, error) {
+	recent, err := loadRecentRapidGuesses("diagnostic_answers", session.ID)
+	if err != nil {
+		return nil, err
+	}
+	engagement := evaluateEngagement(session.PreguntasTotales, session.AdivinanzasRapidas, recent, loadEffortConfig())
+	return &engagement, nil
+}
+
+// evaluateEngagement sugiere una pausa si entre las últimas ENGAGEMENT_BREAK_WINDOW (5) respuestas hay al menos
+// ENGAGEMENT_BREAK_RAPID_GUESSES (3) adivinanzas rápidas
+func evaluateEngagement(respuestas, adivinanzas int, recent []bool, config effortConfig) SessionEngagement {
+	engagement := SessionEngagement{Respuestas: respuestas, AdivinanzasRapidas: adivinanzas, Compromiso: 1}
+	if compromiso := SessionCompromiso(respuestas, adivinanzas); compromiso != nil {
+		engagement.Compromiso = *compromiso
+	}
+	engagement.BajoCompromiso = respuestas > 0 && engagement.Compromiso*100 < float64(config.BajoPercent)
+
+	rapid := 0
+	for _, flagged := range recent {
+		if flagged {
+			rapid++
+		}
+	}
+	if config.AdivinanzasPausa > 0 && rapid >= config.AdivinanzasPausa {
+		engagement.SugerirPausa = true
+		engagement.Mensaje = fmt.Sprintf("Respondiste %d de las últimas %d preguntas muy rápido. ¿Qué tal una pausa de 5 minutos antes de seguir?", rapid, len(recent))
+	}
+	return engagement
+}
+
+// loadRecentRapidGuesses retorna las marcas de adivinanza de las últimas ENGAGEMENT_BREAK_WINDOW respuestas de la sesión
+func loadRecentRapidGuesses(table string, sessionID uint) ([]bool, error) {
+	var flags []bool
+	err := db.DB.Table(table).
+		Where("session_id = ?", sessionID).
+		Order("created_at DESC, id DESC").
+		Limit(loadEffortConfig().VentanaPausa).
+		Pluck("adivinanza_rapida", &flags).Error
+	return flags, err
+}
+
+// RefreshResponseTimeNorms recalcula el tiempo normativo (mediana) de cada pregunta con sus respuestas cronometradas
+// de práctica y diagnóstico
+func RefreshResponseTimeNorms() (int64, error) {
+	result := db.DB.Exec(`
+		UPDATE questions q
+		SET tiempo_normativo_segundos = t.mediana, respuestas_cronometradas = t.respuestas
+		FROM (
+			SELECT question_id,
+				percentile_cont(0.5) WITHIN GROUP (ORDER BY tiempo_segundos) AS mediana,
+				COUNT(*) AS respuestas
+			FROM (
+				SELECT question_id, tiempo_segundos FROM practice_answers WHERE tiempo_segundos > 0
+				UNION ALL
+				SELECT question_id, tiempo_segundos FROM diagnostic_answers WHERE tiempo_segundos > 0
+			) tiempos
+			GROUP BY question_id
+		) t
+		WHERE q.id = t.question_id`)
+	return result.RowsAffected, result.Error
+}
+
+// StartResponseTimeNormRefresher recalcula periódicamente los tiempos normativos (RESPONSE_TIME_NORMS_HOURS, 6; 0 lo
+// desactiva) hasta que ctx se cancela
+func StartResponseTimeNormRefresher(ctx context.Context) {
+	interval := time.Duration(loadEffortConfig().IntervaloNormasHr) * time.Hour
+	if interval <= 0 {
+		log.Println("Response time norm refresher disabled")
+		return
+	}
+
+	refresh := func() {
+		updated, err := RefreshResponseTimeNorms()
+		if err != nil {
+			log.Printf("⚠ Failed to refresh response time norms: %v", err)
+			return
+		}
+		log.Printf("✓ Refreshed response time norms of %d questions", updated)
+	}
+
+	go func() {
+		refresh()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				refresh()
+			}
+		}
+	}()
+}
+
+// engagementSessionsSQL une las sesiones completadas de práctica y diagnóstico entre dos fechas
+const engagementSessionsSQL = `
+	SELECT ps.user_id, 'practica' AS tipo, ps.id AS session_id, oa.materia_id, ps.oa_id,
+		ps.preguntas_respondidas AS respuestas, ps.adivinanzas_rapidas, ps.compromiso, ps.completed_at
+	FROM practice_sessions ps
+	JOIN objetivos_aprendizaje oa ON oa.id = ps.oa_id
+	WHERE ps.estado = 'completado' AND ps.completed_at >= @from AND ps.completed_at < @to
+	UNION ALL
+	SELECT ds.user_id, 'diagnostico', ds.id, ds.materia_id, NULL,
+		ds.preguntas_totales, ds.adivinanzas_rapidas, ds.compromiso, ds.completed_at
+	FROM diagnostic_sessions ds
+	WHERE ds.estado = 'completado' AND ds.completed_at >= @from AND ds.completed_at < @to`
+
+// GetEngagementReport resume el compromiso de cada estudiante en las sesiones completadas entre from y to
+// (opcionalmente de una materia), los de menor compromiso primero
+func GetEngagementReport(from, to time.Time, materiaID uint) ([]EngagementStudentRow, error) {
+	config := loadEffortConfig()
+	var rows []EngagementStudentRow
+	err := db.DB.Raw(`
+		SELECT s.user_id, u.name AS nombre,
+			COUNT(*) AS sesiones,
+			COALESCE(SUM(s.respuestas), 0) AS respuestas,
+			COALESCE(SUM(s.adivinanzas_rapidas), 0) AS adivinanzas_rapidas,
+			ROUND(AVG(s.compromiso), 4) AS compromiso_medio,
+			COUNT(*) FILTER (WHERE s.compromiso * 100 < @bajo) AS sesiones_bajo_compromiso,
+			MAX(s.completed_at) AS ultima_sesion
+		FROM (`+engagementSessionsSQL+`) s
+		JOIN users u ON u.id = s.user_id
+		WHERE @materia = 0 OR s.materia_id = @materia
+		GROUP BY s.user_id, u.name
+		ORDER BY compromiso_medio ASC NULLS LAST, s.user_id`,
+		map[string]interface{}{"from": from, "to": to, "materia": materiaID, "bajo": config.BajoPercent}).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	if rows == nil {
+		rows = []EngagementStudentRow{}
+	}
+	return rows, nil
+}
+
+// GetStudentEngagementSessions retorna las sesiones completadas del estudiante entre from y to con su compromiso,
+// más recientes primero
+func GetStudentEngagementSessions(userID uint, from, to time.Time) ([]EngagementSessionRow, error) {
+	config := loadEffortConfig()
+	var rows []EngagementSessionRow
+	err := db.DB.Raw(`
+		SELECT s.tipo, s.session_id, s.materia_id, s.oa_id, s.respuestas, s.adivinanzas_rapidas, s.compromiso, s.completed_at
+		FROM (`+engagementSessionsSQL+`) s
+		WHERE s.user_id = @user
+		ORDER BY s.completed_at DESC`,
+		map[string]interface{}{"from": from, "to": to, "user": userID}).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		rows[i].BajoCompromiso = rows[i].Compromiso != nil && *rows[i].Compromiso*100 < float64(config.BajoPercent)
+	}
+	if rows == nil {
+		rows = []EngagementSessionRow{}
+	}
+	return rows, nil
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionPaused   = errors.New("session is paused")
+	ErrSessionClosed   = errors.New("session is no longer open")
+	ErrSessionManaged  = errors.New("session belongs to a placement diagnostic")
+	ErrSessionLimit    = errors.New("too many open sessions")
+)
+
+// Tipos de sesión con ciclo de vida
+const (
+	SessionKindPractice   = "practica"
+	SessionKindDiagnostic = "diagnostico"
+)
+
+// sessionOpenStates son los estados que cuentan como sesión abierta
+var sessionOpenStates = []string{"en_progreso", "pausado"}
+
+// SessionLimitError es ErrSessionLimit con las sesiones abiertas que impiden iniciar otra
+type SessionLimitError struct {
+	Abiertas []uint
+}
+
+func (e *SessionLimitError) Error() string { return ErrSessionLimit.Error() }
+
+func (e *SessionLimitError) Unwrap() error { return ErrSessionLimit }
+
+type sessionLifecycleConfig struct {
+	InactividadMinutos int    // en_progreso sin actividad → expirado (0 no expira)
+	PausaHoras         int    // pausado sin actividad → expirado (0 no expira)
+	BarridoMinutos     int    // intervalo del barrido (0 lo desactiva)
+	MaxAbiertas        int    // sesiones abiertas por estudiante, tipo y materia (0 sin límite)
+	Politica           string // reanudar, reemplazar o rechazar al alcanzar el límite
+}
+
+func loadSessionLifecycleConfig() sessionLifecycleConfig {
+	config := sessionLifecycleConfig{
+		InactividadMinutos: clampInt(getEnvInt("SESSION_INACTIVITY_MINUTES", 30), 0, 7*24*60),
+		PausaHoras:         clampInt(getEnvInt("SESSION_PAUSE_EXPIRY_HOURS", 72), 0, 90*24),
+		BarridoMinutos:     clampInt(getEnvInt("SESSION_EXPIRY_SWEEP_MINUTES", 5), 0, 24*60),
+		MaxAbiertas:        clampInt(getEnvInt("SESSION_MAX_OPEN_PER_MATERIA", 1), 0, 50),
+		Politica:           getEnvString("SESSION_CONCURRENCY_POLICY", "reanudar"),
+	}
+	if !containsString([]string{"reanudar", "reemplazar", "rechazar"}, config.Politica) {
+		config.Politica = "reanudar"
+	}
+	return config
+}
+
+func sessionTable(kind string) string {
+	if kind == SessionKindDiagnostic {
+		return "diagnostic_sessions"
+	}
+	return "practice_sessions"
+}
+
+// CheckSessionActive indica si una sesión en ese estado acepta preguntas y respuestas
+func CheckSessionActive(estado string) error {
+	switch estado {
+	case "en_progreso":
+		return nil
+	case "pausado":
+		return ErrSessionPaused
+	default:
+		return ErrSessionClosed
+	}
+}
+
+// openSession es una sesión abierta del estudiante en la materia
+type openSession struct {
+	ID     uint
+	Target uint // objetivo OA-Bloom (práctica) o materia (diagnóstico)
+}
+
+// ReservePracticeSlot aplica la política de sesiones concurrentes antes de iniciar una práctica del
+// objetivo. Con la política reanudar retorna la práctica abierta del mismo objetivo, ya reanudada.
+func ReservePracticeSlot(userID, objectiveID uint) (*uint, error) {
+	var materiaIDs []uint
+	err := db.DB.Table("oa_bloom_objectives obo").
+		Joins("JOIN objetivos_aprendizaje oa ON oa.id = obo.oa_id").
+		Where("obo.id = ?", objectiveID).
+		Pluck("oa.materia_id", &materiaIDs).Error
+	if err != nil || len(materiaIDs) == 0 {
+		return nil, err
+	}
+
+	var open []openSession
+	err = db.DB.Table("practice_sessions ps").
+		Select("ps.id, ps.oa_bloom_objective_id AS target").
+		Joins("JOIN oa_bloom_objectives obo ON obo.id = ps.oa_bloom_objective_id").
+		Joins("JOIN objetivos_aprendizaje oa ON oa.id = obo.oa_id").
+		Where("ps.user_id = ? AND oa.materia_id = ? AND ps.estado IN ?", userID, materiaIDs[0], sessionOpenStates).
+		Order("ps.ultima_actividad_at DESC").
+		Scan(&open).Error
+	if err != nil {
+		return nil, err
+	}
+	return reserveSessionSlot(SessionKindPractice, open, objectiveID)
+}
+
+// ReserveDiagnosticSlot aplica la política de sesiones concurrentes antes de iniciar un diagnóstico de
+// la materia. Las sesiones de un diagnóstico de ubicación no cuentan.
+func ReserveDiagnosticSlot(userID, materiaID uint) (*uint, error) {
+	var open []openSession
+	err := db.DB.Table("diagnostic_sessions").
+		Select("id, materia_id AS target").
+		Where("user_id = ? AND materia_id = ? AND estado IN ? AND placement_diagnostic_id IS NULL", userID, materiaID, sessionOpenStates).
+		Order("ultima_actividad_at DESC").
+		Scan(&open).Error
+	if err != nil {
+		return nil, err
+	}
+	return reserveSessionSlot(SessionKindDiagnostic, open, materiaID)
+}
+
+// reserveSessionSlot recibe las sesiones abiertas de la más a la menos reciente. Bajo el límite no hace
+// nada; al alcanzarlo rechaza, reanuda la más reciente del mismo objetivo o abandona las menos
+// recientes para dejar espacio a la nueva.
+func reserveSessionSlot(kind string, open []openSession, target uint) (*uint, error) {
+	config := loadSessionLifecycleConfig()
+	if config.MaxAbiertas == 0 || len(open) < config.MaxAbiertas {
+		return nil, nil
+	}
+
+	switch config.Politica {
+	case "rechazar":
+		ids := make([]uint, len(open))
+		for i, session := range open {
+			ids[i] = session.ID
+		}
+		return nil, &SessionLimitError{Abiertas: ids}
+	case "reanudar":
+		for _, session := range open {
+			if session.Target != target {
+				continue
+			}
+			now := time.Now()
+			err := db.DB.Table(sessionTable(kind)).
+				Where("id = ? AND estado IN ?", session.ID, sessionOpenStates).
+				Updates(map[string]interface{}{"estado": "en_progreso", "pausado_at": nil, "ultima_actividad_at": now}).Error
+			if err != nil {
+				return nil, err
+			}
+			return &session.ID, nil
+		}
+	}
+
+	var abandon []uint
+	for _, session := range open[config.MaxAbiertas-1:] {
+		abandon = append(abandon, session.ID)
+	}
+	err := db.DB.Table(sessionTable(kind)).
+		Where("id IN ? AND estado IN ?", abandon, sessionOpenStates).
+		Updates(map[string]interface{}{"estado": "abandonado", "pregunta_pendiente_id": nil}).Error
+	return nil, err
+}
+
+// PauseSession pausa una sesión en progreso; no acepta preguntas ni respuestas hasta reanudarla
+func PauseSession(kind string, userID, sessionID uint) error {
+	now := time.Now()
+	return transitionSession(kind, userID, sessionID, "pausado", map[string]interface{}{
+		"estado":              "pausado",
+		"pausado_at":          now,
+		"ultima_actividad_at": now,
+	})
+}
+
+// ResumeSession reanuda una sesión pausada (o en progreso) y reinicia su inactividad
+func ResumeSession(kind string, userID, sessionID uint) error {
+	return transitionSession(kind, userID, sessionID, "en_progreso", map[string]interface{}{
+		"estado":              "en_progreso",
+		"pausado_at":          nil,
+		"ultima_actividad_at": time.Now(),
+	})
+}
+
+// AbandonSession cierra una sesión abierta sin completarla
+func AbandonSession(kind string, userID, sessionID uint) error {
+	return transitionSession(kind, userID, sessionID, "abandonado", map[string]interface{}{
+		"estado":                "abandonado",
+		"pregunta_pendiente_id": nil,
+		"ultima_actividad_at":   time.Now(),
+	})
+}
+
+// transitionSession cambia el estado de una sesión abierta del estudiante. Repetir la transición no
+// falla; las sesiones cerradas y las de un diagnóstico de ubicación no cambian.
+func transitionSession(kind string, userID, sessionID uint, estado string, updates map[string]interface{}) error {
+	columns := "estado"
+	if kind == SessionKindDiagnostic {
+		columns = "estado, placement_diagnostic_id"
+	}
+	var current struct {
+		Estado                string
+		PlacementDiagnosticID *uint
+	}
+	result := db.DB.Table(sessionTable(kind)).Select(columns).
+		Where("id = ? AND user_id = ?", sessionID, userID).
+		Limit(1).Scan(&current)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	if current.PlacementDiagnosticID != nil {
+		return ErrSessionManaged
+	}
+	if current.Estado == estado {
+		return nil
+	}
+	if !containsString(sessionOpenStates, current.Estado) {
+		return ErrSessionClosed
+	}
+
+	result = db.DB.Table(sessionTable(kind)).
+		Where("id = ? AND estado = ?", sessionID, current.Estado).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// Otra petición (o el barrido) cambió el estado entremedio
+		return ErrSessionClosed
+	}
+	return nil
+}
+
+// ExpireInactiveSessions marca como expiradas las sesiones en progreso sin actividad por
+// SESSION_INACTIVITY_MINUTES y las pausadas por SESSION_PAUSE_EXPIRY_HOURS. Las sesiones de un
+// diagnóstico de ubicación se rigen por su presupuesto de minutos.
+func ExpireInactiveSessions(now time.Time) (int64, error) {
+	config := loadSessionLifecycleConfig()
+	var expired int64
+	for _, kind := range []string{SessionKindPractice, SessionKindDiagnostic} {
+		for _, rule := range []struct {
+			estado string
+			limite time.Duration
+		}{
+			{"en_progreso", time.Duration(config.InactividadMinutos) * time.Minute},
+			{"pausado", time.Duration(config.PausaHoras) * time.Hour},
+		} {
+			if rule.limite <= 0 {
+				continue
+			}
+			query := db.DB.Table(sessionTable(kind)).
+				Where("estado = ? AND ultima_actividad_at < ?", rule.estado, now.Add(-rule.limite))
+			if kind == SessionKindDiagnostic {
+				query = query.Where("placement_diagnostic_id IS NULL")
+			}
+			result := query.Updates(map[string]interface{}{"estado": "expirado", "pregunta_pendiente_id": nil})
+			if result.Error != nil {
+				return expired, result.Error
+			}
+			expired += result.RowsAffected
+		}
+	}
+	return expired, nil
+}
+
+// StartSessionExpirySweeper expira periódicamente las sesiones inactivas. SESSION_EXPIRY_SWEEP_MINUTES=0 lo desactiva.
+func StartSessionExpirySweeper(ctx context.Context) {
+	interval := time.Duration(loadSessionLifecycleConfig().BarridoMinutos) * time.Minute
+	if interval <= 0 {
+		log.Println("Session expiry sweeper disabled")
+		return
+	}
+
+	sweep := func() {
+		expired, err := ExpireInactiveSessions(time.Now())
+		if err != nil {
+			log.Printf("⚠ Failed to expire inactive sessions: %v", err)
+			return
+		}
+		if expired > 0 {
+			log.Printf("✓ Expired %d inactive sessions", expired)
+		}
+	}
+
+	go func() {
+		sweep()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				sweep()
+			}
+		}
+	}()
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db/dbtest"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+)
+
+func TestDiagnosticSessionPauseAndExpiry(t *testing.T) {
+	gdb := dbtest.Open(t)
+	user := dbtest.CreateUser(t, gdb)
+
+	newSession := func() models.DiagnosticSession {
+		session := models.DiagnosticSession{
+			UserID:            user.ID,
+			MateriaID:         1,
+			Estado:            "en_progreso",
+			Estrategia:        []byte(`{}`),
+			StartedAt:         time.Now(),
+			UltimaActividadAt: time.Now(),
+		}
+		if err := gdb.Create(&session).Error; err != nil {
+			t.Fatalf("create session: %v", err)
+		}
+		return session
+	}
+	estado := func(id uint) string {
+		var session models.DiagnosticSession
+		if err := gdb.First(&session, id).Error; err != nil {
+			t.Fatalf("load session: %v", err)
+		}
+		return session.Estado
+	}
+
+	paused := newSession()
+	if err := PauseSession(SessionKindDiagnostic, user.ID, paused.ID); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	if got := estado(paused.ID); got != "pausado" {
+		t.Fatalf("estado after pause = %q, want pausado", got)
+	}
+
+	idle := newSession()
+	old := time.Now().Add(-100 * time.Hour)
+	if err := gdb.Model(&models.DiagnosticSession{}).Where("id IN ?", []uint{paused.ID, idle.ID}).
+		Update("ultima_actividad_at", old).Error; err != nil {
+		t.Fatalf("age sessions: %v", err)
+	}
+
+	expired, err := ExpireInactiveSessions(time.Now())
+	if err != nil {
+		t.Fatalf("expire: %v", err)
+	}
+	if expired != 2 {
+		t.Errorf("expired %d sessions, want 2", expired)
+	}
+	for _, id := range []uint{paused.ID, idle.ID} {
+		if got := estado(id); got != "expirado" {
+			t.Errorf("session %d estado = %q, want expirado", id, got)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"gorm.io/gorm"
+)
+
+// StudyGoalCalendar retorna el calendario iCalendar (.ics) de una meta del estudiante
+func StudyGoalCalendar(userID, goalID uint) ([]byte, error) {
+	goal, err := loadOwnedStudyGoal(userID, goalID)
+	if err != nil {
+		return nil, err
+	}
+	return studyGoalICS(goal)
+}
+
+// StudyGoalCalendarByToken retorna el calendario de la meta dueña del token, para suscribirse desde Google
+// Calendar u otros clientes que no envían el JWT
+func StudyGoalCalendarByToken(token string) ([]byte, error) {
+	if token == "" {
+		return nil, ErrStudyGoalNotFound
+	}
+	var goal models.StudyGoal
+	if err := db.DB.Where("ical_token = ?", token).First(&goal).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrStudyGoalNotFound
+		}
+		return nil, err
+	}
+	return studyGoalICS(&goal)
+}
+
+func studyGoalICS(goal *models.StudyGoal) ([]byte, error) {
+	detail, err := loadStudyGoalDetail(goal)
+	if err != nil {
+		return nil, err
+	}
+	return buildStudyGoalICS(detail, time.Now()), nil
+}
+
+// buildStudyGoalICS arma el VCALENDAR (RFC 5545) con un evento de día completo por tarea agendada y uno para
+// la fecha límite
+func buildStudyGoalICS(detail *StudyGoalDetail, now time.Time) []byte {
+	stamp := now.UTC().Format("20060102T150405Z")
+	var lines []string
+	lines = append(lines,
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Lumera//Metas de estudio//ES",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:"+icsEscape("Lumera · "+detail.Titulo),
+		"REFRESH-INTERVAL;VALUE=DURATION:PT1H",
+		"X-PUBLISHED-TTL:PT1H",
+	)
+
+	for _, task := range detail.Tareas {
+		if task.Fecha == nil {
+			continue
+		}
+		summary := task.Titulo
+		if task.Estado == models.StudyGoalTaskEstadoCompletada {
+			summary = "✓ " + summary
+		}
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			fmt.Sprintf("UID:study-task-%d@lumera.app", task.ID),
+			"DTSTAMP:"+stamp,
+			"DTSTART;VALUE=DATE:"+task.Fecha.Format("20060102"),
+			"DTEND;VALUE=DATE:"+task.Fecha.AddDate(0, 0, 1).Format("20060102"),
+			"SUMMARY:"+icsEscape(summary),
+			"DESCRIPTION:"+icsEscape(fmt.Sprintf("%d minutos · %s", task.Minutos, detail.Titulo)),
+			"TRANSP:TRANSPARENT",
+		)
+		if task.Estado == models.StudyGoalTaskEstadoOmitida {
+			lines = append(lines, "STATUS:CANCELLED")
+		}
+		lines = append(lines, "END:VEVENT")
+	}
+
+	lines = append(lines,
+		"BEGIN:VEVENT",
+		fmt.Sprintf("UID:study-goal-%d@lumera.app", detail.ID),
+		"DTSTAMP:"+stamp,
+		"DTSTART;VALUE=DATE:"+detail.FechaLimite.Format("20060102"),
+		"DTEND;VALUE=DATE:"+detail.FechaLimite.AddDate(0, 0, 1).Format("20060102"),
+		"SUMMARY:"+icsEscape("🎯 "+detail.Titulo),
+		"END:VEVENT",
+		"END:VCALENDAR",
+	)
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(icsFold(line))
+		b.WriteString("\r\n")
+	}
+	return []byte(b.String())
+}
+
+// icsEscape escapa un valor TEXT de iCalendar
+func icsEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+}
+
+// icsFold corta la línea en trozos de a lo más 75 octetos sin partir caracteres UTF-8; las continuaciones
+// empiezan con un espacio
+func icsFold(line string) string {
+	const limit = 75
+	if len(line) <= limit {
+		return line
+	}
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := utf8.RuneLen(r)
+		if width+size > limit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
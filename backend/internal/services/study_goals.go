@@ -0,0 +1,872 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/platanus-hack-25/lumera_app/internal/db"
+	"github.com/platanus-hack-25/lumera_app/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrStudyGoalNotFound     = errors.New("study goal not found")
+	ErrStudyGoalTaskNotFound = errors.New("study goal task not found")
+	ErrInvalidStudyGoal      = errors.New("invalid study goal")
+)
+
+const maxStudyGoalObjectives = 30
+
+// studyWeekdays son las claves de minutos_por_dia, de lunes a domingo
+var studyWeekdays = []string{"lunes", "martes", "miercoles", "jueves", "viernes", "sabado", "domingo"}
+
+// StudyGoalInput crea o reemplaza una meta
+type StudyGoalInput struct {
+	Titulo              string         `json:"titulo"`
+	FechaLimite         string         `json:"fecha_limite"`    // YYYY-MM-DD, día de la prueba (no se agenda)
+	MinutosPorDia       map[string]int `json:"minutos_por_dia"` // lunes..domingo
+	OABloomObjectiveIDs []uint         `json:"oa_bloom_objective_ids"`
+}
+
+// StudyGoalDetail es la meta con el estado de sus objetivos y su calendario
+type StudyGoalDetail struct {
+	models.StudyGoal
+	ICalPath  string                     `json:"ical_path"` // feed iCalendar público (con el token secreto de la meta)
+	Objetivos []StudyGoalObjectiveStatus `json:"objetivos"`
+	Tareas    []models.StudyGoalTask     `json:"tareas"`
+	Resumen   StudyGoalSummary           `json:"resumen"`
+}
+
+// StudyGoalObjectiveStatus es el progreso del estudiante en un objetivo de la meta
+type StudyGoalObjectiveStatus struct {
+	OABloomObjectiveID uint   `json:"oa_bloom_objective_id"`
+	OAID               uint   `json:"oa_id"`
+	MateriaID          uint   `json:"materia_id"`
+	Codigo             string `json:"codigo"`
+	Titulo             string `json:"titulo"`
+	BloomLevel         int    `json:"bloom_level"`
+	BloomNombre        string `json:"bloom_nombre"`
+	Estado             string `json:"estado"`
+	PorcentajeLogro    int    `json:"porcentaje_logro"`
+	Dominado           bool   `json:"dominado"`
+}
+
+// StudyGoalSummary resume el avance y la carga pendiente de la meta
+type StudyGoalSummary struct {
+	ObjetivosTotales   int  `json:"objetivos_totales"`
+	ObjetivosDominados int  `json:"objetivos_dominados"`
+	TareasTotales      int  `json:"tareas_totales"`
+	Completadas        int  `json:"completadas"`
+	Pendientes         int  `json:"pendientes"`
+	Omitidas           int  `json:"omitidas"`
+	SinAgendar         int  `json:"sin_agendar"` // tareas que no caben antes de la fecha límite
+	MinutosPendientes  int  `json:"minutos_pendientes"`
+	MinutosDisponibles int  `json:"minutos_disponibles"` // desde hoy hasta el día anterior a la fecha límite
+	DiasRestantes      int  `json:"dias_restantes"`
+	EnRiesgo           bool `json:"en_riesgo"` // no alcanza el tiempo para todo lo pendiente
+}
+
+// studyWorkItem es una tarea por agendar
+type studyWorkItem struct {
+	objectiveID    uint
+	oaID           uint
+	learningPlanID *uint
+	tipo           string
+	titulo         string
+	minutos        int
+}
+
+// studyObjectiveWork es el trabajo de un objetivo en secuencia, más su repaso final
+type studyObjectiveWork struct {
+	items  []studyWorkItem
+	review *studyWorkItem
+}
+
+// scheduledStudyTask es una tarea con su día (nil si no cupo antes de la fecha límite)
+type scheduledStudyTask struct {
+	item  studyWorkItem
+	fecha *time.Time
+}
+
+// ListStudyGoals retorna las metas del estudiante, más recientes primero
+func ListStudyGoals(userID uint) ([]StudyGoalDetail, error) {
+	var goals []models.StudyGoal
+	if err := db.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&goals).Error; err != nil {
+		return nil, err
+	}
+	details := make([]StudyGoalDetail, 0, len(goals))
+	for i := range goals {
+		detail, err := loadStudyGoalDetail(&goals[i])
+		if err != nil {
+			return nil, err
+		}
+		details = append(details, *detail)
+	}
+	return details, nil
+}
+
+// GetStudyGoal retorna la meta con su calendario, marcando las tareas hechas y replanificando si el estudiante
+// se atrasó
+func GetStudyGoal(userID, goalID uint) (*StudyGoalDetail, error) {
+	goal, err := loadOwnedStudyGoal(userID, goalID)
+	if err != nil {
+		return nil, err
+	}
+	return loadStudyGoalDetail(goal)
+}
+
+// CreateStudyGoal crea la meta y agenda sus tareas desde hoy
+func CreateStudyGoal(userID uint, input StudyGoalInput) (*StudyGoalDetail, error) {
+	fechaLimite, minutos, objectiveIDs, err := validateStudyGoalInput(input)
+	if err != nil {
+		return nil, err
+	}
+	token, err := newStudyGoalToken()
+	if err != nil {
+		return nil, err
+	}
+	minutosJSON, _ := json.Marshal(minutos)
+
+	goal := models.StudyGoal{
+		UserID:        userID,
+		Titulo:        strings.TrimSpace(input.Titulo),
+		FechaLimite:   fechaLimite,
+		MinutosPorDia: minutosJSON,
+		Estado:        models.StudyGoalEstadoActiva,
+		ICalToken:     token,
+	}
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&goal).Error; err != nil {
+			return err
+		}
+		return createStudyGoalObjectives(tx, goal.ID, objectiveIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := replanStudyGoal(&goal); err != nil {
+		return nil, err
+	}
+	return loadStudyGoalDetail(&goal)
+}
+
+// UpdateStudyGoal reemplaza título, fecha límite, disponibilidad y objetivos, y replanifica. Una meta vencida
+// vuelve a quedar activa si la nueva fecha es futura.
+func UpdateStudyGoal(userID, goalID uint, input StudyGoalInput) (*StudyGoalDetail, error) {
+	goal, err := loadOwnedStudyGoal(userID, goalID)
+	if err != nil {
+		return nil, err
+	}
+	fechaLimite, minutos, objectiveIDs, err := validateStudyGoalInput(input)
+	if err != nil {
+		return nil, err
+	}
+	minutosJSON, _ := json.Marshal(minutos)
+
+	goal.Titulo = strings.TrimSpace(input.Titulo)
+	goal.FechaLimite = fechaLimite
+	goal.MinutosPorDia = minutosJSON
+	goal.Estado = models.StudyGoalEstadoActiva
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(goal).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("study_goal_id = ?", goal.ID).Delete(&models.StudyGoalObjective{}).Error; err != nil {
+			return err
+		}
+		// Las tareas de objetivos que salieron de la meta ya no aplican
+		if err := tx.Where("study_goal_id = ? AND estado = ? AND oa_bloom_objective_id NOT IN ?",
+			goal.ID, models.StudyGoalTaskEstadoPendiente, objectiveIDs).
+			Delete(&models.StudyGoalTask{}).Error; err != nil {
+			return err
+		}
+		return createStudyGoalObjectives(tx, goal.ID, objectiveIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := replanStudyGoal(goal); err != nil {
+		return nil, err
+	}
+	return loadStudyGoalDetail(goal)
+}
+
+// DeleteStudyGoal elimina la meta y su calendario
+func DeleteStudyGoal(userID, goalID uint) error {
+	goal, err := loadOwnedStudyGoal(userID, goalID)
+	if err != nil {
+		return err
+	}
+	return db.DB.Select("Objetivos", "Tareas").Delete(goal).Error
+}
+
+// ReplanStudyGoal vuelve a agendar las tareas pendientes desde hoy
+func ReplanStudyGoal(userID, goalID uint) (*StudyGoalDetail, error) {
+	goal, err := loadOwnedStudyGoal(userID, goalID)
+	if err != nil {
+		return nil, err
+	}
+	if goal.Estado != models.StudyGoalEstadoActiva {
+		return nil, fmt.Errorf("%w: la meta está %s", ErrInvalidStudyGoal, goal.Estado)
+	}
+	if err := replanStudyGoal(goal); err != nil {
+		return nil, err
+	}
+	return loadStudyGoalDetail(goal)
+}
+
+// UpdateStudyGoalTask marca una tarea como completada, omitida o de nuevo pendiente. Omitir una tarea
+// replanifica la meta para reubicar ese trabajo.
+func UpdateStudyGoalTask(userID, goalID, taskID uint, estado string) (*models.StudyGoalTask, error) {
+	goal, err := loadOwnedStudyGoal(userID, goalID)
+	if err != nil {
+		return nil, err
+	}
+	if estado != models.StudyGoalTaskEstadoPendiente && estado != models.StudyGoalTaskEstadoCompletada && estado != models.StudyGoalTaskEstadoOmitida {
+		return nil, fmt.Errorf("%w: estado debe ser pendiente, completada u omitida", ErrInvalidStudyGoal)
+	}
+
+	var task models.StudyGoalTask
+	if err := db.DB.Where("id = ? AND study_goal_id = ?", taskID, goal.ID).First(&task).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrStudyGoalTaskNotFound
+		}
+		return nil, err
+	}
+
+	task.Estado = estado
+	task.CompletedAt = nil
+	if estado == models.StudyGoalTaskEstadoCompletada {
+		now := time.Now()
+		task.CompletedAt = &now
+	}
+	if err := db.DB.Save(&task).Error; err != nil {
+		return nil, err
+	}
+
+	if estado == models.StudyGoalTaskEstadoOmitida && goal.Estado == models.StudyGoalEstadoActiva {
+		if err := replanStudyGoal(goal); err != nil {
+			return nil, err
+		}
+	}
+	return &task, nil
+}
+
+func loadOwnedStudyGoal(userID, goalID uint) (*models.StudyGoal, error) {
+	var goal models.StudyGoal
+	if err := db.DB.Where("id = ? AND user_id = ?", goalID, userID).First(&goal).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrStudyGoalNotFound
+		}
+		return nil, err
+	}
+	return &goal, nil
+}
+
+// loadStudyGoalDetail sincroniza la meta (tareas hechas, estado, atrasos) y arma la respuesta
+func loadStudyGoalDetail(goal *models.StudyGoal) (*StudyGoalDetail, error) {
+	if err := syncStudyGoal(goal); err != nil {
+		return nil, err
+	}
+
+	statuses, err := studyGoalObjectiveStatuses(goal)
+	if err != nil {
+		return nil, err
+	}
+	var tasks []models.StudyGoalTask
+	if err := db.DB.Where("study_goal_id = ?", goal.ID).Order("fecha ASC NULLS LAST, orden ASC, id ASC").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	detail := &StudyGoalDetail{
+		StudyGoal: *goal,
+		ICalPath:  "/api/study-goals/feed/" + goal.ICalToken + ".ics",
+		Objetivos: statuses,
+		Tareas:    tasks,
+	}
+	summary := &detail.Resumen
+	summary.ObjetivosTotales = len(statuses)
+	for _, status := range statuses {
+		if status.Dominado {
+			summary.ObjetivosDominados++
+		}
+	}
+	summary.TareasTotales = len(tasks)
+	for _, task := range tasks {
+		switch task.Estado {
+		case models.StudyGoalTaskEstadoCompletada:
+			summary.Completadas++
+		case models.StudyGoalTaskEstadoOmitida:
+			summary.Omitidas++
+		default:
+			summary.Pendientes++
+			summary.MinutosPendientes += task.Minutos
+			if task.Fecha == nil {
+				summary.SinAgendar++
+			}
+		}
+	}
+
+	today := studyDate(time.Now())
+	deadline := studyDate(goal.FechaLimite)
+	minutos := studyGoalMinutes(goal)
+	for day := today; day.Before(deadline); day = day.AddDate(0, 0, 1) {
+		summary.MinutosDisponibles += minutos[studyWeekdayIndex(day)]
+		summary.DiasRestantes++
+	}
+	summary.EnRiesgo = goal.Estado == models.StudyGoalEstadoActiva && summary.SinAgendar > 0
+	return detail, nil
+}
+
+// syncStudyGoal marca como hechas las tareas que el estudiante ya cumplió (prácticas completadas y planes
+// terminados), cierra la meta si dominó todos los objetivos o pasó la fecha, y replanifica si quedaron tareas
+// pendientes de días anteriores
+func syncStudyGoal(goal *models.StudyGoal) error {
+	if goal.Estado != models.StudyGoalEstadoActiva {
+		return nil
+	}
+	if err := markCompletedStudyTasks(goal); err != nil {
+		return err
+	}
+
+	statuses, err := studyGoalObjectiveStatuses(goal)
+	if err != nil {
+		return err
+	}
+	allMastered := len(statuses) > 0
+	for _, status := range statuses {
+		allMastered = allMastered && status.Dominado
+	}
+	today := studyDate(time.Now())
+	switch {
+	case allMastered:
+		goal.Estado = models.StudyGoalEstadoCompletada
+	case !today.Before(studyDate(goal.FechaLimite)): // el día de la prueba ya no se agenda
+		goal.Estado = models.StudyGoalEstadoVencida
+	}
+	if goal.Estado != models.StudyGoalEstadoActiva {
+		return db.DB.Model(goal).Update("estado", goal.Estado).Error
+	}
+
+	var overdue int64
+	if err := db.DB.Model(&models.StudyGoalTask{}).
+		Where("study_goal_id = ? AND estado = ? AND fecha < ?", goal.ID, models.StudyGoalTaskEstadoPendiente, today.Format("2006-01-02")).
+		Count(&overdue).Error; err != nil {
+		return err
+	}
+	if overdue > 0 {
+		return replanStudyGoal(goal)
+	}
+	return nil
+}
+
+// markCompletedStudyTasks asigna las prácticas completadas desde que se creó la meta a sus tareas de práctica
+// y repaso (en orden de fecha) y completa las tareas de plan cuyo plan de aprendizaje ya terminó
+func markCompletedStudyTasks(goal *models.StudyGoal) error {
+	var tasks []models.StudyGoalTask
+	if err := db.DB.Where("study_goal_id = ?", goal.ID).Order("fecha ASC NULLS LAST, orden ASC, id ASC").Find(&tasks).Error; err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		return nil
+	}
+	var objectiveIDs []uint
+	for _, task := range tasks {
+		if !containsUint(objectiveIDs, task.OABloomObjectiveID) {
+			objectiveIDs = append(objectiveIDs, task.OABloomObjectiveID)
+		}
+	}
+
+	var sessions []models.PracticeSession
+	if err := db.DB.Select("id, oa_bloom_objective_id, completed_at").
+		Where("user_id = ? AND estado = ? AND completed_at >= ? AND oa_bloom_objective_id IN ?", goal.UserID, "completado", goal.CreatedAt, objectiveIDs).
+		Order("completed_at").
+		Find(&sessions).Error; err != nil {
+		return err
+	}
+	sessionsByObjective := map[uint][]time.Time{}
+	for _, session := range sessions {
+		if session.CompletedAt != nil {
+			sessionsByObjective[session.OABloomObjectiveID] = append(sessionsByObjective[session.OABloomObjectiveID], *session.CompletedAt)
+		}
+	}
+
+	var plans []models.LearningPlan
+	if err := db.DB.Select("id, oa_bloom_objective_id, completado, fecha_completado").
+		Where("user_id = ? AND completado = ? AND oa_bloom_objective_id IN ?", goal.UserID, true, objectiveIDs).
+		Find(&plans).Error; err != nil {
+		return err
+	}
+	completedPlans := map[uint]time.Time{}
+	for _, plan := range plans {
+		completedAt := time.Now()
+		if plan.FechaCompletado != nil {
+			completedAt = *plan.FechaCompletado
+		}
+		completedPlans[plan.OABloomObjectiveID] = completedAt
+	}
+
+	// Las prácticas ya contadas son las tareas de práctica o repaso completadas
+	used := map[uint]int{}
+	for _, task := range tasks {
+		if task.Tipo != models.StudyGoalTaskTipoPlanAprendizaje && task.Estado == models.StudyGoalTaskEstadoCompletada {
+			used[task.OABloomObjectiveID]++
+		}
+	}
+
+	for _, task := range tasks {
+		if task.Estado != models.StudyGoalTaskEstadoPendiente {
+			continue
+		}
+		var completedAt *time.Time
+		if task.Tipo == models.StudyGoalTaskTipoPlanAprendizaje {
+			if at, ok := completedPlans[task.OABloomObjectiveID]; ok {
+				completedAt = &at
+			}
+		} else if available := sessionsByObjective[task.OABloomObjectiveID]; used[task.OABloomObjectiveID] < len(available) {
+			completedAt = &available[used[task.OABloomObjectiveID]]
+			used[task.OABloomObjectiveID]++
+		}
+		if completedAt == nil {
+			continue
+		}
+		if err := db.DB.Model(&models.StudyGoalTask{}).Where("id = ?", task.ID).Updates(map[string]interface{}{
+			"estado":       models.StudyGoalTaskEstadoCompletada,
+			"completed_at": *completedAt,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replanStudyGoal marca como omitidas las tareas pendientes de días pasados, borra las demás pendientes y agenda
+// desde hoy el trabajo que falta según el progreso actual
+func replanStudyGoal(goal *models.StudyGoal) error {
+	work, err := buildStudyWork(goal)
+	if err != nil {
+		return err
+	}
+	today := studyDate(time.Now())
+	scheduled := scheduleStudyWork(work, today, studyDate(goal.FechaLimite), studyGoalMinutes(goal))
+
+	now := time.Now()
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.StudyGoalTask{}).
+			Where("study_goal_id = ? AND estado = ? AND fecha < ?", goal.ID, models.StudyGoalTaskEstadoPendiente, today.Format("2006-01-02")).
+			Update("estado", models.StudyGoalTaskEstadoOmitida).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("study_goal_id = ? AND estado = ?", goal.ID, models.StudyGoalTaskEstadoPendiente).
+			Delete(&models.StudyGoalTask{}).Error; err != nil {
+			return err
+		}
+
+		if len(scheduled) > 0 {
+			tasks := make([]models.StudyGoalTask, len(scheduled))
+			for i, entry := range scheduled {
+				tasks[i] = models.StudyGoalTask{
+					StudyGoalID:        goal.ID,
+					UserID:             goal.UserID,
+					OABloomObjectiveID: entry.item.objectiveID,
+					OAID:               entry.item.oaID,
+					LearningPlanID:     entry.item.learningPlanID,
+					Fecha:              entry.fecha,
+					Orden:              i + 1,
+					Tipo:               entry.item.tipo,
+					Titulo:             entry.item.titulo,
+					Minutos:            entry.item.minutos,
+					Estado:             models.StudyGoalTaskEstadoPendiente,
+				}
+			}
+			if err := tx.Create(&tasks).Error; err != nil {
+				return err
+			}
+		}
+
+		goal.ReplanificadaAt = &now
+		return tx.Model(goal).Update("replanificada_at", now).Error
+	})
+}
+
+// buildStudyWork arma el trabajo que falta por objetivo, en orden de prerequisitos: un plan de aprendizaje si
+// el objetivo va bajo el 50% y no tiene plan terminado, prácticas hasta completar el logro
+// (STUDY_GOAL_PRACTICE_GAIN puntos por práctica, 35) y un repaso final. Los objetivos dominados solo se repasan.
+func buildStudyWork(goal *models.StudyGoal) ([]studyObjectiveWork, error) {
+	var links []models.StudyGoalObjective
+	if err := db.DB.Where("study_goal_id = ?", goal.ID).Find(&links).Error; err != nil {
+		return nil, err
+	}
+	objectiveIDs := make([]uint, len(links))
+	for i, link := range links {
+		objectiveIDs[i] = link.OABloomObjectiveID
+	}
+	if len(objectiveIDs) == 0 {
+		return nil, nil
+	}
+
+	var objectives []models.OABloomObjective
+	if err := db.DB.Preload("OA").Preload("BloomLevel").Where("id IN ?", objectiveIDs).Find(&objectives).Error; err != nil {
+		return nil, err
+	}
+	progress, err := loadRecommendationProgress(goal.UserID, objectiveIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var plans []models.LearningPlan
+	if err := db.DB.Select("id, oa_bloom_objective_id, estado, completado, tiempo_estimado_minutos").
+		Where("user_id = ? AND oa_bloom_objective_id IN ?", goal.UserID, objectiveIDs).
+		Order("id DESC").
+		Find(&plans).Error; err != nil {
+		return nil, err
+	}
+	latestPlan := map[uint]models.LearningPlan{}
+	completedPlan := map[uint]bool{}
+	for _, plan := range plans {
+		if _, ok := latestPlan[plan.OABloomObjectiveID]; !ok {
+			latestPlan[plan.OABloomObjectiveID] = plan
+		}
+		if plan.Completado {
+			completedPlan[plan.OABloomObjectiveID] = true
+		}
+	}
+
+	order, err := studyObjectiveOrder(objectives)
+	if err != nil {
+		return nil, err
+	}
+
+	gain := getEnvInt("STUDY_GOAL_PRACTICE_GAIN", 35)
+	if gain <= 0 {
+		gain = 35
+	}
+	planMinutes := getEnvInt("STUDY_GOAL_PLAN_MINUTES", 30)
+
+	work := make([]studyObjectiveWork, 0, len(order))
+	for _, objective := range order {
+		label := fmt.Sprintf("%s · %s", objective.OA.Codigo, objective.BloomLevel.Nombre)
+		base := studyWorkItem{objectiveID: objective.ID, oaID: objective.OAID}
+		p := progress[objective.ID]
+
+		var entry studyObjectiveWork
+		review := base
+		review.tipo = models.StudyGoalTaskTipoRepaso
+		review.titulo = fmt.Sprintf("Repasar %s (%d preguntas)", label, recommendationQuestions/2)
+		review.minutos = practiceMinutes(recommendationQuestions / 2)
+		entry.review = &review
+
+		if !masteredEstado(p.estado) {
+			if p.porcentajeLogro < 50 && !completedPlan[objective.ID] {
+				item := base
+				item.tipo = models.StudyGoalTaskTipoPlanAprendizaje
+				item.titulo = "Estudiar plan de aprendizaje: " + label
+				item.minutos = planMinutes
+				if plan, ok := latestPlan[objective.ID]; ok {
+					planID := plan.ID
+					item.learningPlanID = &planID
+					if plan.TiempoEstimadoMin > 0 {
+						item.minutos = plan.TiempoEstimadoMin
+					}
+				}
+				entry.items = append(entry.items, item)
+			}
+			sessions := int(math.Ceil(float64(100-p.porcentajeLogro) / float64(gain)))
+			if sessions < 1 {
+				sessions = 1
+			}
+			for i := 0; i < sessions; i++ {
+				item := base
+				item.tipo = models.StudyGoalTaskTipoPractica
+				item.titulo = fmt.Sprintf("Practicar %s (%d preguntas)", label, recommendationQuestions)
+				item.minutos = practiceMinutes(recommendationQuestions)
+				entry.items = append(entry.items, item)
+			}
+		}
+		work = append(work, entry)
+	}
+	return work, nil
+}
+
+// studyObjectiveOrder ordena los objetivos por el orden topológico de sus OAs (prerequisitos primero) y dentro
+// de cada OA por nivel Bloom
+func studyObjectiveOrder(objectives []models.OABloomObjective) ([]models.OABloomObjective, error) {
+	nodes := map[uint]*CurriculumMapNode{}
+	var oaIDs []uint
+	for _, objective := range objectives {
+		if _, ok := nodes[objective.OAID]; !ok {
+			nodes[objective.OAID] = &CurriculumMapNode{OAID: objective.OAID, Codigo: objective.OA.Codigo, Orden: objective.OA.Orden}
+			oaIDs = append(oaIDs, objective.OAID)
+		}
+	}
+
+	var prerequisites []models.OAPrerequisite
+	if err := db.DB.Where("oa_id IN ? AND prerequisito_oa_id IN ?", oaIDs, oaIDs).Find(&prerequisites).Error; err != nil {
+		return nil, err
+	}
+	edges := make([]CurriculumMapEdge, len(prerequisites))
+	for i, prerequisite := range prerequisites {
+		edges[i] = CurriculumMapEdge{Desde: prerequisite.PrerequisitoOAID, Hacia: prerequisite.OAID}
+	}
+
+	position := map[uint]int{}
+	for i, oaID := range topologicalOAOrder(nodes, edges) {
+		position[oaID] = i
+	}
+	ordered := append([]models.OABloomObjective(nil), objectives...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if position[ordered[i].OAID] != position[ordered[j].OAID] {
+			return position[ordered[i].OAID] < position[ordered[j].OAID]
+		}
+		return ordered[i].BloomLevel.Nivel < ordered[j].BloomLevel.Nivel
+	})
+	return ordered, nil
+}
+
+// scheduleStudyWork reparte el trabajo en los días desde start hasta el anterior a deadline según los minutos de
+// cada día de la semana. Cada día se llena en orden de prioridad con a lo más una tarea por objetivo (así las
+// prácticas de un objetivo quedan en días distintos); una tarea más larga que la disponibilidad del día va sola.
+// Los repasos van lo más cerca posible de la fecha límite, después de la última tarea de su objetivo.
+func scheduleStudyWork(work []studyObjectiveWork, start, deadline time.Time, minutos [7]int) []scheduledStudyTask {
+	var days []time.Time
+	for day := start; day.Before(deadline); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	capacity := make([]int, len(days))
+	remaining := make([]int, len(days))
+	for i, day := range days {
+		capacity[i] = minutos[studyWeekdayIndex(day)]
+		remaining[i] = capacity[i]
+	}
+
+	type placement struct {
+		objective int
+		item      studyWorkItem
+		day       int // -1 sin agendar
+		seq       int
+	}
+	var placements []placement
+	next := make([]int, len(work))
+	lastDay := make([]int, len(work))
+	for i := range lastDay {
+		lastDay[i] = -1
+	}
+
+	seq := 0
+	for d := range days {
+		scheduledToday := map[int]bool{}
+		for {
+			placed := false
+			for o := range work {
+				if scheduledToday[o] || next[o] >= len(work[o].items) {
+					continue
+				}
+				item := work[o].items[next[o]]
+				empty := remaining[d] == capacity[d]
+				if remaining[d] <= 0 || (item.minutos > remaining[d] && !(empty && item.minutos > capacity[d])) {
+					continue
+				}
+				placements = append(placements, placement{objective: o, item: item, day: d, seq: seq})
+				seq++
+				remaining[d] -= item.minutos
+				scheduledToday[o] = true
+				lastDay[o] = d
+				next[o]++
+				placed = true
+				break
+			}
+			if !placed {
+				break
+			}
+		}
+	}
+
+	for o := range work {
+		for ; next[o] < len(work[o].items); next[o]++ {
+			placements = append(placements, placement{objective: o, item: work[o].items[next[o]], day: -1, seq: seq})
+			seq++
+		}
+	}
+
+	for o := range work {
+		if work[o].review == nil {
+			continue
+		}
+		day := -1
+		if next[o] >= len(work[o].items) { // sin trabajo pendiente fuera del calendario
+			for d := len(days) - 1; d > lastDay[o]; d-- {
+				if remaining[d] >= work[o].review.minutos {
+					day = d
+					break
+				}
+			}
+		}
+		if day >= 0 {
+			remaining[day] -= work[o].review.minutos
+		}
+		placements = append(placements, placement{objective: o, item: *work[o].review, day: day, seq: seq})
+		seq++
+	}
+
+	sort.SliceStable(placements, func(i, j int) bool {
+		a, b := placements[i], placements[j]
+		if (a.day < 0) != (b.day < 0) {
+			return b.day < 0
+		}
+		if a.day != b.day {
+			return a.day < b.day
+		}
+		return a.seq < b.seq
+	})
+
+	scheduled := make([]scheduledStudyTask, len(placements))
+	for i, p := range placements {
+		scheduled[i] = scheduledStudyTask{item: p.item}
+		if p.day >= 0 {
+			day := days[p.day]
+			scheduled[i].fecha = &day
+		}
+	}
+	return scheduled
+}
+
+// studyGoalObjectiveStatuses retorna el progreso del estudiante en cada objetivo de la meta
+func studyGoalObjectiveStatuses(goal *models.StudyGoal) ([]StudyGoalObjectiveStatus, error) {
+	var objectives []models.OABloomObjective
+	err := db.DB.Preload("OA").Preload("BloomLevel").
+		Joins("JOIN study_goal_objectives sgo ON sgo.oa_bloom_objective_id = oa_bloom_objectives.id").
+		Where("sgo.study_goal_id = ?", goal.ID).
+		Find(&objectives).Error
+	if err != nil {
+		return nil, err
+	}
+	objectiveIDs := make([]uint, len(objectives))
+	for i, objective := range objectives {
+		objectiveIDs[i] = objective.ID
+	}
+	progress, err := loadRecommendationProgress(goal.UserID, objectiveIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	ordered, err := studyObjectiveOrder(objectives)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]StudyGoalObjectiveStatus, len(ordered))
+	for i, objective := range ordered {
+		p := progress[objective.ID]
+		estado := p.estado
+		if estado == "" {
+			estado = "no_iniciado"
+		}
+		statuses[i] = StudyGoalObjectiveStatus{
+			OABloomObjectiveID: objective.ID,
+			OAID:               objective.OAID,
+			MateriaID:          objective.OA.MateriaID,
+			Codigo:             objective.OA.Codigo,
+			Titulo:             objective.OA.Titulo,
+			BloomLevel:         objective.BloomLevel.Nivel,
+			BloomNombre:        objective.BloomLevel.Nombre,
+			Estado:             estado,
+			PorcentajeLogro:    p.porcentajeLogro,
+			Dominado:           masteredEstado(estado),
+		}
+	}
+	return statuses, nil
+}
+
+func validateStudyGoalInput(input StudyGoalInput) (time.Time, map[string]int, []uint, error) {
+	if strings.TrimSpace(input.Titulo) == "" {
+		return time.Time{}, nil, nil, fmt.Errorf("%w: titulo es obligatorio", ErrInvalidStudyGoal)
+	}
+	fechaLimite, err := time.Parse("2006-01-02", input.FechaLimite)
+	if err != nil {
+		return time.Time{}, nil, nil, fmt.Errorf("%w: fecha_limite debe ser YYYY-MM-DD", ErrInvalidStudyGoal)
+	}
+	if !fechaLimite.After(studyDate(time.Now())) {
+		return time.Time{}, nil, nil, fmt.Errorf("%w: fecha_limite debe ser posterior a hoy", ErrInvalidStudyGoal)
+	}
+
+	minutos := make(map[string]int, len(studyWeekdays))
+	total := 0
+	for key, value := range input.MinutosPorDia {
+		day := normalizeCacheText(key)
+		if !containsString(studyWeekdays, day) {
+			return time.Time{}, nil, nil, fmt.Errorf("%w: día desconocido %q en minutos_por_dia", ErrInvalidStudyGoal, key)
+		}
+		if value < 0 || value > 600 {
+			return time.Time{}, nil, nil, fmt.Errorf("%w: minutos_por_dia.%s debe estar entre 0 y 600", ErrInvalidStudyGoal, day)
+		}
+		minutos[day] = value
+		total += value
+	}
+	if total == 0 {
+		return time.Time{}, nil, nil, fmt.Errorf("%w: indica minutos disponibles para al menos un día", ErrInvalidStudyGoal)
+	}
+
+	var objectiveIDs []uint
+	for _, id := range input.OABloomObjectiveIDs {
+		if !containsUint(objectiveIDs, id) {
+			objectiveIDs = append(objectiveIDs, id)
+		}
+	}
+	if len(objectiveIDs) == 0 || len(objectiveIDs) > maxStudyGoalObjectives {
+		return time.Time{}, nil, nil, fmt.Errorf("%w: indica entre 1 y %d oa_bloom_objective_ids", ErrInvalidStudyGoal, maxStudyGoalObjectives)
+	}
+	var count int64
+	if err := db.DB.Model(&models.OABloomObjective{}).Where("id IN ?", objectiveIDs).Count(&count).Error; err != nil {
+		return time.Time{}, nil, nil, err
+	}
+	if int(count) != len(objectiveIDs) {
+		return time.Time{}, nil, nil, fmt.Errorf("%w: algún objetivo OA-Bloom no existe", ErrInvalidStudyGoal)
+	}
+	return fechaLimite, minutos, objectiveIDs, nil
+}
+
+func createStudyGoalObjectives(tx *gorm.DB, goalID uint, objectiveIDs []uint) error {
+	links := make([]models.StudyGoalObjective, len(objectiveIDs))
+	for i, id := range objectiveIDs {
+		links[i] = models.StudyGoalObjective{StudyGoalID: goalID, OABloomObjectiveID: id}
+	}
+	return tx.Create(&links).Error
+}
+
+// studyGoalMinutes retorna los minutos disponibles de lunes a domingo
+func studyGoalMinutes(goal *models.StudyGoal) [7]int {
+	var byDay map[string]int
+	json.Unmarshal(goal.MinutosPorDia, &byDay)
+	var minutos [7]int
+	for i, day := range studyWeekdays {
+		minutos[i] = byDay[day]
+	}
+	return minutos
+}
+
+// studyDate es el día calendario de t (medianoche UTC), para comparar con columnas DATE
+func studyDate(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// studyWeekdayIndex es el índice del día en studyWeekdays (lunes = 0)
+func studyWeekdayIndex(day time.Time) int {
+	return (int(day.Weekday()) + 6) % 7
+}
+
+func newStudyGoalToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
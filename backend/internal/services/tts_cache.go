@@ -0,0 +1,21 @@
+package services
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"path/filepath"
+)
+
+// TTSCacheDir es donde /api/tts/generate guarda los audios de ElevenLabs
+const TTSCacheDir = "./static/tts-cache"
+
+// TTSCacheKey es el nombre del audio cacheado de un texto (md5 del texto)
+func TTSCacheKey(text string) string {
+	hash := md5.Sum([]byte(text))
+	return hex.EncodeToString(hash[:])
+}
+
+// TTSCachePath es la ruta del mp3 cacheado de un texto (puede no existir)
+func TTSCachePath(text string) string {
+	return filepath.Join(TTSCacheDir, TTSCacheKey(text)+".mp3")
+}
@@ -0,0 +1,7 @@
+-- Drop LLM usage accounting
+ALTER TABLE users DROP COLUMN IF EXISTS llm_daily_budget_usd;
+
+DROP INDEX IF EXISTS idx_llm_calls_created_at;
+DROP INDEX IF EXISTS idx_llm_calls_feature;
+DROP INDEX IF EXISTS idx_llm_calls_user_created;
+DROP TABLE IF EXISTS llm_calls;
@@ -0,0 +1,33 @@
+-- Create llm_calls table: one row per LLM request (backend and generator tools)
+CREATE TABLE IF NOT EXISTS llm_calls (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    feature VARCHAR(50) NOT NULL,
+    kind VARCHAR(10) NOT NULL DEFAULT 'chat',
+    model VARCHAR(100) NOT NULL,
+    prompt_tokens INTEGER NOT NULL DEFAULT 0,
+    completion_tokens INTEGER NOT NULL DEFAULT 0,
+    total_tokens INTEGER NOT NULL DEFAULT 0,
+    latency_ms BIGINT NOT NULL DEFAULT 0,
+    retry_count INTEGER NOT NULL DEFAULT 0,
+    cost_usd DECIMAL(12,6) NOT NULL DEFAULT 0,
+    success BOOLEAN NOT NULL DEFAULT TRUE,
+    error_mensaje TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Indexes for budget checks and reports
+CREATE INDEX idx_llm_calls_user_created ON llm_calls(user_id, created_at);
+CREATE INDEX idx_llm_calls_feature ON llm_calls(feature);
+CREATE INDEX idx_llm_calls_created_at ON llm_calls(created_at);
+
+-- Per-user daily LLM budget (NULL = use LLM_DAILY_BUDGET_USD default)
+ALTER TABLE users
+ADD COLUMN llm_daily_budget_usd DECIMAL(10,4);
+
+-- Comments
+COMMENT ON TABLE llm_calls IS 'Usage and estimated cost of every LLM call';
+COMMENT ON COLUMN llm_calls.feature IS 'Feature that triggered the call (learning_plan_structure, component_content, question_generation, ...)';
+COMMENT ON COLUMN llm_calls.retry_count IS 'Number of previous attempts within the same retry loop';
+COMMENT ON COLUMN llm_calls.cost_usd IS 'Estimated cost in USD from list prices';
+COMMENT ON COLUMN users.llm_daily_budget_usd IS 'Daily LLM budget in USD; NULL uses the default, 0 means unlimited';
@@ -0,0 +1,6 @@
+-- Drop generation jobs queue
+DROP INDEX IF EXISTS idx_generation_jobs_active_plan;
+DROP INDEX IF EXISTS idx_generation_jobs_learning_plan_id;
+DROP INDEX IF EXISTS idx_generation_jobs_user_id;
+DROP INDEX IF EXISTS idx_generation_jobs_claim;
+DROP TABLE IF EXISTS generation_jobs;
@@ -0,0 +1,35 @@
+-- Create generation_jobs table: DB-backed queue for asynchronous content generation
+CREATE TABLE IF NOT EXISTS generation_jobs (
+    id SERIAL PRIMARY KEY,
+    tipo VARCHAR(50) NOT NULL DEFAULT 'learning_plan',
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    learning_plan_id INTEGER REFERENCES learning_plans(id) ON DELETE CASCADE,
+    payload JSONB NOT NULL DEFAULT '{}',
+    estado VARCHAR(20) NOT NULL DEFAULT 'pendiente'
+        CHECK (estado IN ('pendiente', 'en_proceso', 'completado', 'dead_letter')),
+    intentos INTEGER NOT NULL DEFAULT 0,
+    max_intentos INTEGER NOT NULL DEFAULT 3,
+    run_after TIMESTAMP NOT NULL DEFAULT NOW(),
+    locked_by VARCHAR(100),
+    locked_at TIMESTAMP,
+    error_mensaje TEXT,
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Indexes for claiming jobs and looking up active jobs
+CREATE INDEX idx_generation_jobs_claim ON generation_jobs(estado, run_after);
+CREATE INDEX idx_generation_jobs_user_id ON generation_jobs(user_id);
+CREATE INDEX idx_generation_jobs_learning_plan_id ON generation_jobs(learning_plan_id);
+
+-- At most one active learning plan job per user and OA-Bloom objective
+CREATE UNIQUE INDEX idx_generation_jobs_active_plan
+    ON generation_jobs(user_id, tipo, (payload->>'oa_bloom_objective_id'))
+    WHERE estado IN ('pendiente', 'en_proceso');
+
+-- Comments
+COMMENT ON TABLE generation_jobs IS 'Queue of asynchronous generation jobs processed by backend workers';
+COMMENT ON COLUMN generation_jobs.estado IS 'pendiente (queued), en_proceso (claimed by a worker), completado, dead_letter (gave up after max_intentos)';
+COMMENT ON COLUMN generation_jobs.run_after IS 'Earliest time the job can be claimed (retry backoff)';
+COMMENT ON COLUMN generation_jobs.locked_at IS 'Last heartbeat of the worker holding the job; stale locks are released';
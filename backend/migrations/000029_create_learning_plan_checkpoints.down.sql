@@ -0,0 +1,7 @@
+-- Drop learning plan checkpoints
+COMMENT ON COLUMN student_oa_history.tipo_evento IS 'evaluacion | practica | diagnostico | repaso';
+DROP INDEX IF EXISTS idx_checkpoint_attempts_checkpoint_id;
+DROP TABLE IF EXISTS learning_plan_checkpoint_attempts;
+DROP INDEX IF EXISTS idx_learning_plan_checkpoints_plan_id;
+DROP TABLE IF EXISTS learning_plan_checkpoints;
+ALTER TABLE learning_plan_components DROP COLUMN IF EXISTS es_remediacion;
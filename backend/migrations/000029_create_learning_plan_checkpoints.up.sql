@@ -0,0 +1,43 @@
+-- Create learning_plan_checkpoints: check-for-understanding quizzes inside learning plans
+CREATE TABLE IF NOT EXISTS learning_plan_checkpoints (
+    id SERIAL PRIMARY KEY,
+    learning_plan_id INTEGER NOT NULL REFERENCES learning_plans(id) ON DELETE CASCADE,
+    component_id INTEGER NOT NULL REFERENCES learning_plan_components(id) ON DELETE CASCADE,
+    question_ids INTEGER[] NOT NULL,
+    porcentaje_aprobacion INTEGER NOT NULL DEFAULT 70
+        CHECK (porcentaje_aprobacion BETWEEN 0 AND 100),
+    estado VARCHAR(20) NOT NULL DEFAULT 'pendiente'
+        CHECK (estado IN ('pendiente', 'aprobado', 'reprobado')),
+    intentos INTEGER NOT NULL DEFAULT 0,
+    mejor_porcentaje INTEGER,
+    remediation_component_id INTEGER REFERENCES learning_plan_components(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (learning_plan_id, component_id)
+);
+
+CREATE INDEX idx_learning_plan_checkpoints_plan_id ON learning_plan_checkpoints(learning_plan_id);
+
+-- Graded submissions of each checkpoint
+CREATE TABLE IF NOT EXISTS learning_plan_checkpoint_attempts (
+    id SERIAL PRIMARY KEY,
+    checkpoint_id INTEGER NOT NULL REFERENCES learning_plan_checkpoints(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    respuestas JSONB NOT NULL,
+    correctas INTEGER NOT NULL,
+    total INTEGER NOT NULL,
+    porcentaje INTEGER NOT NULL,
+    aprobado BOOLEAN NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_checkpoint_attempts_checkpoint_id ON learning_plan_checkpoint_attempts(checkpoint_id);
+
+-- Remediation slides inserted after a failed checkpoint
+ALTER TABLE learning_plan_components ADD COLUMN IF NOT EXISTS es_remediacion BOOLEAN NOT NULL DEFAULT false;
+
+-- Comments
+COMMENT ON TABLE learning_plan_checkpoints IS 'Quizzes from the question bank shown after selected plan components; all must be passed to complete the plan';
+COMMENT ON COLUMN learning_plan_checkpoints.component_id IS 'Component after which the checkpoint is shown';
+COMMENT ON COLUMN learning_plan_checkpoints.remediation_component_id IS 'Remediation slide inserted after the first failed attempt';
+COMMENT ON COLUMN student_oa_history.tipo_evento IS 'evaluacion | practica | diagnostico | repaso | checkpoint';
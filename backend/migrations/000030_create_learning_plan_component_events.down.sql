@@ -0,0 +1,8 @@
+-- Drop learning plan component events
+ALTER TABLE learning_plans DROP COLUMN IF EXISTS ultima_actividad;
+COMMENT ON COLUMN learning_plans.progreso_actual IS 'Number of slides completed by the user';
+ALTER TABLE learning_plans DROP COLUMN IF EXISTS ultimo_componente_id;
+DROP INDEX IF EXISTS idx_component_events_created_at;
+DROP INDEX IF EXISTS idx_component_events_component_tipo;
+DROP INDEX IF EXISTS idx_component_events_plan_id;
+DROP TABLE IF EXISTS learning_plan_component_events;
@@ -0,0 +1,24 @@
+-- Create learning_plan_component_events: per-slide view events of learning plans
+CREATE TABLE IF NOT EXISTS learning_plan_component_events (
+    id SERIAL PRIMARY KEY,
+    learning_plan_id INTEGER NOT NULL REFERENCES learning_plans(id) ON DELETE CASCADE,
+    component_id INTEGER NOT NULL REFERENCES learning_plan_components(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    tipo VARCHAR(20) NOT NULL
+        CHECK (tipo IN ('abierto', 'tiempo', 'scroll_final', 'audio')),
+    tiempo_segundos INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_component_events_plan_id ON learning_plan_component_events(learning_plan_id);
+CREATE INDEX idx_component_events_component_tipo ON learning_plan_component_events(component_id, tipo);
+CREATE INDEX idx_component_events_created_at ON learning_plan_component_events(created_at);
+
+-- Resume point and last activity of each plan
+ALTER TABLE learning_plans ADD COLUMN IF NOT EXISTS ultimo_componente_id INTEGER REFERENCES learning_plan_components(id) ON DELETE SET NULL;
+ALTER TABLE learning_plans ADD COLUMN IF NOT EXISTS ultima_actividad TIMESTAMP;
+
+-- Comments
+COMMENT ON TABLE learning_plan_component_events IS 'Slide view events: abierto, tiempo (seconds spent), scroll_final, audio';
+COMMENT ON COLUMN learning_plans.progreso_actual IS 'Number of distinct components opened by the student';
+COMMENT ON COLUMN learning_plans.ultimo_componente_id IS 'Last component opened; the player resumes there';
@@ -0,0 +1,6 @@
+-- Drop learning plan versions and feedback
+DROP INDEX IF EXISTS idx_plan_feedback_component_id;
+DROP INDEX IF EXISTS idx_plan_feedback_plan_id;
+DROP TABLE IF EXISTS learning_plan_component_feedback;
+DROP TABLE IF EXISTS learning_plan_versions;
+ALTER TABLE learning_plans DROP COLUMN IF EXISTS version;
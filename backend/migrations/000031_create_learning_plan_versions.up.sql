@@ -0,0 +1,40 @@
+-- Current version of each learning plan; bumped by every regeneration or rollback
+ALTER TABLE learning_plans ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+-- Snapshots of previous plan versions (plan fields, components with content and checkpoints)
+CREATE TABLE IF NOT EXISTS learning_plan_versions (
+    id SERIAL PRIMARY KEY,
+    learning_plan_id INTEGER NOT NULL REFERENCES learning_plans(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    motivo VARCHAR(30) NOT NULL
+        CHECK (motivo IN ('regeneracion_componente', 'regeneracion_plan', 'rollback')),
+    nota TEXT,
+    snapshot JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (learning_plan_id, version)
+);
+
+-- Student feedback on plan components (or the whole plan when component_id is NULL)
+CREATE TABLE IF NOT EXISTS learning_plan_component_feedback (
+    id SERIAL PRIMARY KEY,
+    learning_plan_id INTEGER NOT NULL REFERENCES learning_plans(id) ON DELETE CASCADE,
+    component_id INTEGER,
+    tipo_componente VARCHAR(100),
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    valoracion VARCHAR(10)
+        CHECK (valoracion IN ('positiva', 'negativa')),
+    comentario TEXT,
+    aplicada_en_version INTEGER,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_plan_feedback_plan_id ON learning_plan_component_feedback(learning_plan_id);
+CREATE INDEX idx_plan_feedback_component_id ON learning_plan_component_feedback(component_id);
+
+-- Comments
+COMMENT ON COLUMN learning_plans.version IS 'Current version; previous ones are stored in learning_plan_versions';
+COMMENT ON COLUMN learning_plan_versions.motivo IS 'Why this version was replaced: regeneracion_componente | regeneracion_plan | rollback';
+COMMENT ON COLUMN learning_plan_component_feedback.component_id IS 'Not a foreign key: feedback outlives components replaced by a regeneration. NULL = feedback on the whole plan';
+COMMENT ON COLUMN learning_plan_component_feedback.version IS 'Plan version the feedback was given on';
+COMMENT ON COLUMN learning_plan_component_feedback.aplicada_en_version IS 'Plan version whose regeneration prompt included this feedback';
@@ -0,0 +1,4 @@
+-- Drop shared content cache
+DROP TABLE IF EXISTS content_cache_metrics;
+DROP INDEX IF EXISTS idx_content_cache_lookup;
+DROP TABLE IF EXISTS component_content_cache;
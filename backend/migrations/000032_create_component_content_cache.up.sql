@@ -0,0 +1,34 @@
+-- Shared cache of generated component content, reused across students
+CREATE TABLE IF NOT EXISTS component_content_cache (
+    id SERIAL PRIMARY KEY,
+    cache_key VARCHAR(64) NOT NULL UNIQUE,
+    oa_bloom_objective_id INTEGER NOT NULL REFERENCES oa_bloom_objectives(id) ON DELETE CASCADE,
+    tipo_componente VARCHAR(100) NOT NULL,
+    bloom_level INTEGER NOT NULL,
+    objetivo_normalizado TEXT NOT NULL,
+    bucket VARCHAR(50) NOT NULL,
+    prompt_version VARCHAR(20) NOT NULL,
+    contenido_props JSONB NOT NULL,
+    hits INTEGER NOT NULL DEFAULT 0,
+    last_hit_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_content_cache_lookup ON component_content_cache(oa_bloom_objective_id, tipo_componente, bucket, prompt_version);
+
+-- Daily hit/miss counters per component type
+CREATE TABLE IF NOT EXISTS content_cache_metrics (
+    fecha DATE NOT NULL,
+    tipo_componente VARCHAR(100) NOT NULL,
+    hits INTEGER NOT NULL DEFAULT 0,
+    misses INTEGER NOT NULL DEFAULT 0,
+    bypasses INTEGER NOT NULL DEFAULT 0,
+    personalizaciones INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (fecha, tipo_componente)
+);
+
+-- Comments
+COMMENT ON TABLE component_content_cache IS 'Generic (non-personalized) component content keyed by objective, Bloom level, personalization bucket and prompt version';
+COMMENT ON COLUMN component_content_cache.bucket IS 'Normalized profile traits that change the content beyond examples (preferred format)';
+COMMENT ON COLUMN component_content_cache.prompt_version IS 'Content prompt version; entries of other versions are ignored and purged on startup';
+COMMENT ON COLUMN content_cache_metrics.bypasses IS 'Generations that skipped the cache (regenerations with student feedback)';
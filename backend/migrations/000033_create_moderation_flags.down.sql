@@ -0,0 +1,8 @@
+-- Drop moderation flags and the quarantine component state
+DROP INDEX IF EXISTS idx_moderation_flags_entidad;
+DROP INDEX IF EXISTS idx_moderation_flags_estado;
+DROP TABLE IF EXISTS moderation_flags;
+UPDATE learning_plan_components SET estado = 'error', error_mensaje = 'contenido en revisión' WHERE estado = 'cuarentena';
+ALTER TABLE learning_plan_components DROP CONSTRAINT IF EXISTS learning_plan_components_estado_check;
+ALTER TABLE learning_plan_components ADD CONSTRAINT learning_plan_components_estado_check
+    CHECK (estado IN ('pendiente', 'generando', 'generado', 'error'));
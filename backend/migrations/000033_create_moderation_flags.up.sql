@@ -0,0 +1,34 @@
+-- Quarantined components are hidden from the student until an admin reviews them
+ALTER TABLE learning_plan_components DROP CONSTRAINT IF EXISTS learning_plan_components_estado_check;
+ALTER TABLE learning_plan_components ADD CONSTRAINT learning_plan_components_estado_check
+    CHECK (estado IN ('pendiente', 'generando', 'generado', 'error', 'cuarentena'));
+
+-- Texts flagged by the moderation classifiers, pending admin review
+CREATE TABLE IF NOT EXISTS moderation_flags (
+    id SERIAL PRIMARY KEY,
+    origen VARCHAR(30) NOT NULL
+        CHECK (origen IN ('contenido_generado', 'pregunta', 'entrada_estudiante')),
+    entidad_tipo VARCHAR(50) NOT NULL,
+    entidad_id INTEGER,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    texto TEXT NOT NULL,
+    contenido JSONB,
+    categorias TEXT[],
+    motivo TEXT NOT NULL,
+    clasificador VARCHAR(50) NOT NULL,
+    estado VARCHAR(20) NOT NULL DEFAULT 'pendiente'
+        CHECK (estado IN ('pendiente', 'aprobado', 'rechazado')),
+    revisado_por INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    revisado_en TIMESTAMP,
+    nota_revision TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_moderation_flags_estado ON moderation_flags(estado, created_at);
+CREATE INDEX idx_moderation_flags_entidad ON moderation_flags(entidad_tipo, entidad_id);
+
+-- Comments
+COMMENT ON TABLE moderation_flags IS 'Generated content, questions and student inputs flagged by moderation, with their admin review';
+COMMENT ON COLUMN moderation_flags.entidad_tipo IS 'learning_plan_component, question, plan_feedback, plan_regeneration, student_profile or content_personalization';
+COMMENT ON COLUMN moderation_flags.contenido IS 'Quarantined content, restored to the entity when an admin approves it';
+COMMENT ON COLUMN moderation_flags.clasificador IS 'Classifier that flagged the text (blocklist, openai)';
@@ -0,0 +1,5 @@
+-- Drop prompt templates and the recorded prompt versions
+DROP INDEX IF EXISTS idx_learning_plans_prompt_version;
+ALTER TABLE learning_plan_components DROP COLUMN IF EXISTS prompt_version;
+ALTER TABLE learning_plans DROP COLUMN IF EXISTS prompt_version;
+DROP TABLE IF EXISTS prompt_templates;
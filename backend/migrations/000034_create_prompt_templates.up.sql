@@ -0,0 +1,25 @@
+-- Prompt templates edited without a redeploy; they override the templates embedded in the binary
+CREATE TABLE IF NOT EXISTS prompt_templates (
+    id SERIAL PRIMARY KEY,
+    nombre VARCHAR(100) NOT NULL,
+    version VARCHAR(20) NOT NULL,
+    contenido TEXT NOT NULL,
+    peso INTEGER NOT NULL DEFAULT 0 CHECK (peso >= 0),
+    actualizado_por INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (nombre, version)
+);
+
+-- Prompt version used to generate each plan structure and component content
+ALTER TABLE learning_plans ADD COLUMN IF NOT EXISTS prompt_version VARCHAR(20);
+ALTER TABLE learning_plan_components ADD COLUMN IF NOT EXISTS prompt_version VARCHAR(20);
+
+CREATE INDEX idx_learning_plans_prompt_version ON learning_plans(prompt_version) WHERE prompt_version IS NOT NULL;
+
+-- Comments
+COMMENT ON TABLE prompt_templates IS 'text/template prompt versions; a row replaces the embedded template with the same name and version';
+COMMENT ON COLUMN prompt_templates.nombre IS 'plan_structure, example_personalization or a component type';
+COMMENT ON COLUMN prompt_templates.peso IS 'A/B assignment weight; 0 keeps the version loaded but assigns no new students';
+COMMENT ON COLUMN learning_plans.prompt_version IS 'plan_structure template version (NULL for plans created before versioned prompts)';
+COMMENT ON COLUMN learning_plan_components.prompt_version IS 'Template version of the component type that generated the content';
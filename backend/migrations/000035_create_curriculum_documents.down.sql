@@ -0,0 +1,5 @@
+-- Drop curriculum documents and the recorded citations (the vector extension is left installed)
+ALTER TABLE questions DROP COLUMN IF EXISTS fuentes;
+ALTER TABLE learning_plans DROP COLUMN IF EXISTS fuentes;
+DROP TABLE IF EXISTS curriculum_chunks;
+DROP TABLE IF EXISTS curriculum_documents;
@@ -0,0 +1,55 @@
+-- pgvector is optional: without it the backend searches an in-memory index
+DO $$
+BEGIN
+    CREATE EXTENSION IF NOT EXISTS vector;
+EXCEPTION WHEN OTHERS THEN
+    RAISE NOTICE 'pgvector extension not available, curriculum search will use the in-memory index';
+END
+$$;
+
+-- Reference documents (official curriculum, programs, teacher guides) uploaded per materia/OA
+CREATE TABLE IF NOT EXISTS curriculum_documents (
+    id SERIAL PRIMARY KEY,
+    materia_id INTEGER NOT NULL REFERENCES materias(id) ON DELETE CASCADE,
+    oa_id INTEGER REFERENCES objetivos_aprendizaje(id) ON DELETE CASCADE,
+    titulo VARCHAR(300) NOT NULL,
+    formato VARCHAR(20) NOT NULL CHECK (formato IN ('markdown', 'texto', 'pdf')),
+    fuente VARCHAR(500),
+    contenido TEXT NOT NULL,
+    subido_por INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    chunks INTEGER NOT NULL DEFAULT 0,
+    embedding_model VARCHAR(100),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_curriculum_documents_materia ON curriculum_documents(materia_id, oa_id);
+
+-- Embedded fragments of the documents; the vector is a plain REAL[] so the table works
+-- without pgvector, which casts it with embedding::vector when searching
+CREATE TABLE IF NOT EXISTS curriculum_chunks (
+    id SERIAL PRIMARY KEY,
+    document_id INTEGER NOT NULL REFERENCES curriculum_documents(id) ON DELETE CASCADE,
+    materia_id INTEGER NOT NULL,
+    oa_id INTEGER,
+    orden INTEGER NOT NULL,
+    texto TEXT NOT NULL,
+    embedding REAL[] NOT NULL,
+    embedding_model VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (document_id, orden)
+);
+
+CREATE INDEX idx_curriculum_chunks_lookup ON curriculum_chunks(embedding_model, materia_id, oa_id);
+
+-- Passages used to generate each plan and question
+ALTER TABLE learning_plans ADD COLUMN IF NOT EXISTS fuentes JSONB;
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS fuentes JSONB;
+
+-- Comments
+COMMENT ON TABLE curriculum_documents IS 'Curriculum reference documents; their passages are injected into generation prompts';
+COMMENT ON COLUMN curriculum_documents.oa_id IS 'NULL when the document applies to every OA of the materia';
+COMMENT ON COLUMN curriculum_documents.embedding_model IS 'Embedding provider used for the current chunks';
+COMMENT ON COLUMN curriculum_chunks.embedding IS 'Unit-length vector; only comparable with vectors of the same embedding_model';
+COMMENT ON COLUMN learning_plans.fuentes IS 'Curriculum passages cited by the plan structure prompt';
+COMMENT ON COLUMN questions.fuentes IS 'Curriculum passages cited by the question generation prompt';
@@ -0,0 +1,2 @@
+-- Drop the OA prerequisite graph
+DROP TABLE IF EXISTS oa_prerequisites;
@@ -0,0 +1,25 @@
+-- Prerequisite graph between OAs: oa_id builds on prerequisito_oa_id.
+-- An edge can be narrowed to specific Bloom objectives on either side (e.g. "OA-5 Aplicar needs OA-3 Comprender").
+CREATE TABLE IF NOT EXISTS oa_prerequisites (
+    id SERIAL PRIMARY KEY,
+    oa_id INTEGER NOT NULL REFERENCES objetivos_aprendizaje(id) ON DELETE CASCADE,
+    prerequisito_oa_id INTEGER NOT NULL REFERENCES objetivos_aprendizaje(id) ON DELETE CASCADE,
+    oa_bloom_objective_id INTEGER REFERENCES oa_bloom_objectives(id) ON DELETE CASCADE,
+    prerequisito_oa_bloom_objective_id INTEGER REFERENCES oa_bloom_objectives(id) ON DELETE CASCADE,
+    nota TEXT,
+    creado_por INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (oa_id <> prerequisito_oa_id)
+);
+
+CREATE UNIQUE INDEX idx_oa_prerequisites_edge ON oa_prerequisites(
+    oa_id, prerequisito_oa_id,
+    COALESCE(oa_bloom_objective_id, 0), COALESCE(prerequisito_oa_bloom_objective_id, 0)
+);
+CREATE INDEX idx_oa_prerequisites_prerequisito ON oa_prerequisites(prerequisito_oa_id);
+
+-- Comments
+COMMENT ON TABLE oa_prerequisites IS 'Directed acyclic graph of OA prerequisites (cycles are rejected by the backend)';
+COMMENT ON COLUMN oa_prerequisites.oa_bloom_objective_id IS 'NULL when the whole OA depends on the prerequisite';
+COMMENT ON COLUMN oa_prerequisites.prerequisito_oa_bloom_objective_id IS 'NULL when mastering the prerequisite OA at any Bloom level is enough';
@@ -0,0 +1,2 @@
+-- Drop recommendation impressions
+DROP TABLE IF EXISTS recommendation_impressions;
@@ -0,0 +1,26 @@
+-- Every recommendation shown to a student, with the signals that ranked it, to measure the recommender
+CREATE TABLE IF NOT EXISTS recommendation_impressions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    lote VARCHAR(32) NOT NULL,
+    origen VARCHAR(20) NOT NULL CHECK (origen IN ('daily', 'list')),
+    posicion INTEGER NOT NULL,
+    materia_id INTEGER NOT NULL REFERENCES materias(id) ON DELETE CASCADE,
+    oa_id INTEGER NOT NULL REFERENCES objetivos_aprendizaje(id) ON DELETE CASCADE,
+    oa_bloom_objective_id INTEGER NOT NULL REFERENCES oa_bloom_objectives(id) ON DELETE CASCADE,
+    recommendation_type VARCHAR(30) NOT NULL,
+    score DECIMAL(8,4) NOT NULL,
+    reasons TEXT[] NOT NULL DEFAULT '{}',
+    signals JSONB,
+    clicked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_recommendation_impressions_user ON recommendation_impressions(user_id, created_at DESC);
+CREATE INDEX idx_recommendation_impressions_created ON recommendation_impressions(created_at);
+
+-- Comments
+COMMENT ON TABLE recommendation_impressions IS 'Recommendations shown to students and whether they were clicked';
+COMMENT ON COLUMN recommendation_impressions.lote IS 'Groups the items returned by one recommender call';
+COMMENT ON COLUMN recommendation_impressions.reasons IS 'Reason codes explaining the recommendation (review_due, mastery_gap, ...)';
+COMMENT ON COLUMN recommendation_impressions.signals IS 'Normalized 0-1 signals used to score the candidate';
@@ -0,0 +1,15 @@
+-- Drop bandit state and reward columns
+DROP INDEX IF EXISTS idx_recommendation_impressions_unrewarded;
+
+ALTER TABLE recommendation_impressions
+    DROP COLUMN IF EXISTS contexto,
+    DROP COLUMN IF EXISTS politica,
+    DROP COLUMN IF EXISTS probabilidad,
+    DROP COLUMN IF EXISTS brazos,
+    DROP COLUMN IF EXISTS porcentaje_inicial,
+    DROP COLUMN IF EXISTS practica_completada,
+    DROP COLUMN IF EXISTS delta_logro,
+    DROP COLUMN IF EXISTS recompensa,
+    DROP COLUMN IF EXISTS recompensada_at;
+
+DROP TABLE IF EXISTS recommendation_bandit_arms;
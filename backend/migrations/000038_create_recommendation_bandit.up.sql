@@ -0,0 +1,34 @@
+-- Bandit policy state: Beta posterior of the reward of each recommendation type per context
+CREATE TABLE IF NOT EXISTS recommendation_bandit_arms (
+    id SERIAL PRIMARY KEY,
+    brazo VARCHAR(30) NOT NULL,
+    contexto VARCHAR(60) NOT NULL,
+    alfa DECIMAL(12,4) NOT NULL DEFAULT 1,
+    beta DECIMAL(12,4) NOT NULL DEFAULT 1,
+    jugadas INTEGER NOT NULL DEFAULT 0,
+    recompensa_total DECIMAL(12,4) NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (brazo, contexto)
+);
+
+-- Bandit decision and observed reward of daily recommendations
+ALTER TABLE recommendation_impressions
+    ADD COLUMN contexto VARCHAR(60),
+    ADD COLUMN politica VARCHAR(30),
+    ADD COLUMN probabilidad DECIMAL(6,4),
+    ADD COLUMN brazos JSONB,
+    ADD COLUMN porcentaje_inicial INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN practica_completada BOOLEAN,
+    ADD COLUMN delta_logro INTEGER,
+    ADD COLUMN recompensa DECIMAL(5,4),
+    ADD COLUMN recompensada_at TIMESTAMP;
+
+CREATE INDEX idx_recommendation_impressions_unrewarded ON recommendation_impressions(created_at)
+    WHERE politica IS NOT NULL AND recompensada_at IS NULL;
+
+-- Comments
+COMMENT ON TABLE recommendation_bandit_arms IS 'Beta(alfa, beta) posterior of the reward of each recommendation type (arm) per candidate context';
+COMMENT ON COLUMN recommendation_bandit_arms.contexto IS 'Discretized candidate features, e.g. gap:high,recency:stale';
+COMMENT ON COLUMN recommendation_impressions.probabilidad IS 'Probability the logging policy had of choosing the shown arm (for offline evaluation)';
+COMMENT ON COLUMN recommendation_impressions.brazos IS 'Arms available at decision time: best candidate per type with its context and ranker score';
+COMMENT ON COLUMN recommendation_impressions.recompensa IS 'Reward in [0,1] from practice completion and mastery delta within the reward window';
@@ -0,0 +1,4 @@
+-- Drop study goals
+DROP TABLE IF EXISTS study_goal_tasks;
+DROP TABLE IF EXISTS study_goal_objectives;
+DROP TABLE IF EXISTS study_goals;
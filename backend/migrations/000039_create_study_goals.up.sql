@@ -0,0 +1,48 @@
+-- Study goals: master a set of OA-Bloom objectives before a deadline with the minutes available per weekday
+CREATE TABLE IF NOT EXISTS study_goals (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    titulo VARCHAR(255) NOT NULL,
+    fecha_limite DATE NOT NULL,
+    minutos_por_dia JSONB NOT NULL,
+    estado VARCHAR(20) NOT NULL DEFAULT 'activa' CHECK (estado IN ('activa', 'completada', 'vencida')),
+    ical_token VARCHAR(64) NOT NULL UNIQUE,
+    replanificada_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_study_goals_user ON study_goals(user_id, estado);
+
+CREATE TABLE IF NOT EXISTS study_goal_objectives (
+    id SERIAL PRIMARY KEY,
+    study_goal_id INTEGER NOT NULL REFERENCES study_goals(id) ON DELETE CASCADE,
+    oa_bloom_objective_id INTEGER NOT NULL REFERENCES oa_bloom_objectives(id) ON DELETE CASCADE,
+    UNIQUE (study_goal_id, oa_bloom_objective_id)
+);
+
+-- Day-by-day schedule of a goal
+CREATE TABLE IF NOT EXISTS study_goal_tasks (
+    id SERIAL PRIMARY KEY,
+    study_goal_id INTEGER NOT NULL REFERENCES study_goals(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    oa_bloom_objective_id INTEGER NOT NULL REFERENCES oa_bloom_objectives(id) ON DELETE CASCADE,
+    oa_id INTEGER NOT NULL REFERENCES objetivos_aprendizaje(id) ON DELETE CASCADE,
+    learning_plan_id INTEGER REFERENCES learning_plans(id) ON DELETE SET NULL,
+    fecha DATE,
+    orden INTEGER NOT NULL DEFAULT 0,
+    tipo VARCHAR(30) NOT NULL CHECK (tipo IN ('practica', 'plan_aprendizaje', 'repaso')),
+    titulo VARCHAR(500) NOT NULL,
+    minutos INTEGER NOT NULL,
+    estado VARCHAR(20) NOT NULL DEFAULT 'pendiente' CHECK (estado IN ('pendiente', 'completada', 'omitida')),
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_study_goal_tasks_goal ON study_goal_tasks(study_goal_id, fecha, orden);
+
+-- Comments
+COMMENT ON COLUMN study_goals.minutos_por_dia IS 'Minutes available per weekday, e.g. {"lunes": 30, "sabado": 60}';
+COMMENT ON COLUMN study_goals.ical_token IS 'Secret of the public iCalendar feed of the goal';
+COMMENT ON COLUMN study_goal_tasks.fecha IS 'Scheduled day; NULL when the task does not fit before the deadline';
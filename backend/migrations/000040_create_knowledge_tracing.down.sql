@@ -0,0 +1,4 @@
+-- Drop knowledge tracing tables
+DROP TABLE IF EXISTS student_knowledge_states;
+DROP INDEX IF EXISTS idx_knowledge_tracing_params_objective;
+DROP TABLE IF EXISTS knowledge_tracing_params;
@@ -0,0 +1,38 @@
+-- Bayesian Knowledge Tracing parameters, fit per OA-Bloom objective from answer history
+CREATE TABLE IF NOT EXISTS knowledge_tracing_params (
+    id SERIAL PRIMARY KEY,
+    oa_bloom_objective_id INTEGER REFERENCES oa_bloom_objectives(id) ON DELETE CASCADE,
+    p_inicial DECIMAL(6,4) NOT NULL,
+    p_aprendizaje DECIMAL(6,4) NOT NULL,
+    p_adivinar DECIMAL(6,4) NOT NULL,
+    p_desliz DECIMAL(6,4) NOT NULL,
+    respuestas INTEGER NOT NULL DEFAULT 0,
+    estudiantes INTEGER NOT NULL DEFAULT 0,
+    log_verosimilitud DECIMAL(14,4),
+    ajustado_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- One row per objective, plus the pooled fallback (NULL objective)
+CREATE UNIQUE INDEX idx_knowledge_tracing_params_objective ON knowledge_tracing_params (COALESCE(oa_bloom_objective_id, 0));
+
+-- Mastery posterior of each student on each OA-Bloom objective
+CREATE TABLE IF NOT EXISTS student_knowledge_states (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    oa_bloom_objective_id INTEGER NOT NULL REFERENCES oa_bloom_objectives(id) ON DELETE CASCADE,
+    p_dominio DECIMAL(6,4) NOT NULL,
+    respuestas INTEGER NOT NULL DEFAULT 0,
+    correctas INTEGER NOT NULL DEFAULT 0,
+    ultima_respuesta_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, oa_bloom_objective_id)
+);
+
+-- Comments
+COMMENT ON TABLE knowledge_tracing_params IS 'BKT parameters per OA-Bloom objective; the row with NULL objective is fit on all answers and used when an objective has too few';
+COMMENT ON COLUMN knowledge_tracing_params.p_inicial IS 'P(L0): probability of knowing the objective before the first answer';
+COMMENT ON COLUMN knowledge_tracing_params.p_aprendizaje IS 'P(T): probability of learning it after each answer';
+COMMENT ON COLUMN knowledge_tracing_params.p_adivinar IS 'P(G): probability of a correct answer without knowing it';
+COMMENT ON COLUMN knowledge_tracing_params.p_desliz IS 'P(S): probability of a wrong answer while knowing it';
+COMMENT ON COLUMN student_knowledge_states.p_dominio IS 'Posterior P(L) after the last diagnostic or practice answer; drives student_oa_progress.estado and porcentaje_logro';
@@ -0,0 +1,14 @@
+-- Drop response effort columns
+ALTER TABLE diagnostic_sessions
+    DROP COLUMN IF EXISTS adivinanzas_rapidas,
+    DROP COLUMN IF EXISTS compromiso;
+ALTER TABLE practice_sessions
+    DROP COLUMN IF EXISTS adivinanzas_rapidas,
+    DROP COLUMN IF EXISTS compromiso;
+
+ALTER TABLE diagnostic_answers DROP COLUMN IF EXISTS adivinanza_rapida;
+ALTER TABLE practice_answers DROP COLUMN IF EXISTS adivinanza_rapida;
+
+ALTER TABLE questions
+    DROP COLUMN IF EXISTS tiempo_normativo_segundos,
+    DROP COLUMN IF EXISTS respuestas_cronometradas;
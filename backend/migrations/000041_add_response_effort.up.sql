@@ -0,0 +1,23 @@
+-- Normative response time of each question (median of timed answers)
+ALTER TABLE questions
+    ADD COLUMN tiempo_normativo_segundos DECIMAL(8,2),
+    ADD COLUMN respuestas_cronometradas INTEGER NOT NULL DEFAULT 0;
+
+-- Rapid-guess flags on answers
+ALTER TABLE practice_answers ADD COLUMN adivinanza_rapida BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE diagnostic_answers ADD COLUMN adivinanza_rapida BOOLEAN NOT NULL DEFAULT false;
+
+-- Engagement of each session
+ALTER TABLE practice_sessions
+    ADD COLUMN adivinanzas_rapidas INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN compromiso DECIMAL(5,4);
+ALTER TABLE diagnostic_sessions
+    ADD COLUMN adivinanzas_rapidas INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN compromiso DECIMAL(5,4);
+
+-- Comments
+COMMENT ON COLUMN questions.tiempo_normativo_segundos IS 'Median tiempo_segundos of the answers to the question; the rapid-guess threshold is a fraction of it';
+COMMENT ON COLUMN practice_answers.adivinanza_rapida IS 'Answered faster than the question''s rapid-guess threshold (no real effort)';
+COMMENT ON COLUMN diagnostic_answers.adivinanza_rapida IS 'Answered faster than the question''s rapid-guess threshold (no real effort)';
+COMMENT ON COLUMN practice_sessions.compromiso IS 'Response time effort: share of answers in the session that were not rapid guesses';
+COMMENT ON COLUMN diagnostic_sessions.compromiso IS 'Response time effort: share of answers in the session that were not rapid guesses';
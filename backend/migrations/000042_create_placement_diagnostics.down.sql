@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_diagnostic_sessions_placement;
+ALTER TABLE diagnostic_sessions DROP COLUMN IF EXISTS placement_diagnostic_id;
+DROP TABLE IF EXISTS placement_diagnostics;
@@ -0,0 +1,27 @@
+-- Placement diagnostics: one evaluation across every materia of the student's curso
+CREATE TABLE IF NOT EXISTS placement_diagnostics (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    curso_id INTEGER REFERENCES cursos(id) ON DELETE SET NULL,
+    estado VARCHAR(20) NOT NULL DEFAULT 'en_progreso' CHECK (estado IN ('en_progreso', 'completado')),
+    presupuesto_preguntas INTEGER NOT NULL,
+    presupuesto_minutos INTEGER NOT NULL,
+    plan JSONB NOT NULL DEFAULT '[]',
+    informe JSONB,
+    started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMP
+);
+
+CREATE INDEX idx_placement_diagnostics_user ON placement_diagnostics(user_id, started_at DESC);
+
+-- Each materia of a placement is evaluated in its own diagnostic session
+ALTER TABLE diagnostic_sessions
+    ADD COLUMN placement_diagnostic_id INTEGER REFERENCES placement_diagnostics(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_diagnostic_sessions_placement ON diagnostic_sessions(placement_diagnostic_id);
+
+-- Comments
+COMMENT ON TABLE placement_diagnostics IS 'Full-curriculum placement diagnostic across the materias of a curso, within a question and time budget';
+COMMENT ON COLUMN placement_diagnostics.plan IS 'Ordered OAs to evaluate, sampled stratified by materia and categoria: [{materia_id, oa_id, categoria, session_id}]';
+COMMENT ON COLUMN placement_diagnostics.informe IS 'Consolidated placement report, written on completion';
+COMMENT ON COLUMN diagnostic_sessions.placement_diagnostic_id IS 'Placement diagnostic the session belongs to (NULL for single-materia diagnostics)';
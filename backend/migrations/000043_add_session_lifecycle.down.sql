@@ -0,0 +1,23 @@
+DROP INDEX IF EXISTS idx_diagnostic_sessions_open;
+DROP INDEX IF EXISTS idx_practice_sessions_open;
+
+ALTER TABLE diagnostic_sessions DROP CONSTRAINT IF EXISTS chk_diagnostic_sessions_estado;
+ALTER TABLE practice_sessions DROP CONSTRAINT IF EXISTS chk_practice_sessions_estado;
+
+-- Sessions in the new states go back to the closest original one
+UPDATE diagnostic_sessions SET estado = 'en_progreso' WHERE estado = 'pausado';
+UPDATE diagnostic_sessions SET estado = 'abandonado' WHERE estado = 'expirado';
+UPDATE practice_sessions SET estado = 'en_progreso' WHERE estado = 'pausado';
+UPDATE practice_sessions SET estado = 'abandonado' WHERE estado = 'expirado';
+
+ALTER TABLE diagnostic_sessions ADD CONSTRAINT diagnostic_sessions_estado_check
+    CHECK (estado IN ('en_progreso', 'completado', 'abandonado'));
+
+ALTER TABLE diagnostic_sessions
+    DROP COLUMN IF EXISTS pregunta_pendiente_id,
+    DROP COLUMN IF EXISTS pausado_at,
+    DROP COLUMN IF EXISTS ultima_actividad_at;
+ALTER TABLE practice_sessions
+    DROP COLUMN IF EXISTS pregunta_pendiente_id,
+    DROP COLUMN IF EXISTS pausado_at,
+    DROP COLUMN IF EXISTS ultima_actividad_at;
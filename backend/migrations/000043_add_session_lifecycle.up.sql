@@ -0,0 +1,35 @@
+-- Lifecycle of practice and diagnostic sessions: pause, resume, expiry and abandonment
+ALTER TABLE practice_sessions
+    ADD COLUMN ultima_actividad_at TIMESTAMP,
+    ADD COLUMN pausado_at TIMESTAMP,
+    ADD COLUMN pregunta_pendiente_id INTEGER REFERENCES questions(id) ON DELETE SET NULL;
+ALTER TABLE diagnostic_sessions
+    ADD COLUMN ultima_actividad_at TIMESTAMP,
+    ADD COLUMN pausado_at TIMESTAMP,
+    ADD COLUMN pregunta_pendiente_id INTEGER REFERENCES questions(id) ON DELETE SET NULL;
+
+UPDATE practice_sessions SET ultima_actividad_at = COALESCE(completed_at, updated_at, started_at);
+UPDATE diagnostic_sessions SET ultima_actividad_at = COALESCE(completed_at, started_at);
+
+ALTER TABLE practice_sessions ALTER COLUMN ultima_actividad_at SET DEFAULT NOW();
+ALTER TABLE practice_sessions ALTER COLUMN ultima_actividad_at SET NOT NULL;
+ALTER TABLE diagnostic_sessions ALTER COLUMN ultima_actividad_at SET DEFAULT NOW();
+ALTER TABLE diagnostic_sessions ALTER COLUMN ultima_actividad_at SET NOT NULL;
+
+ALTER TABLE practice_sessions ADD CONSTRAINT chk_practice_sessions_estado
+    CHECK (estado IN ('en_progreso', 'pausado', 'abandonado', 'expirado', 'completado'));
+-- The inline check of 000011 only allows en_progreso, completado and abandonado
+ALTER TABLE diagnostic_sessions DROP CONSTRAINT IF EXISTS diagnostic_sessions_estado_check;
+ALTER TABLE diagnostic_sessions ADD CONSTRAINT chk_diagnostic_sessions_estado
+    CHECK (estado IN ('en_progreso', 'pausado', 'abandonado', 'expirado', 'completado'));
+
+-- Expiry sweeper and concurrent-session checks look up open sessions by activity
+CREATE INDEX idx_practice_sessions_open ON practice_sessions(estado, ultima_actividad_at) WHERE estado IN ('en_progreso', 'pausado');
+CREATE INDEX idx_diagnostic_sessions_open ON diagnostic_sessions(estado, ultima_actividad_at) WHERE estado IN ('en_progreso', 'pausado');
+
+-- Comments
+COMMENT ON COLUMN practice_sessions.estado IS 'en_progreso | pausado | abandonado | expirado | completado';
+COMMENT ON COLUMN diagnostic_sessions.estado IS 'en_progreso | pausado | abandonado | expirado | completado';
+COMMENT ON COLUMN practice_sessions.ultima_actividad_at IS 'Last question served, answer or state change; inactive sessions expire';
+COMMENT ON COLUMN practice_sessions.pregunta_pendiente_id IS 'Question served and not yet answered, served again on resume';
+COMMENT ON COLUMN diagnostic_sessions.pregunta_pendiente_id IS 'Question served and not yet answered, served again on resume';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS misconception_observations;
+DROP TABLE IF EXISTS misconceptions;
@@ -0,0 +1,65 @@
+-- Misconception catalog per materia: "the student confuses concepto with confundido_con"
+CREATE TABLE IF NOT EXISTS misconceptions (
+    id SERIAL PRIMARY KEY,
+    materia_id INTEGER NOT NULL REFERENCES materias(id) ON DELETE CASCADE,
+    codigo VARCHAR(50) NOT NULL,
+    concepto VARCHAR(150) NOT NULL,
+    confundido_con VARCHAR(150) NOT NULL,
+    descripcion TEXT,
+    remediacion TEXT,
+    activo BOOLEAN NOT NULL DEFAULT TRUE,
+    creado_por INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (materia_id, codigo)
+);
+
+-- One row per misconception behind a wrong multiple_choice answer
+CREATE TABLE IF NOT EXISTS misconception_observations (
+    id SERIAL PRIMARY KEY,
+    misconception_id INTEGER NOT NULL REFERENCES misconceptions(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    question_id INTEGER NOT NULL REFERENCES questions(id) ON DELETE CASCADE,
+    opcion VARCHAR(10) NOT NULL,
+    origen VARCHAR(20) NOT NULL CHECK (origen IN ('practica', 'diagnostico')),
+    session_id INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_misconception_observations_session ON misconception_observations(origen, session_id);
+CREATE INDEX idx_misconception_observations_user ON misconception_observations(user_id, created_at);
+CREATE INDEX idx_misconception_observations_misconception ON misconception_observations(misconception_id, created_at);
+
+-- Starter catalog for the seeded materias
+INSERT INTO misconceptions (materia_id, codigo, concepto, confundido_con, descripcion, remediacion)
+SELECT m.id, seed.codigo, seed.concepto, seed.confundido_con, seed.descripcion, seed.remediacion
+FROM materias m
+JOIN (VALUES
+    ('MAT', 'FRAC-SUMA', 'la suma de fracciones', 'sumar numeradores y denominadores por separado',
+     'Suma 1/2 + 1/3 como 2/5.', 'Busca un denominador común antes de sumar los numeradores.'),
+    ('MAT', 'POT-PRODUCTO', 'una potencia', 'multiplicar la base por el exponente',
+     'Calcula 2^3 como 6.', 'Una potencia es una multiplicación repetida: 2^3 = 2 · 2 · 2.'),
+    ('MAT', 'RESTA-NEGATIVO', 'restar un número negativo', 'restar su valor absoluto',
+     'Calcula 5 - (-3) como 2.', 'Restar un negativo equivale a sumar su opuesto: 5 - (-3) = 5 + 3.'),
+    ('MAT', 'AREA-PERIMETRO', 'el área', 'el perímetro',
+     'Suma los lados cuando se pide la superficie.', 'El área mide la superficie (unidades cuadradas); el perímetro, el borde.'),
+    ('MAT', 'ORDEN-OPERACIONES', 'la prioridad de las operaciones', 'operar de izquierda a derecha',
+     'Calcula 2 + 3 · 4 como 20.', 'Multiplicaciones y divisiones van antes que sumas y restas, salvo paréntesis.'),
+    ('LYL', 'HECHO-OPINION', 'un hecho', 'una opinión',
+     'Toma valoraciones del autor como información comprobable.', 'Un hecho se puede verificar; una opinión expresa un juicio o valoración.'),
+    ('LYL', 'TEMA-IDEA-PRINCIPAL', 'el tema del texto', 'su idea principal',
+     'Responde con una palabra cuando se pide lo que el texto afirma.', 'El tema es de qué trata; la idea principal es lo que el texto dice sobre ese tema.'),
+    ('LYL', 'NARRADOR-AUTOR', 'el narrador', 'el autor',
+     'Atribuye al autor lo que dice la voz que cuenta la historia.', 'El narrador es una voz creada por el autor dentro del relato.'),
+    ('LYL', 'LITERAL-INFERENCIA', 'lo que se infiere del texto', 'lo que el texto dice explícitamente',
+     'Busca la respuesta copiada del texto en preguntas inferenciales.', 'Inferir es unir pistas del texto con lo que sabes para llegar a algo que no está escrito.')
+) AS seed(materia_codigo, codigo, concepto, confundido_con, descripcion, remediacion)
+    ON seed.materia_codigo = m.codigo
+ON CONFLICT (materia_id, codigo) DO NOTHING;
+
+-- Comments
+COMMENT ON TABLE misconceptions IS 'Managed misconception catalog per materia, referenced by code from multiple_choice distractors (validation_data.conceptos_erroneos)';
+COMMENT ON COLUMN misconceptions.concepto IS 'X in "you likely confuse X with Y"';
+COMMENT ON COLUMN misconceptions.confundido_con IS 'Y in "you likely confuse X with Y"';
+COMMENT ON TABLE misconception_observations IS 'Misconceptions behind wrong multiple_choice answers in practice and diagnostic sessions';
+COMMENT ON COLUMN misconception_observations.session_id IS 'practice_sessions.id or diagnostic_sessions.id, depending on origen';
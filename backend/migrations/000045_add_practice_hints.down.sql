@@ -0,0 +1,3 @@
+ALTER TABLE practice_answers DROP COLUMN IF EXISTS pistas_usadas;
+ALTER TABLE practice_sessions DROP COLUMN IF EXISTS pistas_usadas;
+DROP TABLE IF EXISTS question_hints;
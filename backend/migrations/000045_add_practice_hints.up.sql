@@ -0,0 +1,20 @@
+-- Scaffolded hints for practice questions. Authored hints live in questions.validation_data.pistas;
+-- questions without them get LLM-generated hints, cached here on first request.
+CREATE TABLE IF NOT EXISTS question_hints (
+    id SERIAL PRIMARY KEY,
+    question_id INTEGER NOT NULL REFERENCES questions(id) ON DELETE CASCADE,
+    orden INTEGER NOT NULL CHECK (orden >= 1),
+    texto TEXT NOT NULL,
+    prompt_version VARCHAR(20),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (question_id, orden)
+);
+
+-- Hints revealed for the pending question and for each answer
+ALTER TABLE practice_sessions ADD COLUMN pistas_usadas INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE practice_answers ADD COLUMN pistas_usadas INTEGER NOT NULL DEFAULT 0;
+
+-- Comments
+COMMENT ON TABLE question_hints IS 'LLM-generated hints of questions without authored validation_data.pistas, from most general to most specific';
+COMMENT ON COLUMN practice_sessions.pistas_usadas IS 'Hints revealed for pregunta_pendiente_id; reset when a new question is served';
+COMMENT ON COLUMN practice_answers.pistas_usadas IS 'Hints revealed before answering; reduce score, XP and mastery evidence';
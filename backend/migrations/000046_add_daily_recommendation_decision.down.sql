@@ -0,0 +1,4 @@
+-- Drop the daily decision day (decisions removed from duplicated reloads are not restored)
+DROP INDEX IF EXISTS idx_recommendation_impressions_daily_decision;
+
+ALTER TABLE recommendation_impressions DROP COLUMN IF EXISTS dia;
@@ -0,0 +1,20 @@
+-- One bandit decision per student and day: reloading the daily recommendation reuses it
+ALTER TABLE recommendation_impressions ADD COLUMN dia DATE;
+
+-- Earlier reloads logged a rewardable decision each; only the first of each day keeps its decision
+UPDATE recommendation_impressions i
+SET politica = NULL, probabilidad = NULL, brazos = NULL
+WHERE i.origen = 'daily' AND i.politica IS NOT NULL AND EXISTS (
+    SELECT 1 FROM recommendation_impressions f
+    WHERE f.user_id = i.user_id AND f.origen = 'daily' AND f.politica IS NOT NULL
+      AND f.created_at::date = i.created_at::date AND f.id < i.id
+);
+
+UPDATE recommendation_impressions SET dia = created_at::date
+WHERE origen = 'daily' AND politica IS NOT NULL;
+
+CREATE UNIQUE INDEX idx_recommendation_impressions_daily_decision ON recommendation_impressions(user_id, dia)
+    WHERE dia IS NOT NULL;
+
+-- Comments
+COMMENT ON COLUMN recommendation_impressions.dia IS 'Day of the daily bandit decision; the only impression of the day that is shown again and rewarded';
@@ -0,0 +1,93 @@
+// Package embeddings turns text into vectors for semantic search over the
+// curriculum reference documents.
+//
+// Two providers are available:
+//   - OpenAIProvider: the OpenAI embeddings API (requires OPENAI_API_KEY)
+//   - HashProvider: local feature hashing of words and word pairs, works offline
+//
+// Vectors from different providers are not comparable; callers store the
+// provider Name() next to every vector and only compare vectors with the same name.
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+)
+
+// Provider is implemented by every embedding backend
+type Provider interface {
+	// Name identifies the provider and model, e.g. "text-embedding-3-small"
+	Name() string
+	// Dimensions is the length of every returned vector
+	Dimensions() int
+	// Embed returns one L2-normalized vector per text, in the same order
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// NewFromEnv builds a provider from environment variables:
+//
+//	EMBEDDINGS_PROVIDER  auto (default), openai or local
+//	EMBEDDINGS_MODEL     OpenAI model (default "text-embedding-3-small")
+//	OPENAI_API_KEY       required by openai; auto only uses OpenAI when it is set
+//
+// auto uses OpenAI unless there is no API key or LLM_MODE=replay (offline).
+func NewFromEnv() (Provider, error) {
+	mode := os.Getenv("EMBEDDINGS_PROVIDER")
+	if mode == "" {
+		mode = "auto"
+	}
+
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	model := os.Getenv("EMBEDDINGS_MODEL")
+	switch mode {
+	case "local":
+		return NewHashProvider(DefaultHashDimensions), nil
+	case "openai":
+		if apiKey == "" {
+			return nil, errors.New("OPENAI_API_KEY no está configurada")
+		}
+		return NewOpenAIProvider(apiKey, model), nil
+	case "auto":
+		if apiKey == "" || os.Getenv("LLM_MODE") == "replay" {
+			return NewHashProvider(DefaultHashDimensions), nil
+		}
+		return NewOpenAIProvider(apiKey, model), nil
+	default:
+		return nil, fmt.Errorf("unknown EMBEDDINGS_PROVIDER %q", mode)
+	}
+}
+
+// Cosine returns the cosine similarity of two vectors, 0 if their lengths differ
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// normalize scales a vector to unit length in place
+func normalize(v []float32) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+}
@@ -0,0 +1,89 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+// DefaultHashDimensions is the vector length used by NewFromEnv for the local provider
+const DefaultHashDimensions = 512
+
+// HashProvider implements Provider without network access: every lowercase,
+// accent-free word and pair of consecutive words is hashed into a bucket of a
+// fixed-length vector (with a hashed sign, so collisions tend to cancel out).
+// It captures lexical overlap only, which is enough for tests, offline
+// development and small curricula.
+type HashProvider struct {
+	dims int
+}
+
+// NewHashProvider creates a local provider producing vectors of the given length
+func NewHashProvider(dims int) *HashProvider {
+	if dims <= 0 {
+		dims = DefaultHashDimensions
+	}
+	return &HashProvider{dims: dims}
+}
+
+// Name implements Provider
+func (p *HashProvider) Name() string {
+	return fmt.Sprintf("local-hash-%d", p.dims)
+}
+
+// Dimensions implements Provider
+func (p *HashProvider) Dimensions() int {
+	return p.dims
+}
+
+// Embed implements Provider
+func (p *HashProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		vector := make([]float32, p.dims)
+		words := tokenize(text)
+		for j, word := range words {
+			p.add(vector, word, 1)
+			if j > 0 {
+				p.add(vector, words[j-1]+" "+word, 0.5)
+			}
+		}
+		normalize(vector)
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+func (p *HashProvider) add(vector []float32, feature string, weight float32) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+	bucket := int(sum % uint64(p.dims))
+	if sum>>63 == 1 {
+		weight = -weight
+	}
+	vector[bucket] += weight
+}
+
+var accentReplacer = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n")
+
+// tokenize lowercases, strips accents and splits on anything that is not a letter or digit.
+// Words of one character are dropped.
+func tokenize(text string) []string {
+	plain := accentReplacer.Replace(strings.ToLower(text))
+	fields := strings.FieldsFunc(plain, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	words := fields[:0]
+	for _, field := range fields {
+		if len([]rune(field)) > 1 {
+			words = append(words, field)
+		}
+	}
+	return words
+}
@@ -0,0 +1,125 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/platanus-hack-25/lumera_app/pkg/llm"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// DefaultOpenAIModel is the embedding model used when none is configured
+const DefaultOpenAIModel = string(openai.SmallEmbedding3)
+
+// openAIBatchSize is the number of texts sent per request
+const openAIBatchSize = 64
+
+// OpenAIProvider implements Provider with the OpenAI embeddings API
+type OpenAIProvider struct {
+	client   *openai.Client
+	model    string
+	recorder llm.Recorder
+}
+
+// NewOpenAIProvider creates a provider authenticated with the given API key.
+// An empty model uses DefaultOpenAIModel.
+func NewOpenAIProvider(apiKey, model string) *OpenAIProvider {
+	if model == "" {
+		model = DefaultOpenAIModel
+	}
+	return &OpenAIProvider{client: openai.NewClient(apiKey), model: model}
+}
+
+// WithRecorder records every request in the llm_calls table (kind "embedding"),
+// tagged with the user and feature found in the context
+func (p *OpenAIProvider) WithRecorder(recorder llm.Recorder) *OpenAIProvider {
+	p.recorder = recorder
+	return p
+}
+
+// Name implements Provider
+func (p *OpenAIProvider) Name() string {
+	return p.model
+}
+
+// Dimensions implements Provider
+func (p *OpenAIProvider) Dimensions() int {
+	if p.model == string(openai.LargeEmbedding3) {
+		return 3072
+	}
+	return 1536
+}
+
+// Embed implements Provider
+func (p *OpenAIProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += openAIBatchSize {
+		end := start + openAIBatchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+
+		// OpenAI recommends replacing newlines with spaces
+		input := make([]string, end-start)
+		for i, text := range texts[start:end] {
+			input[i] = strings.ReplaceAll(text, "\n", " ")
+		}
+
+		begin := time.Now()
+		resp, err := p.client.CreateEmbeddings(ctx, openai.EmbeddingRequestStrings{
+			Input: input,
+			Model: openai.EmbeddingModel(p.model),
+		})
+		p.record(ctx, resp, begin, err)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Data) != len(input) {
+			return nil, fmt.Errorf("embeddings: expected %d vectors, got %d", len(input), len(resp.Data))
+		}
+
+		batch := make([][]float32, len(input))
+		for _, item := range resp.Data {
+			if item.Index < 0 || item.Index >= len(batch) {
+				return nil, fmt.Errorf("embeddings: unexpected index %d in response", item.Index)
+			}
+			normalize(item.Embedding)
+			batch[item.Index] = item.Embedding
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+func (p *OpenAIProvider) record(ctx context.Context, resp openai.EmbeddingResponse, start time.Time, err error) {
+	if p.recorder == nil {
+		return
+	}
+
+	tags := llm.TagsFromContext(ctx)
+	feature := tags.Feature
+	if feature == "" {
+		feature = llm.FeatureCurriculumEmbedding
+	}
+	record := llm.CallRecord{
+		UserID:       tags.UserID,
+		Feature:      feature,
+		Kind:         llm.CallKindEmbedding,
+		Model:        p.model,
+		PromptTokens: resp.Usage.PromptTokens,
+		TotalTokens:  resp.Usage.TotalTokens,
+		LatencyMs:    time.Since(start).Milliseconds(),
+		Success:      err == nil,
+	}
+	if err != nil {
+		record.ErrorMensaje = err.Error()
+	}
+	record.CostUSD = llm.EstimateChatCost(p.model, llm.Usage{PromptTokens: resp.Usage.PromptTokens})
+
+	if err := p.recorder.Record(ctx, record); err != nil {
+		log.Printf("⚠ Failed to record embedding call (%s/%s): %v", record.Feature, record.Model, err)
+	}
+}
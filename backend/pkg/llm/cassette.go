@@ -0,0 +1,183 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CassetteMode controls how a CassetteClient uses its recordings
+type CassetteMode string
+
+const (
+	// CassetteReplay only serves recorded responses; a miss is an error
+	CassetteReplay CassetteMode = "replay"
+	// CassetteRecord always calls the inner client and overwrites the recording
+	CassetteRecord CassetteMode = "record"
+	// CassetteReplayOrRecord serves recordings and records missing ones
+	CassetteReplayOrRecord CassetteMode = "auto"
+)
+
+// ErrCassetteMiss is returned in replay mode when no recording matches a request
+var ErrCassetteMiss = errors.New("llm: no cassette recording for request")
+
+// cassetteEntry is the on-disk format of a single recording
+type cassetteEntry struct {
+	Kind          string         `json:"kind"` // chat, image
+	ChatRequest   *ChatRequest   `json:"chat_request,omitempty"`
+	ChatResponse  *ChatResponse  `json:"chat_response,omitempty"`
+	ImageRequest  *ImageRequest  `json:"image_request,omitempty"`
+	ImageResponse *ImageResponse `json:"image_response,omitempty"`
+}
+
+// CassetteClient records responses from an inner client and replays them,
+// keyed by a hash of the request (model, prompt and sampling parameters).
+// Each recording is stored as <dir>/<hash>.json.
+type CassetteClient struct {
+	dir   string
+	mode  CassetteMode
+	inner LLMClient
+	mu    sync.Mutex
+}
+
+// NewCassetteClient creates a cassette client. inner may be nil in replay mode.
+func NewCassetteClient(dir string, mode CassetteMode, inner LLMClient) (*CassetteClient, error) {
+	switch mode {
+	case CassetteReplay:
+	case CassetteRecord, CassetteReplayOrRecord:
+		if inner == nil {
+			return nil, fmt.Errorf("llm: cassette mode %q requires an inner client", mode)
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("llm: failed to create cassette directory: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("llm: unknown cassette mode %q", mode)
+	}
+
+	return &CassetteClient{dir: dir, mode: mode, inner: inner}, nil
+}
+
+// Chat implements LLMClient
+func (c *CassetteClient) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	key := RequestHash("chat", req)
+
+	if c.mode != CassetteRecord {
+		entry, err := c.load(key)
+		if err == nil && entry.ChatResponse != nil {
+			return entry.ChatResponse, nil
+		}
+		if c.mode == CassetteReplay {
+			return nil, fmt.Errorf("%w (chat %s)", ErrCassetteMiss, key)
+		}
+	}
+
+	resp, err := c.inner.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.save(key, cassetteEntry{Kind: "chat", ChatRequest: &req, ChatResponse: resp}); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ChatStream implements StreamingClient. Recordings are replayed in small deltas;
+// misses are streamed from the inner client and recorded as a regular chat entry.
+func (c *CassetteClient) ChatStream(ctx context.Context, req ChatRequest, onDelta StreamFunc) (*ChatResponse, error) {
+	key := RequestHash("chat", req)
+
+	if c.mode != CassetteRecord {
+		entry, err := c.load(key)
+		if err == nil && entry.ChatResponse != nil {
+			if err := emitChunks(ctx, entry.ChatResponse.Content, onDelta); err != nil {
+				return nil, err
+			}
+			return entry.ChatResponse, nil
+		}
+		if c.mode == CassetteReplay {
+			return nil, fmt.Errorf("%w (chat %s)", ErrCassetteMiss, key)
+		}
+	}
+
+	resp, err := ChatStream(ctx, c.inner, req, onDelta)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.save(key, cassetteEntry{Kind: "chat", ChatRequest: &req, ChatResponse: resp}); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Image implements LLMClient
+func (c *CassetteClient) Image(ctx context.Context, req ImageRequest) (*ImageResponse, error) {
+	key := RequestHash("image", req)
+
+	if c.mode != CassetteRecord {
+		entry, err := c.load(key)
+		if err == nil && entry.ImageResponse != nil {
+			return entry.ImageResponse, nil
+		}
+		if c.mode == CassetteReplay {
+			return nil, fmt.Errorf("%w (image %s)", ErrCassetteMiss, key)
+		}
+	}
+
+	resp, err := c.inner.Image(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.save(key, cassetteEntry{Kind: "image", ImageRequest: &req, ImageResponse: resp}); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *CassetteClient) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+func (c *CassetteClient) load(key string) (*cassetteEntry, error) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, err
+	}
+
+	var entry cassetteEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("llm: corrupt cassette %s: %w", key, err)
+	}
+	return &entry, nil
+}
+
+func (c *CassetteClient) save(key string, entry cassetteEntry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := os.WriteFile(c.path(key), data, 0644); err != nil {
+		return fmt.Errorf("llm: failed to write cassette %s: %w", key, err)
+	}
+	return nil
+}
+
+// RequestHash returns a stable hash identifying a request. Two requests with
+// the same kind, model, messages and sampling parameters share the same hash.
+func RequestHash(kind string, req interface{}) string {
+	data, _ := json.Marshal(req)
+	sum := sha256.Sum256(append([]byte(kind+":"), data...))
+	return hex.EncodeToString(sum[:])
+}
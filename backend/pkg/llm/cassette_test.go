@@ -0,0 +1,90 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCassetteRecordThenReplay(t *testing.T) {
+	dir := t.TempDir()
+	req := ChatRequest{Model: "gpt-4o-mini", Messages: []Message{{Role: RoleUser, Content: "Explica las potencias"}}, Temperature: 0.7}
+
+	fake := NewFakeClient("Una potencia es una multiplicación repetida: aⁿ.")
+	recorder, err := NewCassetteClient(dir, CassetteRecord, fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := recorder.Chat(context.Background(), req)
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+
+	player, err := NewCassetteClient(dir, CassetteReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := player.Chat(context.Background(), req)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if replayed.Content != recorded.Content || replayed.Usage != recorded.Usage {
+		t.Errorf("replayed %+v, recorded %+v", replayed, recorded)
+	}
+
+	var deltas []string
+	streamed, err := ChatStream(context.Background(), player, req, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("replay stream: %v", err)
+	}
+	if strings.Join(deltas, "") != recorded.Content || streamed.Content != recorded.Content {
+		t.Errorf("streamed %q in %d deltas", strings.Join(deltas, ""), len(deltas))
+	}
+	if len(fake.Requests) != 1 {
+		t.Errorf("inner client called %d times, want 1", len(fake.Requests))
+	}
+}
+
+func TestCassetteReplayMiss(t *testing.T) {
+	player, err := NewCassetteClient(t.TempDir(), CassetteReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = player.Chat(context.Background(), ChatRequest{Model: "gpt-4o-mini"})
+	if !errors.Is(err, ErrCassetteMiss) {
+		t.Errorf("err = %v, want ErrCassetteMiss", err)
+	}
+}
+
+func TestCassetteReplayOrRecordOnlyCallsInnerOnMiss(t *testing.T) {
+	fake := NewFakeClient("primera", "segunda")
+	client, err := NewCassetteClient(t.TempDir(), CassetteReplayOrRecord, fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := ChatRequest{Model: "gpt-4o-mini", Messages: []Message{{Role: RoleUser, Content: "hola"}}}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Chat(context.Background(), req)
+		if err != nil || resp.Content != "primera" {
+			t.Fatalf("call %d: %v %v", i, resp, err)
+		}
+	}
+	if len(fake.Requests) != 1 {
+		t.Errorf("inner client called %d times, want 1", len(fake.Requests))
+	}
+}
+
+func TestRequestHashDependsOnPrompt(t *testing.T) {
+	a := ChatRequest{Model: "m", Messages: []Message{{Role: RoleUser, Content: "a"}}}
+	b := ChatRequest{Model: "m", Messages: []Message{{Role: RoleUser, Content: "b"}}}
+	if RequestHash("chat", a) != RequestHash("chat", a) {
+		t.Error("hash is not stable")
+	}
+	if RequestHash("chat", a) == RequestHash("chat", b) || RequestHash("chat", a) == RequestHash("image", a) {
+		t.Error("different requests share a hash")
+	}
+}
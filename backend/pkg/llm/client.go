@@ -0,0 +1,73 @@
+// Package llm provides a small, provider-agnostic client used by the backend
+// and the generator tools to talk to language and image models.
+//
+// Three implementations are available:
+//   - OpenAIClient: calls the OpenAI API (requires OPENAI_API_KEY)
+//   - CassetteClient: records responses to disk and replays them by request hash
+//   - FakeClient: returns scripted responses, for tests and local development
+package llm
+
+import (
+	"context"
+	"errors"
+)
+
+// Message roles
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message is a single chat message
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatRequest is a provider-agnostic chat completion request
+type ChatRequest struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	Temperature float32   `json:"temperature"`
+	MaxTokens   int       `json:"max_tokens,omitempty"`
+}
+
+// Usage reports the tokens consumed by a request
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ChatResponse is the result of a chat completion
+type ChatResponse struct {
+	Content string `json:"content"`
+	Model   string `json:"model"`
+	Usage   Usage  `json:"usage"`
+}
+
+// ImageRequest is a provider-agnostic image generation request
+type ImageRequest struct {
+	Model   string `json:"model"`
+	Prompt  string `json:"prompt"`
+	Size    string `json:"size,omitempty"`
+	Quality string `json:"quality,omitempty"`
+}
+
+// ImageResponse contains the raw bytes of a generated image
+type ImageResponse struct {
+	Data          []byte `json:"data"`
+	RevisedPrompt string `json:"revised_prompt,omitempty"`
+}
+
+// LLMClient is implemented by every model backend
+type LLMClient interface {
+	// Chat sends a chat completion request and returns the first choice
+	Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error)
+	// Image generates a single image from a prompt
+	Image(ctx context.Context, req ImageRequest) (*ImageResponse, error)
+}
+
+// ErrEmptyResponse is returned when the provider answers without any choice
+var ErrEmptyResponse = errors.New("llm: empty response from provider")
@@ -0,0 +1,42 @@
+package llm
+
+import (
+	"fmt"
+	"os"
+)
+
+// NewFromEnv builds a client from environment variables:
+//
+//	LLM_MODE          openai (default), record, replay or auto
+//	LLM_CASSETTE_DIR  cassette directory for record/replay/auto (default "testdata/cassettes")
+//	OPENAI_API_KEY    required by every mode except replay
+func NewFromEnv() (LLMClient, error) {
+	mode := os.Getenv("LLM_MODE")
+	if mode == "" {
+		mode = "openai"
+	}
+
+	dir := os.Getenv("LLM_CASSETTE_DIR")
+	if dir == "" {
+		dir = "testdata/cassettes"
+	}
+
+	if mode == string(CassetteReplay) {
+		return NewCassetteClient(dir, CassetteReplay, nil)
+	}
+
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("OPENAI_API_KEY no está configurada")
+	}
+	openaiClient := NewOpenAIClient(apiKey)
+
+	switch mode {
+	case "openai":
+		return openaiClient, nil
+	case string(CassetteRecord), string(CassetteReplayOrRecord):
+		return NewCassetteClient(dir, CassetteMode(mode), openaiClient)
+	default:
+		return nil, fmt.Errorf("unknown LLM_MODE %q", mode)
+	}
+}
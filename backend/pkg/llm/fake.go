@@ -0,0 +1,152 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// ErrScriptExhausted is returned by FakeClient when no scripted reply is left
+var ErrScriptExhausted = errors.New("llm: fake client has no scripted reply left")
+
+// FakeReply is a scripted chat reply
+type FakeReply struct {
+	Content string
+	Err     error
+}
+
+type fakeRule struct {
+	contains string
+	reply    FakeReply
+}
+
+// FakeClient returns scripted responses without any network access.
+//
+// Rules registered with When are matched first (by substring of the last
+// message) and can be reused; otherwise replies are consumed in FIFO order.
+// Every request is recorded so tests can assert on the prompts sent.
+type FakeClient struct {
+	mu       sync.Mutex
+	rules    []fakeRule
+	replies  []FakeReply
+	images   [][]byte
+	Requests []ChatRequest
+	Images   []ImageRequest
+}
+
+// NewFakeClient creates a fake client that replies with the given contents in order
+func NewFakeClient(contents ...string) *FakeClient {
+	f := &FakeClient{}
+	for _, c := range contents {
+		f.replies = append(f.replies, FakeReply{Content: c})
+	}
+	return f
+}
+
+// Reply queues a chat reply
+func (f *FakeClient) Reply(content string) *FakeClient {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.replies = append(f.replies, FakeReply{Content: content})
+	return f
+}
+
+// Fail queues a chat error
+func (f *FakeClient) Fail(err error) *FakeClient {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.replies = append(f.replies, FakeReply{Err: err})
+	return f
+}
+
+// When registers a reusable reply for prompts whose last message contains substr
+func (f *FakeClient) When(substr string, content string) *FakeClient {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append(f.rules, fakeRule{contains: substr, reply: FakeReply{Content: content}})
+	return f
+}
+
+// ReplyImage queues an image reply
+func (f *FakeClient) ReplyImage(data []byte) *FakeClient {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.images = append(f.images, data)
+	return f
+}
+
+// Chat implements LLMClient
+func (f *FakeClient) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Requests = append(f.Requests, req)
+
+	prompt := ""
+	if len(req.Messages) > 0 {
+		prompt = req.Messages[len(req.Messages)-1].Content
+	}
+
+	reply, ok := f.match(prompt)
+	if !ok {
+		if len(f.replies) == 0 {
+			return nil, ErrScriptExhausted
+		}
+		reply = f.replies[0]
+		f.replies = f.replies[1:]
+	}
+
+	if reply.Err != nil {
+		return nil, reply.Err
+	}
+
+	return &ChatResponse{
+		Content: reply.Content,
+		Model:   req.Model,
+		Usage: Usage{
+			PromptTokens:     approxTokens(prompt),
+			CompletionTokens: approxTokens(reply.Content),
+			TotalTokens:      approxTokens(prompt) + approxTokens(reply.Content),
+		},
+	}, nil
+}
+
+// ChatStream implements StreamingClient by delivering the scripted reply in small deltas
+func (f *FakeClient) ChatStream(ctx context.Context, req ChatRequest, onDelta StreamFunc) (*ChatResponse, error) {
+	resp, err := f.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := emitChunks(ctx, resp.Content, onDelta); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Image implements LLMClient
+func (f *FakeClient) Image(ctx context.Context, req ImageRequest) (*ImageResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Images = append(f.Images, req)
+
+	if len(f.images) == 0 {
+		return nil, ErrScriptExhausted
+	}
+	data := f.images[0]
+	f.images = f.images[1:]
+	return &ImageResponse{Data: data}, nil
+}
+
+func (f *FakeClient) match(prompt string) (FakeReply, bool) {
+	for _, rule := range f.rules {
+		if strings.Contains(prompt, rule.contains) {
+			return rule.reply, true
+		}
+	}
+	return FakeReply{}, false
+}
+
+// approxTokens estimates token count (~4 characters per token)
+func approxTokens(s string) int {
+	return (len(s) + 3) / 4
+}
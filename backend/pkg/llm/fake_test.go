@@ -0,0 +1,43 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func chatPrompt(t *testing.T, client LLMClient, prompt string) (string, error) {
+	t.Helper()
+	resp, err := client.Chat(context.Background(), ChatRequest{Messages: []Message{{Role: RoleUser, Content: prompt}}})
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
+
+func TestFakeClientScript(t *testing.T) {
+	boom := errors.New("boom")
+	fake := NewFakeClient("uno").Fail(boom).Reply("dos").When("plan", "estructura")
+
+	steps := []struct {
+		prompt string
+		want   string
+		err    error
+	}{
+		{"genera el plan", "estructura", nil}, // rules win and are not consumed
+		{"primera", "uno", nil},
+		{"segunda", "", boom},
+		{"otra vez el plan", "estructura", nil},
+		{"tercera", "dos", nil},
+		{"cuarta", "", ErrScriptExhausted},
+	}
+	for _, step := range steps {
+		got, err := chatPrompt(t, fake, step.prompt)
+		if got != step.want || !errors.Is(err, step.err) {
+			t.Errorf("%q: got %q, %v; want %q, %v", step.prompt, got, err, step.want, step.err)
+		}
+	}
+	if len(fake.Requests) != len(steps) {
+		t.Errorf("recorded %d requests, want %d", len(fake.Requests), len(steps))
+	}
+}
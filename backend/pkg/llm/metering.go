@@ -0,0 +1,209 @@
+package llm
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Features used to tag LLM calls
+const (
+	FeatureLearningPlanStructure  = "learning_plan_structure"
+	FeatureComponentContent       = "component_content"
+	FeatureContentPersonalization = "content_personalization"
+	FeatureQuestionGeneration     = "question_generation"
+	FeatureOADataLoader           = "oa_data_loader"
+	FeatureAvatarImage            = "avatar_image"
+	FeatureCurriculumEmbedding    = "curriculum_embedding"
+	FeatureQuestionHints          = "question_hints"
+)
+
+// CallTags identify who triggered a call and for which feature
+type CallTags struct {
+	UserID  *uint
+	Feature string
+}
+
+type contextKey int
+
+const (
+	tagsKey contextKey = iota
+	attemptKey
+)
+
+// WithTags attaches call tags to the context
+func WithTags(ctx context.Context, tags CallTags) context.Context {
+	return context.WithValue(ctx, tagsKey, tags)
+}
+
+// WithUser tags calls with the user that triggered them, keeping the feature
+func WithUser(ctx context.Context, userID uint) context.Context {
+	tags := TagsFromContext(ctx)
+	tags.UserID = &userID
+	return WithTags(ctx, tags)
+}
+
+// WithFeature tags calls with a feature, keeping the user
+func WithFeature(ctx context.Context, feature string) context.Context {
+	tags := TagsFromContext(ctx)
+	tags.Feature = feature
+	return WithTags(ctx, tags)
+}
+
+// WithAttempt records the 1-based attempt number of a call inside a retry loop
+func WithAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey, attempt)
+}
+
+// TagsFromContext returns the call tags stored in the context, if any
+func TagsFromContext(ctx context.Context) CallTags {
+	tags, _ := ctx.Value(tagsKey).(CallTags)
+	return tags
+}
+
+func retryCountFromContext(ctx context.Context) int {
+	if attempt, ok := ctx.Value(attemptKey).(int); ok && attempt > 1 {
+		return attempt - 1
+	}
+	return 0
+}
+
+// Call kinds
+const (
+	CallKindChat      = "chat"
+	CallKindImage     = "image"
+	CallKindEmbedding = "embedding"
+)
+
+// CallRecord is a row of the llm_calls table
+type CallRecord struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	UserID           *uint     `json:"user_id,omitempty"`
+	Feature          string    `json:"feature" gorm:"size:50;not null"`
+	Kind             string    `json:"kind" gorm:"size:10;not null"`
+	Model            string    `json:"model" gorm:"size:100;not null"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	LatencyMs        int64     `json:"latency_ms"`
+	RetryCount       int       `json:"retry_count"`
+	CostUSD          float64   `json:"cost_usd" gorm:"column:cost_usd;type:decimal(12,6)"`
+	Success          bool      `json:"success"`
+	ErrorMensaje     string    `json:"error_mensaje,omitempty" gorm:"type:text"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// TableName overrides the default table name
+func (CallRecord) TableName() string {
+	return "llm_calls"
+}
+
+// Recorder persists call records
+type Recorder interface {
+	Record(ctx context.Context, record CallRecord) error
+}
+
+// GormRecorder writes call records to the llm_calls table
+type GormRecorder struct {
+	db *gorm.DB
+}
+
+// NewGormRecorder creates a recorder backed by the given database
+func NewGormRecorder(db *gorm.DB) *GormRecorder {
+	return &GormRecorder{db: db}
+}
+
+// Record inserts a call record
+func (r *GormRecorder) Record(ctx context.Context, record CallRecord) error {
+	// The call context may already be cancelled or timed out; the insert should not be
+	return r.db.WithContext(context.Background()).Create(&record).Error
+}
+
+// MeteredClient wraps a client and records model, tokens, latency, retries
+// and estimated cost of every call
+type MeteredClient struct {
+	inner    LLMClient
+	recorder Recorder
+}
+
+// NewMeteredClient wraps inner so every call is recorded
+func NewMeteredClient(inner LLMClient, recorder Recorder) *MeteredClient {
+	return &MeteredClient{inner: inner, recorder: recorder}
+}
+
+// Chat implements LLMClient
+func (c *MeteredClient) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	start := time.Now()
+	resp, err := c.inner.Chat(ctx, req)
+	c.recordChat(ctx, req, resp, start, err)
+	return resp, err
+}
+
+// ChatStream implements StreamingClient; the call is recorded once the stream ends
+func (c *MeteredClient) ChatStream(ctx context.Context, req ChatRequest, onDelta StreamFunc) (*ChatResponse, error) {
+	start := time.Now()
+	resp, err := ChatStream(ctx, c.inner, req, onDelta)
+	c.recordChat(ctx, req, resp, start, err)
+	return resp, err
+}
+
+// Image implements LLMClient
+func (c *MeteredClient) Image(ctx context.Context, req ImageRequest) (*ImageResponse, error) {
+	start := time.Now()
+	resp, err := c.inner.Image(ctx, req)
+
+	record := c.newRecord(ctx, CallKindImage, req.Model, start, err)
+	if err == nil {
+		record.CostUSD = EstimateImageCost(req)
+	}
+	c.record(ctx, record)
+
+	return resp, err
+}
+
+func (c *MeteredClient) recordChat(ctx context.Context, req ChatRequest, resp *ChatResponse, start time.Time, err error) {
+	record := c.newRecord(ctx, CallKindChat, req.Model, start, err)
+	if resp != nil {
+		if resp.Model != "" {
+			record.Model = resp.Model
+		}
+		record.PromptTokens = resp.Usage.PromptTokens
+		record.CompletionTokens = resp.Usage.CompletionTokens
+		record.TotalTokens = resp.Usage.TotalTokens
+		record.CostUSD = EstimateChatCost(record.Model, resp.Usage)
+	}
+	c.record(ctx, record)
+}
+
+func (c *MeteredClient) newRecord(ctx context.Context, kind, model string, start time.Time, err error) CallRecord {
+	tags := TagsFromContext(ctx)
+	feature := tags.Feature
+	if feature == "" {
+		feature = "unknown"
+	}
+
+	record := CallRecord{
+		UserID:     tags.UserID,
+		Feature:    feature,
+		Kind:       kind,
+		Model:      model,
+		LatencyMs:  time.Since(start).Milliseconds(),
+		RetryCount: retryCountFromContext(ctx),
+		Success:    err == nil,
+	}
+	if err != nil {
+		record.ErrorMensaje = err.Error()
+	}
+	return record
+}
+
+func (c *MeteredClient) record(ctx context.Context, record CallRecord) {
+	if c.recorder == nil {
+		return
+	}
+	if err := c.recorder.Record(ctx, record); err != nil {
+		log.Printf("⚠ Failed to record LLM call (%s/%s): %v", record.Feature, record.Model, err)
+	}
+}
@@ -0,0 +1,144 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// OpenAIClient implements LLMClient using the OpenAI API
+type OpenAIClient struct {
+	client *openai.Client
+}
+
+// NewOpenAIClient creates a client authenticated with the given API key
+func NewOpenAIClient(apiKey string) *OpenAIClient {
+	return &OpenAIClient{client: openai.NewClient(apiKey)}
+}
+
+// Chat implements LLMClient
+func (c *OpenAIClient) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	messages := make([]openai.ChatCompletionMessage, len(req.Messages))
+	for i, m := range req.Messages {
+		messages[i] = openai.ChatCompletionMessage{Role: m.Role, Content: m.Content}
+	}
+
+	resp, err := c.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model:       req.Model,
+		Messages:    messages,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Choices) == 0 {
+		return nil, ErrEmptyResponse
+	}
+
+	return &ChatResponse{
+		Content: resp.Choices[0].Message.Content,
+		Model:   resp.Model,
+		Usage: Usage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		},
+	}, nil
+}
+
+// ChatStream implements StreamingClient using the chat completions streaming API
+func (c *OpenAIClient) ChatStream(ctx context.Context, req ChatRequest, onDelta StreamFunc) (*ChatResponse, error) {
+	messages := make([]openai.ChatCompletionMessage, len(req.Messages))
+	for i, m := range req.Messages {
+		messages[i] = openai.ChatCompletionMessage{Role: m.Role, Content: m.Content}
+	}
+
+	stream, err := c.client.CreateChatCompletionStream(ctx, openai.ChatCompletionRequest{
+		Model:         req.Model,
+		Messages:      messages,
+		Temperature:   req.Temperature,
+		MaxTokens:     req.MaxTokens,
+		Stream:        true,
+		StreamOptions: &openai.StreamOptions{IncludeUsage: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	result := &ChatResponse{Model: req.Model}
+	var content strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		// The last chunk carries the usage of the whole request and no choices
+		if chunk.Usage != nil {
+			result.Usage = Usage{
+				PromptTokens:     chunk.Usage.PromptTokens,
+				CompletionTokens: chunk.Usage.CompletionTokens,
+				TotalTokens:      chunk.Usage.TotalTokens,
+			}
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+
+		delta := chunk.Choices[0].Delta.Content
+		content.WriteString(delta)
+		if err := onDelta(delta); err != nil {
+			return nil, err
+		}
+	}
+
+	if content.Len() == 0 {
+		return nil, ErrEmptyResponse
+	}
+	result.Content = content.String()
+	return result, nil
+}
+
+// Image implements LLMClient. Images are requested as base64 so the bytes can
+// be stored directly (and recorded by CassetteClient) without a second download.
+func (c *OpenAIClient) Image(ctx context.Context, req ImageRequest) (*ImageResponse, error) {
+	resp, err := c.client.CreateImage(ctx, openai.ImageRequest{
+		Prompt:         req.Prompt,
+		Model:          req.Model,
+		N:              1,
+		Size:           req.Size,
+		Quality:        req.Quality,
+		ResponseFormat: openai.CreateImageResponseFormatB64JSON,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Data) == 0 {
+		return nil, ErrEmptyResponse
+	}
+
+	data, err := base64.StdEncoding.DecodeString(resp.Data[0].B64JSON)
+	if err != nil {
+		return nil, fmt.Errorf("llm: failed to decode image: %w", err)
+	}
+
+	return &ImageResponse{
+		Data:          data,
+		RevisedPrompt: resp.Data[0].RevisedPrompt,
+	}, nil
+}
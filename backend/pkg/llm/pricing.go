@@ -0,0 +1,86 @@
+package llm
+
+import (
+	"strings"
+	"sync"
+)
+
+// Price is the list price of a model in USD
+type Price struct {
+	PromptPerMillion     float64 // per 1M prompt tokens
+	CompletionPerMillion float64 // per 1M completion tokens
+	PerImage             float64 // per standard-quality image
+}
+
+var (
+	pricesMu sync.RWMutex
+	prices   = map[string]Price{
+		"gpt-4o-mini":  {PromptPerMillion: 0.15, CompletionPerMillion: 0.60},
+		"gpt-4o":       {PromptPerMillion: 2.50, CompletionPerMillion: 10.00},
+		"gpt-4.1-nano": {PromptPerMillion: 0.10, CompletionPerMillion: 0.40},
+		"gpt-4.1-mini": {PromptPerMillion: 0.40, CompletionPerMillion: 1.60},
+		"gpt-4.1":      {PromptPerMillion: 2.00, CompletionPerMillion: 8.00},
+		"dall-e-3":     {PerImage: 0.04},
+		"dall-e-2":     {PerImage: 0.02},
+
+		"text-embedding-3-small": {PromptPerMillion: 0.02},
+		"text-embedding-3-large": {PromptPerMillion: 0.13},
+	}
+)
+
+// SetPrice registers or overrides the price of a model
+func SetPrice(model string, price Price) {
+	pricesMu.Lock()
+	defer pricesMu.Unlock()
+	prices[model] = price
+}
+
+// lookupPrice finds the price of a model, matching dated snapshots
+// ("gpt-4o-mini-2024-07-18") by their longest known prefix
+func lookupPrice(model string) (Price, bool) {
+	pricesMu.RLock()
+	defer pricesMu.RUnlock()
+
+	if price, ok := prices[model]; ok {
+		return price, true
+	}
+
+	best := ""
+	for name := range prices {
+		if strings.HasPrefix(model, name+"-") && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return prices[best], true
+}
+
+// EstimateChatCost returns the estimated cost in USD of a chat completion.
+// Unknown models cost 0.
+func EstimateChatCost(model string, usage Usage) float64 {
+	price, ok := lookupPrice(model)
+	if !ok {
+		return 0
+	}
+	return float64(usage.PromptTokens)*price.PromptPerMillion/1e6 +
+		float64(usage.CompletionTokens)*price.CompletionPerMillion/1e6
+}
+
+// EstimateImageCost returns the estimated cost in USD of one generated image.
+// HD and non-square images cost twice the standard price.
+func EstimateImageCost(req ImageRequest) float64 {
+	price, ok := lookupPrice(req.Model)
+	if !ok {
+		return 0
+	}
+	cost := price.PerImage
+	if req.Quality == "hd" {
+		cost *= 2
+	}
+	if req.Size != "" && req.Size != "1024x1024" && req.Size != "512x512" && req.Size != "256x256" {
+		cost *= 2
+	}
+	return cost
+}
@@ -0,0 +1,61 @@
+package llm
+
+import (
+	"context"
+	"unicode/utf8"
+)
+
+// StreamFunc receives each content delta of a streamed chat completion.
+// Returning an error aborts the stream.
+type StreamFunc func(delta string) error
+
+// StreamingClient is implemented by clients that can stream chat completions
+type StreamingClient interface {
+	LLMClient
+	// ChatStream sends a chat request, calls onDelta for every content delta and
+	// returns the full response (content and usage) once the stream ends
+	ChatStream(ctx context.Context, req ChatRequest, onDelta StreamFunc) (*ChatResponse, error)
+}
+
+// ChatStream streams a chat completion when the client supports it. Otherwise it
+// falls back to Chat and delivers the whole content as a single delta.
+func ChatStream(ctx context.Context, client LLMClient, req ChatRequest, onDelta StreamFunc) (*ChatResponse, error) {
+	if streaming, ok := client.(StreamingClient); ok {
+		return streaming.ChatStream(ctx, req, onDelta)
+	}
+
+	resp, err := client.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := onDelta(resp.Content); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// replayChunkSize is the delta size used when replaying a recorded or scripted response
+const replayChunkSize = 24
+
+// emitChunks delivers content in small deltas, emulating a provider stream.
+// Deltas never split a multi-byte rune.
+func emitChunks(ctx context.Context, content string, onDelta StreamFunc) error {
+	for start := 0; start < len(content); {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := start + replayChunkSize
+		if end >= len(content) {
+			end = len(content)
+		} else {
+			for end > start+1 && !utf8.RuneStart(content[end]) {
+				end--
+			}
+		}
+		if err := onDelta(content[start:end]); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestEmitChunksKeepsRunesWhole(t *testing.T) {
+	tests := []string{
+		"",
+		"corto",
+		strings.Repeat("a", replayChunkSize),
+		"¿Qué pasa con los exponentes? Se suman: aⁿ · aᵐ = aⁿ⁺ᵐ. Años, niños, canción…",
+		strings.Repeat("ñ", replayChunkSize),
+		strings.Repeat("a", replayChunkSize-1) + "é" + "resto",
+		"emoji 😀 en el borde " + strings.Repeat("😀", 10),
+	}
+	for _, content := range tests {
+		var deltas []string
+		err := emitChunks(context.Background(), content, func(delta string) error {
+			deltas = append(deltas, delta)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("emitChunks(%q): %v", content, err)
+		}
+		if got := strings.Join(deltas, ""); got != content {
+			t.Errorf("deltas of %q join to %q", content, got)
+		}
+		for _, delta := range deltas {
+			if delta == "" || len(delta) > replayChunkSize || !utf8.ValidString(delta) {
+				t.Errorf("invalid delta %q of %q", delta, content)
+			}
+		}
+	}
+}
+
+func TestEmitChunksStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := emitChunks(ctx, strings.Repeat("x", replayChunkSize*3), func(string) error {
+		calls++
+		cancel()
+		return nil
+	})
+	if err != context.Canceled || calls != 1 {
+		t.Errorf("err = %v after %d deltas, want context.Canceled after 1", err, calls)
+	}
+}
@@ -0,0 +1,113 @@
+package moderation
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Rule flags texts matching Pattern under Category
+type Rule struct {
+	Category string
+	Pattern  *regexp.Regexp
+}
+
+// accentReplacer removes Spanish accents so rules can be written without them
+var accentReplacer = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n")
+
+// defaultRules are written in lowercase without accents, matching the normalized text
+var defaultRules = []struct {
+	category string
+	pattern  string
+}{
+	{"self-harm", `\b(suicidar(me|te|se)|quitar(me|te|se) la vida|matar(me|te|se)|cortar(me|te|se) las venas|no quiero vivir|autolesion(es|arme)?)\b`},
+	{"sexual", `\b(porno(grafia|grafico)?|sexo explicito|desnud(o|a|os|as) (fotos?|videos?)|nudes?|pack de fotos)\b`},
+	{"violence", `\b(como (fabricar|hacer|armar) (una |un )?(bomba|explosivo|arma)|tiroteo escolar)\b`},
+	{"drugs", `\b(comprar|vender|conseguir) (drogas?|cocaina|marihuana|pasta base|tusi|lsd|extasis)\b`},
+	{"hate", `\b(maricon(es)?|negro de mierda|indio de mierda|sudaca|retrasad(o|a)s? mental(es)?)\b`},
+	{"harassment", `\b(eres (un|una) (idiota|imbecil|estupid(o|a)|weon|huevon)|te voy a (pegar|matar))\b`},
+	{"prompt-injection", `\b(ignora|olvida|ignore) (todas )?(las|tus|the|all)? ?(instrucciones|reglas|previous instructions)\b`},
+}
+
+// DefaultRules returns the built-in Spanish blocklist
+func DefaultRules() []Rule {
+	rules := make([]Rule, len(defaultRules))
+	for i, r := range defaultRules {
+		rules[i] = Rule{Category: r.category, Pattern: regexp.MustCompile(r.pattern)}
+	}
+	return rules
+}
+
+// LoadRules reads extra rules from a file with one "categoria: regex" per line.
+// Empty lines and lines starting with # are ignored; texts are matched in lowercase without accents.
+func LoadRules(path string) ([]Rule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("moderation: failed to open blocklist: %w", err)
+	}
+	defer file.Close()
+
+	var rules []Rule
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		category, pattern, ok := strings.Cut(text, ":")
+		if !ok {
+			return nil, fmt.Errorf("moderation: %s:%d: expected \"categoria: regex\"", path, line)
+		}
+		re, err := regexp.Compile(strings.TrimSpace(pattern))
+		if err != nil {
+			return nil, fmt.Errorf("moderation: %s:%d: %w", path, line, err)
+		}
+		rules = append(rules, Rule{Category: strings.TrimSpace(category), Pattern: re})
+	}
+	return rules, scanner.Err()
+}
+
+// BlocklistClassifier implements Classifier with local regular expressions
+type BlocklistClassifier struct {
+	rules []Rule
+}
+
+// NewBlocklistClassifier creates a classifier with the given rules
+func NewBlocklistClassifier(rules []Rule) *BlocklistClassifier {
+	return &BlocklistClassifier{rules: rules}
+}
+
+// Name implements Classifier
+func (c *BlocklistClassifier) Name() string {
+	return "blocklist"
+}
+
+// Classify implements Classifier
+func (c *BlocklistClassifier) Classify(ctx context.Context, text string) (*Result, error) {
+	normalized := accentReplacer.Replace(strings.ToLower(text))
+
+	result := &Result{Classifier: c.Name()}
+	seen := make(map[string]bool)
+	var matches []string
+	for _, rule := range c.rules {
+		match := rule.Pattern.FindString(normalized)
+		if match == "" {
+			continue
+		}
+		result.Flagged = true
+		if !seen[rule.Category] {
+			seen[rule.Category] = true
+			result.Categories = append(result.Categories, rule.Category)
+		}
+		matches = append(matches, fmt.Sprintf("%q", strings.TrimSpace(match)))
+	}
+	if result.Flagged {
+		result.Reason = fmt.Sprintf("blocklist (%s): %s", strings.Join(result.Categories, ", "), strings.Join(matches, ", "))
+	}
+	return result, nil
+}
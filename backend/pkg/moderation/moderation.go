@@ -0,0 +1,151 @@
+// Package moderation checks text before it reaches students (generated content,
+// questions) and text written by students before it is stored or sent to a model.
+//
+// Two classifiers are available and can be combined with NewChain:
+//   - OpenAIClassifier: the OpenAI moderation API (requires OPENAI_API_KEY)
+//   - BlocklistClassifier: local regular expressions, works offline
+package moderation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Result is the verdict of a classifier for a text
+type Result struct {
+	Flagged    bool     `json:"flagged"`
+	Categories []string `json:"categories,omitempty"`
+	Reason     string   `json:"reason,omitempty"`
+	Classifier string   `json:"classifier"`
+}
+
+// Classifier is implemented by every moderation backend
+type Classifier interface {
+	// Name identifies the classifier in results and logs
+	Name() string
+	// Classify checks a text and reports whether it must be quarantined
+	Classify(ctx context.Context, text string) (*Result, error)
+}
+
+// Chain runs several classifiers and flags the text if any of them does.
+// A failing classifier is skipped as long as at least one other answered.
+type Chain struct {
+	classifiers []Classifier
+}
+
+// NewChain combines classifiers, usually the local blocklist first
+func NewChain(classifiers ...Classifier) *Chain {
+	return &Chain{classifiers: classifiers}
+}
+
+// Name implements Classifier
+func (c *Chain) Name() string {
+	names := make([]string, len(c.classifiers))
+	for i, classifier := range c.classifiers {
+		names[i] = classifier.Name()
+	}
+	return strings.Join(names, "+")
+}
+
+// Classify implements Classifier
+func (c *Chain) Classify(ctx context.Context, text string) (*Result, error) {
+	var lastErr error
+	answered := false
+	for _, classifier := range c.classifiers {
+		result, err := classifier.Classify(ctx, text)
+		if err != nil {
+			log.Printf("⚠ moderation: %s failed: %v", classifier.Name(), err)
+			lastErr = err
+			continue
+		}
+		answered = true
+		if result.Flagged {
+			return result, nil
+		}
+	}
+	if !answered && lastErr != nil {
+		return nil, lastErr
+	}
+	return &Result{Classifier: c.Name()}, nil
+}
+
+// NewFromEnv builds a classifier from environment variables:
+//
+//	MODERATION_MODE            auto (default), local, openai or off
+//	MODERATION_BLOCKLIST_FILE  extra blocklist rules ("categoria: regex" per line)
+//	OPENAI_API_KEY             required by openai; auto only uses OpenAI when it is set
+//
+// auto combines the blocklist with OpenAI, except with LLM_MODE=replay (offline).
+// off returns a nil classifier.
+func NewFromEnv() (Classifier, error) {
+	mode := os.Getenv("MODERATION_MODE")
+	if mode == "" {
+		mode = "auto"
+	}
+	if mode == "off" {
+		return nil, nil
+	}
+
+	rules := DefaultRules()
+	if path := os.Getenv("MODERATION_BLOCKLIST_FILE"); path != "" {
+		extra, err := LoadRules(path)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, extra...)
+	}
+	blocklist := NewBlocklistClassifier(rules)
+
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	switch mode {
+	case "local":
+		return blocklist, nil
+	case "openai":
+		if apiKey == "" {
+			return nil, errors.New("OPENAI_API_KEY no está configurada")
+		}
+		return NewOpenAIClassifier(apiKey), nil
+	case "auto":
+		if apiKey == "" || os.Getenv("LLM_MODE") == "replay" {
+			return blocklist, nil
+		}
+		return NewChain(blocklist, NewOpenAIClassifier(apiKey)), nil
+	default:
+		return nil, fmt.Errorf("unknown MODERATION_MODE %q", mode)
+	}
+}
+
+// CollectText joins every string found in a decoded JSON value (object keys sorted),
+// so structured content can be classified as a single text
+func CollectText(value interface{}) string {
+	var parts []string
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch t := v.(type) {
+		case string:
+			if s := strings.TrimSpace(t); s != "" {
+				parts = append(parts, s)
+			}
+		case []interface{}:
+			for _, item := range t {
+				walk(item)
+			}
+		case map[string]interface{}:
+			keys := make([]string, 0, len(t))
+			for k := range t {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				walk(t[k])
+			}
+		}
+	}
+	walk(value)
+	return strings.Join(parts, "\n")
+}
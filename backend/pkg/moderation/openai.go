@@ -0,0 +1,58 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// OpenAIClassifier implements Classifier with the OpenAI moderation API
+type OpenAIClassifier struct {
+	client *openai.Client
+}
+
+// NewOpenAIClassifier creates a classifier authenticated with the given API key
+func NewOpenAIClassifier(apiKey string) *OpenAIClassifier {
+	return &OpenAIClassifier{client: openai.NewClient(apiKey)}
+}
+
+// Name implements Classifier
+func (c *OpenAIClassifier) Name() string {
+	return "openai"
+}
+
+// Classify implements Classifier
+func (c *OpenAIClassifier) Classify(ctx context.Context, text string) (*Result, error) {
+	resp, err := c.client.Moderations(ctx, openai.ModerationRequest{
+		Input: text,
+		Model: openai.ModerationOmniLatest,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Results) == 0 {
+		return nil, errors.New("moderation: empty response from provider")
+	}
+
+	result := &Result{Classifier: c.Name(), Flagged: resp.Results[0].Flagged}
+	if !result.Flagged {
+		return result, nil
+	}
+
+	// The categories struct has one bool per category, named by its JSON tag
+	raw, _ := json.Marshal(resp.Results[0].Categories)
+	var categories map[string]bool
+	json.Unmarshal(raw, &categories)
+	for category, flagged := range categories {
+		if flagged {
+			result.Categories = append(result.Categories, category)
+		}
+	}
+	sort.Strings(result.Categories)
+	result.Reason = "OpenAI moderation: " + strings.Join(result.Categories, ", ")
+	return result, nil
+}
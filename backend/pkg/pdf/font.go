@@ -0,0 +1,112 @@
+package pdf
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Glyph widths (1/1000 em) of Helvetica and Helvetica-Bold for ASCII 32-126, from the Adobe AFM files
+var (
+	helveticaWidths = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBoldWidths = [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+// winAnsiExtra maps the characters WinAnsiEncoding places in 0x80-0x9F
+var winAnsiExtra = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// encode converts UTF-8 text to WinAnsiEncoding; tabs and line breaks become spaces
+func encode(text string) string {
+	var out strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\t', r == '\n', r == '\r':
+			out.WriteByte(' ')
+		case r >= 32 && r < 127, r >= 0xA0 && r <= 0xFF:
+			out.WriteByte(byte(r))
+		case winAnsiExtra[r] != 0:
+			out.WriteByte(winAnsiExtra[r])
+		default:
+			out.WriteByte('?')
+		}
+	}
+	return out.String()
+}
+
+// latinBase is the ASCII letter whose width approximates an accented letter
+var latinBase = strings.NewReplacer(
+	"á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n",
+	"Á", "A", "É", "E", "Í", "I", "Ó", "O", "Ú", "U", "Ü", "U", "Ñ", "N",
+	"¿", "?", "¡", "!", "°", "o", "º", "o", "ª", "a", "·", ".",
+	"“", "\"", "”", "\"", "‘", "'", "’", "'", "–", "-", "—", "--", "…", "...", "•", "-",
+)
+
+// TextWidth is the width in points of text drawn at size
+func TextWidth(text string, size float64, bold bool) float64 {
+	widths := &helveticaWidths
+	if bold {
+		widths = &helveticaBoldWidths
+	}
+	total := 0
+	for _, r := range latinBase.Replace(text) {
+		switch {
+		case r >= 32 && r < 127:
+			total += widths[r-32]
+		case unicode.IsUpper(r):
+			total += 722
+		default:
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// Wrap splits text into lines no wider than width. Words longer than a line are cut.
+func Wrap(text string, size float64, bold bool, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for TextWidth(word, size, bold) > width && len([]rune(word)) > 1 {
+				runes := []rune(word)
+				cut := len(runes) - 1
+				for cut > 1 && TextWidth(string(runes[:cut]), size, bold) > width {
+					cut--
+				}
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				lines = append(lines, string(runes[:cut]))
+				word = string(runes[cut:])
+			}
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if line != "" && TextWidth(candidate, size, bold) > width {
+				lines = append(lines, line)
+				candidate = word
+			}
+			line = candidate
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
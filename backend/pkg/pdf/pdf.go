@@ -0,0 +1,148 @@
+// Package pdf writes simple PDF documents without external dependencies: A4 pages with text in the
+// standard Helvetica fonts, filled rectangles and lines. It is meant for reports (bars, tables, lists),
+// not for general layout.
+//
+// Coordinates are in points measured from the top-left corner of the page. Text is encoded as
+// WinAnsiEncoding, so Spanish accents and ñ render with the built-in fonts; other characters are
+// replaced with '?'.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A4 page size in points
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+// Color is an RGB color with components between 0 and 1
+type Color struct {
+	R, G, B float64
+}
+
+// Hex parses colors like "#3b82f6"; invalid values return black
+func Hex(value string) Color {
+	var r, g, b uint8
+	if _, err := fmt.Sscanf(strings.TrimPrefix(value, "#"), "%02x%02x%02x", &r, &g, &b); err != nil {
+		return Color{}
+	}
+	return Color{float64(r) / 255, float64(g) / 255, float64(b) / 255}
+}
+
+// Document is a PDF being built page by page
+type Document struct {
+	Title   string
+	Author  string
+	pages   []*bytes.Buffer
+	current *bytes.Buffer
+}
+
+// New creates an empty document; AddPage must be called before drawing
+func New() *Document {
+	return &Document{}
+}
+
+// AddPage starts a new page; drawing goes to the last page added
+func (d *Document) AddPage() {
+	d.current = &bytes.Buffer{}
+	d.pages = append(d.pages, d.current)
+}
+
+// PageCount is the number of pages added so far
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+// Text draws a single line of text with its baseline at y
+func (d *Document) Text(x, y, size float64, bold bool, color Color, text string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.current, "BT /%s %s Tf %s rg %s %s Td (%s) Tj ET\n",
+		font, num(size), rgb(color), num(x), num(PageHeight-y), escape(encode(text)))
+}
+
+// Rect draws a filled rectangle whose top-left corner is at (x, y)
+func (d *Document) Rect(x, y, width, height float64, color Color) {
+	fmt.Fprintf(d.current, "%s rg %s %s %s %s re f\n",
+		rgb(color), num(x), num(PageHeight-y-height), num(width), num(height))
+}
+
+// Line draws a straight line
+func (d *Document) Line(x1, y1, x2, y2, width float64, color Color) {
+	fmt.Fprintf(d.current, "%s RG %s w %s %s m %s %s l S\n",
+		rgb(color), num(width), num(x1), num(PageHeight-y1), num(x2), num(PageHeight-y2))
+}
+
+// Bytes serializes the document. Content streams are compressed with Flate.
+func (d *Document) Bytes() ([]byte, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// 1 catalog, 2 page tree, 3-4 fonts, 5 info; then a page and its content stream per page
+	const firstPage = 6
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object(fmt.Sprintf("<< /Title (%s) /Author (%s) /Producer (Lumera) /CreationDate (D:%s) >>",
+		escape(encode(d.Title)), escape(encode(d.Author)), time.Now().UTC().Format("20060102150405Z")))
+
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			num(PageWidth), num(PageHeight), firstPage+2*i+1))
+
+		var stream bytes.Buffer
+		writer := zlib.NewWriter(&stream)
+		writer.Write(page.Bytes())
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", stream.Len(), stream.Bytes()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes(), nil
+}
+
+func num(value float64) string {
+	formatted := strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", value), "0"), ".")
+	if formatted == "" || formatted == "-0" {
+		return "0"
+	}
+	return formatted
+}
+
+func rgb(color Color) string {
+	return num(color.R) + " " + num(color.G) + " " + num(color.B)
+}
+
+func escape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`, "\r", " ", "\n", " ").Replace(text)
+}
@@ -0,0 +1,173 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var (
+	startxrefPattern = regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`)
+	streamPattern    = regexp.MustCompile(`(?s)<< /Length (\d+) /Filter /FlateDecode >>\nstream\n`)
+	showTextPattern  = regexp.MustCompile(`(?s)Td \((.*?)\) Tj ET\n`)
+)
+
+// xrefOffsets parses the cross-reference table and checks that startxref points at it
+func xrefOffsets(t *testing.T, out []byte) []int {
+	t.Helper()
+	match := startxrefPattern.FindSubmatch(out)
+	if match == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(match[1]))
+	if !bytes.HasPrefix(out[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+
+	lines := strings.Split(string(out[xref:]), "\n")
+	var count int
+	fmt.Sscanf(lines[1], "0 %d", &count)
+	if lines[2] != "0000000000 65535 f " {
+		t.Fatalf("xref free entry = %q", lines[2])
+	}
+	offsets := make([]int, 0, count-1)
+	for _, line := range lines[3 : 3+count-1] {
+		if len(line) != 19 || !strings.HasSuffix(line, " 00000 n ") {
+			t.Fatalf("xref entry %q is not 20 bytes long", line)
+		}
+		offset, _ := strconv.Atoi(line[:10])
+		offsets = append(offsets, offset)
+	}
+	return offsets
+}
+
+// contentStreams inflates every page content stream
+func contentStreams(t *testing.T, out []byte) []string {
+	t.Helper()
+	var streams []string
+	for _, loc := range streamPattern.FindAllSubmatchIndex(out, -1) {
+		length, _ := strconv.Atoi(string(out[loc[2]:loc[3]]))
+		data := out[loc[1] : loc[1]+length]
+		if !bytes.HasPrefix(out[loc[1]+length:], []byte("\nendstream")) {
+			t.Fatalf("/Length %d does not end at endstream", length)
+		}
+		reader, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("inflate: %v", err)
+		}
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("inflate: %v", err)
+		}
+		streams = append(streams, string(content))
+	}
+	return streams
+}
+
+// balanced reports whether the unescaped parentheses of a string literal are balanced
+func balanced(literal string) bool {
+	depth := 0
+	for i := 0; i < len(literal); i++ {
+		switch literal[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+func TestDocumentBytes(t *testing.T) {
+	doc := New()
+	doc.Title = "Reporte (1° Medio): Matemática"
+	doc.Author = "Profesora Muñoz"
+	doc.AddPage()
+	doc.Text(40, 60, 14, true, Hex("#1f2937"), "Función (f) = x\\y — ¿Qué año? Ñandú")
+	doc.Rect(40, 80, 100, 10, Hex("#3b82f6"))
+	doc.AddPage()
+	doc.Text(40, 60, 11, false, Color{}, "Símbolos: π → 😀 中 \xff fin)")
+	doc.Line(40, 90, 200, 90, 1, Hex("#000000"))
+
+	out, err := doc.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+	if !bytes.HasPrefix(out, []byte("%PDF-1.4\n")) {
+		t.Fatal("missing header")
+	}
+
+	// 5 fixed objects plus a page and a content stream per page
+	offsets := xrefOffsets(t, out)
+	if len(offsets) != 5+2*doc.PageCount() {
+		t.Fatalf("got %d xref entries", len(offsets))
+	}
+	for i, offset := range offsets {
+		header := fmt.Sprintf("%d 0 obj\n", i+1)
+		if !bytes.HasPrefix(out[offset:], []byte(header)) {
+			t.Errorf("xref entry %d points at %q, want %q", i+1, out[offset:offset+10], header)
+		}
+	}
+
+	info := []byte("/Title (Reporte \\(1\xb0 Medio\\): Matem\xe1tica) /Author (Profesora Mu\xf1oz)")
+	if !bytes.Contains(out, info) {
+		t.Error("info dictionary is not WinAnsi encoded and escaped")
+	}
+
+	streams := contentStreams(t, out)
+	if len(streams) != 2 {
+		t.Fatalf("got %d content streams", len(streams))
+	}
+	want := []string{
+		"Funci\xf3n \\(f\\) = x\\\\y \x97 \xbfQu\xe9 a\xf1o? \xd1and\xfa",
+		// Runes outside WinAnsiEncoding and invalid UTF-8 become '?' one by one
+		"S\xedmbolos: ? ? ? ? ? fin\\)",
+	}
+	for i, stream := range streams {
+		shown := showTextPattern.FindStringSubmatch(stream)
+		if shown == nil {
+			t.Fatalf("page %d: no text in %q", i+1, stream)
+		}
+		if shown[1] != want[i] {
+			t.Errorf("page %d: text = %q, want %q", i+1, shown[1], want[i])
+		}
+		if !balanced(shown[1]) {
+			t.Errorf("page %d: unbalanced string literal %q", i+1, shown[1])
+		}
+	}
+}
+
+func TestEncodeDegradesUnmappableRunes(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"ñandú", "\xf1and\xfa"},
+		{"“comillas” – 5€", "\x93comillas\x94 \x96 5\x80"},
+		{"tab\tsalto\nretorno\r", "tab salto retorno "},
+		{"√2 ≈ 1,41", "?2 ? 1,41"},
+		{"\x00\x1b", "??"},
+		{"a\xc3b", "a?b"},
+	}
+	for _, tt := range tests {
+		got := encode(tt.text)
+		if got != tt.want {
+			t.Errorf("encode(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+		for i := 0; i < len(got); i++ {
+			if got[i] < 32 {
+				t.Errorf("encode(%q) kept control byte %#x", tt.text, got[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package vectorstore
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/platanus-hack-25/lumera_app/pkg/embeddings"
+)
+
+// MemoryIndex implements Index with a brute force scan over chunks kept in memory
+type MemoryIndex struct {
+	mu     sync.RWMutex
+	chunks map[uint]Chunk
+}
+
+// NewMemoryIndex creates an empty in-process index
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{chunks: make(map[uint]Chunk)}
+}
+
+// Name implements Index
+func (m *MemoryIndex) Name() string {
+	return "memory"
+}
+
+// Len returns the number of indexed chunks
+func (m *MemoryIndex) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.chunks)
+}
+
+// Add implements Index
+func (m *MemoryIndex) Add(ctx context.Context, chunks []Chunk) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, chunk := range chunks {
+		m.chunks[chunk.ID] = chunk
+	}
+	return nil
+}
+
+// RemoveDocument implements Index
+func (m *MemoryIndex) RemoveDocument(ctx context.Context, documentID uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, chunk := range m.chunks {
+		if chunk.DocumentID == documentID {
+			delete(m.chunks, id)
+		}
+	}
+	return nil
+}
+
+// Search implements Index
+func (m *MemoryIndex) Search(ctx context.Context, query []float32, filter Filter, k int) ([]Match, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	matches := []Match{}
+	for _, chunk := range m.chunks {
+		if !filter.matches(chunk) {
+			continue
+		}
+		matches = append(matches, Match{
+			ChunkID:    chunk.ID,
+			DocumentID: chunk.DocumentID,
+			Score:      embeddings.Cosine(query, chunk.Vector),
+		})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ChunkID < matches[j].ChunkID
+	})
+	if k > 0 && len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches, nil
+}
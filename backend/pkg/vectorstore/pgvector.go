@@ -0,0 +1,97 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// PgvectorIndex implements Index with the pgvector extension. The chunks live in a
+// table with the columns id, document_id, materia_id, oa_id, embedding_model and
+// embedding (REAL[]), so Add and RemoveDocument are no-ops: inserting or deleting
+// the rows is enough.
+type PgvectorIndex struct {
+	db    *gorm.DB
+	table string
+}
+
+// NewPgvectorIndex creates an index over the given chunks table
+func NewPgvectorIndex(db *gorm.DB, table string) *PgvectorIndex {
+	return &PgvectorIndex{db: db, table: table}
+}
+
+// PgvectorAvailable reports whether the vector extension is installed in the database
+func PgvectorAvailable(db *gorm.DB) bool {
+	var installed bool
+	if err := db.Raw(`SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'vector')`).Scan(&installed).Error; err != nil {
+		return false
+	}
+	return installed
+}
+
+// Name implements Index
+func (p *PgvectorIndex) Name() string {
+	return "pgvector"
+}
+
+// Add implements Index
+func (p *PgvectorIndex) Add(ctx context.Context, chunks []Chunk) error {
+	return nil
+}
+
+// RemoveDocument implements Index
+func (p *PgvectorIndex) RemoveDocument(ctx context.Context, documentID uint) error {
+	return nil
+}
+
+// Search implements Index
+func (p *PgvectorIndex) Search(ctx context.Context, query []float32, filter Filter, k int) ([]Match, error) {
+	if k <= 0 {
+		k = 10
+	}
+
+	where := []string{"embedding_model = ?"}
+	args := []interface{}{vectorLiteral(query), filter.Model}
+	if filter.MateriaID != 0 {
+		where = append(where, "materia_id = ?")
+		args = append(args, filter.MateriaID)
+	}
+	if filter.OAID != nil {
+		where = append(where, "(oa_id = ? OR oa_id IS NULL)")
+		args = append(args, *filter.OAID)
+	}
+	args = append(args, k)
+
+	// <=> is the cosine distance, so the similarity is 1 - distance
+	sql := fmt.Sprintf(`
+		SELECT id AS chunk_id, document_id, score FROM (
+			SELECT id, document_id, 1 - (embedding::vector <=> ?::vector) AS score
+			FROM %s
+			WHERE %s
+		) ranked
+		ORDER BY score DESC, id
+		LIMIT ?`, p.table, strings.Join(where, " AND "))
+
+	matches := []Match{}
+	if err := p.db.WithContext(ctx).Raw(sql, args...).Scan(&matches).Error; err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// vectorLiteral formats a vector as pgvector text input: [0.1,0.2,...]
+func vectorLiteral(v []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, x := range v {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(x), 'g', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
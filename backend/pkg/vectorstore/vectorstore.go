@@ -0,0 +1,60 @@
+// Package vectorstore keeps the embedded chunks of the curriculum documents and
+// finds the ones closest to a query vector.
+//
+// Two indexes are available:
+//   - MemoryIndex: in-process brute force search, loaded at startup (tests, small curricula)
+//   - PgvectorIndex: delegates the search to PostgreSQL with the pgvector extension
+package vectorstore
+
+import (
+	"context"
+)
+
+// Chunk is an embedded fragment of a document
+type Chunk struct {
+	ID         uint
+	DocumentID uint
+	MateriaID  uint
+	OAID       *uint  // nil when the document applies to the whole materia
+	Model      string // embedding provider that produced Vector
+	Vector     []float32
+}
+
+// Filter restricts a search
+type Filter struct {
+	MateriaID uint   // 0 searches every materia
+	OAID      *uint  // when set, chunks of that OA or of the whole materia (OAID nil)
+	Model     string // required: vectors of different providers are not comparable
+}
+
+// Match is a search result, best first
+type Match struct {
+	ChunkID    uint    `json:"chunk_id"`
+	DocumentID uint    `json:"documento_id"`
+	Score      float64 `json:"score"` // cosine similarity
+}
+
+// Index is implemented by every vector store
+type Index interface {
+	// Name identifies the index in logs and stats
+	Name() string
+	// Add indexes chunks, replacing any chunk with the same ID
+	Add(ctx context.Context, chunks []Chunk) error
+	// RemoveDocument drops every chunk of a document
+	RemoveDocument(ctx context.Context, documentID uint) error
+	// Search returns up to k chunks matching the filter, most similar first
+	Search(ctx context.Context, query []float32, filter Filter, k int) ([]Match, error)
+}
+
+func (f Filter) matches(chunk Chunk) bool {
+	if chunk.Model != f.Model {
+		return false
+	}
+	if f.MateriaID != 0 && chunk.MateriaID != f.MateriaID {
+		return false
+	}
+	if f.OAID != nil && chunk.OAID != nil && *chunk.OAID != *f.OAID {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,19 @@
+// Package prompts embeds the default LLM prompt templates.
+//
+// Layout:
+//
+//	partials.tmpl                 shared {{define}} blocks available to every template
+//	experiments.json              A/B weights per template name and version
+//	<nombre>/<version>.tmpl       one text/template per prompt version
+//
+// Template names are "plan_structure", "example_personalization", "question_hints"
+// and one per component type (e.g. "ExplainAndExploreSlide"). The same layout can be loaded
+// from PROMPT_TEMPLATES_DIR, and the prompt_templates table overrides both.
+package prompts
+
+import "embed"
+
+// FS holds the default templates compiled into the binary
+//
+//go:embed partials.tmpl experiments.json */*.tmpl
+var FS embed.FS
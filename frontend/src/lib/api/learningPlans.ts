@@ -11,8 +11,42 @@ export interface LearningPlanComponent {
   tipo_componente: string;
   objetivo_especifico: string;
   tiempo_estimado_minutos: number;
-  estado: 'pendiente' | 'generando' | 'generado' | 'error';
+  estado: 'pendiente' | 'generando' | 'generado' | 'error' | 'cuarentena'; // cuarentena: flagged by moderation, in review
   contenido_props: any | null;
+  es_remediacion?: boolean;
+}
+
+export interface LearningPlanCheckpoint {
+  id: number;
+  learning_plan_id: number;
+  component_id: number; // the checkpoint is shown after this component
+  question_ids: number[];
+  porcentaje_aprobacion: number;
+  estado: 'pendiente' | 'aprobado' | 'reprobado';
+  intentos: number;
+  mejor_porcentaje?: number;
+  remediation_component_id?: number;
+}
+
+export interface CheckpointQuestion {
+  id: number;
+  tipo: 'multiple_choice' | 'true_false';
+  question_data: any; // without explicacion, so it doesn't give the answer away
+}
+
+export interface CheckpointWithQuestions extends LearningPlanCheckpoint {
+  preguntas: CheckpointQuestion[];
+}
+
+export interface CheckpointResult {
+  checkpoint: LearningPlanCheckpoint;
+  correctas: number;
+  total: number;
+  porcentaje: number;
+  aprobado: boolean;
+  resultados: { question_id: number; correcta: boolean; puntaje: number; error?: string }[];
+  remediacion?: LearningPlanComponent;
+  plan_aprobado: boolean;
 }
 
 export interface LearningPlan {
@@ -24,6 +58,7 @@ export interface LearningPlan {
   tiempo_estimado_minutos: number;
   estado: 'generando' | 'generado' | 'error';
   components?: LearningPlanComponent[];
+  checkpoints?: LearningPlanCheckpoint[];
   created_at?: string;
   updated_at?: string;
   // Completion tracking fields
@@ -32,6 +67,11 @@ export interface LearningPlan {
   fecha_completado?: string;
   progreso_actual?: number;
   total_slides?: number;
+  // Current version; bumped by regenerations and rollbacks
+  version?: number;
+  // Resume point: last component the student opened
+  ultimo_componente_id?: number;
+  ultima_actividad?: string;
 }
 
 export interface OABloomObjective {
@@ -116,34 +156,63 @@ export async function getPlanByOA(oaBloomObjectiveId: number): Promise<LearningP
   }
 }
 
+export interface GenerationJob {
+  id: number;
+  tipo: string;
+  user_id: number;
+  learning_plan_id: number | null;
+  estado: 'pendiente' | 'en_proceso' | 'completado' | 'dead_letter';
+  intentos: number;
+  max_intentos: number;
+  error_mensaje?: string;
+}
+
+export interface ComponentProgress {
+  component_id: number;
+  orden: number;
+  tipo_componente: string;
+  estado: LearningPlanComponent['estado'];
+  error_mensaje?: string;
+}
+
+export type GenerationEvent =
+  | { type: 'job'; data: GenerationJob }
+  | { type: 'component'; data: ComponentProgress }
+  | {
+      type: 'done';
+      data: {
+        estado: GenerationJob['estado'];
+        learning_plan_id: number | null;
+        error_mensaje?: string;
+        generados: number;
+        total: number;
+      };
+    };
+
 /**
  * Generate a new learning plan for a specific OA Bloom Objective
- * This will create the plan structure (without content yet)
  *
- * NOTE: Plan generation can take 2-3 minutes because it generates content with OpenAI
- * We use AbortController with a 5 minute timeout to prevent browser timeout
+ * The backend queues the generation and answers 202 with a job. We follow the job
+ * over Server-Sent Events (reconnecting when the server closes the stream) and
+ * load the plan once it is done. onProgress receives every job/component event.
  */
-export async function generatePlan(oaBloomObjectiveId: number): Promise<{
+export async function generatePlan(
+  oaBloomObjectiveId: number,
+  onProgress?: (event: GenerationEvent) => void
+): Promise<{
   success: boolean;
   plan?: LearningPlan;
   error?: string;
 }> {
   try {
-    // Create an AbortController with 5 minute timeout (300000ms)
-    const controller = new AbortController();
-    const timeoutId = setTimeout(() => controller.abort(), 300000);
-
     const response = await fetch('/api/learning-plans/generate', {
       method: 'POST',
       headers: getAuthHeaders(),
       body: JSON.stringify({
         oa_bloom_objective_id: oaBloomObjectiveId
-      }),
-      signal: controller.signal
+      })
     });
 
-    clearTimeout(timeoutId);
-
     if (!response.ok) {
       const errorData = await response.json().catch(() => null);
       return {
@@ -152,19 +221,169 @@ export async function generatePlan(oaBloomObjectiveId: number): Promise<{
       };
     }
 
-    const plan = await response.json();
-    return { success: true, plan };
-  } catch (error) {
-    console.error('Error generating plan:', error);
+    // Plan already existed
+    if (response.status !== 202) {
+      const plan = await response.json();
+      return { success: true, plan };
+    }
 
-    // Handle abort/timeout specifically
-    if (error instanceof Error && error.name === 'AbortError') {
+    const accepted: { job_id: number; events_url: string } = await response.json();
+    const done = await followGenerationJob(accepted.events_url, onProgress);
+
+    if (done.estado !== 'completado' || !done.learning_plan_id) {
       return {
         success: false,
-        error: 'La generación del plan está tomando más tiempo del esperado. Por favor, recarga la página en unos minutos.'
+        error: done.error_mensaje || 'No se pudo generar el plan. Intenta nuevamente en unos minutos.'
       };
     }
 
+    return await getPlanById(done.learning_plan_id);
+  } catch (error) {
+    console.error('Error generating plan:', error);
+    return {
+      success: false,
+      error: error instanceof Error ? error.message : 'Unknown error'
+    };
+  }
+}
+
+// Give up following a job after 10 minutes
+const GENERATION_FOLLOW_TIMEOUT_MS = 600000;
+
+/**
+ * Follow a generation job's SSE stream until the "done" event.
+ * EventSource can't send the Authorization header, so the stream is read with fetch.
+ */
+async function followGenerationJob(
+  eventsUrl: string,
+  onProgress?: (event: GenerationEvent) => void
+): Promise<Extract<GenerationEvent, { type: 'done' }>['data']> {
+  const startedAt = Date.now();
+
+  while (Date.now() - startedAt < GENERATION_FOLLOW_TIMEOUT_MS) {
+    const response = await fetch(eventsUrl, { headers: getAuthHeaders() });
+    if (!response.ok || !response.body) {
+      throw new Error(`HTTP ${response.status}: ${response.statusText}`);
+    }
+
+    let result: Extract<GenerationEvent, { type: 'done' }>['data'] | null = null;
+    await readEventStream(response, (type, data) => {
+      const event = { type, data } as GenerationEvent;
+      onProgress?.(event);
+      if (event.type === 'done') {
+        result = event.data;
+        return true;
+      }
+      return false;
+    });
+    if (result) return result;
+
+    // The server closes long streams; reconnect and receive a fresh snapshot
+    await new Promise((resolve) => setTimeout(resolve, 2000));
+  }
+
+  throw new Error('La generación del plan está tomando más tiempo del esperado. Por favor, recarga la página en unos minutos.');
+}
+
+/**
+ * Read a Server-Sent Events response, calling onEvent with each parsed JSON event.
+ * onEvent returns true to stop reading.
+ */
+async function readEventStream(
+  response: Response,
+  onEvent: (type: string, data: any) => boolean | void
+): Promise<void> {
+  const reader = response.body!.pipeThrough(new TextDecoderStream()).getReader();
+  let buffer = '';
+
+  while (true) {
+    const { value, done } = await reader.read();
+    if (done) return;
+
+    buffer += value;
+    let separator: number;
+    while ((separator = buffer.indexOf('\n\n')) !== -1) {
+      const raw = buffer.slice(0, separator);
+      buffer = buffer.slice(separator + 2);
+
+      let type = 'message';
+      let data = '';
+      for (const line of raw.split('\n')) {
+        if (line.startsWith('event: ')) type = line.slice(7);
+        else if (line.startsWith('data: ')) data += line.slice(6);
+      }
+      if (!data) continue;
+
+      if (onEvent(type, JSON.parse(data))) {
+        reader.cancel();
+        return;
+      }
+    }
+  }
+}
+
+export type ContentStreamEvent =
+  | { type: 'titulo'; data: { titulo: string } }
+  | { type: 'campo'; data: { campo: string; valor: string } }
+  | { type: 'bloque'; data: { campo?: string; indice: number; bloque: any } }
+  | { type: 'reintento'; data: { intento: number; motivo: string } }
+  | { type: 'done'; data: LearningPlanComponent }
+  | { type: 'cuarentena'; data: LearningPlanComponent }
+  | { type: 'error'; data: { error: string } };
+
+/**
+ * Stream the content of a component block by block.
+ * onEvent receives the title, each top-level text field ("campo") and each element of the
+ * component's main array ("bloque", named by data.campo) as soon as the backend parses it; on
+ * "reintento" everything received so far must be discarded. Resolves with the final
+ * component once its content has been validated and saved. On "cuarentena" moderation
+ * flagged the content: discard what was received, the component comes back without content.
+ */
+export async function streamComponentContent(
+  planId: number,
+  componentId: number,
+  onEvent: (event: ContentStreamEvent) => void
+): Promise<{
+  success: boolean;
+  component?: LearningPlanComponent;
+  error?: string;
+}> {
+  try {
+    // The server closes long streams; reconnecting returns the saved content once ready
+    for (let attempt = 0; attempt < 5; attempt++) {
+      const response = await fetch(
+        `/api/learning-plans/${planId}/components/${componentId}/stream-content`,
+        {
+          method: 'POST',
+          headers: getAuthHeaders()
+        }
+      );
+
+      if (!response.ok || !response.body) {
+        const errorData = await response.json().catch(() => null);
+        return {
+          success: false,
+          error: errorData?.error || `HTTP ${response.status}: ${response.statusText}`
+        };
+      }
+
+      let finished: { success: boolean; component?: LearningPlanComponent; error?: string } | null = null;
+      await readEventStream(response, (type, data) => {
+        const event = { type, data } as ContentStreamEvent;
+        onEvent(event);
+        if (event.type === 'done' || event.type === 'cuarentena') {
+          finished = { success: true, component: event.data };
+        } else if (event.type === 'error') {
+          finished = { success: false, error: event.data.error };
+        }
+        return finished !== null;
+      });
+      if (finished) return finished;
+    }
+
+    return { success: false, error: 'La generación del contenido está tomando más tiempo del esperado.' };
+  } catch (error) {
+    console.error('Error streaming component content:', error);
     return {
       success: false,
       error: error instanceof Error ? error.message : 'Unknown error'
@@ -317,6 +536,7 @@ export async function completeLearningPlan(planId: number): Promise<{
   success: boolean;
   plan?: LearningPlan;
   error?: string;
+  pendingCheckpoints?: number[];
 }> {
   try {
     const response = await fetch(`/api/learning-plans/${planId}/complete`, {
@@ -328,7 +548,9 @@ export async function completeLearningPlan(planId: number): Promise<{
       const errorData = await response.json().catch(() => null);
       return {
         success: false,
-        error: errorData?.error || `HTTP ${response.status}: ${response.statusText}`
+        error: errorData?.error || `HTTP ${response.status}: ${response.statusText}`,
+        // 409: the plan still has checkpoints that were not passed
+        pendingCheckpoints: errorData?.checkpoints_pendientes
       };
     }
 
@@ -342,3 +564,349 @@ export async function completeLearningPlan(planId: number): Promise<{
     };
   }
 }
+
+/**
+ * Get the checkpoints of a plan with their questions (answers are not included)
+ */
+export async function getPlanCheckpoints(planId: number): Promise<{
+  success: boolean;
+  checkpoints?: CheckpointWithQuestions[];
+  error?: string;
+}> {
+  try {
+    const response = await fetch(`/api/learning-plans/${planId}/checkpoints`, {
+      headers: getAuthHeaders()
+    });
+
+    if (!response.ok) {
+      const errorData = await response.json().catch(() => null);
+      return {
+        success: false,
+        error: errorData?.error || `HTTP ${response.status}: ${response.statusText}`
+      };
+    }
+
+    const checkpoints = await response.json();
+    return { success: true, checkpoints };
+  } catch (error) {
+    console.error('Error fetching checkpoints:', error);
+    return {
+      success: false,
+      error: error instanceof Error ? error.message : 'Unknown error'
+    };
+  }
+}
+
+/**
+ * Submit the answers of a checkpoint. Answers use the same format as question validation:
+ * { selected: "A" } for multiple_choice and { answer: true } for true_false.
+ * If the checkpoint is failed the result includes the inserted remediation component.
+ */
+export async function submitCheckpoint(
+  planId: number,
+  checkpointId: number,
+  respuestas: { question_id: number; answer: any }[]
+): Promise<{
+  success: boolean;
+  result?: CheckpointResult;
+  error?: string;
+}> {
+  try {
+    const response = await fetch(`/api/learning-plans/${planId}/checkpoints/${checkpointId}/submit`, {
+      method: 'POST',
+      headers: getAuthHeaders(),
+      body: JSON.stringify({ respuestas })
+    });
+
+    if (!response.ok) {
+      const errorData = await response.json().catch(() => null);
+      return {
+        success: false,
+        error: errorData?.error || `HTTP ${response.status}: ${response.statusText}`
+      };
+    }
+
+    const result = await response.json();
+    return { success: true, result };
+  } catch (error) {
+    console.error('Error submitting checkpoint:', error);
+    return {
+      success: false,
+      error: error instanceof Error ? error.message : 'Unknown error'
+    };
+  }
+}
+
+export type ComponentEventType = 'abierto' | 'tiempo' | 'scroll_final' | 'audio';
+
+export interface ComponentEvent {
+  component_id: number;
+  tipo: ComponentEventType;
+  tiempo_segundos?: number;
+}
+
+export interface ComponentProgressSummary {
+  component_id: number;
+  orden: number;
+  tipo_componente: string;
+  abierto: boolean;
+  aperturas: number;
+  tiempo_segundos: number;
+  scroll_final: boolean;
+  audio: boolean;
+}
+
+export interface PlanProgress {
+  learning_plan_id: number;
+  progreso_actual: number;
+  total_slides: number;
+  ultimo_componente_id?: number;
+  ultima_actividad?: string;
+  completado: boolean;
+  componentes?: ComponentProgressSummary[];
+}
+
+/**
+ * Record slide view events (opened, time spent, scrolled to end, audio played)
+ * Uses keepalive so events sent while the page is closing still reach the backend
+ */
+export async function recordPlanEvents(planId: number, eventos: ComponentEvent[]): Promise<{
+  success: boolean;
+  progress?: PlanProgress;
+  error?: string;
+}> {
+  if (eventos.length === 0) {
+    return { success: true };
+  }
+
+  try {
+    const response = await fetch(`/api/learning-plans/${planId}/events`, {
+      method: 'POST',
+      headers: getAuthHeaders(),
+      body: JSON.stringify({ eventos }),
+      keepalive: true
+    });
+
+    if (!response.ok) {
+      const errorData = await response.json().catch(() => null);
+      return {
+        success: false,
+        error: errorData?.error || `HTTP ${response.status}: ${response.statusText}`
+      };
+    }
+
+    const progress = await response.json();
+    return { success: true, progress };
+  } catch (error) {
+    console.error('Error recording plan events:', error);
+    return {
+      success: false,
+      error: error instanceof Error ? error.message : 'Unknown error'
+    };
+  }
+}
+
+/**
+ * Get plan progress, resume point and per-component activity
+ */
+export async function getPlanProgress(planId: number): Promise<{
+  success: boolean;
+  progress?: PlanProgress;
+  error?: string;
+}> {
+  try {
+    const response = await fetch(`/api/learning-plans/${planId}/progress`, {
+      headers: getAuthHeaders()
+    });
+
+    if (!response.ok) {
+      const errorData = await response.json().catch(() => null);
+      return {
+        success: false,
+        error: errorData?.error || `HTTP ${response.status}: ${response.statusText}`
+      };
+    }
+
+    const progress = await response.json();
+    return { success: true, progress };
+  } catch (error) {
+    console.error('Error fetching plan progress:', error);
+    return {
+      success: false,
+      error: error instanceof Error ? error.message : 'Unknown error'
+    };
+  }
+}
+
+// ============================================================================
+// Feedback, regeneration and versions
+// ============================================================================
+
+export type FeedbackValoracion = 'positiva' | 'negativa';
+
+export interface PlanFeedback {
+  id: number;
+  learning_plan_id: number;
+  component_id?: number; // missing = feedback on the whole plan
+  tipo_componente?: string;
+  version: number;
+  valoracion?: FeedbackValoracion;
+  comentario?: string;
+  aplicada_en_version?: number;
+  created_at: string;
+}
+
+export interface PlanVersionSummary {
+  version: number;
+  actual: boolean;
+  motivo?: 'regeneracion_componente' | 'regeneracion_plan' | 'rollback';
+  nota?: string;
+  titulo: string;
+  componentes: number;
+  created_at: string;
+}
+
+export interface ComponentSnapshot {
+  id: number;
+  orden: number;
+  tipo_componente: string;
+  objetivo_especifico: string;
+  tiempo_estimado_minutos: number;
+  estado: string;
+  contenido_props?: any;
+  es_remediacion: boolean;
+}
+
+export interface PlanVersionComparison {
+  from: number;
+  to: number;
+  campos_plan?: string[];
+  componentes: {
+    orden: number;
+    cambio: 'igual' | 'modificado' | 'agregado' | 'eliminado';
+    campos?: string[];
+    antes?: ComponentSnapshot;
+    despues?: ComponentSnapshot;
+  }[];
+}
+
+/**
+ * Shared request helper for the feedback/version endpoints: returns the parsed JSON or an error message
+ */
+async function requestJSON<T>(url: string, init: RequestInit = {}): Promise<{
+  success: boolean;
+  data?: T;
+  error?: string;
+}> {
+  try {
+    const response = await fetch(url, { ...init, headers: getAuthHeaders() });
+
+    if (response.status === 422) {
+      // Student text flagged by moderation
+      return { success: false, error: 'Tu texto incluye contenido no permitido. Revísalo e inténtalo de nuevo.' };
+    }
+
+    if (!response.ok) {
+      const errorData = await response.json().catch(() => null);
+      return {
+        success: false,
+        error: errorData?.error || `HTTP ${response.status}: ${response.statusText}`
+      };
+    }
+
+    return { success: true, data: await response.json() };
+  } catch (error) {
+    console.error(`Error requesting ${url}:`, error);
+    return {
+      success: false,
+      error: error instanceof Error ? error.message : 'Unknown error'
+    };
+  }
+}
+
+/**
+ * Thumbs up/down and/or comment on a component (or on the whole plan without componentId)
+ */
+export async function submitPlanFeedback(
+  planId: number,
+  feedback: { component_id?: number; valoracion?: FeedbackValoracion; comentario?: string }
+) {
+  return requestJSON<PlanFeedback>(`/api/learning-plans/${planId}/feedback`, {
+    method: 'POST',
+    body: JSON.stringify(feedback)
+  });
+}
+
+/**
+ * Save a new plan version and reset the component to pending; its content is then
+ * streamed again (streamComponentContent) with the student's feedback in the prompt
+ */
+export async function regenerateComponent(planId: number, componentId: number, comentario = '') {
+  return requestJSON<LearningPlanComponent>(`/api/learning-plans/${planId}/components/${componentId}/regenerate`, {
+    method: 'POST',
+    body: JSON.stringify({ comentario })
+  });
+}
+
+/**
+ * Regenerate the whole plan (new structure and content) with the pending feedback.
+ * Follows the generation job like generatePlan and returns the regenerated plan.
+ */
+export async function regeneratePlan(
+  planId: number,
+  comentario = '',
+  onProgress?: (event: GenerationEvent) => void
+): Promise<{
+  success: boolean;
+  plan?: LearningPlan;
+  error?: string;
+}> {
+  const accepted = await requestJSON<{ job_id: number; events_url: string }>(`/api/learning-plans/${planId}/regenerate`, {
+    method: 'POST',
+    body: JSON.stringify({ comentario })
+  });
+  if (!accepted.success || !accepted.data) {
+    return { success: false, error: accepted.error };
+  }
+
+  try {
+    const done = await followGenerationJob(accepted.data.events_url, onProgress);
+    if (done.estado !== 'completado') {
+      return {
+        success: false,
+        error: done.error_mensaje || 'No se pudo regenerar el plan. Puedes restaurar la versión anterior.'
+      };
+    }
+    return await getPlanById(planId);
+  } catch (error) {
+    console.error('Error regenerating plan:', error);
+    return {
+      success: false,
+      error: error instanceof Error ? error.message : 'Unknown error'
+    };
+  }
+}
+
+/**
+ * List plan versions, newest first (the first one is the current version)
+ */
+export async function getPlanVersions(planId: number) {
+  return requestJSON<PlanVersionSummary[]>(`/api/learning-plans/${planId}/versions`);
+}
+
+/**
+ * Compare two plan versions component by component (to defaults to the current version)
+ */
+export async function comparePlanVersions(planId: number, from: number, to?: number) {
+  const query = to ? `from=${from}&to=${to}` : `from=${from}`;
+  return requestJSON<PlanVersionComparison>(`/api/learning-plans/${planId}/versions/compare?${query}`);
+}
+
+/**
+ * Restore a previous plan version; the current one is kept as a version too
+ */
+export async function rollbackPlan(planId: number, version: number) {
+  return requestJSON<LearningPlan>(`/api/learning-plans/${planId}/versions/${version}/rollback`, {
+    method: 'POST'
+  });
+}
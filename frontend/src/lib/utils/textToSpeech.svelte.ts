@@ -33,6 +33,11 @@ export class TextToSpeechPlayer {
 		// Stop any currently playing audio
 		this.stop();
 
+		// Notify listeners (e.g. slide progress tracking) that audio was requested
+		if (typeof window !== 'undefined') {
+			window.dispatchEvent(new CustomEvent('lumera:tts-play'));
+		}
+
 		this.isLoading = true;
 		this.error = null;
 		this.currentText = text;
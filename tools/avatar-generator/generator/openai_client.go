@@ -3,28 +3,32 @@ package generator
 import (
 	"context"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
-	openai "github.com/sashabaranov/go-openai"
+	"github.com/platanus-hack-25/lumera_app/pkg/llm"
 )
 
-var openaiClient *openai.Client
+var llmClient llm.LLMClient
 
-// InitOpenAI inicializa el cliente de OpenAI
-func InitOpenAI() {
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		log.Fatal("OPENAI_API_KEY no está configurada en el archivo .env")
+// InitLLMClient inicializa el cliente LLM según LLM_MODE (openai, record, replay, auto).
+// Requiere ConnectDB: cada llamada queda registrada en llm_calls.
+func InitLLMClient() {
+	client, err := llm.NewFromEnv()
+	if err != nil {
+		log.Fatalf("❌ LLM client initialization failed: %v", err)
 	}
-	openaiClient = openai.NewClient(apiKey)
-	log.Println("✓ OpenAI client initialized")
+	llmClient = llm.NewMeteredClient(client, llm.NewGormRecorder(DB))
+	log.Println("✓ LLM client initialized")
+}
+
+// SetLLMClient reemplaza el cliente LLM (p. ej. con un llm.FakeClient en tests)
+func SetLLMClient(client llm.LLMClient) {
+	llmClient = client
 }
 
 // slugify convierte un nombre en un slug para archivo
@@ -73,17 +77,16 @@ func GenerateAvatarImage(avatar Avatar) (string, error) {
 
 	var lastError error
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+		ctx := llm.WithAttempt(llm.WithFeature(context.Background(), llm.FeatureAvatarImage), attempt)
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
 		defer cancel()
 
 		// Generar imagen con DALL-E-3
-		resp, err := openaiClient.CreateImage(ctx, openai.ImageRequest{
-			Prompt:         fullPrompt,
-			Model:          "dall-e-3",
-			N:              1,
-			Size:           "1024x1024",
-			Quality:        "standard",
-			ResponseFormat: "url",
+		resp, err := llmClient.Image(ctx, llm.ImageRequest{
+			Prompt:  fullPrompt,
+			Model:   "dall-e-3",
+			Size:    "1024x1024",
+			Quality: "standard",
 		})
 
 		if err != nil {
@@ -97,24 +100,10 @@ func GenerateAvatarImage(avatar Avatar) (string, error) {
 			return "", fmt.Errorf("failed after %d attempts: %w", maxRetries, err)
 		}
 
-		if len(resp.Data) == 0 {
-			lastError = fmt.Errorf("no image generated")
-			continue
-		}
-
-		imageURL := resp.Data[0].URL
-
-		// Descargar imagen y guardar localmente
-		localPath, err := downloadImage(imageURL, avatar.Nombre)
+		// Guardar imagen localmente
+		localPath, err := saveImage(resp.Data, avatar.Nombre)
 		if err != nil {
-			lastError = fmt.Errorf("failed to download image: %w", err)
-			if attempt < maxRetries {
-				waitTime := time.Duration(attempt*2) * time.Second
-				log.Printf("⚠ Download error (attempt %d/%d): %v. Retrying in %v...", attempt, maxRetries, err, waitTime)
-				time.Sleep(waitTime)
-				continue
-			}
-			return "", lastError
+			return "", fmt.Errorf("failed to save image: %w", err)
 		}
 
 		log.Printf("✓ Generated avatar image for '%s' (tier %d⭐)", avatar.Nombre, avatar.Tier)
@@ -149,8 +138,8 @@ func buildFullPrompt(avatar Avatar) string {
 	return fullPrompt
 }
 
-// downloadImage descarga una imagen desde URL y la guarda localmente
-func downloadImage(url, nombre string) (string, error) {
+// saveImage guarda los bytes de una imagen generada localmente
+func saveImage(data []byte, nombre string) (string, error) {
 	// Crear directorio si no existe
 	outputDir := "output/images"
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
@@ -162,26 +151,8 @@ func downloadImage(url, nombre string) (string, error) {
 	filename := fmt.Sprintf("%s.png", slug)
 	filepath := filepath.Join(outputDir, filename)
 
-	// Descargar imagen
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", fmt.Errorf("failed to download image: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("bad status code: %d", resp.StatusCode)
-	}
-
 	// Guardar imagen
-	file, err := os.Create(filepath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create file: %w", err)
-	}
-	defer file.Close()
-
-	_, err = io.Copy(file, resp.Body)
-	if err != nil {
+	if err := os.WriteFile(filepath, data, 0644); err != nil {
 		return "", fmt.Errorf("failed to write image: %w", err)
 	}
 
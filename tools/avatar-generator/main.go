@@ -28,8 +28,8 @@ func main() {
 		log.Fatalf("❌ Database connection failed: %v", err)
 	}
 
-	// Initialize OpenAI
-	generator.InitOpenAI()
+	// Initialize LLM client
+	generator.InitLLMClient()
 
 	// Read avatar configuration
 	configFile := "input/avatar_config.json"
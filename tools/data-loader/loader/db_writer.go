@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/platanus-hack-25/lumera_app/pkg/llm"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -26,6 +27,11 @@ func NewDBWriter(dsn string) (*DBWriter, error) {
 	return &DBWriter{db: db}, nil
 }
 
+// LLMRecorder retorna un registrador de llamadas LLM sobre la misma conexión
+func (w *DBWriter) LLMRecorder() llm.Recorder {
+	return llm.NewGormRecorder(w.db)
+}
+
 // Close cierra la conexión a la BD
 func (w *DBWriter) Close() error {
 	sqlDB, err := w.db.DB()
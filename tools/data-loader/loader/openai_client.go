@@ -6,7 +6,7 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/sashabaranov/go-openai"
+	"github.com/platanus-hack-25/lumera_app/pkg/llm"
 )
 
 const systemPrompt = `Eres un especialista en diseño instruccional, currículo chileno de enseñanza media y aprendizaje adaptativo.
@@ -78,18 +78,18 @@ Input: un solo Objetivo de Aprendizaje (texto simple).
 
 Output: lista JSON de subobjetivos con sus indicadores de logro, tipo_actividad_sugerida y complejidad_estimada, categorizados por nivel de la Taxonomía de Bloom.`
 
-// OpenAIClient wrapper para el cliente de OpenAI
+// OpenAIClient genera objetivos de Bloom usando un llm.LLMClient (OpenAI, cassette o fake)
 type OpenAIClient struct {
-	client     *openai.Client
+	client     llm.LLMClient
 	model      string
 	timeout    time.Duration
 	maxRetries int
 }
 
-// NewOpenAIClient crea una nueva instancia del cliente OpenAI
-func NewOpenAIClient(apiKey, model string, timeout time.Duration, maxRetries int) *OpenAIClient {
+// NewOpenAIClient crea una nueva instancia del generador sobre el cliente LLM dado
+func NewOpenAIClient(client llm.LLMClient, model string, timeout time.Duration, maxRetries int) *OpenAIClient {
 	return &OpenAIClient{
-		client:     openai.NewClient(apiKey),
+		client:     client,
 		model:      model,
 		timeout:    timeout,
 		maxRetries: maxRetries,
@@ -101,18 +101,19 @@ func (c *OpenAIClient) GenerateBloomObjectives(objetivo string) (OpenAIResponse,
 	var lastErr error
 
 	for attempt := 1; attempt <= c.maxRetries; attempt++ {
-		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+		ctx := llm.WithAttempt(llm.WithFeature(context.Background(), llm.FeatureOADataLoader), attempt)
+		ctx, cancel := context.WithTimeout(ctx, c.timeout)
 		defer cancel()
 
-		resp, err := c.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		resp, err := c.client.Chat(ctx, llm.ChatRequest{
 			Model: c.model,
-			Messages: []openai.ChatCompletionMessage{
+			Messages: []llm.Message{
 				{
-					Role:    openai.ChatMessageRoleSystem,
+					Role:    llm.RoleSystem,
 					Content: systemPrompt,
 				},
 				{
-					Role:    openai.ChatMessageRoleUser,
+					Role:    llm.RoleUser,
 					Content: objetivo,
 				},
 			},
@@ -129,16 +130,7 @@ func (c *OpenAIClient) GenerateBloomObjectives(objetivo string) (OpenAIResponse,
 			return nil, lastErr
 		}
 
-		if len(resp.Choices) == 0 {
-			lastErr = fmt.Errorf("intento %d/%d: respuesta de OpenAI vacía", attempt, c.maxRetries)
-			if attempt < c.maxRetries {
-				time.Sleep(time.Duration(attempt*attempt) * time.Second)
-				continue
-			}
-			return nil, lastErr
-		}
-
-		content := resp.Choices[0].Message.Content
+		content := resp.Content
 
 		// Parsear JSON
 		var bloomObjectives OpenAIResponse
@@ -12,6 +12,7 @@ import (
 
 	"github.com/joho/godotenv"
 	"github.com/platanus-hack-25/lumera_app/data-loader/loader"
+	"github.com/platanus-hack-25/lumera_app/pkg/llm"
 )
 
 func main() {
@@ -24,12 +25,6 @@ func main() {
 		log.Println("⚠️  Archivo .env no encontrado, usando variables de entorno del sistema")
 	}
 
-	// Validar variables requeridas
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		log.Fatal("❌ Error: OPENAI_API_KEY no está configurada en .env")
-	}
-
 	// Configuración OpenAI
 	model := getEnvOrDefault("OPENAI_MODEL", "gpt-4o-mini")
 	timeoutSeconds := getEnvOrDefaultInt("OPENAI_TIMEOUT_SECONDS", 30)
@@ -50,14 +45,20 @@ func main() {
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		dbHost, dbPort, dbUser, dbPassword, dbName, dbSSLMode)
 
-	// Inicializar clientes
+	// Inicializar clientes (LLM_MODE: openai, record, replay, auto)
 	log.Println("🔧 Inicializando clientes...")
-	openaiClient := loader.NewOpenAIClient(apiKey, model, time.Duration(timeoutSeconds)*time.Second, maxRetries)
+	llmClient, err := llm.NewFromEnv()
+	if err != nil {
+		log.Fatalf("❌ Error inicializando cliente LLM: %v", err)
+	}
 	dbWriter, err := loader.NewDBWriter(dsn)
 	if err != nil {
 		log.Fatalf("❌ Error conectando a BD: %v", err)
 	}
 	defer dbWriter.Close()
+	// Cada llamada queda registrada en llm_calls
+	llmClient = llm.NewMeteredClient(llmClient, dbWriter.LLMRecorder())
+	openaiClient := loader.NewOpenAIClient(llmClient, model, time.Duration(timeoutSeconds)*time.Second, maxRetries)
 
 	// Leer CSV
 	log.Printf("📂 Leyendo archivo CSV: %s\n", *inputFile)
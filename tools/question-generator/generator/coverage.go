@@ -0,0 +1,479 @@
+package generator
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// TargetCell define cuántas preguntas se esperan para una combinación tipo / uso / dificultad
+type TargetCell struct {
+	Tipo       string `json:"tipo"`
+	TipoUso    string `json:"tipo_uso"`
+	Dificultad int    `json:"dificultad"`
+	Cantidad   int    `json:"cantidad"`
+}
+
+// ItemAnalysisConfig define los umbrales del análisis de ítems
+type ItemAnalysisConfig struct {
+	MinRespuestas     int     `json:"min_respuestas"`
+	PMin              float64 `json:"p_min"`
+	PMax              float64 `json:"p_max"`
+	DiscriminacionMin float64 `json:"discriminacion_min"`
+}
+
+// CoverageTargets es la matriz objetivo de cobertura del banco de preguntas.
+// Las celdas se resuelven por objetivo (override), luego por nivel de Bloom y
+// finalmente por la lista default.
+type CoverageTargets struct {
+	ItemAnalysis ItemAnalysisConfig    `json:"item_analysis"`
+	Default      []TargetCell          `json:"default"`
+	Niveles      map[int][]TargetCell  `json:"niveles"`
+	Objetivos    map[uint][]TargetCell `json:"objetivos,omitempty"`
+}
+
+// LoadCoverageTargets lee la matriz objetivo desde un archivo JSON
+func LoadCoverageTargets(path string) (*CoverageTargets, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read targets file: %w", err)
+	}
+
+	var targets CoverageTargets
+	if err := json.Unmarshal(data, &targets); err != nil {
+		return nil, fmt.Errorf("failed to parse targets file: %w", err)
+	}
+
+	for _, cells := range append([][]TargetCell{targets.Default}, flattenCells(targets)...) {
+		for _, cell := range cells {
+			if cell.Tipo == "" || cell.Cantidad < 0 {
+				return nil, fmt.Errorf("invalid target cell: %+v", cell)
+			}
+			if cell.Dificultad < 1 || cell.Dificultad > 5 {
+				return nil, fmt.Errorf("invalid dificultad %d for tipo %s", cell.Dificultad, cell.Tipo)
+			}
+			switch cell.TipoUso {
+			case "diagnostico", "practica", "evaluacion", "all":
+			default:
+				return nil, fmt.Errorf("invalid tipo_uso %q for tipo %s", cell.TipoUso, cell.Tipo)
+			}
+		}
+	}
+
+	return &targets, nil
+}
+
+func flattenCells(targets CoverageTargets) [][]TargetCell {
+	var all [][]TargetCell
+	for _, cells := range targets.Niveles {
+		all = append(all, cells)
+	}
+	for _, cells := range targets.Objetivos {
+		all = append(all, cells)
+	}
+	return all
+}
+
+// CellsFor retorna las celdas objetivo aplicables a un OA-Bloom objective
+func (t *CoverageTargets) CellsFor(objective OABloomObjective) []TargetCell {
+	if cells, ok := t.Objetivos[objective.ID]; ok {
+		return cells
+	}
+	if cells, ok := t.Niveles[objective.BloomLevelNumero]; ok {
+		return cells
+	}
+	return t.Default
+}
+
+// ItemStats contiene el análisis clásico de un ítem (dificultad p y discriminación)
+type ItemStats struct {
+	QuestionID         uint     `json:"question_id"`
+	OABloomObjectiveID uint     `json:"oa_bloom_objective_id"`
+	Tipo               string   `json:"tipo"`
+	Respuestas         int      `json:"respuestas"`
+	PValue             float64  `json:"p_value"`
+	Discriminacion     *float64 `json:"discriminacion,omitempty"`
+	Motivos            []string `json:"motivos,omitempty"`
+}
+
+// Flagged indica si el análisis de ítems marcó la pregunta
+func (s ItemStats) Flagged() bool {
+	return len(s.Motivos) > 0
+}
+
+// AnalyzeItems calcula p-value y discriminación (punto-biserial contra el
+// puntaje del resto de la sesión) para cada pregunta con respuestas
+func AnalyzeItems(responses []ItemResponse, cfg ItemAnalysisConfig) map[uint]*ItemStats {
+	type accum struct {
+		n, correct int
+		xs, ys     []float64
+	}
+	byQuestion := make(map[uint]*accum)
+
+	for _, r := range responses {
+		a, ok := byQuestion[r.QuestionID]
+		if !ok {
+			a = &accum{}
+			byQuestion[r.QuestionID] = a
+		}
+		x := 0.0
+		if r.IsCorrect {
+			x = 1
+			a.correct++
+		}
+		a.n++
+		if r.SessionTotal > 1 {
+			rest := (float64(r.SessionCorrectas) - x) / float64(r.SessionTotal-1)
+			a.xs = append(a.xs, x)
+			a.ys = append(a.ys, rest)
+		}
+	}
+
+	stats := make(map[uint]*ItemStats, len(byQuestion))
+	for questionID, a := range byQuestion {
+		s := &ItemStats{
+			QuestionID: questionID,
+			Respuestas: a.n,
+			PValue:     float64(a.correct) / float64(a.n),
+		}
+		if r, ok := pearson(a.xs, a.ys); ok {
+			s.Discriminacion = &r
+		}
+
+		if a.n >= cfg.MinRespuestas {
+			if s.PValue < cfg.PMin {
+				s.Motivos = append(s.Motivos, "muy_dificil")
+			}
+			if s.PValue > cfg.PMax {
+				s.Motivos = append(s.Motivos, "muy_facil")
+			}
+			if s.Discriminacion != nil && *s.Discriminacion < cfg.DiscriminacionMin {
+				s.Motivos = append(s.Motivos, "baja_discriminacion")
+			}
+		}
+		stats[questionID] = s
+	}
+
+	return stats
+}
+
+// pearson calcula la correlación entre xs e ys; false si alguna varianza es cero
+func pearson(xs, ys []float64) (float64, bool) {
+	n := float64(len(xs))
+	if n < 2 {
+		return 0, false
+	}
+
+	var sumX, sumY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var cov, varX, varY float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return 0, false
+	}
+
+	return cov / math.Sqrt(varX*varY), true
+}
+
+// CellCoverage compara una celda objetivo con lo que ya existe en el banco
+type CellCoverage struct {
+	TargetCell
+	Cubiertas int `json:"cubiertas"`
+	Marcadas  int `json:"marcadas"`
+	Faltantes int `json:"faltantes"`
+}
+
+// ObjectiveCoverage resume la cobertura de un OA-Bloom objective
+type ObjectiveCoverage struct {
+	OABloomObjectiveID uint           `json:"oa_bloom_objective_id"`
+	MateriaNombre      string         `json:"materia"`
+	CursoNombre        string         `json:"curso"`
+	OATitulo           string         `json:"oa_titulo"`
+	BloomLevelNumero   int            `json:"bloom_level"`
+	TotalActivas       int            `json:"total_activas"`
+	PorTipo            map[string]int `json:"por_tipo"`
+	PorTipoUso         map[string]int `json:"por_tipo_uso"`
+	PorDificultad      map[int]int    `json:"por_dificultad"`
+	Marcadas           []ItemStats    `json:"marcadas,omitempty"`
+	Celdas             []CellCoverage `json:"celdas"`
+	Objetivo           int            `json:"objetivo"`
+	Faltantes          int            `json:"faltantes"`
+}
+
+// CoverageReport es el reporte completo de cobertura del banco
+type CoverageReport struct {
+	GeneratedAt   time.Time           `json:"generated_at"`
+	TotalObjetivo int                 `json:"total_objetivo"`
+	TotalCubierto int                 `json:"total_cubierto"`
+	TotalFaltante int                 `json:"total_faltante"`
+	TotalMarcadas int                 `json:"total_marcadas"`
+	Objetivos     []ObjectiveCoverage `json:"objetivos"`
+}
+
+// BuildCoverageReport cruza el inventario del banco y el análisis de ítems con la matriz objetivo
+func BuildCoverageReport(objectives []OABloomObjective, targets *CoverageTargets) (*CoverageReport, error) {
+	inventory, err := GetQuestionInventory()
+	if err != nil {
+		return nil, err
+	}
+	responses, err := GetItemResponses()
+	if err != nil {
+		return nil, err
+	}
+
+	itemStats := AnalyzeItems(responses, targets.ItemAnalysis)
+
+	byObjective := make(map[uint][]BankItem)
+	for _, item := range inventory {
+		byObjective[item.OABloomObjectiveID] = append(byObjective[item.OABloomObjectiveID], item)
+	}
+
+	report := &CoverageReport{GeneratedAt: time.Now()}
+	for _, objective := range objectives {
+		coverage := computeObjectiveCoverage(objective, byObjective[objective.ID], targets.CellsFor(objective), itemStats)
+		report.TotalObjetivo += coverage.Objetivo
+		report.TotalFaltante += coverage.Faltantes
+		report.TotalCubierto += coverage.Objetivo - coverage.Faltantes
+		report.TotalMarcadas += len(coverage.Marcadas)
+		report.Objetivos = append(report.Objetivos, coverage)
+	}
+
+	return report, nil
+}
+
+func computeObjectiveCoverage(objective OABloomObjective, items []BankItem, cells []TargetCell, itemStats map[uint]*ItemStats) ObjectiveCoverage {
+	coverage := ObjectiveCoverage{
+		OABloomObjectiveID: objective.ID,
+		MateriaNombre:      objective.MateriaNombre,
+		CursoNombre:        objective.CursoNombre,
+		OATitulo:           objective.OATitulo,
+		BloomLevelNumero:   objective.BloomLevelNumero,
+		TotalActivas:       len(items),
+		PorTipo:            make(map[string]int),
+		PorTipoUso:         make(map[string]int),
+		PorDificultad:      make(map[int]int),
+	}
+
+	var healthy, flagged []BankItem
+	for _, item := range items {
+		coverage.PorTipo[item.Tipo]++
+		coverage.PorTipoUso[item.TipoUso]++
+		coverage.PorDificultad[item.DificultadRelativa]++
+
+		if s, ok := itemStats[item.ID]; ok && s.Flagged() {
+			s.OABloomObjectiveID = item.OABloomObjectiveID
+			s.Tipo = item.Tipo
+			coverage.Marcadas = append(coverage.Marcadas, *s)
+			flagged = append(flagged, item)
+			continue
+		}
+		healthy = append(healthy, item)
+	}
+
+	cubiertas := assignItems(cells, healthy)
+	marcadas := assignItems(cells, flagged)
+
+	for i, cell := range cells {
+		faltantes := cell.Cantidad - cubiertas[i]
+		if faltantes < 0 {
+			faltantes = 0
+		}
+		coverage.Celdas = append(coverage.Celdas, CellCoverage{
+			TargetCell: cell,
+			Cubiertas:  cubiertas[i],
+			Marcadas:   marcadas[i],
+			Faltantes:  faltantes,
+		})
+		coverage.Objetivo += cell.Cantidad
+		coverage.Faltantes += faltantes
+	}
+
+	return coverage
+}
+
+// assignItems reparte las preguntas entre las celdas sin contar una pregunta
+// dos veces: primero calzan por tipo_uso exacto y luego vía "all"
+func assignItems(cells []TargetCell, items []BankItem) []int {
+	counts := make([]int, len(cells))
+	used := make(map[uint]bool)
+
+	passes := []func(cell TargetCell, item BankItem) bool{
+		func(cell TargetCell, item BankItem) bool { return cell.TipoUso == item.TipoUso },
+		func(cell TargetCell, item BankItem) bool { return cell.TipoUso == "all" || item.TipoUso == "all" },
+	}
+
+	for _, matches := range passes {
+		for i, cell := range cells {
+			for _, item := range items {
+				if counts[i] >= cell.Cantidad {
+					break
+				}
+				if used[item.ID] || item.Tipo != cell.Tipo || item.DificultadRelativa != cell.Dificultad {
+					continue
+				}
+				if matches(cell, item) {
+					used[item.ID] = true
+					counts[i]++
+				}
+			}
+		}
+	}
+
+	return counts
+}
+
+// PlanItem es una unidad de trabajo del plan de generación
+type PlanItem struct {
+	OABloomObjectiveID uint   `json:"oa_bloom_objective_id"`
+	Tipo               string `json:"tipo"`
+	TipoUso            string `json:"tipo_uso"`
+	Dificultad         int    `json:"dificultad"`
+	Cantidad           int    `json:"cantidad"`
+	Motivo             string `json:"motivo"`
+}
+
+// GenerationPlan es el plan que consume el generador de preguntas
+type GenerationPlan struct {
+	GeneratedAt    time.Time  `json:"generated_at"`
+	TotalPreguntas int        `json:"total_preguntas"`
+	Items          []PlanItem `json:"items"`
+}
+
+// BuildGenerationPlan convierte los faltantes del reporte en un plan de generación.
+// Los faltantes que existen solo porque el análisis de ítems descartó preguntas
+// se marcan como reemplazo.
+func BuildGenerationPlan(report *CoverageReport) *GenerationPlan {
+	plan := &GenerationPlan{GeneratedAt: time.Now()}
+
+	for _, objective := range report.Objetivos {
+		for _, cell := range objective.Celdas {
+			if cell.Faltantes == 0 {
+				continue
+			}
+
+			reemplazo := cell.Marcadas
+			if reemplazo > cell.Faltantes {
+				reemplazo = cell.Faltantes
+			}
+			if faltante := cell.Faltantes - reemplazo; faltante > 0 {
+				plan.Items = append(plan.Items, planItemFromCell(objective.OABloomObjectiveID, cell, faltante, "faltante"))
+			}
+			if reemplazo > 0 {
+				plan.Items = append(plan.Items, planItemFromCell(objective.OABloomObjectiveID, cell, reemplazo, "reemplazo_marcada"))
+			}
+			plan.TotalPreguntas += cell.Faltantes
+		}
+	}
+
+	return plan
+}
+
+func planItemFromCell(objectiveID uint, cell CellCoverage, cantidad int, motivo string) PlanItem {
+	return PlanItem{
+		OABloomObjectiveID: objectiveID,
+		Tipo:               cell.Tipo,
+		TipoUso:            cell.TipoUso,
+		Dificultad:         cell.Dificultad,
+		Cantidad:           cantidad,
+		Motivo:             motivo,
+	}
+}
+
+// ItemsByObjective agrupa los ítems del plan por OA-Bloom objective
+func (p *GenerationPlan) ItemsByObjective() map[uint][]PlanItem {
+	grouped := make(map[uint][]PlanItem)
+	for _, item := range p.Items {
+		grouped[item.OABloomObjectiveID] = append(grouped[item.OABloomObjectiveID], item)
+	}
+	return grouped
+}
+
+// SavePlan guarda el plan de generación como JSON
+func SavePlan(plan *GenerationPlan, path string) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal plan: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadPlan lee un plan de generación previamente guardado
+func LoadPlan(path string) (*GenerationPlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan file: %w", err)
+	}
+
+	var plan GenerationPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan file: %w", err)
+	}
+	return &plan, nil
+}
+
+// SaveReportJSON guarda el reporte de cobertura completo como JSON
+func SaveReportJSON(report *CoverageReport, path string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// SaveReportCSV guarda una fila por celda objetivo, pensado para planillas de jefes de currículum
+func SaveReportCSV(report *CoverageReport, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	w.Write([]string{"materia", "curso", "oa_bloom_objective_id", "oa_titulo", "bloom_level",
+		"tipo", "tipo_uso", "dificultad", "objetivo", "cubiertas", "marcadas", "faltantes"})
+
+	objectives := append([]ObjectiveCoverage(nil), report.Objetivos...)
+	sort.SliceStable(objectives, func(i, j int) bool {
+		if objectives[i].MateriaNombre != objectives[j].MateriaNombre {
+			return objectives[i].MateriaNombre < objectives[j].MateriaNombre
+		}
+		return objectives[i].OABloomObjectiveID < objectives[j].OABloomObjectiveID
+	})
+
+	for _, objective := range objectives {
+		for _, cell := range objective.Celdas {
+			w.Write([]string{
+				objective.MateriaNombre,
+				objective.CursoNombre,
+				strconv.FormatUint(uint64(objective.OABloomObjectiveID), 10),
+				objective.OATitulo,
+				strconv.Itoa(objective.BloomLevelNumero),
+				cell.Tipo,
+				cell.TipoUso,
+				strconv.Itoa(cell.Dificultad),
+				strconv.Itoa(cell.Cantidad),
+				strconv.Itoa(cell.Cubiertas),
+				strconv.Itoa(cell.Marcadas),
+				strconv.Itoa(cell.Faltantes),
+			})
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
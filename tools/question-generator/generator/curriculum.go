@@ -0,0 +1,200 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lib/pq"
+	"github.com/platanus-hack-25/lumera_app/pkg/embeddings"
+	"github.com/platanus-hack-25/lumera_app/pkg/llm"
+	"github.com/platanus-hack-25/lumera_app/pkg/vectorstore"
+)
+
+// Documentos curriculares subidos por los docentes (ver /api/curriculum-documents en el backend).
+// Los pasajes más cercanos a cada objetivo se agregan al prompt y se guardan en questions.fuentes.
+var (
+	curriculumProvider embeddings.Provider
+	curriculumIndex    *vectorstore.MemoryIndex
+	curriculumChunks   map[uint]curriculumChunk
+
+	referencesMu    sync.Mutex
+	referencesCache = map[uint][]CurriculumCitation{}
+)
+
+// curriculumChunk es un fragmento cargado de curriculum_chunks con los datos de su documento
+type curriculumChunk struct {
+	ID             uint
+	DocumentID     uint
+	MateriaID      uint
+	OAID           *uint
+	Texto          string
+	Embedding      pq.Float32Array
+	EmbeddingModel string
+	Titulo         string
+	Fuente         string
+}
+
+// InitCurriculumReferences carga en memoria los fragmentos embebidos con el proveedor de EMBEDDINGS_PROVIDER.
+// Requiere ConnectDB. Sin documentos (o sin la tabla) las preguntas se generan sin referencias.
+func InitCurriculumReferences() {
+	if os.Getenv("CURRICULUM_RAG_ENABLED") == "false" {
+		log.Println("⚠ Curriculum references disabled (CURRICULUM_RAG_ENABLED=false)")
+		return
+	}
+
+	provider, err := embeddings.NewFromEnv()
+	if err != nil {
+		log.Printf("⚠ Curriculum references disabled: %v", err)
+		return
+	}
+	if openaiProvider, ok := provider.(*embeddings.OpenAIProvider); ok {
+		openaiProvider.WithRecorder(llm.NewGormRecorder(DB))
+	}
+
+	var rows []curriculumChunk
+	err = DB.Raw(`
+		SELECT c.id, c.document_id, c.materia_id, c.oa_id, c.texto, c.embedding, c.embedding_model,
+			d.titulo, COALESCE(d.fuente, '') AS fuente
+		FROM curriculum_chunks c
+		INNER JOIN curriculum_documents d ON d.id = c.document_id
+		WHERE c.embedding_model = ?
+	`, provider.Name()).Scan(&rows).Error
+	if err != nil {
+		log.Printf("⚠ Curriculum references disabled: failed to load chunks: %v", err)
+		return
+	}
+
+	index := vectorstore.NewMemoryIndex()
+	chunks := make(map[uint]curriculumChunk, len(rows))
+	vectors := make([]vectorstore.Chunk, len(rows))
+	for i, row := range rows {
+		chunks[row.ID] = row
+		vectors[i] = vectorstore.Chunk{
+			ID:         row.ID,
+			DocumentID: row.DocumentID,
+			MateriaID:  row.MateriaID,
+			OAID:       row.OAID,
+			Model:      row.EmbeddingModel,
+			Vector:     row.Embedding,
+		}
+	}
+	index.Add(context.Background(), vectors)
+
+	curriculumProvider = provider
+	curriculumIndex = index
+	curriculumChunks = chunks
+	log.Printf("✓ Curriculum references initialized (%d passages, %s)", len(rows), provider.Name())
+}
+
+// CurriculumReferences retorna los pasajes más cercanos al objetivo (de su OA o de toda la materia).
+// El resultado se memoriza por objetivo: todas sus preguntas usan las mismas referencias.
+func CurriculumReferences(objective OABloomObjective) []CurriculumCitation {
+	if curriculumIndex == nil || curriculumIndex.Len() == 0 {
+		return nil
+	}
+
+	referencesMu.Lock()
+	defer referencesMu.Unlock()
+	if citations, ok := referencesCache[objective.ID]; ok {
+		return citations
+	}
+
+	citations, err := searchCurriculum(objective)
+	if err != nil {
+		// Sin memorizar: el siguiente intento vuelve a buscar
+		log.Printf("⚠ Curriculum search failed for OA-Bloom #%d: %v", objective.ID, err)
+		return nil
+	}
+	referencesCache[objective.ID] = citations
+	return citations
+}
+
+func searchCurriculum(objective OABloomObjective) ([]CurriculumCitation, error) {
+	query := strings.Join([]string{objective.OATitulo, objective.OADescripcion, objective.ObjetivoEspecifico}, ". ")
+	ctx := llm.WithFeature(context.Background(), llm.FeatureCurriculumEmbedding)
+	vectors, err := curriculumProvider.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+
+	oaID := objective.OAID
+	matches, err := curriculumIndex.Search(ctx, vectors[0], vectorstore.Filter{
+		MateriaID: objective.MateriaID,
+		OAID:      &oaID,
+		Model:     curriculumProvider.Name(),
+	}, envInt("CURRICULUM_RAG_TOP_K", 4))
+	if err != nil {
+		return nil, err
+	}
+
+	minScore := 0.2
+	if value, err := strconv.ParseFloat(os.Getenv("CURRICULUM_RAG_MIN_SCORE"), 64); err == nil {
+		minScore = value
+	}
+
+	var citations []CurriculumCitation
+	for _, match := range matches {
+		chunk, ok := curriculumChunks[match.ChunkID]
+		if !ok || match.Score < minScore {
+			continue
+		}
+		citations = append(citations, CurriculumCitation{
+			Indice:      len(citations) + 1,
+			DocumentoID: chunk.DocumentID,
+			Titulo:      chunk.Titulo,
+			Fuente:      chunk.Fuente,
+			ChunkID:     chunk.ID,
+			Fragmento:   citationFragment(chunk.Texto),
+			Score:       match.Score,
+			Texto:       chunk.Texto,
+		})
+	}
+	return citations, nil
+}
+
+// CitationsJSON serializa las citas para questions.fuentes (nil sin citas)
+func CitationsJSON(citations []CurriculumCitation) []byte {
+	if len(citations) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(citations)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// referencesBlock es la sección del prompt con los pasajes (vacía sin referencias)
+func referencesBlock(citations []CurriculumCitation) string {
+	if len(citations) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("REFERENCIAS CURRICULARES (documentos oficiales cargados por los docentes):\n")
+	for _, citation := range citations {
+		fmt.Fprintf(&b, "[%d] %s\n%s\n\n", citation.Indice, citation.Titulo, citation.Texto)
+	}
+	b.WriteString("IMPORTANTE: La pregunta, la respuesta correcta y la explicación deben ser coherentes con estas referencias. Si algo las contradice, sigue las referencias.\n\n")
+	return b.String()
+}
+
+func citationFragment(text string) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	if len(runes) <= 300 {
+		return string(runes)
+	}
+	return string(runes[:300]) + "…"
+}
+
+func envInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -64,6 +66,7 @@ func GetOABloomObjectives() ([]OABloomObjective, error) {
 			oab.complejidad_estimada,
 			oa.titulo as oa_titulo,
 			oa.descripcion as oa_descripcion,
+			m.id as materia_id,
 			m.nombre as materia_nombre,
 			COALESCE(c.nombre, 'Sin curso asignado') as curso_nombre
 		FROM oa_bloom_objectives oab
@@ -84,13 +87,17 @@ func GetOABloomObjectives() ([]OABloomObjective, error) {
 	return objectives, nil
 }
 
-// InsertQuestions inserta preguntas en la base de datos en lotes
+// InsertQuestions inserta preguntas en la base de datos en lotes.
+// Solo se guardan las etiquetas de conceptos erróneos válidas para el catálogo de la materia.
+// Las preguntas marcadas por la moderación se insertan inactivas y se registran en moderation_flags.
 func InsertQuestions(questions []Question) error {
 	if len(questions) == 0 {
 		return nil
 	}
 
 	batchSize := 50
+	flagged := 0
+	activeCodes := map[uint]map[string]bool{}
 	for i := 0; i < len(questions); i += batchSize {
 		end := i + batchSize
 		if end > len(questions) {
@@ -100,8 +107,30 @@ func InsertQuestions(questions []Question) error {
 
 		err := DB.Transaction(func(tx *gorm.DB) error {
 			for _, q := range batch {
+				// Las etiquetas de conceptos erróneos se validan contra el catálogo activo al insertar
+				active, ok := activeCodes[q.OABloomObjectiveID]
+				if !ok {
+					var err error
+					if active, err = activeMisconceptionCodes(q.OABloomObjectiveID); err != nil {
+						return err
+					}
+					activeCodes[q.OABloomObjectiveID] = active
+				}
+				validationData, dropped, err := filterMisconceptionTags(q, active)
+				if err != nil {
+					return err
+				}
+				if len(dropped) > 0 {
+					log.Printf("⚠ Dropped misconception tags of a %s question for OA-Bloom #%d: %s",
+						q.Tipo, q.OABloomObjectiveID, strings.Join(dropped, ", "))
+				}
+				q.ValidationData = validationData
+
+				result, text := moderateQuestion(q)
+
 				// Insertar usando raw SQL para mejor control
-				result := tx.Exec(`
+				var id uint
+				err = tx.Raw(`
 					INSERT INTO questions (
 						oa_bloom_objective_id,
 						tipo,
@@ -110,14 +139,28 @@ func InsertQuestions(questions []Question) error {
 						validation_data,
 						dificultad_relativa,
 						tags,
+						fuentes,
 						activa,
 						created_at,
 						updated_at
-					) VALUES (?, ?, ?, ?, ?, ?, ?, true, NOW(), NOW())
-				`, q.OABloomObjectiveID, q.Tipo, q.TipoUso, q.QuestionData, q.ValidationData, q.DificultadRelativa, q.Tags)
+					) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())
+					RETURNING id
+				`, q.OABloomObjectiveID, q.Tipo, q.TipoUso, q.QuestionData, q.ValidationData, q.DificultadRelativa, q.Tags, jsonOrNull(q.Fuentes), result == nil).
+					Scan(&id).Error
 
-				if result.Error != nil {
-					return fmt.Errorf("failed to insert question: %w", result.Error)
+				if err != nil {
+					return fmt.Errorf("failed to insert question: %w", err)
+				}
+
+				if result != nil {
+					flagged++
+					if err := tx.Exec(`
+						INSERT INTO moderation_flags (origen, entidad_tipo, entidad_id, texto, categorias, motivo, clasificador, estado, created_at)
+						VALUES ('pregunta', 'question', ?, ?, ?, ?, ?, 'pendiente', NOW())
+					`, id, text, pq.StringArray(result.Categories), result.Reason, result.Classifier).Error; err != nil {
+						return fmt.Errorf("failed to record moderation flag: %w", err)
+					}
+					log.Printf("🚫 Question %d flagged by moderation, inserted inactive: %s", id, result.Reason)
 				}
 			}
 			return nil
@@ -130,13 +173,65 @@ func InsertQuestions(questions []Question) error {
 		log.Printf("✓ Inserted batch %d-%d questions", i+1, end)
 	}
 
-	log.Printf("✓ Successfully inserted %d questions", len(questions))
+	log.Printf("✓ Successfully inserted %d questions (%d pending moderation review)", len(questions), flagged)
 	return nil
 }
 
+// jsonOrNull evita insertar un JSON vacío en columnas jsonb opcionales
+func jsonOrNull(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
+
 // CountExistingQuestions cuenta cuántas preguntas ya existen para un OA-Bloom objective
 func CountExistingQuestions(oaBloomObjectiveID uint) (int64, error) {
 	var count int64
 	err := DB.Model(&Question{}).Where("oa_bloom_objective_id = ?", oaBloomObjectiveID).Count(&count).Error
 	return count, err
 }
+
+// GetQuestionInventory obtiene las preguntas activas del banco (sin contenido)
+func GetQuestionInventory() ([]BankItem, error) {
+	var items []BankItem
+	err := DB.Raw(`
+		SELECT id, oa_bloom_objective_id, tipo, tipo_uso, dificultad_relativa
+		FROM questions
+		WHERE activa = true
+		ORDER BY oa_bloom_objective_id, id
+	`).Scan(&items).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch question inventory: %w", err)
+	}
+
+	log.Printf("✓ Fetched %d active questions from bank", len(items))
+	return items, nil
+}
+
+// GetItemResponses obtiene las respuestas corregidas de diagnóstico y práctica
+// junto al resultado global de su sesión, para el análisis de ítems
+func GetItemResponses() ([]ItemResponse, error) {
+	var responses []ItemResponse
+	err := DB.Raw(`
+		SELECT da.question_id, da.is_correct,
+			ds.preguntas_correctas AS session_correctas,
+			ds.preguntas_totales AS session_total
+		FROM diagnostic_answers da
+		INNER JOIN diagnostic_sessions ds ON ds.id = da.session_id
+		WHERE da.is_correct IS NOT NULL
+		UNION ALL
+		SELECT pa.question_id, pa.is_correct,
+			ps.preguntas_correctas AS session_correctas,
+			ps.preguntas_respondidas AS session_total
+		FROM practice_answers pa
+		INNER JOIN practice_sessions ps ON ps.id = pa.session_id
+		WHERE pa.is_correct IS NOT NULL
+	`).Scan(&responses).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch item responses: %w", err)
+	}
+
+	log.Printf("✓ Fetched %d graded responses for item analysis", len(responses))
+	return responses, nil
+}
@@ -0,0 +1,149 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Catálogo de conceptos erróneos de cada materia (ver /api/misconceptions en el backend). Las preguntas
+// multiple_choice etiquetan sus distractores en validation_data.conceptos_erroneos ({"B": ["FRAC-SUMA"]}).
+const misconceptionTagsKey = "conceptos_erroneos"
+
+var (
+	misconceptionsMu    sync.Mutex
+	misconceptionsCache = map[uint][]Misconception{}
+)
+
+// MateriaMisconceptions retorna el catálogo activo de la materia (memorizado por materia).
+// Sin base de datos o sin la tabla las preguntas se generan sin etiquetas.
+func MateriaMisconceptions(materiaID uint) []Misconception {
+	if DB == nil {
+		return nil
+	}
+
+	misconceptionsMu.Lock()
+	defer misconceptionsMu.Unlock()
+	if catalog, ok := misconceptionsCache[materiaID]; ok {
+		return catalog
+	}
+
+	var catalog []Misconception
+	err := DB.Raw(`
+		SELECT codigo, concepto, confundido_con, COALESCE(descripcion, '') AS descripcion
+		FROM misconceptions
+		WHERE materia_id = ? AND activo = true
+		ORDER BY codigo
+	`, materiaID).Scan(&catalog).Error
+	if err != nil {
+		// Sin memorizar: el siguiente intento vuelve a consultar
+		log.Printf("⚠ Failed to load misconceptions of materia #%d: %v", materiaID, err)
+		return nil
+	}
+	misconceptionsCache[materiaID] = catalog
+	return catalog
+}
+
+// misconceptionsBlock es la sección del prompt multiple_choice con el catálogo (vacía sin catálogo)
+func misconceptionsBlock(catalog []Misconception) string {
+	if len(catalog) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("CONCEPTOS ERRÓNEOS FRECUENTES DE LA MATERIA (catálogo de los docentes):\n")
+	for _, misconception := range catalog {
+		fmt.Fprintf(&b, "- %s: confundir %s con %s", misconception.Codigo, misconception.Concepto, misconception.ConfundidoCon)
+		if misconception.Descripcion != "" {
+			fmt.Fprintf(&b, ". Ejemplo: %s", misconception.Descripcion)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(`Cuando un distractor refleje uno de estos errores, etiquétalo con su código en validation_data, p. ej. "conceptos_erroneos": {"A": ["CODIGO"]}. Usa SOLO estos códigos y nunca etiquetes la opción correcta.` + "\n\n")
+	return b.String()
+}
+
+// activeMisconceptionCodes retorna los códigos activos del catálogo de la materia del objetivo
+func activeMisconceptionCodes(oaBloomObjectiveID uint) (map[string]bool, error) {
+	var codes []string
+	err := DB.Raw(`
+		SELECT m.codigo
+		FROM misconceptions m
+		INNER JOIN objetivos_aprendizaje oa ON oa.materia_id = m.materia_id
+		INNER JOIN oa_bloom_objectives oab ON oab.oa_id = oa.id
+		WHERE oab.id = ? AND m.activo = true
+	`, oaBloomObjectiveID).Scan(&codes).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load misconception codes: %w", err)
+	}
+	active := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		active[code] = true
+	}
+	return active, nil
+}
+
+// filterMisconceptionTags deja en validation_data solo las etiquetas válidas: opciones incorrectas existentes
+// de una pregunta multiple_choice y códigos activos del catálogo. Retorna el validation_data resultante y los
+// códigos descartados.
+func filterMisconceptionTags(q Question, active map[string]bool) ([]byte, []string, error) {
+	var validation map[string]interface{}
+	if err := json.Unmarshal(q.ValidationData, &validation); err != nil {
+		return nil, nil, fmt.Errorf("invalid validation_data: %w", err)
+	}
+	raw, ok := validation[misconceptionTagsKey]
+	if !ok {
+		return q.ValidationData, nil, nil
+	}
+	delete(validation, misconceptionTagsKey)
+
+	var data struct {
+		Opciones map[string]interface{} `json:"opciones"`
+	}
+	json.Unmarshal(q.QuestionData, &data)
+	correct := fmt.Sprint(validation["respuesta_correcta"])
+
+	tags := make(map[string][]string)
+	var dropped []string
+	byLetter, _ := raw.(map[string]interface{})
+	for letter, value := range byLetter {
+		// El modelo a veces entrega un solo código como texto en vez de una lista
+		var codes []string
+		switch typed := value.(type) {
+		case string:
+			codes = []string{typed}
+		case []interface{}:
+			for _, code := range typed {
+				if text, ok := code.(string); ok {
+					codes = append(codes, text)
+				}
+			}
+		}
+
+		_, exists := data.Opciones[letter]
+		seen := make(map[string]bool)
+		for _, code := range codes {
+			code = strings.ToUpper(strings.TrimSpace(code))
+			if q.Tipo != "multiple_choice" || !exists || letter == correct || !active[code] {
+				dropped = append(dropped, code)
+				continue
+			}
+			if !seen[code] {
+				seen[code] = true
+				tags[letter] = append(tags[letter], code)
+			}
+		}
+	}
+	if len(tags) > 0 {
+		validation[misconceptionTagsKey] = tags
+	}
+	sort.Strings(dropped)
+
+	validationData, err := json.Marshal(validation)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal validation_data: %w", err)
+	}
+	return validationData, dropped, nil
+}
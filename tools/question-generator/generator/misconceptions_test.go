@@ -0,0 +1,80 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildPromptIncludesMisconceptionCatalog(t *testing.T) {
+	objective := cassetteObjective()
+	if prompt := BuildPrompt("multiple_choice", objective, 2); strings.Contains(prompt, misconceptionTagsKey) {
+		t.Error("prompt without catalog asks for misconception tags")
+	}
+
+	objective.ConceptosErroneos = []Misconception{
+		{Codigo: "POT-MULT", Concepto: "potencia", ConfundidoCon: "multiplicación por el exponente"},
+	}
+	prompt := BuildPrompt("multiple_choice", objective, 2)
+	if !strings.Contains(prompt, "- POT-MULT: confundir potencia con multiplicación por el exponente") ||
+		!strings.Contains(prompt, `"conceptos_erroneos"`) {
+		t.Errorf("multiple_choice prompt lacks the catalog:\n%s", prompt)
+	}
+	if prompt := BuildPrompt("true_false", objective, 2); strings.Contains(prompt, "POT-MULT") {
+		t.Error("true_false prompt includes the catalog")
+	}
+}
+
+func TestFilterMisconceptionTags(t *testing.T) {
+	questionData := []byte(`{"pregunta":"2^3 = ?","opciones":{"A":"6","B":"8","C":"5","D":"9"}}`)
+	active := map[string]bool{"POT-MULT": true, "POT-SUMA": true}
+
+	tests := []struct {
+		name       string
+		tipo       string
+		validation string
+		want       string
+		dropped    []string
+	}{
+		{
+			name:       "sin etiquetas",
+			tipo:       "multiple_choice",
+			validation: `{"respuesta_correcta":"B"}`,
+			want:       `{"respuesta_correcta":"B"}`,
+		},
+		{
+			name:       "etiquetas válidas normalizadas",
+			tipo:       "multiple_choice",
+			validation: `{"respuesta_correcta":"B","conceptos_erroneos":{"A":[" pot-mult","POT-MULT"],"C":"POT-SUMA"}}`,
+			want:       `{"conceptos_erroneos":{"A":["POT-MULT"],"C":["POT-SUMA"]},"respuesta_correcta":"B"}`,
+		},
+		{
+			name:       "descarta correcta, opción inexistente y códigos fuera del catálogo",
+			tipo:       "multiple_choice",
+			validation: `{"respuesta_correcta":"B","conceptos_erroneos":{"B":["POT-MULT"],"E":["POT-SUMA"],"D":["INVENTADO"]}}`,
+			want:       `{"respuesta_correcta":"B"}`,
+			dropped:    []string{"INVENTADO", "POT-MULT", "POT-SUMA"},
+		},
+		{
+			name:       "solo multiple_choice",
+			tipo:       "true_false",
+			validation: `{"respuesta_correcta":true,"conceptos_erroneos":{"A":["POT-MULT"]}}`,
+			want:       `{"respuesta_correcta":true}`,
+			dropped:    []string{"POT-MULT"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := Question{Tipo: tt.tipo, QuestionData: questionData, ValidationData: []byte(tt.validation)}
+			got, dropped, err := filterMisconceptionTags(q, active)
+			if err != nil {
+				t.Fatalf("filter: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("validation_data = %s, want %s", got, tt.want)
+			}
+			if strings.Join(dropped, ",") != strings.Join(tt.dropped, ",") {
+				t.Errorf("dropped = %v, want %v", dropped, tt.dropped)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/platanus-hack-25/lumera_app/pkg/moderation"
+)
+
+var moderator moderation.Classifier
+
+// InitModeration inicializa el clasificador según MODERATION_MODE (auto, local, openai, off).
+// Las preguntas marcadas se insertan inactivas y quedan en moderation_flags para revisión.
+func InitModeration() {
+	classifier, err := moderation.NewFromEnv()
+	if err != nil {
+		log.Fatalf("❌ Moderation initialization failed: %v", err)
+	}
+	moderator = classifier
+	if classifier == nil {
+		log.Println("⚠ Moderation disabled (MODERATION_MODE=off)")
+		return
+	}
+	log.Printf("✓ Moderation initialized (%s)", classifier.Name())
+}
+
+// SetModerator reemplaza el clasificador (p. ej. con un moderation.BlocklistClassifier en tests)
+func SetModerator(classifier moderation.Classifier) {
+	moderator = classifier
+}
+
+// moderateQuestion revisa el texto de la pregunta (enunciado, alternativas y explicaciones).
+// Retorna el veredicto solo si fue marcada; si el clasificador falla también se retorna uno marcado para que
+// la pregunta quede inactiva hasta que un admin la revise.
+func moderateQuestion(q Question) (*moderation.Result, string) {
+	if moderator == nil {
+		return nil, ""
+	}
+
+	var questionData, validationData interface{}
+	json.Unmarshal(q.QuestionData, &questionData)
+	json.Unmarshal(q.ValidationData, &validationData)
+	text := moderation.CollectText([]interface{}{questionData, validationData})
+
+	result, err := moderator.Classify(context.Background(), text)
+	if err != nil {
+		log.Printf("⚠ Moderation failed, inserting question inactive: %v", err)
+		return &moderation.Result{Flagged: true, Reason: "moderation unavailable: " + err.Error(), Classifier: moderator.Name()}, text
+	}
+	if !result.Flagged {
+		return nil, text
+	}
+	return result, text
+}
@@ -10,10 +10,10 @@ import (
 	"strings"
 	"time"
 
-	openai "github.com/sashabaranov/go-openai"
+	"github.com/platanus-hack-25/lumera_app/pkg/llm"
 )
 
-var openaiClient *openai.Client
+var llmClient llm.LLMClient
 
 // cleanMarkdownJSON removes markdown code block markers from JSON response
 func cleanMarkdownJSON(content string) string {
@@ -36,14 +36,20 @@ func cleanMarkdownJSON(content string) string {
 	return strings.TrimSpace(content)
 }
 
-// InitOpenAI inicializa el cliente de OpenAI
-func InitOpenAI() {
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		log.Fatal("OPENAI_API_KEY no está configurada en el archivo .env")
+// InitLLMClient inicializa el cliente LLM según LLM_MODE (openai, record, replay, auto).
+// Requiere ConnectDB: cada llamada queda registrada en llm_calls.
+func InitLLMClient() {
+	client, err := llm.NewFromEnv()
+	if err != nil {
+		log.Fatalf("❌ LLM client initialization failed: %v", err)
 	}
-	openaiClient = openai.NewClient(apiKey)
-	log.Println("✓ OpenAI client initialized")
+	llmClient = llm.NewMeteredClient(client, llm.NewGormRecorder(DB))
+	log.Println("✓ LLM client initialized")
+}
+
+// SetLLMClient reemplaza el cliente LLM (p. ej. con un llm.FakeClient en tests)
+func SetLLMClient(client llm.LLMClient) {
+	llmClient = client
 }
 
 // GenerateQuestion genera una pregunta usando OpenAI
@@ -69,22 +75,25 @@ func GenerateQuestion(questionType string, objective OABloomObjective, dificulta
 		}
 	}
 
+	objective.Referencias = CurriculumReferences(objective)
+	objective.ConceptosErroneos = MateriaMisconceptions(objective.MateriaID)
 	prompt := BuildPrompt(questionType, objective, dificultad)
 
 	var lastError error
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+		ctx := llm.WithAttempt(llm.WithFeature(context.Background(), llm.FeatureQuestionGeneration), attempt)
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
 		defer cancel()
 
-		resp, err := openaiClient.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		resp, err := llmClient.Chat(ctx, llm.ChatRequest{
 			Model: model,
-			Messages: []openai.ChatCompletionMessage{
+			Messages: []llm.Message{
 				{
-					Role:    openai.ChatMessageRoleSystem,
+					Role:    llm.RoleSystem,
 					Content: "Eres un experto en diseño de evaluaciones educativas para el currículo chileno de enseñanza media.",
 				},
 				{
-					Role:    openai.ChatMessageRoleUser,
+					Role:    llm.RoleUser,
 					Content: prompt,
 				},
 			},
@@ -103,15 +112,8 @@ func GenerateQuestion(questionType string, objective OABloomObjective, dificulta
 			return nil, fmt.Errorf("failed after %d attempts: %w", maxRetries, err)
 		}
 
-		if len(resp.Choices) == 0 {
-			lastError = fmt.Errorf("no response from OpenAI")
-			continue
-		}
-
-		content := resp.Choices[0].Message.Content
-
 		// Clean markdown code blocks if present
-		content = cleanMarkdownJSON(content)
+		content := cleanMarkdownJSON(resp.Content)
 
 		// Parse JSON response
 		var result map[string]interface{}
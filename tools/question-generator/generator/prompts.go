@@ -27,11 +27,11 @@ CONTEXTO EDUCATIVO:
 		objective.TipoActividadSugerida,
 		objective.ComplejidadEstimada,
 		dificultad,
-	)
+	) + referencesBlock(objective.Referencias)
 
 	switch questionType {
 	case "multiple_choice":
-		return baseContext + promptMultipleChoice
+		return baseContext + misconceptionsBlock(objective.ConceptosErroneos) + promptMultipleChoice
 	case "true_false":
 		return baseContext + promptTrueFalse
 	case "fill_blanks":
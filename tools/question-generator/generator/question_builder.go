@@ -9,74 +9,16 @@ import (
 	"github.com/lib/pq"
 )
 
-// QuestionTypeDistribution define qué tipos de pregunta generar para cada nivel de Bloom
-type QuestionTypeDistribution struct {
-	Types       []string
-	Counts      []int
-	Dificultad  []int // Dificultad relativa para cada tipo
-}
-
-// GetQuestionTypesForBloomLevel retorna la distribución de tipos de pregunta según nivel de Bloom
-func GetQuestionTypesForBloomLevel(bloomLevel int) QuestionTypeDistribution {
-	switch bloomLevel {
-	case 1: // Recordar
-		return QuestionTypeDistribution{
-			Types:      []string{"multiple_choice", "multiple_choice", "multiple_choice", "true_false", "fill_blanks"},
-			Counts:     []int{1, 1, 1, 1, 1},
-			Dificultad: []int{1, 2, 3, 1, 2},
-		}
-	case 2: // Comprender
-		return QuestionTypeDistribution{
-			Types:      []string{"multiple_choice", "multiple_choice", "true_false", "drag_drop_matching", "sequencing"},
-			Counts:     []int{1, 1, 1, 1, 1},
-			Dificultad: []int{2, 3, 2, 2, 3},
-		}
-	case 3: // Aplicar
-		return QuestionTypeDistribution{
-			Types:      []string{"multiple_choice", "multiple_choice", "drag_drop_matching", "sequencing", "open_ended"},
-			Counts:     []int{1, 1, 1, 1, 1},
-			Dificultad: []int{3, 4, 3, 3, 3},
-		}
-	case 4: // Analizar
-		return QuestionTypeDistribution{
-			Types:      []string{"compare_contrast", "compare_contrast", "open_ended", "concept_map", "concept_map"},
-			Counts:     []int{1, 1, 1, 1, 1},
-			Dificultad: []int{3, 4, 4, 3, 4},
-		}
-	case 5: // Evaluar
-		return QuestionTypeDistribution{
-			Types:      []string{"criteria_evaluation", "criteria_evaluation", "criteria_evaluation", "open_ended", "open_ended"},
-			Counts:     []int{1, 1, 1, 1, 1},
-			Dificultad: []int{4, 5, 5, 4, 5},
-		}
-	case 6: // Crear
-		return QuestionTypeDistribution{
-			Types:      []string{"open_ended", "open_ended", "concept_map", "concept_map", "open_ended"},
-			Counts:     []int{1, 1, 1, 1, 1},
-			Dificultad: []int{4, 5, 4, 5, 5},
-		}
-	default:
-		// Fallback genérico
-		return QuestionTypeDistribution{
-			Types:      []string{"multiple_choice", "true_false", "fill_blanks", "open_ended", "sequencing"},
-			Counts:     []int{1, 1, 1, 1, 1},
-			Dificultad: []int{2, 2, 2, 3, 3},
-		}
-	}
-}
-
-// GenerateQuestionsForObjective genera todas las preguntas para un OA-Bloom objective
-func GenerateQuestionsForObjective(objective OABloomObjective, stats *Stats) ([]Question, []FailedQuestion) {
-	distribution := GetQuestionTypesForBloomLevel(objective.BloomLevelNumero)
-
+// GenerateQuestionsForObjective genera las preguntas que el plan de cobertura pide para un OA-Bloom objective
+func GenerateQuestionsForObjective(objective OABloomObjective, items []PlanItem, stats *Stats) ([]Question, []FailedQuestion) {
 	var questions []Question
 	var failed []FailedQuestion
 
-	for i, questionType := range distribution.Types {
-		dificultad := distribution.Dificultad[i]
+	for _, item := range expandPlanItems(items) {
+		questionType, tipoUso, dificultad := item.Tipo, item.TipoUso, item.Dificultad
 
-		log.Printf("→ Generating %s (difficulty %d) for OA-Bloom #%d (%s - Bloom %d)",
-			questionType, dificultad, objective.ID, objective.MateriaNombre, objective.BloomLevelNumero)
+		log.Printf("→ Generating %s/%s (difficulty %d, %s) for OA-Bloom #%d (%s - Bloom %d)",
+			questionType, tipoUso, dificultad, item.Motivo, objective.ID, objective.MateriaNombre, objective.BloomLevelNumero)
 
 		stats.TotalAttempts++
 
@@ -87,6 +29,7 @@ func GenerateQuestionsForObjective(objective OABloomObjective, stats *Stats) ([]
 			stats.AddFail(FailedQuestion{
 				OABloomObjectiveID: objective.ID,
 				Tipo:               questionType,
+				TipoUso:            tipoUso,
 				Dificultad:         dificultad,
 				Error:              err.Error(),
 				Timestamp:          time.Now(),
@@ -94,6 +37,7 @@ func GenerateQuestionsForObjective(objective OABloomObjective, stats *Stats) ([]
 			failed = append(failed, FailedQuestion{
 				OABloomObjectiveID: objective.ID,
 				Tipo:               questionType,
+				TipoUso:            tipoUso,
 				Dificultad:         dificultad,
 				Error:              err.Error(),
 				Timestamp:          time.Now(),
@@ -102,12 +46,13 @@ func GenerateQuestionsForObjective(objective OABloomObjective, stats *Stats) ([]
 		}
 
 		// Construir Question struct
-		question, err := buildQuestionStruct(objective.ID, questionType, result, dificultad)
+		question, err := buildQuestionStruct(objective.ID, questionType, tipoUso, result, dificultad)
 		if err != nil {
 			log.Printf("✗ Failed to build question struct: %v", err)
 			stats.AddFail(FailedQuestion{
 				OABloomObjectiveID: objective.ID,
 				Tipo:               questionType,
+				TipoUso:            tipoUso,
 				Dificultad:         dificultad,
 				Error:              fmt.Sprintf("build error: %v", err),
 				Timestamp:          time.Now(),
@@ -115,6 +60,7 @@ func GenerateQuestionsForObjective(objective OABloomObjective, stats *Stats) ([]
 			failed = append(failed, FailedQuestion{
 				OABloomObjectiveID: objective.ID,
 				Tipo:               questionType,
+				TipoUso:            tipoUso,
 				Dificultad:         dificultad,
 				Error:              fmt.Sprintf("build error: %v", err),
 				Timestamp:          time.Now(),
@@ -122,6 +68,7 @@ func GenerateQuestionsForObjective(objective OABloomObjective, stats *Stats) ([]
 			continue
 		}
 
+		question.Fuentes = CitationsJSON(CurriculumReferences(objective))
 		questions = append(questions, question)
 		stats.AddSuccess(questionType)
 		log.Printf("✓ Successfully created %s question (difficulty %d)", questionType, dificultad)
@@ -130,8 +77,21 @@ func GenerateQuestionsForObjective(objective OABloomObjective, stats *Stats) ([]
 	return questions, failed
 }
 
+// expandPlanItems convierte cada ítem del plan en una entrada por pregunta a generar
+func expandPlanItems(items []PlanItem) []PlanItem {
+	var expanded []PlanItem
+	for _, item := range items {
+		for i := 0; i < item.Cantidad; i++ {
+			single := item
+			single.Cantidad = 1
+			expanded = append(expanded, single)
+		}
+	}
+	return expanded
+}
+
 // buildQuestionStruct convierte el resultado de OpenAI en un struct Question
-func buildQuestionStruct(oaBloomObjectiveID uint, questionType string, result map[string]interface{}, dificultad int) (Question, error) {
+func buildQuestionStruct(oaBloomObjectiveID uint, questionType, tipoUso string, result map[string]interface{}, dificultad int) (Question, error) {
 	// Extract question_data
 	questionDataMap, ok := result["question_data"].(map[string]interface{})
 	if !ok {
@@ -169,10 +129,10 @@ func buildQuestionStruct(oaBloomObjectiveID uint, questionType string, result ma
 		tags = pq.StringArray{"auto-generated"}
 	}
 
-	// Determine tipo_uso based on question type
-	// Most questions can be used for diagnostico, practica, and evaluacion
-	// But some specialized types are better for certain uses
-	tipoUso := "all" // diagnostico, practica, evaluacion
+	// tipo_uso comes from the coverage plan; "all" serves diagnostico, practica and evaluacion
+	if tipoUso == "" {
+		tipoUso = "all"
+	}
 
 	return Question{
 		OABloomObjectiveID: oaBloomObjectiveID,
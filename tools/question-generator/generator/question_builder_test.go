@@ -0,0 +1,82 @@
+package generator
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/platanus-hack-25/lumera_app/pkg/llm"
+)
+
+// useCassettes replays testdata/cassettes as the LLM client. LLM_MODE=record (with OPENAI_API_KEY) re-records them.
+func useCassettes(t *testing.T) {
+	t.Helper()
+	if os.Getenv("LLM_MODE") != string(llm.CassetteRecord) {
+		t.Setenv("LLM_MODE", string(llm.CassetteReplay))
+	}
+	t.Setenv("LLM_CASSETTE_DIR", "testdata/cassettes")
+	t.Setenv("OPENAI_MODEL", "gpt-4o-mini")
+	t.Setenv("OPENAI_MAX_RETRIES", "1")
+
+	client, err := llm.NewFromEnv()
+	if err != nil {
+		t.Fatalf("cassette client: %v", err)
+	}
+	previous := llmClient
+	SetLLMClient(client)
+	t.Cleanup(func() { llmClient = previous })
+}
+
+func cassetteObjective() OABloomObjective {
+	return OABloomObjective{
+		ID:                 7,
+		BloomLevelNumero:   3,
+		BloomLevelNombre:   "Aplicar",
+		ObjetivoEspecifico: "Resolver problemas de crecimiento con potencias de base natural",
+		OATitulo:           "Potencias",
+		OADescripcion:      "Mostrar que comprenden las potencias de base racional y exponente entero",
+		MateriaNombre:      "Matemática",
+		CursoNombre:        "1° Medio",
+	}
+}
+
+func TestGenerateQuestionsForObjectiveReplay(t *testing.T) {
+	useCassettes(t)
+
+	stats := &Stats{TypeCounts: map[string]int{}}
+	items := []PlanItem{
+		{OABloomObjectiveID: 7, Tipo: "multiple_choice", TipoUso: "practica", Dificultad: 2, Cantidad: 1, Motivo: "faltante"},
+		// Not recorded: replay misses and the item is reported as failed
+		{OABloomObjectiveID: 7, Tipo: "sequencing", Dificultad: 3, Cantidad: 1, Motivo: "faltante"},
+	}
+	questions, failed := GenerateQuestionsForObjective(cassetteObjective(), items, stats)
+
+	if len(questions) != 1 {
+		t.Fatalf("got %d questions, want 1", len(questions))
+	}
+	question := questions[0]
+	if question.Tipo != "multiple_choice" || question.TipoUso != "practica" || question.DificultadRelativa != 2 {
+		t.Errorf("question = %s/%s dificultad %d", question.Tipo, question.TipoUso, question.DificultadRelativa)
+	}
+	var questionData struct {
+		Pregunta string            `json:"pregunta"`
+		Opciones map[string]string `json:"opciones"`
+	}
+	if err := json.Unmarshal(question.QuestionData, &questionData); err != nil {
+		t.Fatalf("question_data: %v", err)
+	}
+	if !strings.HasPrefix(questionData.Pregunta, "Una población de bacterias") || len(questionData.Opciones) != 4 {
+		t.Errorf("question_data = %+v", questionData)
+	}
+	if string(question.ValidationData) != `{"respuesta_correcta":"C"}` {
+		t.Errorf("validation_data = %s", question.ValidationData)
+	}
+
+	if len(failed) != 1 || failed[0].Tipo != "sequencing" || !strings.Contains(failed[0].Error, "no cassette recording") {
+		t.Errorf("failed = %+v", failed)
+	}
+	if stats.SuccessCount != 1 || stats.FailCount != 1 || stats.TypeCounts["multiple_choice"] != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
@@ -19,8 +19,22 @@ type OABloomObjective struct {
 	ComplejidadEstimada   int
 	OATitulo              string
 	OADescripcion         string
+	MateriaID             uint
 	MateriaNombre         string
 	CursoNombre           string
+
+	// Pasajes curriculares incluidos en el prompt (ver CurriculumReferences)
+	Referencias []CurriculumCitation `gorm:"-"`
+	// Catálogo de conceptos erróneos de la materia para etiquetar distractores (ver MateriaMisconceptions)
+	ConceptosErroneos []Misconception `gorm:"-"`
+}
+
+// Misconception es una entrada activa del catálogo de conceptos erróneos de una materia
+type Misconception struct {
+	Codigo        string
+	Concepto      string
+	ConfundidoCon string
+	Descripcion   string
 }
 
 // Question representa una pregunta para insertar en BD
@@ -32,12 +46,43 @@ type Question struct {
 	ValidationData     []byte // JSON
 	DificultadRelativa int
 	Tags               pq.StringArray
+	Fuentes            []byte // JSON: pasajes curriculares citados por el prompt
+}
+
+// CurriculumCitation es un pasaje de un documento curricular usado al generar una pregunta
+type CurriculumCitation struct {
+	Indice      int     `json:"indice"`
+	DocumentoID uint    `json:"documento_id"`
+	Titulo      string  `json:"titulo"`
+	Fuente      string  `json:"fuente,omitempty"`
+	ChunkID     uint    `json:"chunk_id"`
+	Fragmento   string  `json:"fragmento"`
+	Score       float64 `json:"score"`
+	Texto       string  `json:"-"` // pasaje completo, solo para el prompt
+}
+
+// BankItem es una pregunta existente del banco, sin su contenido
+type BankItem struct {
+	ID                 uint
+	OABloomObjectiveID uint
+	Tipo               string
+	TipoUso            string
+	DificultadRelativa int
+}
+
+// ItemResponse es una respuesta corregida junto al resultado de su sesión
+type ItemResponse struct {
+	QuestionID       uint
+	IsCorrect        bool
+	SessionCorrectas int
+	SessionTotal     int
 }
 
 // FailedQuestion representa una pregunta que falló al generarse
 type FailedQuestion struct {
 	OABloomObjectiveID uint      `json:"oa_bloom_objective_id"`
 	Tipo               string    `json:"tipo"`
+	TipoUso            string    `json:"tipo_uso,omitempty"`
 	Dificultad         int       `json:"dificultad"`
 	Error              string    `json:"error"`
 	Timestamp          time.Time `json:"timestamp"`
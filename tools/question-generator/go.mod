@@ -5,9 +5,9 @@ go 1.23
 require (
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
-	github.com/sashabaranov/go-openai v1.35.7
+	github.com/platanus-hack-25/lumera_app v0.0.0
 	gorm.io/driver/postgres v1.5.11
-	gorm.io/gorm v1.25.12
+	gorm.io/gorm v1.30.0
 )
 
 require (
@@ -17,7 +17,11 @@ require (
 	github.com/jackc/puddle/v2 v2.2.2 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
-	golang.org/x/crypto v0.31.0 // indirect
+	github.com/sashabaranov/go-openai v1.41.2 // indirect
+	golang.org/x/crypto v0.32.0 // indirect
 	golang.org/x/sync v0.10.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
 )
+
+// pkg/llm is shared with the backend module
+replace github.com/platanus-hack-25/lumera_app => ../../backend
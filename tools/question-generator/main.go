@@ -14,12 +14,15 @@ import (
 
 func main() {
 	// Flags
-	skipExisting := flag.Bool("skip-existing", true, "Skip objectives that already have questions")
+	targetsFile := flag.String("targets", "coverage_targets.json", "Coverage target matrix (JSON)")
+	planFile := flag.String("plan", "", "Use an existing generation plan instead of computing one")
+	reportOnly := flag.Bool("report-only", false, "Only compute and save the coverage report, do not generate")
 	batchSize := flag.Int("batch-size", 10, "Number of objectives to process before saving to database")
 	flag.Parse()
 
 	log.Println("=== Question Generator for Lumera App ===")
-	log.Printf("Skip existing: %v", *skipExisting)
+	log.Printf("Targets: %s", *targetsFile)
+	log.Printf("Report only: %v", *reportOnly)
 	log.Printf("Batch size: %d objectives\n", *batchSize)
 
 	// Load .env
@@ -32,8 +35,38 @@ func main() {
 		log.Fatalf("❌ Database connection failed: %v", err)
 	}
 
-	// Initialize OpenAI
-	generator.InitOpenAI()
+	// Fetch all OA-Bloom objectives
+	log.Println("\n📚 Fetching OA-Bloom objectives from database...")
+	objectives, err := generator.GetOABloomObjectives()
+	if err != nil {
+		log.Fatalf("❌ Failed to fetch objectives: %v", err)
+	}
+	log.Printf("Found %d OA-Bloom objectives\n", len(objectives))
+
+	// Build or load the generation plan
+	var plan *generator.GenerationPlan
+	if *planFile != "" {
+		plan, err = generator.LoadPlan(*planFile)
+		if err != nil {
+			log.Fatalf("❌ Failed to load plan: %v", err)
+		}
+		log.Printf("📋 Loaded plan %s (%d questions)", *planFile, plan.TotalPreguntas)
+	} else {
+		plan = buildPlan(objectives, *targetsFile, *reportOnly)
+		if plan == nil {
+			return
+		}
+	}
+
+	if plan.TotalPreguntas == 0 {
+		log.Println("✓ Question bank already meets the coverage targets, nothing to generate")
+		return
+	}
+
+	// Initialize LLM client
+	generator.InitLLMClient()
+	generator.InitModeration()
+	generator.InitCurriculumReferences()
 
 	// Initialize stats
 	stats := &generator.Stats{
@@ -45,15 +78,8 @@ func main() {
 		StartTime:       time.Now(),
 	}
 
-	// Fetch all OA-Bloom objectives
-	log.Println("\n📚 Fetching OA-Bloom objectives from database...")
-	objectives, err := generator.GetOABloomObjectives()
-	if err != nil {
-		log.Fatalf("❌ Failed to fetch objectives: %v", err)
-	}
-
-	log.Printf("Found %d OA-Bloom objectives\n", len(objectives))
-	log.Printf("Expected to generate ~%d questions (5 per objective)\n", len(objectives)*5)
+	planByObjective := plan.ItemsByObjective()
+	log.Printf("Expected to generate %d questions for %d objectives\n", plan.TotalPreguntas, len(planByObjective))
 
 	// Process objectives
 	var allQuestions []generator.Question
@@ -64,19 +90,15 @@ func main() {
 			i+1, len(objectives), objective.ID, objective.OATitulo,
 			objective.BloomLevelNombre, objective.BloomLevelNumero)
 
-		// Skip if already has questions
-		if *skipExisting {
-			count, err := generator.CountExistingQuestions(objective.ID)
-			if err != nil {
-				log.Printf("⚠ Error checking existing questions: %v", err)
-			} else if count > 0 {
-				log.Printf("⏭ Skipping (already has %d questions)", count)
-				continue
-			}
+		// Skip if the plan has nothing for this objective
+		items, ok := planByObjective[objective.ID]
+		if !ok {
+			log.Printf("⏭ Skipping (coverage targets met)")
+			continue
 		}
 
 		// Generate questions
-		questions, failed := generator.GenerateQuestionsForObjective(objective, stats)
+		questions, failed := generator.GenerateQuestionsForObjective(objective, items, stats)
 		allQuestions = append(allQuestions, questions...)
 		allFailed = append(allFailed, failed...)
 
@@ -90,6 +112,7 @@ func main() {
 					allFailed = append(allFailed, generator.FailedQuestion{
 						OABloomObjectiveID: q.OABloomObjectiveID,
 						Tipo:               q.Tipo,
+						TipoUso:            q.TipoUso,
 						Dificultad:         q.DificultadRelativa,
 						Error:              "database insertion failed",
 						Timestamp:          time.Now(),
@@ -139,6 +162,78 @@ func main() {
 	printStats(stats)
 }
 
+// buildPlan computes the coverage report and, unless reportOnly is set, the generation plan.
+// Both are saved to output/ so curriculum leads can review them.
+func buildPlan(objectives []generator.OABloomObjective, targetsFile string, reportOnly bool) *generator.GenerationPlan {
+	targets, err := generator.LoadCoverageTargets(targetsFile)
+	if err != nil {
+		log.Fatalf("❌ Failed to load coverage targets: %v", err)
+	}
+
+	log.Println("\n🔎 Computing question bank coverage...")
+	report, err := generator.BuildCoverageReport(objectives, targets)
+	if err != nil {
+		log.Fatalf("❌ Failed to compute coverage: %v", err)
+	}
+
+	timestamp := time.Now().Format("20060102_150405")
+	reportJSON := fmt.Sprintf("output/coverage_report_%s.json", timestamp)
+	reportCSV := fmt.Sprintf("output/coverage_report_%s.csv", timestamp)
+	if err := generator.SaveReportJSON(report, reportJSON); err != nil {
+		log.Printf("⚠ Failed to save coverage report: %v", err)
+	}
+	if err := generator.SaveReportCSV(report, reportCSV); err != nil {
+		log.Printf("⚠ Failed to save coverage report CSV: %v", err)
+	}
+	log.Printf("📝 Saved coverage report to %s and %s", reportJSON, reportCSV)
+
+	printCoverage(report)
+
+	if reportOnly {
+		return nil
+	}
+
+	plan := generator.BuildGenerationPlan(report)
+	planPath := fmt.Sprintf("output/generation_plan_%s.json", timestamp)
+	if err := generator.SavePlan(plan, planPath); err != nil {
+		log.Printf("⚠ Failed to save generation plan: %v", err)
+	} else {
+		log.Printf("📝 Saved generation plan to %s (%d questions)", planPath, plan.TotalPreguntas)
+	}
+
+	return plan
+}
+
+func printCoverage(report *generator.CoverageReport) {
+	fmt.Println("\n" + repeat("=", 60))
+	fmt.Println("🔎 QUESTION BANK COVERAGE")
+	fmt.Println(repeat("=", 60))
+	fmt.Printf("Objectives:         %d\n", len(report.Objetivos))
+	fmt.Printf("Target questions:   %d\n", report.TotalObjetivo)
+	if report.TotalObjetivo > 0 {
+		fmt.Printf("✓ Covered:          %d (%.1f%%)\n",
+			report.TotalCubierto,
+			float64(report.TotalCubierto)/float64(report.TotalObjetivo)*100)
+	}
+	fmt.Printf("✗ Missing:          %d\n", report.TotalFaltante)
+	fmt.Printf("⚑ Flagged items:    %d\n", report.TotalMarcadas)
+
+	missingByMateria := make(map[string]int)
+	for _, objective := range report.Objetivos {
+		if objective.Faltantes > 0 {
+			missingByMateria[objective.MateriaNombre] += objective.Faltantes
+		}
+	}
+	if len(missingByMateria) > 0 {
+		fmt.Println("\n📋 Missing by Materia:")
+		for materia, count := range missingByMateria {
+			fmt.Printf("  - %-30s: %d\n", materia, count)
+		}
+	}
+
+	fmt.Println(repeat("=", 60))
+}
+
 func printStats(stats *generator.Stats) {
 	duration := stats.EndTime.Sub(stats.StartTime)
 
@@ -24,8 +24,10 @@ func main() {
 		log.Fatalf("❌ Database connection failed: %v", err)
 	}
 
-	// Initialize OpenAI
-	generator.InitOpenAI()
+	// Initialize LLM client
+	generator.InitLLMClient()
+	generator.InitModeration()
+	generator.InitCurriculumReferences()
 
 	// Read failed questions file
 	failedFile := "output/failed_questions_20251122_155646.json"
@@ -45,15 +47,21 @@ func main() {
 	type RetryItem struct {
 		OAID       uint
 		Tipo       string
+		TipoUso    string
 		Dificultad int
 	}
 	retryMap := make(map[string]RetryItem)
 
 	for _, failed := range failedQuestions {
-		key := fmt.Sprintf("%d_%s_%d", failed.OABloomObjectiveID, failed.Tipo, failed.Dificultad)
+		tipoUso := failed.TipoUso
+		if tipoUso == "" {
+			tipoUso = "all"
+		}
+		key := fmt.Sprintf("%d_%s_%s_%d", failed.OABloomObjectiveID, failed.Tipo, tipoUso, failed.Dificultad)
 		retryMap[key] = RetryItem{
 			OAID:       failed.OABloomObjectiveID,
 			Tipo:       failed.Tipo,
+			TipoUso:    tipoUso,
 			Dificultad: failed.Dificultad,
 		}
 	}
@@ -235,11 +243,12 @@ func main() {
 		question := generator.Question{
 			OABloomObjectiveID: retry.OAID,
 			Tipo:               retry.Tipo,
-			TipoUso:            "all",
+			TipoUso:            retry.TipoUso,
 			QuestionData:       questionDataJSON,
 			ValidationData:     validationDataJSON,
 			DificultadRelativa: retry.Dificultad,
 			Tags:               tags,
+			Fuentes:            generator.CitationsJSON(generator.CurriculumReferences(objective)),
 		}
 
 		allQuestions = append(allQuestions, question)